@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/apikey"
+)
+
+var (
+	apikeyName    string
+	apikeyScopes  string
+	apikeyExpires string
+)
+
+var apikeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage API keys for the daemon's HTTP API",
+	Long: `Create, list and revoke the keys clients of the HTTP API (see api_listen)
+authenticate with, sent as 'Authorization: Bearer <key>'.
+
+Each key grants scopes: 'read' to view progress, retries and rate limits,
+'trigger' to start syncs, 'report' for fleet machines to report their syncs
+to this daemon (see report_to), under the key's name as machine_id. Keys can expire, and take effect without
+restarting the daemon.`,
+}
+
+var apikeyCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an API key",
+	Long: `Create an API key with the given scopes. The key is shown once; only a
+hash of it is stored.
+
+Examples:
+  git sync apikey create --name dashboard --scopes read
+  git sync apikey create --name deploy --scopes read,trigger --expires 30d
+  git sync apikey create --name laptop --scopes report`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return createAPIKey(apikeyName, apikeyScopes, apikeyExpires)
+	},
+}
+
+var apikeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API keys",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listAPIKeys()
+	},
+}
+
+var apikeyRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke an API key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return revokeAPIKey(args[0])
+	},
+}
+
+func init() {
+	apikeyCreateCmd.Flags().StringVar(&apikeyName, "name", "", "what the key is for, shown in 'apikey list'")
+	apikeyCreateCmd.Flags().StringVar(&apikeyScopes, "scopes", apikey.ScopeRead,
+		"comma-separated scopes to grant (read, trigger, report)")
+	apikeyCreateCmd.Flags().StringVar(&apikeyExpires, "expires", "0",
+		"expire the key after this long (e.g. 30d, 12h); 0 never expires")
+
+	apikeyCmd.AddCommand(apikeyCreateCmd)
+	apikeyCmd.AddCommand(apikeyListCmd)
+	apikeyCmd.AddCommand(apikeyRevokeCmd)
+	rootCmd.AddCommand(apikeyCmd)
+}
+
+func createAPIKey(name, scopeList, expires string) error {
+	scopes, err := apikey.ParseScopes(scopeList)
+	if err != nil {
+		return err
+	}
+	ttl, err := parseExpiry(expires)
+	if err != nil {
+		return err
+	}
+
+	token, key, err := apikey.Create(name, scopes, ttl)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Created API key %s (%s)\n", key.ID, strings.Join(key.Scopes, ", "))
+	if !key.ExpiresAt.IsZero() {
+		fmt.Printf("  Expires: %s\n", key.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+	fmt.Printf("\n  %s\n\n", token)
+	fmt.Println("ℹ️  Store it now, it can't be shown again.")
+	return nil
+}
+
+func listAPIKeys() error {
+	keys, err := apikey.List()
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		fmt.Println("No API keys. Create one with 'git sync apikey create'.")
+		return nil
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		name := key.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		expiry := "never expires"
+		switch {
+		case key.Expired(now):
+			expiry = "⚠️ expired " + key.ExpiresAt.Format("2006-01-02 15:04")
+		case !key.ExpiresAt.IsZero():
+			expiry = "expires in " + formatUntil(key.ExpiresAt)
+		}
+		fmt.Printf("%s  %-16s %-14s created %s, %s\n",
+			key.ID, name, strings.Join(key.Scopes, ","), key.CreatedAt.Format("2006-01-02"), expiry)
+	}
+	return nil
+}
+
+func revokeAPIKey(id string) error {
+	revoked, err := apikey.Revoke(id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return fmt.Errorf("no API key with id %s", id)
+	}
+	fmt.Printf("✓ Revoked API key %s\n", id)
+	return nil
+}
+
+// parseExpiry parses a key lifetime: a Go duration, a number of days like 30d,
+// or 0 for no expiry
+func parseExpiry(expires string) (time.Duration, error) {
+	if expires == "" || expires == "0" {
+		return 0, nil
+	}
+	if days, isDays := strings.CutSuffix(expires, "d"); isDays {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid --expires '%s'", expires)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	ttl, err := time.ParseDuration(expires)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid --expires '%s': use e.g. 30d or 12h", expires)
+	}
+	return ttl, nil
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/state"
+)
+
+// maxForceWindow bounds how long force pushes can be armed at once
+const maxForceWindow = 24 * time.Hour
+
+var (
+	armForceFor    time.Duration
+	armForceDisarm bool
+)
+
+var armForceCmd = &cobra.Command{
+	Use:   "arm-force [repo]",
+	Short: "Temporarily allow force pushes for a repository",
+	Long: `Allow the daemon to force push a repository for a bounded window, instead of
+enabling force_push permanently. When the window ends the daemon goes back to
+safe pushes automatically, even across restarts.
+
+The repository defaults to the current directory.
+
+Examples:
+  git sync arm-force                 # Allow force pushes here for 1 hour
+  git sync arm-force ~/notes --for 15m
+  git sync arm-force --disarm        # End the window early`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		return armForcePush(target)
+	},
+}
+
+func init() {
+	armForceCmd.Flags().DurationVar(&armForceFor, "for", time.Hour,
+		"how long force pushes stay allowed (max 24h)")
+	armForceCmd.Flags().BoolVar(&armForceDisarm, "disarm", false,
+		"stop allowing force pushes now")
+	rootCmd.AddCommand(armForceCmd)
+}
+
+func armForcePush(target string) error {
+	repo, err := findConfiguredRepo(target)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	if armForceDisarm {
+		var wasArmed bool
+		if err := state.Update(func(s *state.State) {
+			wasArmed = s.DisarmForcePush(repo.Path, now)
+		}); err != nil {
+			return fmt.Errorf("failed to update state: %w", err)
+		}
+
+		if wasArmed {
+			fmt.Printf("✓ Force push disarmed for %s\n", filepath.Base(repo.Path))
+		} else {
+			fmt.Printf("Force push was not armed for %s\n", filepath.Base(repo.Path))
+		}
+		return nil
+	}
+
+	if armForceFor <= 0 || armForceFor > maxForceWindow {
+		return fmt.Errorf("--for must be positive and at most 24h")
+	}
+
+	until := now.Add(armForceFor)
+	if err := state.Update(func(s *state.State) {
+		s.ArmForcePush(repo.Path, until)
+	}); err != nil {
+		return fmt.Errorf("failed to update state: %w", err)
+	}
+
+	fmt.Printf("⚠️  Force push armed for %s until %s\n", filepath.Base(repo.Path), until.Format("2006-01-02 15:04:05"))
+	if repo.ForcePush {
+		fmt.Println("  Note: force_push is already enabled permanently in the config")
+	}
+	if repo.Direction == "pull" {
+		fmt.Println("  Note: this repository only pulls, so nothing will be pushed")
+	}
+	fmt.Println("  Run 'git sync arm-force --disarm' to end the window early.")
+
+	return nil
+}
+
+// findConfiguredRepo returns the configured repository containing target, or
+// the current directory when target is empty
+func findConfiguredRepo(target string) (config.RepoConfig, error) {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return config.RepoConfig{}, fmt.Errorf("failed to load config: %w", err)
+	}
+	return findRepoIn(cfg.ExpandPatterns(), target)
+}
+
+// findRepoIn returns the repository of cfg containing target, the current directory when empty
+func findRepoIn(cfg *config.Config, target string) (config.RepoConfig, error) {
+	if target == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return config.RepoConfig{}, fmt.Errorf("failed to get current directory: %w", err)
+		}
+		target = cwd
+	}
+
+	absPath, err := filepath.Abs(target)
+	if err != nil {
+		return config.RepoConfig{}, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	absPath = repoRoot(absPath)
+
+	if i, found := cfg.FindRepository(absPath); found {
+		return cfg.Repositories[i], nil
+	}
+	return config.RepoConfig{}, fmt.Errorf("repository %s is not configured for sync (run 'git sync init' there first)", absPath)
+}
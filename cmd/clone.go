@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/validation"
+)
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone <url> [path]",
+	Short: "Clone a repository and register it for sync",
+	Long: `Clone a repository and register it for sync in one step, like 'git clone'
+followed by 'git sync init' in the new checkout. The path defaults to a
+directory named after the repository in the current directory.
+
+The settings are prompted for, unless given as flags (the flags of
+'git sync init') or --non-interactive is set.
+
+Examples:
+  git sync clone git@github.com:user/notes.git
+  git sync clone https://github.com/user/dotfiles ~/.dotfiles -d pull -i 3600
+  git sync clone git@github.com:user/wiki.git --fetch-depth 50 --non-interactive`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		path := ""
+		if len(args) == 2 {
+			path = args[1]
+		}
+		return cloneAndRegister(cmd, args[0], path)
+	},
+}
+
+func init() {
+	cloneCmd.Flags().StringVarP(&direction, "direction", "d", "push",
+		"sync direction: push, pull, both")
+	cloneCmd.Flags().IntVarP(&interval, "interval", "i", 300,
+		"sync interval in seconds")
+	cloneCmd.Flags().StringVarP(&remote, "remote", "r", "origin",
+		"name of the remote the clone gets")
+	cloneCmd.Flags().StringVar(&branchStrategy, "branch-strategy", "current",
+		"branch strategy: current, main, all, specific")
+	cloneCmd.Flags().StringVar(&targetBranch, "target-branch", "",
+		"target branch name (required when using 'specific' branch strategy)")
+	cloneCmd.Flags().BoolVar(&safetyChecks, "safety-checks", true,
+		"enable safety checks before sync operations")
+	cloneCmd.Flags().BoolVar(&forcePush, "force", false,
+		"enable force push (use with caution)")
+	cloneCmd.Flags().StringVar(&dirtyAction, "dirty-action", "skip",
+		"action when the worktree has uncommitted changes: skip, stash, commit")
+	cloneCmd.Flags().Bool("non-interactive", false,
+		"run in non-interactive mode using flags or defaults")
+	cloneCmd.Flags().StringSliceVar(&unionMerge, "union-merge", nil,
+		"path patterns auto-merged with git's union driver on diverged pulls (e.g. '*.md,journal/*.txt')")
+	cloneCmd.Flags().IntVar(&fetchDepth, "fetch-depth", 0,
+		"clone and fetch only this many commits, 0 for full history")
+	rootCmd.AddCommand(cloneCmd)
+}
+
+func cloneAndRegister(cmd *cobra.Command, repoURL, path string) error {
+	if err := validation.ValidateGitURL(repoURL); err != nil {
+		return err
+	}
+	if path == "" {
+		path = cloneDirName(repoURL)
+		if path == "" {
+			return fmt.Errorf("cannot tell a directory name from '%s', pass a path", repoURL)
+		}
+	}
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	if err := cloneRepository(repoURL, dir, remote, fetchDepth); err != nil {
+		return err
+	}
+
+	nonInteractive, _ := cmd.Flags().GetBool("non-interactive")
+	if nonInteractive || hasConfigFlags(cmd) {
+		err = registerRepository(dir)
+	} else {
+		err = runInteractiveInit(dir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  The clone is kept, run 'git sync init' in %s to register it\n", dir)
+		return err
+	}
+	notifyDaemonReload()
+	return nil
+}
+
+// cloneDirName is the directory git clone would create for repoURL, e.g.
+// "notes" for git@github.com:user/notes.git
+func cloneDirName(repoURL string) string {
+	name := strings.TrimSuffix(strings.TrimRight(repoURL, "/"), "/.git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, ".git")
+	if name == "" || name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
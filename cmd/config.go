@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/prompt"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config [repo]",
+	Short: "Change the sync settings of a configured repository interactively",
+	Long: `Run the interactive prompts of 'git sync init' again for a repository that is
+already configured, with its current settings preselected, so changing its
+interval or branch strategy doesn't require editing the TOML file. Settings
+the prompts don't cover, such as a schedule or webhook, are kept as they are.
+
+The repository defaults to the current directory.
+
+Examples:
+  git sync config           # Reconfigure the current repository
+  git sync config ~/notes`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		return reconfigureRepository(target)
+	},
+}
+
+var (
+	configFormat string
+	configOutput string
+	configYes    bool
+)
+
+var configExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the config to move it to another machine",
+	Long: `Write the config, with every default filled in and its schema version, to
+stdout or a file, for 'git sync config import' on another machine. The
+machine_id is left out, as it names this machine. Webhook secrets and tokens
+are included, so keep the export private.
+
+Examples:
+  git sync config export > git-sync.toml
+  git sync config export --format json
+  git sync config export -o ~/sync/git-sync.yaml   # Format from the extension`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return exportConfig(configFormat, configOutput)
+	},
+}
+
+var configImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Replace the config with an exported one",
+	Long: `Replace the config with one written by 'git sync config export', or with a
+plain config file. Configs of older schema versions are migrated to the
+current one. The import is validated first: it is refused when it has errors,
+while repository paths missing on this machine are only warned about. The
+current config is kept as a .bak file next to it.
+
+The format is told from the file extension unless --format is given.
+
+Examples:
+  git sync config import git-sync.toml
+  git sync config import ~/sync/git-sync.yaml --yes`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return importConfig(args[0], configFormat)
+	},
+}
+
+func init() {
+	configExportCmd.Flags().StringVarP(&configFormat, "format", "f", "",
+		"export format: toml, json, yaml (default from the output extension, else toml)")
+	configExportCmd.Flags().StringVarP(&configOutput, "output", "o", "",
+		"write to this file instead of stdout")
+	configImportCmd.Flags().StringVarP(&configFormat, "format", "f", "",
+		"format of the file: toml, json, yaml (default from its extension)")
+	configImportCmd.Flags().BoolVarP(&configYes, "yes", "y", false,
+		"replace the current config without asking")
+
+	configCmd.AddCommand(configExportCmd)
+	configCmd.AddCommand(configImportCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func reconfigureRepository(target string) error {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	// A repository a path glob matches is saved with an entry of its own
+	cfg = cfg.ExpandPatterns()
+	repo, err := findRepoIn(cfg, target)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("🔄 Git Sync Reconfiguration")
+	fmt.Println("Change the sync settings, current ones are preselected:")
+	fmt.Println()
+	fmt.Printf("📂 Repository: %s\n", repo.Path)
+	fmt.Println()
+
+	current := cfg.Global.WithGlobalDefaults(repo)
+	proceed, err := promptSyncSettings(prompt.New(), repo.Path, current)
+	if err != nil || !proceed {
+		return err
+	}
+
+	if err := verifyRemoteExists(repo.Path, remote); err != nil {
+		return err
+	}
+	if branchStrategy == "specific" {
+		if err := verifyBranchExists(repo.Path, targetBranch); err != nil {
+			return err
+		}
+	}
+	fetchDepth = repo.FetchDepth
+	if err := validateConfigCombination(); err != nil {
+		return err
+	}
+
+	updated := repo
+	updated.Direction = direction
+	updated.Remote = remote
+	updated.BranchStrategy = branchStrategy
+	updated.TargetBranch = targetBranch
+	updated.SafetyChecks = safetyChecks
+	updated.ForcePush = forcePush
+	// Keep unset settings unset when their default was chosen again
+	if repo.DirtyWorktreeAction != "" || dirtyAction != "skip" {
+		updated.DirtyWorktreeAction = dirtyAction
+	}
+	if repo.Interval > 0 || interval != cfg.Global.DefaultInterval {
+		updated.Interval = interval
+	}
+
+	changes := settingChanges(current, cfg.Global.WithGlobalDefaults(updated))
+	if len(changes) == 0 {
+		fmt.Println("ℹ️  Nothing changed")
+		return nil
+	}
+	if err := config.AddRepository(updated, configFile); err != nil {
+		return fmt.Errorf("failed to update repository config: %w", err)
+	}
+
+	fmt.Println("✓ Repository reconfigured")
+	for _, change := range changes {
+		fmt.Printf("  %s\n", change)
+	}
+	notifyDaemonReload()
+	return nil
+}
+
+// settingChanges describes the prompted settings that differ between before and after
+func settingChanges(before, after config.RepoConfig) []string {
+	var changes []string
+	add := func(name, from, to string) {
+		if from != to {
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", name, orNone(from), orNone(to)))
+		}
+	}
+	add("Direction", before.Direction, after.Direction)
+	add("Interval", strconv.Itoa(before.Interval)+"s", strconv.Itoa(after.Interval)+"s")
+	add("Remote", before.Remote, after.Remote)
+	add("Branch Strategy", before.BranchStrategy, after.BranchStrategy)
+	add("Target Branch", before.TargetBranch, after.TargetBranch)
+	add("Safety Checks", strconv.FormatBool(before.SafetyChecks), strconv.FormatBool(after.SafetyChecks))
+	add("Dirty Worktree Action", before.DirtyWorktreeAction, after.DirtyWorktreeAction)
+	add("Force Push", strconv.FormatBool(before.ForcePush), strconv.FormatBool(after.ForcePush))
+	return changes
+}
+
+// orNone shows empty settings as "none"
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+func exportConfig(format, output string) error {
+	if format == "" {
+		format = config.FormatOf(output)
+		if format == "" {
+			format = "toml"
+		}
+	}
+	if !slices.Contains(config.ExportFormats, format) {
+		return fmt.Errorf("unsupported format '%s': must be toml, json, or yaml", format)
+	}
+
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	data, err := config.Export(cfg, format)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(output, data, 0600); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	fmt.Printf("✓ Exported %d repositories to %s\n", len(cfg.Repositories), output)
+	return nil
+}
+
+func importConfig(file, format string) error {
+	if format == "" {
+		format = config.FormatOf(file)
+		if format == "" {
+			return fmt.Errorf("cannot tell the format of %s from its extension, pass --format", file)
+		}
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	imported, err := config.DecodeExport(data, format)
+	if err != nil {
+		return err
+	}
+
+	configPath, err := config.GetConfigPath(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to get config path: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	encoded, err := imported.Encode(config.FileFormat(configPath))
+	if err != nil {
+		return err
+	}
+	// Staged next to the config, so replacing it is a rename on the same filesystem
+	ext := filepath.Ext(configPath)
+	staged := strings.TrimSuffix(configPath, ext) + ".import" + ext
+	if err := os.WriteFile(staged, encoded, 0600); err != nil {
+		return fmt.Errorf("failed to write imported config: %w", err)
+	}
+	defer os.Remove(staged)
+
+	problems, err := config.ValidateFile(staged)
+	if err != nil {
+		return err
+	}
+	for i := range problems {
+		if problems[i].Local {
+			problems[i].Warning = true
+		}
+	}
+	if len(problems) > 0 {
+		fmt.Printf("🔍 %s\n", file)
+		errorCount, warningCount := printProblems(problems)
+		fmt.Printf("\n📊 %d errors, %d warnings\n\n", errorCount, warningCount)
+		if errorCount > 0 {
+			return fmt.Errorf("not importing a config with %d errors", errorCount)
+		}
+	}
+
+	backup := ""
+	if _, err := os.Stat(configPath); err == nil {
+		backup = configPath + ".bak"
+		if !configYes && !prompt.New().Confirm(fmt.Sprintf("Replace %s? It is kept as %s", configPath, backup), false) {
+			fmt.Println("Import cancelled.")
+			return nil
+		}
+		current, err := os.ReadFile(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to read current config: %w", err)
+		}
+		if err := os.WriteFile(backup, current, 0600); err != nil {
+			return fmt.Errorf("failed to back up current config: %w", err)
+		}
+	}
+	if err := os.Rename(staged, configPath); err != nil {
+		return fmt.Errorf("failed to replace config: %w", err)
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load imported config: %w", err)
+	}
+	fmt.Printf("✓ Imported %d repositories into %s\n", len(cfg.Repositories), configPath)
+	if imported.FromVersion < config.SchemaVersion {
+		fmt.Printf("ℹ️  Migrated from schema version %d to %d\n", imported.FromVersion, config.SchemaVersion)
+	}
+	if backup != "" {
+		fmt.Printf("ℹ️  The previous config is in %s\n", backup)
+	}
+	notifyDaemonReload()
+	return nil
+}
@@ -1,9 +1,26 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
 	"github.com/spf13/cobra"
 
-	"github.com/bnema/git-sync/internal/daemon"
+	gitsyncDaemon "github.com/bnema/git-sync/internal/daemon"
+	"github.com/bnema/git-sync/internal/multiuser"
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+var (
+	daemonUsersFile  string
+	daemonIgnoreLock bool
 )
 
 var daemonCmd = &cobra.Command{
@@ -12,17 +29,133 @@ var daemonCmd = &cobra.Command{
 	Long: `Run the git sync daemon process.
 
 This command is typically executed by systemd and should not be run manually.
-The daemon will continuously monitor and sync configured repositories.`,
+The daemon will continuously monitor and sync configured repositories.
+
+With --users, runs as a system-wide supervisor (as root) that starts one daemon
+per listed user, running as that user with their own configuration.
+
+Only one daemon runs per user: a second one, e.g. started by hand next to the
+systemd service, refuses to start. --ignore-lock starts it anyway.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if daemonUsersFile != "" {
+			return runMultiUserDaemon(daemonUsersFile)
+		}
 		return runDaemon()
 	},
 }
 
+func init() {
+	daemonCmd.Flags().StringVar(&daemonUsersFile, "users", "",
+		"users file for multi-user mode (e.g. /etc/git-sync/users.toml)")
+	daemonCmd.Flags().BoolVar(&daemonIgnoreLock, "ignore-lock", false,
+		"start even if another daemon is running (both sync the same repositories)")
+}
+
 func runDaemon() error {
-	d, err := daemon.NewDaemon(configFile)
+	lock, err := gitsyncDaemon.AcquireInstanceLock()
+	switch {
+	case err == nil:
+		defer lock.Release()
+	case !errors.Is(err, gitsyncDaemon.ErrAlreadyRunning):
+		return err
+	case !daemonIgnoreLock:
+		return fmt.Errorf("%w; stop it first (systemctl --user stop git-sync-daemon.service), or pass --ignore-lock to run both", err)
+	default:
+		fmt.Fprintf(os.Stderr, "⚠️  Ignoring the instance lock: %v\n", err)
+	}
+
+	gitsyncDaemon.SetCommandFlags(forwardedFlags())
+	d, err := gitsyncDaemon.NewDaemon(configFile)
 	if err != nil {
 		return err
 	}
 
 	return d.Run()
-}
\ No newline at end of file
+}
+
+// forwardedFlags returns the global flags given to the daemon that the
+// commands it starts, like Show history, need to see the same files
+func forwardedFlags() []string {
+	var flags []string
+	if configDir != "" {
+		if dir, err := paths.ConfigDir(); err == nil {
+			flags = append(flags, "--config-dir", dir)
+		}
+	}
+	if cacheDir != "" {
+		if dir, err := paths.CacheDir(); err == nil {
+			flags = append(flags, "--cache-dir", dir)
+		}
+	}
+	if profile != "" {
+		flags = append(flags, "--profile", profile)
+	}
+	return flags
+}
+
+// runMultiUserDaemon supervises per-user daemons until SIGINT/SIGTERM; SIGHUP
+// makes every user daemon reload its configuration
+func runMultiUserDaemon(usersFile string) error {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	usersConfig, err := multiuser.LoadUsersConfig(usersFile)
+	if err != nil {
+		return err
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate git-sync binary: %w", err)
+	}
+
+	supervisor, err := multiuser.NewSupervisor(binary, usersConfig, logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		supervisor.Run(ctx)
+		close(done)
+	}()
+
+	if _, err := daemon.SdNotify(false, daemon.SdNotifyReady); err != nil {
+		logger.Warn("Failed to notify systemd of ready state", "error", err)
+	}
+	logger.Info("Multi-user supervisor started", "users", len(usersConfig.Users))
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	var watchdog <-chan time.Time
+	if interval := gitsyncDaemon.WatchdogInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+
+	for {
+		select {
+		case <-watchdog:
+			_, _ = daemon.SdNotify(false, daemon.SdNotifyWatchdog)
+		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				logger.Info("Received SIGHUP, reloading user daemons")
+				supervisor.Reload()
+				continue
+			}
+
+			logger.Info("Received shutdown signal, stopping user daemons", "signal", sig)
+			_, _ = daemon.SdNotify(false, daemon.SdNotifyStopping)
+			cancel()
+			<-done
+			return nil
+		case <-done:
+			return fmt.Errorf("supervisor stopped unexpectedly")
+		}
+	}
+}
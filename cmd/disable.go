@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var disableCmd = &cobra.Command{
+	Use:   "disable [repo]",
+	Short: "Take a repository out of rotation, keeping its configuration",
+	Long: `Set enabled = false for a repository in the sync configuration, so the
+daemon stops scheduling it without losing its settings; 'git sync enable'
+turns it back on. A running daemon reloads right away. Unlike 'git sync
+pause', the change is written to the config file.
+
+The repository defaults to the current directory.
+
+Examples:
+  git sync disable                   # Disable the current repository
+  git sync disable ~/notes`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		return setRepositoryEnabled(target, false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(disableCmd)
+}
@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/prompt"
+)
+
+var (
+	discoverMaxDepth int
+	discoverYes      bool
+)
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover <root-dir>",
+	Short: "Find git repositories under a directory and register them for sync",
+	Long: `Search a directory recursively for git repositories, pick the ones to sync
+from a list, and register them all with the same settings, instead of running
+'git sync init' in each one.
+
+Repositories already configured, or without the chosen remote, are left out.
+Hidden directories and node_modules are not searched, nor are repositories
+nested inside another one. The settings flags work as for 'git sync init'.
+
+Examples:
+  git sync discover ~/notes                     # Choose among the repositories found
+  git sync discover ~/src -d both -i 900        # Register the chosen ones with these settings
+  git sync discover ~/src --max-depth 2 --yes   # Register everything found, no prompts`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return discoverRepositories(args[0])
+	},
+}
+
+func init() {
+	discoverCmd.Flags().StringVarP(&direction, "direction", "d", "push",
+		"sync direction: push, pull, both")
+	discoverCmd.Flags().IntVarP(&interval, "interval", "i", 300,
+		"sync interval in seconds")
+	discoverCmd.Flags().StringVarP(&remote, "remote", "r", "origin",
+		"git remote name")
+	discoverCmd.Flags().StringVar(&branchStrategy, "branch-strategy", "current",
+		"branch strategy: current, main, all, specific")
+	discoverCmd.Flags().StringVar(&targetBranch, "target-branch", "",
+		"target branch name (required when using 'specific' branch strategy)")
+	discoverCmd.Flags().BoolVar(&safetyChecks, "safety-checks", true,
+		"enable safety checks before sync operations")
+	discoverCmd.Flags().BoolVar(&forcePush, "force", false,
+		"enable force push (use with caution)")
+	discoverCmd.Flags().StringVar(&dirtyAction, "dirty-action", "skip",
+		"action when the worktree has uncommitted changes: skip, stash, commit")
+	discoverCmd.Flags().IntVar(&fetchDepth, "fetch-depth", 0,
+		"limit fetches to this many commits, 0 for full history")
+	discoverCmd.Flags().IntVar(&discoverMaxDepth, "max-depth", 5,
+		"how many directory levels below root-dir to search")
+	discoverCmd.Flags().BoolVarP(&discoverYes, "yes", "y", false,
+		"register every repository found without prompting")
+	rootCmd.AddCommand(discoverCmd)
+}
+
+// discoveredRepo is a repository found under the root directory that can be registered
+type discoveredRepo struct {
+	path      string
+	remoteURL string
+}
+
+func discoverRepositories(rootDir string) error {
+	if !isValidDirection(direction) {
+		return fmt.Errorf("invalid direction '%s': must be push, pull, or both", direction)
+	}
+	if !isValidBranchStrategy(branchStrategy) {
+		return fmt.Errorf("invalid branch strategy '%s': must be current, main, all, or specific", branchStrategy)
+	}
+	if !isValidDirtyAction(dirtyAction) {
+		return fmt.Errorf("invalid dirty action '%s': must be skip, stash, or commit", dirtyAction)
+	}
+	if branchStrategy == "specific" && targetBranch == "" {
+		return fmt.Errorf("the 'specific' branch strategy needs --target-branch when registering several repositories")
+	} else if branchStrategy != "specific" && targetBranch != "" {
+		return fmt.Errorf("target-branch can only be used with 'specific' branch strategy")
+	}
+	if err := validateConfigCombination(); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	root, err := filepath.Abs(rootDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	fmt.Printf("🔍 Searching %s for git repositories...\n", root)
+	found, err := findGitRepositories(root, discoverMaxDepth)
+	if err != nil {
+		return err
+	}
+
+	// Repositories a path glob already matches are configured too
+	expanded := cfg.ExpandPatterns()
+	var candidates []discoveredRepo
+	configured := 0
+	for _, path := range found {
+		if _, exists := expanded.FindRepository(path); exists {
+			configured++
+			continue
+		}
+		remoteURL, err := remoteURLOf(path, remote)
+		if err != nil {
+			fmt.Printf("⚠️  Skipping %s: no remote '%s'\n", displayPath(root, path), remote)
+			continue
+		}
+		if branchStrategy == "specific" {
+			if err := verifyBranchExists(path, targetBranch); err != nil {
+				fmt.Printf("⚠️  Skipping %s: %v\n", displayPath(root, path), err)
+				continue
+			}
+		}
+		candidates = append(candidates, discoveredRepo{path: path, remoteURL: remoteURL})
+	}
+	if configured > 0 {
+		fmt.Printf("ℹ️  %d already configured\n", configured)
+	}
+	if len(candidates) == 0 {
+		fmt.Println("No new repositories to register.")
+		return nil
+	}
+	fmt.Println()
+
+	chosen := candidates
+	if !discoverYes {
+		p := prompt.New()
+		options := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			options[i] = fmt.Sprintf("%s (%s)", displayPath(root, candidate.path), candidate.remoteURL)
+		}
+		chosen = nil
+		for _, i := range p.MultiSelect(fmt.Sprintf("Choose the repositories to sync (%d found):", len(candidates)), options) {
+			chosen = append(chosen, candidates[i])
+		}
+		if len(chosen) == 0 {
+			fmt.Println("No repositories chosen.")
+			return nil
+		}
+		fmt.Println()
+
+		summaryItems := map[string]string{
+			"Repositories":    fmt.Sprintf("%d", len(chosen)),
+			"Sync Direction":  direction,
+			"Sync Interval":   fmt.Sprintf("%d seconds", interval),
+			"Remote":          remote,
+			"Branch Strategy": branchStrategy,
+			"Safety Checks":   fmt.Sprintf("%v", safetyChecks),
+			"Dirty Worktree":  dirtyAction,
+			"Force Push":      fmt.Sprintf("%v", forcePush),
+		}
+		if targetBranch != "" {
+			summaryItems["Target Branch"] = targetBranch
+		}
+		p.ShowSummary("Shared Settings", summaryItems)
+		if !p.Confirm("Register these repositories?", true) {
+			fmt.Println("Discovery cancelled.")
+			return nil
+		}
+	}
+
+	repoConfigs := make([]config.RepoConfig, len(chosen))
+	for i, repo := range chosen {
+		repoConfigs[i] = config.RepoConfig{
+			Path:           repo.path,
+			Enabled:        true,
+			Direction:      direction,
+			Interval:       interval,
+			Remote:         remote,
+			BranchStrategy: branchStrategy,
+			TargetBranch:   targetBranch,
+			SafetyChecks:   safetyChecks,
+			ForcePush:      forcePush,
+
+			DirtyWorktreeAction: dirtyAction,
+			FetchDepth:          fetchDepth,
+		}
+	}
+	if err := config.AddRepositories(repoConfigs, configFile); err != nil {
+		return fmt.Errorf("failed to add repositories to config: %w", err)
+	}
+
+	fmt.Printf("✓ Registered %d repositories for sync\n", len(chosen))
+	for _, repo := range chosen {
+		fmt.Printf("  %s\n", repo.path)
+	}
+	notifyDaemonReload()
+	return nil
+}
+
+// findGitRepositories returns the git repositories at most maxDepth levels
+// below root, without descending into hidden directories, node_modules or the
+// repositories themselves. Unreadable directories are skipped.
+func findGitRepositories(root string, maxDepth int) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	var repos []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path != root && errors.Is(err, fs.ErrPermission) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+			return fs.SkipDir
+		}
+		// A .git directory, or the .git file of a linked worktree or submodule
+		if _, err := os.Lstat(filepath.Join(path, ".git")); err == nil {
+			repos = append(repos, path)
+			return fs.SkipDir
+		}
+		depth := 0
+		if rel, _ := filepath.Rel(root, path); rel != "." {
+			depth = strings.Count(rel, string(filepath.Separator)) + 1
+		}
+		if depth >= maxDepth {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s: %w", root, err)
+	}
+	return repos, nil
+}
+
+// remoteURLOf returns the URL of the named remote of the repository at repoPath
+func remoteURLOf(repoPath, remoteName string) (string, error) {
+	cmd := exec.Command("git", "remote", "get-url", remoteName)
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("remote '%s' does not exist", remoteName)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// displayPath shows path relative to root, or "." for root itself
+func displayPath(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return rel
+	}
+	return path
+}
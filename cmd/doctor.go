@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/daemon"
+	"github.com/bnema/git-sync/internal/notification"
+	"github.com/bnema/git-sync/internal/systemd"
+)
+
+const (
+	// doctorProbeTimeout bounds the connection to one repository's remote
+	doctorProbeTimeout = 20 * time.Second
+
+	// doctorProbeWorkers is how many remotes are probed at once
+	doctorProbeWorkers = 8
+)
+
+var doctorOffline bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the setup and print fixes for what is wrong",
+	Long: `Check everything git sync depends on and print a fix for each problem found:
+
+  - the config file is valid
+  - each enabled repository exists, has its remote, isn't locked by a git
+    process, and its remote accepts the credentials syncs use
+  - the systemd service is installed, enabled and running, and the daemon answers
+  - git is installed, and desktop notifications can be shown
+  - the daemon's instance lock and the history file are sound
+
+Exits 1 when a problem is found, so the output is worth attaching to bug reports.
+
+Examples:
+  git sync doctor
+  git sync doctor --offline   # Skip connecting to the remotes`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runDoctor()
+	},
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorOffline, "offline", false, "don't connect to the remotes of the repositories")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// doctorFinding is one failed check with how to fix it
+type doctorFinding struct {
+	problem bool // a warning otherwise
+	message string
+	fix     string
+}
+
+// doctorReport prints check results and counts what went wrong
+type doctorReport struct {
+	problems int
+	warnings int
+}
+
+func (r *doctorReport) ok(format string, args ...any) {
+	fmt.Printf("  ✓ %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *doctorReport) info(format string, args ...any) {
+	fmt.Printf("  ℹ️  %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *doctorReport) report(finding doctorFinding) {
+	if finding.problem {
+		r.problems++
+		fmt.Printf("  ❌ %s\n", finding.message)
+	} else {
+		r.warnings++
+		fmt.Printf("  ⚠️  %s\n", finding.message)
+	}
+	if finding.fix != "" {
+		fmt.Printf("     → %s\n", finding.fix)
+	}
+}
+
+func (r *doctorReport) fail(message, fix string) {
+	r.report(doctorFinding{problem: true, message: message, fix: fix})
+}
+
+func (r *doctorReport) warn(message, fix string) {
+	r.report(doctorFinding{message: message, fix: fix})
+}
+
+func runDoctor() error {
+	report := &doctorReport{}
+	fmt.Println("🩺 Git Sync Doctor")
+
+	fmt.Println("\nConfiguration:")
+	cfg := checkDoctorConfig(report)
+
+	fmt.Println("\nTools:")
+	checkDoctorTools(report, cfg)
+
+	fmt.Println("\nDaemon:")
+	checkDoctorDaemon(report)
+
+	if cfg != nil {
+		fmt.Println("\nHistory:")
+		checkDoctorHistory(report, cfg)
+
+		fmt.Println("\nRepositories:")
+		checkDoctorRepositories(report, cfg)
+	}
+
+	fmt.Printf("\n📊 %d problems, %d warnings\n", report.problems, report.warnings)
+	if report.problems > 0 {
+		return fmt.Errorf("doctor found %d problems", report.problems)
+	}
+	return nil
+}
+
+// checkDoctorConfig loads the config, returning nil when it can't be used
+func checkDoctorConfig(report *doctorReport) *config.Config {
+	configPath, err := config.GetConfigPath(configFile)
+	if err != nil {
+		report.fail(fmt.Sprintf("Can't locate the config file: %v", err), "set HOME or XDG_CONFIG_HOME, or pass --config")
+		return nil
+	}
+	// Loading would create a missing config, which is no diagnosis
+	if _, err := os.Stat(configPath); errors.Is(err, os.ErrNotExist) {
+		report.warn(fmt.Sprintf("No config file at %s", configPath), "run 'git sync init' in a repository to create it")
+		return nil
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		report.fail(fmt.Sprintf("Config %s is invalid: %v", configPath, err), "correct it with 'git sync edit'")
+		return nil
+	}
+	cfg = cfg.ExpandPatterns()
+
+	enabled := 0
+	for _, repo := range cfg.Repositories {
+		if repo.Enabled {
+			enabled++
+		}
+	}
+	report.ok("Config %s is valid: %d repositories, %d enabled", configPath, len(cfg.Repositories), enabled)
+	if len(cfg.Repositories) == 0 {
+		report.warn("No repositories are configured", "run 'git sync init' in a repository, or 'git sync discover <dir>'")
+	} else if enabled == 0 {
+		report.warn("Every repository is disabled", "turn one back on with 'git sync enable <repo>'")
+	}
+	return cfg
+}
+
+func checkDoctorTools(report *doctorReport, cfg *config.Config) {
+	if path, err := exec.LookPath("git"); err != nil {
+		report.fail("git is not installed or not in PATH", "install git; hooks, lock checks and the git backend run it")
+	} else {
+		report.ok("git: %s", path)
+	}
+
+	notificationsOn := cfg == nil || cfg.Global.EnableNotifications
+	switch backend := notification.Backend(); {
+	case backend != "":
+		report.ok("Desktop notifications: %s", backend)
+	case !notificationsOn:
+		report.info("Desktop notifications can't be shown, they are disabled anyway")
+	case cfg != nil && len(cfg.Notifiers) > 0:
+		report.info("Desktop notifications can't be shown, only the [[notifiers]] get them")
+	case runtime.GOOS == "darwin":
+		report.warn("Notifications are enabled but neither terminal-notifier nor osascript is in PATH",
+			"install terminal-notifier (brew install terminal-notifier), or set enable_notifications = false")
+	case runtime.GOOS == "windows":
+		report.warn("Notifications are enabled but powershell.exe is not in PATH",
+			"add Windows PowerShell to PATH, or set enable_notifications = false")
+	default:
+		report.warn("Notifications are enabled but there is neither a notification daemon on the session bus nor notify-send",
+			"run a notification daemon, install libnotify (libnotify-bin on Debian and Ubuntu), or set enable_notifications = false")
+	}
+}
+
+func checkDoctorDaemon(report *doctorReport) {
+	state, err := systemd.GetServiceState()
+	switch {
+	case err != nil:
+		report.info("Skipping the systemd service: %v", err)
+	case !state.Installed:
+		report.warn("The systemd service isn't installed, the daemon only runs when started by hand",
+			"run 'git sync install-daemon'")
+	default:
+		if state.Enabled != "enabled" {
+			report.warn(fmt.Sprintf("git-sync-daemon.service is %s, it won't start with your session", orNone(state.Enabled)),
+				"run 'systemctl --user enable git-sync-daemon.service'")
+		}
+		switch state.Active {
+		case "active":
+			report.ok("git-sync-daemon.service is active")
+		case "failed":
+			report.fail("git-sync-daemon.service has failed",
+				"read why with 'journalctl --user -u git-sync-daemon -n 50', then 'systemctl --user restart git-sync-daemon.service'")
+		default:
+			report.warn(fmt.Sprintf("git-sync-daemon.service is %s", orNone(state.Active)),
+				"run 'systemctl --user start git-sync-daemon.service'")
+		}
+	}
+
+	resp, err := control.CallTimeout(control.Request{Command: "health"}, 5*time.Second)
+	if err != nil {
+		pid, held, lockErr := daemon.InstanceLockHolder()
+		switch {
+		case lockErr != nil:
+			report.warn(fmt.Sprintf("Can't check the daemon's instance lock: %v", lockErr), "")
+		case held && pid > 0:
+			report.fail(fmt.Sprintf("Daemon pid %d holds the instance lock but doesn't answer on %s", pid, control.SocketPath()),
+				fmt.Sprintf("restart it: 'systemctl --user restart git-sync-daemon.service' or 'kill %d'", pid))
+		case held:
+			report.fail(fmt.Sprintf("A daemon holds the instance lock but doesn't answer on %s", control.SocketPath()),
+				"restart it with 'systemctl --user restart git-sync-daemon.service'")
+		case errors.Is(err, control.ErrDaemonNotRunning):
+			report.warn("The daemon is not running, nothing is synced",
+				"start it with 'systemctl --user start git-sync-daemon.service' or 'git sync daemon'")
+		default:
+			report.fail(fmt.Sprintf("The daemon didn't answer: %v", err), "restart it with 'systemctl --user restart git-sync-daemon.service'")
+		}
+		return
+	}
+
+	var health daemon.Health
+	if err := json.Unmarshal(resp.Data, &health); err != nil {
+		report.warn(fmt.Sprintf("Can't read the daemon's health: %v", err), "")
+		return
+	}
+	switch health.Status {
+	case daemon.HealthOK:
+		report.ok("The daemon is running and healthy, up %s", formatSince(health.StartedAt))
+	case daemon.HealthDegraded:
+		report.warn(fmt.Sprintf("The daemon is degraded: %s", strings.Join(health.Problems, "; ")), "see 'git sync status --health'")
+	default:
+		report.fail(fmt.Sprintf("The daemon is failing: %s", strings.Join(health.Problems, "; ")), "see 'git sync status --health'")
+	}
+	if reload := health.LastReload; reload != nil && reload.Error != "" {
+		report.fail(fmt.Sprintf("The daemon's last config reload failed: %s", reload.Error),
+			"correct the config with 'git sync edit'; the daemon keeps its previous one until then")
+	}
+}
+
+func checkDoctorHistory(report *doctorReport, cfg *config.Config) {
+	historyManager, err := openHistoryManager(cfg)
+	if err != nil {
+		report.fail(err.Error(), "check that history_cache_dir (or ~/.cache/git-sync) is writable")
+		return
+	}
+	check, err := historyManager.Check()
+	if err != nil {
+		report.fail(fmt.Sprintf("Can't read %s: %v", check.File, err), "check its permissions, or move it aside to start a new history")
+		return
+	}
+	if len(check.CorruptLines) > 0 {
+		lines := make([]string, 0, len(check.CorruptLines))
+		for _, line := range check.CorruptLines {
+			lines = append(lines, strconv.Itoa(line))
+		}
+		if len(lines) > 10 {
+			lines = append(lines[:10], "...")
+		}
+		report.warn(fmt.Sprintf("%s has damaged lines that history listings skip: %s", check.File, strings.Join(lines, ", ")),
+			"delete those lines, e.g. after a crash mid-write; the other entries are fine")
+		return
+	}
+	report.ok("%s: %d entries", check.File, check.Entries)
+}
+
+func checkDoctorRepositories(report *doctorReport, cfg *config.Config) {
+	if len(cfg.Repositories) == 0 {
+		report.info("None configured")
+		return
+	}
+
+	// Remotes are probed concurrently, results are printed in config order
+	findings := make([][]doctorFinding, len(cfg.Repositories))
+	sem := make(chan struct{}, doctorProbeWorkers)
+	var wg sync.WaitGroup
+	for i, repo := range cfg.Repositories {
+		if !repo.Enabled {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, repo config.RepoConfig) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			findings[i] = checkDoctorRepository(cfg.Global.WithGlobalDefaults(repo))
+		}(i, repo)
+	}
+	wg.Wait()
+
+	for i, repo := range cfg.Repositories {
+		name := filepath.Base(repo.Path)
+		if !repo.Enabled {
+			report.info("%s: disabled, not checked", name)
+			continue
+		}
+		if len(findings[i]) == 0 {
+			report.ok("%s", name)
+			continue
+		}
+		for _, finding := range findings[i] {
+			finding.message = name + ": " + finding.message
+			report.report(finding)
+		}
+	}
+}
+
+// checkDoctorRepository returns what is wrong with one repository
+func checkDoctorRepository(repo config.RepoConfig) []doctorFinding {
+	info, err := os.Stat(repo.Path)
+	if err != nil {
+		return []doctorFinding{{problem: true,
+			message: fmt.Sprintf("%s doesn't exist", repo.Path),
+			fix:     fmt.Sprintf("restore it, or stop syncing it with 'git sync remove %s'", repo.Path)}}
+	}
+	if !info.IsDir() {
+		return []doctorFinding{{problem: true,
+			message: fmt.Sprintf("%s is not a directory", repo.Path),
+			fix:     fmt.Sprintf("stop syncing it with 'git sync remove %s'", repo.Path)}}
+	}
+	gitDir := exec.Command("git", "rev-parse", "--git-dir")
+	gitDir.Dir = repo.Path
+	if err := gitDir.Run(); err != nil {
+		return []doctorFinding{{problem: true,
+			message: fmt.Sprintf("%s is not a git repository", repo.Path),
+			fix:     fmt.Sprintf("stop syncing it with 'git sync remove %s'", repo.Path)}}
+	}
+	if _, err := remoteURLOf(repo.Path, repo.Remote); err != nil {
+		return []doctorFinding{{problem: true,
+			message: fmt.Sprintf("has no remote '%s'", repo.Remote),
+			fix:     fmt.Sprintf("add it with 'git -C %s remote add %s <url>', or pick another with 'git sync config %s'", repo.Path, repo.Remote, repo.Path)}}
+	}
+
+	var findings []doctorFinding
+	ctx, cancel := context.WithTimeout(context.Background(), doctorProbeTimeout)
+	defer cancel()
+	if lockPath := daemon.IndexLockFile(ctx, repo); lockPath != "" {
+		findings = append(findings, doctorFinding{
+			message: fmt.Sprintf("%s exists, syncs are skipped while it does", lockPath),
+			fix:     fmt.Sprintf("if no git command is running there, delete it: rm %s", lockPath)})
+	}
+	if doctorOffline {
+		return findings
+	}
+	if err := daemon.ProbeRemote(ctx, repo); err != nil {
+		findings = append(findings, remoteFinding(repo, err))
+	}
+	return findings
+}
+
+// remoteFinding explains a failed connection to the repository's remote
+func remoteFinding(repo config.RepoConfig, err error) doctorFinding {
+	finding := doctorFinding{problem: true, message: fmt.Sprintf("can't reach remote '%s': %v", repo.Remote, err)}
+	switch daemon.ClassifyError(err.Error()) {
+	case daemon.ErrorAuth:
+		finding.message = fmt.Sprintf("remote '%s' rejected the credentials: %v", repo.Remote, err)
+		finding.fix = "syncs can't answer prompts: load your SSH key with 'ssh-add', or set up a git credential helper"
+	case daemon.ErrorNetwork, daemon.ErrorTimeout:
+		finding.fix = "check the network connection, and the proxy and ca_bundle settings"
+	case daemon.ErrorRepository:
+		finding.fix = fmt.Sprintf("check the remote URL with 'git -C %s remote -v'", repo.Path)
+	default:
+		finding.fix = fmt.Sprintf("try 'git -C %s fetch %s' to see the full error", repo.Path, repo.Remote)
+	}
+	return finding
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+)
+
+var enableCmd = &cobra.Command{
+	Use:   "enable [repo]",
+	Short: "Put a disabled repository back into rotation",
+	Long: `Set enabled = true for a repository in the sync configuration, so the
+daemon schedules it again with the settings it had. A running daemon
+reloads right away.
+
+The repository defaults to the current directory.
+
+Examples:
+  git sync enable                    # Enable the current repository
+  git sync enable ~/notes`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		return setRepositoryEnabled(target, true)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(enableCmd)
+}
+
+// setRepositoryEnabled flips the enabled flag of the repository at target in the config
+func setRepositoryEnabled(target string, enabled bool) error {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	// Repositories a path glob matches are looked up too; only their own entry is saved
+	cfg = cfg.ExpandPatterns()
+	repo, err := findRepoIn(cfg, target)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(repo.Path)
+
+	_, changed, err := config.SetRepositoryEnabled(repo.Path, enabled, configFile)
+	if err != nil {
+		return fmt.Errorf("failed to update config: %w", err)
+	}
+	switch {
+	case enabled && cfg.ProfileLeavesOut(repo.Path):
+		fmt.Printf("⚠️  %s is enabled, but the %s profile leaves it out, so only other machines sync it\n", name, cfg.HostProfile())
+		return nil
+	case !changed && enabled:
+		fmt.Printf("%s is already enabled\n", name)
+		return nil
+	case !changed:
+		fmt.Printf("%s is already disabled\n", name)
+		return nil
+	case enabled:
+		fmt.Printf("✓ Enabled %s, the daemon syncs it again\n", name)
+	default:
+		fmt.Printf("✓ Disabled %s, its configuration is kept for 'git sync enable'\n", name)
+	}
+
+	notifyDaemonReload()
+	return nil
+}
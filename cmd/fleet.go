@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/fleet"
+)
+
+// fleetSilentAfter is how long a machine may go without reporting before status flags it
+const fleetSilentAfter = 24 * time.Hour
+
+var (
+	fleetMachine string
+	fleetFormat  string
+)
+
+var fleetCmd = &cobra.Command{
+	Use:   "fleet",
+	Short: "See the repositories of every machine reporting to this daemon",
+	Long: `Daemons with report_to send their syncs to an aggregator: another git-sync
+daemon with api_listen. The fleet commands run on the aggregator.`,
+}
+
+var fleetStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the latest sync of every repository of every machine",
+	Long: `Show the latest sync each machine reported for each of its repositories,
+from the reports this daemon received.
+
+Examples:
+  git sync fleet status
+  git sync fleet status --machine laptop
+  git sync fleet status --format json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showFleetStatus()
+	},
+}
+
+func init() {
+	fleetStatusCmd.Flags().StringVar(&fleetMachine, "machine", "", "only show this machine")
+	fleetStatusCmd.Flags().StringVar(&fleetFormat, "format", "table", "output format (table, json)")
+	fleetCmd.AddCommand(fleetStatusCmd)
+	rootCmd.AddCommand(fleetCmd)
+}
+
+func showFleetStatus() error {
+	f, err := fleet.Load()
+	if err != nil {
+		return err
+	}
+	machines := f.SortedMachines()
+	if fleetMachine != "" {
+		machine, exists := f.Machines[fleetMachine]
+		if !exists {
+			return fmt.Errorf("no reports from machine '%s'", fleetMachine)
+		}
+		machines = []*fleet.Machine{machine}
+	}
+
+	switch fleetFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(machines)
+	case "table":
+	default:
+		return fmt.Errorf("invalid format: %s (supported: table, json)", fleetFormat)
+	}
+
+	if len(machines) == 0 {
+		fmt.Println("No machines have reported yet.")
+		fmt.Println("Set report_to on other machines to this daemon's api_listen URL, with an API key")
+		fmt.Println("created here by 'git sync apikey create --scopes report'.")
+		return nil
+	}
+
+	var repos, failing int
+	for _, machine := range machines {
+		repos += len(machine.Repos)
+		for _, repo := range machine.Repos {
+			if repo.LastStatus == "failed" {
+				failing++
+			}
+		}
+	}
+	fmt.Printf("📊 %d machines, %d repositories, %d failing\n\n", len(machines), repos, failing)
+
+	fmt.Printf("%-20s %-30s %-9s %-7s %-10s %-12s %s\n",
+		"MACHINE", "REPOSITORY", "DIRECTION", "STATUS", "LAST SYNC", "LAST SUCCESS", "ERROR")
+	fmt.Println(strings.Repeat("-", 110))
+	for _, machine := range machines {
+		for _, repo := range machine.SortedRepos() {
+			lastSuccess := "never"
+			if !repo.LastSuccess.IsZero() {
+				lastSuccess = formatSince(repo.LastSuccess) + " ago"
+			}
+			errorMsg := repo.LastError
+			if repo.Failures > 1 {
+				errorMsg = fmt.Sprintf("%d failures: %s", repo.Failures, errorMsg)
+			}
+			if len(errorMsg) > 40 {
+				errorMsg = errorMsg[:37] + "..."
+			}
+			fmt.Printf("%-20s %-30s %-9s %-7s %-10s %-12s %s\n",
+				truncateLeft(machine.ID, 20), truncateLeft(repo.Path, 30), repo.Direction, repo.LastStatus,
+				formatSince(repo.LastSync)+" ago", lastSuccess, errorMsg)
+		}
+	}
+
+	var silent []string
+	for _, machine := range machines {
+		if time.Since(machine.LastReport) > fleetSilentAfter {
+			silent = append(silent, fmt.Sprintf("%s (%s ago)", machine.ID, formatSince(machine.LastReport)))
+		}
+	}
+	if len(silent) > 0 {
+		fmt.Printf("\n⚠️  Not reporting for over a day: %s\n", strings.Join(silent, ", "))
+	}
+	return nil
+}
+
+// truncateLeft shortens s to width, keeping its end, which tells paths apart
+func truncateLeft(s string, width int) string {
+	if len(s) <= width {
+		return s
+	}
+	return "..." + s[len(s)-width+3:]
+}
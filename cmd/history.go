@@ -12,6 +12,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/bnema/git-sync/internal/config"
 	"github.com/bnema/git-sync/internal/daemon"
+	"github.com/bnema/git-sync/internal/paths"
 )
 
 var (
@@ -57,8 +58,23 @@ func showHistory() error {
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	cfg = cfg.ExpandPatterns()
 
-	// Create history manager
+	historyManager, err := openHistoryManager(cfg)
+	if err != nil {
+		return err
+	}
+
+	historyRepo = resolveRepoFilter(cfg, historyRepo)
+	if historyWatch {
+		return watchHistory(historyManager)
+	}
+
+	return displayHistory(historyManager)
+}
+
+// openHistoryManager creates a history manager for CLI use from the loaded config
+func openHistoryManager(cfg *config.Config) (*daemon.HistoryManager, error) {
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level: slog.LevelWarn, // Only show warnings/errors for CLI
 	}))
@@ -68,17 +84,25 @@ func showHistory() error {
 		cfg.Global.HistoryMaxEntries,
 		cfg.Global.HistoryRetentionDays,
 		cfg.Global.HistoryMaxFileSizeMB,
+		cfg.Global.HistoryBackend,
 		logger,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create history manager: %w", err)
+		return nil, fmt.Errorf("failed to create history manager: %w", err)
 	}
+	return historyManager, nil
+}
 
-	if historyWatch {
-		return watchHistory(historyManager)
+// resolveRepoFilter returns the configured path of the repository a --repo flag
+// names, so filters match however the path was typed; unknown paths are normalized
+func resolveRepoFilter(cfg *config.Config, path string) string {
+	if path == "" {
+		return ""
 	}
-
-	return displayHistory(historyManager)
+	if i, found := cfg.FindRepository(path); found {
+		return cfg.Repositories[i].Path
+	}
+	return paths.NormalizeRepo(path)
 }
 
 func displayHistory(hm *daemon.HistoryManager) error {
@@ -117,6 +141,12 @@ func displayHistoryTable(entries []daemon.SyncHistoryEntry) error {
 		}
 		duration := formatHistoryDuration(time.Duration(entry.DurationMs) * time.Millisecond)
 		errorMsg := entry.ErrorMsg
+		if entry.SkipReason != "" {
+			errorMsg = entry.SkipReason
+		}
+		if errorMsg == "" {
+			errorMsg = entry.HookError
+		}
 		if len(errorMsg) > 40 {
 			errorMsg = errorMsg[:37] + "..."
 		}
@@ -129,6 +159,12 @@ func displayHistoryTable(entries []daemon.SyncHistoryEntry) error {
 				status = fmt.Sprintf("\033[32m%s\033[0m", entry.Status) // Green
 			case "failed":
 				status = fmt.Sprintf("\033[31m%s\033[0m", entry.Status)  // Red
+			case "skipped":
+				status = fmt.Sprintf("\033[33m%s\033[0m", entry.Status) // Yellow
+			case "partial":
+				status = fmt.Sprintf("\033[36m%s\033[0m", entry.Status) // Cyan
+			case "noop":
+				status = fmt.Sprintf("\033[90m%s\033[0m", entry.Status) // Gray
 			}
 		}
 
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -12,6 +13,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/daemon"
 	"github.com/bnema/git-sync/internal/prompt"
 	"github.com/bnema/git-sync/internal/validation"
 )
@@ -24,6 +26,9 @@ var (
 	targetBranch   string
 	safetyChecks   bool
 	forcePush      bool
+	dirtyAction    string
+	unionMerge     []string
+	fetchDepth     int
 )
 
 var initCmd = &cobra.Command{
@@ -38,7 +43,10 @@ Interactive Mode (default):
 Non-Interactive Mode:
   git sync init --non-interactive   # Use flags or defaults, no prompts
   git sync init -d both -i 600      # Both directions, 10 min interval
-  git sync init --branch-strategy main --force  # Force push to main branch`,
+  git sync init --branch-strategy main --force  # Force push to main branch
+
+New Checkouts:
+  git sync init --from-url git@github.com:user/notes.git  # Clone into the empty current directory, then register`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runInitCommand(cmd, args)
 	},
@@ -59,11 +67,26 @@ func init() {
 		"enable safety checks before sync operations")
 	initCmd.Flags().BoolVar(&forcePush, "force", false,
 		"enable force push (use with caution)")
+	initCmd.Flags().StringVar(&dirtyAction, "dirty-action", "skip",
+		"action when the worktree has uncommitted changes: skip, stash, commit")
 	initCmd.Flags().Bool("non-interactive", false,
 		"run in non-interactive mode using flags or defaults")
+	initCmd.Flags().StringSliceVar(&unionMerge, "union-merge", nil,
+		"path patterns auto-merged with git's union driver on diverged pulls (e.g. '*.md,journal/*.txt')")
+	initCmd.Flags().IntVar(&fetchDepth, "fetch-depth", 0,
+		"limit fetches (and --from-url clones) to this many commits, 0 for full history")
+	initCmd.Flags().String("from-url", "",
+		"clone this repository into the (empty) current directory before registering it")
 }
 
 func runInitCommand(cmd *cobra.Command, _ []string) error {
+	// Clone first when onboarding a brand-new checkout
+	if fromURL, _ := cmd.Flags().GetString("from-url"); fromURL != "" {
+		if err := cloneIntoCurrentDirectory(fromURL, remote); err != nil {
+			return err
+		}
+	}
+
 	// Check if we're in a git repository first
 	if err := validation.ValidateGitRepository(); err != nil {
 		return err
@@ -71,38 +94,69 @@ func runInitCommand(cmd *cobra.Command, _ []string) error {
 
 	// Check if non-interactive flag is set or if any config flags are provided
 	nonInteractive, _ := cmd.Flags().GetBool("non-interactive")
-	hasConfigFlags := cmd.Flags().Changed("direction") || 
+	if nonInteractive || hasConfigFlags(cmd) {
+		return initRepository()
+	}
+
+	// Run interactive mode
+	repoPath, err := currentRepoRoot()
+	if err != nil {
+		return err
+	}
+	return runInteractiveInit(repoPath)
+}
+
+// hasConfigFlags reports whether any sync setting was given as a flag, which
+// skips the interactive prompts
+func hasConfigFlags(cmd *cobra.Command) bool {
+	return cmd.Flags().Changed("direction") || 
 		cmd.Flags().Changed("interval") || 
 		cmd.Flags().Changed("remote") || 
 		cmd.Flags().Changed("branch-strategy") || 
 		cmd.Flags().Changed("target-branch") || 
 		cmd.Flags().Changed("safety-checks") || 
-		cmd.Flags().Changed("force")
-
-	if nonInteractive || hasConfigFlags {
-		return initRepository()
-	}
-
-	// Run interactive mode
-	return runInteractiveInit()
+		cmd.Flags().Changed("force") ||
+		cmd.Flags().Changed("dirty-action") ||
+		cmd.Flags().Changed("union-merge") ||
+		cmd.Flags().Changed("fetch-depth")
 }
 
-func runInteractiveInit() error {
+// runInteractiveInit prompts for the settings of the repository at repoPath
+// and registers it
+func runInteractiveInit(repoPath string) error {
 	p := prompt.New()
 	
 	fmt.Println("🔄 Git Sync Interactive Setup")
 	fmt.Println("Configure your git sync settings:")
 	fmt.Println()
 
-	// Get current directory for display
-	repoPath, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
-	}
-	
 	fmt.Printf("📂 Repository: %s\n", repoPath)
 	fmt.Println()
 
+	// The flag defaults preselect each answer
+	defaults := config.RepoConfig{
+		Direction:           direction,
+		Interval:            interval,
+		Remote:              remote,
+		BranchStrategy:      branchStrategy,
+		TargetBranch:        targetBranch,
+		SafetyChecks:        safetyChecks,
+		ForcePush:           forcePush,
+		DirtyWorktreeAction: dirtyAction,
+	}
+	proceed, err := promptSyncSettings(p, repoPath, defaults)
+	if err != nil || !proceed {
+		return err
+	}
+
+	// Run the actual initialization
+	return registerRepository(repoPath)
+}
+
+// promptSyncSettings asks for the sync settings of the repository at repoPath,
+// preselecting those of current, and stores the answers in the init flag
+// variables. It returns false when the summary isn't confirmed.
+func promptSyncSettings(p *prompt.Prompter, repoPath string, current config.RepoConfig) (bool, error) {
 	// 1. Sync Direction
 	fmt.Println("1️⃣ Sync Direction")
 	directionOptions := []string{
@@ -110,13 +164,16 @@ func runInteractiveInit() error {
 		"pull - Only pull remote changes locally", 
 		"both - Bidirectional sync (push and pull)",
 	}
-	directionIndex := p.SelectWithDefault("Choose sync direction:", directionOptions, 0)
 	directionValues := []string{"push", "pull", "both"}
+	directionIndex := p.SelectWithDefault("Choose sync direction:", directionOptions, optionIndex(directionValues, current.Direction))
 	direction = directionValues[directionIndex]
 	fmt.Println()
 
 	// 2. Sync Interval
 	fmt.Println("2️⃣ Sync Interval")
+	if current.Schedule != "" {
+		fmt.Printf("ℹ️  Timed syncs follow the schedule '%s', the interval is only its fallback\n", current.Schedule)
+	}
 	intervalOptions := []string{
 		"30 seconds (fast)",
 		"5 minutes (recommended)",
@@ -125,11 +182,19 @@ func runInteractiveInit() error {
 		"1 hour",
 		"Custom interval",
 	}
-	intervalIndex := p.SelectWithDefault("Choose sync interval:", intervalOptions, 1)
 	intervalValues := []int{30, 300, 900, 1800, 3600, 0}
+	intervalDefault := slices.Index(intervalValues, current.Interval)
+	if intervalDefault < 0 {
+		intervalDefault = len(intervalValues) - 1
+	}
+	intervalIndex := p.SelectWithDefault("Choose sync interval:", intervalOptions, intervalDefault)
 	
 	if intervalIndex == 5 { // Custom interval
-		customInterval := p.Input("Enter custom interval in seconds:", validation.ValidateInterval)
+		customDefault := ""
+		if intervalDefault == 5 && current.Interval > 0 {
+			customDefault = strconv.Itoa(current.Interval)
+		}
+		customInterval := p.InputWithDefault("Enter custom interval in seconds", customDefault, validation.ValidateInterval)
 		interval, _ = strconv.Atoi(customInterval)
 	} else {
 		interval = intervalValues[intervalIndex]
@@ -140,14 +205,15 @@ func runInteractiveInit() error {
 	fmt.Println("3️⃣ Git Remote")
 	// Get available remotes
 	cmd := exec.Command("git", "remote")
+	cmd.Dir = repoPath
 	output, err := cmd.Output()
 	if err != nil {
-		return fmt.Errorf("failed to get git remotes: %w", err)
+		return false, fmt.Errorf("failed to get git remotes: %w", err)
 	}
 	
 	remotes := strings.Fields(strings.TrimSpace(string(output)))
 	if len(remotes) == 0 {
-		return fmt.Errorf("no git remotes found. Please add a remote first with: git remote add origin <url>")
+		return false, fmt.Errorf("no git remotes found. Please add a remote first with: git remote add origin <url>")
 	}
 	
 	if len(remotes) == 1 {
@@ -155,7 +221,7 @@ func runInteractiveInit() error {
 		fmt.Printf("Using remote: %s\n", remote)
 	} else {
 		fmt.Println("Available remotes:")
-		remoteIndex := p.SelectWithDefault("Choose git remote:", remotes, 0)
+		remoteIndex := p.SelectWithDefault("Choose git remote:", remotes, optionIndex(remotes, current.Remote))
 		remote = remotes[remoteIndex]
 	}
 	fmt.Println()
@@ -168,25 +234,42 @@ func runInteractiveInit() error {
 		"all - Sync all branches",
 		"specific - Sync a specific branch",
 	}
-	strategyIndex := p.SelectWithDefault("Choose branch strategy:", strategyOptions, 0)
 	strategyValues := []string{"current", "main", "all", "specific"}
+	strategyIndex := p.SelectWithDefault("Choose branch strategy:", strategyOptions, optionIndex(strategyValues, current.BranchStrategy))
 	branchStrategy = strategyValues[strategyIndex]
 	
 	// If specific strategy, ask for target branch
+	targetBranch = ""
 	if branchStrategy == "specific" {
-		targetBranch = p.Input("Enter target branch name:", validation.ValidateBranch)
+		targetBranch = p.InputWithDefault("Enter target branch name", current.TargetBranch, validation.ValidateBranch)
 		if err := validation.ValidateTargetBranch(targetBranch); err != nil {
-			return err
+			return false, err
 		}
 	}
 	fmt.Println()
 
 	// 5. Safety Options
 	fmt.Println("5️⃣ Safety Options")
-	safetyChecks = p.Confirm("Enable safety checks before sync operations?", true)
+	safetyChecks = p.Confirm("Enable safety checks before sync operations?", current.SafetyChecks)
+
+	dirtyAction = current.DirtyWorktreeAction
+	if dirtyAction == "" {
+		dirtyAction = "skip"
+	}
+	if safetyChecks {
+		dirtyOptions := []string{
+			"skip - Skip the sync until changes are committed",
+			"stash - Stash changes, sync, then restore them",
+			"commit - Auto-commit changes before syncing",
+		}
+		dirtyValues := []string{"skip", "stash", "commit"}
+		dirtyIndex := p.SelectWithDefault("When the worktree has uncommitted changes:", dirtyOptions, optionIndex(dirtyValues, dirtyAction))
+		dirtyAction = dirtyValues[dirtyIndex]
+	}
 	
+	forcePush = false
 	if direction == "push" || direction == "both" {
-		forcePush = p.Confirm("Enable force push? (⚠️  Use with caution)", false)
+		forcePush = p.Confirm("Enable force push? (⚠️  Use with caution)", current.ForcePush)
 		if forcePush && !safetyChecks {
 			fmt.Println("⚠️  WARNING: Force push enabled without safety checks!")
 		}
@@ -201,6 +284,7 @@ func runInteractiveInit() error {
 		"Remote":           remote,
 		"Branch Strategy":  branchStrategy,
 		"Safety Checks":    fmt.Sprintf("%v", safetyChecks),
+		"Dirty Worktree":   dirtyAction,
 		"Force Push":       fmt.Sprintf("%v", forcePush),
 	}
 	
@@ -212,27 +296,38 @@ func runInteractiveInit() error {
 	
 	if !p.Confirm("Proceed with this configuration?", true) {
 		fmt.Println("Setup cancelled.")
-		return nil
+		return false, nil
 	}
+	return true, nil
+}
 
-	// Run the actual initialization
-	return initRepository()
+// optionIndex returns the index of value in values, or 0 (the first option) when missing
+func optionIndex(values []string, value string) int {
+	if i := slices.Index(values, value); i >= 0 {
+		return i
+	}
+	return 0
 }
 
 func initRepository() error {
-	// Get current working directory
-	repoPath, err := os.Getwd()
+	// Register the repository the current directory is in, even from a subdirectory
+	repoPath, err := currentRepoRoot()
 	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
+		return err
 	}
+	return registerRepository(repoPath)
+}
 
+// registerRepository adds the repository at repoPath to the config with the
+// settings of the init flag variables
+func registerRepository(repoPath string) error {
 	// Verify this is a Git repository
 	if err := verifyGitRepository(repoPath); err != nil {
 		return err
 	}
 
 	// Verify remote exists
-	if err := verifyRemoteExists(remote); err != nil {
+	if err := verifyRemoteExists(repoPath, remote); err != nil {
 		return err
 	}
 
@@ -246,11 +341,16 @@ func initRepository() error {
 		return fmt.Errorf("invalid branch strategy '%s': must be current, main, all, or specific", branchStrategy)
 	}
 
+	// Validate dirty worktree action
+	if !isValidDirtyAction(dirtyAction) {
+		return fmt.Errorf("invalid dirty action '%s': must be skip, stash, or commit", dirtyAction)
+	}
+
 	// Validate specific branch strategy requirements
 	if branchStrategy == "specific" {
 		if targetBranch == "" {
 			// Default to current branch if not specified
-			currentBranch, err := getCurrentBranch()
+			currentBranch, err := getCurrentBranch(repoPath)
 			if err != nil {
 				return fmt.Errorf("failed to get current branch for 'specific' strategy: %w", err)
 			}
@@ -258,7 +358,7 @@ func initRepository() error {
 			fmt.Printf("Using current branch '%s' as target branch\n", targetBranch)
 		}
 		// Verify the target branch exists
-		if err := verifyBranchExists(targetBranch); err != nil {
+		if err := verifyBranchExists(repoPath, targetBranch); err != nil {
 			return err
 		}
 	} else if targetBranch != "" {
@@ -281,6 +381,15 @@ func initRepository() error {
 		TargetBranch:   targetBranch,
 		SafetyChecks:   safetyChecks,
 		ForcePush:      forcePush,
+
+		DirtyWorktreeAction: dirtyAction,
+		UnionMergePatterns:  unionMerge,
+		FetchDepth:          fetchDepth,
+	}
+
+	// Set up the union merge driver for append-only note files
+	if err := daemon.EnsureUnionMergeAttributes(context.Background(), repoPath, unionMerge); err != nil {
+		return fmt.Errorf("failed to configure union merge: %w", err)
 	}
 
 	// Add to configuration
@@ -299,33 +408,138 @@ func initRepository() error {
 	}
 	fmt.Printf("  Safety Checks: %v\n", safetyChecks)
 	fmt.Printf("  Force Push: %v\n", forcePush)
+	fmt.Printf("  Dirty Worktree Action: %s\n", dirtyAction)
+	if len(unionMerge) > 0 {
+		fmt.Printf("  Union Merge: %s\n", strings.Join(unionMerge, ", "))
+	}
+	if fetchDepth > 0 {
+		fmt.Printf("  Fetch Depth: %d\n", fetchDepth)
+	}
 	fmt.Printf("\nThe daemon will automatically sync this repository when running.\n")
 
 	return nil
 }
 
+// cloneIntoCurrentDirectory clones repoURL into the current directory, which must be empty.
+// The git binary is used so the user's credential helpers and SSH config apply.
+func cloneIntoCurrentDirectory(repoURL, remoteName string) error {
+	if err := validation.ValidateGitURL(repoURL); err != nil {
+		return err
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	return cloneRepository(repoURL, dir, remoteName, fetchDepth)
+}
+
+// cloneRepository clones repoURL into dir (created if missing, must be empty otherwise).
+// A positive depth makes a shallow clone that still tracks all branches.
+func cloneRepository(repoURL, dir, remoteName string, depth int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("directory '%s' is not empty, refusing to clone into it", dir)
+	}
+
+	fmt.Printf("📥 Cloning %s into %s\n", repoURL, dir)
+
+	args := []string{"clone", "--origin", remoteName}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth), "--no-single-branch")
+	}
+	args = append(args, repoURL, dir)
+
+	cloneCmd := exec.Command("git", args...)
+	cloneCmd.Stdin = os.Stdin
+	cloneCmd.Stdout = os.Stdout
+	cloneCmd.Stderr = os.Stderr
+	if err := cloneCmd.Run(); err != nil {
+		return fmt.Errorf("git clone failed: %w", err)
+	}
+
+	fmt.Println("✓ Clone complete")
+	fmt.Println()
+	return nil
+}
+
 func verifyGitRepository(path string) error {
 	gitDir := filepath.Join(path, ".git")
-	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+	info, err := os.Stat(gitDir)
+	if os.IsNotExist(err) {
+		// Bare repositories have no .git directory but can still be synced
+		if isBareRepository(path) {
+			fmt.Println("ℹ️  Bare repository detected: pulls will fetch into local branches, no worktree checks")
+			return nil
+		}
 		return fmt.Errorf("not a git repository (missing .git directory)")
 	}
+	if err != nil {
+		return fmt.Errorf("failed to check .git: %w", err)
+	}
+
+	// Linked worktrees and submodules have a .git file pointing at their git directory
+	if !info.IsDir() {
+		mainRepo, err := linkedWorktreeMain(path)
+		if err != nil {
+			return fmt.Errorf("not a git repository (.git file is not a valid gitdir link): %w", err)
+		}
+		if mainRepo != "" {
+			fmt.Printf("ℹ️  Linked worktree of %s: branches checked out in other worktrees are never switched to or moved\n", mainRepo)
+		}
+	}
 	return nil
 }
 
-func verifyRemoteExists(remoteName string) error {
+// linkedWorktreeMain returns the main worktree of a linked worktree, or "" for
+// other .git file layouts such as submodules
+func linkedWorktreeMain(path string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--path-format=absolute", "--git-dir", "--git-common-dir")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	dirs := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(dirs) != 2 || dirs[0] == dirs[1] {
+		return "", nil
+	}
+	// A bare main repository is its own common directory
+	if filepath.Base(dirs[1]) != ".git" {
+		return dirs[1], nil
+	}
+	return filepath.Dir(dirs[1]), nil
+}
+
+func isBareRepository(path string) bool {
+	cmd := exec.Command("git", "rev-parse", "--is-bare-repository")
+	cmd.Dir = path
+	output, err := cmd.Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+func verifyRemoteExists(repoPath, remoteName string) error {
 	cmd := exec.Command("git", "remote", "get-url", remoteName)
+	cmd.Dir = repoPath
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("remote '%s' does not exist", remoteName)
 	}
 	return nil
 }
 
-func verifyBranchExists(branchName string) error {
+func verifyBranchExists(repoPath, branchName string) error {
 	// Check if branch exists locally
 	cmd := exec.Command("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branchName)
+	cmd.Dir = repoPath
 	if err := cmd.Run(); err != nil {
 		// If not local, check if it exists on remote
 		cmd = exec.Command("git", "show-ref", "--verify", "--quiet", "refs/remotes/origin/"+branchName)
+		cmd.Dir = repoPath
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("branch '%s' does not exist locally or on remote", branchName)
 		}
@@ -333,8 +547,9 @@ func verifyBranchExists(branchName string) error {
 	return nil
 }
 
-func getCurrentBranch() (string, error) {
+func getCurrentBranch(repoPath string) (string, error) {
 	cmd := exec.Command("git", "branch", "--show-current")
+	cmd.Dir = repoPath
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("failed to get current branch: %w", err)
@@ -358,6 +573,11 @@ func isValidBranchStrategy(strategy string) bool {
 	return slices.Contains(validStrategies, strategy)
 }
 
+func isValidDirtyAction(action string) bool {
+	validActions := []string{"skip", "stash", "commit"}
+	return slices.Contains(validActions, action)
+}
+
 func validateConfigCombination() error {
 	// Validate interval is reasonable
 	if interval < 30 {
@@ -366,12 +586,19 @@ func validateConfigCombination() error {
 	if interval > 86400 {
 		return fmt.Errorf("interval too high (%ds): maximum is 24 hours (86400 seconds)", interval)
 	}
+	if fetchDepth < 0 {
+		return fmt.Errorf("invalid fetch depth %d: must be 0 or positive", fetchDepth)
+	}
 
 	// Warn about dangerous combinations
 	if forcePush && !safetyChecks {
 		fmt.Printf("⚠️  WARNING: Force push enabled without safety checks - this can overwrite remote changes\n")
 	}
 
+	if dirtyAction == "commit" && (direction == "push" || direction == "both") {
+		fmt.Printf("⚠️  WARNING: Auto-commit will push every local change, including work in progress\n")
+	}
+
 	if direction == "both" && forcePush {
 		fmt.Printf("⚠️  WARNING: Bidirectional sync with force push may cause data loss\n")
 	}
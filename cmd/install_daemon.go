@@ -8,13 +8,15 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/bnema/git-sync/internal/paths"
 	"github.com/bnema/git-sync/internal/systemd"
 )
 
 var (
-	enableLinger bool
-	autoStart    bool
-	uninstall    bool
+	enableLinger     bool
+	autoStart        bool
+	uninstall        bool
+	socketActivation bool
 )
 
 var installDaemonCmd = &cobra.Command{
@@ -26,6 +28,7 @@ This will create the necessary service files and enable the daemon to start auto
 Examples:
   git sync install-daemon                    # Install with defaults
   git sync install-daemon --no-auto-start   # Install but don't start immediately  
+  git sync install-daemon --socket-activation # Let systemd start the daemon on the first CLI call
   git sync install-daemon --uninstall       # Remove the service`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if uninstall {
@@ -42,6 +45,8 @@ func init() {
 		"automatically start the daemon after installation")
 	installDaemonCmd.Flags().BoolVar(&uninstall, "uninstall", false,
 		"uninstall the systemd service")
+	installDaemonCmd.Flags().BoolVar(&socketActivation, "socket-activation", false,
+		"also install git-sync-daemon.socket, starting the daemon on the first control socket connection")
 }
 
 func installDaemon() error {
@@ -88,14 +93,69 @@ func installDaemon() error {
 		return fmt.Errorf("binary not found at %s: %w", binaryPath, err)
 	}
 
+	service, err := daemonServiceOptions()
+	if err != nil {
+		return err
+	}
+	for _, arg := range service.Args {
+		fmt.Printf("Daemon flag: %s\n", arg)
+	}
+	for _, env := range service.Environment {
+		fmt.Printf("Daemon environment: %s\n", env)
+	}
+
 	// Install the systemd service
-	if err := systemd.InstallUserService(binaryPath, enableLinger, autoStart); err != nil {
+	if err := systemd.InstallUserService(binaryPath, service, enableLinger, autoStart, socketActivation); err != nil {
 		return fmt.Errorf("failed to install systemd service: %w", err)
 	}
 
 	return nil
 }
 
+// daemonServiceOptions passes the --config, --config-dir, --cache-dir and
+// --profile of this run, and the directory overrides in its environment, on
+// to the service, so the daemon uses the same files as the CLI
+func daemonServiceOptions() (systemd.ServiceOptions, error) {
+	var service systemd.ServiceOptions
+
+	if configFile != "" {
+		path, err := filepath.Abs(configFile)
+		if err != nil {
+			return service, fmt.Errorf("failed to resolve config path: %w", err)
+		}
+		service.Args = append(service.Args, "--config="+path)
+		service.WritablePaths = append(service.WritablePaths, filepath.Dir(path))
+	}
+	if configDir != "" || os.Getenv(paths.ConfigDirEnv) != "" {
+		dir, err := paths.ConfigDir()
+		if err != nil {
+			return service, fmt.Errorf("failed to resolve config directory: %w", err)
+		}
+		service.Args = append(service.Args, "--config-dir="+dir)
+		service.WritablePaths = append(service.WritablePaths, dir)
+	}
+	if cacheDir != "" || os.Getenv(paths.CacheDirEnv) != "" {
+		dir, err := paths.CacheDir()
+		if err != nil {
+			return service, fmt.Errorf("failed to resolve cache directory: %w", err)
+		}
+		service.Args = append(service.Args, "--cache-dir="+dir)
+		service.WritablePaths = append(service.WritablePaths, dir)
+	}
+	if os.Getenv(paths.StateDirEnv) != "" {
+		dir, err := paths.StateDir()
+		if err != nil {
+			return service, fmt.Errorf("failed to resolve state directory: %w", err)
+		}
+		service.Environment = append(service.Environment, paths.StateDirEnv+"="+dir)
+		service.WritablePaths = append(service.WritablePaths, dir)
+	}
+	if profile != "" {
+		service.Args = append(service.Args, "--profile="+profile)
+	}
+	return service, nil
+}
+
 func uninstallDaemon() error {
 	fmt.Println("Uninstalling git-sync daemon...")
 
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/daemon"
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+var listFormat string
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List configured repositories with their last sync",
+	Long: `Print every configured repository on one line: whether it is enabled, its
+direction and interval (or schedule), and the result of its latest sync in
+history. json, toml and csv output carry the same fields for scripts.
+
+Examples:
+  git sync list
+  git sync list --format json | jq -r '.[] | select(.last_status == "failed") | .path'
+  git sync list --format csv > repos.csv`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return listRepositories()
+	},
+}
+
+func init() {
+	listCmd.Flags().StringVar(&listFormat, "format", "table", "output format (table, json, toml, csv)")
+	rootCmd.AddCommand(listCmd)
+}
+
+// listedRepo is one repository in the output of git sync list
+type listedRepo struct {
+	Path       string     `json:"path" toml:"path"`
+	Name       string     `json:"name" toml:"name"`
+	Enabled    bool       `json:"enabled" toml:"enabled"`
+	Direction  string     `json:"direction" toml:"direction"`
+	Interval   int        `json:"interval" toml:"interval"` // seconds
+	Schedule   string     `json:"schedule,omitempty" toml:"schedule,omitempty"`
+	LastStatus string     `json:"last_status,omitempty" toml:"last_status,omitempty"`
+	LastSync   *time.Time `json:"last_sync,omitempty" toml:"last_sync,omitempty"`
+	LastError  string     `json:"last_error,omitempty" toml:"last_error,omitempty"`
+}
+
+func listRepositories() error {
+	switch listFormat {
+	case "table", "json", "toml", "csv":
+	default:
+		return fmt.Errorf("invalid format: %s (supported: table, json, toml, csv)", listFormat)
+	}
+
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg = cfg.ExpandPatterns()
+	repos := listedRepos(cfg)
+
+	switch listFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(repos)
+	case "toml":
+		return toml.NewEncoder(os.Stdout).Encode(struct {
+			Repositories []listedRepo `toml:"repositories"`
+		}{repos})
+	case "csv":
+		return writeReposCSV(repos)
+	}
+
+	if len(repos) == 0 {
+		fmt.Println("No repositories configured. Run 'git sync init' in one to add it.")
+		return nil
+	}
+	fmt.Printf("%-40s %-8s %-9s %-18s %-10s %s\n", "REPOSITORY", "ENABLED", "DIRECTION", "INTERVAL", "LAST SYNC", "STATUS")
+	fmt.Println(strings.Repeat("-", 98))
+	for _, repo := range repos {
+		enabled := "✓"
+		if !repo.Enabled {
+			enabled = "✗"
+		}
+		timing := formatDuration(repo.Interval)
+		if repo.Schedule != "" {
+			timing = repo.Schedule
+		}
+		last, status := "never", "-"
+		if repo.LastSync != nil {
+			last, status = formatSince(*repo.LastSync)+" ago", repo.LastStatus
+		}
+		fmt.Printf("%-40s %-8s %-9s %-18s %-10s %s\n",
+			truncateLeft(repo.Path, 40), enabled, repo.Direction, timing, last, status)
+	}
+	return nil
+}
+
+// listedRepos describes the configured repositories in config order, with the
+// latest sync of each found in history
+func listedRepos(cfg *config.Config) []listedRepo {
+	latest := make(map[string]daemon.SyncHistoryEntry)
+	if hm, err := openHistoryManager(cfg); err == nil {
+		// Newest first, so the first entry of each repository is its latest
+		entries, _ := hm.GetHistory(0, "", false)
+		for _, entry := range entries {
+			key := paths.NormalizeRepo(entry.RepoPath)
+			if _, seen := latest[key]; !seen && entry.Direction != "mirror" {
+				latest[key] = entry
+			}
+		}
+	}
+
+	repos := make([]listedRepo, 0, len(cfg.Repositories))
+	for _, repo := range cfg.Repositories {
+		repo = cfg.Global.WithGlobalDefaults(repo)
+		listed := listedRepo{
+			Path:      repo.Path,
+			Name:      filepath.Base(repo.Path),
+			Enabled:   repo.Enabled,
+			Direction: repo.Direction,
+			Interval:  repo.Interval,
+			Schedule:  repo.Schedule,
+		}
+		if entry, synced := latest[paths.NormalizeRepo(repo.Path)]; synced {
+			timestamp := entry.Timestamp
+			listed.LastStatus = entry.Status
+			listed.LastSync = &timestamp
+			listed.LastError = entry.ErrorMsg
+		}
+		repos = append(repos, listed)
+	}
+	return repos
+}
+
+// writeReposCSV prints the repositories as CSV with a header row
+func writeReposCSV(repos []listedRepo) error {
+	w := csv.NewWriter(os.Stdout)
+	_ = w.Write([]string{"path", "name", "enabled", "direction", "interval", "schedule", "last_status", "last_sync", "last_error"})
+	for _, repo := range repos {
+		lastSync := ""
+		if repo.LastSync != nil {
+			lastSync = repo.LastSync.Format(time.RFC3339)
+		}
+		_ = w.Write([]string{
+			repo.Path, repo.Name, strconv.FormatBool(repo.Enabled), repo.Direction,
+			strconv.Itoa(repo.Interval), repo.Schedule, repo.LastStatus, lastSync, repo.LastError,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/daemon"
+)
+
+var logLevelFor time.Duration
+
+var logLevelCmd = &cobra.Command{
+	Use:   "log-level [debug|info|warn|error]",
+	Short: "Show or change the running daemon's log level",
+	Long: `Show or change the log level of the running daemon without restarting it.
+
+Without --for, the override lasts until the next config reload.
+
+Examples:
+  git sync log-level                  # Show current log level
+  git sync log-level debug --for 10m  # Debug logging for 10 minutes
+  git sync log-level info             # Back to info until next reload`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		level := ""
+		if len(args) == 1 {
+			level = args[0]
+		}
+		return setLogLevel(level, logLevelFor)
+	},
+}
+
+func init() {
+	logLevelCmd.Flags().DurationVar(&logLevelFor, "for", 0,
+		"revert to the configured level after this duration (e.g. 10m)")
+	rootCmd.AddCommand(logLevelCmd)
+}
+
+func setLogLevel(level string, duration time.Duration) error {
+	req := control.Request{Command: "log-level", Args: map[string]string{}}
+	if level != "" {
+		req.Args["level"] = level
+		if duration > 0 {
+			req.Args["duration"] = duration.String()
+		}
+	} else if duration > 0 {
+		return fmt.Errorf("--for requires a log level")
+	}
+
+	resp, err := control.Call(req)
+	if err != nil {
+		return err
+	}
+
+	var status daemon.LogLevelStatus
+	if err := json.Unmarshal(resp.Data, &status); err != nil {
+		return fmt.Errorf("failed to decode daemon response: %w", err)
+	}
+
+	if level != "" {
+		fmt.Printf("✓ Daemon log level set to %s\n", status.Level)
+	} else {
+		fmt.Printf("Log level: %s\n", status.Level)
+	}
+	fmt.Printf("  Configured level: %s\n", status.ConfiguredLevel)
+	if !status.OverrideUntil.IsZero() {
+		fmt.Printf("  Reverts at: %s\n", status.OverrideUntil.Format("2006-01-02 15:04:05"))
+	}
+	if len(status.RepoLevels) > 0 {
+		fmt.Println("  Repository levels:")
+		repos := slices.Sorted(maps.Keys(status.RepoLevels))
+		for _, repo := range repos {
+			fmt.Printf("    %s: %s\n", repo, status.RepoLevels[repo])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,626 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/daemon"
+	"github.com/bnema/git-sync/internal/logging"
+)
+
+// logDuplicateWindow is how far apart a daemon log line and the history entry
+// of the same failure may be timestamped
+const logDuplicateWindow = 10 * time.Second
+
+// followBacklog is how many earlier entries --follow shows before new ones,
+// unless --since is given
+const followBacklog = 10
+
+// followPollInterval is how often --follow checks log_file and the history for
+// new entries
+const followPollInterval = time.Second
+
+// followSettle is how long --follow holds new daemon log lines back, so a
+// history entry recorded for the same failure can still replace them
+const followSettle = 3 * time.Second
+
+var (
+	logsErrors  bool
+	logsKind    string
+	logsSince   time.Duration
+	logsRepo    string
+	logsSource  string
+	logsNoColor bool
+	logsFollow  bool
+)
+
+// logEvent is a daemon log line or a history entry in the merged stream
+type logEvent struct {
+	Time    time.Time
+	Level   string // DEBUG, INFO, WARN or ERROR
+	Source  string // history or daemon
+	Repo    string
+	Message string
+	Error   string
+	Kind    daemon.ErrorKind
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show daemon logs and sync history as one stream",
+	Long: `Show the daemon's log lines, from log_file when set and the systemd journal
+otherwise, and the sync history merged into one stream, oldest first. Failures are tagged with their error kind:
+auth, network, timeout, rejected, conflict, hook, repository, config, disk or
+other, so a class of failures can be looked at on its own.
+
+Examples:
+  git sync logs                          # Everything from the last 24 hours
+  git sync logs --errors --since 2h      # Failures and warnings of the last 2 hours
+  git sync logs --kind auth              # Authentication failures only
+  git sync logs --repo ~/notes --errors  # Failures of one repository
+  git sync logs --source history         # Sync history only, without daemon logs
+  git sync logs -f --repo ~/notes        # Follow one repository as it syncs`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return showLogs(cmd.Flags().Changed("since"))
+	},
+}
+
+func init() {
+	logsCmd.Flags().BoolVarP(&logsErrors, "errors", "e", false, "Show only failures, warnings and errors")
+	logsCmd.Flags().StringVarP(&logsKind, "kind", "k", "", "Show only failures of this error kind (implies --errors)")
+	logsCmd.Flags().DurationVarP(&logsSince, "since", "s", 24*time.Hour, "How far back to look, e.g. 30m or 2h")
+	logsCmd.Flags().StringVarP(&logsRepo, "repo", "r", "", "Filter by specific repository path")
+	logsCmd.Flags().StringVar(&logsSource, "source", "all", "Where to read from (all|history|daemon)")
+	logsCmd.Flags().BoolVar(&logsNoColor, "no-color", false, "Don't colorize the output")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep showing new entries as they are written")
+	rootCmd.AddCommand(logsCmd)
+}
+
+func showLogs(sinceSet bool) error {
+	var kind daemon.ErrorKind
+	if logsKind != "" {
+		var valid bool
+		if kind, valid = daemon.ParseErrorKind(logsKind); !valid {
+			names := make([]string, len(daemon.ErrorKinds))
+			for i, k := range daemon.ErrorKinds {
+				names[i] = string(k)
+			}
+			return fmt.Errorf("invalid kind: %s (supported: %s)", logsKind, strings.Join(names, ", "))
+		}
+		logsErrors = true
+	}
+	if logsSince <= 0 {
+		return fmt.Errorf("invalid since: %s (must be positive)", logsSince)
+	}
+	switch logsSource {
+	case "all", "history", "daemon":
+	default:
+		return fmt.Errorf("invalid source: %s (supported: all, history, daemon)", logsSource)
+	}
+
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg = cfg.ExpandPatterns()
+	logsRepo = resolveRepoFilter(cfg, logsRepo)
+	since := time.Now().Add(-logsSince)
+
+	var history, daemonLog []logEvent
+	if logsSource != "daemon" {
+		if history, err = historyEvents(cfg, since); err != nil {
+			return err
+		}
+	}
+	if logsSource != "history" {
+		if cfg.Global.LogFile != "" {
+			daemonLog, err = logFileEvents(cfg.Global.LogFile, since)
+		} else {
+			daemonLog, err = journalEvents(since)
+		}
+		if err != nil {
+			if logsSource == "daemon" {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "ℹ️  Daemon logs unavailable, showing sync history only: %v\n", err)
+		}
+	}
+
+	var events []logEvent
+	for _, event := range mergeLogEvents(history, daemonLog) {
+		if event.matches(kind) {
+			events = append(events, event)
+		}
+	}
+	if logsFollow && !sinceSet && len(events) > followBacklog {
+		events = events[len(events)-followBacklog:]
+	}
+	color := isTerminal() && !logsNoColor && os.Getenv("NO_COLOR") == ""
+	for _, event := range events {
+		printLogEvent(event, color)
+	}
+	if !logsFollow {
+		if len(events) == 0 {
+			fmt.Printf("No matching log entries in the last %s.\n", logsSince)
+		}
+		return nil
+	}
+
+	lastHistory := time.Now()
+	for _, entry := range history {
+		if entry.Time.After(lastHistory) {
+			lastHistory = entry.Time
+		}
+	}
+	return followLogs(cfg, kind, lastHistory, color)
+}
+
+// followLogs prints new daemon log lines and history entries as they are
+// written, until interrupted. History entries newer than lastHistory are new.
+func followLogs(cfg *config.Config, kind daemon.ErrorKind, lastHistory time.Time, color bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	lines := make(chan logEvent)
+	followErr := make(chan error, 1)
+	if logsSource != "history" {
+		go func() {
+			if cfg.Global.LogFile != "" {
+				followErr <- followLogFile(ctx, cfg.Global.LogFile, lines)
+			} else {
+				followErr <- followJournal(ctx, lines)
+			}
+		}()
+	}
+	withHistory := logsSource != "daemon"
+	fmt.Fprintln(os.Stderr, "ℹ️  Following logs (Press Ctrl+C to exit)...")
+
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+
+	// Daemon log lines wait followSettle for the history entries they may repeat
+	var pending, recentHistory []logEvent
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-followErr:
+			if err == nil || ctx.Err() != nil {
+				return nil
+			}
+			if !withHistory {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "ℹ️  Daemon logs unavailable, following sync history only: %v\n", err)
+		case line := <-lines:
+			if !withHistory {
+				if line.matches(kind) {
+					printLogEvent(line, color)
+				}
+				continue
+			}
+			pending = append(pending, line)
+		case <-ticker.C:
+			if !withHistory {
+				continue
+			}
+			history, err := historyEvents(cfg, lastHistory)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+				continue
+			}
+			newest := lastHistory
+			for _, entry := range history {
+				if entry.Time.After(lastHistory) {
+					pending = append(pending, entry)
+					recentHistory = append(recentHistory, entry)
+					if entry.Time.After(newest) {
+						newest = entry.Time
+					}
+				}
+			}
+			lastHistory = newest
+
+			// Print what has settled, forgetting history too old to be repeated
+			settled := time.Now().Add(-followSettle)
+			var ready, held, daemonLines []logEvent
+			for _, event := range pending {
+				switch {
+				case event.Time.After(settled):
+					held = append(held, event)
+				case event.Source == "history":
+					ready = append(ready, event)
+				default:
+					daemonLines = append(daemonLines, event)
+				}
+			}
+			for _, event := range mergeLogEvents(ready, filterRepeats(daemonLines, recentHistory)) {
+				if event.matches(kind) {
+					printLogEvent(event, color)
+				}
+			}
+			pending = held
+			recentHistory = slices.DeleteFunc(recentHistory, func(entry logEvent) bool {
+				return time.Since(entry.Time) > followSettle+logDuplicateWindow
+			})
+		}
+	}
+}
+
+// filterRepeats drops the log lines that only repeat the error of a history entry
+func filterRepeats(lines, history []logEvent) []logEvent {
+	var kept []logEvent
+	for _, line := range lines {
+		if !repeatsHistory(line, history) {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
+
+// followJournal sends the daemon's log lines written to the user journal from
+// now on, until ctx ends or journalctl exits
+func followJournal(ctx context.Context, lines chan<- logEvent) error {
+	if _, err := exec.LookPath("journalctl"); err != nil {
+		return fmt.Errorf("journalctl not found")
+	}
+	journal := exec.CommandContext(ctx, "journalctl", "--user", "-u", "git-sync-daemon.service",
+		"--no-pager", "-o", "cat", "-f", "-n", "0")
+	stdout, err := journal.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to read the journal: %w", err)
+	}
+	if err := journal.Start(); err != nil {
+		return fmt.Errorf("failed to read the journal: %w", err)
+	}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if !sendLogLine(ctx, scanner.Text(), lines) {
+			break
+		}
+	}
+	if err := journal.Wait(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read the journal: %w", err)
+	}
+	return nil
+}
+
+// followLogFile sends the lines appended to log_file from now on, reopening it
+// when the daemon rotates or recreates it, until ctx ends
+func followLogFile(ctx context.Context, path string, lines chan<- logEvent) error {
+	file, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	if file != nil {
+		defer func() { file.Close() }()
+		if _, err := file.Seek(0, io.SeekEnd); err != nil {
+			return fmt.Errorf("failed to read log file: %w", err)
+		}
+	}
+
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+	var partial string
+	for {
+		if file != nil {
+			data, err := io.ReadAll(file)
+			if err != nil {
+				return fmt.Errorf("failed to read log file: %w", err)
+			}
+			text := partial + string(data)
+			for {
+				line, rest, complete := strings.Cut(text, "\n")
+				if !complete {
+					break
+				}
+				if !sendLogLine(ctx, line, lines) {
+					return nil
+				}
+				text = rest
+			}
+			partial = text
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// A rotated or recreated file is read again from its start
+		current, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if file != nil {
+			if opened, err := file.Stat(); err == nil && os.SameFile(opened, current) {
+				continue
+			}
+			file.Close()
+		}
+		if file, err = os.Open(path); err != nil {
+			file = nil
+		}
+		partial = ""
+	}
+}
+
+// sendLogLine parses a daemon log line and sends it when it passes the --repo
+// filter; it reports false once ctx has ended
+func sendLogLine(ctx context.Context, line string, lines chan<- logEvent) bool {
+	for _, event := range parseDaemonLog([]byte(line), time.Time{}) {
+		select {
+		case lines <- event:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return ctx.Err() == nil
+}
+
+// historyEvents reads the sync history recorded since
+func historyEvents(cfg *config.Config, since time.Time) ([]logEvent, error) {
+	historyManager, err := openHistoryManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := historyManager.GetHistory(0, logsRepo, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+
+	var events []logEvent
+	for _, entry := range entries {
+		if entry.Timestamp.Before(since) {
+			continue
+		}
+		event := logEvent{
+			Time:   entry.Timestamp,
+			Level:  "INFO",
+			Source: "history",
+			Repo:   entry.RepoPath,
+			Error:  entry.ErrorMsg,
+			Kind:   entry.Kind(),
+		}
+		duration := formatHistoryDuration(time.Duration(entry.DurationMs) * time.Millisecond)
+		switch entry.Status {
+		case "failed":
+			event.Level = "ERROR"
+			event.Message = fmt.Sprintf("%s failed after %s", entry.Direction, duration)
+		case "skipped":
+			event.Message = fmt.Sprintf("%s skipped: %s", entry.Direction, entry.SkipReason)
+		case "partial":
+			event.Level = "WARN"
+			event.Message = fmt.Sprintf("%s stopped at max_runtime after %s, kept %s", entry.Direction, duration, entry.Progress)
+			event.Error = ""
+		case "noop":
+			event.Message = fmt.Sprintf("%s had nothing to sync (%s)", entry.Direction, duration)
+		default:
+			event.Message = fmt.Sprintf("%s %s in %s", entry.Direction, entry.Status, duration)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// journalEvents reads the daemon's log lines written since from the user journal
+func journalEvents(since time.Time) ([]logEvent, error) {
+	if _, err := exec.LookPath("journalctl"); err != nil {
+		return nil, fmt.Errorf("journalctl not found")
+	}
+	output, err := exec.Command("journalctl", "--user", "-u", "git-sync-daemon.service",
+		"--no-pager", "-o", "cat", "--since", fmt.Sprintf("@%d", since.Unix())).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the journal: %w", err)
+	}
+	return parseDaemonLog(output, since), nil
+}
+
+// logFileEvents reads the daemon's log lines written since from log_file and
+// the backups it was rotated to
+func logFileEvents(path string, since time.Time) ([]logEvent, error) {
+	var events []logEvent
+	for _, file := range append(logging.Backups(path), path) {
+		output, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read log file: %w", err)
+		}
+		events = append(events, parseDaemonLog(output, since)...)
+	}
+	return events, nil
+}
+
+// parseDaemonLog parses the daemon's log lines written since, in the text
+// (time=... level=... msg=...) or json log_format; other lines are ignored
+func parseDaemonLog(output []byte, since time.Time) []logEvent {
+	var events []logEvent
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var fields map[string]string
+		if strings.HasPrefix(line, "{") {
+			fields = parseJSONLog(line)
+		} else {
+			fields = parseLogfmt(line)
+		}
+		timestamp, err := time.Parse(time.RFC3339Nano, fields["time"])
+		if err != nil || fields["msg"] == "" || timestamp.Before(since) {
+			continue
+		}
+		event := logEvent{
+			Time:    timestamp,
+			Level:   strings.ToUpper(fields["level"]),
+			Source:  "daemon",
+			Repo:    fields["repo"],
+			Message: fields["msg"],
+			Error:   fields["error"],
+			Kind:    daemon.ErrorKind(fields["kind"]),
+		}
+		if event.Kind == "" && event.Error != "" {
+			event.Kind = daemon.ClassifyError(event.Error)
+		}
+		if logsRepo != "" && event.Repo != logsRepo && event.Repo != filepath.Base(logsRepo) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// parseLogfmt splits a key=value log line, unquoting quoted values
+func parseLogfmt(line string) map[string]string {
+	fields := make(map[string]string)
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		key, rest, found := strings.Cut(line, "=")
+		if !found || key == "" || strings.ContainsAny(key, " \"") {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := quotedEnd(rest)
+			unquoted, err := strconv.Unquote(rest[:end])
+			if err != nil {
+				break
+			}
+			value, rest = unquoted, rest[end:]
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+		fields[key] = value
+		line = rest
+	}
+	return fields
+}
+
+// parseJSONLog flattens the top-level fields of a json log line into strings
+func parseJSONLog(line string) map[string]string {
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return nil
+	}
+	fields := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if text, isString := value.(string); isString {
+			fields[key] = text
+		} else {
+			fields[key] = fmt.Sprint(value)
+		}
+	}
+	return fields
+}
+
+// quotedEnd returns the index after the closing quote of the quoted string s starts with
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// mergeLogEvents sorts history entries and daemon log lines into one stream,
+// dropping log lines that only repeat the error of a history entry
+func mergeLogEvents(history, journal []logEvent) []logEvent {
+	events := append([]logEvent{}, history...)
+	for _, line := range journal {
+		if !repeatsHistory(line, history) {
+			events = append(events, line)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events
+}
+
+// repeatsHistory reports whether a log line is about a failure history recorded
+func repeatsHistory(line logEvent, history []logEvent) bool {
+	if line.Error == "" {
+		return false
+	}
+	for _, entry := range history {
+		if entry.Error != line.Error || filepath.Base(entry.Repo) != filepath.Base(line.Repo) {
+			continue
+		}
+		if gap := line.Time.Sub(entry.Time); gap > -logDuplicateWindow && gap < logDuplicateWindow {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the event passes the --errors and --kind filters
+func (e logEvent) matches(kind daemon.ErrorKind) bool {
+	if kind != "" {
+		return e.Kind == kind
+	}
+	if logsErrors {
+		return e.Level == "ERROR" || e.Level == "WARN"
+	}
+	return true
+}
+
+// printLogEvent prints one line of the stream, colored by level when color is set
+func printLogEvent(e logEvent, color bool) {
+	level := fmt.Sprintf("%-5s", e.Level)
+	source := fmt.Sprintf("%-7s", e.Source)
+	repo := "-"
+	if e.Repo != "" {
+		repo = filepath.Base(e.Repo)
+	}
+	kind := ""
+	if e.Kind != "" {
+		kind = fmt.Sprintf("[%s] ", e.Kind)
+	}
+	detail := ""
+	if e.Error != "" {
+		detail = ": " + e.Error
+	}
+
+	if color {
+		switch e.Level {
+		case "ERROR":
+			level = "\033[31m" + level + "\033[0m" // Red
+		case "WARN":
+			level = "\033[33m" + level + "\033[0m" // Yellow
+		case "DEBUG":
+			level = "\033[90m" + level + "\033[0m" // Gray
+		}
+		source = "\033[90m" + source + "\033[0m"
+		if kind != "" {
+			kind = "\033[35m" + kind + "\033[0m" // Magenta
+		}
+	}
+	fmt.Printf("%s %s %s %-20s %s%s%s\n",
+		e.Time.Local().Format("2006-01-02 15:04:05"), level, source, repo, kind, e.Message, detail)
+}
@@ -6,18 +6,26 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/notification"
 )
 
 var notificationsCmd = &cobra.Command{
-	Use:   "notifications [enable|disable|status]",
+	Use:   "notifications [enable|disable|status|policy <mode>]",
 	Short: "Configure desktop notifications",
 	Long: `Configure desktop notifications for git sync events.
 
 Examples:
   git sync notifications enable   # Enable notifications
   git sync notifications disable  # Disable notifications  
-  git sync notifications status   # Show current status`,
-	Args: cobra.ExactArgs(1),
+  git sync notifications status   # Show current status
+  git sync notifications policy transitions  # Only notify when a repository starts failing or recovers
+
+Policies:
+  all            Every sync that changed something, and every failure (default)
+  failures-only  Failures only
+  transitions    A repository starting to fail, and recovering
+  digest         One summary for each wave of syncs`,
+	Args: cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		action := args[0]
 		
@@ -28,8 +36,13 @@ Examples:
 			return disableNotifications()
 		case "status":
 			return showNotificationStatus()
+		case "policy":
+			if len(args) != 2 {
+				return fmt.Errorf("policy needs a mode: all, failures-only, transitions or digest")
+			}
+			return setNotificationPolicy(args[1])
 		default:
-			return fmt.Errorf("invalid action: %s (use 'enable', 'disable', or 'status')", action)
+			return fmt.Errorf("invalid action: %s (use 'enable', 'disable', 'status' or 'policy')", action)
 		}
 	},
 }
@@ -78,6 +91,33 @@ func disableNotifications() error {
 	return nil
 }
 
+func setNotificationPolicy(policy string) error {
+	switch policy {
+	case notification.PolicyAll, notification.PolicyFailuresOnly, notification.PolicyTransitions, notification.PolicyDigest:
+	default:
+		return fmt.Errorf("invalid policy: %s (use all, failures-only, transitions or digest)", policy)
+	}
+
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if cfg.Global.NotificationPolicy == policy {
+		fmt.Printf("✓ Notification policy is already %s\n", policy)
+		return nil
+	}
+
+	cfg.Global.NotificationPolicy = policy
+	if err := config.SaveConfig(cfg, configFile); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	fmt.Printf("✓ Notification policy set to %s\n", policy)
+	fmt.Println("ℹ️  A running daemon picks it up on its next config reload")
+	return nil
+}
+
 func showNotificationStatus() error {
 	cfg, err := config.LoadConfig(configFile)
 	if err != nil {
@@ -87,23 +127,17 @@ func showNotificationStatus() error {
 	fmt.Printf("📊 Notification Status\n")
 	fmt.Printf("Enabled: %v\n", cfg.Global.EnableNotifications)
 	fmt.Printf("Timeout: %d ms\n", cfg.Global.NotificationTimeout)
+	fmt.Printf("Policy: %s\n", cfg.Global.NotificationPolicy)
 	
-	// Check if notify-send is available
-	if err := checkNotifySendAvailability(); err != nil {
-		fmt.Printf("⚠️  Warning: %s\n", err)
+	if backend := notification.Backend(); backend != "" {
+		fmt.Printf("✓ Notifications are shown over %s\n", backend)
 	} else {
-		fmt.Printf("✓ notify-send is available\n")
+		fmt.Printf("⚠️  Warning: neither a notification daemon on the session bus nor notify-send is available\n")
 	}
 	
 	return nil
 }
 
-func checkNotifySendAvailability() error {
-	// This is a simple check - the actual availability check is in the notification package
-	// but we can provide basic feedback here
-	return nil
-}
-
 func init() {
 	rootCmd.AddCommand(notificationsCmd)
 }
\ No newline at end of file
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/daemon"
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+var (
+	nowAll  bool
+	nowWait time.Duration
+)
+
+// nowPollInterval is how often history is checked for the results of requested syncs
+const nowPollInterval = 500 * time.Millisecond
+
+var nowCmd = &cobra.Command{
+	Use:   "now [repo]",
+	Short: "Sync a repository right away",
+	Long: `Sync a repository, or every enabled one with --all, right away and print
+the result.
+
+When the daemon runs, it is asked to sync over its control socket, so the sync
+takes its usual slot, retries and notifications; the command waits up to --wait
+for the result. Without a daemon the sync runs inline, like 'git sync run --once'.
+
+The repository defaults to the current directory.
+
+Examples:
+  git sync now                       # Sync the current repository
+  git sync now ~/notes
+  git sync now --all`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if nowAll && len(args) > 0 {
+			return fmt.Errorf("--all doesn't take a repository")
+		}
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		// Failed syncs are reported with the results, not usage mistakes
+		cmd.SilenceUsage = true
+		return syncNow(target, nowAll, nowWait)
+	},
+}
+
+func init() {
+	nowCmd.Flags().BoolVar(&nowAll, "all", false, "sync every enabled repository")
+	nowCmd.Flags().DurationVar(&nowWait, "wait", 2*time.Minute,
+		"how long to wait for the daemon to report results (0 doesn't wait)")
+	rootCmd.AddCommand(nowCmd)
+}
+
+func syncNow(target string, all bool, wait time.Duration) error {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg = cfg.ExpandPatterns()
+
+	var repos []config.RepoConfig
+	if all {
+		for _, repo := range cfg.Repositories {
+			if repo.Enabled {
+				repos = append(repos, repo)
+			}
+		}
+		if len(repos) == 0 {
+			fmt.Println("No enabled repositories to sync.")
+			return nil
+		}
+	} else {
+		repo, err := findRepoIn(cfg, target)
+		if err != nil {
+			return err
+		}
+		if !repo.Enabled {
+			return fmt.Errorf("repository %s is disabled in the config", repo.Path)
+		}
+		repos = []config.RepoConfig{repo}
+	}
+
+	since := time.Now()
+	req := control.Request{Command: "sync", Args: map[string]string{"all": "true"}}
+	if !all {
+		req.Args = map[string]string{"repo": repos[0].Path}
+	}
+	_, err = control.Call(req)
+	if errors.Is(err, control.ErrDaemonNotRunning) {
+		return syncInline(cfg, repos)
+	}
+	if err != nil {
+		return fmt.Errorf("daemon refused the sync: %w", err)
+	}
+
+	if len(repos) == 1 {
+		fmt.Printf("ℹ️  Daemon is syncing %s\n", filepath.Base(repos[0].Path))
+	} else {
+		fmt.Printf("ℹ️  Daemon is syncing %d repositories\n", len(repos))
+	}
+	if wait <= 0 {
+		return nil
+	}
+	return awaitResults(cfg, repos, since, wait)
+}
+
+// syncInline syncs repos in this process, for when no daemon is running
+func syncInline(cfg *config.Config, repos []config.RepoConfig) error {
+	fmt.Println("ℹ️  Daemon is not running, syncing here")
+
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelInfo
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	hm, err := openHistoryManager(cfg)
+	if err != nil {
+		logger.Warn("History unavailable, syncs will not be recorded", "error", err)
+		hm = nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	only := *cfg
+	only.Repositories = repos
+	summary := daemon.RunOnce(ctx, &only, hm, logger)
+	printRunSummary(os.Stdout, summary)
+
+	if failed := summary.Failed(); failed > 0 {
+		return fmt.Errorf("%d of %d repositories failed to sync", failed, len(summary.Results))
+	}
+	return nil
+}
+
+// awaitResults waits for the daemon to record a sync of each repository after
+// since, and prints them. Repositories kept out of history can't be awaited.
+func awaitResults(cfg *config.Config, repos []config.RepoConfig, since time.Time, wait time.Duration) error {
+	hm, err := openHistoryManager(cfg)
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[string]config.RepoConfig)
+	for _, repo := range repos {
+		if repo.HistoryEnabled() {
+			pending[paths.NormalizeRepo(repo.Path)] = repo
+		} else {
+			fmt.Printf("ℹ️  %s keeps syncs out of history, see the daemon logs for its result\n", filepath.Base(repo.Path))
+		}
+	}
+
+	results := make(map[string]daemon.RunResult)
+	deadline := time.Now().Add(wait)
+	for len(pending) > 0 && time.Now().Before(deadline) {
+		time.Sleep(nowPollInterval)
+
+		entries, err := hm.GetHistory(0, "", false)
+		if err != nil {
+			return fmt.Errorf("failed to read history: %w", err)
+		}
+		// Newest first, so the last match is the first sync after the request
+		for _, entry := range entries {
+			if entry.Timestamp.Before(since) {
+				break
+			}
+			if key := paths.NormalizeRepo(entry.RepoPath); pending[key].Path != "" {
+				results[key] = daemon.ResultOf(entry)
+			}
+		}
+		for key := range results {
+			delete(pending, key)
+		}
+	}
+
+	summary := daemon.RunSummary{StartedAt: since, Totals: make(map[string]int)}
+	for _, repo := range repos {
+		if result, done := results[paths.NormalizeRepo(repo.Path)]; done {
+			summary.Results = append(summary.Results, result)
+			summary.Totals[result.Status]++
+		}
+	}
+	if len(summary.Results) > 0 {
+		printRunSummary(os.Stdout, summary)
+	}
+
+	for _, repo := range pending {
+		fmt.Printf("⚠️  %s: no result after %s, it may still be waiting for a slot\n", filepath.Base(repo.Path), wait)
+	}
+	if failed := summary.Failed(); failed > 0 {
+		return fmt.Errorf("%d of %d repositories failed to sync", failed, len(summary.Results))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/daemon"
+	"github.com/bnema/git-sync/internal/state"
+)
+
+var pauseAll bool
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause [repo]",
+	Short: "Stop syncing a repository until it is resumed",
+	Long: `Pause the syncs of a repository, or of every repository with --all, until
+'git sync resume'. The running daemon applies the pause from its next sync on,
+without editing the config or restarting, and pauses survive restarts.
+
+The repository defaults to the current directory.
+
+Examples:
+  git sync pause                     # Pause the current repository
+  git sync pause ~/notes
+  git sync pause --all               # Pause everything, e.g. before a flight`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pauseAll {
+			if len(args) > 0 {
+				return fmt.Errorf("--all doesn't take a repository")
+			}
+			return pauseEverything()
+		}
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		return pauseRepository(target)
+	},
+}
+
+func init() {
+	pauseCmd.Flags().BoolVar(&pauseAll, "all", false, "pause every repository")
+	rootCmd.AddCommand(pauseCmd)
+}
+
+func pauseRepository(target string) error {
+	repo, err := findConfiguredRepo(target)
+	if err != nil {
+		return err
+	}
+
+	paused, err := changePause("pause", map[string]string{"repo": repo.Path}, func(s *state.State) (int, error) {
+		return countChanged(s.Pause(repo.Path, time.Now())), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if paused > 0 {
+		fmt.Printf("✓ Paused %s, run 'git sync resume' to sync it again\n", filepath.Base(repo.Path))
+	} else {
+		fmt.Printf("%s is already paused\n", filepath.Base(repo.Path))
+	}
+	return nil
+}
+
+func pauseEverything() error {
+	paused, err := changePause("pause", map[string]string{"all": "true"}, func(s *state.State) (int, error) {
+		return countChanged(s.PauseAll(time.Now())), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if paused > 0 {
+		fmt.Println("✓ Paused all repositories, run 'git sync resume --all' to sync again")
+	} else {
+		fmt.Println("All repositories are already paused")
+	}
+	return nil
+}
+
+// changePause asks the running daemon to pause or resume, so the change shows in
+// its log, or applies update to the state file itself when no daemon runs. It
+// returns how many repositories were paused or resumed.
+func changePause(command string, args map[string]string, update func(*state.State) (int, error)) (int, error) {
+	resp, err := control.Call(control.Request{Command: command, Args: args})
+	if err == nil {
+		var result daemon.PauseResult
+		if err := json.Unmarshal(resp.Data, &result); err != nil {
+			return 0, fmt.Errorf("failed to decode daemon response: %w", err)
+		}
+		return result.Changed, nil
+	}
+	if !errors.Is(err, control.ErrDaemonNotRunning) {
+		return 0, err
+	}
+
+	var changed int
+	var updateErr error
+	if err := state.Update(func(s *state.State) {
+		changed, updateErr = update(s)
+	}); err != nil {
+		return 0, fmt.Errorf("failed to update state: %w", err)
+	}
+	return changed, updateErr
+}
+
+func countChanged(changed bool) int {
+	if changed {
+		return 1
+	}
+	return 0
+}
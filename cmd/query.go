@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/control"
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query <status|next|last> [path]",
+	Short: "Answer one quick question about a repository, for scripts and prompts",
+	Long: `Ask the daemon about the repository containing path (the current directory
+by default) over the plain-text query protocol of the control socket, and
+print its one-line answer:
+
+  status  syncing, paused, circuit-open, failed, ok, pending or disabled
+  next    Unix time of the next sync
+  last    status and Unix time of the latest sync, e.g. "success 1760450000"
+
+"-" means there is nothing to report, such as a path outside every
+configured repository. Prompts wanting the fastest answer can write
+"STATUS <path>" to the socket themselves instead; see the README.`,
+	Args:      cobra.RangeArgs(1, 2),
+	ValidArgs: []string{"status", "next", "last"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return runQuery(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+}
+
+func runQuery(args []string) error {
+	path := "."
+	if len(args) == 2 {
+		path = args[1]
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	answer, err := control.Query(strings.ToUpper(args[0]), absPath)
+	if err != nil {
+		return err
+	}
+	fmt.Println(answer)
+	return nil
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/daemon"
+)
+
+// reloadTimeout bounds waiting for a reload, which lets syncs in progress finish first
+const reloadTimeout = 10 * time.Minute
+
+var reloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Make the running daemon reload its config",
+	Long: `Make the running daemon read its config file again, like SIGHUP or
+'systemctl --user reload git-sync-daemon', and wait until the changed
+repositories are rescheduled. Errors in the file are reported here and the
+daemon keeps its current settings.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return reloadDaemon()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reloadCmd)
+}
+
+func reloadDaemon() error {
+	resp, err := control.CallTimeout(control.Request{Command: "reload"}, reloadTimeout)
+	if err != nil {
+		return err
+	}
+
+	var summary daemon.RescheduleSummary
+	if err := json.Unmarshal(resp.Data, &summary); err != nil {
+		return fmt.Errorf("failed to decode daemon response: %w", err)
+	}
+	fmt.Println("✓ Config reloaded")
+	fmt.Printf("  %d added, %d removed, %d rescheduled, %d unchanged\n",
+		summary.Added, summary.Removed, summary.Rescheduled, summary.Unchanged)
+	return nil
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/daemon"
+)
+
+var (
+	removeYes          bool
+	removePurgeHistory bool
+)
+
+var removeCmd = &cobra.Command{
+	Use:   "remove [repo]",
+	Short: "Stop syncing a repository and remove it from the config",
+	Long: `Remove a repository from the sync configuration, the reverse of
+'git sync init'. The repository itself is left untouched; only the daemon
+stops syncing it. It is also taken out of its sync set, and a set left with
+a single member is dropped.
+
+The repository defaults to the current directory. Its history entries are
+kept, so 'git sync history' still shows them, unless --purge-history is given.
+
+Examples:
+  git sync remove                    # Remove the current repository, after confirming
+  git sync remove ~/notes --yes
+  git sync remove --purge-history    # Also drop its history entries`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		return removeRepository(target)
+	},
+}
+
+func init() {
+	removeCmd.Flags().BoolVarP(&removeYes, "yes", "y", false, "remove without asking for confirmation")
+	removeCmd.Flags().BoolVar(&removePurgeHistory, "purge-history", false, "also delete the repository's history entries")
+	rootCmd.AddCommand(removeCmd)
+}
+
+func removeRepository(target string) error {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	repo, err := findRepoIn(cfg.ExpandPatterns(), target)
+	if err != nil {
+		return err
+	}
+	if repo.Pattern != "" {
+		return fmt.Errorf("%s is matched by the path glob %s, it can't be removed on its own; disable it with 'git sync disable', or change the glob", repo.Path, repo.Pattern)
+	}
+	name := filepath.Base(repo.Path)
+
+	if !removeYes {
+		question := fmt.Sprintf("Remove %s from the sync configuration?", repo.Path)
+		if removePurgeHistory {
+			question = fmt.Sprintf("Remove %s from the sync configuration and delete its history?", repo.Path)
+		}
+		fmt.Printf("%s (y/N): ", question)
+		var response string
+		_, _ = fmt.Scanln(&response)
+		if response = strings.ToLower(response); response != "y" && response != "yes" {
+			fmt.Println("Removal cancelled.")
+			return nil
+		}
+	}
+
+	set, inSet := cfg.SyncSetOf(repo.Path)
+	if _, err := config.RemoveRepository(repo.Path, configFile); err != nil {
+		return fmt.Errorf("failed to remove repository: %w", err)
+	}
+	fmt.Printf("✓ Removed %s from the sync configuration, its files are untouched\n", name)
+	if inSet && len(set.Repositories) <= 2 {
+		fmt.Printf("ℹ️  Dropped sync set '%s', which needs at least two repositories\n", set.Name)
+	} else if inSet {
+		fmt.Printf("ℹ️  Took it out of sync set '%s'\n", set.Name)
+	}
+
+	// The daemon stops syncing the repository before its history goes
+	notifyDaemonReload()
+
+	if removePurgeHistory {
+		removed, err := purgeHistory(cfg, repo.Path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ Deleted %d history entries\n", removed)
+	}
+	return nil
+}
+
+// purgeHistory deletes the history of the repository at repoPath through the
+// daemon when it runs, so entries it holds in memory go too, or else directly
+func purgeHistory(cfg *config.Config, repoPath string) (int, error) {
+	resp, err := control.Call(control.Request{Command: "purge-history", Args: map[string]string{"repo": repoPath}})
+	if err == nil {
+		var purged daemon.HistoryPurged
+		if err := json.Unmarshal(resp.Data, &purged); err != nil {
+			return 0, fmt.Errorf("failed to decode daemon response: %w", err)
+		}
+		return purged.Removed, nil
+	}
+	if !errors.Is(err, control.ErrDaemonNotRunning) {
+		return 0, fmt.Errorf("failed to purge history through the daemon: %w", err)
+	}
+
+	historyManager, err := openHistoryManager(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer historyManager.Close()
+	return historyManager.RemoveRepository(repoPath)
+}
+
+// notifyDaemonReload makes a running daemon apply a config change right away
+// instead of when its config watcher notices
+func notifyDaemonReload() {
+	_, err := control.CallTimeout(control.Request{Command: "reload"}, reloadTimeout)
+	if err == nil {
+		fmt.Println("✓ The daemon reloaded its config")
+		return
+	}
+	if !errors.Is(err, control.ErrDaemonNotRunning) {
+		fmt.Printf("⚠️  The daemon didn't reload its config: %v\n", err)
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/state"
+)
+
+var resumeAll bool
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume [repo]",
+	Short: "Resume syncing a paused repository",
+	Long: `Resume a repository paused with 'git sync pause', or one the daemon paused
+after too many failed syncs in a row (see circuit_breaker_failures) without
+waiting for that pause to run out. The failure count starts over, and the next
+scheduled sync runs as usual. --all resumes every paused repository.
+
+The repository defaults to the current directory.
+
+Examples:
+  git sync resume                    # Resume the current repository
+  git sync resume ~/notes
+  git sync resume --all`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if resumeAll {
+			if len(args) > 0 {
+				return fmt.Errorf("--all doesn't take a repository")
+			}
+			return resumeEverything()
+		}
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		// Repositories still paused by --all are reported as errors, not usage mistakes
+		cmd.SilenceUsage = true
+		return resumeRepository(target)
+	},
+}
+
+func init() {
+	resumeCmd.Flags().BoolVar(&resumeAll, "all", false, "resume every paused repository")
+	rootCmd.AddCommand(resumeCmd)
+}
+
+func resumeRepository(target string) error {
+	repo, err := findConfiguredRepo(target)
+	if err != nil {
+		return err
+	}
+
+	resumed, err := changePause("resume", map[string]string{"repo": repo.Path}, func(s *state.State) (int, error) {
+		resumed, err := s.Resume(repo.Path, time.Now())
+		return countChanged(resumed), err
+	})
+	if err != nil {
+		return err
+	}
+
+	if resumed > 0 {
+		fmt.Printf("✓ Resumed %s, it syncs again on its next run\n", filepath.Base(repo.Path))
+	} else {
+		fmt.Printf("%s was not paused\n", filepath.Base(repo.Path))
+	}
+	return nil
+}
+
+func resumeEverything() error {
+	resumed, err := changePause("resume", map[string]string{"all": "true"}, func(s *state.State) (int, error) {
+		return s.ResumeAll(time.Now()), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if resumed > 0 {
+		fmt.Println("✓ Resumed all repositories, they sync again on their next run")
+	} else {
+		fmt.Println("No repositories were paused")
+	}
+	return nil
+}
@@ -3,10 +3,16 @@ package cmd
 import (
 	"github.com/bnema/cobra-autocomp"
 	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/paths"
 )
 
 var (
 	configFile string
+	configDir  string
+	cacheDir   string
+	profile    string
 	verbose    bool
 )
 
@@ -24,6 +30,18 @@ Examples:
   git sync daemon                  # Run daemon (usually via systemd)
   git sync install-daemon          # Install systemd service`,
 	Version: "0.3.1",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Flags take precedence over GIT_SYNC_CONFIG_DIR / GIT_SYNC_CACHE_DIR
+		if configDir != "" {
+			paths.SetConfigDir(configDir)
+		}
+		if cacheDir != "" {
+			paths.SetCacheDir(cacheDir)
+		}
+		if profile != "" {
+			config.SetHostProfile(profile)
+		}
+	},
 }
 
 func Execute() error {
@@ -32,7 +50,13 @@ func Execute() error {
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", 
-		"config file (default: ~/.config/git-sync/config.toml)")
+		"config file (default: $XDG_CONFIG_HOME/git-sync/config.toml)")
+	rootCmd.PersistentFlags().StringVar(&configDir, "config-dir", "",
+		"config directory (env "+paths.ConfigDirEnv+", default: $XDG_CONFIG_HOME/git-sync)")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "",
+		"cache directory for history (env "+paths.CacheDirEnv+", default: $XDG_CACHE_HOME/git-sync)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "",
+		"[profiles] section of the config to apply (default: the one whose hosts match the hostname)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, 
 		"verbose output")
 	
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/daemon"
+)
+
+var (
+	runOnce    bool
+	runSummary string
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Sync repositories in the foreground",
+	Long: `Run the sync daemon in the foreground, or with --once sync every enabled
+repository a single time and exit, for cron jobs and CI.
+
+With --once the exit status is non-zero when any repository fails to sync;
+skipped repositories don't count as failures. --summary writes a JSON report
+with the outcome of every repository and totals per status, to a file or to
+stdout with '-'.
+
+Examples:
+  git sync run --once                          # Sync everything once
+  git sync run --once --summary -              # JSON summary on stdout
+  git sync run --once --summary /tmp/sync.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !runOnce {
+			if runSummary != "" {
+				return fmt.Errorf("--summary requires --once")
+			}
+			return runDaemon()
+		}
+		// Failed syncs are reported in the summary, not usage mistakes
+		cmd.SilenceUsage = true
+		return runOneShot()
+	},
+}
+
+func init() {
+	runCmd.Flags().BoolVar(&runOnce, "once", false, "sync every enabled repository once and exit")
+	runCmd.Flags().StringVar(&runSummary, "summary", "", "write a JSON summary to this file ('-' for stdout)")
+	rootCmd.AddCommand(runCmd)
+}
+
+func runOneShot() error {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelInfo
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	// History is optional: a one-shot run still syncs if the cache is unavailable
+	hm, err := openHistoryManager(cfg)
+	if err != nil {
+		logger.Warn("History unavailable, syncs will not be recorded", "error", err)
+		hm = nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	summary := daemon.RunOnce(ctx, cfg, hm, logger)
+
+	// The human-readable report moves to stderr when stdout carries the JSON summary
+	var out io.Writer = os.Stdout
+	if runSummary == "-" {
+		out = os.Stderr
+	}
+	printRunSummary(out, summary)
+
+	if runSummary != "" {
+		if err := writeRunSummary(runSummary, summary); err != nil {
+			return err
+		}
+	}
+
+	if failed := summary.Failed(); failed > 0 {
+		return fmt.Errorf("%d of %d repositories failed to sync", failed, len(summary.Results))
+	}
+	return nil
+}
+
+func printRunSummary(out io.Writer, summary daemon.RunSummary) {
+	if len(summary.Results) == 0 {
+		fmt.Fprintln(out, "No enabled repositories to sync.")
+		return
+	}
+
+	for _, result := range summary.Results {
+		switch result.Status {
+		case "failed":
+			fmt.Fprintf(out, "❌ %s: %s\n", result.Name, result.Error)
+		case "skipped":
+			fmt.Fprintf(out, "⚠️  %s: skipped (%s) %s\n", result.Name, result.SkipReason, result.Error)
+		case "noop":
+			fmt.Fprintf(out, "✓ %s: up to date\n", result.Name)
+		case "partial":
+			fmt.Fprintf(out, "⚠️  %s: partial, %s\n", result.Name, result.Error)
+		default:
+			fmt.Fprintf(out, "✓ %s: synced\n", result.Name)
+		}
+	}
+	fmt.Fprintf(out, "\n📊 %d synced, %d up to date, %d skipped, %d failed\n",
+		summary.Totals["success"], summary.Totals["noop"], summary.Totals["skipped"], summary.Totals["failed"])
+	if partial := summary.Totals["partial"]; partial > 0 {
+		fmt.Fprintf(out, "   %d stopped at max_runtime, a transfer they cut starts over on the next sync\n", partial)
+	}
+}
+
+func writeRunSummary(path string, summary daemon.RunSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode summary: %w", err)
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
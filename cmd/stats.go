@@ -0,0 +1,395 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/daemon"
+)
+
+var (
+	statsRepo  string
+	statsDays  int
+	statsSince string
+	statsEmoji bool
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show sync statistics and an activity heatmap",
+	Long: `Show sync statistics from the history: success rate, average and percentile
+durations, failure causes grouped by error and the busiest repositories, with
+a heatmap of sync activity per weekday and hour (in local time), overall and
+per repository.
+
+Only syncs that actually ran are counted in the success rate, durations and
+heatmap; skipped syncs are not.
+
+Examples:
+  git sync stats                      # Last 30 days, all repositories
+  git sync stats --since 7d           # Last week only
+  git sync stats --since 12h          # Last 12 hours
+  git sync stats --repo /home/notes   # A single repository
+  git sync stats --emoji              # Emoji cells instead of shaded blocks`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showStats()
+	},
+}
+
+func init() {
+	statsCmd.Flags().StringVarP(&statsRepo, "repo", "r", "", "Filter by specific repository path")
+	statsCmd.Flags().IntVarP(&statsDays, "days", "d", 30, "Number of days of history to include")
+	statsCmd.Flags().StringVarP(&statsSince, "since", "s", "", "How far back to look, e.g. 7d or 12h (overrides --days)")
+	statsCmd.Flags().BoolVar(&statsEmoji, "emoji", false, "Render the heatmap with emoji")
+	rootCmd.AddCommand(statsCmd)
+}
+
+// heatmapBlocks and heatmapEmoji are cell glyphs from no activity to the busiest hour
+var (
+	heatmapBlocks = []string{"··", "░░", "▒▒", "▓▓", "██"}
+	heatmapEmoji  = []string{"⬜", "🟦", "🟩", "🟨", "🟥"}
+)
+
+// heatmap counts syncs per weekday (Monday first) and hour
+type heatmap [7][24]int
+
+// How many failure causes and repositories the rankings list
+const (
+	statsTopCauses = 5
+	statsTopRepos  = 5
+)
+
+func showStats() error {
+	since, window, err := statsWindow()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg = cfg.ExpandPatterns()
+
+	historyManager, err := openHistoryManager(cfg)
+	if err != nil {
+		return err
+	}
+
+	statsRepo = resolveRepoFilter(cfg, statsRepo)
+	all, err := historyManager.QueryHistory(daemon.HistoryQuery{Repo: statsRepo, Since: since})
+	if err != nil {
+		return fmt.Errorf("failed to get history: %w", err)
+	}
+
+	byRepo := make(map[string][]daemon.SyncHistoryEntry)
+	for _, entry := range all {
+		byRepo[entry.RepoPath] = append(byRepo[entry.RepoPath], entry)
+	}
+
+	if len(all) == 0 {
+		fmt.Printf("No sync history in the last %s.\n", window)
+		return nil
+	}
+
+	fmt.Printf("📊 Sync activity, last %s\n", window)
+
+	if len(byRepo) > 1 {
+		fmt.Println()
+		printStatsSection("All repositories", all)
+		printBusiestRepos(byRepo)
+	}
+
+	repoPaths := make([]string, 0, len(byRepo))
+	for path := range byRepo {
+		repoPaths = append(repoPaths, path)
+	}
+	sort.Strings(repoPaths)
+
+	for _, path := range repoPaths {
+		fmt.Println()
+		printStatsSection(fmt.Sprintf("%s (%s)", filepath.Base(path), path), byRepo[path])
+	}
+
+	return nil
+}
+
+// statsWindow returns the start of the period --since or --days covers, and
+// how it reads after "last", e.g. "7 days" or "12h"
+func statsWindow() (time.Time, string, error) {
+	if statsSince == "" {
+		if statsDays <= 0 {
+			return time.Time{}, "", fmt.Errorf("days must be positive")
+		}
+		return time.Now().AddDate(0, 0, -statsDays), fmt.Sprintf("%d days", statsDays), nil
+	}
+
+	if days, isDays := strings.CutSuffix(statsSince, "d"); isDays {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return time.Time{}, "", fmt.Errorf("invalid --since '%s': use e.g. 7d or 12h", statsSince)
+		}
+		if n == 1 {
+			return time.Now().AddDate(0, 0, -1), "day", nil
+		}
+		return time.Now().AddDate(0, 0, -n), fmt.Sprintf("%d days", n), nil
+	}
+	window, err := time.ParseDuration(statsSince)
+	if err != nil || window <= 0 {
+		return time.Time{}, "", fmt.Errorf("invalid --since '%s': use e.g. 7d or 12h", statsSince)
+	}
+	return time.Now().Add(-window), statsSince, nil
+}
+
+// printStatsSection prints the summary counters, durations, failure causes and
+// heatmap for a set of entries
+func printStatsSection(title string, entries []daemon.SyncHistoryEntry) {
+	var succeeded, noop, partial, failed, skipped int
+	var durations []time.Duration
+	var grid heatmap
+	var delayed, hostDelayed, lockSkips int
+	var totalWait, totalHostWait time.Duration
+
+	for _, entry := range entries {
+		if entry.QueueWaitMs > 0 {
+			delayed++
+			totalWait += time.Duration(entry.QueueWaitMs) * time.Millisecond
+		}
+		if entry.HostWaitMs > 0 {
+			hostDelayed++
+			totalHostWait += time.Duration(entry.HostWaitMs) * time.Millisecond
+		}
+		if entry.SkipReason == string(daemon.SkipRepoBusy) || entry.SkipReason == string(daemon.SkipRepoLocked) {
+			lockSkips++
+		}
+
+		switch entry.Status {
+		case "success":
+			succeeded++
+		case "noop":
+			succeeded++
+			noop++
+		case "partial":
+			partial++
+		case "failed":
+			failed++
+		case "skipped":
+			skipped++
+			continue
+		}
+		durations = append(durations, time.Duration(entry.DurationMs)*time.Millisecond)
+
+		local := entry.Timestamp.Local()
+		weekday := (int(local.Weekday()) + 6) % 7 // Monday first
+		grid[weekday][local.Hour()]++
+	}
+
+	fmt.Printf("%s\n", title)
+	fmt.Printf("  Syncs: %d (✓ %d succeeded, ❌ %d failed, ⚠️  %d skipped)\n",
+		len(entries), succeeded, failed, skipped)
+	if noop > 0 {
+		fmt.Printf("  No-op Syncs: %d (nothing to push or pull)\n", noop)
+	}
+	if partial > 0 {
+		fmt.Printf("  Partial Syncs: %d (stopped at max_runtime after updating refs)\n", partial)
+	}
+	if ran := succeeded + partial + failed; ran > 0 {
+		fmt.Printf("  Success Rate: %.1f%% of %d syncs that ran\n", float64(succeeded)*100/float64(ran), ran)
+		printDurations(durations)
+	}
+	if delayed > 0 || hostDelayed > 0 || lockSkips > 0 {
+		line := fmt.Sprintf("  Lock Contention: %d skipped (repository busy or locked)", lockSkips)
+		if delayed > 0 {
+			line += fmt.Sprintf(", %d delayed by max_concurrent_syncs (average wait %s)",
+				delayed, formatHistoryDuration(totalWait/time.Duration(delayed)))
+		}
+		if hostDelayed > 0 {
+			line += fmt.Sprintf(", %d delayed by per-host limits (average wait %s)",
+				hostDelayed, formatHistoryDuration(totalHostWait/time.Duration(hostDelayed)))
+		}
+		fmt.Println(line)
+	}
+	printFailureCauses(entries)
+	fmt.Println()
+
+	renderHeatmap(grid)
+}
+
+// printDurations prints the average and percentile durations of the syncs that ran
+func printDurations(durations []time.Duration) {
+	slices.Sort(durations)
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	fmt.Printf("  Duration: average %s, p50 %s, p90 %s, p99 %s, max %s\n",
+		formatHistoryDuration(total/time.Duration(len(durations))),
+		formatHistoryDuration(percentile(durations, 50)),
+		formatHistoryDuration(percentile(durations, 90)),
+		formatHistoryDuration(percentile(durations, 99)),
+		formatHistoryDuration(durations[len(durations)-1]))
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted, which isn't empty
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// errorVariables matches the parts of error messages that differ between
+// occurrences of the same failure: numbers, durations, hashes
+var errorVariables = regexp.MustCompile(`[0-9a-f]{7,40}|\d+(\.\d+)?`)
+
+// failureCause is one kind of failure and how often it happened
+type failureCause struct {
+	kind    string
+	message string // the latest occurrence's
+	count   int
+	last    time.Time
+}
+
+// printFailureCauses lists the most frequent failures, grouping errors that
+// only differ in numbers or hashes
+func printFailureCauses(entries []daemon.SyncHistoryEntry) {
+	causes := make(map[string]*failureCause)
+	for _, entry := range entries {
+		if entry.Status != "failed" {
+			continue
+		}
+		message, _, _ := strings.Cut(strings.TrimSpace(entry.ErrorMsg), "\n")
+		if message == "" {
+			message = "unknown error"
+		}
+		key := entry.ErrorKind + "\x00" + errorVariables.ReplaceAllString(message, "#")
+		cause := causes[key]
+		if cause == nil {
+			cause = &failureCause{kind: entry.ErrorKind}
+			causes[key] = cause
+		}
+		cause.count++
+		if entry.Timestamp.After(cause.last) {
+			cause.last = entry.Timestamp
+			cause.message = message
+		}
+	}
+	if len(causes) == 0 {
+		return
+	}
+
+	ranked := make([]*failureCause, 0, len(causes))
+	for _, cause := range causes {
+		ranked = append(ranked, cause)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].count != ranked[j].count {
+			return ranked[i].count > ranked[j].count
+		}
+		return ranked[i].last.After(ranked[j].last)
+	})
+
+	fmt.Println("  Failure Causes:")
+	for _, cause := range ranked[:min(len(ranked), statsTopCauses)] {
+		message := cause.message
+		if len(message) > 80 {
+			message = message[:77] + "..."
+		}
+		if cause.kind != "" {
+			message = "[" + cause.kind + "] " + message
+		}
+		fmt.Printf("    %4d× %s\n", cause.count, message)
+	}
+	if len(ranked) > statsTopCauses {
+		fmt.Printf("    ... and %d more\n", len(ranked)-statsTopCauses)
+	}
+}
+
+// printBusiestRepos lists the repositories with the most syncs and how often
+// their syncs failed, to spot flaky ones
+func printBusiestRepos(byRepo map[string][]daemon.SyncHistoryEntry) {
+	repoPaths := make([]string, 0, len(byRepo))
+	for path := range byRepo {
+		repoPaths = append(repoPaths, path)
+	}
+	sort.Slice(repoPaths, func(i, j int) bool {
+		a, b := len(byRepo[repoPaths[i]]), len(byRepo[repoPaths[j]])
+		if a != b {
+			return a > b
+		}
+		return repoPaths[i] < repoPaths[j]
+	})
+
+	fmt.Println()
+	fmt.Println("Busiest repositories")
+	for _, path := range repoPaths[:min(len(repoPaths), statsTopRepos)] {
+		var ran, failed int
+		for _, entry := range byRepo[path] {
+			switch entry.Status {
+			case "skipped":
+				continue
+			case "failed":
+				failed++
+			}
+			ran++
+		}
+		line := fmt.Sprintf("  %-24s %5d syncs", filepath.Base(path), len(byRepo[path]))
+		if ran > 0 {
+			line += fmt.Sprintf(", %5.1f%% failed", float64(failed)*100/float64(ran))
+		}
+		fmt.Println(line)
+	}
+}
+
+// renderHeatmap prints the weekday x hour grid scaled to its busiest cell
+func renderHeatmap(grid heatmap) {
+	glyphs := heatmapBlocks
+	if statsEmoji {
+		glyphs = heatmapEmoji
+	}
+
+	peak := 0
+	for _, hours := range grid {
+		for _, count := range hours {
+			peak = max(peak, count)
+		}
+	}
+
+	// Hour labels every three hours, each cell is two columns wide
+	var header strings.Builder
+	for hour := 0; hour < 24; hour += 3 {
+		fmt.Fprintf(&header, "%-6s", fmt.Sprintf("%02d", hour))
+	}
+	fmt.Printf("      %s\n", strings.TrimRight(header.String(), " "))
+
+	days := []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+	for day, hours := range grid {
+		var row strings.Builder
+		for _, count := range hours {
+			row.WriteString(glyphs[heatLevel(count, peak, len(glyphs)-1)])
+		}
+		fmt.Printf("  %s %s\n", days[day], row.String())
+	}
+
+	var legend strings.Builder
+	for _, glyph := range glyphs {
+		legend.WriteString(glyph)
+	}
+	fmt.Printf("      less %s more (peak %d/hour)\n", legend.String(), peak)
+}
+
+// heatLevel maps a count onto 0..levels, reserving 0 for no activity
+func heatLevel(count, peak, levels int) int {
+	if count == 0 || peak == 0 {
+		return 0
+	}
+	level := (count*levels + peak - 1) / peak
+	return min(max(level, 1), levels)
+}
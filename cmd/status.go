@@ -1,20 +1,29 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/cron"
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/daemon"
+	"github.com/bnema/git-sync/internal/state"
 )
 
 var (
-	showAll      bool
-	daemonStatus bool
+	showAll         bool
+	daemonStatus    bool
+	healthStatus    bool
+	effectiveConfig bool
 )
 
 var statusCmd = &cobra.Command{
@@ -25,8 +34,12 @@ var statusCmd = &cobra.Command{
 Examples:
   git sync status                    # Show status for current repo
   git sync status --all              # Show all configured repos  
-  git sync status --daemon           # Show daemon status`,
+  git sync status --daemon           # Show daemon status
+  git sync status --health           # Check that the daemon is syncing, exits 1 when failing
+  git sync status --effective        # Show the settings the daemon resolved for each repo`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// A failing health check is an answer, not a usage mistake, and neither is a stopped daemon
+		cmd.SilenceUsage = healthStatus || effectiveConfig
 		return showStatus()
 	},
 }
@@ -36,9 +49,19 @@ func init() {
 		"show all configured repositories")
 	statusCmd.Flags().BoolVar(&daemonStatus, "daemon", false,
 		"show daemon status")
+	statusCmd.Flags().BoolVar(&healthStatus, "health", false,
+		"show daemon health: stale repositories, failed reloads and history writes")
+	statusCmd.Flags().BoolVar(&effectiveConfig, "effective", false,
+		"show the settings the running daemon uses for each repository, after defaults")
 }
 
 func showStatus() error {
+	if healthStatus {
+		return showHealth()
+	}
+	if effectiveConfig {
+		return showEffectiveConfig()
+	}
 	if daemonStatus {
 		return showDaemonStatus()
 	}
@@ -54,17 +77,34 @@ func showStatus() error {
 		return nil
 	}
 
-	currentDir, _ := os.Getwd()
+	currentDir, err := currentRepoRoot()
+	if err != nil {
+		return err
+	}
+
+	// History is optional here: status still works if the cache is unavailable
+	hm, err := openHistoryManager(cfg)
+	if err != nil {
+		hm = nil
+	}
+
+	// Live transfer progress and pending retries are only available while the daemon runs
+	transfers := activeTransfers()
+	retries := pendingRetries()
+
+	// Show repositories as the daemon runs them, with global settings applied
+	cfg = cfg.ExpandPatterns()
+	for i, repo := range cfg.Repositories {
+		cfg.Repositories[i] = cfg.Global.WithGlobalDefaults(repo)
+	}
 
 	if showAll {
-		return showAllRepositories(cfg.Repositories)
+		return showAllRepositories(cfg, hm, transfers, retries)
 	}
 
 	// Show status for current repository only
-	for _, repo := range cfg.Repositories {
-		if repo.Path == currentDir {
-			return showRepositoryStatus(repo)
-		}
+	if i, found := cfg.FindRepository(currentDir); found {
+		return showRepositoryStatus(cfg, cfg.Repositories[i], hm, transfers, retries)
 	}
 
 	fmt.Printf("Current repository (%s) is not configured for sync.\n", currentDir)
@@ -72,14 +112,39 @@ func showStatus() error {
 	return nil
 }
 
-func showAllRepositories(repos []config.RepoConfig) error {
-	fmt.Printf("Git Sync Configuration (%d repositories)\n\n", len(repos))
+// currentRepoRoot returns the top of the repository the current directory is in,
+// so commands work from any subdirectory
+func currentRepoRoot() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current directory: %w", err)
+	}
+	return repoRoot(cwd), nil
+}
+
+// repoRoot returns the top-level directory of the worktree containing dir, or dir
+// itself outside a worktree, as in bare repositories
+func repoRoot(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return dir
+	}
+	if root := strings.TrimSpace(string(output)); root != "" {
+		return root
+	}
+	return dir
+}
 
-	for i, repo := range repos {
+func showAllRepositories(cfg *config.Config, hm *daemon.HistoryManager, transfers map[string]daemon.TransferProgress, retries map[string]daemon.RetryState) error {
+	fmt.Printf("Git Sync Configuration (%d repositories)\n\n", len(cfg.Repositories))
+
+	for i, repo := range cfg.Repositories {
 		if i > 0 {
 			fmt.Println()
 		}
-		if err := showRepositoryStatus(repo); err != nil {
+		if err := showRepositoryStatus(cfg, repo, hm, transfers, retries); err != nil {
 			fmt.Printf("Error getting status for %s: %v\n", repo.Path, err)
 		}
 	}
@@ -87,36 +152,271 @@ func showAllRepositories(repos []config.RepoConfig) error {
 	return nil
 }
 
-func showRepositoryStatus(repo config.RepoConfig) error {
+func showRepositoryStatus(cfg *config.Config, repo config.RepoConfig, hm *daemon.HistoryManager, transfers map[string]daemon.TransferProgress, retries map[string]daemon.RetryState) error {
 	fmt.Printf("Repository: %s\n", filepath.Base(repo.Path))
 	fmt.Printf("  Path: %s\n", repo.Path)
 	fmt.Printf("  Status: %s\n", getEnabledStatus(repo.Enabled))
 	fmt.Printf("  Direction: %s\n", repo.Direction)
-	fmt.Printf("  Interval: %ds (%s)\n", repo.Interval, formatDuration(repo.Interval))
+	if set, inSet := cfg.SyncSetOf(repo.Path); inSet {
+		fmt.Printf("  Sync Set: %s\n", describeSyncSet(set, repo.Path, cfg.Global.DefaultInterval))
+	} else if repo.SplitsDirections() {
+		fmt.Printf("  Pulls: %s\n", describeDirectionTiming(repo, "pull"))
+		fmt.Printf("  Pushes: %s\n", describeDirectionTiming(repo, "push"))
+	} else if repo.Schedule != "" {
+		fmt.Printf("  Schedule: %s\n", describeSchedule(repo.Schedule))
+	} else {
+		fmt.Printf("  Interval: %ds (%s)\n", repo.Interval, formatDuration(repo.Interval))
+	}
+	if repo.WatchesFiles() {
+		fmt.Printf("  Trigger: %s (syncs on file changes)\n", repo.Trigger)
+		if repo.FSWatchMinGap > 0 || repo.FSWatchMaxDelay > 0 {
+			fmt.Printf("  Batching: %s\n", describeBatching(repo))
+		}
+	}
+	if repo.Priority != "" && repo.Priority != "normal" {
+		fmt.Printf("  Priority: %s\n", repo.Priority)
+	}
+	if repo.Class() != config.ClassNormal {
+		fmt.Printf("  Concurrency Class: %s\n", repo.Class())
+	}
+	if repo.MaxRuntime > 0 {
+		fmt.Printf("  Max Runtime: %s (a transfer cut at the limit starts over)\n", formatDuration(repo.MaxRuntime))
+	}
 	fmt.Printf("  Remote: %s\n", repo.Remote)
 	fmt.Printf("  Branch Strategy: %s\n", repo.BranchStrategy)
 	fmt.Printf("  Safety Checks: %s\n", getBoolStatus(repo.SafetyChecks))
-	fmt.Printf("  Force Push: %s\n", getBoolStatus(repo.ForcePush))
+	fmt.Printf("  Force Push: %s\n", forcePushStatus(repo))
+	if repo.GitBackend != "" {
+		fmt.Printf("  Git Backend: %s\n", repo.GitBackend)
+	}
+	if repo.RunAfterPull != "" {
+		fmt.Printf("  Run After Pull: %s\n", repo.RunAfterPull)
+	}
+	if repo.RunHooks {
+		fmt.Printf("  Run Hooks: ✓ Yes\n")
+	}
+	if repo.LogLevel != "" {
+		fmt.Printf("  Log Level: %s\n", repo.LogLevel)
+	}
+	if repo.Mirror != "" {
+		fmt.Printf("  Mirror: %s\n", repo.Mirror)
+	}
+	if repo.Webhook != "" {
+		signed := ""
+		if repo.WebhookSecret != "" {
+			signed = " (signed)"
+		}
+		fmt.Printf("  Webhook: %s%s\n", repo.Webhook, signed)
+	}
+	if repo.SecretScan {
+		fmt.Printf("  Secret Scan: %s\n", secretScanStatus(repo))
+	}
+	if repo.SafetyChecks {
+		fmt.Printf("  Dirty Worktree Action: %s\n", dirtyActionOrDefault(repo.DirtyWorktreeAction))
+	}
+	if repo.AuthorName != "" || repo.AuthorEmail != "" {
+		fmt.Printf("  Commit Identity: %s <%s> (unset parts from git config)\n", repo.AuthorName, repo.AuthorEmail)
+	}
 
-	// Check Git status if accessible
-	if gitStatus, err := getGitStatus(repo.Path); err == nil {
+	// Check Git status if accessible; the low-power profile doesn't walk worktrees for it
+	if cfg.Global.LowPower() {
+		fmt.Printf("  Git Status: Not read (low-power profile)\n")
+	} else if gitStatus, err := getGitStatus(repo.Path); err == nil {
 		fmt.Printf("  Git Status: %s\n", gitStatus)
+	} else if !errors.Is(err, daemon.ErrNoWorktree) {
+		fmt.Printf("  Git Status: Unknown (%v)\n", err)
+	}
+
+	if transfer, syncing := transfers[repo.Path]; syncing {
+		fmt.Printf("  Syncing: %s (started %s ago)\n", describeTransfer(transfer), formatSince(transfer.StartedAt))
+	}
+	if retry, retrying := retries[repo.Path]; retrying {
+		fmt.Printf("  Retrying: retry %d of %d in %s (transient failure: %s)\n",
+			retry.Attempt, retry.MaxAttempts, formatUntil(retry.NextRetry), retry.LastError)
+	}
+	if since, paused := pausedSince(repo); paused {
+		fmt.Printf("  Paused: ⚠️  since %s, until 'git sync resume'\n", since.Local().Format("2006-01-02 15:04"))
+	} else if circuit, paused := pausedCircuit(repo); paused {
+		fmt.Printf("  Paused: ⚠️  %d failed syncs in a row, resuming in %s or on 'git sync resume' (last error: %s)\n",
+			circuit.Failures, formatUntil(circuit.PausedUntil), circuit.LastError)
+	}
+
+	switch repo.NotifyMode() {
+	case config.NotifyNever:
+		fmt.Printf("  Notifications: ✗ Off for this repository\n")
+	case config.NotifyFailuresOnly:
+		fmt.Printf("  Notifications: Failures only for this repository\n")
+	}
+
+	if !repo.HistoryEnabled() {
+		fmt.Printf("  History: ✗ Not recorded for this repository\n")
+	} else if hm != nil {
+		showLastSync(repo, hm)
 	}
 
 	return nil
 }
 
+// describeBatching describes how change-triggered syncs of repo are batched
+func describeBatching(repo config.RepoConfig) string {
+	var parts []string
+	if repo.FSWatchMinGap > 0 {
+		parts = append(parts, fmt.Sprintf("changes sync %s after the last sync at the earliest", formatDuration(repo.FSWatchMinGap)))
+	}
+	if repo.FSWatchMaxDelay > 0 {
+		parts = append(parts, fmt.Sprintf("changes wait at most %s", formatDuration(repo.FSWatchMaxDelay)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeDirectionTiming describes when direction syncs for a repository with separate pull and push timing
+func describeDirectionTiming(repo config.RepoConfig, direction string) string {
+	interval, schedule := repo.DirectionTiming(direction)
+	if schedule != "" {
+		return describeSchedule(schedule)
+	}
+	return fmt.Sprintf("every %ds (%s)", interval, formatDuration(interval))
+}
+
+// activeTransfers asks the running daemon for in-progress syncs, keyed by repository path
+func activeTransfers() map[string]daemon.TransferProgress {
+	transfers := make(map[string]daemon.TransferProgress)
+
+	resp, err := control.Call(control.Request{Command: "progress"})
+	if err != nil {
+		return transfers
+	}
+
+	var list []daemon.TransferProgress
+	if err := json.Unmarshal(resp.Data, &list); err != nil {
+		return transfers
+	}
+	for _, transfer := range list {
+		transfers[transfer.Repo] = transfer
+	}
+	return transfers
+}
+
+// pendingRetries asks the running daemon for syncs waiting to be retried, keyed by repository path
+func pendingRetries() map[string]daemon.RetryState {
+	retries := make(map[string]daemon.RetryState)
+
+	resp, err := control.Call(control.Request{Command: "retries"})
+	if err != nil {
+		return retries
+	}
+
+	var list []daemon.RetryState
+	if err := json.Unmarshal(resp.Data, &list); err != nil {
+		return retries
+	}
+	for _, retry := range list {
+		retries[retry.Repo] = retry
+	}
+	return retries
+}
+
+// describeTransfer summarizes remote progress, e.g. "Compressing objects 45%"
+func describeTransfer(transfer daemon.TransferProgress) string {
+	if transfer.Phase != "" {
+		return fmt.Sprintf("%s %d%%", transfer.Phase, transfer.Percent)
+	}
+	if transfer.Message != "" {
+		return transfer.Message
+	}
+	return "waiting for remote"
+}
+
+// showLastSync prints the most recent history entry for a repository, including
+// why it was skipped, and when it last actually synced if that was earlier
+func showLastSync(repo config.RepoConfig, hm *daemon.HistoryManager) {
+	entries, err := hm.GetHistory(0, repo.Path, false)
+	if err != nil || len(entries) == 0 {
+		fmt.Printf("  Last Sync: never\n")
+		return
+	}
+
+	last := entries[0]
+	fmt.Printf("  Last Sync: %s (%s ago)\n", last.Status, formatSince(last.Timestamp))
+	switch last.Status {
+	case "skipped":
+		fmt.Printf("  Skip Reason: %s - %s\n", last.SkipReason, last.ErrorMsg)
+	case "failed":
+		fmt.Printf("  Last Error: %s\n", last.ErrorMsg)
+	}
+	if last.HookError != "" {
+		fmt.Printf("  ⚠️  After-pull command: %s\n", last.HookError)
+	}
+
+	if last.BytesFetched > 0 {
+		fmt.Printf("  Fetched: %s\n", formatBytes(last.BytesFetched))
+	}
+
+	if last.Status != "success" && last.Status != "noop" {
+		for _, entry := range entries[1:] {
+			if entry.Status == "success" || entry.Status == "noop" {
+				fmt.Printf("  Last Success: %s ago\n", formatSince(entry.Timestamp))
+				return
+			}
+		}
+		fmt.Printf("  Last Success: none recorded\n")
+	}
+}
+
+// formatBytes renders a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for value := n / unit; value >= unit; value /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatSince renders the time elapsed since t in a compact form
+func formatSince(t time.Time) string {
+	return formatDuration(int(time.Since(t).Seconds()))
+}
+
+// formatUntil renders the time left until t in a compact form
+func formatUntil(t time.Time) string {
+	return formatDuration(max(int(time.Until(t).Seconds()), 0))
+}
+
 func showDaemonStatus() error {
-	// Check if systemd service exists
+	// The daemon answers for itself, however it was started
+	resp, err := control.Call(control.Request{Command: "status"})
+	if errors.Is(err, control.ErrDaemonNotRunning) {
+		fmt.Println("Daemon Status: Not running")
+		fmt.Println("Run 'git sync install-daemon' to install the systemd service, or 'git sync daemon' to start it.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var status daemon.DaemonStatus
+	if err := json.Unmarshal(resp.Data, &status); err != nil {
+		return fmt.Errorf("failed to decode daemon response: %w", err)
+	}
+
+	fmt.Printf("Daemon Status: Running (pid %d, up %s)\n", status.PID, formatSince(status.StartedAt))
+	fmt.Printf("  Config: %s\n", status.ConfigPath)
+	fmt.Printf("  Log Level: %s, up to %d syncs at once (%d io-heavy, %d light)\n", status.LogLevel, status.MaxConcurrent,
+		status.ClassLimits[config.ClassIOHeavy], status.ClassLimits[config.ClassLight])
+	showDaemonRepositories(status)
+	showRateLimits()
+	showHistoryHealth()
+
+	// Started by hand rather than by the systemd service
 	cmd := exec.Command("systemctl", "--user", "is-active", "git-sync-daemon.service")
 	if err := cmd.Run(); err != nil {
-		fmt.Println("Daemon Status: Not installed or not running")
-		fmt.Println("Run 'git sync install-daemon' to install the systemd service.")
 		return nil
 	}
 
-	fmt.Println("Daemon Status: Running")
-
 	// Get service status
 	cmd = exec.Command("systemctl", "--user", "status", "git-sync-daemon.service", "--no-pager")
 	output, err := cmd.Output()
@@ -136,19 +436,259 @@ func showDaemonStatus() error {
 	return nil
 }
 
+// showDaemonRepositories prints what the daemon is doing with each repository
+func showDaemonRepositories(status daemon.DaemonStatus) {
+	fmt.Println("\nRepositories:")
+	if status.PausedAll {
+		fmt.Println("  ℹ️  All repositories are paused, run 'git sync resume --all' to sync again")
+	}
+	if len(status.Repositories) == 0 {
+		fmt.Println("  No enabled repositories")
+	}
+	for _, repo := range status.Repositories {
+		name := filepath.Base(repo.Path)
+		last := "not synced yet"
+		if !repo.LastSync.IsZero() {
+			last = fmt.Sprintf("last %s %s ago", repo.LastStatus, formatSince(repo.LastSync))
+		}
+		switch {
+		case repo.Syncing:
+			fmt.Printf("  🔄 %s: syncing, %s\n", name, last)
+		case repo.Paused && !status.PausedAll:
+			fmt.Printf("  ℹ️  %s: paused, %s\n", name, last)
+		case !repo.PausedUntil.IsZero():
+			fmt.Printf("  ⚠️  %s: paused after failures until %s, %s\n", name, repo.PausedUntil.Local().Format("15:04"), last)
+		case !repo.NextSync.IsZero() && !status.PausedAll:
+			fmt.Printf("  %s: %s, next in %s\n", name, last, formatDuration(max(0, int(time.Until(repo.NextSync).Seconds()))))
+		default:
+			fmt.Printf("  %s: %s\n", name, last)
+		}
+	}
+}
+
+// showHealth prints the running daemon's health check, failing when the daemon is
+func showHealth() error {
+	resp, err := control.Call(control.Request{Command: "health"})
+	if err != nil {
+		return err
+	}
+	var health daemon.Health
+	if err := json.Unmarshal(resp.Data, &health); err != nil {
+		return fmt.Errorf("failed to decode daemon response: %w", err)
+	}
+
+	switch health.Status {
+	case daemon.HealthOK:
+		fmt.Println("✓ Daemon is healthy")
+	case daemon.HealthDegraded:
+		fmt.Println("⚠️  Daemon is degraded")
+	default:
+		fmt.Println("❌ Daemon is failing")
+	}
+	for _, problem := range health.Problems {
+		fmt.Printf("  - %s\n", problem)
+	}
+
+	fmt.Printf("\nUp %s, %d schedules, %d goroutines\n", formatSince(health.StartedAt), health.Schedules, health.Goroutines)
+	if reload := health.LastReload; reload != nil {
+		if reload.Error != "" {
+			fmt.Printf("❌ Last config reload %s ago failed: %s\n", formatSince(reload.At), reload.Error)
+		} else {
+			fmt.Printf("✓ Last config reload %s ago\n", formatSince(reload.At))
+		}
+	}
+
+	fmt.Println("\nRepositories:")
+	for _, repo := range health.Repositories {
+		name := filepath.Base(repo.Path)
+		switch {
+		case repo.Paused:
+			fmt.Printf("  ℹ️  %s: paused\n", name)
+		case repo.LastSuccess.IsZero() && repo.Stale:
+			fmt.Printf("  ❌ %s: no successful sync since the daemon started\n", name)
+		case repo.LastSuccess.IsZero() && repo.LastSync.IsZero():
+			fmt.Printf("  ℹ️  %s: not synced yet\n", name)
+		case repo.LastSuccess.IsZero():
+			fmt.Printf("  ⚠️  %s: no successful sync yet, last one %s\n", name, repo.LastStatus)
+		case repo.Stale:
+			fmt.Printf("  ❌ %s: last success %s ago, stale\n", name, formatSince(repo.LastSuccess))
+		case repo.LastSync.After(repo.LastSuccess):
+			fmt.Printf("  ⚠️  %s: last success %s ago, last sync %s\n", name, formatSince(repo.LastSuccess), repo.LastStatus)
+		default:
+			fmt.Printf("  ✓ %s: last success %s ago\n", name, formatSince(repo.LastSuccess))
+		}
+	}
+
+	if health.Status == daemon.HealthFailing {
+		return errors.New("daemon health check failed")
+	}
+	return nil
+}
+
+// showEffectiveConfig prints the configuration the running daemon resolved
+func showEffectiveConfig() error {
+	resp, err := control.Call(control.Request{Command: "config-report"})
+	if err != nil {
+		return err
+	}
+	var report daemon.ConfigReport
+	if err := json.Unmarshal(resp.Data, &report); err != nil {
+		return fmt.Errorf("failed to decode daemon response: %w", err)
+	}
+
+	fmt.Printf("📊 Effective configuration of %s\n", report.ConfigPath)
+	fmt.Printf("  Profile: %s, max %d concurrent syncs (%d io-heavy, %d light)\n",
+		report.Profile, report.MaxConcurrent, report.MaxIOHeavy, report.MaxLight)
+	if report.MaxPerHost > 0 || report.HostDelay > 0 {
+		fmt.Printf("  Per remote host: max %d syncs at once (0 = no limit), starts %s apart\n",
+			report.MaxPerHost, formatDuration(report.HostDelay))
+	}
+	if report.HostProfile != "" {
+		fmt.Printf("  Machine profile: %s\n", report.HostProfile)
+	}
+	for _, repo := range report.Repositories {
+		fmt.Printf("\n%s\n", repo.Path)
+		if repo.Pattern != "" {
+			fmt.Printf("  Matched by: %s\n", repo.Pattern)
+		}
+		fmt.Printf("  Direction: %s, remote: %s, branches: %s\n", repo.Direction, orNotSet(repo.Remote), orNotSet(repo.BranchStrategy))
+		switch {
+		case repo.SyncSet != "" && repo.Schedule != "":
+			fmt.Printf("  Timing: sync set %s, %s\n", repo.SyncSet, describeSchedule(repo.Schedule))
+		case repo.SyncSet != "":
+			fmt.Printf("  Timing: sync set %s, every %s\n", repo.SyncSet, formatDuration(repo.Interval))
+		case repo.PullTiming != "":
+			fmt.Printf("  Timing: pulls %s, pushes %s\n", repo.PullTiming, repo.PushTiming)
+		case repo.Schedule != "":
+			fmt.Printf("  Timing: %s\n", describeSchedule(repo.Schedule))
+		default:
+			fmt.Printf("  Timing: every %s\n", formatDuration(repo.Interval))
+		}
+		fmt.Printf("  Trigger: %s, backend: %s, priority: %s, concurrency class: %s\n",
+			repo.Trigger, repo.GitBackend, repo.Priority, repo.ConcurrencyClass)
+		fmt.Printf("  Retries: %d, dirty worktree: %s, safety checks: %s\n",
+			repo.RetryMaxAttempts, repo.DirtyWorktreeAction, getBoolStatus(repo.SafetyChecks))
+		if repo.Proxy != "" {
+			fmt.Printf("  Proxy: %s\n", repo.Proxy)
+		}
+		if repo.LogLevel != "" {
+			fmt.Printf("  Log Level: %s\n", repo.LogLevel)
+		}
+		if repo.MaxRuntime > 0 {
+			fmt.Printf("  Max Runtime: %s (a transfer cut at the limit starts over)\n", formatDuration(repo.MaxRuntime))
+		}
+		fmt.Printf("  Secret scan: %s, history: %s, notifications: %s\n",
+			getBoolStatus(repo.SecretScan), getBoolStatus(repo.History), repo.Notify)
+		for _, warning := range repo.Warnings {
+			fmt.Printf("  ⚠️  %s\n", warning)
+		}
+	}
+
+	if len(report.Skipped) > 0 {
+		fmt.Println("\nNot synced:")
+		for _, skipped := range report.Skipped {
+			fmt.Printf("  ℹ️  %s: %s\n", skipped.Path, skipped.Reason)
+		}
+	}
+	return nil
+}
+
+// showHistoryHealth prints whether the daemon manages to record sync history
+func showHistoryHealth() {
+	resp, err := control.Call(control.Request{Command: "history-health"})
+	if err != nil {
+		return
+	}
+	var health daemon.HistoryHealth
+	if err := json.Unmarshal(resp.Data, &health); err != nil {
+		return
+	}
+
+	fmt.Println("\nHistory:")
+	size := formatBytes(health.FileSizeBytes)
+	if health.OldFileSizeBytes > 0 {
+		size += fmt.Sprintf(" (+%s rotated)", formatBytes(health.OldFileSizeBytes))
+	}
+	fmt.Printf("  File: %s, %s\n", health.File, size)
+	if health.Failing() {
+		fmt.Printf("  ❌ Last %d writes failed, syncs are not being recorded: %s\n", health.ConsecutiveFailures, health.LastError)
+	} else {
+		fmt.Printf("  ✓ %d syncs recorded since the daemon started\n", health.Writes)
+	}
+	if health.WriteFailures > 0 && !health.Failing() {
+		fmt.Printf("  ⚠️  %d writes failed, last %s ago: %s\n", health.WriteFailures, formatSince(health.LastFailure), health.LastError)
+	}
+	if health.Pending > 0 {
+		fmt.Printf("  ℹ️  %d entries kept in memory, recorded once the file is writable again\n", health.Pending)
+	}
+	if health.Dropped > 0 {
+		fmt.Printf("  ⚠️  %d entries lost, too many were waiting to be recorded\n", health.Dropped)
+	}
+	if health.Rotations > 0 {
+		fmt.Printf("  Rotated %d times, last %s ago\n", health.Rotations, formatSince(health.LastRotation))
+	}
+}
+
+// showRateLimits prints the quotas git hosts reported to the daemon's recent syncs
+func showRateLimits() {
+	resp, err := control.Call(control.Request{Command: "rate-limits"})
+	if err != nil {
+		return
+	}
+	var limits []daemon.RateLimit
+	if err := json.Unmarshal(resp.Data, &limits); err != nil || len(limits) == 0 {
+		return
+	}
+
+	fmt.Println("\nRate Limits:")
+	now := time.Now()
+	for _, limit := range limits {
+		host := limit.Host
+		if limit.Resource != "" {
+			host = fmt.Sprintf("%s (%s)", limit.Host, limit.Resource)
+		}
+		quota := "quota unknown"
+		if limit.Limit > 0 {
+			quota = fmt.Sprintf("%d of %d left", limit.Remaining, limit.Limit)
+		}
+		if until := limit.BackoffUntil(now); !until.IsZero() {
+			fmt.Printf("  ⚠️  %s: %s, syncs paused until %s\n", host, quota, until.Local().Format("15:04"))
+		} else if limit.Reset.After(now) {
+			fmt.Printf("  ✓ %s: %s, resets at %s\n", host, quota, limit.Reset.Local().Format("15:04"))
+		} else {
+			fmt.Printf("  ✓ %s: %s\n", host, quota)
+		}
+	}
+}
+
+// worktreeStatusTimeout bounds reading a worktree's status, so huge worktrees don't stall status --all
+const worktreeStatusTimeout = 2 * time.Second
+
 func getGitStatus(repoPath string) (string, error) {
-	cmd := exec.Command("git", "status", "--porcelain")
-	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	status, err := daemon.ReadWorktreeStatus(repoPath, worktreeStatusTimeout)
 	if err != nil {
 		return "", err
 	}
 
-	if len(output) == 0 {
+	if status.Clean() {
 		return "Clean", nil
 	}
 
-	return "Modified files present", nil
+	var parts []string
+	if status.Modified > 0 {
+		parts = append(parts, fmt.Sprintf("%d modified", status.Modified))
+	}
+	if status.Untracked > 0 {
+		parts = append(parts, fmt.Sprintf("%d untracked", status.Untracked))
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+func dirtyActionOrDefault(action string) string {
+	if action == "" {
+		return "skip"
+	}
+	return action
 }
 
 func getEnabledStatus(enabled bool) string {
@@ -158,6 +698,85 @@ func getEnabledStatus(enabled bool) string {
 	return "✗ Disabled"
 }
 
+// describeSyncSet shows where a repository sits in its sync set and when the set syncs
+func describeSyncSet(set config.SyncSetConfig, repoPath string, defaultInterval int) string {
+	position := 0
+	for i, path := range set.Repositories {
+		if path == repoPath {
+			position = i + 1
+		}
+	}
+
+	interval := set.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	timing := fmt.Sprintf("every %s", formatDuration(interval))
+	if set.Schedule != "" {
+		timing = describeSchedule(set.Schedule)
+	}
+	return fmt.Sprintf("%s (%d of %d, %s)", set.Name, position, len(set.Repositories), timing)
+}
+
+// describeSchedule shows a cron schedule with the next time it fires
+func describeSchedule(expr string) string {
+	schedule, err := cron.Parse(expr)
+	if err != nil {
+		return fmt.Sprintf("%s (❌ %v)", expr, err)
+	}
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		return fmt.Sprintf("%s (never fires)", expr)
+	}
+	return fmt.Sprintf("%s (next sync %s)", expr, next.Format("2006-01-02 15:04"))
+}
+
+// secretScanStatus names the rules pushes of a scanned repository are checked against
+func secretScanStatus(repo config.RepoConfig) string {
+	if repo.SecretScanRules == "" {
+		return "✓ On (built-in rules)"
+	}
+	return fmt.Sprintf("✓ On (rules from %s)", repo.SecretScanRules)
+}
+
+// forcePushStatus describes force pushing, including a temporary window set by arm-force
+func forcePushStatus(repo config.RepoConfig) string {
+	if !repo.ForcePush {
+		if s, err := state.Load(); err == nil {
+			if until, armed := s.ForcePushArmedUntil(repo.Path, time.Now()); armed {
+				return fmt.Sprintf("⚠️  Armed until %s", until.Format("2006-01-02 15:04:05"))
+			}
+		}
+	}
+	return getBoolStatus(repo.ForcePush)
+}
+
+// pausedCircuit returns the circuit of repo if the circuit breaker has paused it
+func pausedCircuit(repo config.RepoConfig) (state.Circuit, bool) {
+	s, err := state.Load()
+	if err != nil {
+		return state.Circuit{}, false
+	}
+	return s.Paused(repo.Path, time.Now())
+}
+
+// pausedSince returns when repo was paused with 'git sync pause', if it is
+func pausedSince(repo config.RepoConfig) (time.Time, bool) {
+	s, err := state.Load()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return s.PausedSince(repo.Path)
+}
+
+// orNotSet marks empty settings in output
+func orNotSet(value string) string {
+	if value == "" {
+		return "(not set)"
+	}
+	return value
+}
+
 func getBoolStatus(value bool) string {
 	if value {
 		return "✓ Yes"
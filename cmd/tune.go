@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/daemon"
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+var (
+	tuneDays  int
+	tuneRepo  string
+	tuneApply bool
+)
+
+// tuneIntervals are the intervals tune suggests, in seconds
+var tuneIntervals = []int{60, 120, 300, 600, 900, 1800, 3600}
+
+const (
+	// tuneSyncsPerChange is how many syncs an average gap between changes should span
+	tuneSyncsPerChange = 20
+
+	// tuneMinSyncs and tuneMinSpan are the history needed before suggesting anything
+	tuneMinSyncs = 10
+	tuneMinSpan  = 24 * time.Hour
+)
+
+var tuneCmd = &cobra.Command{
+	Use:   "tune",
+	Short: "Suggest sync intervals from history",
+	Long: `Analyze how often each repository actually changed in the sync history and
+suggest intervals that match: rarely changing repositories can sync less often,
+busy ones more often. Suggestions range from 1 minute to 1 hour.
+
+Only syncs that pushed, pulled or committed something count as changes; no-op
+syncs do not. Repositories that sync only on file changes are left alone.
+
+Examples:
+  git sync tune                 # Suggestions from the last 30 days
+  git sync tune --days 90       # Use a longer window
+  git sync tune --apply         # Write the suggested intervals to the config`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return tuneIntervalsFromHistory()
+	},
+}
+
+func init() {
+	tuneCmd.Flags().IntVarP(&tuneDays, "days", "d", 30, "Number of days of history to analyze")
+	tuneCmd.Flags().StringVarP(&tuneRepo, "repo", "r", "", "Only tune a specific repository path")
+	tuneCmd.Flags().BoolVar(&tuneApply, "apply", false, "Apply the suggested intervals to the config")
+	rootCmd.AddCommand(tuneCmd)
+}
+
+// intervalSuggestion is the outcome of analyzing one repository's history
+type intervalSuggestion struct {
+	changes   int
+	syncs     int
+	span      time.Duration
+	suggested int // seconds, 0 without enough history
+}
+
+func tuneIntervalsFromHistory() error {
+	if tuneDays <= 0 {
+		return fmt.Errorf("days must be positive")
+	}
+
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	historyManager, err := openHistoryManager(cfg)
+	if err != nil {
+		return err
+	}
+
+	tuneRepo = resolveRepoFilter(cfg, tuneRepo)
+	entries, err := historyManager.GetHistory(0, tuneRepo, false)
+	if err != nil {
+		return fmt.Errorf("failed to get history: %w", err)
+	}
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -tuneDays)
+	byRepo := make(map[string][]daemon.SyncHistoryEntry)
+	for _, entry := range entries {
+		if !entry.Timestamp.Before(since) {
+			byRepo[entry.RepoPath] = append(byRepo[entry.RepoPath], entry)
+		}
+	}
+
+	fmt.Printf("📊 Interval suggestions from the last %d days\n", tuneDays)
+
+	applied := 0
+	pending := 0
+	for i, repo := range cfg.Repositories {
+		if tuneRepo != "" && !paths.SameRepo(repo.Path, tuneRepo) {
+			continue
+		}
+
+		fmt.Printf("\n%s (%s)\n", filepath.Base(repo.Path), repo.Path)
+		if repo.Trigger == "fswatch" {
+			fmt.Println("  ℹ️  Syncs on file changes only, the interval is not used")
+			continue
+		}
+
+		s := suggestInterval(byRepo[repo.Path], now)
+		if s.suggested == 0 {
+			fmt.Printf("  ℹ️  Not enough history yet (%d syncs), keeping %s\n", s.syncs, formatDuration(repo.Interval))
+			continue
+		}
+
+		days := s.span.Hours() / 24
+		fmt.Printf("  Changes: %d in %.0f days (%d syncs)\n", s.changes, days, s.syncs)
+
+		// Only suggest changes that at least halve or double the interval
+		if s.suggested*2 > repo.Interval && s.suggested < repo.Interval*2 {
+			fmt.Printf("  ✓ Interval %s fits\n", formatDuration(repo.Interval))
+			continue
+		}
+
+		reason := "no changes"
+		if s.changes > 0 {
+			reason = fmt.Sprintf("changed every %s on average", formatGap(s.span/time.Duration(s.changes)))
+		}
+		fmt.Printf("  ⚠️  Interval %s, suggest %s (%s)\n",
+			formatDuration(repo.Interval), formatDuration(s.suggested), reason)
+
+		if tuneApply {
+			cfg.Repositories[i].Interval = s.suggested
+			applied++
+		} else {
+			pending++
+		}
+	}
+
+	fmt.Println()
+	if applied > 0 {
+		if err := config.SaveConfig(cfg, configFile); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		fmt.Printf("✓ Applied %d interval change(s); a running daemon picks them up automatically.\n", applied)
+	} else if pending > 0 {
+		fmt.Println("Run 'git sync tune --apply' to use the suggested intervals.")
+	} else {
+		fmt.Println("✓ Nothing to change.")
+	}
+
+	return nil
+}
+
+// suggestInterval picks an interval that spans the average gap between
+// changes with tuneSyncsPerChange syncs
+func suggestInterval(entries []daemon.SyncHistoryEntry, now time.Time) intervalSuggestion {
+	var s intervalSuggestion
+	first := now
+	for _, entry := range entries {
+		switch entry.Status {
+		case "success":
+			s.changes++
+		case "noop":
+		default:
+			continue
+		}
+		s.syncs++
+		if entry.Timestamp.Before(first) {
+			first = entry.Timestamp
+		}
+	}
+
+	s.span = now.Sub(first)
+	if s.syncs < tuneMinSyncs || s.span < tuneMinSpan {
+		return s
+	}
+
+	target := s.span / time.Duration(tuneSyncsPerChange)
+	if s.changes > 0 {
+		target = s.span / time.Duration(s.changes*tuneSyncsPerChange)
+	}
+
+	s.suggested = tuneIntervals[0]
+	for _, interval := range tuneIntervals {
+		if time.Duration(interval)*time.Second <= target {
+			s.suggested = interval
+		}
+	}
+	return s
+}
+
+// formatGap formats the time between changes, in days once it exceeds two
+func formatGap(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%.1f days", d.Hours()/24)
+	}
+	return formatDuration(int(d.Seconds()))
+}
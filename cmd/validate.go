@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bnema/git-sync/internal/config"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file for mistakes",
+	Long: `Check the config file for mistakes without changing it: syntax errors,
+unknown keys, invalid values, duplicate repositories, paths that aren't git
+repositories, and settings that have no effect together.
+
+Every problem is listed, not just the first one, and the command exits
+non-zero when there are errors, so it can check a config before the daemon
+loads it. Warnings alone don't fail it.
+
+Examples:
+  git sync validate
+  git sync validate --config ~/dotfiles/git-sync.toml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return validateConfigFile()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+func validateConfigFile() error {
+	configPath, err := config.GetConfigPath(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to get config path: %w", err)
+	}
+	problems, err := config.ValidateFile(configPath)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		fmt.Printf("✓ %s is valid\n", configPath)
+		return nil
+	}
+
+	fmt.Printf("🔍 %s\n", configPath)
+	errorCount, warningCount := printProblems(problems)
+	fmt.Printf("\n📊 %d errors, %d warnings\n", errorCount, warningCount)
+	if errorCount > 0 {
+		return fmt.Errorf("config has %d errors", errorCount)
+	}
+	return nil
+}
+
+// printProblems lists problems under their sections and counts them
+func printProblems(problems []config.Problem) (errorCount, warningCount int) {
+	section := ""
+	for _, problem := range problems {
+		if problem.Section != section {
+			section = problem.Section
+			fmt.Printf("\n%s:\n", section)
+		}
+		if problem.Warning {
+			warningCount++
+			fmt.Printf("  ⚠️  %s\n", problem.Message)
+		} else {
+			errorCount++
+			fmt.Printf("  ❌ %s\n", problem.Message)
+		}
+	}
+	return errorCount, warningCount
+}
@@ -0,0 +1,264 @@
+// Package apikey manages the keys that authenticate clients of the daemon's HTTP
+// API. Only a hash of each key is stored, in the git-sync state directory, and
+// every key carries the scopes it grants and an optional expiry.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bnema/git-sync/internal/filelock"
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+const (
+	// ScopeRead allows reading status, progress, retries and rate limits
+	ScopeRead = "read"
+	// ScopeTrigger allows starting syncs
+	ScopeTrigger = "trigger"
+	// ScopeReport allows fleet machines to report their syncs to an aggregator
+	ScopeReport = "report"
+
+	keysFileName = "apikeys.json"
+	tokenPrefix  = "gsk_"
+)
+
+// Scopes lists every scope a key can be granted
+var Scopes = []string{ScopeRead, ScopeTrigger, ScopeReport}
+
+var (
+	// ErrInvalidKey is returned for tokens that match no key, or an expired one
+	ErrInvalidKey = errors.New("invalid or expired API key")
+	// ErrMissingScope is returned when a valid key lacks the scope a request needs
+	ErrMissingScope = errors.New("API key lacks the required scope")
+)
+
+// Key is a stored API key; the token itself is only shown when it is created
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	Hash      string    `json:"hash"` // SHA-256 of the token
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // zero never expires
+}
+
+// Expired reports whether the key has expired
+func (k Key) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// HasScope reports whether the key grants scope
+func (k Key) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// ParseScopes validates a comma-separated scope list such as "read,trigger"
+func ParseScopes(list string) ([]string, error) {
+	var scopes []string
+	for _, scope := range strings.Split(list, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope '%s': must be one of %s", scope, strings.Join(Scopes, ", "))
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required (%s)", strings.Join(Scopes, ", "))
+	}
+	return scopes, nil
+}
+
+// Create generates a key with the given scopes, expiring after ttl unless ttl is
+// zero, and stores it. The returned token is not stored and cannot be shown again.
+func Create(name string, scopes []string, ttl time.Duration) (string, Key, error) {
+	id, err := randomHex(4)
+	if err != nil {
+		return "", Key{}, err
+	}
+	secret, err := randomHex(20)
+	if err != nil {
+		return "", Key{}, err
+	}
+	token := tokenPrefix + id + "_" + secret
+
+	now := time.Now()
+	key := Key{
+		ID:        id,
+		Name:      name,
+		Scopes:    scopes,
+		Hash:      hashToken(token),
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		key.ExpiresAt = now.Add(ttl)
+	}
+
+	err = update(func(keys []Key) ([]Key, bool) {
+		return append(keys, key), true
+	})
+	if err != nil {
+		return "", Key{}, err
+	}
+	return token, key, nil
+}
+
+// List returns the stored keys, oldest first; a missing file has none
+func List() ([]Key, error) {
+	path, err := filePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+
+	var keys []Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys %s: %w", path, err)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// Revoke deletes the key with the given ID, reporting whether it existed
+func Revoke(id string) (bool, error) {
+	found := false
+	err := update(func(keys []Key) ([]Key, bool) {
+		i := slices.IndexFunc(keys, func(k Key) bool { return k.ID == id })
+		if i < 0 {
+			return keys, false
+		}
+		found = true
+		return slices.Delete(keys, i, i+1), true
+	})
+	return found, err
+}
+
+// Authenticate returns the key token belongs to if it is valid and grants scope.
+// Keys are read on every call, so created and revoked keys apply immediately.
+func Authenticate(token, scope string) (Key, error) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(token, tokenPrefix), "_")
+	if !ok || !strings.HasPrefix(token, tokenPrefix) {
+		return Key{}, ErrInvalidKey
+	}
+
+	keys, err := List()
+	if err != nil {
+		return Key{}, err
+	}
+	hash := hashToken(token)
+	for _, key := range keys {
+		if key.ID != id || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		if key.Expired(time.Now()) {
+			return Key{}, ErrInvalidKey
+		}
+		if !key.HasScope(scope) {
+			return key, ErrMissingScope
+		}
+		return key, nil
+	}
+	return Key{}, ErrInvalidKey
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func filePath() (string, error) {
+	dir, err := paths.StateDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve state directory: %w", err)
+	}
+	return filepath.Join(dir, keysFileName), nil
+}
+
+// update applies fn to the stored keys and saves the result when fn reports a
+// change. Updates hold an exclusive lock so overlapping runs never drop each
+// other's keys.
+func update(fn func([]Key) ([]Key, bool)) error {
+	path, err := filePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open API keys lock: %w", err)
+	}
+	defer lock.Close()
+
+	if err := filelock.Lock(lock); err != nil {
+		return fmt.Errorf("failed to lock API keys: %w", err)
+	}
+	defer func() { _ = filelock.Unlock(lock) }()
+
+	keys, err := List()
+	if err != nil {
+		return err
+	}
+	keys, changed := fn(keys)
+	if !changed {
+		return nil
+	}
+	return save(keys)
+}
+
+// save writes the keys atomically, readable by the owner only
+func save(keys []Key) error {
+	path, err := filePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode API keys: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write API keys: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace API keys: %w", err)
+	}
+	return nil
+}
@@ -1,37 +1,203 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/viper"
+
+	"github.com/bnema/git-sync/internal/cron"
+	"github.com/bnema/git-sync/internal/logging"
+	"github.com/bnema/git-sync/internal/paths"
+	"github.com/bnema/git-sync/internal/secretref"
+	"github.com/bnema/git-sync/internal/secrets"
 )
 
 type Config struct {
-	Global       GlobalConfig `toml:"global"`
-	Repositories []RepoConfig `toml:"repositories"`
+	// Version of the config layout, for migrating files written by older releases
+	ConfigVersion int `toml:"config_version,omitempty"`
+
+	// Files merged over this one, relative to its directory; globs match optional files
+	Include      []string        `toml:"include,omitempty"`
+	Global       GlobalConfig    `toml:"global"`
+	Repositories []RepoConfig    `toml:"repositories"`
+	SyncSets     []SyncSetConfig `toml:"sync_sets,omitempty"`
+	Webhooks     []WebhookConfig `toml:"webhooks,omitempty"`
+
+	// Where notifications go besides the desktop, e.g. for headless servers
+	Notifiers []NotifierConfig `toml:"notifiers,omitempty"`
+
+	// Sections applied on the machines they select, by hostname or --profile
+	Profiles map[string]HostProfile `toml:"profiles,omitempty"`
+
+	// Repository settings shared by the repositories naming the group, by key
+	Groups map[string]map[string]any `toml:"groups,omitempty"`
+
+	sources     *configSources    // what the includes and drop-ins set, for saving
+	hostProfile *appliedProfile   // the [profiles] section applied, undone when saving
+	preset      *appliedPreset    // the low-power adjustments, undone when saving
+	unexpanded  map[string]string // paths as written, by what ~ and variables expanded to
+}
+
+// SyncSetConfig groups repositories that sync together in order; a member that
+// fails or is skipped stops the set, so later members are never synced ahead of it
+type SyncSetConfig struct {
+	Name         string   `toml:"name"`
+	Repositories []string `toml:"repositories"`       // member paths, synced in this order
+	Interval     int      `toml:"interval,omitempty"` // seconds; defaults to the global default_interval
+	Schedule     string   `toml:"schedule,omitempty"` // cron expression; replaces interval when set
+
+	Source string `toml:"-"` // included file or drop-in defining the set; empty for the main config
+}
+
+// WebhookConfig is an outgoing webhook fired after the syncs it selects, for
+// chat bots, automation flows or custom endpoints
+type WebhookConfig struct {
+	URL         string   `toml:"url"`
+	Template    string   `toml:"template,omitempty"`     // Go text/template of the body; empty sends the JSON payload
+	ContentType string   `toml:"content_type,omitempty"` // defaults to application/json
+	Statuses    []string `toml:"statuses,omitempty"`     // success, noop, partial, failed, skipped; empty is all but skipped
+	Repos       []string `toml:"repos,omitempty"`        // path or name globs; empty is every repository
+	Secret      string   `toml:"secret,omitempty"`       // signs bodies with HMAC-SHA256
+	Timeout     int      `toml:"timeout,omitempty"`      // seconds per attempt (default 10)
+	Attempts    int      `toml:"attempts,omitempty"`     // tries before a delivery is dropped (default 3)
+
+	Source string `toml:"-"` // included file or drop-in defining the webhook; empty for the main config
+}
+
+// NotifierConfig is a notification backend notifications are sent to besides the desktop
+type NotifierConfig struct {
+	Type        string `toml:"type"`                   // smtp, slack, discord, telegram or ntfy
+	MinSeverity string `toml:"min_severity,omitempty"` // info, warning or critical; empty is info, everything
+
+	// Slack and Discord incoming webhook, ntfy topic (e.g. https://ntfy.sh/my-topic), or
+	// a self-hosted Telegram Bot API server
+	URL string `toml:"url,omitempty"`
+
+	Token  string `toml:"token,omitempty"`   // Telegram bot token or ntfy access token
+	ChatID string `toml:"chat_id,omitempty"` // Telegram chat the bot writes to
+
+	SMTPHost string   `toml:"smtp_host,omitempty"`
+	SMTPPort int      `toml:"smtp_port,omitempty"` // default 587 with STARTTLS; 465 uses TLS from the start
+	Username string   `toml:"username,omitempty"`
+	Password string   `toml:"password,omitempty"`
+	From     string   `toml:"from,omitempty"`
+	To       []string `toml:"to,omitempty"`
+
+	Source string `toml:"-"` // included file or drop-in defining the notifier; empty for the main config
 }
 
 type GlobalConfig struct {
+	// Preset adjusting the settings below: default, or low-power for routers and SBCs
+	Profile string `toml:"profile"`
+
 	LogLevel           string `toml:"log_level"`
 	DefaultInterval    int    `toml:"default_interval"`
 	MaxConcurrentSyncs int    `toml:"max_concurrent_syncs"`
+
+	// Budgets of the io-heavy and light concurrency classes, next to the
+	// max_concurrent_syncs of normal repositories
+	MaxIOHeavySyncs int `toml:"max_io_heavy_syncs"`
+	MaxLightSyncs   int `toml:"max_light_syncs"`
+
+	// Syncs talking to one remote host at once, and the seconds between their
+	// starts; 0 (default) leaves them unlimited
+	MaxSyncsPerHost int `toml:"max_syncs_per_host,omitempty"`
+	HostSyncDelay   int `toml:"host_sync_delay,omitempty"`
+
+	// Load spreading for many repositories on the same interval
+	StartupStagger int `toml:"startup_stagger"` // seconds the first syncs are spread over
+	SyncJitter     int `toml:"sync_jitter"`     // percent each interval varies by, 0-50
+
+	// Retries of syncs failing transiently (network, DNS, server errors), with exponential backoff
+	RetryMaxAttempts int `toml:"retry_max_attempts"` // 0 disables retries
+	RetryBackoff     int `toml:"retry_backoff"`      // seconds before the first retry
+	RetryMaxBackoff  int `toml:"retry_max_backoff"`  // seconds the backoff grows to at most
+
+	// Circuit breaker: repositories failing this many syncs in a row pause for the cooldown
+	CircuitBreakerFailures int `toml:"circuit_breaker_failures"` // 0 disables the breaker
+	CircuitBreakerCooldown int `toml:"circuit_breaker_cooldown"` // seconds
+
+	// Hold syncs back while offline, probing offline_probe (host:port) or asking NetworkManager
+	PauseWhenOffline bool   `toml:"pause_when_offline"`
+	OfflineProbe     string `toml:"offline_probe"`
+
+	// Laptops on battery: pause syncs, or stretch intervals by the multiplier
+	PauseOnBattery            bool    `toml:"pause_on_battery"`
+	BatteryIntervalMultiplier float64 `toml:"battery_interval_multiplier"` // 1 keeps intervals
+
+	// Sync everything shortly after NetworkManager or iwd reports the machine connected
+	SyncOnNetworkChange bool `toml:"sync_on_network_change"`
+
+	// Log deduplication: identical messages beyond the burst are dropped for the window
+	LogDedupBurst  int `toml:"log_dedup_burst"`
+	LogDedupWindow int `toml:"log_dedup_window"` // seconds
+
+	// Log output: text or json lines, also written to log_file when set, which is
+	// rotated once it grows past log_file_max_size_mb
+	LogFormat        string `toml:"log_format"`
+	LogFile          string `toml:"log_file"`
+	LogFileMaxSizeMB int    `toml:"log_file_max_size_mb"`
 	
 	// History configuration
 	HistoryMaxEntries    int    `toml:"history_max_entries"`
 	HistoryRetentionDays int    `toml:"history_retention_days"`
 	HistoryCacheDir      string `toml:"history_cache_dir"`
 	HistoryMaxFileSizeMB int    `toml:"history_max_file_size_mb"`
+	// Where history is stored: jsonl, or sqlite for indexed queries on large histories
+	HistoryBackend string `toml:"history_backend"`
 	
 	// Notification configuration
 	EnableNotifications bool `toml:"enable_notifications"`
 	NotificationTimeout int  `toml:"notification_timeout"`
+	// Which sync results are shown: all, failures-only, transitions or digest
+	NotificationPolicy string `toml:"notification_policy"`
+
+	// Transport defaults for all repositories (proxy URL and extra CA bundle path)
+	Proxy    string `toml:"proxy"`
+	CABundle string `toml:"ca_bundle"`
+
+	// Metrics snapshot for node_exporter's textfile collector; disabled when empty
+	MetricsFile          string `toml:"metrics_file"`
+	MetricsFormat        string `toml:"metrics_format"`         // prometheus, json
+	MetricsWriteInterval int    `toml:"metrics_write_interval"` // seconds
+
+	// Prometheus scrape endpoint serving the same metrics at /metrics; disabled when empty
+	MetricsListen string `toml:"metrics_listen"` // host:port
+
+	// HTTP API for dashboards and automation, authenticated with API keys; disabled when empty
+	APIListen string `toml:"api_listen"` // host:port
+
+	// Secret GitHub, GitLab and Gitea sign the push webhooks they send to
+	// /webhooks/push on api_listen with; repositories may use their own
+	IncomingWebhookSecret string `toml:"incoming_webhook_secret"`
+
+	// Fleet aggregator (the api_listen URL of another daemon) this machine reports its syncs to
+	ReportTo    string `toml:"report_to"`
+	ReportToken string `toml:"report_token"` // API key with the report scope on the aggregator
+	MachineID   string `toml:"machine_id"`   // name in the fleet; defaults to the hostname
+
+	// Git implementation used for syncs: go-git (built in) or git (the git binary)
+	GitBackend string `toml:"git_backend"`
+
+	// Gitleaks-compatible rules for repositories with secret_scan; empty uses the built-in rules
+	SecretScanRules string `toml:"secret_scan_rules"`
+
+	// Artificial sync failures and delays for testing (see GIT_SYNC_FAULTS); empty disables
+	FaultInjection string `toml:"fault_injection"`
 }
 
 type RepoConfig struct {
@@ -44,6 +210,228 @@ type RepoConfig struct {
 	TargetBranch   string `toml:"target_branch,omitempty"`
 	SafetyChecks   bool   `toml:"safety_checks"`
 	ForcePush      bool   `toml:"force_push"`
+
+	// Branch synced when HEAD is detached; detached repos are skipped when empty
+	DetachedHeadBranch string `toml:"detached_head_branch,omitempty"`
+
+	// What to do when safety checks find uncommitted changes: skip, stash or commit
+	DirtyWorktreeAction string `toml:"dirty_worktree_action,omitempty"`
+
+	// Path patterns merged with git's union driver when pulls diverge (e.g. "*.md")
+	UnionMergePatterns []string `toml:"union_merge_patterns,omitempty"`
+
+	// Sync even when the repository is owned by another user (see git's safe.directory)
+	AllowForeignOwner bool `toml:"allow_foreign_owner,omitempty"`
+
+	// Transport overrides; empty values use the global proxy and ca_bundle
+	Proxy    string `toml:"proxy,omitempty"`     // http://, https:// or socks5:// URL
+	CABundle string `toml:"ca_bundle,omitempty"` // PEM file trusted in addition to system roots
+
+	// Credentials for the remote, instead of credential helpers and ssh-agent;
+	// http_token and ssh_key_passphrase are best ${secret:...} references
+	HTTPUsername     string `toml:"http_username,omitempty"` // defaults to git
+	HTTPToken        string `toml:"http_token,omitempty"`
+	SSHKey           string `toml:"ssh_key,omitempty"` // private key file
+	SSHKeyPassphrase string `toml:"ssh_key_passphrase,omitempty"`
+
+	// Identity for commits the daemon creates (auto-commit, stash, merges); falls back to git config
+	AuthorName  string `toml:"author_name,omitempty"`
+	AuthorEmail string `toml:"author_email,omitempty"`
+
+	// Git implementation override for this repository; empty uses the global git_backend
+	GitBackend string `toml:"git_backend,omitempty"`
+
+	// Limit fetches to this many commits (0 fetches full history)
+	FetchDepth int `toml:"fetch_depth,omitempty"`
+
+	// Set to false to keep this repository's syncs out of history or desktop notifications
+	History       *bool `toml:"history,omitempty"`
+	Notifications *bool `toml:"notifications,omitempty"`
+
+	// Which syncs send desktop notifications: all (default), failures-only or never;
+	// replaces notifications when set
+	Notify string `toml:"notify,omitempty"`
+
+	// Log level of this repository's log lines (debug, info, warn or error); unset follows log_level
+	LogLevel string `toml:"log_level,omitempty"`
+
+	// Custom refspecs bypass the branch strategy entirely when set
+	PushRefSpecs  []string `toml:"push_refspecs,omitempty"`
+	FetchRefSpecs []string `toml:"fetch_refspecs,omitempty"`
+
+	// Cron expression for timed syncs (e.g. "*/15 9-18 * * 1-5"); replaces interval when set
+	Schedule string `toml:"schedule,omitempty"`
+
+	// Separate timing for the pulls and pushes of a "both" repository; unset
+	// directions follow interval and schedule
+	PullInterval int    `toml:"pull_interval,omitempty"`
+	PullSchedule string `toml:"pull_schedule,omitempty"`
+	PushInterval int    `toml:"push_interval,omitempty"`
+	PushSchedule string `toml:"push_schedule,omitempty"`
+
+	// What starts a sync: interval (default), fswatch (worktree changes) or both
+	Trigger         string `toml:"trigger,omitempty"`
+	FSWatchDebounce int    `toml:"fswatch_debounce,omitempty"` // seconds of quiet before a change syncs (default 5)
+
+	// Batching of change-triggered syncs, in seconds: changes sync no sooner than
+	// fswatch_min_gap after the last sync, and wait for quiet no longer than
+	// fswatch_max_delay; 0 disables either
+	FSWatchMinGap   int `toml:"fswatch_min_gap,omitempty"`
+	FSWatchMaxDelay int `toml:"fswatch_max_delay,omitempty"`
+
+	// Shell command run in the worktree after a sync pulls new commits into it
+	RunAfterPull string `toml:"run_after_pull,omitempty"`
+
+	// Run the repository's pre-commit and pre-push hooks (from core.hooksPath when
+	// set) before auto-commits and pushes with the go-git backend, which skips
+	// them otherwise; hook_timeout is in seconds (default 120)
+	RunHooks    bool `toml:"run_hooks,omitempty"`
+	HookTimeout int  `toml:"hook_timeout,omitempty"`
+
+	// Shell command run in the worktree after a successful sync changed it, e.g.
+	// rsync to a NAS; mirror_timeout is in seconds (default 600)
+	Mirror        string `toml:"mirror,omitempty"`
+	MirrorTimeout int    `toml:"mirror_timeout,omitempty"`
+
+	// http(s) URL the daemon POSTs a JSON summary of each sync to, e.g. to start
+	// a CI job or a site build; webhook_secret signs it with HMAC-SHA256
+	Webhook       string `toml:"webhook,omitempty"`
+	WebhookSecret string `toml:"webhook_secret,omitempty"`
+
+	// Secret for push webhooks from the Git host that trigger a pull; empty uses
+	// the global incoming_webhook_secret
+	IncomingWebhookSecret string `toml:"incoming_webhook_secret,omitempty"`
+
+	// Block pushes whose outgoing commits appear to contain credentials
+	SecretScan      bool   `toml:"secret_scan,omitempty"`
+	SecretScanRules string `toml:"secret_scan_rules,omitempty"` // overrides the global rules file
+
+	// Retry override for this repository; unset uses the global retry_max_attempts, 0 disables
+	RetryMaxAttempts *int `toml:"retry_max_attempts,omitempty"`
+
+	// Order among syncs waiting for a free slot: high, normal (default) or low
+	Priority string `toml:"priority,omitempty"`
+
+	// Concurrency budget the repository's syncs take their slot from: io-heavy
+	// (max_io_heavy_syncs), normal (default, max_concurrent_syncs) or light (max_light_syncs)
+	ConcurrencyClass string `toml:"concurrency_class,omitempty"`
+
+	// Seconds a sync may run once it has a slot before it is stopped, keeping
+	// the refs it updated but not a transfer it cut; 0 (default) never stops syncs
+	MaxRuntime int `toml:"max_runtime,omitempty"`
+
+	// [groups] section whose settings apply where the repository doesn't set them
+	Group string `toml:"group,omitempty"`
+
+	// Included file or drop-in defining the repository; empty for the main config
+	Source string `toml:"-"`
+
+	// Path glob the repository was expanded from by ExpandPatterns; empty when configured by path
+	Pattern string `toml:"-"`
+
+	inherited map[string]any // the settings taken from the group, kept out of the entry when saving
+	written   []string       // the settings the entry of a repository in a group sets itself
+}
+
+// Notification modes of a repository
+const (
+	NotifyAll          = "all"
+	NotifyFailuresOnly = "failures-only"
+	NotifyNever        = "never"
+)
+
+// Concurrency classes, each with its own budget of syncs running at once
+const (
+	ClassIOHeavy = "io-heavy"
+	ClassNormal  = "normal"
+	ClassLight   = "light"
+)
+
+// HistoryEnabled reports whether syncs of the repository are recorded in history
+func (r RepoConfig) HistoryEnabled() bool {
+	return r.History == nil || *r.History
+}
+
+// NotificationsEnabled reports whether syncs of the repository may send desktop notifications
+func (r RepoConfig) NotificationsEnabled() bool {
+	return r.NotifyMode() != NotifyNever
+}
+
+// NotifyMode returns which syncs of the repository send desktop notifications,
+// from notify or else notifications
+func (r RepoConfig) NotifyMode() string {
+	switch {
+	case r.Notify != "":
+		return r.Notify
+	case r.Notifications != nil && !*r.Notifications:
+		return NotifyNever
+	}
+	return NotifyAll
+}
+
+// NotifiesOf reports whether a sync of the repository ending with status sends a notification
+func (r RepoConfig) NotifiesOf(status string) bool {
+	switch r.NotifyMode() {
+	case NotifyNever:
+		return false
+	case NotifyFailuresOnly:
+		return status != "success" && status != "noop"
+	}
+	return true
+}
+
+// RetryAttempts returns how many times a transiently failing sync is retried
+func (r RepoConfig) RetryAttempts() int {
+	if r.RetryMaxAttempts == nil {
+		return 0
+	}
+	return *r.RetryMaxAttempts
+}
+
+// Class returns the concurrency class of the repository, normal when unset
+func (r RepoConfig) Class() string {
+	if r.ConcurrencyClass == "" {
+		return ClassNormal
+	}
+	return r.ConcurrencyClass
+}
+
+// PriorityRank orders syncs waiting for a slot; higher ranks go first
+func (r RepoConfig) PriorityRank() int {
+	switch r.Priority {
+	case "high":
+		return 2
+	case "low":
+		return 0
+	default:
+		return 1
+	}
+}
+
+// SplitsDirections reports whether pulls and pushes of the repository sync on their own timing
+func (r RepoConfig) SplitsDirections() bool {
+	return r.PullInterval > 0 || r.PushInterval > 0 || r.PullSchedule != "" || r.PushSchedule != ""
+}
+
+// DirectionTiming returns the interval and cron schedule direction (pull or
+// push) syncs on; a schedule replaces the interval when set
+func (r RepoConfig) DirectionTiming(direction string) (int, string) {
+	interval, schedule := r.PullInterval, r.PullSchedule
+	if direction == "push" {
+		interval, schedule = r.PushInterval, r.PushSchedule
+	}
+	if interval == 0 && schedule == "" {
+		return r.Interval, r.Schedule
+	}
+	if interval == 0 {
+		interval = r.Interval
+	}
+	return interval, schedule
+}
+
+// WatchesFiles reports whether worktree changes trigger syncs of the repository
+func (r RepoConfig) WatchesFiles() bool {
+	return r.Trigger == "fswatch" || r.Trigger == "both"
 }
 
 // ConfigWatcher handles live configuration file watching
@@ -51,28 +439,25 @@ type ConfigWatcher struct {
 	viper         *viper.Viper
 	configPath    string
 	onChange      func(*Config) error
+	onError       func(error)
 	logger        *slog.Logger
 	currentConfig *Config
 	mu            sync.RWMutex
 	lastChange    time.Time
 	debounceDelay time.Duration
+
+	includeWatcher *fsnotify.Watcher // directories of the includes and drop-ins
 }
 
 func LoadConfig(configPath string) (*Config, error) {
-	v := viper.New()
-	
 	var err error
 	configPath, err = GetConfigPath(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get config path: %w", err)
 	}
 
-	// Configure Viper
-	v.SetConfigFile(configPath)
-	v.SetConfigType("toml")
-	
-	// Set all defaults - Viper uses these only if keys don't exist in config
-	setAllDefaults(v)
+	// Configure Viper with all defaults - Viper uses these only if keys don't exist in config
+	v := newViper(configPath)
 
 	// Read existing config if it exists
 	configExists := false
@@ -80,21 +465,43 @@ func LoadConfig(configPath string) (*Config, error) {
 		if err := v.ReadInConfig(); err != nil {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
+		if err := migrateFile(v, configPath); err != nil {
+			return nil, err
+		}
 		configExists = true
 	} else if !os.IsNotExist(err) {
 		return nil, fmt.Errorf("failed to check config file: %w", err)
 	}
 
-	// Unmarshal into our config struct
-	var config Config
-	if err := v.Unmarshal(&config); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	// Unmarshal into our config struct, with the includes and drop-ins merged
+	config, err := readConfig(v, configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateGlobalOptions(config.Global); err != nil {
+		return nil, err
+	}
+	for i, repo := range config.Repositories {
+		if err := validateRepoOptions(repo); err != nil {
+			return nil, fmt.Errorf("repository %d (%s): %w", i, repo.Path, err)
+		}
+	}
+	if err := validateSyncSets(config); err != nil {
+		return nil, err
+	}
+	if err := validateWebhooks(config.Webhooks); err != nil {
+		return nil, err
+	}
+	if err := validateNotifiers(config.Notifiers); err != nil {
+		return nil, err
 	}
+	applyProfile(config)
 
 	// If config file exists, write it back to ensure all new defaults are included
-	// This is idempotent - WriteConfig only updates if there are changes
+	// This is idempotent - writeSettings only updates if there are changes
 	if configExists {
-		if err := v.WriteConfig(); err != nil {
+		if err := writeSettings(configPath, v.AllSettings()); err != nil {
 			// If write fails, it's not critical - the config is still loaded correctly
 			// This just means the file won't get the new defaults written to disk
 		}
@@ -105,7 +512,581 @@ func LoadConfig(configPath string) (*Config, error) {
 		}
 	}
 
-	return &config, nil
+	return config, nil
+}
+
+// newViper returns a viper reading the config file at configPath over the defaults,
+// as YAML or JSON when its extension says so and as TOML otherwise
+func newViper(configPath string) *viper.Viper {
+	v := viper.New()
+	v.SetConfigFile(configPath)
+	v.SetConfigType(FileFormat(configPath))
+	setAllDefaults(v)
+	return v
+}
+
+// FileFormat is the format of the config file at configPath, TOML unless its extension names another
+func FileFormat(configPath string) string {
+	if format := FormatOf(configPath); format != "" {
+		return format
+	}
+	return "toml"
+}
+
+// unmarshalConfig decodes the viper settings into config using the toml struct tags,
+// so snake_case keys like log_level map onto their fields
+func unmarshalConfig(v *viper.Viper, config *Config) error {
+	// Paths are decoded with ~ and environment variables expanded, leaving v as written
+	settings := cloneSettings(v.AllSettings()).(map[string]any)
+	unexpanded := expandPaths(settings)
+	expanded := viper.New()
+	if err := expanded.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	if err := expanded.Unmarshal(config, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "toml"
+	}); err != nil {
+		return err
+	}
+	config.unexpanded = unexpanded
+
+	// Hand-edited paths may carry trailing slashes or decomposed unicode
+	for i := range config.Repositories {
+		config.Repositories[i].Path = paths.NormalizeRepo(config.Repositories[i].Path)
+	}
+	for i := range config.SyncSets {
+		for j, path := range config.SyncSets[i].Repositories {
+			config.SyncSets[i].Repositories[j] = paths.NormalizeRepo(path)
+		}
+	}
+	return nil
+}
+
+// FindRepository returns the index of the repository configured at path, matching
+// the way the filesystem does: regardless of trailing slashes and unicode
+// normalization, and of letter case where the filesystem ignores it
+func (c *Config) FindRepository(path string) (int, bool) {
+	for i, repo := range c.Repositories {
+		if paths.NormalizeRepo(repo.Path) == paths.NormalizeRepo(path) {
+			return i, true
+		}
+	}
+	for i, repo := range c.Repositories {
+		if paths.SameRepo(repo.Path, path) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// WithGlobalDefaults fills unset per-repository interval, transport, git backend and secret scan settings from the global config
+func (g GlobalConfig) WithGlobalDefaults(repo RepoConfig) RepoConfig {
+	if repo.Interval <= 0 {
+		repo.Interval = g.DefaultInterval
+	}
+	if repo.Proxy == "" {
+		repo.Proxy = g.Proxy
+	}
+	if repo.CABundle == "" {
+		repo.CABundle = g.CABundle
+	}
+	if repo.GitBackend == "" {
+		repo.GitBackend = g.GitBackend
+	}
+	if repo.SecretScanRules == "" {
+		repo.SecretScanRules = g.SecretScanRules
+	}
+	if repo.RetryMaxAttempts == nil {
+		attempts := g.RetryMaxAttempts
+		repo.RetryMaxAttempts = &attempts
+	}
+	return repo
+}
+
+// validateCredentials checks the remote credentials of a repository go together
+// and that the secrets they reference are well-formed
+func validateCredentials(repo RepoConfig) error {
+	if repo.HTTPToken != "" && repo.SSHKey != "" {
+		return fmt.Errorf("http_token and ssh_key can't both be set")
+	}
+	if repo.HTTPUsername != "" && repo.HTTPToken == "" {
+		return fmt.Errorf("http_username requires http_token")
+	}
+	if repo.SSHKeyPassphrase != "" && repo.SSHKey == "" {
+		return fmt.Errorf("ssh_key_passphrase requires ssh_key")
+	}
+	references := []struct{ key, value string }{
+		{"http_token", repo.HTTPToken},
+		{"ssh_key_passphrase", repo.SSHKeyPassphrase},
+		{"webhook_secret", repo.WebhookSecret},
+		{"incoming_webhook_secret", repo.IncomingWebhookSecret},
+	}
+	for _, secret := range references {
+		if err := secretref.Validate(secret.value); err != nil {
+			return fmt.Errorf("%s: %w", secret.key, err)
+		}
+	}
+	return nil
+}
+
+// validateProxyURL checks that a proxy URL uses a scheme go-git transports support
+func validateProxyURL(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	parsed, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Errorf("invalid proxy '%s': %w", proxy, err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	default:
+		return fmt.Errorf("invalid proxy '%s': scheme must be http, https, or socks5", proxy)
+	}
+}
+
+// validateGlobalOptions validates optional global settings at load time
+func validateGlobalOptions(global GlobalConfig) error {
+	if err := validateProfile(global.Profile); err != nil {
+		return err
+	}
+	if err := validateProxyURL(global.Proxy); err != nil {
+		return err
+	}
+	if err := secretref.Validate(global.ReportToken); err != nil {
+		return fmt.Errorf("report_token: %w", err)
+	}
+	if err := secretref.Validate(global.IncomingWebhookSecret); err != nil {
+		return fmt.Errorf("incoming_webhook_secret: %w", err)
+	}
+	switch global.HistoryBackend {
+	case "", "jsonl", "sqlite":
+	default:
+		return fmt.Errorf("invalid history_backend '%s': must be jsonl or sqlite", global.HistoryBackend)
+	}
+	switch global.NotificationPolicy {
+	case "", "all", "failures-only", "transitions", "digest":
+	default:
+		return fmt.Errorf("invalid notification_policy '%s': must be all, failures-only, transitions or digest", global.NotificationPolicy)
+	}
+	switch global.MetricsFormat {
+	case "", "prometheus", "json":
+	default:
+		return fmt.Errorf("invalid metrics_format '%s': must be prometheus or json", global.MetricsFormat)
+	}
+	switch global.LogFormat {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("invalid log_format '%s': must be text or json", global.LogFormat)
+	}
+	if global.LogFile != "" && !filepath.IsAbs(global.LogFile) {
+		return fmt.Errorf("invalid log_file '%s': must be an absolute path", global.LogFile)
+	}
+	if global.LogFileMaxSizeMB < 0 {
+		return fmt.Errorf("log_file_max_size_mb cannot be negative")
+	}
+	if global.StartupStagger < 0 {
+		return fmt.Errorf("startup_stagger cannot be negative")
+	}
+	if global.SyncJitter < 0 || global.SyncJitter > 50 {
+		return fmt.Errorf("invalid sync_jitter %d: must be 0-50", global.SyncJitter)
+	}
+	if global.RetryMaxAttempts < 0 {
+		return fmt.Errorf("retry_max_attempts cannot be negative")
+	}
+	if global.RetryBackoff <= 0 || global.RetryMaxBackoff < global.RetryBackoff {
+		return fmt.Errorf("retry_backoff must be positive and retry_max_backoff at least retry_backoff")
+	}
+	if global.CircuitBreakerFailures < 0 {
+		return fmt.Errorf("circuit_breaker_failures cannot be negative")
+	}
+	if global.CircuitBreakerFailures > 0 && global.CircuitBreakerCooldown <= 0 {
+		return fmt.Errorf("circuit_breaker_cooldown must be positive")
+	}
+	if global.OfflineProbe != "" {
+		if _, _, err := net.SplitHostPort(global.OfflineProbe); err != nil {
+			return fmt.Errorf("invalid offline_probe '%s': must be host:port", global.OfflineProbe)
+		}
+	}
+	if global.BatteryIntervalMultiplier < 1 {
+		return fmt.Errorf("invalid battery_interval_multiplier %g: must be at least 1", global.BatteryIntervalMultiplier)
+	}
+	if global.APIListen != "" {
+		if _, _, err := net.SplitHostPort(global.APIListen); err != nil {
+			return fmt.Errorf("invalid api_listen '%s': must be host:port", global.APIListen)
+		}
+	}
+	if global.MetricsListen != "" {
+		if _, _, err := net.SplitHostPort(global.MetricsListen); err != nil {
+			return fmt.Errorf("invalid metrics_listen '%s': must be host:port", global.MetricsListen)
+		}
+		if global.MetricsListen == global.APIListen {
+			return fmt.Errorf("metrics_listen and api_listen cannot share the address '%s'", global.MetricsListen)
+		}
+	}
+	if global.ReportTo != "" {
+		parsed, err := url.Parse(global.ReportTo)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid report_to '%s': must be an http:// or https:// URL", global.ReportTo)
+		}
+		if global.ReportToken == "" {
+			return fmt.Errorf("report_to requires report_token, an API key with the report scope on the aggregator")
+		}
+	}
+	if global.MetricsFile != "" && global.MetricsWriteInterval <= 0 {
+		return fmt.Errorf("metrics_write_interval must be positive")
+	}
+	if err := validateSecretScanRules(global.SecretScanRules); err != nil {
+		return err
+	}
+	return validateGitBackend(global.GitBackend)
+}
+
+// validateGlobalLimits checks the global settings scheduling can't do without
+func validateGlobalLimits(global GlobalConfig) error {
+	if global.DefaultInterval <= 0 {
+		return fmt.Errorf("default_interval must be positive")
+	}
+	if global.MaxConcurrentSyncs <= 0 {
+		return fmt.Errorf("max_concurrent_syncs must be positive")
+	}
+	if global.MaxIOHeavySyncs <= 0 || global.MaxLightSyncs <= 0 {
+		return fmt.Errorf("max_io_heavy_syncs and max_light_syncs must be positive")
+	}
+	if global.MaxSyncsPerHost < 0 || global.HostSyncDelay < 0 {
+		return fmt.Errorf("max_syncs_per_host and host_sync_delay cannot be negative")
+	}
+	return nil
+}
+
+// validateRepoBasics checks the settings every repository needs
+func validateRepoBasics(repo RepoConfig) error {
+	if repo.Path == "" {
+		return fmt.Errorf("path cannot be empty")
+	}
+	if repo.Interval < 0 {
+		return fmt.Errorf("interval cannot be negative")
+	}
+	if IsPattern(repo.Path) {
+		if err := validatePattern(repo.Path); err != nil {
+			return err
+		}
+	}
+	switch repo.Direction {
+	case "push", "pull", "both":
+	default:
+		return fmt.Errorf("invalid direction '%s': must be push, pull, or both", repo.Direction)
+	}
+	return nil
+}
+
+// validateRepoOptions validates optional per-repository settings at load time
+func validateRepoOptions(repo RepoConfig) error {
+	switch repo.DirtyWorktreeAction {
+	case "", "skip", "stash", "commit":
+	default:
+		return fmt.Errorf("invalid dirty_worktree_action '%s': must be skip, stash, or commit", repo.DirtyWorktreeAction)
+	}
+	if repo.RetryAttempts() < 0 {
+		return fmt.Errorf("retry_max_attempts cannot be negative")
+	}
+	if err := validateProxyURL(repo.Proxy); err != nil {
+		return err
+	}
+	if err := validateCredentials(repo); err != nil {
+		return err
+	}
+	if repo.FetchDepth < 0 {
+		return fmt.Errorf("invalid fetch_depth %d: must be 0 or positive", repo.FetchDepth)
+	}
+	if err := validateGitBackend(repo.GitBackend); err != nil {
+		return err
+	}
+	if repo.Schedule != "" {
+		if _, err := cron.Parse(repo.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule: %w", err)
+		}
+	}
+	switch repo.Trigger {
+	case "", "interval", "fswatch", "both":
+	default:
+		return fmt.Errorf("invalid trigger '%s': must be interval, fswatch, or both", repo.Trigger)
+	}
+	if err := validateDirectionTiming(repo); err != nil {
+		return err
+	}
+	if repo.FSWatchDebounce < 0 {
+		return fmt.Errorf("invalid fswatch_debounce %d: must be 0 or positive", repo.FSWatchDebounce)
+	}
+	if repo.LogLevel != "" {
+		if _, err := logging.ParseLevel(repo.LogLevel); err != nil {
+			return err
+		}
+	}
+	if repo.MirrorTimeout < 0 {
+		return fmt.Errorf("mirror_timeout cannot be negative")
+	}
+	if repo.HookTimeout < 0 {
+		return fmt.Errorf("hook_timeout cannot be negative")
+	}
+	if repo.Webhook != "" {
+		parsed, err := url.Parse(repo.Webhook)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid webhook '%s': must be an http:// or https:// URL", repo.Webhook)
+		}
+	} else if repo.WebhookSecret != "" {
+		return fmt.Errorf("webhook_secret requires webhook")
+	}
+	if repo.FSWatchMinGap < 0 || repo.FSWatchMaxDelay < 0 {
+		return fmt.Errorf("fswatch_min_gap and fswatch_max_delay cannot be negative")
+	}
+	switch repo.Notify {
+	case "", NotifyAll, NotifyFailuresOnly, NotifyNever:
+	default:
+		return fmt.Errorf("invalid notify '%s': must be all, failures-only, or never", repo.Notify)
+	}
+	switch repo.Priority {
+	case "", "high", "normal", "low":
+	default:
+		return fmt.Errorf("invalid priority '%s': must be high, normal, or low", repo.Priority)
+	}
+	switch repo.ConcurrencyClass {
+	case "", ClassIOHeavy, ClassNormal, ClassLight:
+	default:
+		return fmt.Errorf("invalid concurrency_class '%s': must be io-heavy, normal, or light", repo.ConcurrencyClass)
+	}
+	if repo.MaxRuntime < 0 {
+		return fmt.Errorf("max_runtime cannot be negative")
+	}
+	if err := validateSecretScanRules(repo.SecretScanRules); err != nil {
+		return err
+	}
+	return validateRefSpecs(repo)
+}
+
+// validateDirectionTiming checks the pull_* and push_* timing options
+func validateDirectionTiming(repo RepoConfig) error {
+	if repo.PullInterval < 0 || repo.PushInterval < 0 {
+		return fmt.Errorf("pull_interval and push_interval cannot be negative")
+	}
+	for name, schedule := range map[string]string{"pull_schedule": repo.PullSchedule, "push_schedule": repo.PushSchedule} {
+		if schedule == "" {
+			continue
+		}
+		if _, err := cron.Parse(schedule); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	if !repo.SplitsDirections() {
+		return nil
+	}
+	if repo.Direction != "both" {
+		return fmt.Errorf("pull_* and push_* timing needs direction 'both', not '%s'", repo.Direction)
+	}
+	if repo.WatchesFiles() {
+		return fmt.Errorf("pull_* and push_* timing can't be combined with trigger '%s'", repo.Trigger)
+	}
+	return nil
+}
+
+// validateSyncSets checks that sync sets are named uniquely and group configured
+// repositories, each belonging to at most one set. Member paths are rewritten to
+// the configured repository paths they match.
+func validateSyncSets(config *Config) error {
+	names := make(map[string]bool)
+	owner := make(map[string]string)
+	for i, set := range config.SyncSets {
+		if set.Name == "" {
+			return fmt.Errorf("sync set %d: name cannot be empty", i)
+		}
+		if names[set.Name] {
+			return fmt.Errorf("sync set '%s': name is used more than once", set.Name)
+		}
+		names[set.Name] = true
+
+		if len(set.Repositories) < 2 {
+			return fmt.Errorf("sync set '%s': needs at least two repositories", set.Name)
+		}
+		for j, path := range set.Repositories {
+			index, configured := config.FindRepository(path)
+			if !configured {
+				return fmt.Errorf("sync set '%s': repository %s is not configured", set.Name, path)
+			}
+			if config.Repositories[index].SplitsDirections() {
+				return fmt.Errorf("sync set '%s': repository %s pulls and pushes on separate timing, which sync sets don't support", set.Name, path)
+			}
+			path = config.Repositories[index].Path
+			set.Repositories[j] = path
+			if other, exists := owner[path]; exists {
+				return fmt.Errorf("sync set '%s': repository %s already belongs to sync set '%s'", set.Name, path, other)
+			}
+			owner[path] = set.Name
+		}
+
+		if set.Interval < 0 {
+			return fmt.Errorf("sync set '%s': interval cannot be negative", set.Name)
+		}
+		if set.Schedule != "" {
+			if _, err := cron.Parse(set.Schedule); err != nil {
+				return fmt.Errorf("sync set '%s': invalid schedule: %w", set.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SyncSetOf returns the sync set repoPath belongs to, if any
+func (c *Config) SyncSetOf(repoPath string) (SyncSetConfig, bool) {
+	for _, set := range c.SyncSets {
+		for _, path := range set.Repositories {
+			if paths.SameRepo(path, repoPath) {
+				return set, true
+			}
+		}
+	}
+	return SyncSetConfig{}, false
+}
+
+// validateWebhooks checks the URL, filters and template of each outgoing webhook
+func validateWebhooks(webhooks []WebhookConfig) error {
+	for i, webhook := range webhooks {
+		parsed, err := url.Parse(webhook.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("webhook %d: invalid url '%s': must be an http:// or https:// URL", i, webhook.URL)
+		}
+		if err := secretref.Validate(webhook.Secret); err != nil {
+			return fmt.Errorf("webhook %d: secret: %w", i, err)
+		}
+		for _, status := range webhook.Statuses {
+			switch status {
+			case "success", "noop", "partial", "failed", "skipped":
+			default:
+				return fmt.Errorf("webhook %d: invalid status '%s': must be success, noop, partial, failed, or skipped", i, status)
+			}
+		}
+		for _, pattern := range webhook.Repos {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("webhook %d: invalid repos pattern '%s': %w", i, pattern, err)
+			}
+		}
+		if webhook.Template != "" {
+			if _, err := template.New("webhook").Funcs(WebhookTemplateFuncs).Parse(webhook.Template); err != nil {
+				return fmt.Errorf("webhook %d: invalid template: %w", i, err)
+			}
+		}
+		if webhook.Timeout < 0 || webhook.Attempts < 0 {
+			return fmt.Errorf("webhook %d: timeout and attempts cannot be negative", i)
+		}
+	}
+	return nil
+}
+
+// validateNotifiers checks each notification backend has what its type needs
+func validateNotifiers(notifiers []NotifierConfig) error {
+	for i, notifier := range notifiers {
+		switch notifier.MinSeverity {
+		case "", "info", "warning", "critical":
+		default:
+			return fmt.Errorf("notifier %d: invalid min_severity '%s': must be info, warning or critical", i, notifier.MinSeverity)
+		}
+		// A url holding a ${secret:...} reference is checked once looked up, when sending
+		hiddenURL := secretref.HasReference(notifier.URL)
+		if notifier.URL != "" && !hiddenURL {
+			parsed, err := url.Parse(notifier.URL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("notifier %d: invalid url '%s': must be an http:// or https:// URL", i, notifier.URL)
+			}
+		}
+		var missing string
+		switch notifier.Type {
+		case "slack", "discord":
+			if notifier.URL == "" {
+				missing = "url"
+			}
+		case "ntfy":
+			if parsed, err := url.Parse(notifier.URL); notifier.URL == "" || (!hiddenURL && (err != nil || strings.Trim(parsed.Path, "/") == "")) {
+				return fmt.Errorf("notifier %d: ntfy needs the url of its topic, e.g. https://ntfy.sh/my-topic", i)
+			}
+		case "telegram":
+			if notifier.Token == "" {
+				missing = "token"
+			} else if notifier.ChatID == "" {
+				missing = "chat_id"
+			}
+		case "smtp":
+			switch {
+			case notifier.SMTPHost == "":
+				missing = "smtp_host"
+			case notifier.From == "":
+				missing = "from"
+			case len(notifier.To) == 0:
+				missing = "to"
+			}
+			if notifier.SMTPPort < 0 || notifier.SMTPPort > 65535 {
+				return fmt.Errorf("notifier %d: invalid smtp_port %d", i, notifier.SMTPPort)
+			}
+			if notifier.Password != "" && notifier.Username == "" {
+				return fmt.Errorf("notifier %d: password requires username", i)
+			}
+		default:
+			return fmt.Errorf("notifier %d: invalid type '%s': must be smtp, slack, discord, telegram or ntfy", i, notifier.Type)
+		}
+		if missing != "" {
+			return fmt.Errorf("notifier %d: %s needs %s", i, notifier.Type, missing)
+		}
+		for _, secret := range []struct{ key, value string }{{"url", notifier.URL}, {"token", notifier.Token}, {"password", notifier.Password}} {
+			if err := secretref.Validate(secret.value); err != nil {
+				return fmt.Errorf("notifier %d: %s: %w", i, secret.key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// WebhookTemplateFuncs are the functions webhook templates may use besides the
+// built-in ones: json encodes a value as JSON, so it can go in a JSON body as is
+var WebhookTemplateFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// validateSecretScanRules checks that a secret_scan_rules file loads; empty means the built-in rules
+func validateSecretScanRules(path string) error {
+	if path == "" {
+		return nil
+	}
+	if _, _, err := secrets.LoadRules(path); err != nil {
+		return fmt.Errorf("invalid secret_scan_rules: %w", err)
+	}
+	return nil
+}
+
+// validateGitBackend checks a git_backend value; empty means the default
+func validateGitBackend(backend string) error {
+	switch backend {
+	case "", "go-git", "git":
+		return nil
+	default:
+		return fmt.Errorf("invalid git_backend '%s': must be go-git or git", backend)
+	}
+}
+
+// validateRefSpecs checks that custom push/fetch refspecs are well-formed
+func validateRefSpecs(repo RepoConfig) error {
+	for _, spec := range repo.PushRefSpecs {
+		if err := gitconfig.RefSpec(spec).Validate(); err != nil {
+			return fmt.Errorf("invalid push refspec '%s': %w", spec, err)
+		}
+	}
+	for _, spec := range repo.FetchRefSpecs {
+		if err := gitconfig.RefSpec(spec).Validate(); err != nil {
+			return fmt.Errorf("invalid fetch refspec '%s': %w", spec, err)
+		}
+	}
+	return nil
 }
 
 // applyDefaults ensures that any missing configuration values get their default values
@@ -122,19 +1103,67 @@ func applyDefaults(config *Config) {
 // This is the single source of truth for all default values
 func setAllDefaults(v *viper.Viper) {
 	// Global defaults
+	v.SetDefault("global.profile", "default")
 	v.SetDefault("global.log_level", "info")
 	v.SetDefault("global.default_interval", 300)
 	v.SetDefault("global.max_concurrent_syncs", 5)
+	v.SetDefault("global.max_io_heavy_syncs", 1)
+	v.SetDefault("global.max_light_syncs", 5)
+	v.SetDefault("global.log_dedup_burst", 3)
+	v.SetDefault("global.log_dedup_window", 600)
+	v.SetDefault("global.log_format", "text")
+	v.SetDefault("global.log_file", "")
+	v.SetDefault("global.log_file_max_size_mb", 10)
+	v.SetDefault("global.startup_stagger", 0)
+	v.SetDefault("global.sync_jitter", 0)
+	v.SetDefault("global.sync_on_network_change", true)
+	v.SetDefault("global.retry_max_attempts", 3)
+	v.SetDefault("global.retry_backoff", 30)
+	v.SetDefault("global.retry_max_backoff", 600)
+	v.SetDefault("global.circuit_breaker_failures", 5)
+	v.SetDefault("global.circuit_breaker_cooldown", 3600)
+	v.SetDefault("global.pause_when_offline", true)
+	v.SetDefault("global.offline_probe", "")
+	v.SetDefault("global.pause_on_battery", false)
+	v.SetDefault("global.battery_interval_multiplier", 1.0)
 	
 	// History defaults
 	v.SetDefault("global.history_max_entries", 1000)
 	v.SetDefault("global.history_retention_days", 30)
 	v.SetDefault("global.history_cache_dir", "")
 	v.SetDefault("global.history_max_file_size_mb", 10)
+	v.SetDefault("global.history_backend", "jsonl")
 	
 	// Notification defaults
 	v.SetDefault("global.enable_notifications", true)
 	v.SetDefault("global.notification_timeout", 5000)
+	v.SetDefault("global.notification_policy", "all")
+
+	// Transport defaults
+	v.SetDefault("global.proxy", "")
+	v.SetDefault("global.ca_bundle", "")
+
+	// Metrics defaults
+	v.SetDefault("global.metrics_file", "")
+	v.SetDefault("global.metrics_format", "prometheus")
+	v.SetDefault("global.metrics_write_interval", 60)
+
+	// HTTP API defaults
+	v.SetDefault("global.api_listen", "")
+	v.SetDefault("global.metrics_listen", "")
+	v.SetDefault("global.incoming_webhook_secret", "")
+
+	// Fleet reporting defaults
+	v.SetDefault("global.report_to", "")
+	v.SetDefault("global.report_token", "")
+	v.SetDefault("global.machine_id", "")
+	v.SetDefault("global.fault_injection", "")
+
+	// Git backend default
+	v.SetDefault("global.git_backend", "go-git")
+
+	// Secret scan defaults
+	v.SetDefault("global.secret_scan_rules", "")
 }
 
 // structToMap converts a config struct to a map for Viper operations
@@ -149,18 +1178,20 @@ func structToMap(config *Config) map[string]interface{} {
 	if err != nil {
 		return nil
 	}
+	// Settings repositories take from their group stay in the group
+	stripInherited(config.Repositories, m)
+	restorePaths(m, config.unexpanded)
 	
 	return m
 }
 
 func SaveConfig(config *Config, configPath string) error {
-	v := viper.New()
-	
 	var err error
 	configPath, err = GetConfigPath(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to get config path: %w", err)
 	}
+	config = config.withoutPreset().withoutHostProfile()
 
 	// Ensure config directory exists
 	configDir := filepath.Dir(configPath)
@@ -168,12 +1199,8 @@ func SaveConfig(config *Config, configPath string) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	// Configure Viper
-	v.SetConfigFile(configPath)
-	v.SetConfigType("toml")
-	
-	// Set all defaults first
-	setAllDefaults(v)
+	// Configure Viper with all defaults first
+	v := newViper(configPath)
 	
 	// Read existing config if it exists to preserve any extra fields
 	if _, err := os.Stat(configPath); err == nil {
@@ -188,13 +1215,29 @@ func SaveConfig(config *Config, configPath string) error {
 		return fmt.Errorf("failed to convert config to map")
 	}
 	
+	// Saving writes the current layout
+	configMap["config_version"] = SchemaVersion
+
+	// What includes and drop-ins set is written back to them instead
+	if config.sources != nil {
+		if err := config.sources.separateIncluded(config, configMap); err != nil {
+			return err
+		}
+	}
+
 	// Merge our config into viper (preserves defaults for missing fields)
 	if err := v.MergeConfigMap(configMap); err != nil {
 		return fmt.Errorf("failed to merge config: %w", err)
 	}
+	// Lists left empty are omitted from the map, and would keep their old entries
+	for _, key := range listKeys {
+		if _, exists := configMap[key]; !exists && v.IsSet(key) {
+			v.Set(key, []map[string]any{})
+		}
+	}
 
 	// Write the merged config
-	if err := v.WriteConfig(); err != nil {
+	if err := writeSettings(configPath, v.AllSettings()); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -224,39 +1267,136 @@ func mergeGlobalConfig(v *viper.Viper, global GlobalConfig) {
 	if global.HistoryMaxFileSizeMB > 0 {
 		v.Set("global.history_max_file_size_mb", global.HistoryMaxFileSizeMB)
 	}
+	if global.HistoryBackend != "" {
+		v.Set("global.history_backend", global.HistoryBackend)
+	}
 	// Notification settings
 	v.Set("global.enable_notifications", global.EnableNotifications)
 	if global.NotificationTimeout > 0 {
 		v.Set("global.notification_timeout", global.NotificationTimeout)
 	}
+	if global.NotificationPolicy != "" {
+		v.Set("global.notification_policy", global.NotificationPolicy)
+	}
 }
 
 func AddRepository(repoConfig RepoConfig, configPath string) error {
+	return AddRepositories([]RepoConfig{repoConfig}, configPath)
+}
+
+// AddRepositories adds each repository to the config, or updates it when
+// already configured, saving the config once
+func AddRepositories(repoConfigs []RepoConfig, configPath string) error {
 	config, err := LoadConfig(configPath)
 	if err != nil {
 		return err
 	}
 
-	// Check if repository already exists
-	for i, repo := range config.Repositories {
-		if repo.Path == repoConfig.Path {
-			// Update existing repository
+	for _, repoConfig := range repoConfigs {
+		// Check if repository already exists
+		repoConfig.Path = paths.NormalizeRepo(repoConfig.Path)
+		if i, exists := config.FindRepository(repoConfig.Path); exists {
+			// Update existing repository, keeping the path as configured
+			repoConfig.Path = config.Repositories[i].Path
+			repoConfig.Source = config.Repositories[i].Source
 			config.Repositories[i] = repoConfig
-			return SaveConfig(config, configPath)
+			continue
 		}
-	}
 
-	// Add new repository
-	config.Repositories = append(config.Repositories, repoConfig)
+		// Add new repository
+		config.Repositories = append(config.Repositories, repoConfig)
+	}
 	return SaveConfig(config, configPath)
 }
 
+// SetRepositoryEnabled turns syncing of the repository configured at path on or
+// off, keeping the rest of its configuration. It returns the repository and
+// whether its enabled flag changed.
+func SetRepositoryEnabled(path string, enabled bool, configPath string) (RepoConfig, bool, error) {
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		return RepoConfig{}, false, err
+	}
+
+	i, exists := config.FindRepository(path)
+	if !exists {
+		// A repository a path glob matches gets an entry of its own, which the glob leaves alone
+		expanded := config.ExpandPatterns()
+		j, matched := expanded.FindRepository(path)
+		if !matched {
+			return RepoConfig{}, false, fmt.Errorf("repository %s is not configured for sync", path)
+		}
+		repo := expanded.Repositories[j]
+		if repo.Enabled == enabled {
+			return repo, false, nil
+		}
+		repo.Pattern = ""
+		config.Repositories = append(config.Repositories, repo)
+		i = len(config.Repositories) - 1
+	}
+	if config.disabledByProfile(path) && !enabled {
+		// Disabled by the host profile only, so still enabled in the file
+		config.keepDisabled(path)
+		return config.Repositories[i], true, SaveConfig(config, configPath)
+	}
+	if config.Repositories[i].Enabled == enabled {
+		return config.Repositories[i], false, nil
+	}
+	config.Repositories[i].Enabled = enabled
+	return config.Repositories[i], true, SaveConfig(config, configPath)
+}
+
+// RemoveRepository deletes the repository configured at path and takes it out
+// of its sync set, dropping the set when fewer than two members remain. It
+// returns the removed repository.
+func RemoveRepository(path, configPath string) (RepoConfig, error) {
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		return RepoConfig{}, err
+	}
+
+	i, exists := config.FindRepository(path)
+	if !exists {
+		return RepoConfig{}, fmt.Errorf("repository %s is not configured for sync", path)
+	}
+	removed := config.Repositories[i]
+	config.Repositories = append(config.Repositories[:i], config.Repositories[i+1:]...)
+
+	sets := config.SyncSets[:0]
+	for _, set := range config.SyncSets {
+		var members []string
+		for _, member := range set.Repositories {
+			if !paths.SameRepo(member, removed.Path) {
+				members = append(members, member)
+			}
+		}
+		if len(members) >= 2 {
+			set.Repositories = members
+			sets = append(sets, set)
+		}
+	}
+	config.SyncSets = sets
+
+	return removed, SaveConfig(config, configPath)
+}
+
 func getDefaultConfigPath() (string, error) {
-	home, err := os.UserHomeDir()
+	configDir, err := paths.ConfigDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".config", "git-sync", "config.toml"), nil
+	tomlPath := filepath.Join(configDir, "config.toml")
+	if _, err := os.Stat(tomlPath); err == nil {
+		return tomlPath, nil
+	}
+	// A YAML or JSON config is used when there is no TOML one
+	for _, name := range []string{"config.yaml", "config.yml", "config.json"} {
+		path := filepath.Join(configDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return tomlPath, nil
 }
 
 // GetConfigPath returns the config file path, using the provided path if not empty,
@@ -269,15 +1409,12 @@ func GetConfigPath(configFile string) (string, error) {
 }
 
 func createDefaultConfig(configPath string) error {
-	v := viper.New()
-	v.SetConfigFile(configPath)
-	v.SetConfigType("toml")
-	
 	// Use the same defaults system
-	setAllDefaults(v)
+	v := newViper(configPath)
 	
 	// Create empty repositories array
 	v.Set("repositories", []RepoConfig{})
+	v.Set("config_version", SchemaVersion)
 	
 	// Ensure config directory exists
 	configDir := filepath.Dir(configPath)
@@ -286,7 +1423,7 @@ func createDefaultConfig(configPath string) error {
 	}
 	
 	// Write the config with all defaults
-	return v.SafeWriteConfig()
+	return writeSettings(configPath, v.AllSettings())
 }
 
 // NewConfigWatcher creates a new ConfigWatcher instance
@@ -305,12 +1442,8 @@ func NewConfigWatcher(configPath string, onChange func(*Config) error, logger *s
 		return nil, fmt.Errorf("failed to load initial config: %w", err)
 	}
 
-	v := viper.New()
-	v.SetConfigFile(configPath)
-	v.SetConfigType("toml")
-	
 	// Use our centralized defaults system
-	setAllDefaults(v)
+	v := newViper(configPath)
 
 	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
@@ -328,57 +1461,124 @@ func NewConfigWatcher(configPath string, onChange func(*Config) error, logger *s
 	return cw, nil
 }
 
-// StartWatching begins watching the config file for changes
+// StartWatching begins watching the config file, its includes and drop-ins for changes
 func (cw *ConfigWatcher) StartWatching() error {
 	cw.viper.OnConfigChange(func(e fsnotify.Event) {
-		cw.mu.Lock()
-		defer cw.mu.Unlock()
-		
-		// Debounce rapid file changes
-		now := time.Now()
-		if now.Sub(cw.lastChange) < cw.debounceDelay {
-			return
-		}
-		cw.lastChange = now
-		
-		cw.logger.Info("Config file changed, reloading", "file", e.Name)
-		
-		// Reload config
-		var newConfig Config
-		if err := cw.viper.Unmarshal(&newConfig); err != nil {
-			cw.logger.Error("Failed to unmarshal updated config", "error", err)
-			return
-		}
-		
-		// Validate config
-		if err := cw.validateConfig(&newConfig); err != nil {
-			cw.logger.Error("Invalid config detected, ignoring changes", "error", err)
-			return
-		}
-		
-		// Update current config
-		cw.currentConfig = &newConfig
-		
-		// Call the onChange callback
-		if cw.onChange != nil {
-			if err := cw.onChange(&newConfig); err != nil {
-				cw.logger.Error("Failed to apply config changes", "error", err)
-				return
-			}
-		}
-		
-		cw.logger.Info("Config reloaded successfully")
+		cw.reload(e.Name)
 	})
-	
 	cw.viper.WatchConfig()
+
+	// Viper only watches the main file
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch included config files: %w", err)
+	}
+	cw.includeWatcher = watcher
+	cw.watchIncludeDirs()
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if cw.isIncluded(event.Name) {
+					cw.reload(event.Name)
+					cw.watchIncludeDirs()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				cw.logger.Warn("Watching included config files failed", "error", err)
+			}
+		}
+	}()
+
 	cw.logger.Info("Started watching config file", "path", cw.configPath)
 	return nil
 }
 
+// reload reads the config again after name changed and applies it
+func (cw *ConfigWatcher) reload(name string) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	
+	// Debounce rapid file changes
+	now := time.Now()
+	if now.Sub(cw.lastChange) < cw.debounceDelay {
+		return
+	}
+	cw.lastChange = now
+	
+	cw.logger.Info("Config file changed, reloading", "file", name)
+	
+	// Viper logs and drops read errors before calling back with the old
+	// settings, so read again to catch a file that no longer parses
+	if err := cw.viper.ReadInConfig(); err != nil {
+		cw.logger.Error("Failed to read updated config", "error", err)
+		cw.reportError(fmt.Errorf("failed to read config: %w", err))
+		return
+	}
+	if err := migrateFile(cw.viper, cw.configPath); err != nil {
+		cw.logger.Error("Failed to migrate updated config", "error", err)
+		cw.reportError(err)
+		return
+	}
+
+	// Reload config
+	newConfig, err := readConfig(cw.viper, cw.configPath)
+	if err != nil {
+		cw.logger.Error("Failed to load updated config", "error", err)
+		cw.reportError(err)
+		return
+	}
+	
+	// Validate config
+	if err := cw.validateConfig(newConfig); err != nil {
+		cw.logger.Error("Invalid config detected, ignoring changes", "error", err)
+		cw.reportError(err)
+		return
+	}
+	applyProfile(newConfig)
+	
+	// Update current config
+	cw.currentConfig = newConfig
+	
+	// Call the onChange callback
+	if cw.onChange != nil {
+		if err := cw.onChange(newConfig); err != nil {
+			cw.logger.Error("Failed to apply config changes", "error", err)
+			cw.reportError(err)
+			return
+		}
+	}
+	
+	cw.logger.Info("Config reloaded successfully")
+}
+
+// OnReloadError registers fn to learn about changes to the config file that were
+// ignored because the file could not be loaded
+func (cw *ConfigWatcher) OnReloadError(fn func(error)) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.onError = fn
+}
+
+// reportError passes a failed reload to the error callback; the caller holds cw.mu
+func (cw *ConfigWatcher) reportError(err error) {
+	if cw.onError != nil {
+		cw.onError(err)
+	}
+}
+
 // StopWatching stops watching the config file
 func (cw *ConfigWatcher) StopWatching() {
 	// Viper doesn't provide a direct way to stop watching, so we clear the callback
 	cw.viper.OnConfigChange(func(e fsnotify.Event) {})
+	if cw.includeWatcher != nil {
+		cw.includeWatcher.Close()
+	}
 	cw.logger.Info("Stopped watching config file")
 }
 
@@ -391,24 +1591,27 @@ func (cw *ConfigWatcher) GetCurrentConfig() *Config {
 
 // validateConfig performs basic validation on the configuration
 func (cw *ConfigWatcher) validateConfig(config *Config) error {
-	if config.Global.DefaultInterval <= 0 {
-		return fmt.Errorf("default_interval must be positive")
+	if err := validateGlobalLimits(config.Global); err != nil {
+		return err
 	}
-	if config.Global.MaxConcurrentSyncs <= 0 {
-		return fmt.Errorf("max_concurrent_syncs must be positive")
+	if err := validateGlobalOptions(config.Global); err != nil {
+		return err
 	}
 	
 	for i, repo := range config.Repositories {
-		if repo.Path == "" {
-			return fmt.Errorf("repository %d: path cannot be empty", i)
-		}
-		if repo.Interval < 0 {
-			return fmt.Errorf("repository %d: interval cannot be negative", i)
+		if err := validateRepoBasics(repo); err != nil {
+			return fmt.Errorf("repository %d: %w", i, err)
 		}
-		if repo.Direction != "push" && repo.Direction != "pull" && repo.Direction != "sync" {
-			return fmt.Errorf("repository %d: direction must be 'push', 'pull', or 'sync'", i)
+		if err := validateRepoOptions(repo); err != nil {
+			return fmt.Errorf("repository %d: %w", i, err)
 		}
 	}
-	
-	return nil
+
+	if err := validateSyncSets(config); err != nil {
+		return err
+	}
+	if err := validateWebhooks(config.Webhooks); err != nil {
+		return err
+	}
+	return validateNotifiers(config.Notifiers)
 }
\ No newline at end of file
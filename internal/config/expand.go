@@ -0,0 +1,123 @@
+package config
+
+import "github.com/bnema/git-sync/internal/paths"
+
+// Settings holding paths, where ~ and environment variables are expanded
+var (
+	globalPathKeys = []string{"history_cache_dir", "log_file", "ca_bundle", "metrics_file", "secret_scan_rules"}
+	repoPathKeys   = []string{"path", "ca_bundle", "secret_scan_rules", "ssh_key"}
+)
+
+// walkPaths replaces each path in settings, as decoded from a config file,
+// with what replace returns for it
+func walkPaths(settings map[string]any, replace func(string) string) {
+	replaceKeys := func(fields map[string]any, keys []string) {
+		for _, key := range keys {
+			if value, ok := fields[key].(string); ok {
+				fields[key] = replace(value)
+			}
+		}
+	}
+	replaceList := func(value any) {
+		switch list := value.(type) {
+		case []string:
+			for i := range list {
+				list[i] = replace(list[i])
+			}
+		case []any:
+			for i, item := range list {
+				if value, ok := item.(string); ok {
+					list[i] = replace(value)
+				}
+			}
+		}
+	}
+	section := func(key string) map[string]any {
+		fields, _ := settings[key].(map[string]any)
+		return fields
+	}
+
+	if global := section("global"); global != nil {
+		replaceKeys(global, globalPathKeys)
+	}
+	for _, repo := range rawEntries(settings["repositories"]) {
+		replaceKeys(repo, repoPathKeys)
+	}
+	for _, group := range section("groups") {
+		if fields, ok := group.(map[string]any); ok {
+			replaceKeys(fields, repoPathKeys)
+		}
+	}
+	for _, set := range rawEntries(settings["sync_sets"]) {
+		replaceList(set["repositories"])
+	}
+	for _, webhook := range rawEntries(settings["webhooks"]) {
+		replaceList(webhook["repos"])
+	}
+	for _, profile := range section("profiles") {
+		if fields, ok := profile.(map[string]any); ok {
+			replaceList(fields["repositories"])
+			if global, ok := fields["global"].(map[string]any); ok {
+				replaceKeys(global, globalPathKeys)
+			}
+		}
+	}
+}
+
+// expandPaths expands ~ and environment variables in the paths of settings,
+// returning what the changed ones were written as by the values they expanded
+// to, so saving can write them back the same way
+func expandPaths(settings map[string]any) map[string]string {
+	unexpanded := map[string]string{}
+	walkPaths(settings, func(value string) string {
+		expanded := paths.Expand(value)
+		if expanded != value {
+			unexpanded[expanded] = value
+			// Repository paths are stored cleaned, and absolute even with a variable unset
+			unexpanded[paths.NormalizeRepo(expanded)] = value
+		}
+		return expanded
+	})
+	return unexpanded
+}
+
+// cloneSettings copies decoded config settings, so they can be changed apart from where they came from
+func cloneSettings(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		clone := make(map[string]any, len(value))
+		for key, item := range value {
+			clone[key] = cloneSettings(item)
+		}
+		return clone
+	case []map[string]any:
+		clone := make([]map[string]any, len(value))
+		for i, item := range value {
+			clone[i] = cloneSettings(item).(map[string]any)
+		}
+		return clone
+	case []any:
+		clone := make([]any, len(value))
+		for i, item := range value {
+			clone[i] = cloneSettings(item)
+		}
+		return clone
+	case []string:
+		return append([]string(nil), value...)
+	}
+	return value
+}
+
+// restorePaths writes the paths of settings, about to be saved, as they were
+// before expandPaths, unless they were changed since
+func restorePaths(settings map[string]any, unexpanded map[string]string) {
+	if len(unexpanded) == 0 {
+		return
+	}
+	walkPaths(settings, func(value string) string {
+		if raw, ok := unexpanded[value]; ok {
+			return raw
+		}
+		return value
+	})
+}
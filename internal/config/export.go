@@ -0,0 +1,182 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaVersion is the version of the config layout, written as config_version
+// in config files and schema_version in exports. Bump it and register a
+// migration when a change breaks older configs; config files are migrated
+// when loaded.
+const SchemaVersion = 1
+
+// ExportFormats are the formats configs can be exported to and imported from
+var ExportFormats = []string{"toml", "json", "yaml"}
+
+// migrations[i] upgrades a config from schema version i to i+1. Plain config
+// files and exports from before versioning are version 0.
+var migrations = []func(raw map[string]any){
+	migrateV0,
+}
+
+// migrateV0 renames the 'sync' direction of early configs to 'both'
+func migrateV0(raw map[string]any) {
+	repos, _ := raw["repositories"].([]any)
+	for _, r := range repos {
+		if repo, ok := r.(map[string]any); ok && repo["direction"] == "sync" {
+			repo["direction"] = "both"
+		}
+	}
+}
+
+// FormatOf tells the config format from the extension of path, or "" if unknown
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return "toml"
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return ""
+}
+
+// Export encodes cfg in format with its schema version, for 'git sync config
+// import' on another machine. The machine_id is left out, as it names this machine.
+func Export(cfg *Config, format string) ([]byte, error) {
+	exported := *cfg.withoutPreset().withoutHostProfile()
+	exported.Global.MachineID = ""
+	// The entries of includes and drop-ins are exported with the rest
+	exported.Include = nil
+	// schema_version stands for it
+	exported.ConfigVersion = 0
+
+	raw := structToMap(&exported)
+	if raw == nil {
+		return nil, fmt.Errorf("failed to encode config")
+	}
+	if format == "toml" {
+		data, err := encodeSettings(raw, format)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(fmt.Sprintf("schema_version = %d\n\n", SchemaVersion)), data...), nil
+	}
+	raw["schema_version"] = SchemaVersion
+	return encodeSettings(raw, format)
+}
+
+// encodeSettings writes decoded config settings in format
+func encodeSettings(raw map[string]any, format string) ([]byte, error) {
+	var data []byte
+	var err error
+	switch format {
+	case "toml":
+		data, err = toml.Marshal(raw)
+	case "json":
+		data, err = json.MarshalIndent(raw, "", "  ")
+		data = append(data, '\n')
+	case "yaml":
+		data, err = yaml.Marshal(raw)
+	default:
+		return nil, fmt.Errorf("unsupported format '%s': must be toml, json, or yaml", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return data, nil
+}
+
+// Imported is an exported config decoded and migrated to the current schema
+type Imported struct {
+	FromVersion int // schema version of the export
+	settings    map[string]any
+}
+
+// Encode writes the imported config as a config file in format
+func (i *Imported) Encode(format string) ([]byte, error) {
+	return encodeSettings(i.settings, format)
+}
+
+// DecodeExport reads an export, or a plain config file, in format and
+// migrates it to SchemaVersion
+func DecodeExport(data []byte, format string) (*Imported, error) {
+	var raw map[string]any
+	var err error
+	switch format {
+	case "toml":
+		err = toml.Unmarshal(data, &raw)
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err = decoder.Decode(&raw); err == nil {
+			raw, _ = normalizeJSONNumbers(raw).(map[string]any)
+		}
+	case "yaml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported format '%s': must be toml, json, or yaml", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", format, err)
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("failed to parse %s: not a config", format)
+	}
+
+	// Plain config files name their version config_version
+	versionKey := "schema_version"
+	if _, ok := raw[versionKey]; !ok {
+		versionKey = "config_version"
+	}
+	version := 0
+	switch v := raw[versionKey].(type) {
+	case nil:
+	case int:
+		version = v
+	case int64:
+		version = int(v)
+	default:
+		return nil, fmt.Errorf("invalid %s %v", versionKey, v)
+	}
+	if version < 0 || version > SchemaVersion {
+		return nil, fmt.Errorf("%s %d is not supported, this git-sync reads up to %d", versionKey, version, SchemaVersion)
+	}
+	delete(raw, "schema_version")
+	for _, migrate := range migrations[version:] {
+		migrate(raw)
+	}
+	raw["config_version"] = SchemaVersion
+
+	return &Imported{FromVersion: version, settings: raw}, nil
+}
+
+// normalizeJSONNumbers turns the json.Numbers in value into int64 or float64,
+// so integers stay integers in TOML
+func normalizeJSONNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalizeJSONNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeJSONNumbers(item)
+		}
+	}
+	return value
+}
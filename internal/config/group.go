@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// unsharedKeys are the repository settings a group can't set
+var unsharedKeys = []string{"path", "group"}
+
+// rawEntries returns the entries of a list setting as written, e.g. repositories
+func rawEntries(value any) []map[string]any {
+	switch list := value.(type) {
+	case []map[string]any:
+		return list
+	case []any:
+		entries := make([]map[string]any, 0, len(list))
+		for _, entry := range list {
+			fields, _ := entry.(map[string]any)
+			entries = append(entries, fields)
+		}
+		return entries
+	}
+	return nil
+}
+
+// decodeGroup sets the settings of a group on repo, failing on those a repository doesn't have
+func decodeGroup(name string, settings map[string]any, repo *RepoConfig) error {
+	for _, key := range unsharedKeys {
+		if _, ok := settings[key]; ok {
+			return fmt.Errorf("%s can't be set in groups.%s", key, name)
+		}
+	}
+	return decodeSettings(settings, repo, "groups."+name)
+}
+
+// applyGroups fills the settings of repos from their group where the entries
+// don't set them. raw holds the entries as written, as decoding loses whether
+// a setting was left out or set to its zero value.
+func applyGroups(repos []RepoConfig, raw []map[string]any, groups map[string]map[string]any) error {
+	for i := range repos {
+		repo := &repos[i]
+		if repo.Group == "" {
+			continue
+		}
+		// Viper lowercases the names of sections
+		settings, ok := groups[strings.ToLower(repo.Group)]
+		if !ok {
+			return fmt.Errorf("repository %s: group '%s' is not defined in [groups]", repo.Path, repo.Group)
+		}
+		var own map[string]any
+		if i < len(raw) {
+			own = raw[i]
+		}
+		inherited := map[string]any{}
+		for key, value := range settings {
+			if _, set := own[key]; !set {
+				inherited[key] = value
+			}
+		}
+		if err := decodeGroup(strings.ToLower(repo.Group), inherited, repo); err != nil {
+			return fmt.Errorf("repository %s: %w", repo.Path, err)
+		}
+		repo.inherited = inherited
+		repo.written = slices.Collect(maps.Keys(own))
+	}
+	return nil
+}
+
+// stripInherited removes from the repositories in a group of settings, as
+// about to be saved, what their group sets unless it was changed since, and
+// the zero values their entries left out, which would hide the group's
+// should it set them later
+func stripInherited(repos []RepoConfig, settings map[string]any) {
+	entries := rawEntries(settings["repositories"])
+	for i, fields := range entries {
+		if i >= len(repos) || fields == nil || repos[i].Group == "" {
+			continue
+		}
+		repo := repos[i]
+		for key, value := range fields {
+			if inherited, ok := repo.inherited[key]; ok {
+				if fmt.Sprint(value) == fmt.Sprint(inherited) {
+					delete(fields, key)
+				}
+			} else if !slices.Contains(repo.written, key) && reflect.ValueOf(value).IsZero() {
+				delete(fields, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/pelletier/go-toml/v2"
+
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+// hostProfileName is the [profiles] section chosen with --profile; empty selects one by hostname
+var hostProfileName string
+
+// SetHostProfile selects the [profiles] section to apply instead of the one matching the hostname
+func SetHostProfile(name string) {
+	hostProfileName = name
+}
+
+// HostProfile is a [profiles.<name>] section, for sharing one config between
+// machines that sync different repositories or sync them differently. Not to
+// be confused with the profile setting of [global], a resource preset.
+type HostProfile struct {
+	// Hostname globs selecting the profile when --profile isn't given
+	Hosts []string `toml:"hosts,omitempty"`
+
+	// Paths or path globs of the repositories synced under the profile; the
+	// others are treated as disabled. Empty syncs them all.
+	Repositories []string `toml:"repositories,omitempty"`
+
+	// Settings replacing those of [global], e.g. default_interval
+	Global map[string]any `toml:"global,omitempty"`
+}
+
+// appliedProfile is the host profile applied to a loaded config, undone when it is saved
+type appliedProfile struct {
+	name     string
+	profile  HostProfile
+	before   GlobalConfig // the global settings without the profile
+	disabled []string     // the enabled repositories the profile leaves out
+}
+
+// HostProfile returns the name of the [profiles] section applied, or "" if none is
+func (c *Config) HostProfile() string {
+	if c.hostProfile == nil {
+		return ""
+	}
+	return c.hostProfile.name
+}
+
+// ProfileLeavesOut reports whether the applied host profile keeps the repository at repoPath from syncing
+func (c *Config) ProfileLeavesOut(repoPath string) bool {
+	return c.hostProfile != nil && !c.hostProfile.profile.syncs(repoPath)
+}
+
+// disabledByProfile reports whether the repository at repoPath is enabled in its file but not under the host profile
+func (c *Config) disabledByProfile(repoPath string) bool {
+	return c.hostProfile != nil && slices.ContainsFunc(c.hostProfile.disabled, func(p string) bool { return paths.SameRepo(p, repoPath) })
+}
+
+// matchesHost reports whether one of the hosts globs matches hostname, ignoring case
+func (p HostProfile) matchesHost(hostname string) bool {
+	for _, pattern := range p.Hosts {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(hostname)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// syncs reports whether the repository at repoPath is synced under the profile
+func (p HostProfile) syncs(repoPath string) bool {
+	if len(p.Repositories) == 0 {
+		return true
+	}
+	for _, pattern := range p.Repositories {
+		if paths.SameRepo(pattern, repoPath) {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, repoPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hostProfilesMatching returns the names of the profiles whose hosts match hostname, sorted
+func hostProfilesMatching(profiles map[string]HostProfile, hostname string) []string {
+	var names []string
+	for _, name := range slices.Sorted(maps.Keys(profiles)) {
+		if profiles[name].matchesHost(hostname) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// selectHostProfile picks the profile set with SetHostProfile, or else the
+// first by name matching the hostname; "" when none applies
+func selectHostProfile(profiles map[string]HostProfile) (string, error) {
+	if hostProfileName != "" {
+		if _, ok := profiles[hostProfileName]; !ok {
+			return "", fmt.Errorf("profile '%s' is not defined in [profiles]", hostProfileName)
+		}
+		return hostProfileName, nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", nil
+	}
+	if names := hostProfilesMatching(profiles, hostname); len(names) > 0 {
+		return names[0], nil
+	}
+	return "", nil
+}
+
+// applyHostProfile applies the selected [profiles] section to the loaded config
+func applyHostProfile(config *Config) error {
+	name, err := selectHostProfile(config.Profiles)
+	if err != nil || name == "" {
+		return err
+	}
+	profile := config.Profiles[name]
+	applied := &appliedProfile{name: name, profile: profile, before: config.Global}
+	if err := decodeGlobal(profile.Global, &config.Global); err != nil {
+		return fmt.Errorf("profile '%s': %w", name, err)
+	}
+	for i := range config.Repositories {
+		repo := &config.Repositories[i]
+		if repo.Enabled && !profile.syncs(repo.Path) {
+			repo.Enabled = false
+			applied.disabled = append(applied.disabled, repo.Path)
+		}
+	}
+	config.hostProfile = applied
+	return nil
+}
+
+// decodeGlobal sets the global settings in settings, by key, on global, failing on unknown keys
+func decodeGlobal(settings map[string]any, global *GlobalConfig) error {
+	return decodeSettings(settings, global, "global")
+}
+
+// decodeSettings sets settings, by key, on the fields of result, failing on
+// keys none takes; section names them in the error
+func decodeSettings(settings map[string]any, result any, section string) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName:     "toml",
+		ErrorUnused: true,
+		Result:      result,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(settings); err != nil {
+		// mapstructure lists its errors under a header line
+		var messages []string
+		for _, line := range strings.Split(err.Error(), "\n") {
+			if match := unknownKeysPattern.FindStringSubmatch(line); match != nil {
+				messages = append(messages, "unknown keys in "+section+": "+match[2])
+			} else if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "decoding failed") {
+				messages = append(messages, line)
+			}
+		}
+		return errors.New(strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// globalSettings returns the global settings by key
+func globalSettings(global GlobalConfig) map[string]any {
+	data, err := toml.Marshal(global)
+	if err != nil {
+		return nil
+	}
+	var settings map[string]any
+	if err := toml.Unmarshal(data, &settings); err != nil {
+		return nil
+	}
+	return settings
+}
+
+// withoutHostProfile returns the config as its files have it, undoing what
+// the host profile changed. Settings the profile replaces that were changed
+// since are moved into its section, as [global] would no longer apply them.
+func (c *Config) withoutHostProfile() *Config {
+	applied := c.hostProfile
+	if applied == nil {
+		return c
+	}
+	undone := *c
+	undone.hostProfile = nil
+
+	profile := c.Profiles[applied.name]
+	current, before := globalSettings(c.Global), globalSettings(applied.before)
+	var changed map[string]any
+	for key, value := range profile.Global {
+		if fmt.Sprint(current[key]) != fmt.Sprint(value) {
+			if changed == nil {
+				changed = maps.Clone(profile.Global)
+			}
+			changed[key] = current[key]
+		}
+		current[key] = before[key]
+	}
+	undone.Global = GlobalConfig{}
+	_ = decodeGlobal(current, &undone.Global)
+	if changed != nil {
+		undone.Profiles = maps.Clone(c.Profiles)
+		profile.Global = changed
+		undone.Profiles[applied.name] = profile
+	}
+
+	undone.Repositories = slices.Clone(c.Repositories)
+	for i := range undone.Repositories {
+		repo := &undone.Repositories[i]
+		if !repo.Enabled && slices.Contains(applied.disabled, repo.Path) {
+			repo.Enabled = true
+		}
+	}
+	return &undone
+}
+
+// keepDisabled makes the repository at repoPath, left out by the host profile, stay disabled when saved
+func (c *Config) keepDisabled(repoPath string) {
+	if c.hostProfile != nil {
+		c.hostProfile.disabled = slices.DeleteFunc(c.hostProfile.disabled, func(p string) bool { return paths.SameRepo(p, repoPath) })
+	}
+}
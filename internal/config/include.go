@@ -0,0 +1,333 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+// DropInDir is the directory next to the config whose files are merged over it
+const DropInDir = "config.d"
+
+// listKeys are the settings includes and drop-ins add entries to, where other
+// settings they set replace those of the files merged before
+var listKeys = []string{"repositories", "sync_sets", "webhooks", "notifiers"}
+
+// configSources records what the includes and drop-ins of a config set, so
+// saving writes each setting back to the file it came from
+type configSources struct {
+	files     []string            // in merge order
+	overrides map[string]override // settings outside the lists, by dotted key
+	parts     map[string]Config   // the list entries of each file, as loaded
+}
+
+// override is a setting of an included file that replaced the one merged before
+type override struct {
+	file  string
+	value any
+}
+
+// includedFiles resolves the include patterns of the config at configPath and
+// lists its drop-ins, in the order they are merged. Included files that don't
+// exist are skipped, like git does, and returned as missing.
+func includedFiles(configPath string, include []string) (files, missing []string, err error) {
+	dir := filepath.Dir(configPath)
+	seen := map[string]bool{filepath.Clean(configPath): true}
+	add := func(path string) {
+		path = filepath.Clean(path)
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, pattern := range include {
+		pattern = paths.Expand(pattern)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			if _, err := os.Stat(pattern); err != nil {
+				missing = append(missing, pattern)
+				continue
+			}
+			add(pattern)
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid include pattern '%s': %w", pattern, err)
+		}
+		for _, match := range matches {
+			add(match)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, DropInDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", DropInDir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || FormatOf(name) == "" {
+			continue
+		}
+		add(filepath.Join(dir, DropInDir, name))
+	}
+	return files, missing, nil
+}
+
+// readPlain reads the config file at path without the defaults, as included files are
+func readPlain(path string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType(FileFormat(path))
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return v, nil
+}
+
+// readConfig decodes the config file read by v, merged with its includes and
+// drop-ins, and applies the groups and the host profile. v itself is left
+// holding the file alone, so it can be written back.
+func readConfig(v *viper.Viper, configPath string) (*Config, error) {
+	files, _, err := includedFiles(configPath, v.GetStringSlice("include"))
+	if err != nil {
+		return nil, err
+	}
+
+	merged := v
+	unexpanded := map[string]string{}
+	raw := map[string][]map[string]any{configPath: rawEntries(v.Get("repositories"))}
+	var sources *configSources
+	if len(files) > 0 {
+		merged = viper.New()
+		if err := merged.MergeConfigMap(v.AllSettings()); err != nil {
+			return nil, fmt.Errorf("failed to merge includes: %w", err)
+		}
+		sources = &configSources{files: files, overrides: map[string]override{}, parts: map[string]Config{}}
+		for _, file := range files {
+			fv, err := readPlain(file)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range fv.AllKeys() {
+				if key == "include" || slices.Contains(listKeys, key) {
+					continue
+				}
+				merged.Set(key, fv.Get(key))
+				sources.overrides[key] = override{file: file, value: fv.Get(key)}
+			}
+			var part Config
+			if err := unmarshalConfig(fv, &part); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal %s: %w", file, err)
+			}
+			maps.Copy(unexpanded, part.unexpanded)
+			sources.parts[file] = listEntries(&part, "", file)
+			raw[file] = rawEntries(fv.Get("repositories"))
+		}
+	}
+
+	var config Config
+	if err := unmarshalConfig(merged, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	maps.Copy(unexpanded, config.unexpanded)
+	config.unexpanded = unexpanded
+	if err := applyGroups(config.Repositories, raw[configPath], config.Groups); err != nil {
+		return nil, err
+	}
+	if sources != nil {
+		for _, file := range sources.files {
+			part := sources.parts[file]
+			if err := applyGroups(part.Repositories, raw[file], config.Groups); err != nil {
+				return nil, err
+			}
+			config.Repositories = append(config.Repositories, part.Repositories...)
+			config.SyncSets = append(config.SyncSets, part.SyncSets...)
+			config.Webhooks = append(config.Webhooks, part.Webhooks...)
+			config.Notifiers = append(config.Notifiers, part.Notifiers...)
+		}
+		config.sources = sources
+	}
+	if err := applyHostProfile(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// listEntries returns the list entries of config whose source is from, marked as coming from to
+func listEntries(config *Config, from, to string) Config {
+	var part Config
+	for _, repo := range config.Repositories {
+		if repo.Source == from {
+			repo.Source = to
+			part.Repositories = append(part.Repositories, repo)
+		}
+	}
+	for _, set := range config.SyncSets {
+		if set.Source == from {
+			set.Source = to
+			part.SyncSets = append(part.SyncSets, set)
+		}
+	}
+	for _, webhook := range config.Webhooks {
+		if webhook.Source == from {
+			webhook.Source = to
+			part.Webhooks = append(part.Webhooks, webhook)
+		}
+	}
+	for _, notifier := range config.Notifiers {
+		if notifier.Source == from {
+			notifier.Source = to
+			part.Notifiers = append(part.Notifiers, notifier)
+		}
+	}
+	return part
+}
+
+// separateIncluded takes what the includes and drop-ins set out of configMap,
+// the settings of config about to be written to the main file, and writes the
+// changes to them back to the included files. New list entries go to the main file.
+func (s *configSources) separateIncluded(config *Config, configMap map[string]any) error {
+	changed := map[string]map[string]any{}
+	for key, o := range s.overrides {
+		current, ok := lookupKey(configMap, key)
+		if !ok {
+			continue
+		}
+		if fmt.Sprint(current) != fmt.Sprint(o.value) {
+			if changed[o.file] == nil {
+				changed[o.file] = map[string]any{}
+			}
+			changed[o.file][key] = current
+		}
+		deleteKey(configMap, key)
+	}
+
+	own := listEntries(config, "", "")
+	own.unexpanded = config.unexpanded
+	ownMap := structToMap(&own)
+	for _, key := range listKeys {
+		if entries, ok := ownMap[key]; ok {
+			configMap[key] = entries
+		} else {
+			delete(configMap, key)
+		}
+	}
+
+	for _, file := range s.files {
+		part := listEntries(config, file, file)
+		var entries *Config
+		if !reflect.DeepEqual(part, s.parts[file]) {
+			entries = &part
+		}
+		if entries == nil && changed[file] == nil {
+			continue
+		}
+		part.unexpanded = config.unexpanded
+		if err := writeIncluded(file, entries, changed[file]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeIncluded updates the included file at path with settings, by dotted
+// key, and replaces its list entries with those of part unless nil
+func writeIncluded(path string, part *Config, settings map[string]any) error {
+	fv, err := readPlain(path)
+	if err != nil {
+		return err
+	}
+	for key, value := range settings {
+		fv.Set(key, value)
+	}
+	if part != nil {
+		entries := structToMap(part)
+		for _, key := range listKeys {
+			if list, ok := entries[key]; ok {
+				fv.Set(key, list)
+			} else if fv.IsSet(key) {
+				fv.Set(key, []map[string]any{})
+			}
+		}
+	}
+	if err := writeSettings(path, fv.AllSettings()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// watchIncludeDirs watches the directories includes and drop-ins can appear in:
+// that of the config, its drop-in directory and those of the include patterns
+func (cw *ConfigWatcher) watchIncludeDirs() {
+	dir := filepath.Dir(cw.configPath)
+	dirs := []string{dir, filepath.Join(dir, DropInDir)}
+	for _, pattern := range cw.GetCurrentConfig().Include {
+		pattern = paths.Expand(pattern)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		// Directories matched by a glob aren't watched
+		if patternDir := filepath.Dir(pattern); !strings.ContainsAny(patternDir, "*?[") {
+			dirs = append(dirs, patternDir)
+		}
+	}
+	for _, d := range dirs {
+		if info, err := os.Stat(d); err == nil && info.IsDir() {
+			// Adding a watched directory again does nothing
+			_ = cw.includeWatcher.Add(d)
+		}
+	}
+}
+
+// isIncluded tells whether path is, or was when last loaded, an include or drop-in of the config
+func (cw *ConfigWatcher) isIncluded(path string) bool {
+	current := cw.GetCurrentConfig()
+	if path == filepath.Join(filepath.Dir(cw.configPath), DropInDir) {
+		return true
+	}
+	if current.sources != nil && slices.Contains(current.sources.files, path) {
+		return true
+	}
+	files, _, err := includedFiles(cw.configPath, current.Include)
+	return err == nil && slices.Contains(files, path)
+}
+
+// lookupKey finds the value of a dotted key in nested settings
+func lookupKey(settings map[string]any, key string) (any, bool) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := settings[part].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		settings = next
+	}
+	value, ok := settings[parts[len(parts)-1]]
+	return value, ok
+}
+
+// deleteKey removes a dotted key from nested settings
+func deleteKey(settings map[string]any, key string) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := settings[part].(map[string]any)
+		if !ok {
+			return
+		}
+		settings = next
+	}
+	delete(settings, parts[len(parts)-1])
+}
@@ -0,0 +1,391 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+
+	"github.com/bnema/git-sync/internal/paths"
+	"github.com/bnema/git-sync/internal/secretref"
+)
+
+// Problem is something wrong in a config file, found by ValidateFile
+type Problem struct {
+	Section string // e.g. "global", "repository 2 (/home/me/notes)" or "webhooks"
+	Message string
+	Warning bool // the config works, but likely not as meant
+	Local   bool // about the files of this machine rather than the config itself
+}
+
+func (p Problem) String() string {
+	return p.Section + ": " + p.Message
+}
+
+// unknownKeysPattern matches the mapstructure errors of keys no field decodes
+var unknownKeysPattern = regexp.MustCompile(`'([^']*)' has invalid keys: (.+)$`)
+
+// unsetVariablePattern matches the environment variables paths.Expand leaves in place as unset
+var unsetVariablePattern = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// ValidateFile checks the config file at configPath, with its includes and
+// drop-ins, without writing to them. Unlike LoadConfig it reports every
+// problem rather than the first, and also finds unknown keys, duplicate
+// repositories, paths that aren't repositories and settings that have no
+// effect together. It only fails when the file can't be read or parsed.
+func ValidateFile(configPath string) ([]Problem, error) {
+	configPath, err := GetConfigPath(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config path: %w", err)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	v := newViper(configPath)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var problems []Problem
+	add := func(section string, warning bool, format string, args ...any) {
+		problems = append(problems, Problem{Section: section, Message: fmt.Sprintf(format, args...), Warning: warning})
+	}
+
+	// Typos like brach_strategy are otherwise silently ignored
+	unknownKeys := findUnknownKeys(v)
+	addUnknownKeys := func(name, section string) {
+		if keys, ok := unknownKeys[name]; ok {
+			add(section, false, "unknown keys: %s", keys)
+			delete(unknownKeys, name)
+		}
+	}
+
+	// Included files are checked on their own, as the merge hides where keys come from
+	files, missing, err := includedFiles(configPath, v.GetStringSlice("include"))
+	if err != nil {
+		add("include", false, "%v", err)
+		return problems, nil
+	}
+	var includeProblems []Problem
+	for _, file := range files {
+		name := displayName(configPath, file)
+		fv, err := readPlain(file)
+		if err != nil {
+			add(name, false, "%v", err)
+			return problems, nil
+		}
+		if fv.IsSet("include") {
+			includeProblems = append(includeProblems, Problem{Section: name, Message: "include is only read from the main config", Warning: true})
+		}
+		fileKeys := findUnknownKeys(fv)
+		for _, key := range slices.Sorted(maps.Keys(fileKeys)) {
+			section := name
+			if key != "" {
+				section += " " + key
+			}
+			includeProblems = append(includeProblems, Problem{Section: section, Message: "unknown keys: " + fileKeys[key]})
+		}
+	}
+	for _, path := range missing {
+		includeProblems = append(includeProblems, Problem{Section: "include", Message: displayName(configPath, path) + " does not exist", Warning: true, Local: true})
+	}
+
+	config, err := readConfig(v, configPath)
+	if err != nil {
+		add("config", false, "%v", err)
+		return problems, nil
+	}
+
+	addUnknownKeys("", "top level")
+	if _, err := fileVersion(v); err != nil {
+		add("config_version", false, "%v", err)
+	}
+	addUnknownKeys("global", "global")
+	if err := validateGlobalLimits(config.Global); err != nil {
+		add("global", false, "%v", err)
+	}
+	if err := validateGlobalOptions(config.Global); err != nil {
+		add("global", false, "%v", err)
+	}
+	for _, problem := range append(secretProblems("report_token", config.Global.ReportToken, false), secretProblems("incoming_webhook_secret", config.Global.IncomingWebhookSecret, false)...) {
+		problem.Section = "global"
+		problems = append(problems, problem)
+	}
+
+	for i, repo := range config.Repositories {
+		section := fmt.Sprintf("repository %d (%s)", i, repo.Path)
+		if repo.Source != "" {
+			section = fmt.Sprintf("repository %d (%s, in %s)", i, repo.Path, displayName(configPath, repo.Source))
+		}
+		addUnknownKeys(fmt.Sprintf("repositories[%d]", i), section)
+		for j := 0; j < i; j++ {
+			if repo.Path != "" && paths.SameRepo(config.Repositories[j].Path, repo.Path) {
+				add(section, false, "duplicate of repository %d", j)
+				break
+			}
+		}
+		for _, problem := range repoProblems(repo) {
+			problem.Section = section
+			problems = append(problems, problem)
+		}
+	}
+
+	if err := validateSyncSets(config); err != nil {
+		add("sync_sets", false, "%v", err)
+	}
+	if err := validateWebhooks(config.Webhooks); err != nil {
+		add("webhooks", false, "%v", err)
+	}
+	if err := validateNotifiers(config.Notifiers); err != nil {
+		add("notifiers", false, "%v", err)
+	}
+	for i, notifier := range config.Notifiers {
+		// Slack and Discord webhook URLs are credentials themselves
+		webhookURL := notifier.Type == "slack" || notifier.Type == "discord"
+		secrets := append(secretProblems("token", notifier.Token, true), secretProblems("password", notifier.Password, true)...)
+		for _, problem := range append(secrets, secretProblems("url", notifier.URL, webhookURL)...) {
+			problem.Section = fmt.Sprintf("notifier %d (%s)", i, notifier.Type)
+			problems = append(problems, problem)
+		}
+	}
+	problems = append(problems, profileProblems(config)...)
+	problems = append(problems, groupProblems(config)...)
+	for _, name := range slices.Sorted(maps.Keys(unknownKeys)) {
+		addUnknownKeys(name, name)
+	}
+	return append(problems, includeProblems...), nil
+}
+
+// profileProblems checks every [profiles] section, not only the one applied here
+func profileProblems(config *Config) []Problem {
+	var problems []Problem
+	base := config.withoutPreset().withoutHostProfile()
+	for _, name := range slices.Sorted(maps.Keys(config.Profiles)) {
+		profile := config.Profiles[name]
+		add := func(warning bool, format string, args ...any) {
+			problems = append(problems, Problem{Section: "profiles." + name, Message: fmt.Sprintf(format, args...), Warning: warning})
+		}
+
+		global := base.Global
+		if err := decodeGlobal(profile.Global, &global); err != nil {
+			add(false, "%v", err)
+		} else if err := validateGlobalLimits(global); err != nil {
+			add(false, "%v", err)
+		} else if err := validateGlobalOptions(global); err != nil {
+			add(false, "%v", err)
+		}
+		for _, pattern := range profile.Hosts {
+			if _, err := path.Match(pattern, ""); err != nil {
+				add(false, "invalid hosts pattern '%s'", pattern)
+			}
+		}
+		for _, pattern := range profile.Repositories {
+			if validatePattern(pattern) != nil {
+				add(false, "invalid repositories pattern '%s'", pattern)
+				continue
+			}
+			one := HostProfile{Repositories: []string{pattern}}
+			if !slices.ContainsFunc(base.Repositories, func(repo RepoConfig) bool { return one.syncs(repo.Path) }) {
+				add(true, "'%s' matches no configured repository", pattern)
+			}
+		}
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		if names := hostProfilesMatching(config.Profiles, hostname); len(names) > 1 {
+			problems = append(problems, Problem{
+				Section: "profiles",
+				Message: fmt.Sprintf("%s all match this host (%s), only %s applies", strings.Join(names, ", "), hostname, names[0]),
+				Warning: true,
+				Local:   true,
+			})
+		}
+	}
+	return problems
+}
+
+// groupProblems checks the [groups] sections no repository uses, whose
+// settings the repository checks don't cover
+func groupProblems(config *Config) []Problem {
+	var problems []Problem
+	for _, name := range slices.Sorted(maps.Keys(config.Groups)) {
+		if slices.ContainsFunc(config.Repositories, func(repo RepoConfig) bool { return strings.EqualFold(repo.Group, name) }) {
+			continue
+		}
+		section := "groups." + name
+		problems = append(problems, Problem{Section: section, Message: "no repository is in the group", Warning: true})
+		var repo RepoConfig
+		if err := decodeGroup(name, config.Groups[name], &repo); err != nil {
+			problems = append(problems, Problem{Section: section, Message: err.Error()})
+		} else if err := validateRepoOptions(repo); err != nil {
+			problems = append(problems, Problem{Section: section, Message: err.Error()})
+		}
+	}
+	return problems
+}
+
+// findUnknownKeys decodes the settings of v strictly, returning the keys no
+// field takes by the section holding them, "" for the top level
+func findUnknownKeys(v *viper.Viper) map[string]string {
+	unknownKeys := map[string]string{}
+	var strict Config
+	if err := v.Unmarshal(&strict, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "toml"
+		dc.ErrorUnused = true
+	}); err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			if match := unknownKeysPattern.FindStringSubmatch(line); match != nil {
+				unknownKeys[match[1]] = match[2]
+			}
+		}
+	}
+	return unknownKeys
+}
+
+// displayName shows path relative to the directory of the config when inside it
+func displayName(configPath, path string) string {
+	if rel, err := filepath.Rel(filepath.Dir(configPath), path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// repoProblems checks one repository, leaving Section unset
+func repoProblems(repo RepoConfig) []Problem {
+	var problems []Problem
+	add := func(warning bool, format string, args ...any) {
+		problems = append(problems, Problem{Message: fmt.Sprintf(format, args...), Warning: warning})
+	}
+
+	if err := validateRepoBasics(repo); err != nil {
+		add(false, "%v", err)
+	}
+	if err := validateRepoOptions(repo); err != nil {
+		add(false, "%v", err)
+	}
+	if repo.Path != "" {
+		if match := unsetVariablePattern.FindStringSubmatch(repo.Path); match != nil {
+			problems = append(problems, Problem{Message: fmt.Sprintf("path uses $%s, which is not set", match[1]), Local: true})
+		} else if !filepath.IsAbs(repo.Path) {
+			add(false, "path must be absolute")
+		} else if IsPattern(repo.Path) {
+			if validatePattern(repo.Path) == nil && len(MatchPattern(repo.Path)) == 0 {
+				problems = append(problems, Problem{Message: "path matches no git repository", Warning: true, Local: true})
+			}
+		} else if err := CheckRepoPath(repo.Path); err != nil {
+			problems = append(problems, Problem{Message: err.Error(), Local: true})
+		}
+	}
+	if repo.Remote == "" {
+		add(false, "remote is not set")
+	}
+	problems = append(problems, secretProblems("http_token", repo.HTTPToken, true)...)
+	problems = append(problems, secretProblems("ssh_key_passphrase", repo.SSHKeyPassphrase, true)...)
+	problems = append(problems, secretProblems("webhook_secret", repo.WebhookSecret, false)...)
+	problems = append(problems, secretProblems("incoming_webhook_secret", repo.IncomingWebhookSecret, false)...)
+	if repo.SSHKey != "" {
+		if _, err := os.Stat(repo.SSHKey); err != nil {
+			problems = append(problems, Problem{Message: "ssh_key does not exist", Local: true})
+		}
+	}
+
+	switch repo.BranchStrategy {
+	case "current", "main", "all":
+		if repo.TargetBranch != "" {
+			add(true, "target_branch is ignored unless branch_strategy is 'specific'")
+		}
+	case "specific":
+		if repo.TargetBranch == "" {
+			add(false, "branch_strategy 'specific' needs target_branch")
+		}
+	case "":
+		add(false, "branch_strategy is not set")
+	default:
+		add(false, "invalid branch_strategy '%s': must be current, main, all, or specific", repo.BranchStrategy)
+	}
+
+	// Settings of the other direction
+	if repo.Direction == "pull" {
+		if repo.ForcePush {
+			add(true, "force_push has no effect with direction 'pull'")
+		}
+		if len(repo.PushRefSpecs) > 0 {
+			add(true, "push_refspecs have no effect with direction 'pull'")
+		}
+	}
+	if repo.Direction == "push" {
+		if len(repo.FetchRefSpecs) > 0 {
+			add(true, "fetch_refspecs have no effect with direction 'push'")
+		}
+		if len(repo.UnionMergePatterns) > 0 {
+			add(true, "union_merge_patterns have no effect with direction 'push'")
+		}
+	}
+	if !repo.SafetyChecks {
+		if repo.DirtyWorktreeAction != "" && repo.DirtyWorktreeAction != "skip" {
+			add(true, "dirty_worktree_action '%s' has no effect without safety_checks", repo.DirtyWorktreeAction)
+		}
+		if repo.ForcePush && repo.Direction != "pull" {
+			add(true, "force_push without safety_checks can overwrite remote changes")
+		}
+	}
+	if repo.Notify != "" && repo.Notifications != nil {
+		add(true, "notifications is ignored when notify is set")
+	}
+	if repo.Interval > 0 && repo.Interval < 30 && repo.Schedule == "" {
+		add(true, "interval of %ds is below the 30 seconds init allows, and loads the remote", repo.Interval)
+	}
+	return problems
+}
+
+// secretProblems checks that the ${secret:...} references of a setting resolve
+// on this machine, and warns about credentials written out in plain text
+func secretProblems(key, value string, credential bool) []Problem {
+	if value == "" {
+		return nil
+	}
+	if !secretref.HasReference(value) {
+		if credential {
+			return []Problem{{Message: key + " is stored in plain text, use a ${secret:...} reference", Warning: true}}
+		}
+		return nil
+	}
+	if secretref.Validate(value) != nil {
+		// Reported with the other invalid values
+		return nil
+	}
+	if _, err := secretref.Resolve(value); err != nil {
+		return []Problem{{Message: fmt.Sprintf("%s: %v", key, err), Local: true}}
+	}
+	return nil
+}
+
+// CheckRepoPath reports why path can't be synced: it is missing, not a
+// directory or not a git repository, with a worktree or bare
+func CheckRepoPath(path string) error {
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return errors.New("path does not exist")
+	case err != nil:
+		return fmt.Errorf("path is not accessible: %w", err)
+	case !info.IsDir():
+		return errors.New("path is not a directory")
+	}
+	if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+		return nil
+	}
+	// Bare repositories keep HEAD at the top level
+	if _, err := os.Stat(filepath.Join(path, "HEAD")); err == nil {
+		return nil
+	}
+	return errors.New("not a git repository")
+}
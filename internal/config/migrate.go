@@ -0,0 +1,75 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/viper"
+)
+
+// fileVersion returns the config_version of the config file read into v, 0
+// for files from before versioning. It fails on versions this git-sync
+// doesn't know, whose settings it could misread.
+func fileVersion(v *viper.Viper) (int, error) {
+	version := 0
+	switch value := v.Get("config_version").(type) {
+	case nil:
+	case int:
+		version = value
+	case int64:
+		version = int(value)
+	default:
+		return 0, fmt.Errorf("invalid config_version %v", value)
+	}
+	if version < 0 {
+		return 0, fmt.Errorf("invalid config_version %d", version)
+	}
+	if version > SchemaVersion {
+		return 0, fmt.Errorf("config_version %d is newer than this git-sync supports (%d), update git-sync to use this config", version, SchemaVersion)
+	}
+	return version, nil
+}
+
+// migrateFile brings the config file at path, read into v, to SchemaVersion
+// when its config_version is older. The file is first copied to
+// path.bak-<version>, unless an earlier run already did, then rewritten with
+// the migrations run and read into v again.
+func migrateFile(v *viper.Viper, path string) error {
+	version, err := fileVersion(v)
+	if err != nil || version == SchemaVersion {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.bak-%d", path, version)
+	if _, err := os.Stat(backup); errors.Is(err, os.ErrNotExist) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := writeFileAtomic(backup, data); err != nil {
+			return fmt.Errorf("failed to back up config before migrating it: %w", err)
+		}
+	}
+
+	// The file alone, as the defaults are written back after loading
+	fv, err := readPlain(path)
+	if err != nil {
+		return err
+	}
+	settings := fv.AllSettings()
+	for _, migrate := range migrations[version:] {
+		migrate(settings)
+	}
+	settings["config_version"] = SchemaVersion
+	if err := writeSettings(path, settings); err != nil {
+		return fmt.Errorf("failed to write migrated config: %w", err)
+	}
+	slog.Info("Migrated config file", "from", version, "to", SchemaVersion, "backup", backup)
+
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read migrated config: %w", err)
+	}
+	return nil
+}
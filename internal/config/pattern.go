@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+// IsPattern reports whether a repository path is a glob, standing for the repositories it matches
+func IsPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// validatePattern checks the syntax of a repository path glob. Match stops
+// checking once the name fails to match, so each element is matched both
+// against nothing and against itself.
+func validatePattern(pattern string) error {
+	for _, elem := range strings.Split(filepath.ToSlash(pattern), "/") {
+		_, err := filepath.Match(elem, "")
+		if err == nil {
+			_, err = filepath.Match(elem, elem)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid path pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// MatchPattern returns the git repositories matching a repository path glob, sorted
+func MatchPattern(pattern string) []string {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil
+	}
+	var repos []string
+	for _, match := range matches {
+		if CheckRepoPath(match) == nil {
+			repos = append(repos, paths.NormalizeRepo(match))
+		}
+	}
+	return repos
+}
+
+// ExpandPatterns returns the config with every repository whose path is a
+// glob replaced by the repositories it matches now, each with its settings.
+// A repository with an entry of its own, or matched by an earlier glob, keeps
+// that one. The daemon expands the config each time it loads it, which picks
+// up repositories created since.
+func (c *Config) ExpandPatterns() *Config {
+	if !slices.ContainsFunc(c.Repositories, func(repo RepoConfig) bool { return IsPattern(repo.Path) }) {
+		return c
+	}
+	configured := func(repos []RepoConfig, path string) bool {
+		return slices.ContainsFunc(repos, func(repo RepoConfig) bool {
+			return !IsPattern(repo.Path) && paths.SameRepo(repo.Path, path)
+		})
+	}
+
+	expanded := *c
+	expanded.Repositories = nil
+	for _, repo := range c.Repositories {
+		if !IsPattern(repo.Path) {
+			expanded.Repositories = append(expanded.Repositories, repo)
+			continue
+		}
+		for _, match := range MatchPattern(repo.Path) {
+			if configured(c.Repositories, match) || configured(expanded.Repositories, match) {
+				continue
+			}
+			entry := repo
+			entry.Path = match
+			entry.Pattern = repo.Path
+			expanded.Repositories = append(expanded.Repositories, entry)
+		}
+	}
+	return &expanded
+}
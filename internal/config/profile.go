@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/bnema/git-sync/internal/logging"
+)
+
+const (
+	// ProfileLowPower trades sync latency for less CPU, memory and I/O, for
+	// routers and single-board computers syncing a couple of repositories
+	ProfileLowPower = "low-power"
+
+	// lowPowerMinInterval is the shortest interval, in seconds, under the low-power profile
+	lowPowerMinInterval = 1800
+)
+
+// LowPower reports whether the low-power profile is selected
+func (g GlobalConfig) LowPower() bool {
+	return g.Profile == ProfileLowPower
+}
+
+func validateProfile(profile string) error {
+	switch profile {
+	case "", "default", ProfileLowPower:
+		return nil
+	}
+	return fmt.Errorf("invalid profile '%s': must be 'default' or '%s'", profile, ProfileLowPower)
+}
+
+// appliedPreset is what the low-power profile changed in a loaded config, by
+// the settings before and after, undone when it is saved
+type appliedPreset struct {
+	before, after GlobalConfig
+	repos         map[string][2]repoIntervals // by repository path
+	sets          map[string][2]int           // intervals by sync set name
+}
+
+// repoIntervals are the intervals of a repository the low-power profile raises
+type repoIntervals struct {
+	interval, pull, push int
+}
+
+func intervalsOf(repo RepoConfig) repoIntervals {
+	return repoIntervals{repo.Interval, repo.PullInterval, repo.PushInterval}
+}
+
+// applyProfile adjusts the loaded settings to the selected profile. The
+// adjustments are not written back to the config file, so switching the profile
+// off restores the configured values.
+func applyProfile(config *Config) {
+	if !config.Global.LowPower() {
+		return
+	}
+	preset := &appliedPreset{
+		before: config.Global,
+		repos:  make(map[string][2]repoIntervals),
+		sets:   make(map[string][2]int),
+	}
+
+	global := &config.Global
+	global.MaxConcurrentSyncs = 1
+	global.MaxIOHeavySyncs = 1
+	global.MaxLightSyncs = 1
+	global.DefaultInterval = max(global.DefaultInterval, lowPowerMinInterval)
+	// Only warnings and errors are logged, unless the config asks for even less
+	if level, err := logging.ParseLevel(global.LogLevel); err != nil || level < slog.LevelWarn {
+		global.LogLevel = "warn"
+	}
+
+	preset.after = *global
+
+	for i := range config.Repositories {
+		repo := &config.Repositories[i]
+		before := intervalsOf(*repo)
+		for _, interval := range []*int{&repo.Interval, &repo.PullInterval, &repo.PushInterval} {
+			if *interval > 0 {
+				*interval = max(*interval, lowPowerMinInterval)
+			}
+		}
+		preset.repos[repo.Path] = [2]repoIntervals{before, intervalsOf(*repo)}
+	}
+	for i := range config.SyncSets {
+		set := &config.SyncSets[i]
+		before := set.Interval
+		if set.Interval > 0 {
+			set.Interval = max(set.Interval, lowPowerMinInterval)
+		}
+		preset.sets[set.Name] = [2]int{before, set.Interval}
+	}
+	config.preset = preset
+}
+
+// withoutPreset returns the config with the low-power adjustments undone,
+// keeping the settings that were changed since it was loaded
+func (c *Config) withoutPreset() *Config {
+	preset := c.preset
+	if preset == nil {
+		return c
+	}
+	undone := *c
+	undone.preset = nil
+
+	global := &undone.Global
+	if global.MaxConcurrentSyncs == preset.after.MaxConcurrentSyncs {
+		global.MaxConcurrentSyncs = preset.before.MaxConcurrentSyncs
+	}
+	if global.MaxIOHeavySyncs == preset.after.MaxIOHeavySyncs {
+		global.MaxIOHeavySyncs = preset.before.MaxIOHeavySyncs
+	}
+	if global.MaxLightSyncs == preset.after.MaxLightSyncs {
+		global.MaxLightSyncs = preset.before.MaxLightSyncs
+	}
+	if global.DefaultInterval == preset.after.DefaultInterval {
+		global.DefaultInterval = preset.before.DefaultInterval
+	}
+	if global.LogLevel == preset.after.LogLevel {
+		global.LogLevel = preset.before.LogLevel
+	}
+
+	undone.Repositories = slices.Clone(c.Repositories)
+	for i := range undone.Repositories {
+		repo := &undone.Repositories[i]
+		entry, ok := preset.repos[repo.Path]
+		if !ok {
+			continue
+		}
+		before, after := entry[0], entry[1]
+		if repo.Interval == after.interval {
+			repo.Interval = before.interval
+		}
+		if repo.PullInterval == after.pull {
+			repo.PullInterval = before.pull
+		}
+		if repo.PushInterval == after.push {
+			repo.PushInterval = before.push
+		}
+	}
+	undone.SyncSets = slices.Clone(c.SyncSets)
+	for i := range undone.SyncSets {
+		set := &undone.SyncSets[i]
+		if entry, ok := preset.sets[set.Name]; ok && set.Interval == entry[1] {
+			set.Interval = entry[0]
+		}
+	}
+	return &undone
+}
@@ -0,0 +1,122 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/gitfixture"
+)
+
+// The low-power adjustments apply to the loaded config only: saving it writes
+// back the configured values, in [global] and in the host profile
+func TestLowPowerProfileIsNotSaved(t *testing.T) {
+	f := gitfixture.New(t)
+	notes := f.Clone("notes")
+
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{
+			name: "global",
+			config: `
+[global]
+profile = "low-power"
+default_interval = 300
+max_concurrent_syncs = 4
+log_level = "info"
+`,
+		},
+		{
+			name: "host profile",
+			config: `
+[global]
+default_interval = 300
+max_concurrent_syncs = 4
+log_level = "info"
+
+[profiles.router]
+hosts = ["*"]
+
+[profiles.router.global]
+profile = "low-power"
+default_interval = 600
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			repo := "\n[[repositories]]\npath = '" + notes.Path + "'\nenabled = true\ndirection = 'both'\nremote = 'origin'\ninterval = 60\n"
+			if err := os.WriteFile(path, []byte(tt.config+repo), 0644); err != nil {
+				t.Fatal(err)
+			}
+			before := readTOML(t, path)
+
+			cfg, err := config.LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if cfg.Global.MaxConcurrentSyncs != 1 || cfg.Global.DefaultInterval < 1800 || cfg.Repositories[0].Interval < 1800 {
+				t.Fatalf("low-power profile not applied: max_concurrent_syncs %d, default_interval %d, interval %d",
+					cfg.Global.MaxConcurrentSyncs, cfg.Global.DefaultInterval, cfg.Repositories[0].Interval)
+			}
+			if err := config.SaveConfig(cfg, path); err != nil {
+				t.Fatalf("SaveConfig: %v", err)
+			}
+
+			after := readTOML(t, path)
+			for _, key := range []string{"default_interval", "max_concurrent_syncs", "log_level"} {
+				if want, got := lookup(before, "global", key), lookup(after, "global", key); got != want {
+					t.Errorf("global.%s saved as %v, want %v", key, got, want)
+				}
+			}
+			if want, got := lookup(before, "profiles", "router", "global"), lookup(after, "profiles", "router", "global"); !equalTOML(got, want) {
+				t.Errorf("profiles.router.global saved as %v, want %v", got, want)
+			}
+			repos, _ := after["repositories"].([]any)
+			if len(repos) != 1 {
+				t.Fatalf("saved %d repositories, want 1", len(repos))
+			}
+			if got := repos[0].(map[string]any)["interval"]; got != int64(60) {
+				t.Errorf("repository interval saved as %v, want 60", got)
+			}
+		})
+	}
+}
+
+func readTOML(t *testing.T, path string) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var settings map[string]any
+	if err := toml.Unmarshal(data, &settings); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return settings
+}
+
+// lookup returns the value at keys in nested tables, nil when missing
+func lookup(settings map[string]any, keys ...string) any {
+	var value any = settings
+	for _, key := range keys {
+		table, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = table[key]
+	}
+	return value
+}
+
+func equalTOML(a, b any) bool {
+	encodedA, errA := toml.Marshal(map[string]any{"v": a})
+	encodedB, errB := toml.Marshal(map[string]any{"v": b})
+	return errA == nil && errB == nil && string(encodedA) == string(encodedB)
+}
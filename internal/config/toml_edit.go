@@ -0,0 +1,640 @@
+package config
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// errUneditable is returned by editTOML for files it can't edit in place
+var errUneditable = errors.New("the file can't be edited in place")
+
+// identityKeys tell which [[array table]] of a file an entry of the settings is
+var identityKeys = []string{"path", "name", "url"}
+
+// bareKeyPattern matches the keys TOML allows unquoted
+var bareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlBlock is the top level of a TOML file, a [table] or an [[array table]]
+type tomlBlock struct {
+	path    []string // lowercased; empty for the top level
+	array   bool
+	index   int // among the [[array table]]s of its path
+	start   int // of its header, or of the comment lines right above it
+	body    int // after its header line
+	end     int // where the next block starts
+	entries []tomlEntry
+}
+
+// tomlEntry is a key-value of a block
+type tomlEntry struct {
+	key        []string // lowercased, a dotted key split
+	indent     string
+	start, end int // its lines, with the comment lines right above it
+	valueStart int
+	valueEnd   int
+}
+
+// tomlEdit replaces data[start:end] with text
+type tomlEdit struct {
+	start, end int
+	text       string
+}
+
+// tomlEditor works out the edits turning a TOML file into settings
+type tomlEditor struct {
+	data    []byte
+	blocks  []*tomlBlock
+	current map[string]any // the file decoded
+	edits   []tomlEdit
+	tail    strings.Builder // new tables, added at the end of the file
+}
+
+// editTOML returns data, a TOML file, changed to hold settings. Key-values
+// whose value changed are rewritten in place, those no longer set removed and
+// new ones added to their table, so comments, key order and formatting stay
+// as they were. [[array table]]s are told apart by their path, name or url.
+// It fails with errUneditable on layouts it can't edit safely.
+func editTOML(data []byte, settings map[string]any) ([]byte, error) {
+	target, err := normalizeTOML(settings)
+	if err != nil {
+		return nil, err
+	}
+	e := &tomlEditor{data: data, current: map[string]any{}}
+	if err := toml.Unmarshal(data, &e.current); err != nil {
+		return nil, err
+	}
+	if e.blocks, err = parseTOMLBlocks(data); err != nil {
+		return nil, err
+	}
+
+	// The [[array table]]s of each path, matched with the entries of the settings
+	arrays := map[string][]*tomlBlock{}
+	for _, block := range e.blocks[1:] {
+		for i := 1; i < len(block.path); i++ {
+			if _, ok := arrays[strings.Join(block.path[:i], ".")]; ok {
+				// Tables inside array tables belong to their last entry
+				return nil, errUneditable
+			}
+		}
+		if block.array {
+			key := strings.Join(block.path, ".")
+			arrays[key] = append(arrays[key], block)
+		}
+	}
+	matched := map[*tomlBlock]map[string]any{}
+	for _, blocks := range arrays {
+		path := blocks[0].path
+		wanted, _ := lookupPath(target, path).([]any)
+		have, _ := lookupPath(e.current, path).([]any)
+		matches, err := matchArrayTables(have, wanted)
+		if err != nil {
+			return nil, err
+		}
+		last := -1
+		for i, block := range blocks {
+			if j := matches[i]; j >= 0 {
+				matched[block], _ = wanted[j].(map[string]any)
+				last = j
+			}
+		}
+		// Entries the file doesn't have follow its last array table of the path
+		var added strings.Builder
+		for j := last + 1; j < len(wanted); j++ {
+			if slices.Contains(matches, j) {
+				continue
+			}
+			table, _ := wanted[j].(map[string]any)
+			if err := encodeTable(&added, path, table, true); err != nil {
+				return nil, err
+			}
+		}
+		for j := range last {
+			if !slices.Contains(matches, j) {
+				// Entries added in between would change the order
+				return nil, errUneditable
+			}
+		}
+		if added.Len() > 0 {
+			e.insertBlocks(blocks[len(blocks)-1].end, added.String())
+		}
+	}
+
+	for i, block := range e.blocks {
+		var want, have map[string]any
+		switch {
+		case i == 0:
+			want, have = target, e.current
+		case block.array:
+			want = matched[block]
+			list, _ := lookupPath(e.current, block.path).([]any)
+			have, _ = list[block.index].(map[string]any)
+		default:
+			want, _ = lookupPath(target, block.path).(map[string]any)
+			have, _ = lookupPath(e.current, block.path).(map[string]any)
+		}
+		if want == nil {
+			e.deleteBlock(block)
+			continue
+		}
+		if err := e.editBlock(block, have, want); err != nil {
+			return nil, err
+		}
+	}
+
+	edited := e.apply()
+	if e.tail.Len() > 0 {
+		if len(edited) > 0 && edited[len(edited)-1] != '\n' {
+			edited = append(edited, '\n')
+		}
+		edited = append(edited, e.tail.String()...)
+	}
+
+	// Whatever went wrong, the edited file must decode to the settings
+	check := map[string]any{}
+	if err := toml.Unmarshal(edited, &check); err != nil || !reflect.DeepEqual(check, target) {
+		return nil, errUneditable
+	}
+	return edited, nil
+}
+
+// editBlock rewrites the key-values of block found in want, holding have, and
+// adds those it doesn't have
+func (e *tomlEditor) editBlock(block *tomlBlock, have, want map[string]any) error {
+	for _, entry := range block.entries {
+		value := lookupPath(want, entry.key)
+		if value == nil {
+			e.edits = append(e.edits, tomlEdit{start: entry.start, end: entry.end})
+			continue
+		}
+		old, err := encodeValue(lookupPath(have, entry.key))
+		if err != nil {
+			return err
+		}
+		text, err := encodeValue(value)
+		if err != nil {
+			return err
+		}
+		if text != old {
+			e.edits = append(e.edits, tomlEdit{start: entry.valueStart, end: entry.valueEnd, text: text})
+		}
+	}
+
+	lines, err := e.missing(block, nil, want)
+	if err != nil || len(lines) == 0 {
+		return err
+	}
+	at, indent := block.body, ""
+	if n := len(block.entries); n > 0 {
+		at, indent = block.entries[n-1].end, block.entries[n-1].indent
+	}
+	var text strings.Builder
+	if at > 0 && e.data[at-1] != '\n' {
+		text.WriteByte('\n')
+	}
+	for _, line := range lines {
+		text.WriteString(indent + line + "\n")
+	}
+	e.edits = append(e.edits, tomlEdit{start: at, end: at, text: text.String()})
+	return nil
+}
+
+// missing returns the key-value lines of want, below the dotted key prefix of
+// block, that the file doesn't have. Tables it doesn't have go to the tail.
+func (e *tomlEditor) missing(block *tomlBlock, prefix []string, want map[string]any) ([]string, error) {
+	var lines []string
+	for _, key := range slices.Sorted(maps.Keys(want)) {
+		full := append(slices.Clone(prefix), key)
+		path := append(slices.Clone(block.path), full...)
+		value := want[key]
+		table, isTable := value.(map[string]any)
+
+		switch {
+		case block.hasEntry(full, false):
+			continue
+		case block.hasEntry(full, true):
+			// Dotted keys below this one
+			if isTable {
+				more, err := e.missing(block, full, table)
+				if err != nil {
+					return nil, err
+				}
+				lines = append(lines, more...)
+			}
+			continue
+		case e.hasBlock(path, true):
+			list, isList := value.([]any)
+			switch {
+			case isList && len(list) == 0:
+				// Every array table of the path was removed
+				lines = append(lines, encodeKey(full)+" = []")
+			case isTable && !e.hasBlock(path, false):
+				// Only tables below this one have a header
+				sub := &tomlBlock{path: path}
+				more, err := e.missing(sub, nil, table)
+				if err != nil {
+					return nil, err
+				}
+				if len(more) > 0 {
+					e.tail.WriteString("\n[" + encodeKey(path) + "]\n" + strings.Join(more, "\n") + "\n")
+				}
+			}
+			continue
+		case len(prefix) == 0 && (isTable || isTableArray(value)):
+			var text strings.Builder
+			if err := encodeTables(&text, path, value); err != nil {
+				return nil, err
+			}
+			e.tail.WriteString(text.String())
+			continue
+		}
+
+		text, err := encodeValue(value)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, encodeKey(full)+" = "+text)
+	}
+	return lines, nil
+}
+
+// hasEntry reports whether block has a key-value for key, or below it when under is set
+func (b *tomlBlock) hasEntry(key []string, under bool) bool {
+	return slices.ContainsFunc(b.entries, func(entry tomlEntry) bool {
+		if under {
+			return len(entry.key) > len(key) && slices.Equal(entry.key[:len(key)], key)
+		}
+		return slices.Equal(entry.key, key)
+	})
+}
+
+// hasBlock reports whether the file has a table or array table at path, or below it when under is set
+func (e *tomlEditor) hasBlock(path []string, under bool) bool {
+	return slices.ContainsFunc(e.blocks[1:], func(block *tomlBlock) bool {
+		if under {
+			return len(block.path) >= len(path) && slices.Equal(block.path[:len(path)], path)
+		}
+		return slices.Equal(block.path, path)
+	})
+}
+
+// deleteBlock removes block, with the blank lines before it at the end of the file
+func (e *tomlEditor) deleteBlock(block *tomlBlock) {
+	start := block.start
+	if block.end == len(e.data) {
+		for start > 1 && e.data[start-1] == '\n' && e.data[start-2] == '\n' {
+			start--
+		}
+	}
+	e.edits = append(e.edits, tomlEdit{start: start, end: block.end})
+}
+
+// insertBlocks adds tables at offset at, set apart by blank lines
+func (e *tomlEditor) insertBlocks(at int, text string) {
+	if at < len(e.data) {
+		text = strings.TrimPrefix(text, "\n") + "\n"
+	} else if len(e.data) > 0 && e.data[len(e.data)-1] != '\n' {
+		text = "\n" + text
+	}
+	e.edits = append(e.edits, tomlEdit{start: at, end: at, text: text})
+}
+
+// apply returns the file with the edits made, in the order they were added where they meet
+func (e *tomlEditor) apply() []byte {
+	slices.SortStableFunc(e.edits, func(a, b tomlEdit) int { return a.start - b.start })
+	var out []byte
+	pos := 0
+	for _, edit := range e.edits {
+		if edit.start < pos {
+			// Overlapping edits, e.g. a key-value removed with its block
+			if edit.end > pos {
+				pos = edit.end
+			}
+			continue
+		}
+		out = append(out, e.data[pos:edit.start]...)
+		out = append(out, edit.text...)
+		pos = edit.end
+	}
+	return append(out, e.data[pos:]...)
+}
+
+// matchArrayTables returns the entry of wanted each of the array tables in
+// have is, or -1 for those to remove. Tables are matched by identity key, and
+// by position when neither has one; they must stay in order.
+func matchArrayTables(have, wanted []any) ([]int, error) {
+	matches := make([]int, len(have))
+	used := make([]bool, len(wanted))
+	for i, table := range have {
+		matches[i] = -1
+		id := tableIdentity(table)
+		for j, candidate := range wanted {
+			if !used[j] && id != "" && tableIdentity(candidate) == id {
+				matches[i], used[j] = j, true
+				break
+			}
+		}
+	}
+	for i, table := range have {
+		if matches[i] < 0 && i < len(wanted) && !used[i] && tableIdentity(table) == "" && tableIdentity(wanted[i]) == "" {
+			matches[i], used[i] = i, true
+		}
+	}
+	last := -1
+	for _, j := range matches {
+		if j >= 0 {
+			if j < last {
+				return nil, errUneditable
+			}
+			last = j
+		}
+	}
+	return matches, nil
+}
+
+// tableIdentity returns the first identity key of table with its value, "" without one
+func tableIdentity(table any) string {
+	fields, _ := table.(map[string]any)
+	for _, key := range identityKeys {
+		if value, ok := fields[key].(string); ok {
+			return key + "=" + value
+		}
+	}
+	return ""
+}
+
+// parseTOMLBlocks splits a TOML file into its blocks, locating their key-values
+func parseTOMLBlocks(data []byte) ([]*tomlBlock, error) {
+	var p unstable.Parser
+	p.KeepComments = true
+	p.Reset(data)
+
+	blocks := []*tomlBlock{{}}
+	current := blocks[0]
+	counts := map[string]int{}
+
+	var open *tomlEntry // the last key-value, which ends before the next expression
+	openComment := -1   // where the comment after its value starts
+	runStart, runEnd := -1, -1
+	closeEntry := func(next int) {
+		if open == nil {
+			return
+		}
+		limit := next
+		if openComment >= 0 {
+			limit = openComment
+		}
+		open.valueEnd = trimSpaceBefore(data, open.valueStart, limit)
+		open.end = lineEnd(data, max(open.valueEnd, openComment))
+		open, openComment = nil, -1
+	}
+	// leading returns where the expression starting its line at start begins,
+	// with the comment lines right above it
+	leading := func(start int) int {
+		if runStart >= 0 && runEnd == start {
+			start = runStart
+		}
+		runStart, runEnd = -1, -1
+		return start
+	}
+
+	for p.NextExpression() {
+		expr := p.Expression()
+		switch expr.Kind {
+		case unstable.Comment:
+			start := lineStart(data, int(expr.Raw.Offset))
+			closeEntry(int(expr.Raw.Offset))
+			if runEnd != start {
+				runStart = start
+			}
+			runEnd = lineEnd(data, int(expr.Raw.Offset))
+
+		case unstable.Table, unstable.ArrayTable:
+			key, first, last := keyParts(expr.Key())
+			start := lineStart(data, first)
+			closeEntry(start)
+			current.end = leading(start)
+			current = &tomlBlock{path: key, array: expr.Kind == unstable.ArrayTable, start: current.end, body: lineEnd(data, last)}
+			if current.array {
+				name := strings.Join(key, ".")
+				current.index = counts[name]
+				counts[name]++
+			}
+			blocks = append(blocks, current)
+
+		case unstable.KeyValue:
+			key, first, last := keyParts(expr.Key())
+			start := lineStart(data, first)
+			closeEntry(start)
+			valueStart := skipSpace(data, last)
+			if valueStart >= len(data) || data[valueStart] != '=' {
+				return nil, errUneditable
+			}
+			current.entries = append(current.entries, tomlEntry{
+				key:        key,
+				indent:     string(data[start:first]),
+				start:      leading(start),
+				valueStart: skipSpace(data, valueStart+1),
+			})
+			open = &current.entries[len(current.entries)-1]
+			if comment := expr.Next(); comment != nil && comment.Kind == unstable.Comment {
+				openComment = int(comment.Raw.Offset)
+			}
+		}
+	}
+	if err := p.Error(); err != nil {
+		return nil, err
+	}
+	closeEntry(len(data))
+	current.end = len(data)
+	return blocks, nil
+}
+
+// keyParts returns the lowercased parts of a key with where it starts and ends
+func keyParts(it unstable.Iterator) (parts []string, first, last int) {
+	first = -1
+	for it.Next() {
+		node := it.Node()
+		if first < 0 {
+			first = int(node.Raw.Offset)
+		}
+		last = int(node.Raw.Offset + node.Raw.Length)
+		parts = append(parts, strings.ToLower(string(node.Data)))
+	}
+	return parts, first, last
+}
+
+// lineStart returns the offset of the line holding offset
+func lineStart(data []byte, offset int) int {
+	for offset > 0 && data[offset-1] != '\n' {
+		offset--
+	}
+	return offset
+}
+
+// lineEnd returns the offset after the newline ending the line holding offset
+func lineEnd(data []byte, offset int) int {
+	for offset < len(data) && data[offset] != '\n' {
+		offset++
+	}
+	return min(offset+1, len(data))
+}
+
+// skipSpace returns the offset of the first character from offset that isn't a space or tab
+func skipSpace(data []byte, offset int) int {
+	for offset < len(data) && (data[offset] == ' ' || data[offset] == '\t') {
+		offset++
+	}
+	return offset
+}
+
+// trimSpaceBefore returns limit moved back over whitespace, but not before start
+func trimSpaceBefore(data []byte, start, limit int) int {
+	for limit > start && strings.ContainsRune(" \t\r\n", rune(data[limit-1])) {
+		limit--
+	}
+	return limit
+}
+
+// normalizeTOML returns settings as TOML decodes them, for comparison with a file
+func normalizeTOML(settings map[string]any) (map[string]any, error) {
+	data, err := toml.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	normalized := map[string]any{}
+	if err := toml.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// lookupPath finds the value at path in nested settings, nil when unset
+func lookupPath(settings map[string]any, path []string) any {
+	var value any = settings
+	for _, key := range path {
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = fields[key]
+	}
+	return value
+}
+
+// isTableArray reports whether value is written as [[array table]]s
+func isTableArray(value any) bool {
+	list, ok := value.([]any)
+	if !ok || len(list) == 0 {
+		return false
+	}
+	for _, item := range list {
+		if _, ok := item.(map[string]any); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeKey writes a dotted key, quoting the parts that need it
+func encodeKey(parts []string) string {
+	quoted := make([]string, len(parts))
+	for i, part := range parts {
+		quoted[i] = part
+		if !bareKeyPattern.MatchString(part) {
+			quoted[i], _ = encodeValue(part)
+		}
+	}
+	return strings.Join(quoted, ".")
+}
+
+// encodeValue writes a value as on the right of a TOML key-value
+func encodeValue(value any) (string, error) {
+	switch value := value.(type) {
+	case map[string]any:
+		var fields []string
+		for _, key := range slices.Sorted(maps.Keys(value)) {
+			text, err := encodeValue(value[key])
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, encodeKey([]string{key})+" = "+text)
+		}
+		if len(fields) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(fields, ", ") + " }", nil
+	case []any:
+		items := make([]string, len(value))
+		for i, item := range value {
+			text, err := encodeValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = text
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	}
+	data, err := toml.Marshal(map[string]any{"v": value})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(string(data), "v = "), "\n"), nil
+}
+
+// encodeTables writes value, a table or the tables of an array, as sections at path
+func encodeTables(b *strings.Builder, path []string, value any) error {
+	if table, ok := value.(map[string]any); ok {
+		return encodeTable(b, path, table, false)
+	}
+	list, _ := value.([]any)
+	for _, item := range list {
+		table, _ := item.(map[string]any)
+		if err := encodeTable(b, path, table, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTable writes a table as a section at path, preceded by a blank line,
+// with its key-values first and the tables it holds after. A table holding
+// only tables gets no header of its own.
+func encodeTable(b *strings.Builder, path []string, table map[string]any, array bool) error {
+	keys := slices.Sorted(maps.Keys(table))
+	var values, nested []string
+	for _, key := range keys {
+		value := table[key]
+		if _, ok := value.(map[string]any); ok || isTableArray(value) {
+			nested = append(nested, key)
+		} else {
+			values = append(values, key)
+		}
+	}
+	switch {
+	case array:
+		b.WriteString("\n[[" + encodeKey(path) + "]]\n")
+	case len(values) > 0 || len(nested) == 0:
+		b.WriteString("\n[" + encodeKey(path) + "]\n")
+	}
+	for _, key := range values {
+		value := table[key]
+		text, err := encodeValue(value)
+		if err != nil {
+			return err
+		}
+		b.WriteString(encodeKey([]string{key}) + " = " + text + "\n")
+	}
+	for _, key := range nested {
+		if err := encodeTables(b, append(slices.Clone(path), key), table[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+func TestEditTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		edit func(settings map[string]any)
+		want string
+		err  error
+	}{
+		{
+			name: "value changed in place",
+			file: `# git-sync configuration
+
+[global]
+# seconds between syncs
+default_interval = 300 # five minutes
+log_level = "info"
+max_concurrent_syncs = 4
+`,
+			edit: func(s map[string]any) {
+				global(s)["default_interval"] = int64(600)
+			},
+			want: `# git-sync configuration
+
+[global]
+# seconds between syncs
+default_interval = 600 # five minutes
+log_level = "info"
+max_concurrent_syncs = 4
+`,
+		},
+		{
+			name: "keys added and removed",
+			file: `[global]
+log_level = "info" # or debug
+# how many at once
+max_concurrent_syncs = 4
+default_interval = 300
+`,
+			edit: func(s map[string]any) {
+				delete(global(s), "max_concurrent_syncs")
+				global(s)["enable_notifications"] = true
+			},
+			want: `[global]
+log_level = "info" # or debug
+default_interval = 300
+enable_notifications = true
+`,
+		},
+		{
+			name: "repository added",
+			file: `[global]
+default_interval = 300
+
+# notes first
+[[repositories]]
+path = "/home/me/notes"
+interval = 60
+
+[[repositories]]
+path = "/home/me/dotfiles"
+
+[[notifiers]]
+type = "ntfy"
+url = "https://ntfy.sh/sync"
+`,
+			edit: func(s map[string]any) {
+				s["repositories"] = append(s["repositories"].([]any), map[string]any{"path": "/home/me/work", "enabled": true})
+			},
+			want: `[global]
+default_interval = 300
+
+# notes first
+[[repositories]]
+path = "/home/me/notes"
+interval = 60
+
+[[repositories]]
+path = "/home/me/dotfiles"
+
+[[repositories]]
+enabled = true
+path = '/home/me/work'
+
+[[notifiers]]
+type = "ntfy"
+url = "https://ntfy.sh/sync"
+`,
+		},
+		{
+			name: "repository removed",
+			file: `[[repositories]]
+path = "/home/me/notes"
+
+# dotfiles, synced both ways
+[[repositories]]
+path = "/home/me/dotfiles"
+direction = "both"
+
+[[repositories]]
+path = "/home/me/work" # the laptop's
+`,
+			edit: func(s map[string]any) {
+				repos := s["repositories"].([]any)
+				s["repositories"] = []any{repos[0], repos[2]}
+			},
+			want: `[[repositories]]
+path = "/home/me/notes"
+
+[[repositories]]
+path = "/home/me/work" # the laptop's
+`,
+		},
+		{
+			name: "first notifier added",
+			file: `[global]
+enable_notifications = true
+`,
+			edit: func(s map[string]any) {
+				s["notifiers"] = []any{map[string]any{"type": "slack", "url": "${SLACK_WEBHOOK}"}}
+			},
+			want: `[global]
+enable_notifications = true
+
+[[notifiers]]
+type = 'slack'
+url = '${SLACK_WEBHOOK}'
+`,
+		},
+		{
+			name: "last notifier removed",
+			file: `[global]
+enable_notifications = true
+
+[[notifiers]]
+type = "slack"
+url = "${SLACK_WEBHOOK}"
+`,
+			edit: func(s map[string]any) {
+				delete(s, "notifiers")
+			},
+			want: `[global]
+enable_notifications = true
+`,
+		},
+		{
+			name: "nested table",
+			file: `[global]
+default_interval = 300
+
+[profiles.router]
+hosts = ["router-*"] # the small ones
+
+# keep the battery
+[profiles.router.global]
+profile = "low-power"
+default_interval = 600
+`,
+			edit: func(s map[string]any) {
+				router := s["profiles"].(map[string]any)["router"].(map[string]any)
+				router["global"].(map[string]any)["default_interval"] = int64(900)
+				router["hosts"] = []any{"router-*", "nas"}
+			},
+			want: `[global]
+default_interval = 300
+
+[profiles.router]
+hosts = ['router-*', 'nas'] # the small ones
+
+# keep the battery
+[profiles.router.global]
+profile = "low-power"
+default_interval = 900
+`,
+		},
+		{
+			name: "nested table added",
+			file: `[global]
+default_interval = 300
+`,
+			edit: func(s map[string]any) {
+				s["profiles"] = map[string]any{"nas": map[string]any{"hosts": []any{"nas"}}}
+			},
+			want: `[global]
+default_interval = 300
+
+[profiles.nas]
+hosts = ['nas']
+`,
+		},
+		{
+			name: "repositories reordered",
+			file: `[[repositories]]
+path = "/home/me/notes"
+
+[[repositories]]
+path = "/home/me/dotfiles"
+`,
+			edit: func(s map[string]any) {
+				repos := s["repositories"].([]any)
+				s["repositories"] = []any{repos[1], repos[0]}
+			},
+			err: errUneditable,
+		},
+		{
+			name: "repository inserted before others",
+			file: `[[repositories]]
+path = "/home/me/notes"
+`,
+			edit: func(s map[string]any) {
+				s["repositories"] = append([]any{map[string]any{"path": "/home/me/work"}}, s["repositories"].([]any)...)
+			},
+			err: errUneditable,
+		},
+		{
+			name: "table inside an array table",
+			file: `[[repositories]]
+path = "/home/me/notes"
+
+[repositories.hooks]
+post_sync = "make"
+`,
+			edit: func(s map[string]any) {
+				repo := s["repositories"].([]any)[0].(map[string]any)
+				repo["hooks"].(map[string]any)["post_sync"] = "make all"
+			},
+			err: errUneditable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := map[string]any{}
+			if err := toml.Unmarshal([]byte(tt.file), &settings); err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(settings)
+
+			got, err := editTOML([]byte(tt.file), settings)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("editTOML error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("editTOML: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("editTOML =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func global(settings map[string]any) map[string]any {
+	return settings["global"].(map[string]any)
+}
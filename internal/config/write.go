@@ -0,0 +1,82 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeSettings writes decoded settings to the config file at path, in the
+// format of its extension. An existing TOML file is edited in place, keeping
+// its comments and key order, unless its layout doesn't allow it. Nothing is
+// written when the file already holds the settings.
+func writeSettings(path string, settings map[string]any) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	format := FileFormat(path)
+	var data []byte
+	if format == "toml" && len(bytes.TrimSpace(existing)) > 0 {
+		data, _ = editTOML(existing, settings)
+	}
+	if data == nil {
+		if data, err = encodeSettings(settings, format); err != nil {
+			return err
+		}
+	}
+	if bytes.Equal(data, existing) {
+		return nil
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic replaces the file at path with data through a temporary
+// file in the same directory, synced before it is renamed over the old one,
+// so a crash leaves either file but never half of one. A symlink is followed,
+// replacing the file it points to, and the mode of the old file is kept.
+func writeFileAtomic(path string, data []byte) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	// Dot-prefixed, so the watcher and drop-in directories skip it
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set mode of %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	// The rename only survives a crash once the directory is synced
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
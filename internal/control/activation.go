@@ -0,0 +1,38 @@
+package control
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// listenFDsStart is the first file descriptor systemd passes to socket-activated services
+const listenFDsStart = 3
+
+// activatedListener returns the control socket systemd passed in through socket
+// activation (git-sync-daemon.socket), or nil when the daemon was started
+// without one. The LISTEN_ variables are cleared so git and hooks don't inherit them.
+func activatedListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+
+	syscall.CloseOnExec(listenFDsStart)
+	file := os.NewFile(listenFDsStart, "control.sock")
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use the activated control socket: %w", err)
+	}
+	return listener, nil
+}
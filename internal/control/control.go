@@ -0,0 +1,254 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Request is a single command sent to the daemon over the control socket
+type Request struct {
+	Command string            `json:"command"`
+	Args    map[string]string `json:"args,omitempty"`
+}
+
+// Response is the daemon's answer to a Request
+type Response struct {
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// HandlerFunc handles a control request
+type HandlerFunc func(req Request) Response
+
+// ErrDaemonNotRunning is returned by Call when no daemon is listening on the socket
+var ErrDaemonNotRunning = errors.New("daemon is not running (control socket unavailable)")
+
+const clientTimeout = 5 * time.Second
+
+// Server listens on a Unix domain socket and dispatches JSON line requests
+// and plain-text queries
+type Server struct {
+	path      string
+	listener  net.Listener
+	activated bool // the socket belongs to systemd
+	handlers  map[string]HandlerFunc
+	queries   map[string]QueryFunc
+	logger    *slog.Logger
+	mu        sync.RWMutex
+	wg        sync.WaitGroup
+}
+
+// SocketPath returns the control socket path, preferring XDG_RUNTIME_DIR
+func SocketPath() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "git-sync", "control.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("git-sync-%d", os.Getuid()), "control.sock")
+}
+
+// NewServer creates a control server for the given socket path
+func NewServer(path string, logger *slog.Logger) *Server {
+	return &Server{
+		path:     path,
+		handlers: make(map[string]HandlerFunc),
+		queries:  make(map[string]QueryFunc),
+		logger:   logger,
+	}
+}
+
+// Handle registers a handler for a command
+func (s *Server) Handle(command string, handler HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[command] = handler
+}
+
+// Start begins accepting connections on the control socket, the one systemd
+// passed in when socket-activated
+func (s *Server) Start() error {
+	activated, err := activatedListener()
+	if err != nil {
+		return err
+	}
+	if activated != nil {
+		s.listener, s.activated = activated, true
+		s.wg.Add(1)
+		go s.acceptLoop()
+		s.logger.Info("Control socket listening", "path", s.path, "activated", true)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	// Remove a stale socket left behind by a crashed daemon
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	if err := os.Chmod(s.path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict control socket permissions: %w", err)
+	}
+	s.listener = listener
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	s.logger.Info("Control socket listening", "path", s.path)
+	return nil
+}
+
+// Stop closes the listener and waits for in-flight requests to finish
+func (s *Server) Stop() {
+	if s.listener == nil {
+		return
+	}
+	if err := s.listener.Close(); err != nil {
+		s.logger.Debug("Failed to close control socket", "error", err)
+	}
+	s.wg.Wait()
+	if s.activated {
+		return
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		s.logger.Debug("Failed to remove control socket", "error", err)
+	}
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.logger.Warn("Control socket accept failed", "error", err)
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(clientTimeout)); err != nil {
+		return
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		s.logger.Debug("Failed to read control request", "error", err)
+		return
+	}
+
+	if isQuery(line) {
+		answer := s.answerQuery(string(line))
+		if _, err := fmt.Fprintln(conn, answer); err != nil {
+			s.logger.Debug("Failed to write query answer", "error", err)
+		}
+		return
+	}
+
+	var req Request
+	var resp Response
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp = ErrorResponse(fmt.Errorf("invalid request: %w", err))
+	} else {
+		resp = s.dispatch(req)
+	}
+
+	// Handlers such as reload may outlast the read deadline
+	if err := conn.SetDeadline(time.Now().Add(clientTimeout)); err != nil {
+		return
+	}
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		s.logger.Debug("Failed to write control response", "error", err)
+	}
+}
+
+func (s *Server) dispatch(req Request) Response {
+	s.mu.RLock()
+	handler, exists := s.handlers[req.Command]
+	s.mu.RUnlock()
+
+	if !exists {
+		return ErrorResponse(fmt.Errorf("unknown command: %s", req.Command))
+	}
+
+	s.logger.Debug("Handling control request", "command", req.Command)
+	return handler(req)
+}
+
+// Call sends a request to the daemon and waits for its response
+func Call(req Request) (*Response, error) {
+	return CallTimeout(req, clientTimeout)
+}
+
+// CallTimeout is Call for commands that may take longer than usual to answer,
+// such as a reload waiting for syncs in progress
+func CallTimeout(req Request, timeout time.Duration) (*Response, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(), clientTimeout)
+	if err != nil {
+		return nil, ErrDaemonNotRunning
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if !resp.OK {
+		return &resp, errors.New(resp.Error)
+	}
+
+	return &resp, nil
+}
+
+// OKResponse builds a successful response with optional JSON-encoded data
+func OKResponse(data any) Response {
+	if data == nil {
+		return Response{OK: true}
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return ErrorResponse(fmt.Errorf("failed to encode response: %w", err))
+	}
+	return Response{OK: true, Data: raw}
+}
+
+// ErrorResponse builds a failed response
+func ErrorResponse(err error) Response {
+	return Response{OK: false, Error: err.Error()}
+}
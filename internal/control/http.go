@@ -0,0 +1,212 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AuthorizeFunc checks that an API token grants scope, returning the name of
+// its key. It returns ErrUnauthorized for unknown or expired tokens and
+// ErrForbidden for tokens lacking the scope.
+type AuthorizeFunc func(token, scope string) (string, error)
+
+// identityKey holds the key name of authorized requests in their context
+type identityKey struct{}
+
+// RequestIdentity returns the name of the API key a HandleRequest handler's
+// request was authorized with
+func RequestIdentity(r *http.Request) string {
+	name, _ := r.Context().Value(identityKey{}).(string)
+	return name
+}
+
+// BearerToken returns the bearer token of r's Authorization header, "" without one
+func BearerToken(r *http.Request) string {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == r.Header.Get("Authorization") {
+		return ""
+	}
+	return token
+}
+
+var (
+	// ErrUnauthorized rejects requests without a valid API token
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden rejects requests whose token lacks the required scope
+	ErrForbidden = errors.New("forbidden")
+)
+
+// HTTPServer serves the control commands exposed over HTTP at /api/v1/<command>,
+// each guarded by the scope an API token needs to call it
+type HTTPServer struct {
+	addr      string
+	control   *Server
+	scopes    map[string]string
+	routes    map[string]http.HandlerFunc
+	authorize AuthorizeFunc
+	logger    *slog.Logger
+	server    *http.Server
+}
+
+// NewHTTPServer creates an HTTP API listening on addr for commands handled by control
+func NewHTTPServer(addr string, control *Server, authorize AuthorizeFunc, logger *slog.Logger) *HTTPServer {
+	return &HTTPServer{
+		addr:      addr,
+		control:   control,
+		scopes:    make(map[string]string),
+		routes:    make(map[string]http.HandlerFunc),
+		authorize: authorize,
+		logger:    logger,
+	}
+}
+
+// Expose makes a control command reachable over HTTP for tokens granting scope.
+// Commands that aren't exposed are only available on the control socket.
+func (h *HTTPServer) Expose(command, scope string) {
+	h.scopes[command] = scope
+}
+
+// HandleOpen serves handler at pattern without authentication, for probes such
+// as /healthz that monitoring systems call without an API key
+func (h *HTTPServer) HandleOpen(pattern string, handler http.HandlerFunc) {
+	h.routes[pattern] = handler
+}
+
+// HandleRequest serves handler at pattern for tokens granting scope, for
+// requests that don't fit a control command's string arguments. Failed
+// responses are sent as 400. RequestIdentity tells the handler whose key the
+// request carries.
+func (h *HTTPServer) HandleRequest(pattern, scope string, handler func(*http.Request) Response) {
+	h.routes[pattern] = func(w http.ResponseWriter, r *http.Request) {
+		name, ok := h.authorizeRequest(w, r, scope)
+		if !ok {
+			return
+		}
+		resp := handler(r.WithContext(context.WithValue(r.Context(), identityKey{}, name)))
+		status := http.StatusOK
+		if !resp.OK {
+			status = http.StatusBadRequest
+		}
+		writeHTTPResponse(w, status, resp)
+	}
+}
+
+// Start begins serving the HTTP API
+func (h *HTTPServer) Start() error {
+	listener, err := net.Listen("tcp", h.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", h.addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/{command}", h.serveCommand)
+	for pattern, handler := range h.routes {
+		mux.HandleFunc(pattern, handler)
+	}
+	h.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: clientTimeout,
+	}
+
+	go func() {
+		if err := h.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			h.logger.Error("HTTP API stopped", "error", err)
+		}
+	}()
+
+	h.logger.Info("HTTP API listening", "addr", listener.Addr().String())
+	return nil
+}
+
+// Stop shuts the HTTP API down, waiting briefly for in-flight requests
+func (h *HTTPServer) Stop() {
+	if h.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.server.Shutdown(ctx); err != nil {
+		h.logger.Debug("Failed to shut down HTTP API", "error", err)
+	}
+}
+
+// serveCommand runs a command with arguments from the query string, or from a
+// JSON object body on POST
+func (h *HTTPServer) serveCommand(w http.ResponseWriter, r *http.Request) {
+	command := r.PathValue("command")
+	scope, exposed := h.scopes[command]
+	if !exposed {
+		writeHTTPResponse(w, http.StatusNotFound, ErrorResponse(fmt.Errorf("unknown command: %s", command)))
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		writeHTTPResponse(w, http.StatusMethodNotAllowed, ErrorResponse(fmt.Errorf("method %s not allowed", r.Method)))
+		return
+	}
+
+	if _, ok := h.authorizeRequest(w, r, scope); !ok {
+		return
+	}
+
+	req := Request{Command: command, Args: make(map[string]string)}
+	for name, values := range r.URL.Query() {
+		req.Args[name] = values[len(values)-1]
+	}
+	if r.Method == http.MethodPost && r.ContentLength != 0 {
+		var body map[string]string
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); err != nil {
+			writeHTTPResponse(w, http.StatusBadRequest, ErrorResponse(fmt.Errorf("invalid request body: %w", err)))
+			return
+		}
+		for name, value := range body {
+			req.Args[name] = value
+		}
+	}
+
+	resp := h.control.dispatch(req)
+	status := http.StatusOK
+	if !resp.OK {
+		status = http.StatusBadRequest
+	}
+	writeHTTPResponse(w, status, resp)
+}
+
+// authorizeRequest checks the request's bearer token for scope, returning the
+// name of its key, and answers the request itself when it is rejected
+func (h *HTTPServer) authorizeRequest(w http.ResponseWriter, r *http.Request, scope string) (string, bool) {
+	token := BearerToken(r)
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeHTTPResponse(w, http.StatusUnauthorized, ErrorResponse(errors.New("missing bearer token")))
+		return "", false
+	}
+	name, err := h.authorize(token, scope)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrUnauthorized):
+			status = http.StatusUnauthorized
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		case errors.Is(err, ErrForbidden):
+			status = http.StatusForbidden
+		}
+		h.logger.Debug("Rejected HTTP API request", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+		writeHTTPResponse(w, status, ErrorResponse(err))
+		return "", false
+	}
+	return name, true
+}
+
+func writeHTTPResponse(w http.ResponseWriter, status int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,74 @@
+package control
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// queryTimeout bounds a query round trip; prompts would rather show nothing than wait
+const queryTimeout = 500 * time.Millisecond
+
+// QueryFunc answers a plain-text query about the path it names with a single line
+type QueryFunc func(path string) (string, error)
+
+// HandleQuery registers a handler for a plain-text query verb such as STATUS
+func (s *Server) HandleQuery(verb string, handler QueryFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries[strings.ToUpper(verb)] = handler
+}
+
+// answerQuery answers a "VERB <path>" line with one line: the answer, or
+// "ERR <message>" when the query failed
+func (s *Server) answerQuery(line string) string {
+	verb, path, _ := strings.Cut(strings.TrimSpace(line), " ")
+	verb = strings.ToUpper(verb)
+
+	s.mu.RLock()
+	handler, exists := s.queries[verb]
+	s.mu.RUnlock()
+	if !exists {
+		return "ERR unknown query: " + verb
+	}
+
+	answer, err := handler(strings.TrimSpace(path))
+	if err != nil {
+		return "ERR " + err.Error()
+	}
+	return answer
+}
+
+// isQuery reports whether a request line is a plain-text query rather than JSON
+func isQuery(line []byte) bool {
+	trimmed := strings.TrimSpace(string(line))
+	return trimmed != "" && !strings.HasPrefix(trimmed, "{")
+}
+
+// Query sends a plain-text query to the daemon and returns its one-line answer
+func Query(verb, path string) (string, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(), queryTimeout)
+	if err != nil {
+		return "", ErrDaemonNotRunning
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(queryTimeout)); err != nil {
+		return "", err
+	}
+	if _, err := fmt.Fprintf(conn, "%s %s\n", verb, path); err != nil {
+		return "", fmt.Errorf("failed to send query: %w", err)
+	}
+	answer, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	answer = strings.TrimSuffix(answer, "\n")
+	if message, failed := strings.CutPrefix(answer, "ERR "); failed {
+		return "", errors.New(message)
+	}
+	return answer, nil
+}
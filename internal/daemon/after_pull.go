@@ -0,0 +1,118 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+const (
+	// afterPullTimeout bounds how long a run_after_pull command may take
+	afterPullTimeout = 10 * time.Minute
+
+	// maxChangedFilesEnv caps GIT_SYNC_CHANGED_FILES well below the kernel's per-variable limit
+	maxChangedFilesEnv = 64 * 1024
+)
+
+// HookError reports a run_after_pull command that failed after the pull itself
+// worked. The sync is recorded as done with the failure beside it, and isn't
+// retried or counted towards pausing the repository.
+type HookError struct {
+	Err error
+}
+
+func (e *HookError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// AsHookError reports whether err (or anything it wraps) is a HookError
+func AsHookError(err error) (*HookError, bool) {
+	var hookErr *HookError
+	if errors.As(err, &hookErr) {
+		return hookErr, true
+	}
+	return nil, false
+}
+
+// headBeforePull records the checked-out commit when a run_after_pull command is
+// configured for a pulling repository, and returns "" otherwise
+func (g *GitOperations) headBeforePull(ctx context.Context, repo configPkg.RepoConfig) string {
+	if repo.RunAfterPull == "" || repo.Direction == "push" {
+		return ""
+	}
+	head, err := runGit(ctx, repo.Path, append(gitConfigArgs(repo), "rev-parse", "HEAD")...)
+	if err != nil {
+		return ""
+	}
+	return head
+}
+
+// runAfterPull runs the repository's run_after_pull command through the shell
+// when the sync moved the checked-out commit away from oldHead. The command runs
+// in the worktree and learns what changed from GIT_SYNC_* environment variables.
+func (g *GitOperations) runAfterPull(ctx context.Context, repo configPkg.RepoConfig, oldHead string) error {
+	if oldHead == "" {
+		return nil
+	}
+
+	gitArgs := gitConfigArgs(repo)
+	newHead, err := runGit(ctx, repo.Path, append(gitArgs, "rev-parse", "HEAD")...)
+	if err != nil || newHead == oldHead {
+		return nil
+	}
+
+	changed, err := runGit(ctx, repo.Path, append(gitArgs, "diff", "--name-only", oldHead, newHead)...)
+	if err != nil {
+		return &HookError{Err: fmt.Errorf("run_after_pull failed to list files changed by pull: %w", err)}
+	}
+	truncated := "0"
+	if len(changed) > maxChangedFilesEnv {
+		changed = changed[:strings.LastIndex(changed[:maxChangedFilesEnv], "\n")+1]
+		truncated = "1"
+	}
+	branch, _ := runGit(ctx, repo.Path, append(gitArgs, "symbolic-ref", "-q", "--short", "HEAD")...)
+
+	ctx, cancel := context.WithTimeout(ctx, afterPullTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", repo.RunAfterPull)
+	cmd.Dir = repo.Path
+	// Children of a killed command may hold its output open
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(os.Environ(),
+		"GIT_SYNC_REPO="+repo.Path,
+		"GIT_SYNC_BRANCH="+branch,
+		"GIT_SYNC_OLD_HEAD="+oldHead,
+		"GIT_SYNC_NEW_HEAD="+newHead,
+		"GIT_SYNC_CHANGED_FILES="+strings.TrimSpace(changed),
+		"GIT_SYNC_CHANGED_FILES_TRUNCATED="+truncated,
+	)
+
+	g.logger.Info("Running after-pull command",
+		"repo", repo.Path,
+		"command", repo.RunAfterPull)
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		g.logger.Debug("After-pull command output",
+			"repo", repo.Path,
+			"output", strings.TrimSpace(string(output)))
+	}
+	if err != nil {
+		if msg := lastLines(string(output), 3); msg != "" {
+			return &HookError{Err: fmt.Errorf("run_after_pull failed: %w: %s", err, msg)}
+		}
+		return &HookError{Err: fmt.Errorf("run_after_pull failed: %w", err)}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package daemon
+
+import (
+	"context"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// Git backend names accepted by the git_backend setting
+const (
+	BackendGoGit = "go-git"
+	BackendGit   = "git"
+)
+
+// GitBackend performs the git work of a single repository sync
+type GitBackend interface {
+	// Name returns the git_backend value selecting this backend
+	Name() string
+	// SyncRepository syncs repo once; deliberately skipped syncs return a *SkipError
+	SyncRepository(ctx context.Context, repo configPkg.RepoConfig) error
+}
+
+// Name implements GitBackend
+func (g *GitOperations) Name() string {
+	return BackendGoGit
+}
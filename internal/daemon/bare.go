@@ -0,0 +1,84 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// syncBareRepository syncs a repository without a worktree. Pulls become fetches
+// that update local branches directly, pushes use the regular refspecs, and no
+// worktree safety checks or branch switching are performed.
+func (g *GitOperations) syncBareRepository(ctx context.Context, r *git.Repository, repo configPkg.RepoConfig) error {
+	g.logger.Debug("Bare repository, using fetch/push-only flow", "repo", repo.Path)
+
+	// The specific strategy cannot switch branches without a worktree,
+	// so push the target branch directly
+	if repo.BranchStrategy == "specific" && len(repo.PushRefSpecs) == 0 {
+		repo.PushRefSpecs = []string{fmt.Sprintf("refs/heads/%s:refs/heads/%s", repo.TargetBranch, repo.TargetBranch)}
+	}
+
+	switch repo.Direction {
+	case "push":
+		return g.gitPush(ctx, r, repo)
+	case "pull":
+		return g.gitFetchBare(ctx, r, repo)
+	case "both":
+		if err := g.gitFetchBare(ctx, r, repo); err != nil {
+			return fmt.Errorf("pull failed: %w", err)
+		}
+		return g.gitPush(ctx, r, repo)
+	default:
+		return fmt.Errorf("invalid direction: %s", repo.Direction)
+	}
+}
+
+// gitFetchBare fetches remote branches straight into the bare repository's local branches
+func (g *GitOperations) gitFetchBare(ctx context.Context, r *git.Repository, repo configPkg.RepoConfig) error {
+	if len(repo.FetchRefSpecs) == 0 {
+		refSpecs, err := g.bareFetchRefSpecs(ctx, r, repo)
+		if err != nil {
+			return err
+		}
+		repo.FetchRefSpecs = refSpecs
+	}
+	return g.gitFetch(ctx, r, repo)
+}
+
+// bareFetchRefSpecs maps the branch strategy onto refspecs updating local branches.
+// Refspecs are not forced, so local commits are never overwritten by a fetch.
+func (g *GitOperations) bareFetchRefSpecs(ctx context.Context, r *git.Repository, repo configPkg.RepoConfig) ([]string, error) {
+	switch repo.BranchStrategy {
+	case "current":
+		head, err := r.Head()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current branch: %w", err)
+		}
+		branch := head.Name().Short()
+		return []string{fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)}, nil
+	case "main":
+		branch := g.defaultBranch(ctx, r, repo)
+		return []string{fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)}, nil
+	case "specific":
+		return []string{fmt.Sprintf("refs/heads/%s:refs/heads/%s", repo.TargetBranch, repo.TargetBranch)}, nil
+	case "all":
+		// Mirrors already configure their own fetch refspecs, keep those
+		remote, err := r.Remote(repo.Remote)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get remote '%s': %w", repo.Remote, err)
+		}
+		if fetchSpecs := remote.Config().Fetch; len(fetchSpecs) > 0 {
+			specs := make([]string, 0, len(fetchSpecs))
+			for _, spec := range fetchSpecs {
+				specs = append(specs, spec.String())
+			}
+			return specs, nil
+		}
+		return []string{"refs/heads/*:refs/heads/*"}, nil
+	default:
+		return nil, fmt.Errorf("invalid branch strategy: %s", repo.BranchStrategy)
+	}
+}
@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/bnema/git-sync/internal/state"
+)
+
+// checkPaused skips syncs of a repository paused with `git sync pause`. Like the
+// circuit breaker, the state is read on every sync so pauses apply right away.
+func (s *Scheduler) checkPaused(repoPath string) error {
+	st, err := state.Load()
+	if err != nil {
+		s.logger.Warn("Failed to read pause state, syncing anyway", "repo", repoPath, "error", err)
+		return nil
+	}
+	since, paused := st.PausedSince(repoPath)
+	if !paused {
+		return nil
+	}
+	if !st.PausedAllSince.IsZero() {
+		return newSkipError(SkipPaused, "all repositories paused since %s, run 'git sync resume --all' to sync again",
+			since.Local().Format("2006-01-02 15:04"))
+	}
+	return newSkipError(SkipPaused, "paused since %s, run 'git sync resume' to sync again",
+		since.Local().Format("2006-01-02 15:04"))
+}
+
+// checkCircuit skips syncs of a repository the circuit breaker has paused. The
+// state is read on every sync so `git sync resume` takes effect right away.
+func (s *Scheduler) checkCircuit(repoPath string) error {
+	if s.circuitFailures <= 0 {
+		return nil
+	}
+
+	st, err := state.Load()
+	if err != nil {
+		s.logger.Warn("Failed to read circuit breaker state, syncing anyway", "repo", repoPath, "error", err)
+		return nil
+	}
+	circuit, paused := st.Paused(repoPath, time.Now())
+	if !paused {
+		return nil
+	}
+	return newSkipError(SkipCircuitOpen, "paused after %d failed syncs in a row until %s, run 'git sync resume' to retry now",
+		circuit.Failures, circuit.PausedUntil.Local().Format("15:04"))
+}
+
+// recordCircuit counts the failed syncs of repoPath in a row and returns its
+// circuit, and whether this sync paused the repository. Failures still being
+// retried count once they give up, skips don't count, and anything else ends
+// the run. A repository failing again after its pause ran out pauses right away.
+func (s *Scheduler) recordCircuit(repoPath, status, errMsg string, retrying bool) (state.Circuit, bool) {
+	if s.circuitFailures <= 0 || status == "skipped" || retrying {
+		return state.Circuit{}, false
+	}
+
+	if status != "failed" {
+		st, err := state.Load()
+		if err != nil {
+			return state.Circuit{}, false
+		}
+		if _, exists := st.Circuits[repoPath]; !exists {
+			return state.Circuit{}, false
+		}
+		if err := state.Update(func(st *state.State) { st.ResetCircuit(repoPath, time.Now()) }); err != nil {
+			s.logger.Warn("Failed to reset circuit breaker", "repo", repoPath, "error", err)
+		}
+		return state.Circuit{}, false
+	}
+
+	var circuit state.Circuit
+	var paused bool
+	if err := state.Update(func(st *state.State) {
+		circuit, paused = st.RecordFailure(repoPath, errMsg, s.circuitFailures, s.circuitCooldown, time.Now())
+	}); err != nil {
+		s.logger.Warn("Failed to record failure for circuit breaker", "repo", repoPath, "error", err)
+		return state.Circuit{}, false
+	}
+	return circuit, paused
+}
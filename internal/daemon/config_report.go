@@ -0,0 +1,238 @@
+package daemon
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/control"
+)
+
+// ConfigReport is the configuration the daemon runs with, after global defaults
+// and the profile are applied, returned by the config-report control command
+type ConfigReport struct {
+	GeneratedAt   time.Time       `json:"generated_at"`
+	ConfigPath    string          `json:"config_path"`
+	Profile       string          `json:"profile"`
+	HostProfile   string          `json:"host_profile,omitempty"` // [profiles] section applied
+	MaxConcurrent int             `json:"max_concurrent"`
+	MaxIOHeavy    int             `json:"max_io_heavy"`
+	MaxLight      int             `json:"max_light"`
+	MaxPerHost    int             `json:"max_per_host,omitempty"` // 0 is unlimited
+	HostDelay     int             `json:"host_delay,omitempty"`   // seconds
+	Repositories  []EffectiveRepo `json:"repositories"`
+	Skipped       []SkippedRepo   `json:"skipped,omitempty"`
+}
+
+// EffectiveRepo is the resolved settings of a repository the daemon syncs
+type EffectiveRepo struct {
+	Path                string   `json:"path"`
+	Pattern             string   `json:"pattern,omitempty"` // the path glob of the entry matching the repository
+	Direction           string   `json:"direction"`
+	Remote              string   `json:"remote"`
+	BranchStrategy      string   `json:"branch_strategy"`
+	Interval            int      `json:"interval,omitempty"` // seconds
+	Schedule            string   `json:"schedule,omitempty"`
+	PullTiming          string   `json:"pull_timing,omitempty"` // when pulls and pushes sync separately
+	PushTiming          string   `json:"push_timing,omitempty"`
+	SyncSet             string   `json:"sync_set,omitempty"` // the set's timing replaces the repository's
+	Trigger             string   `json:"trigger"`
+	GitBackend          string   `json:"git_backend"`
+	Priority            string   `json:"priority"`
+	ConcurrencyClass    string   `json:"concurrency_class"`
+	MaxRuntime          int      `json:"max_runtime,omitempty"` // seconds
+	RetryMaxAttempts    int      `json:"retry_max_attempts"`
+	SafetyChecks        bool     `json:"safety_checks"`
+	DirtyWorktreeAction string   `json:"dirty_worktree_action"`
+	Proxy               string   `json:"proxy,omitempty"` // without credentials
+	SecretScan          bool     `json:"secret_scan"`
+	History             bool     `json:"history"`
+	Notifications       bool     `json:"notifications"`
+	Notify              string   `json:"notify"`              // all, failures-only or never
+	LogLevel            string   `json:"log_level,omitempty"` // replaces the daemon's for this repository
+	Warnings            []string `json:"warnings,omitempty"`  // problems syncs will run into
+}
+
+// SkippedRepo is a configured repository the daemon doesn't sync, and why
+type SkippedRepo struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// configReport resolves the settings of every configured repository in d.config;
+// callers hold mu
+func (d *Daemon) configReport() ConfigReport {
+	cfg := d.config
+	report := ConfigReport{
+		GeneratedAt:   time.Now(),
+		ConfigPath:    d.configPath,
+		Profile:       cfg.Global.Profile,
+		HostProfile:   cfg.HostProfile(),
+		MaxConcurrent: cfg.Global.MaxConcurrentSyncs,
+		MaxIOHeavy:    cfg.Global.MaxIOHeavySyncs,
+		MaxLight:      cfg.Global.MaxLightSyncs,
+		MaxPerHost:    cfg.Global.MaxSyncsPerHost,
+		HostDelay:     cfg.Global.HostSyncDelay,
+		Repositories:  make([]EffectiveRepo, 0, len(cfg.Repositories)),
+	}
+	if path, err := config.GetConfigPath(d.configPath); err == nil {
+		report.ConfigPath = path
+	}
+
+	enabledRepos := d.enabledRepos()
+	syncSets, _ := SyncSets(cfg, enabledRepos)
+	setOf := make(map[string]SyncSet)
+	for _, set := range syncSets {
+		for _, member := range set.Members {
+			setOf[member.Path] = set
+		}
+	}
+
+	scheduled := make(map[string]bool, len(enabledRepos))
+	for _, repo := range enabledRepos {
+		scheduled[repo.Path] = true
+		effective := EffectiveRepo{
+			Path:                repo.Path,
+			Pattern:             repo.Pattern,
+			Direction:           repo.Direction,
+			Remote:              repo.Remote,
+			BranchStrategy:      repo.BranchStrategy,
+			Interval:            repo.Interval,
+			Schedule:            repo.Schedule,
+			Trigger:             triggerOrDefault(repo.Trigger),
+			GitBackend:          repo.GitBackend,
+			Priority:            repo.Priority,
+			ConcurrencyClass:    repo.Class(),
+			MaxRuntime:          repo.MaxRuntime,
+			RetryMaxAttempts:    repo.RetryAttempts(),
+			SafetyChecks:        repo.SafetyChecks,
+			DirtyWorktreeAction: repo.DirtyWorktreeAction,
+			Proxy:               redactURL(repo.Proxy),
+			SecretScan:          repo.SecretScan,
+			History:             repo.HistoryEnabled(),
+			Notifications:       repo.NotificationsEnabled(),
+			Notify:              repo.NotifyMode(),
+			LogLevel:            repo.LogLevel,
+			Warnings:            repoWarnings(repo),
+		}
+		if effective.Priority == "" {
+			effective.Priority = "normal"
+		}
+		if effective.DirtyWorktreeAction == "" {
+			effective.DirtyWorktreeAction = "skip"
+		}
+		if set, inSet := setOf[repo.Path]; inSet {
+			effective.SyncSet = set.Name
+			effective.Interval, effective.Schedule = set.Interval, set.Schedule
+		} else if repo.SplitsDirections() {
+			effective.PullTiming = describeTiming(repo.DirectionTiming("pull"))
+			effective.PushTiming = describeTiming(repo.DirectionTiming("push"))
+		}
+		if effective.Schedule != "" {
+			effective.Interval = 0
+		}
+		report.Repositories = append(report.Repositories, effective)
+	}
+
+	for _, repo := range cfg.Repositories {
+		switch {
+		case cfg.ProfileLeavesOut(repo.Path):
+			report.Skipped = append(report.Skipped, SkippedRepo{Path: repo.Path, Reason: "not in profile " + cfg.HostProfile()})
+		case !repo.Enabled:
+			report.Skipped = append(report.Skipped, SkippedRepo{Path: repo.Path, Reason: "disabled"})
+		case scheduled[repo.Path]:
+			// The first entry for a path is synced, later ones are duplicates
+			scheduled[repo.Path] = false
+		default:
+			report.Skipped = append(report.Skipped, SkippedRepo{Path: repo.Path, Reason: "duplicate entry, the first one for this path is used"})
+		}
+	}
+	return report
+}
+
+// logConfigReport logs the effective configuration once, at startup
+func (d *Daemon) logConfigReport(report ConfigReport) {
+	d.logger.Info("Effective configuration",
+		"config", report.ConfigPath,
+		"profile", report.Profile,
+		"host_profile", report.HostProfile,
+		"repositories", len(report.Repositories),
+		"skipped", len(report.Skipped))
+	for _, repo := range report.Repositories {
+		attrs := []any{
+			"repo", repo.Path,
+			"direction", repo.Direction,
+			"remote", repo.Remote,
+			"branch_strategy", repo.BranchStrategy,
+		}
+		switch {
+		case repo.PullTiming != "":
+			attrs = append(attrs, "pull", repo.PullTiming, "push", repo.PushTiming)
+		default:
+			attrs = append(attrs, "timing", describeTiming(repo.Interval, repo.Schedule))
+		}
+		if repo.SyncSet != "" {
+			attrs = append(attrs, "sync_set", repo.SyncSet)
+		}
+		if repo.MaxRuntime > 0 {
+			attrs = append(attrs, "max_runtime", time.Duration(repo.MaxRuntime)*time.Second)
+		}
+		attrs = append(attrs,
+			"trigger", repo.Trigger,
+			"backend", repo.GitBackend,
+			"priority", repo.Priority,
+			"concurrency_class", repo.ConcurrencyClass,
+			"retries", repo.RetryMaxAttempts,
+			"dirty_worktree_action", repo.DirtyWorktreeAction)
+		d.logger.Info("Repository settings", attrs...)
+		for _, warning := range repo.Warnings {
+			d.logger.Warn("Repository syncs will fail", "repo", repo.Path, "problem", warning)
+		}
+	}
+	for _, skipped := range report.Skipped {
+		d.logger.Info("Repository not synced", "repo", skipped.Path, "reason", skipped.Reason)
+	}
+}
+
+// handleConfigReport returns the effective configuration of the running daemon
+func (d *Daemon) handleConfigReport(req control.Request) control.Response {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return control.OKResponse(d.configReport())
+}
+
+// describeTiming renders an interval in seconds or a cron schedule, which replaces it
+func describeTiming(interval int, schedule string) string {
+	if schedule != "" {
+		return "cron " + schedule
+	}
+	return fmt.Sprintf("every %s", time.Duration(interval)*time.Second)
+}
+
+// repoWarnings finds problems in the repository settings and path that fail syncs
+func repoWarnings(repo config.RepoConfig) []string {
+	var warnings []string
+	switch repo.BranchStrategy {
+	case "current", "main", "all", "specific":
+	case "":
+		warnings = append(warnings, "branch_strategy is not set")
+	default:
+		warnings = append(warnings, fmt.Sprintf("invalid branch_strategy '%s'", repo.BranchStrategy))
+	}
+
+	if err := config.CheckRepoPath(repo.Path); err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	return warnings
+}
+
+// redactURL drops the credentials of a URL such as a proxy
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.User == nil {
+		return raw
+	}
+	parsed.User = nil
+	return parsed.String()
+}
@@ -0,0 +1,163 @@
+package daemon
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// connectivityTTL is how long an online/offline verdict is reused, so a round of
+	// syncs probes once rather than once per repository
+	connectivityTTL = 15 * time.Second
+
+	// offlinePollInterval is how often connectivity is checked again while offline
+	offlinePollInterval = 30 * time.Second
+
+	// probeTimeout bounds a single connectivity probe
+	probeTimeout = 5 * time.Second
+
+	// nmStateConnecting is the highest NetworkManager state without any connection
+	nmStateConnecting = 40
+)
+
+// connectivity tells whether the machine is online, so syncs can be held back
+// instead of failing while offline. It probes offline_probe over TCP when set,
+// and otherwise asks NetworkManager; without either the machine counts as online.
+type connectivity struct {
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	enabled   bool
+	probe     string // host:port
+	offline   bool
+	checkedAt time.Time
+}
+
+func newConnectivity(logger *slog.Logger) *connectivity {
+	return &connectivity{logger: logger}
+}
+
+// configure turns offline gating on or off and sets the probe target
+func (c *connectivity) configure(enabled bool, probe string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = enabled
+	c.probe = probe
+	c.checkedAt = time.Time{}
+	c.offline = false
+}
+
+// invalidate forgets the last verdict, e.g. when the network just changed
+func (c *connectivity) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkedAt = time.Time{}
+}
+
+// check skips syncs while the machine is offline
+func (c *connectivity) check(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.enabled {
+		return nil
+	}
+	if time.Since(c.checkedAt) >= connectivityTTL {
+		c.refresh(ctx)
+	}
+	if c.offline {
+		return newSkipError(SkipOffline, "offline, syncing once the network is back")
+	}
+	return nil
+}
+
+// refresh probes connectivity and reports whether the machine just came back
+// online; the caller holds mu
+func (c *connectivity) refresh(ctx context.Context) bool {
+	wasOffline := c.offline
+	c.offline = !c.online(ctx)
+	c.checkedAt = time.Now()
+
+	if c.offline && !wasOffline {
+		c.logger.Info("Network is offline, holding back syncs", "probe", c.probe)
+	}
+	return wasOffline && !c.offline
+}
+
+// online probes the configured host, or asks NetworkManager when there is none
+func (c *connectivity) online(ctx context.Context) bool {
+	if c.probe != "" {
+		dialer := net.Dialer{Timeout: probeTimeout}
+		conn, err := dialer.DialContext(ctx, "tcp", c.probe)
+		if err != nil {
+			c.logger.Debug("Connectivity probe failed", "probe", c.probe, "error", err)
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	state, known := networkManagerState(ctx)
+	return !known || state > nmStateConnecting
+}
+
+// watch polls connectivity while offline and calls onOnline once the machine is
+// back, until ctx is done
+func (c *connectivity) watch(ctx context.Context, onOnline func()) {
+	ticker := time.NewTicker(offlinePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			back := false
+			if c.enabled && c.offline {
+				back = c.refresh(ctx)
+			}
+			c.mu.Unlock()
+
+			if back {
+				c.logger.Info("Network is back, catching up on syncs")
+				onOnline()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// networkManagerState reads NetworkManager's global state over D-Bus; known is
+// false when NetworkManager or dbus-send is unavailable
+func networkManagerState(ctx context.Context) (int, bool) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "dbus-send", "--system", "--print-reply",
+		"--dest=org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager",
+		"org.freedesktop.DBus.Properties.Get",
+		"string:org.freedesktop.NetworkManager", "string:State").Output()
+	if err != nil {
+		return 0, false
+	}
+
+	// The reply ends with a line like "   variant       uint32 70"
+	fields := strings.Fields(string(output))
+	if len(fields) < 2 || fields[len(fields)-2] != "uint32" {
+		return 0, false
+	}
+	state, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || state == 0 {
+		return 0, false
+	}
+	return state, true
+}
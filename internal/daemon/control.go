@@ -0,0 +1,274 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bnema/git-sync/internal/apikey"
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/fleet"
+	"github.com/bnema/git-sync/internal/logging"
+)
+
+// logLevelOverride is a temporary log level set through the control socket
+type logLevelOverride struct {
+	until time.Time // zero means until the next config reload
+	timer *time.Timer
+}
+
+// LogLevelStatus is returned by the log-level control command
+type LogLevelStatus struct {
+	Level           string            `json:"level"`
+	ConfiguredLevel string            `json:"configured_level"`
+	OverrideUntil   time.Time         `json:"override_until,omitempty"`
+	RepoLevels      map[string]string `json:"repo_levels,omitempty"` // repositories with their own log_level, unless overridden
+}
+
+// registerControlHandlers wires daemon functionality into the control socket
+func (d *Daemon) registerControlHandlers() {
+	d.controlServer.Handle("log-level", d.handleLogLevel)
+	d.controlServer.Handle("progress", d.handleProgress)
+	d.controlServer.Handle("rate-limits", d.handleRateLimits)
+	d.controlServer.Handle("retries", d.handleRetries)
+	d.controlServer.Handle("sync", d.handleSync)
+	d.controlServer.Handle("history-health", d.handleHistoryHealth)
+	d.controlServer.Handle("health", d.handleHealth)
+	d.controlServer.Handle("status", d.handleStatus)
+	d.controlServer.Handle("pause", d.handlePause)
+	d.controlServer.Handle("resume", d.handleResume)
+	d.controlServer.Handle("reload", d.handleReload)
+	d.controlServer.Handle("history", d.handleHistory)
+	d.controlServer.Handle("purge-history", d.handlePurgeHistory)
+	d.controlServer.Handle("config-report", d.handleConfigReport)
+	d.controlServer.HandleQuery("STATUS", d.queryStatus)
+	d.controlServer.HandleQuery("NEXT", d.queryNext)
+	d.controlServer.HandleQuery("LAST", d.queryLast)
+}
+
+// handleHealth reports whether the daemon is syncing its repositories
+func (d *Daemon) handleHealth(req control.Request) control.Response {
+	return control.OKResponse(d.Health())
+}
+
+// handleHistoryHealth reports whether sync history is being recorded
+func (d *Daemon) handleHistoryHealth(req control.Request) control.Response {
+	if d.historyManager == nil {
+		return control.ErrorResponse(errors.New("history is unavailable"))
+	}
+	return control.OKResponse(d.historyManager.Health())
+}
+
+// SyncTriggered is returned by the sync control command
+type SyncTriggered struct {
+	Repositories []string `json:"repositories,omitempty"` // empty when all were triggered
+}
+
+// startHTTPAPI serves the read-only commands and sync triggers over HTTP, for
+// API keys granting the matching scope. Changing the address needs a restart.
+func (d *Daemon) startHTTPAPI(addr string) {
+	api := control.NewHTTPServer(addr, d.controlServer, authorizeAPIKey, d.logger)
+	api.Expose("progress", apikey.ScopeRead)
+	api.Expose("rate-limits", apikey.ScopeRead)
+	api.Expose("retries", apikey.ScopeRead)
+	api.Expose("history-health", apikey.ScopeRead)
+	api.Expose("health", apikey.ScopeRead)
+	api.Expose("status", apikey.ScopeRead)
+	api.Expose("history", apikey.ScopeRead)
+	api.HandleOpen("/healthz", d.serveHealthz)
+	// Git hosts can't send API keys; push webhooks are checked against their secret instead
+	api.HandleOpen("POST "+PushWebhookPath, d.servePushWebhook)
+	if store, err := fleet.OpenStore(); err != nil {
+		d.logger.Warn("Failed to open fleet reports, not accepting them", "error", err)
+	} else {
+		d.fleetStore = store
+		api.HandleRequest("POST "+fleet.ReportPath, apikey.ScopeReport, d.serveFleetReport)
+	}
+	api.Expose("sync", apikey.ScopeTrigger)
+
+	if err := api.Start(); err != nil {
+		d.logger.Warn("Failed to start HTTP API", "addr", addr, "error", err)
+		return
+	}
+	d.httpAPI = api
+}
+
+// authorizeAPIKey checks an HTTP API token against the stored API keys,
+// returning the name of its key
+func authorizeAPIKey(token, scope string) (string, error) {
+	key, err := apikey.Authenticate(token, scope)
+	switch {
+	case errors.Is(err, apikey.ErrInvalidKey):
+		return "", fmt.Errorf("%w: %v", control.ErrUnauthorized, err)
+	case errors.Is(err, apikey.ErrMissingScope):
+		return "", fmt.Errorf("%w: %v", control.ErrForbidden, err)
+	case err != nil:
+		return "", err
+	}
+	return key.Name, nil
+}
+
+// handleSync makes a configured repository (repo=<path>), or every one
+// (all=true), sync right away
+func (d *Daemon) handleSync(req control.Request) control.Response {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if req.Args["all"] == "true" {
+		d.scheduler.TriggerAll()
+		d.logger.Info("Sync of all repositories requested")
+		return control.OKResponse(SyncTriggered{})
+	}
+
+	repoArg := req.Args["repo"]
+	if repoArg == "" {
+		return control.ErrorResponse(errors.New("either repo or all=true is required"))
+	}
+	i, found := d.config.FindRepository(repoArg)
+	if !found {
+		return control.ErrorResponse(fmt.Errorf("repository not configured: %s", repoArg))
+	}
+	repoPath := d.config.Repositories[i].Path
+	if !d.scheduler.Trigger(repoPath) {
+		return control.ErrorResponse(fmt.Errorf("repository is not enabled: %s", repoPath))
+	}
+	d.logger.Info("Sync requested", "repo", repoPath)
+	return control.OKResponse(SyncTriggered{Repositories: []string{repoPath}})
+}
+
+// handleRetries reports the repositories waiting to retry a failed sync
+func (d *Daemon) handleRetries(req control.Request) control.Response {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return control.OKResponse(d.scheduler.Retries())
+}
+
+// handleRateLimits reports the rate limits git hosts reported to recent syncs
+func (d *Daemon) handleRateLimits(req control.Request) control.Response {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return control.OKResponse(d.syncManager.RateLimits())
+}
+
+// handleProgress reports the transfer progress of running syncs
+func (d *Daemon) handleProgress(req control.Request) control.Response {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return control.OKResponse(d.syncManager.ActiveTransfers())
+}
+
+// handleLogLevel reports the current log level, or overrides it when a level is given.
+// An optional duration reverts the override automatically.
+func (d *Daemon) handleLogLevel(req control.Request) control.Response {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if levelArg := req.Args["level"]; levelArg != "" {
+		level, err := logging.ParseLevel(levelArg)
+		if err != nil {
+			return control.ErrorResponse(err)
+		}
+
+		var duration time.Duration
+		if durationArg := req.Args["duration"]; durationArg != "" {
+			duration, err = time.ParseDuration(durationArg)
+			if err != nil || duration < 0 {
+				return control.ErrorResponse(fmt.Errorf("invalid duration '%s'", durationArg))
+			}
+		}
+
+		d.setLogLevelOverride(level, duration)
+	}
+
+	return control.OKResponse(d.logLevelStatus())
+}
+
+// setLogLevelOverride changes the log level, reverting after duration if positive.
+// Callers must hold d.mu.
+func (d *Daemon) setLogLevelOverride(level slog.Level, duration time.Duration) {
+	d.clearLogLevelOverride()
+
+	override := &logLevelOverride{}
+	if duration > 0 {
+		override.until = time.Now().Add(duration)
+		override.timer = time.AfterFunc(duration, func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if d.levelOverride != override {
+				return // superseded by a newer override
+			}
+			d.levelOverride = nil
+			d.applyConfiguredLogLevel()
+			d.logger.Info("Log level override expired", "level", logging.LevelName(d.logLevel.Level()))
+		})
+	}
+	d.levelOverride = override
+	d.logLevel.Set(level)
+	// The override applies to every repository, including those with their own log_level
+	d.repoLevels.Set(nil)
+
+	d.logger.Info("Log level overridden",
+		"level", logging.LevelName(level),
+		"duration", duration)
+}
+
+// clearLogLevelOverride cancels any pending override revert. Callers must hold d.mu.
+func (d *Daemon) clearLogLevelOverride() {
+	if d.levelOverride != nil && d.levelOverride.timer != nil {
+		d.levelOverride.timer.Stop()
+	}
+	d.levelOverride = nil
+}
+
+// applyConfiguredLogLevel sets the log level from the current config unless a
+// timed override is still active. Overrides without expiry end on config reload.
+// Callers must hold d.mu.
+func (d *Daemon) applyConfiguredLogLevel() {
+	if d.levelOverride != nil && !d.levelOverride.until.IsZero() {
+		return
+	}
+	d.clearLogLevelOverride()
+
+	level, err := logging.ParseLevel(d.config.Global.LogLevel)
+	if err != nil {
+		d.logger.Warn("Invalid log level in config, using info", "error", err)
+	}
+	d.logLevel.Set(level)
+	d.applyRepoLogLevels()
+}
+
+// applyRepoLogLevels sets the log levels of the enabled repositories that have
+// their own log_level. Callers must hold d.mu.
+func (d *Daemon) applyRepoLogLevels() {
+	levels := make(map[string]slog.Level)
+	for _, repo := range d.enabledRepos() {
+		if repo.LogLevel == "" {
+			continue
+		}
+		// Validated when the config was loaded
+		level, _ := logging.ParseLevel(repo.LogLevel)
+		levels[repo.Path] = level
+	}
+	d.repoLevels.Set(levels)
+}
+
+func (d *Daemon) logLevelStatus() LogLevelStatus {
+	status := LogLevelStatus{
+		Level:           logging.LevelName(d.logLevel.Level()),
+		ConfiguredLevel: d.config.Global.LogLevel,
+	}
+	if d.levelOverride != nil {
+		status.OverrideUntil = d.levelOverride.until
+	}
+	for path, level := range d.repoLevels.Levels() {
+		if status.RepoLevels == nil {
+			status.RepoLevels = make(map[string]string)
+		}
+		status.RepoLevels[path] = logging.LevelName(level)
+	}
+	return status
+}
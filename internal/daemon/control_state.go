@@ -0,0 +1,254 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/logging"
+	"github.com/bnema/git-sync/internal/state"
+)
+
+// defaultHistoryTail is how many entries the history control command returns by default
+const defaultHistoryTail = 20
+
+// DaemonStatus is returned by the status control command
+type DaemonStatus struct {
+	PID           int                `json:"pid"`
+	StartedAt     time.Time          `json:"started_at"`
+	ConfigPath    string             `json:"config_path"`
+	LogLevel      string             `json:"log_level"`
+	MaxConcurrent int                `json:"max_concurrent"`
+	ClassLimits   map[string]int     `json:"class_limits"` // syncs at once by concurrency class
+	PausedAll     bool               `json:"paused_all,omitempty"`
+	Repositories  []RepositoryStatus `json:"repositories"`
+}
+
+// RepositoryStatus is what the daemon is doing with one enabled repository
+type RepositoryStatus struct {
+	Path        string    `json:"path"`
+	Direction   string    `json:"direction"`
+	Syncing     bool      `json:"syncing,omitempty"`
+	NextSync    time.Time `json:"next_sync,omitempty"`
+	LastSync    time.Time `json:"last_sync,omitempty"`
+	LastStatus  string    `json:"last_status,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	Paused      bool      `json:"paused,omitempty"`       // by 'git sync pause'
+	PausedUntil time.Time `json:"paused_until,omitempty"` // by the circuit breaker
+}
+
+// PauseResult is returned by the pause and resume control commands
+type PauseResult struct {
+	Changed int `json:"changed"` // repositories paused or resumed; 0 when none changed
+}
+
+// handleStatus reports the daemon's schedules and the latest sync of every enabled repository
+func (d *Daemon) handleStatus(req control.Request) control.Response {
+	d.mu.RLock()
+	enabledRepos := d.enabledRepos()
+	status := DaemonStatus{
+		PID:           os.Getpid(),
+		ConfigPath:    d.configPath,
+		LogLevel:      logging.LevelName(d.logLevel.Level()),
+		MaxConcurrent: d.syncManager.MaxConcurrent(),
+		ClassLimits:   d.syncManager.ClassLimits(),
+	}
+	d.mu.RUnlock()
+
+	st, err := state.Load()
+	if err != nil {
+		return control.ErrorResponse(fmt.Errorf("failed to load state: %w", err))
+	}
+	now := time.Now()
+	status.PausedAll = !st.PausedAllSince.IsZero()
+
+	snapshot := d.metrics.Snapshot()
+	status.StartedAt = snapshot.StartedAt
+	synced := make(map[string]int, len(snapshot.Repos))
+	for i, repo := range snapshot.Repos {
+		synced[repo.Repo] = i
+	}
+	schedules := d.scheduler.GetStatus()
+
+	status.Repositories = make([]RepositoryStatus, 0, len(enabledRepos))
+	for _, repo := range enabledRepos {
+		repoStatus := RepositoryStatus{
+			Path:      repo.Path,
+			Direction: repo.Direction,
+			Syncing:   d.syncManager.syncing(repo.Path),
+			NextSync:  schedules[repo.Path].NextSync,
+		}
+		if i, exists := synced[repo.Path]; exists {
+			repoStatus.LastSync = snapshot.Repos[i].LastSync
+			repoStatus.LastStatus = snapshot.Repos[i].LastStatus
+			repoStatus.LastSuccess = snapshot.Repos[i].LastSuccess
+		}
+		_, repoStatus.Paused = st.PausedSince(repo.Path)
+		if circuit, open := st.Paused(repo.Path, now); open {
+			repoStatus.PausedUntil = circuit.PausedUntil
+		}
+		status.Repositories = append(status.Repositories, repoStatus)
+	}
+	return control.OKResponse(status)
+}
+
+// handlePause pauses a configured repository (repo=<path>), or every one (all=true)
+func (d *Daemon) handlePause(req control.Request) control.Response {
+	repoPath, all, err := d.pauseTarget(req)
+	if err != nil {
+		return control.ErrorResponse(err)
+	}
+
+	var result PauseResult
+	if err := state.Update(func(s *state.State) {
+		changed := false
+		if all {
+			changed = s.PauseAll(time.Now())
+		} else {
+			changed = s.Pause(repoPath, time.Now())
+		}
+		if changed {
+			result.Changed = 1
+		}
+	}); err != nil {
+		return control.ErrorResponse(fmt.Errorf("failed to update state: %w", err))
+	}
+
+	switch {
+	case result.Changed == 0:
+	case all:
+		d.logger.Info("Paused all repositories")
+	default:
+		d.logger.Info("Paused repository", "repo", repoPath)
+	}
+	return control.OKResponse(result)
+}
+
+// handleResume resumes a paused repository (repo=<path>), or every one (all=true),
+// also lifting circuit breaker pauses
+func (d *Daemon) handleResume(req control.Request) control.Response {
+	repoPath, all, err := d.pauseTarget(req)
+	if err != nil {
+		return control.ErrorResponse(err)
+	}
+
+	var result PauseResult
+	var resumeErr error
+	if err := state.Update(func(s *state.State) {
+		if all {
+			result.Changed = s.ResumeAll(time.Now())
+			return
+		}
+		var resumed bool
+		resumed, resumeErr = s.Resume(repoPath, time.Now())
+		if resumed {
+			result.Changed = 1
+		}
+	}); err != nil {
+		return control.ErrorResponse(fmt.Errorf("failed to update state: %w", err))
+	}
+	if resumeErr != nil {
+		return control.ErrorResponse(resumeErr)
+	}
+
+	switch {
+	case result.Changed == 0:
+	case all:
+		d.logger.Info("Resumed all repositories", "resumed", result.Changed)
+	default:
+		d.logger.Info("Resumed repository", "repo", repoPath)
+	}
+	return control.OKResponse(result)
+}
+
+// pauseTarget resolves the repository a pause or resume request is for
+func (d *Daemon) pauseTarget(req control.Request) (string, bool, error) {
+	if req.Args["all"] == "true" {
+		return "", true, nil
+	}
+	repoArg := req.Args["repo"]
+	if repoArg == "" {
+		return "", false, errors.New("either repo or all=true is required")
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	i, found := d.config.FindRepository(repoArg)
+	if !found {
+		return "", false, fmt.Errorf("repository not configured: %s", repoArg)
+	}
+	return d.config.Repositories[i].Path, false, nil
+}
+
+// handleReload reads the config file again and applies it, like SIGHUP, waiting
+// for the reschedule to finish
+func (d *Daemon) handleReload(req control.Request) control.Response {
+	d.logger.Info("Config reload requested")
+	summary, err := d.reloadConfigFile()
+	if err != nil {
+		return control.ErrorResponse(err)
+	}
+	return control.OKResponse(summary)
+}
+
+// handleHistory returns the latest history entries, newest first: limit=<n>
+// (default 20), optionally those of repo=<path>, failed ones with failed=true,
+// or only those after since=<RFC 3339 time> for clients polling for new syncs
+func (d *Daemon) handleHistory(req control.Request) control.Response {
+	if d.historyManager == nil {
+		return control.ErrorResponse(errors.New("history is unavailable"))
+	}
+
+	limit := defaultHistoryTail
+	if limitArg := req.Args["limit"]; limitArg != "" {
+		parsed, err := strconv.Atoi(limitArg)
+		if err != nil || parsed <= 0 {
+			return control.ErrorResponse(fmt.Errorf("invalid limit '%s'", limitArg))
+		}
+		limit = parsed
+	}
+	query := HistoryQuery{Limit: limit, Repo: req.Args["repo"]}
+	if req.Args["failed"] == "true" {
+		query.Status = "failed"
+	}
+	if sinceArg := req.Args["since"]; sinceArg != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, sinceArg)
+		if err != nil {
+			return control.ErrorResponse(fmt.Errorf("invalid since '%s': must be an RFC 3339 time", sinceArg))
+		}
+		// Only entries newer than since, which the caller already has
+		query.Since = parsed.Add(time.Nanosecond)
+	}
+
+	entries, err := d.historyManager.QueryHistory(query)
+	if err != nil {
+		return control.ErrorResponse(err)
+	}
+	return control.OKResponse(entries)
+}
+
+// HistoryPurged is returned by the purge-history control command
+type HistoryPurged struct {
+	Removed int `json:"removed"`
+}
+
+// handlePurgeHistory deletes the history of the repository in args["repo"],
+// including the entries the daemon still holds in memory
+func (d *Daemon) handlePurgeHistory(req control.Request) control.Response {
+	if d.historyManager == nil {
+		return control.ErrorResponse(errors.New("history is unavailable"))
+	}
+	repo := req.Args["repo"]
+	if repo == "" {
+		return control.ErrorResponse(errors.New("purge-history needs a repo"))
+	}
+	removed, err := d.historyManager.RemoveRepository(repo)
+	if err != nil {
+		return control.ErrorResponse(err)
+	}
+	d.logger.Info("Purged repository history", "repo", repo, "removed_count", removed)
+	return control.OKResponse(HistoryPurged{Removed: removed})
+}
@@ -3,30 +3,67 @@ package daemon
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/coreos/go-systemd/v22/daemon"
 
 	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/fleet"
+	"github.com/bnema/git-sync/internal/logging"
+	"github.com/bnema/git-sync/internal/metrics"
 	"github.com/bnema/git-sync/internal/notification"
+	"github.com/bnema/git-sync/internal/state"
 )
 
 type Daemon struct {
 	config              *config.Config
+	configPath          string
 	configWatcher       *config.ConfigWatcher
 	syncManager         *SyncManager
 	scheduler           *Scheduler
 	historyManager      *HistoryManager
 	notificationManager *notification.NotificationManager
+	controlServer       *control.Server
+	httpAPI             *control.HTTPServer
+	reporter            *fleet.Reporter
+	fleetStore          *fleet.Store // reports of other machines, nil until the HTTP API starts
+	metrics             *metrics.Registry
+	metricsServer       *http.Server // nil unless metrics_listen is set
+	connectivity        *connectivity
+	power               *power
+	stopNetworkWatch    context.CancelFunc // nil unless sync_on_network_change is set
+	pushTargets         atomic.Pointer[pushTargets] // what push webhooks can trigger, set per config load
+	stateCache          state.Cache                 // the state for prompt queries, which come too often to read it each time
 	logger              *slog.Logger
+	logFile             *logging.RotatingFile // nil unless log_file is set
+	logLevel            *slog.LevelVar
+	repoLevels          *logging.RepoLevels // log_level of repositories that set their own
+	levelOverride       *logLevelOverride
 	ctx                 context.Context
 	cancel              context.CancelFunc
 	mu                  sync.RWMutex
+	reloadMu            sync.Mutex // one reload at a time, without holding mu while syncs finish
+	watchdogMisses      int        // watchdog pings held back in a row, only used by Run
+	lastReload          *ReloadStatus
+}
+
+// commandFlags are the global flags passed on to the git-sync commands the daemon starts
+var commandFlags []string
+
+// SetCommandFlags sets the global flags, like --cache-dir, that the commands
+// started from notifications get, so they read the same files as the daemon
+func SetCommandFlags(flags []string) {
+	commandFlags = flags
 }
 
 func NewDaemon(configPath string) (*Daemon, error) {
@@ -34,23 +71,47 @@ func NewDaemon(configPath string) (*Daemon, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
+	cfg = cfg.ExpandPatterns()
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// Setup logger based on config
-	logLevel := slog.LevelInfo
-	switch cfg.Global.LogLevel {
-	case "debug":
-		logLevel = slog.LevelDebug
-	case "warn":
-		logLevel = slog.LevelWarn
-	case "error":
-		logLevel = slog.LevelError
+	// Setup logger based on config; the level can change at runtime
+	logLevel := new(slog.LevelVar)
+	configuredLevel, err := logging.ParseLevel(cfg.Global.LogLevel)
+	logLevel.Set(configuredLevel)
+
+	// Logs go to stdout (the journal under systemd) and log_file when set
+	var output io.Writer = os.Stdout
+	var logFile *logging.RotatingFile
+	var logFileErr error
+	if cfg.Global.LogFile != "" {
+		logFile, logFileErr = logging.OpenRotatingFile(cfg.Global.LogFile, cfg.Global.LogFileMaxSizeMB)
+		if logFileErr == nil {
+			output = io.MultiWriter(os.Stdout, logFile)
+		}
+	}
+	handler, formatErr := logging.NewHandler(cfg.Global.LogFormat, output, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+	// Repositories with their own log_level are filtered by it instead
+	handler, repoLevels := logging.NewRepoLevelHandler(handler, logLevel)
+
+	// Identical messages (e.g. a repo failing every interval) are rate limited;
+	// the full detail of every sync is still kept in history
+	logger := slog.New(logging.NewDedupHandler(
+		handler,
+		cfg.Global.LogDedupBurst,
+		time.Duration(cfg.Global.LogDedupWindow)*time.Second,
+	))
+	if err != nil {
+		logger.Warn("Invalid log level in config, using info", "error", err)
+	}
+	if formatErr != nil {
+		logger.Warn("Invalid log format in config, using text", "error", formatErr)
+	}
+	if logFileErr != nil {
+		logger.Warn("Failed to open log file, logging to stdout only", "path", cfg.Global.LogFile, "error", logFileErr)
 	}
-
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
-	}))
 
 	// Create history manager
 	historyManager, err := NewHistoryManager(
@@ -58,6 +119,7 @@ func NewDaemon(configPath string) (*Daemon, error) {
 		cfg.Global.HistoryMaxEntries,
 		cfg.Global.HistoryRetentionDays,
 		cfg.Global.HistoryMaxFileSizeMB,
+		cfg.Global.HistoryBackend,
 		logger,
 	)
 	if err != nil {
@@ -71,29 +133,89 @@ func NewDaemon(configPath string) (*Daemon, error) {
 		cfg.Global.NotificationTimeout,
 		logger,
 	)
+	notificationManager.SetPolicy(cfg.Global.NotificationPolicy)
+
+	metricsRegistry := metrics.NewRegistry()
 
 	// Create daemon instance
 	d := &Daemon{
 		config:              cfg,
+		configPath:          configPath,
 		syncManager:         NewSyncManager(cfg.Global.MaxConcurrentSyncs, logger),
-		scheduler:           NewScheduler(logger, historyManager, notificationManager),
+		scheduler:           NewScheduler(logger, historyManager, notificationManager, metricsRegistry),
 		historyManager:      historyManager,
 		notificationManager: notificationManager,
+		controlServer:       control.NewServer(control.SocketPath(), logger),
+		metrics:             metricsRegistry,
+		connectivity:        newConnectivity(logger),
+		power:               newPower(logger),
+		reporter:            fleet.NewReporter(logger),
 		logger:              logger,
+		logFile:             logFile,
+		logLevel:            logLevel,
+		repoLevels:          repoLevels,
 		ctx:                 ctx,
 		cancel:              cancel,
 	}
 
+	d.applyRepoLogLevels()
+	d.syncManager.SetClassLimits(cfg.Global.MaxIOHeavySyncs, cfg.Global.MaxLightSyncs)
+	d.syncManager.SetHostLimits(cfg.Global.MaxSyncsPerHost, time.Duration(cfg.Global.HostSyncDelay)*time.Second)
+	d.configureScheduler(cfg)
+	d.connectivity.configure(cfg.Global.PauseWhenOffline, cfg.Global.OfflineProbe)
+	d.scheduler.SetConnectivity(d.connectivity)
+	notificationManager.SetRetryHandler(d.scheduler.Trigger)
+	if executable, err := os.Executable(); err == nil {
+		history := append([]string{executable}, commandFlags...)
+		if configPath != "" {
+			// The terminal doesn't start in the daemon's directory
+			path, err := filepath.Abs(configPath)
+			if err != nil {
+				path = configPath
+			}
+			history = append(history, "--config", path)
+		}
+		notificationManager.SetHistoryCommand(append(history, "history"))
+	}
+	if err := notificationManager.SetNotifiers(cfg.Notifiers); err != nil {
+		return nil, err
+	}
+	d.power.configure(cfg.Global.PauseOnBattery, cfg.Global.BatteryIntervalMultiplier)
+	d.scheduler.SetPower(d.power)
+	d.configureReporter(cfg)
+	d.scheduler.SetReporter(d.reporter)
+	if err := d.scheduler.SetWebhooks(cfg.Webhooks); err != nil {
+		return nil, err
+	}
+	d.pushTargets.Store(d.loadPushTargets(cfg))
+	configureFaults(d.syncManager, cfg, logger)
+
 	// Create config watcher with callback to daemon's reload method
 	configWatcher, err := config.NewConfigWatcher(configPath, d.reloadConfig, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create config watcher: %w", err)
 	}
+	configWatcher.OnReloadError(d.recordReload)
 	d.configWatcher = configWatcher
 
 	return d, nil
 }
 
+// enabledRepos returns the enabled repositories with global defaults applied.
+// A path configured twice syncs with its first entry, since schedules are
+// keyed by path.
+func (d *Daemon) enabledRepos() []config.RepoConfig {
+	enabledRepos := make([]config.RepoConfig, 0)
+	seen := make(map[string]bool)
+	for _, repo := range d.config.Repositories {
+		if repo.Enabled && !seen[repo.Path] {
+			seen[repo.Path] = true
+			enabledRepos = append(enabledRepos, d.config.Global.WithGlobalDefaults(repo))
+		}
+	}
+	return enabledRepos
+}
+
 func (d *Daemon) Run() error {
 	// Signal systemd that we're ready
 	if sent, err := daemon.SdNotify(false, daemon.SdNotifyReady); err != nil {
@@ -107,46 +229,99 @@ func (d *Daemon) Run() error {
 		"max_concurrent", d.config.Global.MaxConcurrentSyncs)
 
 	// Start sync scheduler for all enabled repositories
-	enabledRepos := make([]config.RepoConfig, 0)
-	for _, repo := range d.config.Repositories {
-		if repo.Enabled {
-			enabledRepos = append(enabledRepos, repo)
-		}
-	}
+	enabledRepos := d.enabledRepos()
+	syncSets, standalone := SyncSets(d.config, enabledRepos)
 
 	if len(enabledRepos) == 0 {
 		d.logger.Warn("No enabled repositories configured")
 	} else {
-		d.logger.Info("Starting scheduler", "enabled_repos", len(enabledRepos))
-		d.scheduler.Start(d.ctx, enabledRepos, d.syncManager)
+		d.logger.Info("Starting scheduler", "enabled_repos", len(enabledRepos), "sync_sets", len(syncSets))
+		d.scheduler.Start(d.ctx, standalone, d.syncManager)
+		d.scheduler.StartSyncSets(d.ctx, syncSets, d.syncManager)
 	}
 
+	// What the settings resolve to is otherwise only visible from the code
+	d.mu.RLock()
+	report := d.configReport()
+	d.mu.RUnlock()
+	d.logConfigReport(report)
+
 	// Start config file watching
 	if err := d.configWatcher.StartWatching(); err != nil {
 		d.logger.Error("Failed to start config watcher", "error", err)
 		return fmt.Errorf("failed to start config watcher: %w", err)
 	}
 
+	// Start control socket for CLI commands
+	d.registerControlHandlers()
+	if err := d.controlServer.Start(); err != nil {
+		d.logger.Warn("Failed to start control socket, runtime commands unavailable", "error", err)
+	}
+
+	// Serve the exposed control commands over HTTP for dashboards and scripts
+	if d.config.Global.APIListen != "" {
+		d.startHTTPAPI(d.config.Global.APIListen)
+	}
+
+	// Report syncs to the fleet aggregator, once report_to is set
+	go d.reporter.Run(d.ctx)
+
 	// Start history cleanup routine (runs once per day)
 	if d.historyManager != nil {
 		go d.startHistoryCleanup()
 	}
 
+	// History and notifications held back while their stores fail are retried
+	go d.startPendingRetry()
+
+	// Periodically write a metrics snapshot for offline collectors
+	go d.startMetricsExport()
+
+	// Serve the same metrics to Prometheus scrapes
+	if d.config.Global.MetricsListen != "" {
+		d.startMetricsServer(d.config.Global.MetricsListen)
+	}
+
+	// Catch up as soon as the machine gets online instead of waiting for the next interval
+	d.mu.Lock()
+	d.setNetworkWatch(d.config.Global.SyncOnNetworkChange)
+	d.mu.Unlock()
+
+	// Syncs held back while offline catch up once connectivity returns
+	go d.connectivity.watch(d.ctx, d.catchUpAfterOffline)
+
+	// Syncs paused on battery catch up once plugged in
+	go d.power.watch(d.ctx, d.catchUpAfterOffline)
+
 	// Set up signal handling
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
+	// Under WatchdogSec, systemd restarts the daemon when the pings stop
+	var watchdog <-chan time.Time
+	if interval := WatchdogInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watchdog = ticker.C
+		d.logger.Debug("Pinging systemd watchdog", "interval", interval)
+	}
+
 	d.logger.Info("Git sync daemon started successfully")
 
 	for {
 		select {
+		case <-watchdog:
+			d.pingWatchdog()
 		case sig := <-sigChan:
 			switch sig {
 			case syscall.SIGHUP:
 				d.logger.Info("Received SIGHUP, reloading configuration")
-				if err := d.reloadConfigFromSignal(); err != nil {
-					d.logger.Error("Failed to reload config", "error", err)
-				}
+				// Reloads wait for syncs of removed repositories, the watchdog must not
+				go func() {
+					if err := d.reloadConfigFromSignal(); err != nil {
+						d.logger.Error("Failed to reload config", "error", err)
+					}
+				}()
 			case syscall.SIGINT, syscall.SIGTERM:
 				d.logger.Info("Received shutdown signal", "signal", sig)
 				
@@ -174,6 +349,42 @@ func (d *Daemon) Run() error {
 	}
 }
 
+// setNetworkWatch starts or stops watching network changes. The caller must hold d.mu.
+func (d *Daemon) setNetworkWatch(enabled bool) {
+	if enabled == (d.stopNetworkWatch != nil) {
+		return
+	}
+	if !enabled {
+		d.stopNetworkWatch()
+		d.stopNetworkWatch = nil
+		d.logger.Debug("Stopped watching network changes")
+		return
+	}
+	ctx, cancel := context.WithCancel(d.ctx)
+	d.stopNetworkWatch = cancel
+	watchNetwork(ctx, d.logger, d.syncAfterNetworkChange)
+}
+
+// syncAfterNetworkChange syncs all repositories unless a reload turned it off
+func (d *Daemon) syncAfterNetworkChange() {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.config.Global.SyncOnNetworkChange {
+		d.connectivity.invalidate()
+		d.scheduler.TriggerAll()
+	}
+}
+
+// catchUpAfterOffline syncs all repositories once syncs held back while offline
+// or on battery can run again
+func (d *Daemon) catchUpAfterOffline() {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	d.scheduler.TriggerAll()
+}
+
 // startHistoryCleanup starts a goroutine that periodically cleans old history entries
 func (d *Daemon) startHistoryCleanup() {
 	ticker := time.NewTicker(24 * time.Hour) // Run once per day
@@ -203,52 +414,139 @@ func (d *Daemon) startHistoryCleanup() {
 	}
 }
 
-func (d *Daemon) reloadConfig(newConfig *config.Config) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	d.logger.Info("Reloading configuration")
+// startMetricsExport writes the metrics snapshot file at the configured interval.
+// The settings are re-read on every tick so config reloads apply without a restart.
+func (d *Daemon) startMetricsExport() {
+	for {
+		d.mu.RLock()
+		interval := time.Duration(d.config.Global.MetricsWriteInterval) * time.Second
+		d.mu.RUnlock()
+		if interval <= 0 {
+			interval = time.Minute
+		}
 
-	// Stop current scheduler
-	d.scheduler.Stop()
+		select {
+		case <-time.After(interval):
+			d.writeMetricsSnapshot()
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
 
-	// Update config and restart scheduler
-	d.config = newConfig
-	d.syncManager = NewSyncManager(newConfig.Global.MaxConcurrentSyncs, d.logger)
-	
-	// Update notification manager with new config
-	d.notificationManager = notification.NewNotificationManager(
-		newConfig.Global.EnableNotifications,
-		newConfig.Global.NotificationTimeout,
-		d.logger,
-	)
-	
-	d.scheduler = NewScheduler(d.logger, d.historyManager, d.notificationManager)
+// writeMetricsSnapshot writes the current metrics to metrics_file, if configured
+func (d *Daemon) writeMetricsSnapshot() {
+	d.mu.RLock()
+	path := d.config.Global.MetricsFile
+	format := d.config.Global.MetricsFormat
+	d.mu.RUnlock()
 
-	// Start with new configuration
-	enabledRepos := make([]config.RepoConfig, 0)
-	for _, repo := range d.config.Repositories {
-		if repo.Enabled {
-			enabledRepos = append(enabledRepos, repo)
-		}
+	if path == "" {
+		return
 	}
 
-	if len(enabledRepos) > 0 {
-		d.scheduler.Start(d.ctx, enabledRepos, d.syncManager)
+	if err := d.metricsSnapshot().WriteFile(path, format); err != nil {
+		d.logger.Error("Failed to write metrics snapshot", "path", path, "error", err)
+		return
 	}
-	
-	d.logger.Info("Configuration reloaded successfully", "repositories", len(enabledRepos))
+	d.logger.Debug("Wrote metrics snapshot", "path", path, "format", format)
+}
 
+// reloadConfig applies newConfig to the running daemon. Only the schedules of
+// repositories and sync sets whose settings changed restart; the rest keep
+// their timing, and the sync slots and in-flight syncs are left alone.
+func (d *Daemon) reloadConfig(newConfig *config.Config) error {
+	d.applyConfig(newConfig)
 	return nil
 }
 
+// applyConfig reloads newConfig as described for reloadConfig, returning what
+// changed in the schedules
+func (d *Daemon) applyConfig(newConfig *config.Config) RescheduleSummary {
+	d.reloadMu.Lock()
+	defer d.reloadMu.Unlock()
+
+	d.logger.Info("Reloading configuration")
+	newConfig = newConfig.ExpandPatterns()
+	// Opens the repositories, so before taking d.mu
+	targets := d.loadPushTargets(newConfig)
+
+	d.mu.Lock()
+	oldGlobal := d.config.Global
+	d.config = newConfig
+	d.applyConfiguredLogLevel()
+	d.syncManager.SetMaxConcurrent(newConfig.Global.MaxConcurrentSyncs)
+	d.syncManager.SetClassLimits(newConfig.Global.MaxIOHeavySyncs, newConfig.Global.MaxLightSyncs)
+	d.syncManager.SetHostLimits(newConfig.Global.MaxSyncsPerHost, time.Duration(newConfig.Global.HostSyncDelay)*time.Second)
+	configureFaults(d.syncManager, newConfig, d.logger)
+	d.notificationManager.Configure(newConfig.Global.EnableNotifications, newConfig.Global.NotificationTimeout)
+	d.notificationManager.SetPolicy(newConfig.Global.NotificationPolicy)
+	if err := d.notificationManager.SetNotifiers(newConfig.Notifiers); err != nil {
+		d.logger.Error("Failed to apply notifiers, keeping the previous ones", "error", err)
+	}
+	d.connectivity.configure(newConfig.Global.PauseWhenOffline, newConfig.Global.OfflineProbe)
+	d.power.configure(newConfig.Global.PauseOnBattery, newConfig.Global.BatteryIntervalMultiplier)
+	d.setNetworkWatch(newConfig.Global.SyncOnNetworkChange)
+	d.configureReporter(newConfig)
+	if err := d.scheduler.SetWebhooks(newConfig.Webhooks); err != nil {
+		d.logger.Error("Failed to apply webhooks, keeping the previous ones", "error", err)
+	}
+	d.pushTargets.Store(targets)
+	enabledRepos := d.enabledRepos()
+	d.mu.Unlock()
+
+	// Schedules read these settings as they run, so they all restart to pick them up
+	var configure func()
+	if schedulingChanged(oldGlobal, newConfig.Global) {
+		d.logger.Info("Scheduling settings changed, rescheduling all repositories")
+		configure = func() { d.configureScheduler(newConfig) }
+	}
+
+	syncSets, standalone := SyncSets(newConfig, enabledRepos)
+	summary := d.scheduler.Reschedule(d.ctx, standalone, syncSets, d.syncManager, configure)
+
+	d.logger.Info("Configuration reloaded successfully",
+		"repositories", len(enabledRepos),
+		"added", summary.Added,
+		"removed", summary.Removed,
+		"rescheduled", summary.Rescheduled,
+		"unchanged", summary.Unchanged)
+
+	d.recordReload(nil)
+	return summary
+}
+
+// configureScheduler applies the global scheduling settings of cfg to the scheduler
+func (d *Daemon) configureScheduler(cfg *config.Config) {
+	d.scheduler.SetLoadSpread(time.Duration(cfg.Global.StartupStagger)*time.Second, cfg.Global.SyncJitter)
+	d.scheduler.SetRetryBackoff(time.Duration(cfg.Global.RetryBackoff)*time.Second, time.Duration(cfg.Global.RetryMaxBackoff)*time.Second)
+	d.scheduler.SetCircuitBreaker(cfg.Global.CircuitBreakerFailures, time.Duration(cfg.Global.CircuitBreakerCooldown)*time.Second)
+}
+
+// schedulingChanged reports whether a global setting applied by configureScheduler changed
+func schedulingChanged(old, new config.GlobalConfig) bool {
+	return old.StartupStagger != new.StartupStagger ||
+		old.SyncJitter != new.SyncJitter ||
+		old.RetryBackoff != new.RetryBackoff ||
+		old.RetryMaxBackoff != new.RetryMaxBackoff ||
+		old.CircuitBreakerFailures != new.CircuitBreakerFailures ||
+		old.CircuitBreakerCooldown != new.CircuitBreakerCooldown
+}
+
 // reloadConfigFromSignal handles SIGHUP-triggered config reloads
 func (d *Daemon) reloadConfigFromSignal() error {
-	newConfig, err := config.LoadConfig("")
+	_, err := d.reloadConfigFile()
+	return err
+}
+
+// reloadConfigFile reads the config file again and applies it
+func (d *Daemon) reloadConfigFile() (RescheduleSummary, error) {
+	newConfig, err := config.LoadConfig(d.configPath)
 	if err != nil {
-		return fmt.Errorf("failed to reload config: %w", err)
+		d.recordReload(err)
+		return RescheduleSummary{}, fmt.Errorf("failed to reload config: %w", err)
 	}
-	return d.reloadConfig(newConfig)
+	return d.applyConfig(newConfig), nil
 }
 
 func (d *Daemon) shutdown() error {
@@ -264,12 +562,36 @@ func (d *Daemon) shutdown() error {
 		d.configWatcher.StopWatching()
 	}
 
+	// Stop accepting control requests
+	if d.httpAPI != nil {
+		d.httpAPI.Stop()
+	}
+	d.stopMetricsServer()
+	d.controlServer.Stop()
+
 	// Cancel context to stop all operations
 	d.cancel()
 
 	// Stop scheduler (with timeout handling built-in)
 	d.scheduler.Stop()
 
+	// Show the digest of the syncs that finished since the last one, and let
+	// the notifiers still sending finish
+	d.notificationManager.FlushDigest()
+	d.notificationManager.Wait()
+
+	// Leave a final snapshot including syncs that finished during shutdown
+	d.writeMetricsSnapshot()
+
+	if d.historyManager != nil {
+		if err := d.historyManager.Close(); err != nil {
+			d.logger.Warn("Failed to close history database", "error", err)
+		}
+	}
+
 	d.logger.Info("Git sync daemon stopped")
+	if d.logFile != nil {
+		d.logFile.Close()
+	}
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,123 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// defaultBranchTTL is how long a resolved remote default branch is trusted
+const defaultBranchTTL = 1 * time.Hour
+
+// fallbackDefaultBranch is used when the remote HEAD cannot be determined
+const fallbackDefaultBranch = "main"
+
+type cachedBranch struct {
+	name       string
+	resolvedAt time.Time
+}
+
+// defaultBranch returns the remote's default branch for the "main" strategy.
+// It asks the remote for its symbolic HEAD, falls back to the local
+// refs/remotes/<remote>/HEAD, and finally to "main". Results are cached per repository.
+func (g *GitOperations) defaultBranch(ctx context.Context, r *git.Repository, repo configPkg.RepoConfig) string {
+	return g.resolveDefaultBranch(repo,
+		func() (string, error) { return g.remoteHeadBranch(ctx, r, repo) },
+		func() (string, error) { return localRemoteHeadBranch(r, repo.Remote) })
+}
+
+// resolveDefaultBranch caches the result of the remote HEAD lookup, falling back
+// to the local remote-tracking HEAD and then "main"
+func (g *GitOperations) resolveDefaultBranch(repo configPkg.RepoConfig, remoteHead, localHead func() (string, error)) string {
+	cacheKey := repo.Path + "\x00" + repo.Remote
+
+	g.mu.Lock()
+	cached, exists := g.defaultBranches[cacheKey]
+	g.mu.Unlock()
+	if exists && time.Since(cached.resolvedAt) < defaultBranchTTL {
+		return cached.name
+	}
+
+	branch, err := remoteHead()
+	if err != nil {
+		g.logger.Debug("Failed to query remote HEAD, trying local remote-tracking HEAD",
+			"repo", repo.Path,
+			"error", err)
+
+		branch, err = localHead()
+		if err != nil {
+			if exists {
+				// Keep using the last known value rather than guessing
+				return cached.name
+			}
+			g.logger.Warn("Could not detect remote default branch, assuming main",
+				"repo", repo.Path,
+				"remote", repo.Remote)
+			return fallbackDefaultBranch
+		}
+	}
+
+	if !exists || cached.name != branch {
+		g.logger.Debug("Detected remote default branch",
+			"repo", repo.Path,
+			"remote", repo.Remote,
+			"branch", branch)
+	}
+
+	g.mu.Lock()
+	g.defaultBranches[cacheKey] = cachedBranch{name: branch, resolvedAt: time.Now()}
+	g.mu.Unlock()
+
+	return branch
+}
+
+// remoteHeadBranch lists the remote's references and resolves its symbolic HEAD
+func (g *GitOperations) remoteHeadBranch(ctx context.Context, r *git.Repository, repo configPkg.RepoConfig) (string, error) {
+	remote, err := r.Remote(repo.Remote)
+	if err != nil {
+		return "", fmt.Errorf("failed to get remote '%s': %w", repo.Remote, err)
+	}
+
+	proxy, caBundle, auth, err := transportOptions(repo)
+	if err != nil {
+		return "", err
+	}
+
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth, ProxyOptions: proxy, CABundle: caBundle})
+	if err != nil {
+		return "", fmt.Errorf("failed to list remote references: %w", err)
+	}
+
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference && ref.Target().IsBranch() {
+			return ref.Target().Short(), nil
+		}
+	}
+
+	return "", fmt.Errorf("remote did not advertise a symbolic HEAD")
+}
+
+// localRemoteHeadBranch reads refs/remotes/<remote>/HEAD as set by git clone
+func localRemoteHeadBranch(r *git.Repository, remoteName string) (string, error) {
+	ref, err := r.Reference(plumbing.NewRemoteHEADReferenceName(remoteName), false)
+	if err != nil {
+		return "", err
+	}
+	if ref.Type() != plumbing.SymbolicReference {
+		return "", fmt.Errorf("remote HEAD is not a symbolic reference")
+	}
+
+	// Target looks like refs/remotes/origin/main
+	prefix := fmt.Sprintf("refs/remotes/%s/", remoteName)
+	target := ref.Target().String()
+	if !strings.HasPrefix(target, prefix) {
+		return "", fmt.Errorf("unexpected remote HEAD target: %s", target)
+	}
+	return strings.TrimPrefix(target, prefix), nil
+}
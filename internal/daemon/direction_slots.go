@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"context"
+	"time"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/cron"
+)
+
+// directionSlot is when one direction of a repository with separate pull and
+// push timing syncs next
+type directionSlot struct {
+	direction string
+	interval  time.Duration
+	schedule  *cron.Schedule
+	timer     *time.Timer
+	next      time.Time
+}
+
+func newDirectionSlot(repo config.RepoConfig, direction string) *directionSlot {
+	interval, expr := repo.DirectionTiming(direction)
+	slot := &directionSlot{direction: direction, interval: time.Duration(interval) * time.Second}
+	if expr != "" {
+		// Validation already rejected bad expressions
+		if schedule, err := cron.Parse(expr); err == nil {
+			slot.schedule = schedule
+		}
+	}
+	return slot
+}
+
+// due reports whether the slot's sync time has come
+func (slot *directionSlot) due(now time.Time) bool {
+	return !slot.next.After(now)
+}
+
+// plan sets the slot's next sync: interval slots after wait, scheduled slots at
+// their next scheduled time
+func (slot *directionSlot) plan(wait time.Duration) {
+	if slot.schedule != nil {
+		next := slot.schedule.Next(time.Now())
+		if next.IsZero() {
+			next = time.Now().Add(24 * time.Hour)
+		}
+		wait = time.Until(next)
+	}
+	slot.next = time.Now().Add(wait)
+	if slot.timer == nil {
+		slot.timer = time.NewTimer(wait)
+	} else {
+		slot.timer.Reset(wait)
+	}
+}
+
+// scheduleDirections schedules a repository that pulls and pushes on separate
+// timing as two slots of one schedule, so its pulls and pushes take turns
+// instead of contending for the repository. Slots due together sync both
+// directions at once, requested syncs do too, and retries repeat the direction
+// that failed. The caller holds s.mutex.
+func (s *Scheduler) scheduleDirections(ctx context.Context, repo config.RepoConfig, sm *SyncManager, offset time.Duration) {
+	pull, push := newDirectionSlot(repo, "pull"), newDirectionSlot(repo, "push")
+	s.logger.Info("Scheduling repository",
+		"repo", repo.Path,
+		"pull_interval", pull.interval,
+		"pull_schedule", repo.PullSchedule,
+		"push_interval", push.interval,
+		"push_schedule", repo.PushSchedule,
+		"start_offset", offset)
+
+	trigger := make(chan struct{}, 1)
+	s.triggers[repo.Path] = trigger
+	ctx, job := s.addJob(ctx, repo.Path, scheduledJob{repo: repo})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(job.done)
+		defer func() {
+			s.mutex.Lock()
+			delete(s.jobs, repo.Path)
+			delete(s.triggers, repo.Path)
+			s.mutex.Unlock()
+
+			s.nextSyncMu.Lock()
+			delete(s.nextSync, repo.Path)
+			s.nextSyncMu.Unlock()
+		}()
+
+		// Interval slots first sync shortly after startup, scheduled ones wait for their schedule
+		slots := []*directionSlot{pull, push}
+		for _, slot := range slots {
+			slot.plan(initialSyncDelay + offset)
+		}
+		defer func() {
+			for _, slot := range slots {
+				slot.timer.Stop()
+			}
+		}()
+
+		var retryTimer *time.Timer
+		var retries <-chan time.Time
+		retryDirection := repo.Direction
+		defer func() {
+			if retryTimer != nil {
+				retryTimer.Stop()
+			}
+		}()
+
+		runSync := func(direction string) {
+			synced := repo
+			synced.Direction = direction
+			s.performSync(synced, sm)
+
+			if retryTimer != nil {
+				retryTimer.Stop()
+			}
+			retryTimer, retries = s.retryTimer(repo.Path)
+			retryDirection = direction
+		}
+
+		// runSlots syncs the directions that are due, then plans their next syncs
+		runSlots := func() {
+			now := time.Now()
+			direction := ""
+			for _, slot := range slots {
+				if slot.due(now) {
+					direction = slot.direction
+				}
+			}
+			if pull.due(now) && push.due(now) {
+				direction = repo.Direction
+			}
+			runSync(direction)
+			for _, slot := range slots {
+				if slot.due(now) {
+					slot.plan(s.jittered(s.power.scale(slot.interval)))
+				}
+			}
+		}
+
+		for {
+			next := pull.next
+			if push.next.Before(next) {
+				next = push.next
+			}
+			s.setNextSync(repo.Path, next)
+
+			select {
+			case <-pull.timer.C:
+				runSlots()
+			case <-push.timer.C:
+				runSlots()
+			case <-trigger:
+				runSync(repo.Direction)
+			case <-retries:
+				runSync(retryDirection)
+			case <-ctx.Done():
+				s.logger.Debug("Context cancelled for repository", "repo", repo.Path)
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,142 @@
+package daemon
+
+import (
+	"strings"
+)
+
+// ErrorKind is a machine-readable class of sync failure, recorded in history so
+// failures of one kind can be found without matching error messages
+type ErrorKind string
+
+const (
+	ErrorAuth       ErrorKind = "auth"       // rejected or missing credentials, unknown host keys
+	ErrorNetwork    ErrorKind = "network"    // DNS, connection and server trouble, usually transient
+	ErrorTimeout    ErrorKind = "timeout"    // an operation ran out of time
+	ErrorConflict   ErrorKind = "conflict"   // diverged branches, merge and stash conflicts, dirty worktrees
+	ErrorRejected   ErrorKind = "rejected"   // the remote refused the push
+	ErrorHook       ErrorKind = "hook"       // a repository hook, run_after_pull or mirror step failed
+	ErrorRepository ErrorKind = "repository" // missing repositories, remotes or branches
+	ErrorConfig     ErrorKind = "config"     // invalid settings or missing git identity
+	ErrorDisk       ErrorKind = "disk"       // full, read-only or inaccessible files
+	ErrorOther      ErrorKind = "other"
+)
+
+// ErrorKinds lists every error kind, in the order they are matched
+var ErrorKinds = []ErrorKind{
+	ErrorHook, ErrorAuth, ErrorTimeout, ErrorNetwork, ErrorRejected,
+	ErrorConflict, ErrorRepository, ErrorConfig, ErrorDisk, ErrorOther,
+}
+
+// errorKindFragments are fragments of git, go-git and git-sync errors by kind.
+// Network errors are the transient ones retries are planned for.
+var errorKindFragments = map[ErrorKind][]string{
+	ErrorHook: {
+		"hook rejected",
+		"hook timed out",
+		"run_after_pull failed",
+		"mirror failed",
+		"mirror timed out",
+	},
+	ErrorAuth: {
+		"authentication required",
+		"authentication failed",
+		"authorization failed",
+		"invalid username or password",
+		"could not read username",
+		"could not read password",
+		"terminal prompts disabled",
+		"permission denied (publickey",
+		"unable to authenticate",
+		"ssh: handshake failed",
+		"knownhosts",
+		"host key verification failed",
+		"401 unauthorized",
+		"403 forbidden",
+		"failed to resolve ${secret:",
+		"failed to load ssh_key",
+	},
+	ErrorTimeout: {
+		"timed out",
+		"deadline exceeded",
+		"i/o timeout",
+	},
+	ErrorNetwork: transientErrors,
+	ErrorRejected: {
+		"non-fast-forward",
+		"[rejected]",
+		"[remote rejected]",
+		"pre-receive hook declined",
+		"protected branch",
+		"fetch first",
+	},
+	ErrorConflict: {
+		"conflict",
+		"diverged",
+		"uncommitted changes",
+		"checked out in another worktree",
+	},
+	ErrorRepository: {
+		"repository not found",
+		"repository does not exist",
+		"repository not exists",
+		"failed to open repository",
+		"not a git repository",
+		"remote not found",
+		"failed to get remote",
+		"failed to find remote branch",
+		"couldn't find remote ref",
+		"reference not found",
+	},
+	ErrorConfig: {
+		"invalid",
+		"unknown git backend",
+		"no git identity",
+		"not configured",
+	},
+	ErrorDisk: {
+		"no space left",
+		"disk quota",
+		"read-only file system",
+		"permission denied",
+		"too many open files",
+	},
+}
+
+// ClassifyError returns the kind of a sync error message, ErrorOther when no
+// kind matches and "" for an empty message
+func ClassifyError(msg string) ErrorKind {
+	if msg == "" {
+		return ""
+	}
+	msg = strings.ToLower(msg)
+	for _, kind := range ErrorKinds {
+		for _, fragment := range errorKindFragments[kind] {
+			if strings.Contains(msg, fragment) {
+				return kind
+			}
+		}
+	}
+	return ErrorOther
+}
+
+// Kind returns the error kind of a failed entry, classifying entries recorded
+// before kinds were, and "" for entries that didn't fail
+func (e SyncHistoryEntry) Kind() ErrorKind {
+	if e.Status != "failed" {
+		return ""
+	}
+	if e.ErrorKind != "" {
+		return ErrorKind(e.ErrorKind)
+	}
+	return ClassifyError(e.ErrorMsg)
+}
+
+// ParseErrorKind validates an error kind name
+func ParseErrorKind(name string) (ErrorKind, bool) {
+	for _, kind := range ErrorKinds {
+		if string(kind) == strings.ToLower(name) {
+			return kind, true
+		}
+	}
+	return "", false
+}
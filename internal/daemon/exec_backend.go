@@ -0,0 +1,789 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// ExecBackend syncs by running the git binary instead of go-git, so linked
+// worktrees, credential helpers, hooks and LFS behave exactly as in a shell.
+// It follows the same direction, branch strategy and safety rules as go-git.
+type ExecBackend struct {
+	ops *GitOperations // shared logger, progress tracking, default branch cache and helpers
+}
+
+// NewExecBackend creates a git binary backend sharing state with ops
+func NewExecBackend(ops *GitOperations) *ExecBackend {
+	return &ExecBackend{ops: ops}
+}
+
+// Name implements GitBackend
+func (b *ExecBackend) Name() string {
+	return BackendGit
+}
+
+// SyncRepository performs the sync operation using the git binary
+func (b *ExecBackend) SyncRepository(ctx context.Context, repo configPkg.RepoConfig) error {
+	b.ops.logger.Info("Starting sync with git binary",
+		"repo", repo.Path,
+		"direction", repo.Direction)
+
+	// Check context before starting
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	// Refuse repositories owned by other users, like git's safe.directory
+	if err := b.ops.checkOwnership(ctx, repo); err != nil {
+		return err
+	}
+
+	bare, err := b.git(ctx, repo, "rev-parse", "--is-bare-repository")
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	// Track transfer progress for status, and the pack data fetched for history
+	b.ops.progress.begin(repo.Path)
+	packDir := b.packDir(ctx, repo)
+	packsBefore := packDirSize(packDir)
+	refsBefore := b.refSnapshot(ctx, repo)
+	defer func() {
+		moved := movedRefs(refsBefore, b.refSnapshot(context.Background(), repo))
+		b.ops.progress.end(repo.Path, max(packDirSize(packDir)-packsBefore, 0), moved)
+	}()
+
+	// Bare repositories have no worktree and use fetch/push-only flows
+	if bare == "true" {
+		return b.syncBareRepository(ctx, repo)
+	}
+
+	// Detached HEAD: sync via the configured branch or skip
+	if _, err := b.git(ctx, repo, "symbolic-ref", "-q", "HEAD"); err != nil {
+		repo, err = b.ops.resolveDetachedHead(repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Safety checks
+	var restore func() error
+	if repo.SafetyChecks {
+		restore, err = b.performSafetyChecks(ctx, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	headBefore := b.ops.headBeforePull(ctx, repo)
+
+	syncErr := b.syncDirection(ctx, repo)
+
+	// Restore stashed changes even when the sync itself failed
+	if restore != nil {
+		if err := restore(); err != nil {
+			if syncErr != nil {
+				return fmt.Errorf("%w (additionally: %v)", syncErr, err)
+			}
+			return err
+		}
+	}
+	if syncErr != nil {
+		return syncErr
+	}
+
+	// Follow-up actions see the final worktree, with stashed changes back in place
+	return b.ops.runAfterPull(ctx, repo, headBefore)
+}
+
+// syncDirection executes the sync based on the configured direction
+func (b *ExecBackend) syncDirection(ctx context.Context, repo configPkg.RepoConfig) error {
+	switch repo.Direction {
+	case "push":
+		return b.withUnshallowRetry(ctx, repo, func(repo configPkg.RepoConfig) error {
+			return b.push(ctx, repo)
+		})
+	case "pull":
+		return b.withUnshallowRetry(ctx, repo, func(repo configPkg.RepoConfig) error {
+			return b.pull(ctx, repo)
+		})
+	case "both":
+		err := b.withUnshallowRetry(ctx, repo, func(repo configPkg.RepoConfig) error {
+			return b.pull(ctx, repo)
+		})
+		if err != nil {
+			return fmt.Errorf("pull failed: %w", err)
+		}
+		return b.withUnshallowRetry(ctx, repo, func(repo configPkg.RepoConfig) error {
+			return b.push(ctx, repo)
+		})
+	default:
+		return fmt.Errorf("invalid direction: %s", repo.Direction)
+	}
+}
+
+// syncBareRepository mirrors the go-git bare flow: pulls fetch straight into local branches
+func (b *ExecBackend) syncBareRepository(ctx context.Context, repo configPkg.RepoConfig) error {
+	b.ops.logger.Debug("Bare repository, using fetch/push-only flow", "repo", repo.Path)
+
+	if repo.BranchStrategy == "specific" && len(repo.PushRefSpecs) == 0 {
+		repo.PushRefSpecs = []string{fmt.Sprintf("refs/heads/%s:refs/heads/%s", repo.TargetBranch, repo.TargetBranch)}
+	}
+
+	fetchBare := func() error {
+		if len(repo.FetchRefSpecs) > 0 {
+			return b.fetch(ctx, repo, repo.FetchRefSpecs)
+		}
+		refSpecs, err := b.bareFetchRefSpecs(ctx, repo)
+		if err != nil {
+			return err
+		}
+		return b.fetch(ctx, repo, refSpecs)
+	}
+
+	switch repo.Direction {
+	case "push":
+		return b.push(ctx, repo)
+	case "pull":
+		return fetchBare()
+	case "both":
+		if err := fetchBare(); err != nil {
+			return fmt.Errorf("pull failed: %w", err)
+		}
+		return b.push(ctx, repo)
+	default:
+		return fmt.Errorf("invalid direction: %s", repo.Direction)
+	}
+}
+
+// bareFetchRefSpecs maps the branch strategy onto non-forced refspecs updating local branches
+func (b *ExecBackend) bareFetchRefSpecs(ctx context.Context, repo configPkg.RepoConfig) ([]string, error) {
+	var branch string
+	switch repo.BranchStrategy {
+	case "current":
+		current, err := b.git(ctx, repo, "symbolic-ref", "--short", "HEAD")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current branch: %w", err)
+		}
+		branch = current
+	case "main":
+		branch = b.defaultBranch(ctx, repo)
+	case "specific":
+		branch = repo.TargetBranch
+	case "all":
+		// Mirrors already configure their own fetch refspecs, keep those
+		if specs, err := b.git(ctx, repo, "config", "--get-all", "remote."+repo.Remote+".fetch"); err == nil && specs != "" {
+			return strings.Split(specs, "\n"), nil
+		}
+		return []string{"refs/heads/*:refs/heads/*"}, nil
+	default:
+		return nil, fmt.Errorf("invalid branch strategy: %s", repo.BranchStrategy)
+	}
+	return []string{fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)}, nil
+}
+
+// performSafetyChecks verifies the worktree is clean, applying the configured
+// dirty_worktree_action otherwise. The returned restore function, if any, must
+// be called once the sync has finished.
+func (b *ExecBackend) performSafetyChecks(ctx context.Context, repo configPkg.RepoConfig) (func() error, error) {
+	status, err := b.git(ctx, repo, "status", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree status: %w", err)
+	}
+
+	if status == "" || repo.ForcePush {
+		return nil, nil
+	}
+
+	switch repo.DirtyWorktreeAction {
+	case "stash":
+		return b.ops.stashChanges(ctx, repo)
+	case "commit":
+		return nil, b.commitChanges(ctx, repo)
+	default:
+		return nil, newSkipError(SkipDirtyWorktree, "repository has uncommitted changes, skipping sync")
+	}
+}
+
+// commitChanges commits all local changes so they are included in the sync
+func (b *ExecBackend) commitChanges(ctx context.Context, repo configPkg.RepoConfig) error {
+	if _, err := b.git(ctx, repo, "add", "--all"); err != nil {
+		return fmt.Errorf("failed to stage local changes: %w", err)
+	}
+
+	message := fmt.Sprintf("git-sync: auto-commit %s", time.Now().Format(time.RFC3339))
+	if _, err := b.git(ctx, repo, "commit", "--quiet", "-m", message); err != nil {
+		return fmt.Errorf("failed to auto-commit local changes: %w", err)
+	}
+
+	commit, _ := b.git(ctx, repo, "rev-parse", "--short", "HEAD")
+	b.ops.logger.Info("Auto-committed local changes before sync",
+		"repo", repo.Path,
+		"commit", commit)
+
+	return nil
+}
+
+func (b *ExecBackend) push(ctx context.Context, repo configPkg.RepoConfig) error {
+	// Check context before starting
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	refSpecs, err := b.pushRefSpecs(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	// Skip the network round trip when there is nothing new to push
+	if !b.pushNeeded(ctx, repo, refSpecs) {
+		return nil
+	}
+
+	// Keep commits that look like they contain credentials local
+	if err := scanOutgoing(ctx, repo, refSpecs); err != nil {
+		return err
+	}
+
+	args := []string{"push", "--porcelain", "--progress"}
+	if b.ops.forcePushAllowed(repo) {
+		args = append(args, "--force")
+	}
+	args = append(args, repo.Remote)
+	args = append(args, refSpecs...)
+
+	output, err := b.gitTransfer(ctx, repo, args...)
+	if err != nil {
+		if rejected := pushRejections(output); rejected != "" {
+			return fmt.Errorf("git push failed: %s: %w", rejected, err)
+		}
+		return fmt.Errorf("git push failed: %w", err)
+	}
+
+	if pushUpToDate(output) {
+		b.ops.logger.Debug("Push: already up to date", "repo", repo.Path)
+		return nil
+	}
+
+	b.ops.logger.Info("Push successful",
+		"repo", repo.Path,
+		"strategy", repo.BranchStrategy,
+		"custom_refspecs", len(repo.PushRefSpecs) > 0)
+
+	return nil
+}
+
+// pushRefSpecs maps the branch strategy onto push refspecs. Unlike go-git, git can
+// push a branch that is not checked out, so "specific" needs no branch switch.
+func (b *ExecBackend) pushRefSpecs(ctx context.Context, repo configPkg.RepoConfig) ([]string, error) {
+	if len(repo.PushRefSpecs) > 0 {
+		return repo.PushRefSpecs, nil
+	}
+
+	var branch string
+	switch repo.BranchStrategy {
+	case "current":
+		current, err := b.git(ctx, repo, "symbolic-ref", "--short", "HEAD")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current branch: %w", err)
+		}
+		branch = current
+	case "main":
+		branch = b.defaultBranch(ctx, repo)
+	case "specific":
+		branch = repo.TargetBranch
+	case "all":
+		return []string{"refs/heads/*:refs/heads/*"}, nil
+	default:
+		return nil, fmt.Errorf("invalid branch strategy: %s", repo.BranchStrategy)
+	}
+	return []string{fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)}, nil
+}
+
+// pushNeeded reports whether a branch the push would send differs from its
+// remote-tracking ref, like the go-git backend. Custom refspecs and force_push always push.
+func (b *ExecBackend) pushNeeded(ctx context.Context, repo configPkg.RepoConfig, refSpecs []string) bool {
+	if len(repo.PushRefSpecs) > 0 || repo.ForcePush {
+		return true
+	}
+
+	listing, err := b.git(ctx, repo, "for-each-ref", "--format=%(refname)%09%(objectname)",
+		"refs/heads", "refs/remotes/"+repo.Remote)
+	if err != nil {
+		return true
+	}
+	hashes := make(map[string]string)
+	for _, line := range strings.Split(listing, "\n") {
+		if name, hash, ok := strings.Cut(line, "\t"); ok {
+			hashes[name] = hash
+		}
+	}
+
+	// The "all" refspec pushes every local branch
+	var branches []string
+	if len(refSpecs) == 1 && refSpecs[0] == "refs/heads/*:refs/heads/*" {
+		for name := range hashes {
+			if branch, ok := strings.CutPrefix(name, "refs/heads/"); ok {
+				branches = append(branches, branch)
+			}
+		}
+	} else {
+		for _, spec := range refSpecs {
+			src, _, _ := strings.Cut(spec, ":")
+			branches = append(branches, strings.TrimPrefix(src, "refs/heads/"))
+		}
+	}
+
+	for _, branch := range branches {
+		local, exists := hashes["refs/heads/"+branch]
+		if !exists || hashes["refs/remotes/"+repo.Remote+"/"+branch] != local {
+			return true
+		}
+	}
+
+	b.ops.logger.Debug("Push: nothing to push, branches match their remote-tracking refs",
+		"repo", repo.Path)
+	return false
+}
+
+// refSnapshot maps the names of all references to the commits they point at,
+// so comparing snapshots taken before and after a sync tells what it changed
+func (b *ExecBackend) refSnapshot(ctx context.Context, repo configPkg.RepoConfig) map[string]string {
+	refs := make(map[string]string)
+	output, _ := b.git(ctx, repo, "for-each-ref", "--format=%(refname) %(objectname)")
+	for _, line := range strings.Split(output, "\n") {
+		if name, hash, found := strings.Cut(line, " "); found {
+			refs[name] = hash
+		}
+	}
+	return refs
+}
+
+// pushUpToDate reports whether porcelain push output lists only up-to-date refs
+func pushUpToDate(output string) bool {
+	updated := false
+	for _, line := range strings.Split(output, "\n") {
+		if len(line) < 2 || line[1] != '\t' {
+			continue // "To <url>" and "Done" lines
+		}
+		if line[0] != '=' {
+			return false
+		}
+		updated = true
+	}
+	return updated
+}
+
+// pushRejections lists the refs a porcelain push rejected, with git's reason
+func pushRejections(output string) string {
+	var rejected []string
+	for _, line := range strings.Split(output, "\n") {
+		if fields := strings.Split(line, "\t"); len(fields) == 3 && fields[0] == "!" {
+			rejected = append(rejected, fmt.Sprintf("%s %s", fields[1], fields[2]))
+		}
+	}
+	return strings.Join(rejected, ", ")
+}
+
+func (b *ExecBackend) pull(ctx context.Context, repo configPkg.RepoConfig) error {
+	// Check context before starting
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	// Custom fetch refspecs bypass the branch strategy and only fetch
+	if len(repo.FetchRefSpecs) > 0 {
+		return b.fetch(ctx, repo, repo.FetchRefSpecs)
+	}
+
+	switch repo.BranchStrategy {
+	case "all":
+		// Fetch everything and fast-forward local branches behind their upstream
+		if err := b.fetch(ctx, repo, nil); err != nil {
+			return err
+		}
+		return b.fastForwardBranches(ctx, repo)
+	case "main":
+		// The remote's default branch, which may be master or anything else
+		repo.TargetBranch = b.defaultBranch(ctx, repo)
+		return b.withBranchSwitch(ctx, repo, func() error {
+			return b.pullBranch(ctx, repo, repo.TargetBranch)
+		})
+	case "specific":
+		return b.withBranchSwitch(ctx, repo, func() error {
+			return b.pullBranch(ctx, repo, repo.TargetBranch)
+		})
+	default:
+		// Pull the remote branch matching the current one, not the remote HEAD
+		branch, err := b.git(ctx, repo, "symbolic-ref", "--short", "HEAD")
+		if err != nil {
+			return fmt.Errorf("failed to get current branch: %w", err)
+		}
+		return b.pullBranch(ctx, repo, branch)
+	}
+}
+
+// pullBranch fast-forwards the checked-out branch to the remote branch, falling
+// back to the union merge driver when it diverged and patterns are configured
+func (b *ExecBackend) pullBranch(ctx context.Context, repo configPkg.RepoConfig, branch string) error {
+	args := []string{"pull", "--ff-only", "--no-rebase", "--progress"}
+	if depth := b.fetchDepth(ctx, repo); depth > 0 {
+		args = append(args, "--depth", fmt.Sprint(depth))
+	}
+	args = append(args, repo.Remote, branch)
+
+	output, err := b.gitTransfer(ctx, repo, args...)
+	if err != nil {
+		msg := err.Error()
+		if strings.Contains(msg, "couldn't find remote ref") {
+			b.ops.logger.Info("Remote branch does not exist yet",
+				"repo", repo.Path,
+				"branch", branch)
+			return nil
+		}
+		if strings.Contains(msg, "fast-forward") && len(repo.UnionMergePatterns) > 0 {
+			return b.ops.mergeWithUnionDriver(ctx, repo, branch)
+		}
+		return fmt.Errorf("git pull failed: %w", err)
+	}
+
+	if strings.Contains(output, "Already up to date") {
+		b.ops.logger.Debug("Pull: already up to date", "repo", repo.Path)
+		return nil
+	}
+
+	b.ops.logger.Info("Pull successful",
+		"repo", repo.Path,
+		"branch", branch)
+
+	return nil
+}
+
+// fetch fetches from the sync remote, using its configured refspecs when none are given
+func (b *ExecBackend) fetch(ctx context.Context, repo configPkg.RepoConfig, refSpecs []string) error {
+	args := []string{"fetch", "--progress"}
+	if depth := b.fetchDepth(ctx, repo); depth > 0 {
+		args = append(args, "--depth", fmt.Sprint(depth))
+	}
+	args = append(args, repo.Remote)
+	args = append(args, refSpecs...)
+
+	if _, err := b.gitTransfer(ctx, repo, args...); err != nil {
+		return fmt.Errorf("git fetch failed: %w", err)
+	}
+
+	b.ops.logger.Info("Fetch successful", "repo", repo.Path)
+	return nil
+}
+
+// fastForwardBranches advances every local branch tracking the sync remote that
+// is strictly behind its upstream. Diverged branches are left untouched. The
+// checked-out branch is only advanced when the worktree is clean.
+func (b *ExecBackend) fastForwardBranches(ctx context.Context, repo configPkg.RepoConfig) error {
+	listing, err := b.git(ctx, repo, "for-each-ref",
+		"--format=%(refname:short)%09%(objectname)%09%(upstream:remotename)%09%(upstream)", "refs/heads")
+	if err != nil {
+		return fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	current, _ := b.git(ctx, repo, "symbolic-ref", "--short", "-q", "HEAD")
+	siblings := b.ops.siblingWorktreeBranches(ctx, repo)
+
+	var advanced, diverged []string
+	for _, line := range strings.Split(listing, "\n") {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 || fields[2] != repo.Remote || fields[3] == "" {
+			continue
+		}
+		name, localHash, upstream := fields[0], fields[1], fields[3]
+
+		upstreamHash, err := b.git(ctx, repo, "rev-parse", "-q", "--verify", upstream+"^{commit}")
+		if err != nil || upstreamHash == localHash {
+			continue
+		}
+
+		if _, err := b.git(ctx, repo, "merge-base", "--is-ancestor", localHash, upstreamHash); err != nil {
+			diverged = append(diverged, name)
+			continue
+		}
+
+		if path, busy := siblings[name]; busy {
+			b.ops.logger.Info("Skipping fast-forward of branch checked out in another worktree",
+				"repo", repo.Path,
+				"branch", name,
+				"worktree", path)
+			continue
+		}
+
+		if name == current {
+			if status, err := b.git(ctx, repo, "status", "--porcelain", "--untracked-files=no"); err != nil || status != "" {
+				b.ops.logger.Warn("Skipping fast-forward of checked-out branch",
+					"repo", repo.Path,
+					"branch", name,
+					"reason", "worktree has uncommitted changes")
+				continue
+			}
+			if _, err := b.git(ctx, repo, "merge", "--ff-only", "--quiet", upstreamHash); err != nil {
+				return fmt.Errorf("failed to fast-forward branch '%s': %w", name, err)
+			}
+		} else if _, err := b.git(ctx, repo, "update-ref", "refs/heads/"+name, upstreamHash, localHash); err != nil {
+			return fmt.Errorf("failed to fast-forward branch '%s': %w", name, err)
+		}
+		advanced = append(advanced, name)
+	}
+
+	if len(diverged) > 0 {
+		b.ops.logger.Warn("Branches diverged from upstream, not fast-forwarded",
+			"repo", repo.Path,
+			"branches", diverged)
+	}
+	if len(advanced) > 0 {
+		b.ops.logger.Info("Fast-forwarded branches",
+			"repo", repo.Path,
+			"branches", advanced)
+	}
+
+	return nil
+}
+
+// withBranchSwitch executes operation with the target branch checked out,
+// switching back (or to the original detached commit) afterwards
+func (b *ExecBackend) withBranchSwitch(ctx context.Context, repo configPkg.RepoConfig, operation func() error) error {
+	currentBranch, err := b.git(ctx, repo, "symbolic-ref", "--short", "-q", "HEAD")
+	detached := err != nil
+
+	// If we're already on the target branch, just execute the operation
+	if !detached && currentBranch == repo.TargetBranch {
+		return operation()
+	}
+
+	if path, busy := b.ops.siblingWorktreeBranches(ctx, repo)[repo.TargetBranch]; busy {
+		return fmt.Errorf("branch '%s' is checked out in another worktree (%s), not switching to it", repo.TargetBranch, path)
+	}
+
+	original := currentBranch
+	if detached {
+		original, err = b.git(ctx, repo, "rev-parse", "HEAD")
+		if err != nil {
+			return fmt.Errorf("failed to get current commit: %w", err)
+		}
+	}
+
+	b.ops.logger.Debug("Switching to target branch",
+		"from", original,
+		"to", repo.TargetBranch,
+		"repo", repo.Path)
+
+	status, err := b.git(ctx, repo, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return fmt.Errorf("failed to get worktree status: %w", err)
+	}
+	if status != "" {
+		return fmt.Errorf("cannot switch branches due to uncommitted changes")
+	}
+
+	// A branch that only exists remotely is fetched and created tracking the remote
+	if _, err := b.git(ctx, repo, "checkout", "--quiet", repo.TargetBranch); err != nil {
+		remoteBranch := repo.Remote + "/" + repo.TargetBranch
+		fetchSpec := fmt.Sprintf("refs/heads/%s:refs/remotes/%s", repo.TargetBranch, remoteBranch)
+		if err := b.fetch(ctx, repo, []string{fetchSpec}); err != nil {
+			return err
+		}
+		if _, err := b.git(ctx, repo, "checkout", "--quiet", "-b", repo.TargetBranch, "--track", remoteBranch); err != nil {
+			return fmt.Errorf("failed to create and checkout branch '%s': %w", repo.TargetBranch, err)
+		}
+	}
+
+	defer func() {
+		args := []string{"checkout", "--quiet", original}
+		if detached {
+			args = []string{"checkout", "--quiet", "--detach", original}
+		}
+		// Switching back must not be interrupted by daemon shutdown
+		if _, switchErr := b.git(context.Background(), repo, args...); switchErr != nil {
+			b.ops.logger.Error("Failed to switch back to original branch",
+				"original", original,
+				"error", switchErr,
+				"repo", repo.Path)
+		} else {
+			b.ops.logger.Debug("Switched back to original branch",
+				"branch", original,
+				"repo", repo.Path)
+		}
+	}()
+
+	return operation()
+}
+
+// defaultBranch returns the remote's default branch for the "main" strategy,
+// sharing the go-git backend's cache
+func (b *ExecBackend) defaultBranch(ctx context.Context, repo configPkg.RepoConfig) string {
+	return b.ops.resolveDefaultBranch(repo,
+		func() (string, error) {
+			// Output looks like "ref: refs/heads/main\tHEAD"
+			credentials, err := credentialEnv(repo)
+			if err != nil {
+				return "", err
+			}
+			output, err := b.runGit(ctx, repo, nil, credentials, "ls-remote", "--symref", repo.Remote, "HEAD")
+			if err != nil {
+				return "", fmt.Errorf("failed to list remote references: %w", err)
+			}
+			for _, line := range strings.Split(output, "\n") {
+				if target, ok := strings.CutPrefix(line, "ref: refs/heads/"); ok {
+					return strings.TrimSuffix(target, "\tHEAD"), nil
+				}
+			}
+			return "", fmt.Errorf("remote did not advertise a symbolic HEAD")
+		},
+		func() (string, error) {
+			target, err := b.git(ctx, repo, "symbolic-ref", "refs/remotes/"+repo.Remote+"/HEAD")
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimPrefix(target, "refs/remotes/"+repo.Remote+"/"), nil
+		})
+}
+
+// fetchDepth returns the depth to request, applied like the go-git backend:
+// only while the repository is shallow or has no history yet
+func (b *ExecBackend) fetchDepth(ctx context.Context, repo configPkg.RepoConfig) int {
+	if repo.FetchDepth <= 0 {
+		return 0
+	}
+	if b.isShallow(ctx, repo) {
+		return repo.FetchDepth
+	}
+	if _, err := b.git(ctx, repo, "rev-parse", "-q", "--verify", "HEAD"); err != nil {
+		return repo.FetchDepth
+	}
+	return 0
+}
+
+func (b *ExecBackend) isShallow(ctx context.Context, repo configPkg.RepoConfig) bool {
+	shallow, err := b.git(ctx, repo, "rev-parse", "--is-shallow-repository")
+	return err == nil && shallow == "true"
+}
+
+// withUnshallowRetry runs op and, when it fails in a shallow repository for
+// lack of history, fetches the full history once and retries
+func (b *ExecBackend) withUnshallowRetry(ctx context.Context, repo configPkg.RepoConfig, op func(configPkg.RepoConfig) error) error {
+	err := op(repo)
+	if err == nil || !b.isShallow(ctx, repo) || !needsFullHistory(err) {
+		return err
+	}
+
+	b.ops.logger.Info("Shallow history is insufficient, fetching full history",
+		"repo", repo.Path,
+		"reason", err)
+
+	if _, unshallowErr := b.gitTransfer(ctx, repo, "fetch", "--progress", "--unshallow", repo.Remote); unshallowErr != nil {
+		return fmt.Errorf("%w (unshallow failed: %v)", err, unshallowErr)
+	}
+
+	repo.FetchDepth = 0
+	return op(repo)
+}
+
+// packDir returns the object pack directory shared by all worktrees of the repository
+func (b *ExecBackend) packDir(ctx context.Context, repo configPkg.RepoConfig) string {
+	dir, err := b.git(ctx, repo, "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "objects", "pack")
+}
+
+// git runs a local git command with the repository's -c options
+func (b *ExecBackend) git(ctx context.Context, repo configPkg.RepoConfig, args ...string) (string, error) {
+	return b.runGit(ctx, repo, nil, nil, args...)
+}
+
+// gitTransfer runs a network git command, streaming its progress to the tracker
+// and recording the rate limits HTTP remotes report
+func (b *ExecBackend) gitTransfer(ctx context.Context, repo configPkg.RepoConfig, args ...string) (string, error) {
+	progress := b.ops.progress.writer(repo.Path)
+	credentials, err := credentialEnv(repo)
+	if err != nil {
+		return "", err
+	}
+
+	trace, err := os.CreateTemp("", "git-sync-curl-*")
+	if err != nil {
+		return b.runGit(ctx, repo, progress, credentials, args...)
+	}
+	trace.Close()
+	defer os.Remove(trace.Name())
+
+	output, err := b.runGit(ctx, repo, progress, append(credentials, curlTraceEnv(trace.Name())...), args...)
+	observeCurlTrace(trace.Name())
+	return output, err
+}
+
+// runGit runs git non-interactively: prompts for credentials fail instead of
+// blocking the daemon, so only credential helpers and keys can authenticate
+func (b *ExecBackend) runGit(ctx context.Context, repo configPkg.RepoConfig, progress io.Writer, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append(gitConfigArgs(repo), args...)...)
+	cmd.Dir = repo.Path
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if progress != nil {
+		cmd.Stderr = io.MultiWriter(&stderr, progress)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := lastLines(stderr.String(), 3); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+
+	// Output is returned on failure too, e.g. for porcelain push results
+	return strings.TrimSpace(string(output)), err
+}
+
+// lastLines returns the last n lines of git's stderr worth reporting: its fatal and
+// error lines when there are any, otherwise anything but progress output
+func lastLines(stderr string, n int) string {
+	var lines, errorLines []string
+	for _, line := range strings.FieldsFunc(stderr, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || progressLine.MatchString(strings.TrimPrefix(line, "remote: ")) {
+			continue
+		}
+		lines = append(lines, line)
+		if strings.HasPrefix(line, "fatal:") || strings.HasPrefix(line, "error:") {
+			errorLines = append(errorLines, line)
+		}
+	}
+	if len(errorLines) > 0 {
+		lines = errorLines
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "; ")
+}
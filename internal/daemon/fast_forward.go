@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// fastForwardBranches advances every local branch tracking the sync remote that
+// is strictly behind its upstream. Diverged branches are left untouched. The
+// checked-out branch is only advanced when the worktree is clean.
+func (g *GitOperations) fastForwardBranches(ctx context.Context, r *git.Repository, w *git.Worktree, repo configPkg.RepoConfig) error {
+	cfg, err := r.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read repository config: %w", err)
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		head = nil // empty or unborn repository, no checked-out branch to protect
+	}
+
+	siblings := g.siblingWorktreeBranches(ctx, repo)
+
+	// Sort for deterministic logging
+	names := make([]string, 0, len(cfg.Branches))
+	for name := range cfg.Branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var advanced, diverged []string
+	for _, name := range names {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		branch := cfg.Branches[name]
+		if branch.Remote != repo.Remote || !branch.Merge.IsBranch() {
+			continue
+		}
+
+		localName := plumbing.NewBranchReferenceName(name)
+		localRef, err := r.Reference(localName, true)
+		if err != nil {
+			continue
+		}
+		upstreamRef, err := r.Reference(plumbing.NewRemoteReferenceName(repo.Remote, branch.Merge.Short()), true)
+		if err != nil {
+			continue
+		}
+		if localRef.Hash() == upstreamRef.Hash() {
+			continue
+		}
+
+		ff, err := isAncestor(r, localRef.Hash(), upstreamRef.Hash())
+		if err != nil {
+			g.logger.Debug("Failed to compare branch with upstream",
+				"repo", repo.Path,
+				"branch", name,
+				"error", err)
+			continue
+		}
+		if !ff {
+			diverged = append(diverged, name)
+			continue
+		}
+
+		if path, busy := siblings[name]; busy {
+			g.logger.Info("Skipping fast-forward of branch checked out in another worktree",
+				"repo", repo.Path,
+				"branch", name,
+				"worktree", path)
+			continue
+		}
+
+		if head != nil && head.Name() == localName {
+			if err := g.fastForwardCheckedOut(w, upstreamRef.Hash()); err != nil {
+				g.logger.Warn("Skipping fast-forward of checked-out branch",
+					"repo", repo.Path,
+					"branch", name,
+					"reason", err)
+				continue
+			}
+		} else {
+			newRef := plumbing.NewHashReference(localName, upstreamRef.Hash())
+			if err := r.Storer.CheckAndSetReference(newRef, localRef); err != nil {
+				return fmt.Errorf("failed to fast-forward branch '%s': %w", name, err)
+			}
+		}
+		advanced = append(advanced, name)
+	}
+
+	if len(diverged) > 0 {
+		g.logger.Warn("Branches diverged from upstream, not fast-forwarded",
+			"repo", repo.Path,
+			"branches", diverged)
+	}
+	if len(advanced) > 0 {
+		g.logger.Info("Fast-forwarded branches",
+			"repo", repo.Path,
+			"branches", advanced)
+	}
+
+	return nil
+}
+
+// fastForwardCheckedOut moves the checked-out branch and updates the worktree
+func (g *GitOperations) fastForwardCheckedOut(w *git.Worktree, target plumbing.Hash) error {
+	status, err := w.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree status: %w", err)
+	}
+	if !status.IsClean() {
+		return fmt.Errorf("worktree has uncommitted changes")
+	}
+	return w.Reset(&git.ResetOptions{Commit: target, Mode: git.MergeReset})
+}
+
+// isAncestor reports whether ancestor is reachable from descendant
+func isAncestor(r *git.Repository, ancestor, descendant plumbing.Hash) (bool, error) {
+	ancestorCommit, err := r.CommitObject(ancestor)
+	if err != nil {
+		return false, err
+	}
+	descendantCommit, err := r.CommitObject(descendant)
+	if err != nil {
+		return false, err
+	}
+	return ancestorCommit.IsAncestor(descendantCommit)
+}
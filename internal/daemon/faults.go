@@ -0,0 +1,151 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bnema/git-sync/internal/config"
+)
+
+// FaultsEnv enables fault injection, overriding fault_injection in the config
+const FaultsEnv = "GIT_SYNC_FAULTS"
+
+// errInjectedNetwork reads like a dropped connection, so it is retried like one
+var errInjectedNetwork = errors.New("injected fault: connection reset by peer")
+
+// faults injects artificial delays and failures into syncs, to exercise retries,
+// the circuit breaker, notifications and history without broken remotes. The
+// spec is a comma-separated list such as "fail=0.2,network=0.3,delay=5s":
+//
+//	fail=P     fail the sync with a permanent error, with probability P
+//	network=P  fail the sync with a transient network error, with probability P
+//	delay=D    hold the sync slot for up to D before syncing
+//	repos=A|B  only affect repositories with these directory names
+//	seed=N     make the injected faults reproducible
+type faults struct {
+	fail    float64
+	network float64
+	delay   time.Duration
+	repos   []string
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// parseFaults parses a fault spec; an empty spec injects nothing
+func parseFaults(spec string) (*faults, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	f := &faults{rng: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))}
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid fault '%s': expected key=value", field)
+		}
+
+		var err error
+		switch key {
+		case "fail":
+			f.fail, err = parseProbability(value)
+		case "network":
+			f.network, err = parseProbability(value)
+		case "delay":
+			f.delay, err = time.ParseDuration(value)
+			if err == nil && f.delay < 0 {
+				err = errors.New("cannot be negative")
+			}
+		case "repos":
+			f.repos = strings.Split(value, "|")
+		case "seed":
+			var seed uint64
+			seed, err = strconv.ParseUint(value, 10, 64)
+			f.rng = rand.New(rand.NewPCG(seed, seed))
+		default:
+			return nil, fmt.Errorf("unknown fault '%s': must be fail, network, delay, repos or seed", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid fault %s '%s': %w", key, value, err)
+		}
+	}
+	return f, nil
+}
+
+func parseProbability(value string) (float64, error) {
+	p, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if p < 0 || p > 1 {
+		return 0, errors.New("must be between 0 and 1")
+	}
+	return p, nil
+}
+
+// inject delays a sync of repoPath and returns the failure to report instead of
+// syncing, if one is drawn
+func (f *faults) inject(ctx context.Context, repoPath string) error {
+	if f == nil {
+		return nil
+	}
+	if len(f.repos) > 0 && !slices.Contains(f.repos, filepath.Base(repoPath)) {
+		return nil
+	}
+
+	f.mu.Lock()
+	delay := time.Duration(0)
+	if f.delay > 0 {
+		delay = time.Duration(f.rng.Int64N(int64(f.delay) + 1))
+	}
+	roll := f.rng.Float64()
+	f.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	switch {
+	case roll < f.fail:
+		return errors.New("injected fault: sync failed")
+	case roll < f.fail+f.network:
+		return fmt.Errorf("failed to fetch: %w", errInjectedNetwork)
+	}
+	return nil
+}
+
+// configureFaults enables the fault injection set in GIT_SYNC_FAULTS or the
+// config on sm. It is meant for testing and warns loudly when enabled.
+func configureFaults(sm *SyncManager, cfg *config.Config, logger *slog.Logger) {
+	spec := cfg.Global.FaultInjection
+	if env, set := os.LookupEnv(FaultsEnv); set {
+		spec = env
+	}
+
+	f, err := parseFaults(spec)
+	if err != nil {
+		logger.Error("Ignoring fault injection", "error", err)
+		return
+	}
+	if f != nil {
+		logger.Warn("Fault injection enabled, syncs will fail on purpose", "faults", spec)
+	}
+	sm.faults.Store(f)
+}
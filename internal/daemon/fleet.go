@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/fleet"
+	"github.com/bnema/git-sync/internal/secretref"
+)
+
+// maxReportBytes bounds the body of a fleet report
+const maxReportBytes = 4 << 20
+
+// machineID is the name this machine reports to the fleet under
+func machineID(global config.GlobalConfig) string {
+	if global.MachineID != "" {
+		return global.MachineID
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return "unknown"
+}
+
+// configureReporter points the fleet reporter at report_to of cfg
+func (d *Daemon) configureReporter(cfg *config.Config) {
+	token, err := secretref.Resolve(cfg.Global.ReportToken)
+	if err != nil {
+		d.logger.Error("Failed to resolve report_token, reports will be rejected", "error", err)
+	}
+	d.reporter.Configure(cfg.Global.ReportTo, token, machineID(cfg.Global))
+}
+
+// serveFleetReport records the syncs another machine reports to this daemon.
+// A machine reports with a key named after its machine_id, so one machine's
+// key can't overwrite the results of another.
+func (d *Daemon) serveFleetReport(r *http.Request) control.Response {
+	var report fleet.Report
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxReportBytes)).Decode(&report); err != nil {
+		return control.ErrorResponse(fmt.Errorf("invalid report: %w", err))
+	}
+	if err := report.Validate(); err != nil {
+		return control.ErrorResponse(fmt.Errorf("invalid report: %w", err))
+	}
+	if key := control.RequestIdentity(r); report.Machine != key {
+		d.logger.Warn("Rejected fleet report for another machine", "machine", report.Machine, "key", key, "remote", r.RemoteAddr)
+		return control.ErrorResponse(fmt.Errorf("key %q can't report as machine %q, its name must be the machine_id", key, report.Machine))
+	}
+
+	if d.fleetStore == nil {
+		return control.ErrorResponse(errors.New("fleet reports are unavailable"))
+	}
+	if err := d.fleetStore.Record(report, r.RemoteAddr); err != nil {
+		// The report is kept in memory and saved with the next one
+		d.logger.Error("Failed to save fleet report", "machine", report.Machine, "error", err)
+	}
+	d.logger.Debug("Received fleet report", "machine", report.Machine, "entries", len(report.Entries), "remote", r.RemoteAddr)
+	return control.OKResponse(struct {
+		Received int `json:"received"`
+	}{len(report.Entries)})
+}
@@ -0,0 +1,32 @@
+package daemon
+
+import (
+	"time"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/state"
+)
+
+// forcePushAllowed reports whether pushes of repo may be forced: always with
+// force_push, or while a window armed by `git sync arm-force` is open. The state
+// is read on every push so an expired window reverts to safe pushes immediately.
+func (g *GitOperations) forcePushAllowed(repo configPkg.RepoConfig) bool {
+	if repo.ForcePush {
+		g.logger.Warn("Force push enabled", "repo", repo.Path)
+		return true
+	}
+
+	s, err := state.Load()
+	if err != nil {
+		g.logger.Warn("Failed to read force push arming, pushing safely", "repo", repo.Path, "error", err)
+		return false
+	}
+
+	until, armed := s.ForcePushArmedUntil(repo.Path, time.Now())
+	if !armed {
+		return false
+	}
+
+	g.logger.Warn("Force push armed", "repo", repo.Path, "until", until.Format(time.RFC3339))
+	return true
+}
@@ -0,0 +1,368 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+const (
+	// defaultFSWatchDebounce is how long the worktree must stay quiet before a change triggers a sync
+	defaultFSWatchDebounce = 5 * time.Second
+
+	// syncSettleTime covers events from a sync's own writes that arrive after it returns
+	syncSettleTime = time.Second
+
+	// ignoreCheckInterval batches the worktree paths that changed into one
+	// git check-ignore, so busy trees don't fork a git per event
+	ignoreCheckInterval = time.Second
+)
+
+// pathCheck is what a changed worktree path does unless git ignores it
+type pathCheck struct {
+	change bool // triggers a sync
+	newDir bool // gets watched
+}
+
+// fsWatcher turns changes to a repository's worktree and local branches into
+// debounced sync triggers. Ignored directories are not watched, so build output
+// and dependency trees neither trigger syncs nor use up inotify watches.
+type fsWatcher struct {
+	watcher  *fsnotify.Watcher
+	repo     configPkg.RepoConfig
+	worktree string // empty for bare repositories
+	refsDir  string
+	debounce time.Duration
+	minGap   time.Duration // after the last sync, before changes sync again
+	maxDelay time.Duration // longest changes wait for the worktree to go quiet; 0 waits for quiet
+	changes  chan struct{}
+	logger   *slog.Logger
+
+	mu           sync.Mutex
+	syncing      bool
+	ignoreBefore time.Time
+	lastSync     time.Time
+}
+
+// newFSWatcher starts watching repo until ctx is done or Close is called
+func newFSWatcher(ctx context.Context, repo configPkg.RepoConfig, logger *slog.Logger) (*fsWatcher, error) {
+	gitArgs := gitConfigArgs(repo)
+	commonDir, err := runGit(ctx, repo.Path, append(gitArgs, "rev-parse", "--path-format=absolute", "--git-common-dir")...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate git directory: %w", err)
+	}
+	bare, err := runGit(ctx, repo.Path, append(gitArgs, "rev-parse", "--is-bare-repository")...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect repository: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	w := &fsWatcher{
+		watcher:  watcher,
+		repo:     repo,
+		refsDir:  filepath.Join(commonDir, "refs", "heads"),
+		debounce: defaultFSWatchDebounce,
+		minGap:   time.Duration(repo.FSWatchMinGap) * time.Second,
+		maxDelay: time.Duration(repo.FSWatchMaxDelay) * time.Second,
+		changes:  make(chan struct{}, 1),
+		logger:   logger,
+	}
+	if repo.FSWatchDebounce > 0 {
+		w.debounce = time.Duration(repo.FSWatchDebounce) * time.Second
+	}
+	if bare != "true" {
+		w.worktree = repo.Path
+	}
+
+	// Commits change local branches without touching the worktree
+	if err := w.addTree(ctx, w.refsDir, nil); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	if w.worktree != "" {
+		if err := w.addTree(ctx, w.worktree, w.ignoredDirs(ctx)); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	go w.run(ctx)
+	return w, nil
+}
+
+// Changes delivers one value per burst of changes once the debounce period has passed
+func (w *fsWatcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// Close stops watching
+func (w *fsWatcher) Close() {
+	w.watcher.Close()
+}
+
+// beginSync ignores changes until settle, as a sync running now writes to the repository itself
+func (w *fsWatcher) beginSync() {
+	w.mu.Lock()
+	w.syncing = true
+	w.mu.Unlock()
+}
+
+// settle ends a sync, dropping the changes it made
+func (w *fsWatcher) settle() {
+	w.mu.Lock()
+	w.syncing = false
+	w.ignoreBefore = time.Now().Add(syncSettleTime)
+	w.lastSync = time.Now()
+	w.mu.Unlock()
+
+	select {
+	case <-w.changes:
+	default:
+	}
+}
+
+func (w *fsWatcher) run(ctx context.Context) {
+	debounce := time.NewTimer(w.debounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	// Worktree paths that changed since the last check-ignore
+	checkIgnored := time.NewTimer(ignoreCheckInterval)
+	checkIgnored.Stop()
+	defer checkIgnored.Stop()
+	checks := make(map[string]pathCheck)
+
+	// When the oldest change not synced yet happened
+	var pendingSince time.Time
+	changed := func() {
+		now := time.Now()
+		if pendingSince.IsZero() {
+			pendingSince = now
+		}
+		debounce.Reset(w.batchDelay(now, pendingSince))
+	}
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			check := w.triage(event)
+			if !check.change && !check.newDir {
+				continue
+			}
+			// Only worktree paths can be ignored
+			if w.worktree == "" || w.inRefsDir(event.Name) {
+				if check.newDir {
+					w.watchNewDir(ctx, event.Name)
+				}
+				if check.change {
+					changed()
+				}
+				continue
+			}
+			if len(checks) == 0 {
+				checkIgnored.Reset(ignoreCheckInterval)
+			}
+			previous := checks[event.Name]
+			checks[event.Name] = pathCheck{
+				change: previous.change || check.change,
+				newDir: previous.newDir || check.newDir,
+			}
+		case <-checkIgnored.C:
+			if w.applyChecks(ctx, checks) {
+				changed()
+			}
+			clear(checks)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Warn("File watcher error", "repo", w.repo.Path, "error", err)
+		case <-debounce.C:
+			// A sync since the timer was set restarts the gap
+			if wait := w.gapRemaining(time.Now()); wait > 0 {
+				debounce.Reset(wait)
+				continue
+			}
+			batched := time.Since(pendingSince)
+			pendingSince = time.Time{}
+			select {
+			case w.changes <- struct{}{}:
+				w.logger.Debug("Worktree changed, triggering sync", "repo", w.repo.Path, "batched", batched)
+			default:
+				// A sync is already pending
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// batchDelay returns how long changes pending since pendingSince wait before
+// syncing: until the worktree has been quiet for the debounce, at most the max
+// delay, and never less than what is left of the min gap after the last sync
+func (w *fsWatcher) batchDelay(now, pendingSince time.Time) time.Duration {
+	wait := w.debounce
+	if w.maxDelay > 0 {
+		wait = min(wait, max(pendingSince.Add(w.maxDelay).Sub(now), 0))
+	}
+	return max(wait, w.gapRemaining(now))
+}
+
+// gapRemaining returns how much of the min gap after the last sync is left
+func (w *fsWatcher) gapRemaining(now time.Time) time.Duration {
+	w.mu.Lock()
+	lastSync := w.lastSync
+	w.mu.Unlock()
+
+	if w.minGap <= 0 || lastSync.IsZero() {
+		return 0
+	}
+	return max(lastSync.Add(w.minGap).Sub(now), 0)
+}
+
+// triage reports whether event may trigger a sync and whether it created a
+// directory to watch, before git ignores are taken into account. Directories
+// created while a sync settles are still watched.
+func (w *fsWatcher) triage(event fsnotify.Event) pathCheck {
+	w.mu.Lock()
+	settling := w.syncing || time.Now().Before(w.ignoreBefore)
+	w.mu.Unlock()
+
+	if strings.HasSuffix(event.Name, ".lock") {
+		return pathCheck{}
+	}
+
+	var check pathCheck
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Lstat(event.Name); err == nil && info.IsDir() {
+			check.newDir = true
+		}
+	}
+	check.change = !settling && event.Op != fsnotify.Chmod
+	return check
+}
+
+// applyChecks asks git once which of the changed worktree paths it ignores,
+// watches the new directories that aren't, and reports whether any change
+// that isn't ignored should trigger a sync
+func (w *fsWatcher) applyChecks(ctx context.Context, checks map[string]pathCheck) bool {
+	paths := make([]string, 0, len(checks))
+	for path := range checks {
+		paths = append(paths, path)
+	}
+	ignored := w.ignoredPaths(ctx, paths)
+
+	changed := false
+	for path, check := range checks {
+		if ignored[path] {
+			continue
+		}
+		if check.newDir {
+			w.watchNewDir(ctx, path)
+		}
+		changed = changed || check.change
+	}
+	return changed
+}
+
+// watchNewDir watches a directory created since the watcher started
+func (w *fsWatcher) watchNewDir(ctx context.Context, dir string) {
+	if err := w.addTree(ctx, dir, nil); err != nil {
+		w.logger.Warn("Failed to watch new directory",
+			"repo", w.repo.Path,
+			"dir", dir,
+			"error", err)
+	}
+}
+
+// addTree watches root and its subdirectories, except .git and the ignored ones
+func (w *fsWatcher) addTree(ctx context.Context, root string, ignored map[string]bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Directories can disappear while they are walked
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if path != root && (d.Name() == ".git" || ignored[path]) {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// ignoredDirs lists the worktree directories excluded by .gitignore
+func (w *fsWatcher) ignoredDirs(ctx context.Context) map[string]bool {
+	output, err := runGit(ctx, w.worktree, append(gitConfigArgs(w.repo),
+		"ls-files", "--others", "--ignored", "--exclude-standard", "--directory")...)
+	ignored := make(map[string]bool)
+	if err != nil {
+		return ignored
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if dir, ok := strings.CutSuffix(line, "/"); ok {
+			ignored[filepath.Join(w.worktree, dir)] = true
+		}
+	}
+	return ignored
+}
+
+// ignoredPaths returns which of paths git ignores, from a single check-ignore
+func (w *fsWatcher) ignoredPaths(ctx context.Context, paths []string) map[string]bool {
+	ignored := make(map[string]bool)
+	if len(paths) == 0 {
+		return ignored
+	}
+
+	cmd := exec.CommandContext(ctx, "git", append(gitConfigArgs(w.repo), "check-ignore", "--stdin", "-z")...)
+	cmd.Dir = w.worktree
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	output, err := cmd.Output()
+	if err != nil {
+		// Exit status 1 means none of the paths is ignored
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			w.logger.Debug("Failed to check ignored paths", "repo", w.repo.Path, "error", err)
+		}
+		return ignored
+	}
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			ignored[path] = true
+		}
+	}
+	return ignored
+}
+
+func (w *fsWatcher) inRefsDir(path string) bool {
+	return path == w.refsDir || strings.HasPrefix(path, w.refsDir+string(filepath.Separator))
+}
@@ -2,9 +2,10 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"path/filepath"
+	"sync"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
@@ -15,20 +16,25 @@ import (
 )
 
 type GitOperations struct {
-	logger *slog.Logger
+	logger          *slog.Logger
+	defaultBranches map[string]cachedBranch
+	progress        *progressTracker
+	mu              sync.Mutex
 }
 
 func NewGitOperations(logger *slog.Logger) *GitOperations {
+	installRateLimitTracking()
 	return &GitOperations{
-		logger: logger,
+		logger:          logger,
+		defaultBranches: make(map[string]cachedBranch),
+		progress:        newProgressTracker(logger),
 	}
 }
 
 // SyncRepository performs the sync operation using go-git library
 func (g *GitOperations) SyncRepository(ctx context.Context, repo configPkg.RepoConfig) error {
 	g.logger.Info("Starting sync with go-git", 
-		"repo", filepath.Base(repo.Path), 
-		"path", repo.Path,
+		"repo", repo.Path, 
 		"direction", repo.Direction)
 
 	// Check context before starting
@@ -38,60 +44,148 @@ func (g *GitOperations) SyncRepository(ctx context.Context, repo configPkg.RepoC
 	default:
 	}
 
+	// Refuse repositories owned by other users, like git's safe.directory
+	if err := g.checkOwnership(ctx, repo); err != nil {
+		return err
+	}
+
 	// Open repository
-	r, err := git.PlainOpen(repo.Path)
+	r, err := openRepository(repo.Path)
 	if err != nil {
 		return fmt.Errorf("failed to open repository: %w", err)
 	}
 
-	// Get worktree
+	// Track transfer progress for status, and the pack data fetched for history
+	g.progress.begin(repo.Path)
+	packsBefore := packSize(r)
+	refsBefore := refSnapshot(r)
+	defer func() {
+		g.progress.end(repo.Path, max(packSize(r)-packsBefore, 0), movedRefs(refsBefore, refSnapshot(r)))
+	}()
+
+	// Get worktree; bare repositories have none and use fetch/push-only flows
 	worktree, err := r.Worktree()
+	if errors.Is(err, git.ErrIsBareRepository) {
+		return g.syncBareRepository(ctx, r, repo)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
 
+	// Detached HEAD: sync via the configured branch or skip
+	if head, err := r.Head(); err == nil && head.Name() == plumbing.HEAD {
+		repo, err = g.resolveDetachedHead(repo)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Safety checks
+	var restore func() error
 	if repo.SafetyChecks {
-		if err := g.performSafetyChecks(ctx, r, worktree, repo); err != nil {
+		restore, err = g.performSafetyChecks(ctx, r, worktree, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	headBefore := g.headBeforePull(ctx, repo)
+
+	syncErr := g.syncDirection(ctx, r, worktree, repo)
+
+	// Restore stashed changes even when the sync itself failed
+	if restore != nil {
+		if err := restore(); err != nil {
+			if syncErr != nil {
+				return fmt.Errorf("%w (additionally: %v)", syncErr, err)
+			}
 			return err
 		}
 	}
+	if syncErr != nil {
+		return syncErr
+	}
 
-	// Execute sync based on direction
+	// Follow-up actions see the final worktree, with stashed changes back in place
+	return g.runAfterPull(ctx, repo, headBefore)
+}
+
+// resolveDetachedHead decides how to sync a repository whose HEAD is detached.
+// With detached_head_branch set, the configured branch is synced through the
+// branch-switching flow and HEAD is restored to the detached commit afterwards.
+func (g *GitOperations) resolveDetachedHead(repo configPkg.RepoConfig) (configPkg.RepoConfig, error) {
+	if repo.DetachedHeadBranch != "" {
+		g.logger.Info("HEAD is detached, syncing configured branch",
+			"repo", repo.Path,
+			"branch", repo.DetachedHeadBranch)
+		repo.BranchStrategy = "specific"
+		repo.TargetBranch = repo.DetachedHeadBranch
+		return repo, nil
+	}
+
+	// Strategies that never touch HEAD are safe to run as-is
+	if repo.BranchStrategy == "all" || len(repo.PushRefSpecs) > 0 || len(repo.FetchRefSpecs) > 0 {
+		return repo, nil
+	}
+
+	return repo, newSkipError(SkipDetachedHead, "HEAD is detached, skipping sync (set detached_head_branch to sync a branch anyway)")
+}
+
+// syncDirection executes the sync based on the configured direction
+func (g *GitOperations) syncDirection(ctx context.Context, r *git.Repository, worktree *git.Worktree, repo configPkg.RepoConfig) error {
 	switch repo.Direction {
 	case "push":
-		return g.gitPush(ctx, r, repo)
+		return g.withUnshallowRetry(ctx, r, repo, func(repo configPkg.RepoConfig) error {
+			return g.gitPush(ctx, r, repo)
+		})
 	case "pull":
-		return g.gitPull(ctx, r, worktree, repo)
+		return g.withUnshallowRetry(ctx, r, repo, func(repo configPkg.RepoConfig) error {
+			return g.gitPull(ctx, r, worktree, repo)
+		})
 	case "both":
-		if err := g.gitPull(ctx, r, worktree, repo); err != nil {
+		err := g.withUnshallowRetry(ctx, r, repo, func(repo configPkg.RepoConfig) error {
+			return g.gitPull(ctx, r, worktree, repo)
+		})
+		if err != nil {
 			return fmt.Errorf("pull failed: %w", err)
 		}
-		return g.gitPush(ctx, r, repo)
+		return g.withUnshallowRetry(ctx, r, repo, func(repo configPkg.RepoConfig) error {
+			return g.gitPush(ctx, r, repo)
+		})
 	default:
 		return fmt.Errorf("invalid direction: %s", repo.Direction)
 	}
 }
 
-func (g *GitOperations) performSafetyChecks(ctx context.Context, r *git.Repository, w *git.Worktree, repo configPkg.RepoConfig) error {
+// performSafetyChecks verifies the worktree is clean, applying the configured
+// dirty_worktree_action otherwise. The returned restore function, if any, must
+// be called once the sync has finished.
+func (g *GitOperations) performSafetyChecks(ctx context.Context, r *git.Repository, w *git.Worktree, repo configPkg.RepoConfig) (func() error, error) {
 	// Check context before starting
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return nil, ctx.Err()
 	default:
 	}
 
 	// Check if there are uncommitted changes
 	status, err := w.Status()
 	if err != nil {
-		return fmt.Errorf("failed to get worktree status: %w", err)
+		return nil, fmt.Errorf("failed to get worktree status: %w", err)
 	}
 
-	if !status.IsClean() && !repo.ForcePush {
-		return fmt.Errorf("repository has uncommitted changes, skipping sync")
+	if status.IsClean() || repo.ForcePush {
+		return nil, nil
 	}
 
-	return nil
+	switch repo.DirtyWorktreeAction {
+	case "stash":
+		return g.stashChanges(ctx, repo)
+	case "commit":
+		return nil, g.commitChanges(ctx, r, w, repo)
+	default:
+		return nil, newSkipError(SkipDirtyWorktree, "repository has uncommitted changes, skipping sync")
+	}
 }
 
 func (g *GitOperations) gitPush(ctx context.Context, r *git.Repository, repo configPkg.RepoConfig) error {
@@ -102,40 +196,74 @@ func (g *GitOperations) gitPush(ctx context.Context, r *git.Repository, repo con
 	default:
 	}
 
+	// Skip the network round trip when there is nothing new to push
+	if !g.pushNeeded(ctx, r, repo) {
+		return nil
+	}
+
+	// Keep commits that look like they contain credentials local
+	if err := g.scanPush(ctx, r, repo); err != nil {
+		return err
+	}
+
+	// go-git runs no hooks, so run_hooks runs pre-push itself
+	if repo.RunHooks {
+		refSpecs, err := g.pushRefSpecStrings(ctx, r, repo)
+		if err != nil {
+			return err
+		}
+		if err := g.runPrePush(ctx, repo, refSpecs); err != nil {
+			return err
+		}
+	}
+
+	// Custom refspecs bypass the branch strategy entirely
+	customRefSpecs := len(repo.PushRefSpecs) > 0
+
 	// Handle specific branch strategy
-	if repo.BranchStrategy == "specific" {
+	if repo.BranchStrategy == "specific" && !customRefSpecs {
 		return g.gitPushSpecificBranch(ctx, r, repo)
 	}
 
-	pushOptions := &git.PushOptions{
-		RemoteName: repo.Remote,
-		Progress:   nil, // Could add progress reporting later
+	proxy, caBundle, auth, err := transportOptions(repo)
+	if err != nil {
+		return err
 	}
 
-	if repo.ForcePush {
-		pushOptions.Force = true
-		g.logger.Warn("Force push enabled", "repo", repo.Path)
+	pushOptions := &git.PushOptions{
+		RemoteName:   repo.Remote,
+		Auth:         auth,
+		ProxyOptions: proxy,
+		CABundle:     caBundle,
+		Progress:     g.progress.writer(repo.Path),
 	}
 
+	pushOptions.Force = g.forcePushAllowed(repo)
+
 	// Set ref specs based on strategy
-	refSpecs, err := g.getRefSpecs(r, repo.BranchStrategy, repo.Remote, false)
-	if err != nil {
-		return err
+	if customRefSpecs {
+		pushOptions.RefSpecs = toRefSpecs(repo.PushRefSpecs)
+	} else {
+		refSpecs, err := g.getRefSpecs(ctx, r, repo, false)
+		if err != nil {
+			return err
+		}
+		pushOptions.RefSpecs = refSpecs
 	}
-	pushOptions.RefSpecs = refSpecs
 
-	err = r.Push(pushOptions)
+	err = r.PushContext(ctx, pushOptions)
 	if err != nil {
 		if err == git.NoErrAlreadyUpToDate {
-			g.logger.Debug("Push: already up to date", "repo", filepath.Base(repo.Path))
+			g.logger.Debug("Push: already up to date", "repo", repo.Path)
 			return nil
 		}
 		return fmt.Errorf("git push failed: %w", err)
 	}
 
 	g.logger.Info("Push successful", 
-		"repo", filepath.Base(repo.Path),
-		"strategy", repo.BranchStrategy)
+		"repo", repo.Path,
+		"strategy", repo.BranchStrategy,
+		"custom_refspecs", customRefSpecs)
 
 	return nil
 }
@@ -148,36 +276,69 @@ func (g *GitOperations) gitPull(ctx context.Context, r *git.Repository, w *git.W
 	default:
 	}
 
+	// Custom fetch refspecs bypass the branch strategy and only fetch
+	if len(repo.FetchRefSpecs) > 0 {
+		return g.gitFetch(ctx, r, repo)
+	}
+
 	// Handle specific branch strategy
 	if repo.BranchStrategy == "specific" {
 		return g.gitPullSpecificBranch(ctx, r, w, repo)
 	}
 
+	// The "main" strategy pulls the remote's default branch, which may be master or anything else
+	if repo.BranchStrategy == "main" {
+		repo.TargetBranch = g.defaultBranch(ctx, r, repo)
+		return g.gitPullSpecificBranch(ctx, r, w, repo)
+	}
+
+	proxy, caBundle, auth, err := transportOptions(repo)
+	if err != nil {
+		return err
+	}
+
 	pullOptions := &git.PullOptions{
-		RemoteName: repo.Remote,
-		Progress:   nil,
+		RemoteName:   repo.Remote,
+		Depth:        g.fetchDepth(r, repo),
+		Auth:         auth,
+		ProxyOptions: proxy,
+		CABundle:     caBundle,
+		Progress:     g.progress.writer(repo.Path),
 	}
 
-	// For "all" strategy, we do a fetch instead
+	// For "all" strategy, fetch everything and fast-forward local branches behind their upstream
 	if repo.BranchStrategy == "all" {
-		return g.gitFetch(ctx, r, repo)
+		if err := g.gitFetch(ctx, r, repo); err != nil {
+			return err
+		}
+		return g.fastForwardBranches(ctx, r, w, repo)
+	}
+
+	// Pull the remote branch matching the current one, not the remote HEAD
+	head, err := r.Head()
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
 	}
+	pullOptions.ReferenceName = head.Name()
 
-	err := w.Pull(pullOptions)
+	err = w.PullContext(ctx, pullOptions)
 	if err != nil {
 		if err == git.NoErrAlreadyUpToDate {
-			g.logger.Debug("Pull: already up to date", "repo", filepath.Base(repo.Path))
+			g.logger.Debug("Pull: already up to date", "repo", repo.Path)
 			return nil
 		}
 		if err == transport.ErrEmptyRemoteRepository {
-			g.logger.Info("Remote repository is empty", "repo", filepath.Base(repo.Path))
+			g.logger.Info("Remote repository is empty", "repo", repo.Path)
 			return nil
 		}
+		if errors.Is(err, git.ErrNonFastForwardUpdate) && len(repo.UnionMergePatterns) > 0 {
+			return g.mergeWithUnionDriver(ctx, repo, head.Name().Short())
+		}
 		return fmt.Errorf("git pull failed: %w", err)
 	}
 
 	g.logger.Info("Pull successful", 
-		"repo", filepath.Base(repo.Path),
+		"repo", repo.Path,
 		"strategy", repo.BranchStrategy)
 
 	return nil
@@ -191,21 +352,34 @@ func (g *GitOperations) gitFetch(ctx context.Context, r *git.Repository, repo co
 	default:
 	}
 
+	proxy, caBundle, auth, err := transportOptions(repo)
+	if err != nil {
+		return err
+	}
+
 	fetchOptions := &git.FetchOptions{
-		RemoteName: repo.Remote,
-		Progress:   nil,
+		RemoteName:   repo.Remote,
+		Depth:        g.fetchDepth(r, repo),
+		Auth:         auth,
+		ProxyOptions: proxy,
+		CABundle:     caBundle,
+		Progress:     g.progress.writer(repo.Path),
+	}
+
+	if len(repo.FetchRefSpecs) > 0 {
+		fetchOptions.RefSpecs = toRefSpecs(repo.FetchRefSpecs)
 	}
 
-	err := r.Fetch(fetchOptions)
+	err = r.FetchContext(ctx, fetchOptions)
 	if err != nil {
 		if err == git.NoErrAlreadyUpToDate {
-			g.logger.Debug("Fetch: already up to date", "repo", filepath.Base(repo.Path))
+			g.logger.Debug("Fetch: already up to date", "repo", repo.Path)
 			return nil
 		}
 		return fmt.Errorf("git fetch failed: %w", err)
 	}
 
-	g.logger.Info("Fetch successful", "repo", filepath.Base(repo.Path))
+	g.logger.Info("Fetch successful", "repo", repo.Path)
 	return nil
 }
 
@@ -218,32 +392,37 @@ func (g *GitOperations) gitPushSpecificBranch(ctx context.Context, r *git.Reposi
 		default:
 		}
 
-		pushOptions := &git.PushOptions{
-			RemoteName: repo.Remote,
-			Progress:   nil,
+		proxy, caBundle, auth, err := transportOptions(repo)
+		if err != nil {
+			return err
 		}
 
-		if repo.ForcePush {
-			pushOptions.Force = true
-			g.logger.Warn("Force push enabled", "repo", repo.Path)
+		pushOptions := &git.PushOptions{
+			RemoteName:   repo.Remote,
+			Auth:         auth,
+			ProxyOptions: proxy,
+			CABundle:     caBundle,
+			Progress:     g.progress.writer(repo.Path),
 		}
 
+		pushOptions.Force = g.forcePushAllowed(repo)
+
 		// Push only the target branch
 		refSpec := config.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%s", 
 			repo.TargetBranch, repo.TargetBranch))
 		pushOptions.RefSpecs = []config.RefSpec{refSpec}
 
-		err := r.Push(pushOptions)
+		err = r.PushContext(ctx, pushOptions)
 		if err != nil {
 			if err == git.NoErrAlreadyUpToDate {
-				g.logger.Debug("Push: already up to date", "repo", filepath.Base(repo.Path))
+				g.logger.Debug("Push: already up to date", "repo", repo.Path)
 				return nil
 			}
 			return fmt.Errorf("git push failed: %w", err)
 		}
 
 		g.logger.Info("Push successful", 
-			"repo", filepath.Base(repo.Path),
+			"repo", repo.Path,
 			"target_branch", repo.TargetBranch)
 
 		return nil
@@ -251,6 +430,16 @@ func (g *GitOperations) gitPushSpecificBranch(ctx context.Context, r *git.Reposi
 }
 
 func (g *GitOperations) gitPullSpecificBranch(ctx context.Context, r *git.Repository, w *git.Worktree, repo configPkg.RepoConfig) error {
+	// A target branch only the remote has is created from its remote-tracking
+	// branch, which has to be fetched first
+	if _, err := r.Reference(plumbing.NewBranchReferenceName(repo.TargetBranch), true); err != nil {
+		if _, err := r.Reference(plumbing.NewRemoteReferenceName(repo.Remote, repo.TargetBranch), true); err != nil {
+			if err := g.gitFetch(ctx, r, repo); err != nil {
+				return err
+			}
+		}
+	}
+
 	return g.withBranchSwitch(ctx, r, repo, func() error {
 		// Check context before pull operation
 		select {
@@ -259,22 +448,35 @@ func (g *GitOperations) gitPullSpecificBranch(ctx context.Context, r *git.Reposi
 		default:
 		}
 
+		proxy, caBundle, auth, err := transportOptions(repo)
+		if err != nil {
+			return err
+		}
+
 		pullOptions := &git.PullOptions{
-			RemoteName: repo.Remote,
-			Progress:   nil,
+			RemoteName:    repo.Remote,
+			ReferenceName: plumbing.NewBranchReferenceName(repo.TargetBranch),
+			Depth:         g.fetchDepth(r, repo),
+			Auth:          auth,
+			ProxyOptions:  proxy,
+			CABundle:      caBundle,
+			Progress:      g.progress.writer(repo.Path),
 		}
 
-		err := w.Pull(pullOptions)
+		err = w.PullContext(ctx, pullOptions)
 		if err != nil {
 			if err == git.NoErrAlreadyUpToDate {
-				g.logger.Debug("Pull: already up to date", "repo", filepath.Base(repo.Path))
+				g.logger.Debug("Pull: already up to date", "repo", repo.Path)
 				return nil
 			}
+			if errors.Is(err, git.ErrNonFastForwardUpdate) && len(repo.UnionMergePatterns) > 0 {
+				return g.mergeWithUnionDriver(ctx, repo, repo.TargetBranch)
+			}
 			return fmt.Errorf("git pull failed: %w", err)
 		}
 
 		g.logger.Info("Pull successful", 
-			"repo", filepath.Base(repo.Path),
+			"repo", repo.Path,
 			"target_branch", repo.TargetBranch)
 
 		return nil
@@ -302,16 +504,21 @@ func (g *GitOperations) withBranchSwitch(ctx context.Context, r *git.Repository,
 	}
 
 	currentBranch := head.Name().Short()
+	detached := head.Name() == plumbing.HEAD
 
 	// If we're already on the target branch, just execute the operation
-	if currentBranch == repo.TargetBranch {
+	if !detached && currentBranch == repo.TargetBranch {
 		return operation()
 	}
 
+	if path, busy := g.siblingWorktreeBranches(ctx, repo)[repo.TargetBranch]; busy {
+		return fmt.Errorf("branch '%s' is checked out in another worktree (%s), not switching to it", repo.TargetBranch, path)
+	}
+
 	g.logger.Debug("Switching to target branch", 
 		"from", currentBranch, 
 		"to", repo.TargetBranch,
-		"repo", filepath.Base(repo.Path))
+		"repo", repo.Path)
 
 	// Check for uncommitted changes
 	status, err := w.Status()
@@ -361,16 +568,20 @@ func (g *GitOperations) withBranchSwitch(ctx context.Context, r *git.Repository,
 		originalCheckout := &git.CheckoutOptions{
 			Branch: plumbing.ReferenceName(fmt.Sprintf("refs/heads/%s", currentBranch)),
 		}
+		if detached {
+			// Restore the detached HEAD at its original commit
+			originalCheckout = &git.CheckoutOptions{Hash: head.Hash()}
+		}
 		
 		if switchErr := w.Checkout(originalCheckout); switchErr != nil {
 			g.logger.Error("Failed to switch back to original branch", 
 				"original", currentBranch, 
 				"error", switchErr,
-				"repo", filepath.Base(repo.Path))
+				"repo", repo.Path)
 		} else {
 			g.logger.Debug("Switched back to original branch", 
 				"branch", currentBranch,
-				"repo", filepath.Base(repo.Path))
+				"repo", repo.Path)
 		}
 	}()
 
@@ -378,8 +589,10 @@ func (g *GitOperations) withBranchSwitch(ctx context.Context, r *git.Repository,
 	return operation()
 }
 
-func (g *GitOperations) getRefSpecs(r *git.Repository, strategy, remoteName string, isPull bool) ([]config.RefSpec, error) {
-	switch strategy {
+func (g *GitOperations) getRefSpecs(ctx context.Context, r *git.Repository, repo configPkg.RepoConfig, isPull bool) ([]config.RefSpec, error) {
+	remoteName := repo.Remote
+
+	switch repo.BranchStrategy {
 	case "current":
 		head, err := r.Head()
 		if err != nil {
@@ -397,13 +610,14 @@ func (g *GitOperations) getRefSpecs(r *git.Repository, strategy, remoteName stri
 		}, nil
 		
 	case "main":
+		branch := g.defaultBranch(ctx, r, repo)
 		if isPull {
 			return []config.RefSpec{
-				config.RefSpec(fmt.Sprintf("refs/heads/main:refs/remotes/%s/main", remoteName)),
+				config.RefSpec(fmt.Sprintf("refs/heads/%s:refs/remotes/%s/%s", branch, remoteName, branch)),
 			}, nil
 		}
 		return []config.RefSpec{
-			config.RefSpec("refs/heads/main:refs/heads/main"),
+			config.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)),
 		}, nil
 		
 	case "all":
@@ -412,6 +626,15 @@ func (g *GitOperations) getRefSpecs(r *git.Repository, strategy, remoteName stri
 		}, nil
 		
 	default:
-		return nil, fmt.Errorf("invalid branch strategy: %s", strategy)
+		return nil, fmt.Errorf("invalid branch strategy: %s", repo.BranchStrategy)
+	}
+}
+
+// toRefSpecs converts configured refspec strings into go-git refspecs
+func toRefSpecs(specs []string) []config.RefSpec {
+	refSpecs := make([]config.RefSpec, 0, len(specs))
+	for _, spec := range specs {
+		refSpecs = append(refSpecs, config.RefSpec(spec))
 	}
-}
\ No newline at end of file
+	return refSpecs
+}
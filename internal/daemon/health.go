@@ -0,0 +1,232 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/bnema/git-sync/internal/apikey"
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/state"
+)
+
+// Health statuses, from best to worst
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthFailing  = "failing"
+)
+
+const (
+	// staleIntervals is how many intervals a repository may go without a
+	// successful sync before it counts as stale
+	staleIntervals = 3
+
+	// goroutineBudget and goroutinesPerSchedule bound the goroutines a healthy
+	// daemon runs; more point to a leak
+	goroutineBudget       = 200
+	goroutinesPerSchedule = 20
+)
+
+// Health is the daemon's own view of whether it is doing its job, returned by
+// the health control command and summarized by /healthz
+type Health struct {
+	Status       string         `json:"status"`
+	Problems     []string       `json:"problems,omitempty"`
+	StartedAt    time.Time      `json:"started_at"`
+	Goroutines   int            `json:"goroutines"`
+	Schedules    int            `json:"schedules"`
+	LastReload   *ReloadStatus  `json:"last_reload,omitempty"` // nil until the config is reloaded
+	History      *HistoryHealth `json:"history,omitempty"`     // nil when history is disabled
+	Repositories []RepoHealth   `json:"repositories"`
+}
+
+// ReloadStatus is the outcome of the latest config reload
+type ReloadStatus struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+// RepoHealth is how recently an enabled repository synced successfully
+type RepoHealth struct {
+	Path        string    `json:"path"`
+	LastSync    time.Time `json:"last_sync,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LastStatus  string    `json:"last_status,omitempty"`
+	Paused      bool      `json:"paused,omitempty"` // by 'git sync pause' or the circuit breaker
+	Stale       bool      `json:"stale,omitempty"`
+}
+
+// recordReload remembers the outcome of a config reload for the health report
+func (d *Daemon) recordReload(err error) {
+	status := &ReloadStatus{At: time.Now()}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastReload = status
+}
+
+// Health checks the repositories, scheduler and config reloads. Repositories
+// without a success for staleIntervals of their interval are stale, which
+// degrades the daemon, and it is failing when every repository it should be
+// syncing is stale or the scheduler lost schedules. Repositories syncing on a
+// cron schedule or on file changes only have no interval and are never stale.
+func (d *Daemon) Health() Health {
+	now := time.Now()
+	d.mu.RLock()
+	enabledRepos := d.enabledRepos()
+	syncSets, standalone := SyncSets(d.config, enabledRepos)
+	var lastReload *ReloadStatus
+	if d.lastReload != nil {
+		reload := *d.lastReload
+		lastReload = &reload
+	}
+	d.mu.RUnlock()
+
+	snapshot := d.metrics.Snapshot()
+	health := Health{
+		Status:     HealthOK,
+		StartedAt:  snapshot.StartedAt,
+		Goroutines: runtime.NumGoroutine(),
+		Schedules:  d.scheduler.Schedules(),
+		LastReload: lastReload,
+	}
+	degrade := func(problem string, args ...any) {
+		health.Problems = append(health.Problems, fmt.Sprintf(problem, args...))
+		if health.Status == HealthOK {
+			health.Status = HealthDegraded
+		}
+	}
+
+	st, err := state.Load()
+	if err != nil {
+		d.logger.Debug("Failed to load state for health check", "error", err)
+		st = &state.State{}
+	}
+
+	byPath := make(map[string]int, len(snapshot.Repos))
+	for i, repo := range snapshot.Repos {
+		byPath[repo.Repo] = i
+	}
+	intervals := expectedIntervals(standalone, syncSets)
+
+	var watched, stale int
+	for _, repo := range enabledRepos {
+		repoHealth := RepoHealth{Path: repo.Path}
+		if i, synced := byPath[repo.Path]; synced {
+			repoHealth.LastSync = snapshot.Repos[i].LastSync
+			repoHealth.LastSuccess = snapshot.Repos[i].LastSuccess
+			repoHealth.LastStatus = snapshot.Repos[i].LastStatus
+		}
+		_, circuitOpen := st.Paused(repo.Path, now)
+		_, paused := st.PausedSince(repo.Path)
+		repoHealth.Paused = circuitOpen || paused
+
+		if interval := intervals[repo.Path]; interval > 0 && !repoHealth.Paused {
+			watched++
+			since := repoHealth.LastSuccess
+			if since.IsZero() {
+				since = health.StartedAt.Add(initialSyncDelay)
+			}
+			if now.Sub(since) > staleIntervals*d.power.scale(interval) {
+				repoHealth.Stale = true
+				stale++
+				if repoHealth.LastSuccess.IsZero() {
+					degrade("%s has not synced successfully since the daemon started", filepath.Base(repo.Path))
+				} else {
+					degrade("%s last synced successfully %s ago", filepath.Base(repo.Path), now.Sub(repoHealth.LastSuccess).Round(time.Second))
+				}
+			}
+		}
+		health.Repositories = append(health.Repositories, repoHealth)
+	}
+
+	if lastReload != nil && lastReload.Error != "" {
+		degrade("config reload failed %s ago: %s", now.Sub(lastReload.At).Round(time.Second), lastReload.Error)
+	}
+	if d.historyManager != nil {
+		history := d.historyManager.Health()
+		health.History = &history
+		if history.Failing() {
+			degrade("sync history is not being recorded: %s", history.LastError)
+		}
+	}
+	if limit := goroutineBudget + goroutinesPerSchedule*health.Schedules; health.Goroutines > limit {
+		degrade("%d goroutines running, more than the %d expected", health.Goroutines, limit)
+	}
+
+	// Schedules are briefly missing while a reload swaps them
+	if d.reloadMu.TryLock() {
+		expected := len(standalone) + len(syncSets)
+		if health.Schedules < expected {
+			degrade("%d of %d schedules are running", health.Schedules, expected)
+			health.Status = HealthFailing
+		}
+		d.reloadMu.Unlock()
+	}
+	if watched > 0 && stale == watched {
+		health.Status = HealthFailing
+	}
+	return health
+}
+
+// expectedIntervals returns how often each repository should sync successfully,
+// leaving out those without a fixed interval
+func expectedIntervals(standalone []config.RepoConfig, sets []SyncSet) map[string]time.Duration {
+	intervals := make(map[string]time.Duration)
+	for _, repo := range standalone {
+		switch {
+		case repo.Trigger == "fswatch":
+		case repo.SplitsDirections():
+			// Either direction syncing counts as a success
+			for _, direction := range []string{"pull", "push"} {
+				seconds, schedule := repo.DirectionTiming(direction)
+				interval := time.Duration(seconds) * time.Second
+				if schedule == "" && interval > 0 && (intervals[repo.Path] == 0 || interval < intervals[repo.Path]) {
+					intervals[repo.Path] = interval
+				}
+			}
+		case repo.Schedule == "" && repo.Interval > 0:
+			intervals[repo.Path] = time.Duration(repo.Interval) * time.Second
+		}
+	}
+	for _, set := range sets {
+		if set.Schedule != "" || set.Interval <= 0 {
+			continue
+		}
+		for _, member := range set.Members {
+			intervals[member.Path] = time.Duration(set.Interval) * time.Second
+		}
+	}
+	return intervals
+}
+
+// serveHealthz answers monitoring probes with 503 when the daemon is failing.
+// The status and problems, which name repositories, are only sent along to
+// requests with a read key.
+func (d *Daemon) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	health := d.Health()
+	code := http.StatusOK
+	if health.Status == HealthFailing {
+		code = http.StatusServiceUnavailable
+	}
+
+	if _, err := authorizeAPIKey(control.BearerToken(r), apikey.ScopeRead); err != nil {
+		w.WriteHeader(code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(struct {
+		Status   string   `json:"status"`
+		Problems []string `json:"problems,omitempty"`
+	}{health.Status, health.Problems})
+}
@@ -2,16 +2,20 @@ package daemon
 
 import (
 	"bufio"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
+
+	"github.com/bnema/git-sync/internal/filelock"
+	"github.com/bnema/git-sync/internal/paths"
 )
 
 // SyncHistoryEntry represents a single sync operation record
@@ -19,12 +23,21 @@ type SyncHistoryEntry struct {
 	Timestamp  time.Time `json:"timestamp"`
 	RepoPath   string    `json:"repo_path"`
 	Direction  string    `json:"direction"`
-	Status     string    `json:"status"`
+	Status     string    `json:"status"` // success, noop, partial, failed or skipped
 	DurationMs int64     `json:"duration_ms"`
 	ErrorMsg   string    `json:"error_message,omitempty"`
+	ErrorKind  string    `json:"error_kind,omitempty"` // auth, network, conflict... for failed syncs
+	SkipReason string    `json:"skip_reason,omitempty"`
+	Progress   string    `json:"progress,omitempty"` // what a partial sync kept, e.g. "refs updated, 120.5 MiB fetched"
+	HookError  string    `json:"hook_error,omitempty"` // run_after_pull failure of a sync that otherwise worked
+
+	BytesFetched int64 `json:"bytes_fetched,omitempty"` // pack data fetched; pushes aren't counted
+	QueueWaitMs  int64 `json:"queue_wait_ms,omitempty"` // time spent waiting for a free max_concurrent_syncs slot
+	HostWaitMs   int64 `json:"host_wait_ms,omitempty"`  // time held back by max_syncs_per_host and host_sync_delay
 }
 
-// HistoryManager manages persistent sync history using JSON Lines format
+// HistoryManager manages persistent sync history, in a JSON Lines file or a
+// SQLite database
 type HistoryManager struct {
 	cacheDir      string
 	historyFile   string
@@ -34,16 +47,26 @@ type HistoryManager struct {
 	maxFileSizeMB int64
 	logger        *slog.Logger
 	mu            sync.Mutex
+
+	// The history database with the sqlite backend, historyFile then being its path
+	db *sql.DB
+
+	// Writes, failures and rotations since the history manager was created
+	health historyHealth
+
+	// Entries that failed to be written, oldest first, retried before the next one
+	pending []SyncHistoryEntry
 }
 
-// NewHistoryManager creates a new history manager
-func NewHistoryManager(cacheDir string, maxEntries, retentionDays, maxFileSizeMB int, logger *slog.Logger) (*HistoryManager, error) {
+// NewHistoryManager creates a new history manager, storing history in a JSONL
+// file or, with the sqlite backend, a database the JSONL history is migrated to
+func NewHistoryManager(cacheDir string, maxEntries, retentionDays, maxFileSizeMB int, backend string, logger *slog.Logger) (*HistoryManager, error) {
 	if cacheDir == "" {
-		homeDir, err := os.UserHomeDir()
+		var err error
+		cacheDir, err = paths.CacheDir()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get user home directory: %w", err)
+			return nil, fmt.Errorf("failed to get cache directory: %w", err)
 		}
-		cacheDir = filepath.Join(homeDir, ".cache", "git-sync")
 	}
 
 	hm := &HistoryManager{
@@ -60,6 +83,14 @@ func NewHistoryManager(cacheDir string, maxEntries, retentionDays, maxFileSizeMB
 		return nil, err
 	}
 
+	if backend == HistoryBackendSQLite {
+		if err := hm.openSQLite(); err != nil {
+			return nil, err
+		}
+	} else if err := hm.exportSQLite(); err != nil {
+		return nil, fmt.Errorf("failed to move SQLite history back to JSONL: %w", err)
+	}
+
 	return hm, nil
 }
 
@@ -71,22 +102,31 @@ func (hm *HistoryManager) ensureHistoryDir() error {
 	return nil
 }
 
+// Close closes the history database; JSONL history has nothing to close
+func (hm *HistoryManager) Close() error {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	if hm.db == nil {
+		return nil
+	}
+	return hm.db.Close()
+}
+
 // RecordSync records a sync operation to the history file
-func (hm *HistoryManager) RecordSync(repoPath, direction, status string, duration time.Duration, errorMsg string) {
+func (hm *HistoryManager) RecordSync(entry SyncHistoryEntry) {
 	hm.mu.Lock()
 	defer hm.mu.Unlock()
 
-	entry := SyncHistoryEntry{
-		Timestamp:  time.Now(),
-		RepoPath:   repoPath,
-		Direction:  direction,
-		Status:     status,
-		DurationMs: duration.Milliseconds(),
-		ErrorMsg:   errorMsg,
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
 	}
 
-	if err := hm.appendEntry(entry); err != nil {
-		hm.logger.Error("Failed to record sync history", "error", err)
+	err := hm.writeBuffered(entry)
+	hm.recordWrite(err)
+	if err != nil {
+		hm.logger.Error("Failed to record sync history, keeping it in memory until the file is writable",
+			"error", err,
+			"pending", len(hm.pending))
 		return
 	}
 
@@ -94,12 +134,18 @@ func (hm *HistoryManager) RecordSync(repoPath, direction, status string, duratio
 	if hm.shouldRotateFile() {
 		if err := hm.rotateFile(); err != nil {
 			hm.logger.Error("Failed to rotate history file", "error", err)
+		} else {
+			hm.recordRotation()
 		}
 	}
 }
 
 // appendEntry appends a single entry to the history file
 func (hm *HistoryManager) appendEntry(entry SyncHistoryEntry) error {
+	if hm.db != nil {
+		return hm.insertEntries([]SyncHistoryEntry{entry})
+	}
+
 	// Acquire file lock
 	lockFd, err := hm.acquireLock()
 	if err != nil {
@@ -136,11 +182,60 @@ func (hm *HistoryManager) appendEntry(entry SyncHistoryEntry) error {
 	return nil
 }
 
+// HistoryQuery selects history entries; zero fields don't filter
+type HistoryQuery struct {
+	Limit  int
+	Repo   string
+	Status string
+	Since  time.Time // inclusive
+	Until  time.Time // exclusive
+}
+
+// matches reports whether entry is selected by q, whose Repo is normalized
+func (q HistoryQuery) matches(entry SyncHistoryEntry) bool {
+	if q.Repo != "" && paths.NormalizeRepo(entry.RepoPath) != q.Repo {
+		return false
+	}
+	if q.Status != "" && entry.Status != q.Status {
+		return false
+	}
+	if !q.Since.IsZero() && entry.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !entry.Timestamp.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
 // GetHistory retrieves sync history entries with optional filtering
 func (hm *HistoryManager) GetHistory(limit int, repoFilter string, failedOnly bool) ([]SyncHistoryEntry, error) {
+	q := HistoryQuery{Limit: limit, Repo: repoFilter}
+	if failedOnly {
+		q.Status = "failed"
+	}
+	return hm.QueryHistory(q)
+}
+
+// QueryHistory returns the entries q selects, newest first; the sqlite backend
+// answers from its indexes, JSONL history is read in full
+func (hm *HistoryManager) QueryHistory(q HistoryQuery) ([]SyncHistoryEntry, error) {
 	hm.mu.Lock()
 	defer hm.mu.Unlock()
 
+	q.Repo = paths.NormalizeRepo(q.Repo)
+
+	// Entries not written yet because the history file isn't writable come last
+	entries := hm.pendingHistory(q)
+
+	if hm.db != nil {
+		stored, err := hm.queryDatabase(q)
+		if err != nil {
+			return nil, err
+		}
+		return hm.sortHistory(append(entries, stored...), q.Limit), nil
+	}
+
 	// Acquire file lock for reading
 	lockFd, err := hm.acquireLock()
 	if err != nil {
@@ -151,7 +246,7 @@ func (hm *HistoryManager) GetHistory(limit int, repoFilter string, failedOnly bo
 	file, err := os.Open(hm.historyFile)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return []SyncHistoryEntry{}, nil
+			return hm.sortHistory(entries, q.Limit), nil
 		}
 		return nil, fmt.Errorf("failed to open history file: %w", err)
 	}
@@ -161,7 +256,6 @@ func (hm *HistoryManager) GetHistory(limit int, repoFilter string, failedOnly bo
 		}
 	}()
 
-	var entries []SyncHistoryEntry
 	scanner := bufio.NewScanner(file)
 
 	for scanner.Scan() {
@@ -176,11 +270,7 @@ func (hm *HistoryManager) GetHistory(limit int, repoFilter string, failedOnly bo
 			continue
 		}
 
-		// Apply filters
-		if repoFilter != "" && entry.RepoPath != repoFilter {
-			continue
-		}
-		if failedOnly && entry.Status != "failed" {
+		if !q.matches(entry) {
 			continue
 		}
 
@@ -191,6 +281,11 @@ func (hm *HistoryManager) GetHistory(limit int, repoFilter string, failedOnly bo
 		return nil, fmt.Errorf("failed to read history file: %w", err)
 	}
 
+	return hm.sortHistory(entries, q.Limit), nil
+}
+
+// sortHistory sorts entries newest first and keeps the first limit of them
+func (hm *HistoryManager) sortHistory(entries []SyncHistoryEntry, limit int) []SyncHistoryEntry {
 	// Sort by timestamp (newest first)
 	sort.Slice(entries, func(i, j int) bool {
 		return entries[i].Timestamp.After(entries[j].Timestamp)
@@ -201,11 +296,14 @@ func (hm *HistoryManager) GetHistory(limit int, repoFilter string, failedOnly bo
 		entries = entries[:limit]
 	}
 
-	return entries, nil
+	return entries
 }
 
 // shouldRotateFile checks if the history file should be rotated
 func (hm *HistoryManager) shouldRotateFile() bool {
+	if hm.db != nil {
+		return hm.databaseSize() > hm.maxFileSizeMB
+	}
 	info, err := os.Stat(hm.historyFile)
 	if err != nil {
 		return false
@@ -215,6 +313,9 @@ func (hm *HistoryManager) shouldRotateFile() bool {
 
 // rotateFile rotates the current history file
 func (hm *HistoryManager) rotateFile() error {
+	if hm.db != nil {
+		return hm.trimDatabase()
+	}
 	oldFile := hm.historyFile + ".old"
 	
 	// Remove old backup if it exists
@@ -237,6 +338,17 @@ func (hm *HistoryManager) CleanOldEntries() error {
 	defer hm.mu.Unlock()
 
 	cutoff := time.Now().AddDate(0, 0, -hm.retentionDays)
+
+	if hm.db != nil {
+		removedCount, err := hm.deleteEntries("timestamp <= ?", cutoff.UnixNano())
+		if err != nil {
+			return err
+		}
+		if removedCount > 0 {
+			hm.logger.Info("Cleaned old history entries", "removed_count", removedCount, "retention_days", hm.retentionDays)
+		}
+		return nil
+	}
 	
 	// Get all entries
 	entries, err := hm.getAllEntries()
@@ -269,6 +381,96 @@ func (hm *HistoryManager) CleanOldEntries() error {
 	return nil
 }
 
+// RemoveRepository deletes every entry of the repository at repoPath, returning how many were removed
+func (hm *HistoryManager) RemoveRepository(repoPath string) (int, error) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	// Entries held back in memory would otherwise be written back later
+	pending := len(hm.pending)
+	hm.pending = slices.DeleteFunc(hm.pending, func(entry SyncHistoryEntry) bool {
+		return paths.SameRepo(entry.RepoPath, repoPath)
+	})
+	unwritten := pending - len(hm.pending)
+
+	if hm.db != nil {
+		removed, err := hm.deleteEntries("repo_key = ?", paths.NormalizeRepo(repoPath))
+		return removed + unwritten, err
+	}
+
+	entries, err := hm.getAllEntries()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var kept []SyncHistoryEntry
+	for _, entry := range entries {
+		if !paths.SameRepo(entry.RepoPath, repoPath) {
+			kept = append(kept, entry)
+		}
+	}
+	removed := len(entries) - len(kept)
+	if removed == 0 {
+		return unwritten, nil
+	}
+	if err := hm.rewriteHistoryFile(kept); err != nil {
+		return unwritten, fmt.Errorf("failed to rewrite history: %w", err)
+	}
+	return removed + unwritten, nil
+}
+
+// HistoryCheck is the result of reading every line of the history file, or of
+// checking the integrity of the history database
+type HistoryCheck struct {
+	File         string
+	Entries      int
+	CorruptLines []int // line numbers that aren't valid entries
+}
+
+// Check reads the history file and reports the lines that can't be parsed,
+// which history listings skip
+func (hm *HistoryManager) Check() (HistoryCheck, error) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	check := HistoryCheck{File: hm.historyFile}
+	if hm.db != nil {
+		return hm.checkDatabase(check)
+	}
+	lockFd, err := hm.acquireLock()
+	if err != nil {
+		return check, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer hm.releaseLock(lockFd)
+
+	file, err := os.Open(hm.historyFile)
+	if os.IsNotExist(err) {
+		return check, nil
+	}
+	if err != nil {
+		return check, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry SyncHistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.RepoPath == "" {
+			check.CorruptLines = append(check.CorruptLines, lineNumber)
+			continue
+		}
+		check.Entries++
+	}
+	if err := scanner.Err(); err != nil {
+		return check, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return check, nil
+}
+
 // getAllEntries reads all entries from the history file
 func (hm *HistoryManager) getAllEntries() ([]SyncHistoryEntry, error) {
 	file, err := os.Open(hm.historyFile)
@@ -370,7 +572,7 @@ func (hm *HistoryManager) acquireLock() (*os.File, error) {
 		return nil, err
 	}
 
-	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX); err != nil {
+	if err := filelock.Lock(lockFile); err != nil {
 		if err := lockFile.Close(); err != nil {
 			fmt.Printf("Warning: failed to close lock file: %v\n", err)
 		}
@@ -382,7 +584,7 @@ func (hm *HistoryManager) acquireLock() (*os.File, error) {
 
 // releaseLock releases the file lock
 func (hm *HistoryManager) releaseLock(lockFile *os.File) {
-	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN); err != nil {
+	if err := filelock.Unlock(lockFile); err != nil {
 		fmt.Printf("Warning: failed to unlock file: %v\n", err)
 	}
 	if err := lockFile.Close(); err != nil {
@@ -0,0 +1,108 @@
+package daemon
+
+import "time"
+
+// maxPendingHistory bounds the entries kept in memory while the history file
+// isn't writable; the oldest are dropped beyond it
+const maxPendingHistory = 1000
+
+// pendingRetryInterval is how often entries and notifications held back during
+// an outage are retried when no new ones come along
+const pendingRetryInterval = time.Minute
+
+// writeBuffered writes the entries held back by earlier failures, then entry,
+// keeping whatever couldn't be written for the next attempt; the caller holds hm.mu
+func (hm *HistoryManager) writeBuffered(entry SyncHistoryEntry) error {
+	if err := hm.flushPendingLocked(); err != nil {
+		hm.bufferLocked(entry)
+		return err
+	}
+	if err := hm.appendEntry(entry); err != nil {
+		hm.bufferLocked(entry)
+		return err
+	}
+	return nil
+}
+
+// bufferLocked holds entry back for a later write, dropping the oldest held back
+// entry when the buffer is full; the caller holds hm.mu
+func (hm *HistoryManager) bufferLocked(entry SyncHistoryEntry) {
+	if len(hm.pending) >= maxPendingHistory {
+		hm.pending = hm.pending[1:]
+		hm.health.dropped++
+	}
+	hm.pending = append(hm.pending, entry)
+}
+
+// flushPendingLocked writes the held back entries in order, stopping at the first
+// failure; the caller holds hm.mu
+func (hm *HistoryManager) flushPendingLocked() error {
+	written := 0
+	for len(hm.pending) > 0 {
+		if err := hm.appendEntry(hm.pending[0]); err != nil {
+			return err
+		}
+		hm.pending = hm.pending[1:]
+		written++
+	}
+	if written > 0 {
+		hm.pending = nil
+		hm.health.writes += int64(written)
+		hm.logger.Info("Sync history is writable again, recorded the entries kept in memory",
+			"entries", written)
+	}
+	return nil
+}
+
+// FlushPending retries writing the entries held back while the history file
+// wasn't writable
+func (hm *HistoryManager) FlushPending() {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	if len(hm.pending) == 0 {
+		return
+	}
+	if err := hm.flushPendingLocked(); err != nil {
+		hm.logger.Debug("History file is still not writable", "error", err, "pending", len(hm.pending))
+		return
+	}
+	hm.health.consecutiveFailures = 0
+	if hm.shouldRotateFile() {
+		if err := hm.rotateFile(); err != nil {
+			hm.logger.Error("Failed to rotate history file", "error", err)
+		} else {
+			hm.recordRotation()
+		}
+	}
+}
+
+// pendingHistory returns the held back entries q selects; q.Repo is normalized
+// and the caller holds hm.mu
+func (hm *HistoryManager) pendingHistory(q HistoryQuery) []SyncHistoryEntry {
+	var entries []SyncHistoryEntry
+	for _, entry := range hm.pending {
+		if q.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// startPendingRetry retries history entries and notifications held back during
+// an outage until the daemon stops, so they don't wait for the next sync
+func (d *Daemon) startPendingRetry() {
+	ticker := time.NewTicker(pendingRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if d.historyManager != nil {
+				d.historyManager.FlushPending()
+			}
+			d.notificationManager.FlushPending()
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
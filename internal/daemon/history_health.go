@@ -0,0 +1,147 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bnema/git-sync/internal/fleet"
+)
+
+// historyAlertFailures is how many history writes in a row fail before the user is alerted
+const historyAlertFailures = 3
+
+// HistoryHealth reports whether sync history is being recorded, since the daemon started
+type HistoryHealth struct {
+	File                string    `json:"file"`
+	FileSizeBytes       int64     `json:"file_size_bytes"`
+	OldFileSizeBytes    int64     `json:"old_file_size_bytes,omitempty"` // the rotated .old file
+	Writes              int64     `json:"writes"`
+	WriteFailures       int64     `json:"write_failures"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastFailure         time.Time `json:"last_failure,omitempty"`
+	Rotations           int64     `json:"rotations"`
+	LastRotation        time.Time `json:"last_rotation,omitempty"`
+	Pending             int       `json:"pending,omitempty"` // entries kept in memory until the file is writable
+	Dropped             int64     `json:"dropped,omitempty"` // entries lost because too many were pending
+}
+
+// Failing reports whether the latest history writes failed
+func (h HistoryHealth) Failing() bool {
+	return h.ConsecutiveFailures > 0
+}
+
+// historyHealth is the mutable state behind HistoryHealth; guarded by HistoryManager.mu
+type historyHealth struct {
+	writes              int64
+	writeFailures       int64
+	consecutiveFailures int
+	lastError           string
+	lastFailure         time.Time
+	rotations           int64
+	lastRotation        time.Time
+	dropped             int64
+	alertPending        bool
+}
+
+// recordWrite counts the outcome of a history write; the caller holds hm.mu
+func (hm *HistoryManager) recordWrite(err error) {
+	h := &hm.health
+	if err == nil {
+		h.writes++
+		h.consecutiveFailures = 0
+		return
+	}
+
+	h.writeFailures++
+	h.consecutiveFailures++
+	h.lastError = err.Error()
+	h.lastFailure = time.Now()
+	if h.consecutiveFailures == historyAlertFailures {
+		h.alertPending = true
+	}
+}
+
+// recordRotation counts a rotation of the history file; the caller holds hm.mu
+func (hm *HistoryManager) recordRotation() {
+	hm.health.rotations++
+	hm.health.lastRotation = time.Now()
+}
+
+// Health reports history writes, failures and rotations, and the current file sizes
+func (hm *HistoryManager) Health() HistoryHealth {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	h := hm.health
+	health := HistoryHealth{
+		File:                hm.historyFile,
+		Writes:              h.writes,
+		WriteFailures:       h.writeFailures,
+		ConsecutiveFailures: h.consecutiveFailures,
+		LastError:           h.lastError,
+		LastFailure:         h.lastFailure,
+		Rotations:           h.rotations,
+		LastRotation:        h.lastRotation,
+		Pending:             len(hm.pending),
+		Dropped:             h.dropped,
+	}
+	if info, err := os.Stat(hm.historyFile); err == nil && info.Mode().IsRegular() {
+		health.FileSizeBytes = info.Size()
+	}
+	if info, err := os.Stat(hm.historyFile + ".old"); err == nil && info.Mode().IsRegular() {
+		health.OldFileSizeBytes = info.Size()
+	}
+	return health
+}
+
+// takeFailureAlert reports, once per streak, that history writes keep failing
+func (hm *HistoryManager) takeFailureAlert() (HistoryHealth, bool) {
+	hm.mu.Lock()
+	pending := hm.health.alertPending
+	hm.health.alertPending = false
+	hm.mu.Unlock()
+
+	if !pending {
+		return HistoryHealth{}, false
+	}
+	return hm.Health(), true
+}
+
+// recordHistory records entry in history and reports it to the fleet, alerting
+// when history writes keep failing so syncs don't silently go unrecorded
+func (s *Scheduler) recordHistory(entry SyncHistoryEntry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if s.reporter != nil {
+		s.reporter.Add(fleet.Entry{
+			Timestamp:  entry.Timestamp,
+			Repo:       entry.RepoPath,
+			Direction:  entry.Direction,
+			Status:     entry.Status,
+			DurationMs: entry.DurationMs,
+			Error:      entry.ErrorMsg,
+			SkipReason: entry.SkipReason,
+		})
+	}
+	if s.historyManager == nil {
+		return
+	}
+	s.historyManager.RecordSync(entry)
+
+	health, alert := s.historyManager.takeFailureAlert()
+	if !alert {
+		return
+	}
+	s.logger.Error("Sync history is not being recorded",
+		"file", health.File,
+		"consecutive_failures", health.ConsecutiveFailures,
+		"error", health.LastError)
+	if s.notificationManager != nil {
+		s.notificationManager.SendAlert("✗ Git Sync History Failing",
+			fmt.Sprintf("The last %d syncs could not be recorded in %s: %s. They are kept in memory until it is writable.",
+				health.ConsecutiveFailures, health.File, health.LastError))
+	}
+}
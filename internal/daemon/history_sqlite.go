@@ -0,0 +1,311 @@
+package daemon
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bnema/git-sync/internal/paths"
+
+	_ "modernc.org/sqlite" // registers the pure-Go "sqlite" driver
+)
+
+// History backends, set with history_backend
+const (
+	HistoryBackendJSONL  = "jsonl"
+	HistoryBackendSQLite = "sqlite"
+)
+
+// Entries are kept whole as JSON, with the columns queries filter on beside them
+const historySchema = `
+CREATE TABLE IF NOT EXISTS history (
+	id        INTEGER PRIMARY KEY,
+	timestamp INTEGER NOT NULL, -- unix nanoseconds
+	repo_key  TEXT NOT NULL,    -- normalized repository path
+	status    TEXT NOT NULL,
+	entry     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS history_timestamp ON history (timestamp);
+CREATE INDEX IF NOT EXISTS history_repo ON history (repo_key, timestamp);
+CREATE INDEX IF NOT EXISTS history_status ON history (status, timestamp);
+`
+
+// openSQLite opens the history database, creating it on first use and moving
+// the entries of the JSONL history files into it
+func (hm *HistoryManager) openSQLite() error {
+	hm.historyFile = filepath.Join(hm.cacheDir, "history.db")
+
+	// The daemon and the CLI share the database: wait for each other's writes
+	// rather than failing with SQLITE_BUSY
+	dsn := "file:" + hm.historyFile + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open history database: %w", err)
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create history database %s: %w", hm.historyFile, err)
+	}
+	hm.db = db
+
+	if err := hm.migrateJSONL(); err != nil {
+		db.Close()
+		hm.db = nil
+		return fmt.Errorf("failed to migrate JSONL history: %w", err)
+	}
+	return nil
+}
+
+// migrateJSONL imports history.jsonl and its rotated .old file, then renames
+// them with a .migrated suffix so they are imported only once
+func (hm *HistoryManager) migrateJSONL() error {
+	// A daemon still on the JSONL backend appends under this lock
+	lockFd, err := hm.acquireLock()
+	if err != nil {
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer hm.releaseLock(lockFd)
+
+	jsonl := filepath.Join(hm.cacheDir, "history.jsonl")
+	for _, file := range []string{jsonl + ".old", jsonl} {
+		entries, err := readHistoryFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := hm.insertEntries(entries); err != nil {
+			return err
+		}
+		if err := os.Rename(file, file+".migrated"); err != nil {
+			return fmt.Errorf("failed to rename %s after migrating it: %w", file, err)
+		}
+		hm.logger.Info("Migrated JSONL history to SQLite", "file", file, "entries", len(entries), "database", hm.historyFile)
+	}
+	return nil
+}
+
+// exportSQLite moves the entries of a history database left by the sqlite
+// backend back into history.jsonl, then renames it with a .migrated suffix
+func (hm *HistoryManager) exportSQLite() error {
+	dbFile := filepath.Join(hm.cacheDir, "history.db")
+	if _, err := os.Stat(dbFile); err != nil {
+		return nil
+	}
+
+	lockFd, err := hm.acquireLock()
+	if err != nil {
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer hm.releaseLock(lockFd)
+
+	db, err := sql.Open("sqlite", "file:"+dbFile+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return fmt.Errorf("failed to open history database: %w", err)
+	}
+	exporter := &HistoryManager{db: db, logger: hm.logger}
+	entries, err := exporter.queryDatabase(HistoryQuery{})
+	db.Close()
+	if err != nil {
+		return err
+	}
+
+	// Appended oldest first, like they were recorded
+	slices.Reverse(entries)
+	file, err := os.OpenFile(hm.historyFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	for _, entry := range entries {
+		jsonData, err := json.Marshal(entry)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to marshal entry: %w", err)
+		}
+		writer.Write(append(jsonData, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	if err := os.Rename(dbFile, dbFile+".migrated"); err != nil {
+		return fmt.Errorf("failed to rename %s after exporting it: %w", dbFile, err)
+	}
+	hm.logger.Info("Moved SQLite history back to JSONL", "database", dbFile, "entries", len(entries), "file", hm.historyFile)
+	return nil
+}
+
+// readHistoryFile returns the entries of a JSONL history file, skipping damaged lines
+func readHistoryFile(file string) ([]SyncHistoryEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []SyncHistoryEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry SyncHistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.RepoPath == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return entries, nil
+}
+
+// insertEntries adds entries to the database in a single transaction
+func (hm *HistoryManager) insertEntries(entries []SyncHistoryEntry) error {
+	tx, err := hm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO history (timestamp, repo_key, status, entry) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, entry := range entries {
+		jsonData, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal entry: %w", err)
+		}
+		if _, err := stmt.Exec(entry.Timestamp.UnixNano(), paths.NormalizeRepo(entry.RepoPath), entry.Status, string(jsonData)); err != nil {
+			return fmt.Errorf("failed to insert entry: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit entries: %w", err)
+	}
+	return nil
+}
+
+// queryDatabase returns the entries matching q, newest first, using the indexes
+func (hm *HistoryManager) queryDatabase(q HistoryQuery) ([]SyncHistoryEntry, error) {
+	var where []string
+	var args []any
+	if q.Repo != "" {
+		where = append(where, "repo_key = ?")
+		args = append(args, q.Repo)
+	}
+	if q.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, q.Status)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "timestamp < ?")
+		args = append(args, q.Until.UnixNano())
+	}
+
+	query := "SELECT entry FROM history"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY timestamp DESC, id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := hm.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []SyncHistoryEntry
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read history row: %w", err)
+		}
+		var entry SyncHistoryEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			hm.logger.Warn("Failed to parse history row, skipping", "error", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// deleteEntries removes the rows matching where, returning how many were removed
+func (hm *HistoryManager) deleteEntries(where string, args ...any) (int, error) {
+	result, err := hm.db.Exec("DELETE FROM history WHERE "+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete history entries: %w", err)
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted history entries: %w", err)
+	}
+	return int(removed), nil
+}
+
+// databaseSize returns the bytes the history database uses, leaving out the
+// pages deleted entries freed
+func (hm *HistoryManager) databaseSize() int64 {
+	var pages, free, pageSize int64
+	err := hm.db.QueryRow("SELECT page_count, freelist_count, page_size FROM pragma_page_count(), pragma_freelist_count(), pragma_page_size()").Scan(&pages, &free, &pageSize)
+	if err != nil {
+		hm.logger.Debug("Failed to read history database size", "error", err)
+		return 0
+	}
+	return (pages - free) * pageSize
+}
+
+// trimDatabase deletes the older half of the entries once the database grows
+// past history_max_file_size_mb, as rotating drops the older JSONL file
+func (hm *HistoryManager) trimDatabase() error {
+	removed, err := hm.deleteEntries("id IN (SELECT id FROM history ORDER BY timestamp, id LIMIT (SELECT COUNT(*) / 2 FROM history))")
+	if err != nil {
+		return err
+	}
+	// Give the freed pages back to the file system
+	if _, err := hm.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to compact history database: %w", err)
+	}
+	hm.logger.Info("Trimmed history database", "removed_count", removed, "database", hm.historyFile)
+	return nil
+}
+
+// checkDatabase runs SQLite's integrity check and counts the entries
+func (hm *HistoryManager) checkDatabase(check HistoryCheck) (HistoryCheck, error) {
+	var result string
+	if err := hm.db.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		return check, fmt.Errorf("failed to check history database: %w", err)
+	}
+	if result != "ok" {
+		return check, fmt.Errorf("history database is damaged: %s", result)
+	}
+	if err := hm.db.QueryRow("SELECT COUNT(*) FROM history").Scan(&check.Entries); err != nil {
+		return check, fmt.Errorf("failed to count history entries: %w", err)
+	}
+	return check, nil
+}
@@ -0,0 +1,157 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// defaultHookTimeout bounds a hook when hook_timeout is unset
+const defaultHookTimeout = 2 * time.Minute
+
+// zeroOID is what pre-push hooks are told a ref missing on the remote points at
+const zeroOID = "0000000000000000000000000000000000000000"
+
+// findHook returns the executable hook called name of the repository, honoring
+// core.hooksPath like git does. Hooks that aren't executable are ignored, as git
+// ignores them.
+func findHook(ctx context.Context, repo configPkg.RepoConfig, name string) (string, bool) {
+	dir, err := runGit(ctx, repo.Path, append(gitConfigArgs(repo),
+		"rev-parse", "--path-format=absolute", "--git-path", "hooks")...)
+	if err != nil {
+		return "", false
+	}
+	hook := filepath.Join(dir, name)
+	info, err := os.Stat(hook)
+	if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+		return "", false
+	}
+	return hook, true
+}
+
+// runHook runs the repository's hook called name, if it has one, in the
+// worktree (the repository itself when bare), failing when the hook rejects
+// the operation or runs longer than hook_timeout
+func (g *GitOperations) runHook(ctx context.Context, repo configPkg.RepoConfig, name, stdin string, args ...string) error {
+	hook, found := findHook(ctx, repo, name)
+	if !found {
+		return nil
+	}
+
+	timeout := defaultHookTimeout
+	if repo.HookTimeout > 0 {
+		timeout = time.Duration(repo.HookTimeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook, args...)
+	cmd.Dir = repo.Path
+	cmd.Stdin = strings.NewReader(stdin)
+	// Children of a killed hook may hold its output open
+	cmd.WaitDelay = time.Second
+
+	g.logger.Debug("Running hook", "repo", repo.Path, "hook", name)
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		g.logger.Debug("Hook output",
+			"repo", repo.Path,
+			"hook", name,
+			"output", strings.TrimSpace(string(output)))
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%s hook timed out after %s", name, timeout)
+	case err != nil:
+		if msg := lastLines(string(output), 3); msg != "" {
+			return fmt.Errorf("%s hook rejected the %s: %v: %s", name, hookOperation(name), err, msg)
+		}
+		return fmt.Errorf("%s hook rejected the %s: %v", name, hookOperation(name), err)
+	}
+	return nil
+}
+
+// hookOperation names what a hook guards, for errors
+func hookOperation(name string) string {
+	if name == "pre-push" {
+		return "push"
+	}
+	return "auto-commit"
+}
+
+// runPrePush runs the pre-push hook with what git would tell it about pushing
+// refSpecs: the remote name and URL as arguments, and one "<local ref> <local
+// oid> <remote ref> <remote oid>" line per ref on stdin
+func (g *GitOperations) runPrePush(ctx context.Context, repo configPkg.RepoConfig, refSpecs []string) error {
+	if _, found := findHook(ctx, repo, "pre-push"); !found {
+		return nil
+	}
+
+	gitArgs := gitConfigArgs(repo)
+	url, _ := runGit(ctx, repo.Path, append(gitArgs, "remote", "get-url", repo.Remote)...)
+
+	var lines strings.Builder
+	for _, spec := range refSpecs {
+		src, dst, _ := strings.Cut(strings.TrimPrefix(spec, "+"), ":")
+		if dst == "" {
+			dst = src
+		}
+		for localRef, remoteRef := range expandRefSpec(ctx, repo, src, dst) {
+			localOID := zeroOID
+			if localRef != "" {
+				oid, err := runGit(ctx, repo.Path, append(gitArgs, "rev-parse", "--verify", "-q", localRef)...)
+				if err != nil {
+					continue
+				}
+				localOID = oid
+			}
+			remoteOID := zeroOID
+			tracking := "refs/remotes/" + repo.Remote + "/" + strings.TrimPrefix(remoteRef, "refs/heads/")
+			if oid, err := runGit(ctx, repo.Path, append(gitArgs, "rev-parse", "--verify", "-q", tracking)...); err == nil {
+				remoteOID = oid
+			}
+			if localOID == remoteOID {
+				continue
+			}
+			if localRef == "" {
+				localRef = "(delete)"
+			}
+			fmt.Fprintf(&lines, "%s %s %s %s\n", localRef, localOID, remoteRef, remoteOID)
+		}
+	}
+	return g.runHook(ctx, repo, "pre-push", lines.String(), repo.Remote, url)
+}
+
+// expandRefSpec maps the local refs matching a refspec's source onto the remote
+// refs they update; a deletion maps "" onto its destination
+func expandRefSpec(ctx context.Context, repo configPkg.RepoConfig, src, dst string) map[string]string {
+	if src == "" {
+		return map[string]string{"": dst}
+	}
+	srcPrefix, srcSuffix, wildcard := strings.Cut(src, "*")
+	if !wildcard {
+		return map[string]string{src: dst}
+	}
+	dstPrefix, dstSuffix, _ := strings.Cut(dst, "*")
+
+	refs, err := runGit(ctx, repo.Path, append(gitConfigArgs(repo), "for-each-ref", "--format=%(refname)", srcPrefix)...)
+	if err != nil {
+		return nil
+	}
+	expanded := make(map[string]string)
+	for _, ref := range strings.Fields(refs) {
+		if !strings.HasPrefix(ref, srcPrefix) || !strings.HasSuffix(ref, srcSuffix) {
+			continue
+		}
+		match := strings.TrimSuffix(strings.TrimPrefix(ref, srcPrefix), srcSuffix)
+		expanded[ref] = dstPrefix + match + dstSuffix
+	}
+	return expanded
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// commitSignature returns the identity for commits the daemon creates: the
+// repository's author_name/author_email, with missing parts taken from git config.
+func commitSignature(r *git.Repository, repo configPkg.RepoConfig) (*object.Signature, error) {
+	name, email := repo.AuthorName, repo.AuthorEmail
+
+	if name == "" || email == "" {
+		cfg, err := r.ConfigScoped(gitconfig.SystemScope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read git config: %w", err)
+		}
+		if name == "" {
+			name = cfg.User.Name
+		}
+		if email == "" {
+			email = cfg.User.Email
+		}
+	}
+
+	if name == "" || email == "" {
+		return nil, fmt.Errorf("no git identity for generated commits: set author_name and author_email in the repository config or user.name and user.email in git config")
+	}
+
+	return &object.Signature{Name: name, Email: email, When: time.Now()}, nil
+}
+
+// gitConfigArgs returns git -c options for commands run with the git binary:
+// the configured identity (unset fields fall back to git config), transport
+// proxy and CA bundle, and for allow_foreign_owner repositories a matching safe.directory.
+func gitConfigArgs(repo configPkg.RepoConfig) []string {
+	var args []string
+	if repo.AllowForeignOwner {
+		args = append(args, "-c", "safe.directory="+repo.Path)
+	}
+	if repo.Proxy != "" {
+		args = append(args, "-c", "http.proxy="+repo.Proxy)
+	}
+	if repo.CABundle != "" {
+		args = append(args, "-c", "http.sslCAInfo="+repo.CABundle)
+	}
+	if repo.AuthorName != "" {
+		args = append(args, "-c", "user.name="+repo.AuthorName)
+	}
+	if repo.AuthorEmail != "" {
+		args = append(args, "-c", "user.email="+repo.AuthorEmail)
+	}
+	return args
+}
@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bnema/git-sync/internal/filelock"
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+// instanceLockName is the file in the cache directory locked by the running daemon
+const instanceLockName = "daemon.lock"
+
+// ErrAlreadyRunning means another daemon holds the instance lock
+var ErrAlreadyRunning = errors.New("another git sync daemon is already running")
+
+// InstanceLock keeps a second daemon of the same user, e.g. one started by hand
+// next to the systemd service, from syncing the same repositories. The lock is
+// a file lock, so it goes away with the process however it exits.
+type InstanceLock struct {
+	file *os.File
+}
+
+// AcquireInstanceLock takes the instance lock, failing with ErrAlreadyRunning
+// and the holder's PID when another daemon has it
+func AcquireInstanceLock() (*InstanceLock, error) {
+	cacheDir, err := paths.CacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	path := filepath.Join(cacheDir, instanceLockName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open instance lock: %w", err)
+	}
+	if err := filelock.TryLock(file); err != nil {
+		pid := readLockPID(file)
+		file.Close()
+		if errors.Is(err, filelock.ErrLocked) {
+			if pid > 0 {
+				return nil, fmt.Errorf("%w (pid %d, lock %s)", ErrAlreadyRunning, pid, path)
+			}
+			return nil, fmt.Errorf("%w (lock %s)", ErrAlreadyRunning, path)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	// The PID is only informational, the lock is what counts
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &InstanceLock{file: file}, nil
+}
+
+// Release gives up the lock so another daemon can start
+func (l *InstanceLock) Release() {
+	if l == nil {
+		return
+	}
+	_ = filelock.Unlock(l.file)
+	l.file.Close()
+}
+
+// InstanceLockHolder reports whether a daemon holds the instance lock, with its
+// PID when it recorded one, without taking the lock for more than a moment
+func InstanceLockHolder() (pid int, held bool, err error) {
+	cacheDir, err := paths.CacheDir()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	file, err := os.Open(filepath.Join(cacheDir, instanceLockName))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to open instance lock: %w", err)
+	}
+	defer file.Close()
+
+	if err := filelock.TryRLock(file); err != nil {
+		if errors.Is(err, filelock.ErrLocked) {
+			return readLockPID(file), true, nil
+		}
+		return 0, false, fmt.Errorf("failed to check instance lock: %w", err)
+	}
+	_ = filelock.Unlock(file)
+	return 0, false, nil
+}
+
+func readLockPID(file *os.File) int {
+	buf := make([]byte, 32)
+	n, _ := file.ReadAt(buf, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
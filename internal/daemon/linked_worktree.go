@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// openRepository opens a repository with go-git. Linked worktrees (git worktree add)
+// have a .git file pointing into the main repository, whose objects and refs are
+// shared through its common directory.
+func openRepository(path string) (*git.Repository, error) {
+	return git.PlainOpenWithOptions(path, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+}
+
+// siblingWorktreeBranches returns the branches checked out in the other worktrees
+// of repo, mapped to their worktree paths. Those branches must not be checked out
+// or moved from here, or the sibling's index and files would silently go stale.
+func (g *GitOperations) siblingWorktreeBranches(ctx context.Context, repo configPkg.RepoConfig) map[string]string {
+	branches := make(map[string]string)
+
+	args := append(gitConfigArgs(repo), "worktree", "list", "--porcelain")
+	output, err := runGit(ctx, repo.Path, args...)
+	if err != nil {
+		g.logger.Debug("Failed to list worktrees", "repo", repo.Path, "error", err)
+		return branches
+	}
+
+	self := resolvePath(repo.Path)
+
+	// Entries are blocks of "worktree <path>", "HEAD <hash>" and "branch <ref>" lines
+	var path string
+	for _, line := range strings.Split(output, "\n") {
+		if value, ok := strings.CutPrefix(line, "worktree "); ok {
+			path = value
+			continue
+		}
+		if ref, ok := strings.CutPrefix(line, "branch refs/heads/"); ok && resolvePath(path) != self {
+			branches[ref] = path
+		}
+	}
+
+	return branches
+}
+
+// resolvePath makes worktree paths comparable despite symlinks
+func resolvePath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,370 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// acquireRepo marks repoPath as syncing, reporting false when a sync of it is already running
+func (sm *SyncManager) acquireRepo(repoPath string) bool {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+
+	if sm.busy[repoPath] {
+		return false
+	}
+	sm.busy[repoPath] = true
+	return true
+}
+
+func (sm *SyncManager) releaseRepo(repoPath string) {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+	delete(sm.busy, repoPath)
+}
+
+// syncing reports whether a sync of repoPath is running
+func (sm *SyncManager) syncing(repoPath string) bool {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+	return sm.busy[repoPath]
+}
+
+// slotWaiter is a sync queued for a slot; ready is closed once it holds one
+type slotWaiter struct {
+	priority int
+	ready    chan struct{}
+}
+
+// slotPool is the concurrency budget of one concurrency class: slots in use out
+// of max, and the syncs waiting for one by priority
+type slotPool struct {
+	max     int
+	inUse   int
+	waiters []*slotWaiter
+}
+
+// pool returns the slot pool of a concurrency class; the caller holds slotsMu
+func (sm *SyncManager) pool(class string) *slotPool {
+	if pool, exists := sm.pools[class]; exists {
+		return pool
+	}
+	return sm.pools[configPkg.ClassNormal]
+}
+
+// acquireSlot takes one of the slots of the repository's concurrency class and
+// returns how long the sync had to wait for it, zero when one was free. Waiting
+// syncs get freed slots by priority, and in arrival order within a priority.
+func (sm *SyncManager) acquireSlot(ctx context.Context, class string, priority int) (time.Duration, error) {
+	sm.slotsMu.Lock()
+	pool := sm.pool(class)
+	if pool.inUse < pool.max && len(pool.waiters) == 0 {
+		pool.inUse++
+		sm.slotsMu.Unlock()
+		return 0, nil
+	}
+
+	// Behind every waiter of the same or a higher priority
+	waiter := &slotWaiter{priority: priority, ready: make(chan struct{})}
+	i := sort.Search(len(pool.waiters), func(i int) bool {
+		return pool.waiters[i].priority < priority
+	})
+	pool.waiters = slices.Insert(pool.waiters, i, waiter)
+	sm.slotsMu.Unlock()
+
+	start := time.Now()
+	select {
+	case <-waiter.ready:
+		return time.Since(start), nil
+	case <-ctx.Done():
+		sm.slotsMu.Lock()
+		defer sm.slotsMu.Unlock()
+		if i := slices.Index(pool.waiters, waiter); i >= 0 {
+			pool.waiters = slices.Delete(pool.waiters, i, i+1)
+		} else {
+			// Handed a slot just as the context ended; pass it on
+			pool.release()
+		}
+		return time.Since(start), ctx.Err()
+	}
+}
+
+// releaseSlot frees a slot of a concurrency class, handing it straight to the
+// next sync waiting in that class
+func (sm *SyncManager) releaseSlot(class string) {
+	sm.slotsMu.Lock()
+	defer sm.slotsMu.Unlock()
+	sm.pool(class).release()
+}
+
+// release frees a slot; the caller holds slotsMu
+func (p *slotPool) release() {
+	// Slots beyond a lowered limit go away instead of being handed on
+	if len(p.waiters) == 0 || p.inUse > p.max {
+		p.inUse--
+		return
+	}
+	next := p.waiters[0]
+	p.waiters = p.waiters[1:]
+	close(next.ready)
+}
+
+// resize changes the limit of the pool, handing new slots to waiting syncs right
+// away; the caller holds slotsMu
+func (p *slotPool) resize(n int) {
+	p.max = n
+	for p.inUse < p.max && len(p.waiters) > 0 {
+		p.inUse++
+		next := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		close(next.ready)
+	}
+}
+
+// MaxConcurrent returns how many syncs of normal repositories may run at once
+func (sm *SyncManager) MaxConcurrent() int {
+	sm.slotsMu.Lock()
+	defer sm.slotsMu.Unlock()
+	return sm.pools[configPkg.ClassNormal].max
+}
+
+// ClassLimits returns how many syncs of each concurrency class may run at once
+func (sm *SyncManager) ClassLimits() map[string]int {
+	sm.slotsMu.Lock()
+	defer sm.slotsMu.Unlock()
+	limits := make(map[string]int, len(sm.pools))
+	for class, pool := range sm.pools {
+		limits[class] = pool.max
+	}
+	return limits
+}
+
+// Slots returns how many syncs hold a slot and how many are waiting for one, in
+// every concurrency class
+func (sm *SyncManager) Slots() (running, waiting int) {
+	sm.slotsMu.Lock()
+	defer sm.slotsMu.Unlock()
+	for _, pool := range sm.pools {
+		running += pool.inUse
+		waiting += len(pool.waiters)
+	}
+	return running, waiting
+}
+
+// SetMaxConcurrent changes how many syncs of normal repositories may run at
+// once. Running syncs keep their slots; a lower limit applies as they finish, a
+// higher one hands the new slots to waiting syncs right away.
+func (sm *SyncManager) SetMaxConcurrent(n int) {
+	sm.slotsMu.Lock()
+	defer sm.slotsMu.Unlock()
+	sm.pools[configPkg.ClassNormal].resize(n)
+}
+
+// SetClassLimits changes how many syncs of io-heavy and light repositories may
+// run at once, like SetMaxConcurrent does for normal ones
+func (sm *SyncManager) SetClassLimits(ioHeavy, light int) {
+	sm.slotsMu.Lock()
+	defer sm.slotsMu.Unlock()
+	sm.pools[configPkg.ClassIOHeavy].resize(ioHeavy)
+	sm.pools[configPkg.ClassLight].resize(light)
+}
+
+// LastQueueWait returns how long the most recent sync of repoPath waited for a free slot
+func (sm *SyncManager) LastQueueWait(repoPath string) time.Duration {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+	return sm.queueWait[repoPath]
+}
+
+func (sm *SyncManager) recordQueueWait(repoPath string, wait time.Duration) {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+	sm.queueWait[repoPath] = wait
+}
+
+// hostLimiter holds back syncs talking to the same remote host: at most max of
+// them at once (max_syncs_per_host) and their starts delay apart
+// (host_sync_delay). Zero leaves either unlimited.
+type hostLimiter struct {
+	mu        sync.Mutex
+	max       int
+	delay     time.Duration
+	running   map[string]int
+	waiters   map[string][]chan struct{}
+	nextStart map[string]time.Time
+}
+
+func newHostLimiter() *hostLimiter {
+	return &hostLimiter{
+		running:   make(map[string]int),
+		waiters:   make(map[string][]chan struct{}),
+		nextStart: make(map[string]time.Time),
+	}
+}
+
+// configure changes the limits, starting the syncs a higher max lets through
+// right away; running syncs keep their place when it is lowered
+func (h *hostLimiter) configure(max int, delay time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.max, h.delay = max, delay
+	for host, waiters := range h.waiters {
+		for len(waiters) > 0 && (h.max == 0 || h.running[host] < h.max) {
+			h.running[host]++
+			close(waiters[0])
+			waiters = waiters[1:]
+		}
+		h.waiters[host] = waiters
+	}
+}
+
+// limited reports whether either limit is set
+func (h *hostLimiter) limited() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.max > 0 || h.delay > 0
+}
+
+// acquire waits until a sync may start talking to host and returns how long
+// that took; "" never waits. Each acquire is paired with a release.
+func (h *hostLimiter) acquire(ctx context.Context, host string) (time.Duration, error) {
+	if host == "" {
+		return 0, nil
+	}
+	start := time.Now()
+
+	h.mu.Lock()
+	if h.max > 0 && (h.running[host] >= h.max || len(h.waiters[host]) > 0) {
+		ready := make(chan struct{})
+		h.waiters[host] = append(h.waiters[host], ready)
+		h.mu.Unlock()
+		select {
+		case <-ready:
+			// The releasing sync handed its place over
+		case <-ctx.Done():
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if i := slices.Index(h.waiters[host], ready); i >= 0 {
+				h.waiters[host] = slices.Delete(h.waiters[host], i, i+1)
+			} else {
+				h.releaseLocked(host)
+			}
+			return time.Since(start), ctx.Err()
+		}
+		h.mu.Lock()
+	} else {
+		h.running[host]++
+	}
+
+	// Starts are spaced out in the order syncs got their place
+	now := time.Now()
+	at := h.nextStart[host]
+	if at.Before(now) {
+		at = now
+	}
+	h.nextStart[host] = at.Add(h.delay)
+	h.mu.Unlock()
+
+	if wait := time.Until(at); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			h.release(host)
+			return time.Since(start), ctx.Err()
+		}
+	}
+	return time.Since(start), nil
+}
+
+// release gives up the place of a sync that talked to host, handing it to the
+// next sync waiting for the host
+func (h *hostLimiter) release(host string) {
+	if host == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.releaseLocked(host)
+}
+
+// releaseLocked is release for callers holding mu
+func (h *hostLimiter) releaseLocked(host string) {
+	if waiters := h.waiters[host]; len(waiters) > 0 && (h.max == 0 || h.running[host] <= h.max) {
+		close(waiters[0])
+		h.waiters[host] = waiters[1:]
+		return
+	}
+	if h.running[host]--; h.running[host] <= 0 {
+		delete(h.running, host)
+		delete(h.waiters, host)
+	}
+}
+
+// remoteHost returns the lowercased host of the repository's remote, "" for
+// local remotes or when it can't be read
+func remoteHost(repo configPkg.RepoConfig) string {
+	r, err := openRepository(repo.Path)
+	if err != nil {
+		return ""
+	}
+	remote, err := r.Remote(repo.Remote)
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return ""
+	}
+	host, _, _ := strings.Cut(remoteKey(remote.Config().URLs[0]), "/")
+	return host
+}
+
+// SetHostLimits changes how many syncs may talk to one remote host at once,
+// and how far apart their starts are; zero lifts either limit
+func (sm *SyncManager) SetHostLimits(max int, delay time.Duration) {
+	sm.hosts.configure(max, delay)
+}
+
+// LastHostWait returns how long the most recent sync of repoPath was held back by the per-host limits
+func (sm *SyncManager) LastHostWait(repoPath string) time.Duration {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+	return sm.hostWait[repoPath]
+}
+
+func (sm *SyncManager) recordHostWait(repoPath string, wait time.Duration) {
+	sm.locksMu.Lock()
+	defer sm.locksMu.Unlock()
+	sm.hostWait[repoPath] = wait
+}
+
+// checkIndexLock skips repositories where another git process holds the index
+// lock, like git itself refuses to run. Repositories git cannot inspect are left
+// to the backend, which reports the actual problem.
+func checkIndexLock(ctx context.Context, repo configPkg.RepoConfig) error {
+	lockPath := IndexLockFile(ctx, repo)
+	if lockPath == "" {
+		return nil
+	}
+	return newSkipError(SkipRepoLocked,
+		"another git process is running in the repository (%s exists), skipping sync; remove the file if no git command is running", lockPath)
+}
+
+// IndexLockFile returns the index.lock of the repository when it exists, which
+// makes git and syncs refuse to run, or "" otherwise
+func IndexLockFile(ctx context.Context, repo configPkg.RepoConfig) string {
+	lockPath, err := runGit(ctx, repo.Path, append(gitConfigArgs(repo),
+		"rev-parse", "--path-format=absolute", "--git-path", "index.lock")...)
+	if err != nil {
+		return ""
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		return ""
+	}
+	return lockPath
+}
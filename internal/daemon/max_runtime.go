@@ -0,0 +1,88 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bnema/git-sync/internal/config"
+)
+
+// errMaxRuntime is the cause of syncs cancelled by their max_runtime
+var errMaxRuntime = errors.New("max_runtime exceeded")
+
+// PartialError reports a sync stopped by its max_runtime after it updated refs.
+// Finished fetches, ref updates and merges are kept. A transfer cut midway is
+// not: neither backend keeps part of a pack, so the next sync starts it over.
+type PartialError struct {
+	Budget   time.Duration
+	Progress TransferProgress // how far the sync got
+	Err      error
+}
+
+func (e *PartialError) Error() string {
+	msg := fmt.Sprintf("stopped after max_runtime of %s, kept %s", e.Budget, e.Summary())
+	if e.Progress.Phase != "" {
+		msg += fmt.Sprintf(", dropped the transfer cut at %s %d%%", e.Progress.Phase, e.Progress.Percent)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// Summary describes the progress the stopped sync kept, e.g.
+// "refs updated, 120.5 MiB fetched"
+func (e *PartialError) Summary() string {
+	summary := "refs updated"
+	if e.Progress.BytesFetched > 0 {
+		summary += ", " + formatBytes(e.Progress.BytesFetched) + " fetched"
+	}
+	return summary
+}
+
+// AsPartialError reports whether err (or anything it wraps) is a PartialError
+func AsPartialError(err error) (*PartialError, bool) {
+	var partialErr *PartialError
+	if errors.As(err, &partialErr) {
+		return partialErr, true
+	}
+	return nil, false
+}
+
+// withMaxRuntime bounds ctx by the repository's max_runtime, when it has one
+func withMaxRuntime(ctx context.Context, repo config.RepoConfig) (context.Context, context.CancelFunc) {
+	if repo.MaxRuntime <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, time.Duration(repo.MaxRuntime)*time.Second, errMaxRuntime)
+}
+
+// runtimeExceeded turns the error of a sync stopped by its max_runtime into a
+// PartialError when the sync started at start updated refs, and into a
+// timeout otherwise: the data of a cut transfer is dropped, so repositories
+// whose fetch never fits in the budget still fail
+func (sm *SyncManager) runtimeExceeded(repo config.RepoConfig, start time.Time, err error) error {
+	budget := time.Duration(repo.MaxRuntime) * time.Second
+	transfer, ok := sm.LastTransfer(repo.Path)
+	if !ok || transfer.StartedAt.Before(start) || !transfer.RefsUpdated {
+		return fmt.Errorf("timed out after max_runtime of %s without updating any ref: %w", budget, err)
+	}
+	return &PartialError{Budget: budget, Progress: transfer, Err: err}
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,78 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bnema/git-sync/internal/metrics"
+)
+
+// metricsScrapeTimeout bounds reading a scrape request and writing its response
+const metricsScrapeTimeout = 10 * time.Second
+
+// metricsSnapshot returns the sync metrics along with the history and slot
+// gauges, which are read from their owners at the time of the snapshot
+func (d *Daemon) metricsSnapshot() metrics.Snapshot {
+	snapshot := d.metrics.Snapshot()
+	if d.historyManager != nil {
+		health := d.historyManager.Health()
+		snapshot.History = &metrics.HistoryMetrics{
+			Writes:        health.Writes,
+			WriteFailures: health.WriteFailures,
+			Rotations:     health.Rotations,
+			FileSizeBytes: health.FileSizeBytes,
+			Pending:       health.Pending,
+			Dropped:       health.Dropped,
+		}
+	}
+	running, waiting := d.syncManager.Slots()
+	snapshot.Queue = &metrics.QueueMetrics{Running: running, Waiting: waiting}
+	return snapshot
+}
+
+// startMetricsServer serves the metrics in the Prometheus text format at
+// /metrics on addr, without authentication like other exporters
+func (d *Daemon) startMetricsServer(addr string) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		d.logger.Warn("Failed to start metrics listener", "addr", addr, "error", err)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", d.serveMetrics)
+	d.metricsServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: metricsScrapeTimeout,
+		WriteTimeout:      metricsScrapeTimeout,
+	}
+	server := d.metricsServer
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.logger.Error("Metrics listener stopped", "error", err)
+		}
+	}()
+	d.logger.Info("Metrics listening", "addr", listener.Addr().String())
+}
+
+// stopMetricsServer shuts the metrics listener down, if it was started
+func (d *Daemon) stopMetricsServer() {
+	if d.metricsServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.metricsServer.Shutdown(ctx); err != nil {
+		d.logger.Debug("Failed to shut down metrics listener", "error", err)
+	}
+}
+
+func (d *Daemon) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := d.metricsSnapshot().WritePrometheus(w); err != nil {
+		d.logger.Debug("Failed to write metrics response", "remote", r.RemoteAddr, "error", err)
+	}
+}
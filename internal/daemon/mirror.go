@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bnema/git-sync/internal/config"
+)
+
+// defaultMirrorTimeout bounds a mirror step when mirror_timeout is unset
+const defaultMirrorTimeout = 10 * time.Minute
+
+// mirrorState serializes the mirror steps of one repository, whose pulls and
+// pushes may sync separately, and remembers how the last one went
+type mirrorState struct {
+	mu       sync.Mutex
+	mirrored bool // the last mirror step succeeded
+	failing  bool // the last mirror step failed and was notified
+}
+
+// mirrorStateFor returns the mirror state of the repository at path
+func (s *Scheduler) mirrorStateFor(path string) *mirrorState {
+	s.mirrorsMu.Lock()
+	defer s.mirrorsMu.Unlock()
+	state, exists := s.mirrors[path]
+	if !exists {
+		state = &mirrorState{}
+		s.mirrors[path] = state
+	}
+	return state
+}
+
+// runMirror runs the repository's mirror command after a successful sync that
+// changed something, or after the first one since the daemon started or the
+// last failed mirror, so the mirror catches up on what it missed. The step is
+// recorded in history as its own "mirror" entry; its failure doesn't fail the sync.
+func (s *Scheduler) runMirror(repo config.RepoConfig, synced SyncHistoryEntry) {
+	state := s.mirrorStateFor(repo.Path)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if synced.Status == "noop" && state.mirrored {
+		return
+	}
+
+	timeout := defaultMirrorTimeout
+	if repo.MirrorTimeout > 0 {
+		timeout = time.Duration(repo.MirrorTimeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", repo.Mirror)
+	cmd.Dir = repo.Path
+	cmd.Env = append(os.Environ(),
+		"GIT_SYNC_REPO="+repo.Path,
+		"GIT_SYNC_DIRECTION="+synced.Direction,
+	)
+	// Children of a killed command may hold its output open
+	cmd.WaitDelay = time.Second
+
+	s.logger.Info("Running mirror step",
+		"repo", repo.Path,
+		"command", repo.Mirror)
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	duration := time.Since(start)
+	if len(output) > 0 {
+		s.logger.Debug("Mirror step output",
+			"repo", repo.Path,
+			"output", strings.TrimSpace(string(output)))
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("mirror timed out after %s", timeout)
+	} else if err != nil {
+		if msg := lastLines(string(output), 3); msg != "" {
+			err = fmt.Errorf("mirror failed: %w: %s", err, msg)
+		} else {
+			err = fmt.Errorf("mirror failed: %w", err)
+		}
+	}
+
+	entry := SyncHistoryEntry{
+		RepoPath:   repo.Path,
+		Direction:  "mirror",
+		Status:     "success",
+		DurationMs: duration.Milliseconds(),
+	}
+	if err != nil {
+		entry.Status = "failed"
+		entry.ErrorMsg = err.Error()
+		entry.ErrorKind = string(ErrorHook)
+	}
+	if repo.HistoryEnabled() {
+		s.recordHistory(entry)
+	}
+
+	state.mirrored = err == nil
+	if err != nil {
+		s.logger.Error("Mirror step failed",
+			"repo", repo.Path,
+			"error", err,
+			"kind", entry.ErrorKind,
+			"duration", duration)
+		// A mirror failing again on the next syncs notifies only once
+		if !state.failing && s.notificationManager != nil && repo.NotificationsEnabled() {
+			s.notificationManager.SendSyncNotification(repo.Path, "mirror", "failed", duration, entry.ErrorMsg)
+		}
+		state.failing = true
+		return
+	}
+	state.failing = false
+	s.logger.Info("Mirror step completed",
+		"repo", repo.Path,
+		"duration", duration)
+}
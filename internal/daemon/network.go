@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// networkSettleDelay gives DNS, VPNs and captive portals time to come up after connecting
+const networkSettleDelay = 10 * time.Second
+
+// nmStateConnectedGlobal is NetworkManager's state for full connectivity
+const nmStateConnectedGlobal = "uint32 70"
+
+// networkMatchRules select NetworkManager's state changes and iwd's station state changes
+var networkMatchRules = []string{
+	"type='signal',interface='org.freedesktop.NetworkManager',member='StateChanged'",
+	"type='signal',sender='net.connman.iwd',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged',arg0='net.connman.iwd.Station'",
+}
+
+// watchNetwork calls onConnect shortly after NetworkManager or iwd reports the
+// machine connected, until ctx is done. The signals are read from dbus-monitor
+// on the system bus; without it, network changes are not watched.
+func watchNetwork(ctx context.Context, logger *slog.Logger, onConnect func()) {
+	monitor, err := exec.LookPath("dbus-monitor")
+	if err != nil {
+		logger.Debug("dbus-monitor not available, not watching network changes")
+		return
+	}
+
+	cmd := exec.CommandContext(ctx, monitor, append([]string{"--system"}, networkMatchRules...)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		logger.Warn("Failed to watch network changes", "error", err)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		logger.Warn("Failed to watch network changes", "error", err)
+		return
+	}
+	logger.Debug("Watching network changes")
+
+	connected := make(chan struct{}, 1)
+	go parseNetworkSignals(stdout, connected)
+
+	go func() {
+		defer cmd.Wait()
+
+		// Connecting often takes several state changes; sync once they settle
+		settle := time.NewTimer(networkSettleDelay)
+		settle.Stop()
+		defer settle.Stop()
+
+		for {
+			select {
+			case _, ok := <-connected:
+				if !ok {
+					if ctx.Err() == nil {
+						logger.Warn("Stopped watching network changes, dbus-monitor exited")
+					}
+					return
+				}
+				settle.Reset(networkSettleDelay)
+			case <-settle.C:
+				logger.Info("Network connected, syncing repositories")
+				onConnect()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// parseNetworkSignals reads dbus-monitor output and sends on connected for every
+// signal reporting a connection; connected is closed when the output ends
+func parseNetworkSignals(r io.Reader, connected chan<- struct{}) {
+	defer close(connected)
+
+	var member string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "signal "):
+			member = ""
+			if _, after, ok := strings.Cut(line, "member="); ok {
+				if fields := strings.Fields(after); len(fields) > 0 {
+					member = fields[0]
+				}
+			}
+			continue
+		case member == "StateChanged" && line == nmStateConnectedGlobal:
+		case member == "PropertiesChanged" && strings.HasPrefix(line, "variant") && strings.HasSuffix(line, `string "connected"`):
+		default:
+			continue
+		}
+
+		select {
+		case connected <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"context"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// pushNeeded reports whether a branch the push would send differs from its
+// remote-tracking ref. Pushes and fetches keep those refs current, so equal refs
+// mean nothing changed locally since the last sync and the network round trip
+// can be skipped. Custom refspecs and force_push always push.
+func (g *GitOperations) pushNeeded(ctx context.Context, r *git.Repository, repo configPkg.RepoConfig) bool {
+	if len(repo.PushRefSpecs) > 0 || repo.ForcePush {
+		return true
+	}
+
+	var branches []string
+	switch repo.BranchStrategy {
+	case "current":
+		head, err := r.Head()
+		if err != nil {
+			return true
+		}
+		branches = []string{head.Name().Short()}
+	case "main":
+		branches = []string{g.defaultBranch(ctx, r, repo)}
+	case "specific":
+		branches = []string{repo.TargetBranch}
+	case "all":
+		iter, err := r.Branches()
+		if err != nil {
+			return true
+		}
+		_ = iter.ForEach(func(ref *plumbing.Reference) error {
+			branches = append(branches, ref.Name().Short())
+			return nil
+		})
+	default:
+		return true
+	}
+
+	for _, branch := range branches {
+		local, err := r.Reference(plumbing.NewBranchReferenceName(branch), true)
+		if err != nil {
+			return true
+		}
+		tracking, err := r.Reference(plumbing.NewRemoteReferenceName(repo.Remote, branch), true)
+		if err != nil || tracking.Hash() != local.Hash() {
+			return true
+		}
+	}
+
+	g.logger.Debug("Push: nothing to push, branches match their remote-tracking refs",
+		"repo", repo.Path)
+	return false
+}
+
+// RefUpdate is a reference a sync created, moved or deleted; Old is empty for
+// created references and New for deleted ones
+type RefUpdate struct {
+	Ref string `json:"ref"`
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// refSnapshot maps the names of all references to the commits they point at,
+// so comparing snapshots taken before and after a sync tells what it changed
+func refSnapshot(r *git.Repository) map[string]string {
+	refs := make(map[string]string)
+	iter, err := r.References()
+	if err != nil {
+		return refs
+	}
+	_ = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			refs[ref.Name().String()] = ref.Hash().String()
+		}
+		return nil
+	})
+	return refs
+}
+
+// movedRefs lists the references that differ between two snapshots, sorted by name
+func movedRefs(before, after map[string]string) []RefUpdate {
+	var moved []RefUpdate
+	for name, hash := range after {
+		if before[name] != hash {
+			moved = append(moved, RefUpdate{Ref: name, Old: before[name], New: hash})
+		}
+	}
+	for name, hash := range before {
+		if _, exists := after[name]; !exists {
+			moved = append(moved, RefUpdate{Ref: name, Old: hash})
+		}
+	}
+	sort.Slice(moved, func(i, j int) bool {
+		return moved[i].Ref < moved[j].Ref
+	})
+	return moved
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// checkOwnership refuses to sync repositories owned by another user, mirroring
+// git's safe.directory protection: a repository owned by someone else can run
+// hooks and config under our identity. Directories listed in safe.directory
+// (global or system config) and repos with allow_foreign_owner are trusted.
+func (g *GitOperations) checkOwnership(ctx context.Context, repo configPkg.RepoConfig) error {
+	if repo.AllowForeignOwner {
+		return nil
+	}
+
+	paths := []string{repo.Path}
+	if _, err := os.Stat(filepath.Join(repo.Path, ".git")); err == nil {
+		paths = append(paths, filepath.Join(repo.Path, ".git"))
+	}
+
+	for _, path := range paths {
+		owner, ok, err := pathOwner(path)
+		if err != nil {
+			return fmt.Errorf("failed to check repository ownership: %w", err)
+		}
+		if !ok || ownedByCurrentUser(owner) {
+			continue
+		}
+
+		if isSafeDirectory(ctx, repo.Path) {
+			g.logger.Debug("Repository owned by another user is listed in safe.directory",
+				"repo", repo.Path,
+				"owner_uid", owner)
+			return nil
+		}
+
+		return newSkipError(SkipUnsafeOwnership,
+			"%s is owned by uid %d, not the current user (uid %d); add it to git's safe.directory or set allow_foreign_owner",
+			path, owner, os.Geteuid())
+	}
+
+	return nil
+}
+
+// ownedByCurrentUser accepts our own uid, and like git, the invoking user's
+// uid when running as root through sudo
+func ownedByCurrentUser(owner uint32) bool {
+	euid := os.Geteuid()
+	if int(owner) == euid {
+		return true
+	}
+	if euid == 0 {
+		if sudoUID, err := strconv.Atoi(os.Getenv("SUDO_UID")); err == nil && uint32(sudoUID) == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafeDirectory reports whether git's global or system safe.directory list covers path.
+// An empty entry resets the list and "*" trusts everything, as in git.
+func isSafeDirectory(ctx context.Context, path string) bool {
+	// Repository-local config is ignored for safe.directory, so read it from outside the repo
+	output, err := runGit(ctx, os.TempDir(), "config", "--get-all", "safe.directory")
+	if err != nil {
+		return false
+	}
+
+	cleaned := filepath.Clean(path)
+	safe := false
+	for _, entry := range strings.Split(output, "\n") {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+			safe = false
+		case entry == "*":
+			safe = true
+		case strings.HasSuffix(entry, "/*"):
+			if strings.HasPrefix(cleaned+"/", strings.TrimSuffix(entry, "*")) {
+				safe = true
+			}
+		case filepath.Clean(entry) == cleaned:
+			safe = true
+		}
+	}
+	return safe
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// pathOwner returns the owning uid of path; ok is false where ownership is unavailable
+func pathOwner(path string) (uint32, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false, nil
+	}
+	return stat.Uid, true, nil
+}
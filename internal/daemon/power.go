@@ -0,0 +1,160 @@
+package daemon
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// powerTTL is how long the power source read from sysfs is reused
+	powerTTL = 30 * time.Second
+
+	// powerPollInterval is how often the daemon looks for the charger being plugged back in
+	powerPollInterval = time.Minute
+)
+
+// powerSupplyDir lists the machine's batteries and chargers
+var powerSupplyDir = "/sys/class/power_supply"
+
+// power follows whether a laptop runs on battery, to pause syncs or space them
+// out meanwhile. Machines without a battery always count as on AC.
+type power struct {
+	logger *slog.Logger
+
+	mu             sync.Mutex
+	pauseOnBattery bool
+	multiplier     float64 // intervals are stretched by this much on battery
+	onBattery      bool
+	checkedAt      time.Time
+}
+
+func newPower(logger *slog.Logger) *power {
+	return &power{logger: logger, multiplier: 1}
+}
+
+// configure sets whether syncs pause on battery and how much intervals stretch
+func (p *power) configure(pauseOnBattery bool, multiplier float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pauseOnBattery = pauseOnBattery
+	p.multiplier = multiplier
+}
+
+// enabled reports whether running on battery changes anything; the caller holds mu
+func (p *power) enabled() bool {
+	return p.pauseOnBattery || p.multiplier > 1
+}
+
+// battery reports whether the machine runs on battery; the caller holds mu
+func (p *power) battery() bool {
+	if time.Since(p.checkedAt) >= powerTTL {
+		p.refresh()
+	}
+	return p.onBattery
+}
+
+// refresh reads the power source again and reports whether AC just came back;
+// the caller holds mu
+func (p *power) refresh() bool {
+	wasOnBattery := p.onBattery
+	p.onBattery = onBatteryPower()
+	p.checkedAt = time.Now()
+
+	if p.onBattery && !wasOnBattery {
+		p.logger.Info("Running on battery", "pause_syncs", p.pauseOnBattery, "interval_multiplier", p.multiplier)
+	} else if !p.onBattery && wasOnBattery {
+		p.logger.Info("Running on AC power again")
+	}
+	return wasOnBattery && !p.onBattery
+}
+
+// check skips syncs while on battery, if pause_on_battery is set
+func (p *power) check() error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pauseOnBattery && p.battery() {
+		return newSkipError(SkipOnBattery, "running on battery, syncing once plugged in")
+	}
+	return nil
+}
+
+// scale stretches a sync interval by the battery multiplier while on battery
+func (p *power) scale(interval time.Duration) time.Duration {
+	if p == nil {
+		return interval
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.multiplier <= 1 || !p.battery() {
+		return interval
+	}
+	return time.Duration(float64(interval) * p.multiplier)
+}
+
+// watch follows the power source and calls onAC when the charger is plugged
+// back in while syncs were paused, until ctx is done
+func (p *power) watch(ctx context.Context, onAC func()) {
+	ticker := time.NewTicker(powerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.mu.Lock()
+			catchUp := false
+			if p.enabled() {
+				catchUp = p.refresh() && p.pauseOnBattery
+			}
+			p.mu.Unlock()
+
+			if catchUp {
+				onAC()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// onBatteryPower reports whether a battery is discharging with no charger online
+func onBatteryPower() bool {
+	entries, err := os.ReadDir(powerSupplyDir)
+	if err != nil {
+		return false
+	}
+
+	read := func(supply, attribute string) string {
+		data, err := os.ReadFile(filepath.Join(powerSupplyDir, supply, attribute))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	}
+
+	discharging := false
+	for _, entry := range entries {
+		supply := entry.Name()
+		switch read(supply, "type") {
+		case "Mains", "USB":
+			if read(supply, "online") == "1" {
+				return false
+			}
+		case "Battery":
+			// Peripherals like mice report batteries too; only system batteries power the machine
+			if read(supply, "scope") != "Device" && read(supply, "status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging
+}
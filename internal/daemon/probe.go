@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// ProbeRemote lists the references of the repository's remote the way its git
+// backend connects for syncs, so an error shows the remote is unreachable or
+// rejects the credentials a sync would use. repo should carry the global
+// defaults.
+func ProbeRemote(ctx context.Context, repo configPkg.RepoConfig) error {
+	if repo.GitBackend == BackendGit {
+		credentials, err := credentialEnv(repo)
+		if err != nil {
+			return err
+		}
+		cmd := exec.CommandContext(ctx, "git", append(gitConfigArgs(repo), "ls-remote", "--heads", repo.Remote)...)
+		cmd.Dir = repo.Path
+		cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), credentials...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("%w: %s", err, msg)
+			}
+			return err
+		}
+		return nil
+	}
+
+	r, err := openRepository(repo.Path)
+	if err != nil {
+		return err
+	}
+	remote, err := r.Remote(repo.Remote)
+	if err != nil {
+		return fmt.Errorf("failed to get remote '%s': %w", repo.Remote, err)
+	}
+	proxy, caBundle, auth, err := transportOptions(repo)
+	if err != nil {
+		return err
+	}
+	_, err = remote.ListContext(ctx, &git.ListOptions{Auth: auth, ProxyOptions: proxy, CABundle: caBundle})
+	if err != nil && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return fmt.Errorf("failed to list remote references: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package daemon
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// progressLogInterval limits how often transfer progress is logged per repository
+const progressLogInterval = 5 * time.Second
+
+// progressLine matches remote progress such as "Counting objects:  45% (450/1000)"
+var progressLine = regexp.MustCompile(`^([A-Za-z][A-Za-z ]*):\s+(\d+)%`)
+
+// TransferProgress is the transfer state of a sync, reported by the remote
+type TransferProgress struct {
+	Repo         string      `json:"repo"`
+	Phase        string      `json:"phase,omitempty"`
+	Percent      int         `json:"percent"`
+	Message      string      `json:"message,omitempty"`
+	BytesFetched int64       `json:"bytes_fetched"`
+	RefsUpdated  bool        `json:"refs_updated"` // the sync created, moved or deleted a ref
+	RefsMoved    []RefUpdate `json:"refs_moved,omitempty"`
+	StartedAt    time.Time   `json:"started_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// progressTracker records the progress of running syncs and the result of the last one per repository
+type progressTracker struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+	active map[string]*progressWriter
+	last   map[string]TransferProgress
+}
+
+func newProgressTracker(logger *slog.Logger) *progressTracker {
+	return &progressTracker{
+		logger: logger,
+		active: make(map[string]*progressWriter),
+		last:   make(map[string]TransferProgress),
+	}
+}
+
+// begin starts tracking a sync of repoPath
+func (t *progressTracker) begin(repoPath string) *progressWriter {
+	now := time.Now()
+	w := &progressWriter{
+		tracker: t,
+		state:   TransferProgress{Repo: repoPath, StartedAt: now, UpdatedAt: now},
+	}
+
+	t.mu.Lock()
+	t.active[repoPath] = w
+	t.mu.Unlock()
+	return w
+}
+
+// end stops tracking repoPath, keeping its final state
+func (t *progressTracker) end(repoPath string, bytesFetched int64, moved []RefUpdate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, exists := t.active[repoPath]
+	if !exists {
+		return
+	}
+	delete(t.active, repoPath)
+
+	w.state.BytesFetched = bytesFetched
+	w.state.RefsUpdated = len(moved) > 0
+	w.state.RefsMoved = moved
+	w.state.UpdatedAt = time.Now()
+	t.last[repoPath] = w.state
+}
+
+// writer returns the progress writer for go-git options; nil when not tracking
+func (t *progressTracker) writer(repoPath string) io.Writer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if w, exists := t.active[repoPath]; exists {
+		return w
+	}
+	return nil
+}
+
+// snapshot returns the state of all running syncs sorted by repository
+func (t *progressTracker) snapshot() []TransferProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	transfers := make([]TransferProgress, 0, len(t.active))
+	for _, w := range t.active {
+		transfers = append(transfers, w.state)
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].Repo < transfers[j].Repo
+	})
+	return transfers
+}
+
+// lastResult returns the final state of the most recent finished sync of repoPath
+func (t *progressTracker) lastResult(repoPath string) (TransferProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result, exists := t.last[repoPath]
+	return result, exists
+}
+
+// progressWriter parses the sideband progress stream of one sync. Lines are
+// terminated by \r (in-place updates) or \n.
+type progressWriter struct {
+	tracker *progressTracker
+	buf     []byte
+	state   TransferProgress
+	lastLog time.Time
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.tracker.mu.Lock()
+	defer w.tracker.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		// The git binary prefixes remote-side progress with "remote: "
+		line := strings.TrimPrefix(strings.TrimSpace(string(w.buf[:i])), "remote: ")
+		w.buf = w.buf[i+1:]
+		if line != "" {
+			w.update(line)
+		}
+	}
+	return len(p), nil
+}
+
+// update records a progress line; callers hold the tracker lock
+func (w *progressWriter) update(line string) {
+	w.state.Message = line
+	w.state.UpdatedAt = time.Now()
+
+	if match := progressLine.FindStringSubmatch(line); match != nil {
+		w.state.Phase = match[1]
+		w.state.Percent, _ = strconv.Atoi(match[2])
+	}
+
+	if time.Since(w.lastLog) >= progressLogInterval || strings.HasSuffix(line, "done.") {
+		w.lastLog = time.Now()
+		w.tracker.logger.Debug("Transfer progress",
+			"repo", w.state.Repo,
+			"progress", line)
+	}
+}
+
+// packSize returns the total size of the repository's packfiles. go-git stores
+// fetched objects as packs, so the growth across a sync is the data received.
+func packSize(r *git.Repository) int64 {
+	storage, ok := r.Storer.(*filesystem.Storage)
+	if !ok {
+		return 0
+	}
+
+	gitDir := storage.Filesystem().Root()
+
+	// Linked worktrees keep their objects in the main repository's common directory
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		gitDir = commonDir
+	}
+
+	return packDirSize(filepath.Join(gitDir, "objects", "pack"))
+}
+
+// packDirSize returns the total size of the packfiles in packDir
+func packDirSize(packDir string) int64 {
+	entries, err := os.ReadDir(packDir)
+	if err != nil {
+		return 0
+	}
+
+	var total int64
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".pack") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+// queryNone answers queries with nothing to report: paths outside configured
+// repositories, unscheduled repositories and repositories that never synced
+const queryNone = "-"
+
+// queryStatus answers STATUS <path> with the sync state of the repository
+// containing path: syncing, paused, circuit-open, failed, ok, pending or disabled
+func (d *Daemon) queryStatus(path string) (string, error) {
+	repo, found, err := d.queryRepo(path)
+	if err != nil || !found {
+		return queryNone, err
+	}
+	if !repo.Enabled {
+		return "disabled", nil
+	}
+	if d.syncManager.syncing(repo.Path) {
+		return "syncing", nil
+	}
+
+	st, err := d.stateCache.Load()
+	if err != nil {
+		return "", fmt.Errorf("failed to load state: %w", err)
+	}
+	if _, paused := st.PausedSince(repo.Path); paused {
+		return "paused", nil
+	}
+	if _, open := st.Paused(repo.Path, time.Now()); open {
+		return "circuit-open", nil
+	}
+
+	_, status, lastSuccess := d.metrics.Last(repo.Path)
+	switch {
+	case status == "failed":
+		return "failed", nil
+	case !lastSuccess.IsZero():
+		return "ok", nil
+	}
+	return "pending", nil
+}
+
+// queryNext answers NEXT <path> with the Unix time the repository containing
+// path is next expected to sync
+func (d *Daemon) queryNext(path string) (string, error) {
+	repo, found, err := d.queryRepo(path)
+	if err != nil || !found {
+		return queryNone, err
+	}
+	next := d.scheduler.NextSync(repo.Path)
+	if next.IsZero() {
+		return queryNone, nil
+	}
+	return strconv.FormatInt(next.Unix(), 10), nil
+}
+
+// queryLast answers LAST <path> with the status and Unix time of the latest
+// sync of the repository containing path since the daemon started, such as
+// "success 1760450000"
+func (d *Daemon) queryLast(path string) (string, error) {
+	repo, found, err := d.queryRepo(path)
+	if err != nil || !found {
+		return queryNone, err
+	}
+	lastSync, status, _ := d.metrics.Last(repo.Path)
+	if lastSync.IsZero() {
+		return queryNone, nil
+	}
+	return fmt.Sprintf("%s %d", status, lastSync.Unix()), nil
+}
+
+// queryRepo returns the configured repository containing path, the innermost
+// one when repositories are nested, so prompts can ask from any subdirectory
+func (d *Daemon) queryRepo(path string) (config.RepoConfig, bool, error) {
+	if path == "" {
+		return config.RepoConfig{}, false, errors.New("a path is required")
+	}
+	if !filepath.IsAbs(path) {
+		return config.RepoConfig{}, false, fmt.Errorf("path must be absolute: %s", path)
+	}
+	path = paths.NormalizeRepo(path)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	best, bestLen := -1, 0
+	for i, repo := range d.config.Repositories {
+		repoPath := paths.NormalizeRepo(repo.Path)
+		if path != repoPath && !strings.HasPrefix(path, repoPath+string(filepath.Separator)) {
+			continue
+		}
+		if len(repoPath) > bestLen {
+			best, bestLen = i, len(repoPath)
+		}
+	}
+	if best < 0 {
+		// Symlinked or differently cased paths to the repository itself
+		var found bool
+		if best, found = d.config.FindRepository(path); !found {
+			return config.RepoConfig{}, false, nil
+		}
+	}
+	return d.config.Repositories[best], true, nil
+}
@@ -0,0 +1,385 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+const (
+	// rateLimitReserve is the percentage of a quota left to the user's own git and API use
+	rateLimitReserve = 10
+
+	// defaultRetryAfter is how long to back off when a host refuses requests without saying for how long
+	defaultRetryAfter = time.Minute
+)
+
+// RateLimit is the quota a git host reported in its latest HTTP response
+type RateLimit struct {
+	Host       string    `json:"host"`
+	Resource   string    `json:"resource,omitempty"`
+	Limit      int       `json:"limit"`
+	Remaining  int       `json:"remaining"`
+	Reset      time.Time `json:"reset,omitempty"`
+	RetryAfter time.Time `json:"retry_after,omitempty"` // set when the host refused a request as rate limited
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// BackoffUntil returns when syncs with the host may resume, or the zero time when they are not held back
+func (l RateLimit) BackoffUntil(now time.Time) time.Time {
+	if l.RetryAfter.After(now) {
+		return l.RetryAfter
+	}
+	if l.Limit > 0 && l.Remaining*100 <= l.Limit*rateLimitReserve && l.Reset.After(now) {
+		return l.Reset
+	}
+	return time.Time{}
+}
+
+// rateLimitTracker records the rate limits reported by each host
+type rateLimitTracker struct {
+	mu    sync.Mutex
+	hosts map[string]RateLimit
+}
+
+// rateLimits is shared by all syncs: quotas belong to the host and token, not to a configuration
+var rateLimits = &rateLimitTracker{hosts: make(map[string]RateLimit)}
+
+// observe records the rate-limit headers of a response from host; responses
+// without a limit and what remains of it are ignored. Both GitHub's
+// X-RateLimit-* and the RateLimit-* headers GitLab sends are understood.
+func (t *rateLimitTracker) observe(host string, status int, header func(string) string) {
+	first := func(names ...string) string {
+		for _, name := range names {
+			if value := header(name); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	now := time.Now()
+	limit := RateLimit{Host: strings.ToLower(host), UpdatedAt: now}
+	// A limit without what remains of it tells nothing about the quota
+	quota, limitErr := strconv.Atoi(first("X-RateLimit-Limit", "RateLimit-Limit"))
+	remaining, remainingErr := strconv.Atoi(first("X-RateLimit-Remaining", "RateLimit-Remaining"))
+	known := limitErr == nil && remainingErr == nil
+	if known {
+		limit.Limit, limit.Remaining = quota, remaining
+	}
+	if value, err := strconv.ParseInt(first("X-RateLimit-Reset", "RateLimit-Reset"), 10, 64); err == nil {
+		limit.Reset = time.Unix(value, 0)
+	}
+	limit.Resource = header("X-RateLimit-Resource")
+
+	limited := status == http.StatusTooManyRequests || (status == http.StatusForbidden && known && remaining == 0)
+	if limited {
+		limit.RetryAfter = parseRetryAfter(header("Retry-After"), now)
+		if limit.RetryAfter.IsZero() {
+			limit.RetryAfter = limit.Reset
+		}
+		if !limit.RetryAfter.After(now) {
+			limit.RetryAfter = now.Add(defaultRetryAfter)
+		}
+	}
+	if !known && !limited {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if previous, exists := t.hosts[limit.Host]; exists && !known {
+		// A refusal without quota headers keeps the last known quota
+		previous.RetryAfter = limit.RetryAfter
+		previous.UpdatedAt = now
+		limit = previous
+	}
+	t.hosts[limit.Host] = limit
+}
+
+// get returns the last rate limit reported by host
+func (t *rateLimitTracker) get(host string) (RateLimit, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	limit, exists := t.hosts[strings.ToLower(host)]
+	return limit, exists
+}
+
+// backingOff reports whether syncs with any host are currently held back
+func (t *rateLimitTracker) backingOff() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for _, limit := range t.hosts {
+		if !limit.BackoffUntil(now).IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot returns the known rate limits sorted by host
+func (t *rateLimitTracker) snapshot() []RateLimit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	limits := make([]RateLimit, 0, len(t.hosts))
+	for _, limit := range t.hosts {
+		limits = append(limits, limit)
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Host < limits[j].Host })
+	return limits
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date
+	}
+	return time.Time{}
+}
+
+// checkRateLimit skips syncs with a host that is nearly out of quota or refusing
+// requests until its limit resets. The remote is only looked up while some host is
+// being backed off from, so syncs cost nothing extra the rest of the time.
+func checkRateLimit(ctx context.Context, repo configPkg.RepoConfig) error {
+	if !rateLimits.backingOff() {
+		return nil
+	}
+
+	remoteURL, err := runGit(ctx, repo.Path, append(gitConfigArgs(repo), "remote", "get-url", repo.Remote)...)
+	if err != nil {
+		return nil
+	}
+	host := httpHost(remoteURL)
+	if host == "" {
+		return nil
+	}
+
+	limit, exists := rateLimits.get(host)
+	if !exists {
+		return nil
+	}
+	until := limit.BackoffUntil(time.Now())
+	if until.IsZero() {
+		return nil
+	}
+	if limit.RetryAfter.Equal(until) {
+		return newSkipError(SkipRateLimited, "%s is rate limiting requests, skipping sync until %s",
+			host, until.Local().Format("15:04"))
+	}
+	return newSkipError(SkipRateLimited, "rate limit nearly exhausted on %s (%d of %d left), skipping sync until %s",
+		host, limit.Remaining, limit.Limit, until.Local().Format("15:04"))
+}
+
+// httpHost returns the host of an http or https remote URL, empty for other transports
+func httpHost(remoteURL string) string {
+	parsed, err := url.Parse(remoteURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return ""
+	}
+	return parsed.Hostname()
+}
+
+// rateLimitTransport records the rate-limit headers of every HTTP response
+type rateLimitTransport struct {
+	base http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		rateLimits.observe(req.URL.Hostname(), resp.StatusCode, resp.Header.Get)
+	}
+	return resp, err
+}
+
+var installRateLimitClient sync.Once
+
+// rateLimitAuth marks the credentials of the daemon's own syncs, the only
+// sessions whose HTTP responses are tracked
+type rateLimitAuth struct {
+	auth transport.AuthMethod // nil leaves authentication to go-git
+}
+
+func (a *rateLimitAuth) Name() string {
+	if a.auth == nil {
+		return "none"
+	}
+	return a.auth.Name()
+}
+
+func (a *rateLimitAuth) String() string {
+	if a.auth == nil {
+		return a.Name()
+	}
+	return a.auth.String()
+}
+
+// trackRateLimits marks auth so the session it opens records rate limits
+func trackRateLimits(auth transport.AuthMethod) transport.AuthMethod {
+	installRateLimitTracking()
+	return &rateLimitAuth{auth: auth}
+}
+
+// rateLimitClient stands in for one of go-git's transports. Sessions opened
+// with a rateLimitAuth get their credentials back and, over HTTP, a client
+// tracking rate limits; all others go to the transport unchanged. go-git
+// applies proxies and CA bundles only to its own *http.Transport, never to a
+// wrapped one, so they are configured on the wrapped transport here instead.
+type rateLimitClient struct {
+	base    transport.Transport // the transport installed before
+	http    bool
+	mu      sync.Mutex
+	clients map[string]transport.Transport
+}
+
+// installRateLimitTracking puts a rateLimitClient in front of each of go-git's transports
+func installRateLimitTracking() {
+	installRateLimitClient.Do(func() {
+		for scheme, base := range client.Protocols {
+			client.InstallProtocol(scheme, &rateLimitClient{
+				base:    base,
+				http:    scheme == "http" || scheme == "https",
+				clients: make(map[string]transport.Transport),
+			})
+		}
+	})
+}
+
+func (c *rateLimitClient) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	cl, ep, auth, err := c.client(ep, auth)
+	if err != nil {
+		return nil, err
+	}
+	return cl.NewUploadPackSession(ep, auth)
+}
+
+func (c *rateLimitClient) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	cl, ep, auth, err := c.client(ep, auth)
+	if err != nil {
+		return nil, err
+	}
+	return cl.NewReceivePackSession(ep, auth)
+}
+
+// client returns the transport for a session of ep with auth, along with the
+// endpoint and credentials to open it with
+func (c *rateLimitClient) client(ep *transport.Endpoint, auth transport.AuthMethod) (transport.Transport, *transport.Endpoint, transport.AuthMethod, error) {
+	tracked, ok := auth.(*rateLimitAuth)
+	if !ok {
+		return c.base, ep, auth, nil
+	}
+	auth = tracked.auth
+	// Client certificates and disabled TLS verification are never configured by
+	// git-sync; leave such endpoints to go-git untracked
+	if !c.http || len(ep.ClientCert) > 0 || len(ep.ClientKey) > 0 || ep.InsecureSkipTLS {
+		return c.base, ep, auth, nil
+	}
+
+	key := ep.Proxy.URL + "\x00" + string(ep.CaBundle)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cl, exists := c.clients[key]
+	if !exists {
+		base := http.DefaultTransport.(*http.Transport).Clone()
+		if ep.Proxy.URL != "" {
+			proxyURL, err := ep.Proxy.FullURL()
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("invalid proxy: %w", err)
+			}
+			base.Proxy = http.ProxyURL(proxyURL)
+		}
+		if len(ep.CaBundle) > 0 {
+			roots, err := x509.SystemCertPool()
+			if err != nil {
+				roots = x509.NewCertPool()
+			}
+			roots.AppendCertsFromPEM(ep.CaBundle)
+			base.TLSClientConfig = &tls.Config{RootCAs: roots}
+		}
+		cl = githttp.NewClient(&http.Client{Transport: &rateLimitTransport{base: base}})
+		c.clients[key] = cl
+	}
+
+	plain := *ep
+	plain.Proxy = transport.ProxyOptions{}
+	plain.CaBundle = nil
+	return cl, &plain, auth, nil
+}
+
+// curlTraceEnv makes git trace its HTTP headers to path; git redacts credentials in traces
+func curlTraceEnv(path string) []string {
+	return []string{"GIT_TRACE_CURL=" + path, "GIT_TRACE_CURL_NO_DATA=1", "GIT_TRACE_REDACT=1"}
+}
+
+// observeCurlTrace records the rate-limit headers of the responses in a GIT_TRACE_CURL file
+func observeCurlTrace(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	var host string
+	var status int
+	var headers http.Header
+	flush := func() {
+		if headers != nil && host != "" {
+			rateLimits.observe(host, status, headers.Get)
+		}
+		headers = nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if _, sent, ok := strings.Cut(line, "=> Send header: "); ok {
+			if name, value, ok := strings.Cut(sent, ":"); ok && strings.EqualFold(name, "Host") {
+				host, _, _ = strings.Cut(strings.TrimSpace(value), ":")
+			}
+			continue
+		}
+		_, received, ok := strings.Cut(line, "<= Recv header: ")
+		if !ok {
+			continue
+		}
+		// A status line starts the headers of the next response
+		if strings.HasPrefix(received, "HTTP/") {
+			flush()
+			status = 0
+			if fields := strings.Fields(received); len(fields) > 1 {
+				status, _ = strconv.Atoi(fields[1])
+			}
+			headers = make(http.Header)
+			continue
+		}
+		if name, value, ok := strings.Cut(received, ":"); ok && headers != nil {
+			headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	flush()
+}
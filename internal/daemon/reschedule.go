@@ -0,0 +1,130 @@
+package daemon
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/bnema/git-sync/internal/config"
+)
+
+// scheduledJob is the running schedule of a standalone repository or a sync set
+type scheduledJob struct {
+	repo config.RepoConfig
+	set  SyncSet
+
+	cancel context.CancelFunc
+	done   chan struct{} // closed once the schedule stopped and its last sync finished
+}
+
+// RescheduleSummary counts what a reschedule changed
+type RescheduleSummary struct {
+	Added       int `json:"added"`
+	Removed     int `json:"removed"`
+	Rescheduled int `json:"rescheduled"`
+	Unchanged   int `json:"unchanged"`
+}
+
+// addJob registers the schedule of key and returns the context it runs under;
+// the caller holds s.mutex
+func (s *Scheduler) addJob(ctx context.Context, key string, job scheduledJob) (context.Context, *scheduledJob) {
+	ctx, job.cancel = context.WithCancel(ctx)
+	job.done = make(chan struct{})
+	s.jobs[key] = &job
+	return ctx, &job
+}
+
+// Schedules returns how many repositories and sync sets are scheduled
+func (s *Scheduler) Schedules() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.jobs)
+}
+
+// sameSchedule reports whether job already runs as want would
+func (job *scheduledJob) sameSchedule(want scheduledJob) bool {
+	return reflect.DeepEqual(job.repo, want.repo) && reflect.DeepEqual(job.set, want.set)
+}
+
+// Reschedule brings the running schedules in line with repos and sets. Schedules
+// that are unchanged keep running with their tickers and pending retries; removed
+// and changed ones are stopped, letting a sync in progress finish first, and the
+// new and changed ones start as they would at startup. A non-nil configure
+// restarts every schedule and is called while none runs, to change the
+// scheduler's settings.
+func (s *Scheduler) Reschedule(ctx context.Context, repos []config.RepoConfig, sets []SyncSet, sm *SyncManager, configure func()) RescheduleSummary {
+	want := make(map[string]scheduledJob, len(repos)+len(sets))
+	for _, repo := range repos {
+		if repo.Enabled {
+			want[repo.Path] = scheduledJob{repo: repo}
+		}
+	}
+	for _, set := range sets {
+		want[setTriggerKey(set.Name)] = scheduledJob{set: set}
+	}
+
+	var summary RescheduleSummary
+	var stopping []*scheduledJob
+	s.mutex.Lock()
+	s.ctx = ctx
+	for key, job := range s.jobs {
+		next, kept := want[key]
+		switch {
+		case !kept:
+			summary.Removed++
+		case configure == nil && job.sameSchedule(next):
+			summary.Unchanged++
+			delete(want, key)
+			continue
+		default:
+			summary.Rescheduled++
+		}
+		job.cancel()
+		stopping = append(stopping, job)
+	}
+	summary.Added = len(want) - summary.Rescheduled
+	s.mutex.Unlock()
+
+	// The next schedule of a repository must not start while its last sync still runs
+	for _, job := range stopping {
+		s.waitForJob(job, sm)
+	}
+	if configure != nil {
+		configure()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var i int
+	for _, repo := range repos {
+		if _, start := want[repo.Path]; start {
+			s.scheduleRepo(ctx, repo, sm, s.startOffset(i, len(want)))
+			i++
+		}
+	}
+	for _, set := range sets {
+		if _, start := want[setTriggerKey(set.Name)]; start {
+			s.scheduleSyncSet(ctx, set, sm, s.startOffset(i, len(want)))
+			i++
+		}
+	}
+	return summary
+}
+
+// waitForJob waits until a stopped schedule has exited
+func (s *Scheduler) waitForJob(job *scheduledJob, sm *SyncManager) {
+	name, members := job.repo.Path, []config.RepoConfig{job.repo}
+	if job.set.Name != "" {
+		name, members = job.set.Name, job.set.Members
+	}
+	for _, member := range members {
+		if sm.syncing(member.Path) {
+			s.logger.Info("Waiting for the sync in progress before rescheduling", "schedule", name, "repo", member.Path)
+			break
+		}
+	}
+
+	start := time.Now()
+	<-job.done
+	s.logger.Debug("Stopped schedule", "schedule", name, "waited", time.Since(start))
+}
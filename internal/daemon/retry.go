@@ -0,0 +1,150 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryState is a repository waiting to retry a sync that failed transiently
+type RetryState struct {
+	Repo        string    `json:"repo"`
+	Attempt     int       `json:"attempt"` // the retry that comes next, starting at 1
+	MaxAttempts int       `json:"max_attempts"`
+	NextRetry   time.Time `json:"next_retry"`
+	LastError   string    `json:"last_error"`
+}
+
+// transientErrors are fragments of git and go-git errors that usually resolve on
+// their own: network and DNS trouble, timeouts, overloaded servers and locked
+// SSH agents or keyrings
+var transientErrors = []string{
+	"could not resolve host",
+	"temporary failure in name resolution",
+	"no such host",
+	"connection refused",
+	"couldn't connect to server",
+	"connection reset",
+	"network is unreachable",
+	"no route to host",
+	"i/o timeout",
+	"tls handshake timeout",
+	"timed out",
+	"broken pipe",
+	"unexpected eof",
+	"early eof",
+	"the remote end hung up unexpectedly",
+	"rpc failed",
+	"kex_exchange_identification",
+	"internal server error",
+	"bad gateway",
+	"service unavailable",
+	"gateway timeout",
+	"agent refused operation",
+	"signing failed",
+}
+
+// IsTransient reports whether err is likely to go away by itself, so retrying the
+// sync soon can succeed. Skips and everything else, like rejected credentials,
+// missing repositories or conflicts, need the user and are not retried.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if _, skipped := AsSkipError(err); skipped {
+		return false
+	}
+
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ENETUNREACH),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.As(err, &dnsErr),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range transientErrors {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// planRetry records the outcome of a sync of repoPath and returns the retry it
+// calls for, if any: transient failures retry up to maxAttempts times with
+// exponentially growing, jittered delays, and any other outcome ends the retries
+func (s *Scheduler) planRetry(repoPath string, maxAttempts int, err error) (RetryState, bool) {
+	s.retriesMu.Lock()
+	defer s.retriesMu.Unlock()
+
+	previous := s.retries[repoPath]
+	if !IsTransient(err) || previous.Attempt >= maxAttempts {
+		delete(s.retries, repoPath)
+		return RetryState{}, false
+	}
+
+	retry := RetryState{
+		Repo:        repoPath,
+		Attempt:     previous.Attempt + 1,
+		MaxAttempts: maxAttempts,
+		NextRetry:   time.Now().Add(s.retryBackoff(previous.Attempt)),
+		LastError:   err.Error(),
+	}
+	s.retries[repoPath] = retry
+	return retry, true
+}
+
+// pendingRetry returns the retry repoPath is waiting for
+func (s *Scheduler) pendingRetry(repoPath string) (RetryState, bool) {
+	s.retriesMu.Lock()
+	defer s.retriesMu.Unlock()
+	retry, pending := s.retries[repoPath]
+	return retry, pending
+}
+
+// Retries returns the repositories waiting to retry a failed sync
+func (s *Scheduler) Retries() []RetryState {
+	s.retriesMu.Lock()
+	defer s.retriesMu.Unlock()
+	retries := make([]RetryState, 0, len(s.retries))
+	for _, retry := range s.retries {
+		retries = append(retries, retry)
+	}
+	return retries
+}
+
+// retryBackoff returns the delay before retry number attempt+1: the initial
+// backoff doubled per attempt up to the maximum, with the upper half jittered
+// so repositories failing together don't retry together
+func (s *Scheduler) retryBackoff(attempt int) time.Duration {
+	backoff := s.retryInitial
+	for i := 0; i < attempt && backoff < s.retryMax; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, s.retryMax)
+	if backoff <= 1 {
+		return backoff
+	}
+	return backoff/2 + rand.N(backoff/2)
+}
+
+// retryTimer starts a timer for the pending retry of repoPath; nil channels never fire
+func (s *Scheduler) retryTimer(repoPath string) (*time.Timer, <-chan time.Time) {
+	retry, pending := s.pendingRetry(repoPath)
+	if !pending {
+		return nil, nil
+	}
+	timer := time.NewTimer(time.Until(retry.NextRetry))
+	return timer, timer.C
+}
@@ -0,0 +1,133 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bnema/git-sync/internal/config"
+)
+
+// RunResult is the outcome of one repository in a one-shot run
+type RunResult struct {
+	Repo       string `json:"repo"`
+	Name       string `json:"name"`
+	Set        string `json:"set,omitempty"`
+	Status     string `json:"status"` // success, noop, partial, failed or skipped
+	SkipReason string `json:"skip_reason,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ResultOf reports a recorded sync as a RunResult
+func ResultOf(entry SyncHistoryEntry) RunResult {
+	return RunResult{
+		Repo:       entry.RepoPath,
+		Name:       filepath.Base(entry.RepoPath),
+		Status:     entry.Status,
+		SkipReason: entry.SkipReason,
+		Error:      entry.ErrorMsg,
+		DurationMs: entry.DurationMs,
+	}
+}
+
+// RunSummary reports a one-shot run of every enabled repository
+type RunSummary struct {
+	StartedAt  time.Time      `json:"started_at"`
+	DurationMs int64          `json:"duration_ms"`
+	Totals     map[string]int `json:"totals"` // repositories per status
+	Results    []RunResult    `json:"results"`
+}
+
+// Failed returns how many repositories failed to sync
+func (s RunSummary) Failed() int {
+	return s.Totals["failed"]
+}
+
+// RunOnce syncs every enabled repository once, the members of sync sets in
+// order, and reports the outcomes. Syncs are recorded in history (hm may be nil)
+// like the daemon's, but failures are neither retried nor notified and no
+// repository is paused; the caller acts on the summary instead.
+func RunOnce(ctx context.Context, cfg *config.Config, hm *HistoryManager, logger *slog.Logger) RunSummary {
+	start := time.Now()
+	cfg = cfg.ExpandPatterns()
+
+	var enabled []config.RepoConfig
+	for _, repo := range cfg.Repositories {
+		if repo.Enabled {
+			repo = cfg.Global.WithGlobalDefaults(repo)
+			noRetries := 0
+			repo.RetryMaxAttempts = &noRetries
+			enabled = append(enabled, repo)
+		}
+	}
+	sets, standalone := SyncSets(cfg, enabled)
+
+	s := NewScheduler(logger, hm, nil, nil)
+	s.ctx = ctx
+	s.SetCircuitBreaker(0, 0)
+	if err := s.SetWebhooks(cfg.Webhooks); err != nil {
+		logger.Error("Failed to set up webhooks", "error", err)
+	}
+	sm := NewSyncManager(cfg.Global.MaxConcurrentSyncs, logger)
+	sm.SetClassLimits(cfg.Global.MaxIOHeavySyncs, cfg.Global.MaxLightSyncs)
+	sm.SetHostLimits(cfg.Global.MaxSyncsPerHost, time.Duration(cfg.Global.HostSyncDelay)*time.Second)
+	configureFaults(sm, cfg, logger)
+
+	// Repositories and sync sets run side by side, bounded by the concurrency class budgets
+	results := make(map[string]RunResult, len(enabled))
+	var mu sync.Mutex
+	record := func(set string, entry SyncHistoryEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+		result := ResultOf(entry)
+		result.Set = set
+		results[entry.RepoPath] = result
+	}
+
+	var wg sync.WaitGroup
+	for _, repo := range standalone {
+		wg.Add(1)
+		go func(repo config.RepoConfig) {
+			defer wg.Done()
+			entry, _ := s.performSync(repo, sm)
+			record("", entry)
+		}(repo)
+	}
+	for _, set := range sets {
+		wg.Add(1)
+		go func(set SyncSet) {
+			defer wg.Done()
+			for i, member := range set.Members {
+				entry, err := s.performSync(member, sm)
+				record(set.Name, entry)
+				if err == nil {
+					continue
+				}
+				detail := fmt.Sprintf("sync set '%s' stopped at %s", set.Name, filepath.Base(member.Path))
+				for _, skipped := range set.Members[i+1:] {
+					record(set.Name, s.recordNotAttempted(skipped, detail))
+				}
+				return
+			}
+		}(set)
+	}
+	wg.Wait()
+	s.webhooks.Wait()
+
+	// Report in config order
+	summary := RunSummary{StartedAt: start, Totals: make(map[string]int)}
+	for _, repo := range enabled {
+		result, ran := results[repo.Path]
+		if !ran {
+			continue
+		}
+		summary.Results = append(summary.Results, result)
+		summary.Totals[result.Status]++
+	}
+	summary.DurationMs = time.Since(start).Milliseconds()
+	return summary
+}
@@ -2,11 +2,16 @@ package daemon
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"sync"
 	"time"
 
 	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/cron"
+	"github.com/bnema/git-sync/internal/fleet"
+	"github.com/bnema/git-sync/internal/metrics"
 	"github.com/bnema/git-sync/internal/notification"
 )
 
@@ -18,19 +23,131 @@ type Scheduler struct {
 	wg                  sync.WaitGroup
 	historyManager      *HistoryManager
 	notificationManager *notification.NotificationManager
+	metrics             *metrics.Registry
 	ctx                 context.Context
+
+	// Channels that make a repository, or a sync set keyed by setTriggerKey, sync right away
+	triggers map[string]chan struct{}
+
+	// Running schedules by the same keys, so a reload can stop them one by one
+	jobs map[string]*scheduledJob
+
+	// Sync set each member repository belongs to, for triggering a single member
+	memberSets map[string]string
+
+	// When each repository is next expected to sync
+	nextSync   map[string]time.Time
+	nextSyncMu sync.Mutex
+
+	// Findings last notified per repository for pushes blocked by the secret scan
+	secretAlerts   map[string]string
+	secretAlertsMu sync.Mutex
+
+	// Load spreading: first syncs are spread over stagger, intervals vary by jitter percent
+	stagger time.Duration
+	jitter  int
+
+	// Pending retries of transiently failed syncs, and the backoff between them
+	retries      map[string]RetryState
+	retriesMu    sync.Mutex
+	retryInitial time.Duration
+	retryMax     time.Duration
+
+	// Consecutive failures that pause a repository, and for how long; 0 failures disables
+	circuitFailures int
+	circuitCooldown time.Duration
+
+	// Hold syncs back while offline or on battery, and stretch intervals on battery; nil never do
+	connectivity *connectivity
+	power        *power
+
+	// Sends history entries to a fleet aggregator; nil never reports
+	reporter *fleet.Reporter
+
+	// Mirror step state per repository path
+	mirrors   map[string]*mirrorState
+	mirrorsMu sync.Mutex
+
+	// Global webhooks, and the deliveries still in flight
+	webhookTargets []webhookTarget
+	webhooksMu     sync.Mutex
+	webhooks       sync.WaitGroup
 }
 
-func NewScheduler(logger *slog.Logger, historyManager *HistoryManager, notificationManager *notification.NotificationManager) *Scheduler {
+// initialSyncDelay is how long after startup interval repositories first sync
+const initialSyncDelay = 10 * time.Second
+
+func NewScheduler(logger *slog.Logger, historyManager *HistoryManager, notificationManager *notification.NotificationManager, metricsRegistry *metrics.Registry) *Scheduler {
 	return &Scheduler{
 		timers:              make(map[string]*time.Timer),
 		tickers:             make(map[string]*time.Ticker),
 		logger:              logger,
 		historyManager:      historyManager,
 		notificationManager: notificationManager,
+		metrics:             metricsRegistry,
+		triggers:            make(map[string]chan struct{}),
+		jobs:                make(map[string]*scheduledJob),
+		memberSets:          make(map[string]string),
+		nextSync:            make(map[string]time.Time),
+		secretAlerts:        make(map[string]string),
+		retries:             make(map[string]RetryState),
+		mirrors:             make(map[string]*mirrorState),
+		retryInitial:        30 * time.Second,
+		retryMax:            10 * time.Minute,
+		circuitFailures:     5,
+		circuitCooldown:     time.Hour,
 	}
 }
 
+// SetLoadSpread spreads the first syncs evenly over stagger and varies each
+// interval by up to jitter percent, so repositories sharing an interval don't
+// all sync at once. Call it while nothing is scheduled.
+func (s *Scheduler) SetLoadSpread(stagger time.Duration, jitter int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stagger = stagger
+	s.jitter = jitter
+}
+
+// SetRetryBackoff sets the delay before the first retry of a failed sync and
+// the most it grows to. Call it while nothing is scheduled.
+func (s *Scheduler) SetRetryBackoff(initial, max time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.retryInitial = initial
+	s.retryMax = max
+}
+
+// SetCircuitBreaker pauses repositories after failures consecutive failed syncs
+// for cooldown; 0 failures disables it. Call it while nothing is scheduled.
+func (s *Scheduler) SetCircuitBreaker(failures int, cooldown time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.circuitFailures = failures
+	s.circuitCooldown = cooldown
+}
+
+// SetConnectivity holds syncs back while c reports the machine offline. Call it before Start.
+func (s *Scheduler) SetConnectivity(c *connectivity) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.connectivity = c
+}
+
+// SetPower pauses syncs or stretches intervals on battery as p is configured. Call it before Start.
+func (s *Scheduler) SetPower(p *power) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.power = p
+}
+
+// SetReporter reports every sync recorded in history to r. Call it before Start.
+func (s *Scheduler) SetReporter(r *fleet.Reporter) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reporter = r
+}
+
 func (s *Scheduler) Start(ctx context.Context, repos []config.RepoConfig, sm *SyncManager) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -38,20 +155,22 @@ func (s *Scheduler) Start(ctx context.Context, repos []config.RepoConfig, sm *Sy
 	s.ctx = ctx
 	s.logger.Info("Starting scheduler", "repositories", len(repos))
 
+	enabled := make([]config.RepoConfig, 0, len(repos))
 	for _, repo := range repos {
 		if !repo.Enabled {
-			s.logger.Debug("Skipping disabled repository", "path", repo.Path)
+			s.logger.Debug("Skipping disabled repository", "repo", repo.Path)
 			continue
 		}
+		enabled = append(enabled, repo)
+	}
 
-		s.scheduleRepo(ctx, repo, sm)
+	for i, repo := range enabled {
+		s.scheduleRepo(ctx, repo, sm, s.startOffset(i, len(enabled)))
 	}
 }
 
 func (s *Scheduler) Stop() {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
 	s.logger.Info("Stopping scheduler")
 
 	// Stop all timers
@@ -65,6 +184,8 @@ func (s *Scheduler) Stop() {
 		ticker.Stop()
 		delete(s.tickers, path)
 	}
+	// Exiting goroutines take the mutex to clean up after themselves
+	s.mutex.Unlock()
 
 	// Wait for all goroutines to finish with timeout
 	done := make(chan struct{})
@@ -87,88 +208,451 @@ func (s *Scheduler) Stop() {
 	s.logger.Info("Scheduler stopped")
 }
 
-func (s *Scheduler) scheduleRepo(ctx context.Context, repo config.RepoConfig, sm *SyncManager) {
+func (s *Scheduler) scheduleRepo(ctx context.Context, repo config.RepoConfig, sm *SyncManager, offset time.Duration) {
+	if repo.SplitsDirections() {
+		s.scheduleDirections(ctx, repo, sm, offset)
+		return
+	}
+
 	s.logger.Info("Scheduling repository", 
-		"path", repo.Path, 
-		"interval", repo.Interval)
+		"repo", repo.Path, 
+		"interval", repo.Interval,
+		"schedule", repo.Schedule,
+		"trigger", triggerOrDefault(repo.Trigger),
+		"start_offset", offset)
 
 	interval := time.Duration(repo.Interval) * time.Second
 
-	// Create ticker for regular syncing
-	ticker := time.NewTicker(interval)
-	s.tickers[repo.Path] = ticker
+	// Cron schedules replace the interval; validation already rejected bad expressions
+	var schedule *cron.Schedule
+	if repo.Schedule != "" {
+		parsed, err := cron.Parse(repo.Schedule)
+		if err != nil {
+			s.logger.Error("Invalid schedule, using interval", "repo", repo.Path, "error", err)
+		} else {
+			schedule = parsed
+		}
+	}
+
+	// Create a timer for the next scheduled sync, or a ticker for regular syncing
+	var ticks <-chan time.Time
+	var timer *time.Timer
+	var ticker *time.Ticker
+	if schedule != nil {
+		timer = time.NewTimer(s.untilNextSync(repo.Path, schedule))
+		s.timers[repo.Path] = timer
+		ticks = timer.C
+	} else {
+		ticker = time.NewTicker(interval)
+		s.tickers[repo.Path] = ticker
+		ticks = ticker.C
+	}
+
+	trigger := make(chan struct{}, 1)
+	s.triggers[repo.Path] = trigger
+	ctx, job := s.addJob(ctx, repo.Path, scheduledJob{repo: repo})
 
 	s.wg.Add(1)
 	go func(repoConfig config.RepoConfig) {
 		defer s.wg.Done()
+		defer close(job.done)
 		defer func() {
 			s.mutex.Lock()
+			delete(s.jobs, repoConfig.Path)
+			delete(s.triggers, repoConfig.Path)
 			if ticker, exists := s.tickers[repoConfig.Path]; exists {
 				ticker.Stop()
 				delete(s.tickers, repoConfig.Path)
 			}
+			if timer, exists := s.timers[repoConfig.Path]; exists {
+				timer.Stop()
+				delete(s.timers, repoConfig.Path)
+			}
 			s.mutex.Unlock()
+
+			s.nextSyncMu.Lock()
+			delete(s.nextSync, repoConfig.Path)
+			s.nextSyncMu.Unlock()
 		}()
 
-		// Perform initial sync after a short delay
-		initialDelay := time.NewTimer(10 * time.Second)
-		select {
-		case <-initialDelay.C:
+		// Worktree changes trigger syncs in fswatch mode; nil channels never fire
+		var watcher *fsWatcher
+		var changes <-chan struct{}
+		if repoConfig.WatchesFiles() {
+			w, err := newFSWatcher(ctx, repoConfig, s.logger)
+			if err != nil {
+				s.logger.Warn("Failed to watch repository, using interval syncs",
+					"repo", repoConfig.Path,
+					"error", err)
+			} else {
+				watcher = w
+				defer watcher.Close()
+				changes = watcher.Changes()
+			}
+		}
+		if watcher != nil && repoConfig.Trigger == "fswatch" {
+			ticks = nil
+		}
+
+		// Transient failures are retried with backoff on top of the regular syncs
+		var retryTimer *time.Timer
+		var retries <-chan time.Time
+		defer func() {
+			if retryTimer != nil {
+				retryTimer.Stop()
+			}
+		}()
+
+		runSync := func() {
+			if watcher != nil {
+				watcher.beginSync()
+			}
 			s.performSync(repoConfig, sm)
-		case <-ctx.Done():
-			initialDelay.Stop()
-			return
+			if watcher != nil {
+				watcher.settle()
+			}
+
+			if retryTimer != nil {
+				retryTimer.Stop()
+			}
+			retryTimer, retries = s.retryTimer(repoConfig.Path)
+		}
+
+		// Perform initial sync after a short delay; scheduled repositories wait for their schedule
+		period := interval
+		if schedule == nil {
+			initialDelay := time.NewTimer(initialSyncDelay + offset)
+			s.setNextSync(repoConfig.Path, time.Now().Add(initialSyncDelay+offset))
+			select {
+			case <-initialDelay.C:
+				runSync()
+			case <-trigger:
+				// Requested syncs don't wait for the startup delay
+				initialDelay.Stop()
+				runSync()
+			case <-ctx.Done():
+				initialDelay.Stop()
+				return
+			}
+			// Keep the staggered phase instead of ticking in step with every other repository
+			if offset > 0 || s.jitter > 0 || s.power.scale(interval) != interval {
+				period = s.jittered(s.power.scale(interval))
+				ticker.Reset(period)
+			}
 		}
 
 		// Regular sync loop
 		for {
+			if schedule == nil {
+				s.setNextSync(repoConfig.Path, time.Now().Add(period))
+			}
+
 			select {
-			case <-ticker.C:
-				s.performSync(repoConfig, sm)
+			case <-ticks:
+				runSync()
+				if timer != nil {
+					timer.Reset(s.untilNextSync(repoConfig.Path, schedule))
+				} else if next := s.power.scale(interval); s.jitter > 0 || next != period {
+					// Intervals vary by jitter and stretch while on battery
+					period = s.jittered(next)
+					ticker.Reset(period)
+				}
+			case <-changes:
+				runSync()
+			case <-trigger:
+				runSync()
+			case <-retries:
+				runSync()
 			case <-ctx.Done():
-				s.logger.Debug("Context cancelled for repository", "path", repoConfig.Path)
+				s.logger.Debug("Context cancelled for repository", "repo", repoConfig.Path)
 				return
 			}
 		}
 	}(repo)
 }
 
-func (s *Scheduler) performSync(repo config.RepoConfig, sm *SyncManager) {
+// performSync syncs repo once, recording and reporting the outcome; it returns the
+// recorded entry and the sync error
+func (s *Scheduler) performSync(repo config.RepoConfig, sm *SyncManager) (SyncHistoryEntry, error) {
 	s.logger.Debug("Performing scheduled sync", "repo", repo.Path)
 
 	// Use the scheduler's context for the sync operation
 	start := time.Now()
-	err := sm.SyncRepository(s.ctx, repo)
+	err := s.checkPaused(repo.Path)
+	if err == nil {
+		err = s.checkCircuit(repo.Path)
+	}
+	if err == nil {
+		err = s.connectivity.check(s.ctx)
+	}
+	if err == nil {
+		err = s.power.check()
+	}
+	if err == nil {
+		err = sm.SyncRepository(s.ctx, repo)
+	}
 	duration := time.Since(start)
 
+	// A failing run_after_pull doesn't undo the pull, so the sync counts as done
+	// with the failure recorded beside it, and is neither retried nor paused for
+	hookErr, hookFailed := AsHookError(err)
+	if hookFailed {
+		err = nil
+	}
+
 	// Determine status and error message
-	status := "success"
-	errorMsg := ""
-	if err != nil {
-		status = "failed"
-		errorMsg = err.Error()
+	entry := SyncHistoryEntry{
+		RepoPath:   repo.Path,
+		Direction:  repo.Direction,
+		Status:     "success",
+		DurationMs: duration.Milliseconds(),
+	}
+	// Syncs failing before the repository is opened have no transfer of their own
+	transfer, ok := sm.LastTransfer(repo.Path)
+	ranNow := ok && !transfer.StartedAt.Before(start)
+	if ranNow {
+		entry.BytesFetched = transfer.BytesFetched
+		if s.metrics != nil {
+			s.metrics.ObserveTransfer(repo.Path, transfer.BytesFetched)
+		}
+	}
+	skipErr, skipped := AsSkipError(err)
+	partialErr, partial := AsPartialError(err)
+	// A sync refused as busy, paused or offline never queued; the wait on record is another sync's
+	if !skipped || !skipErr.Reason.beforeQueue() {
+		if wait := sm.LastQueueWait(repo.Path); wait > 0 {
+			entry.QueueWaitMs = wait.Milliseconds()
+			if s.metrics != nil {
+				s.metrics.ObserveQueueWait(repo.Path, wait)
+			}
+		}
+		if wait := sm.LastHostWait(repo.Path); wait > 0 {
+			entry.HostWaitMs = wait.Milliseconds()
+			if s.metrics != nil {
+				s.metrics.ObserveHostWait(repo.Path, wait)
+			}
+		}
+	}
+	if skipped {
+		entry.Status = "skipped"
+		entry.SkipReason = string(skipErr.Reason)
+		entry.ErrorMsg = skipErr.Detail
+	} else if partial {
+		// Stopped by max_runtime after updating refs, which are kept
+		entry.Status = "partial"
+		entry.ErrorMsg = err.Error()
+		entry.Progress = partialErr.Summary()
+	} else if err != nil {
+		entry.Status = "failed"
+		entry.ErrorMsg = err.Error()
+		entry.ErrorKind = string(ClassifyError(entry.ErrorMsg))
+	} else if ranNow && !transfer.RefsUpdated {
+		// Nothing was pushed, pulled or committed
+		entry.Status = "noop"
+	}
+	if hookFailed {
+		entry.HookError = hookErr.Error()
+	}
+
+	// Record in history and report to the fleet unless the repo opted out
+	if repo.HistoryEnabled() {
+		s.recordHistory(entry)
+	}
+
+	if s.metrics != nil {
+		s.metrics.ObserveSync(repo.Path, entry.Status, entry.SkipReason, duration)
+	}
+
+	// Partial syncs wait for their next interval rather than retrying
+	retryErr := err
+	if partial {
+		retryErr = nil
+	}
+	retry, retrying := s.planRetry(repo.Path, repo.RetryAttempts(), retryErr)
+	circuit, paused := s.recordCircuit(repo.Path, entry.Status, entry.ErrorMsg, retrying)
+
+	// Skipped and partial syncs are visible in status/history but don't warrant a desktop notification,
+	// failures only do once they are not retried anymore, and pauses replace them. No-op syncs go to the
+	// notification policy, which only shows them as recoveries
+	if s.notificationManager != nil && !skipped && !partial && !retrying && !paused {
+		if repo.NotifiesOf(entry.Status) {
+			s.notificationManager.SendSyncNotification(repo.Path, repo.Direction, entry.Status, duration, entry.ErrorMsg)
+		} else {
+			s.notificationManager.RecordStatus(repo.Path, entry.Status)
+		}
 	}
 
-	// Record in history if history manager is available
-	if s.historyManager != nil {
-		s.historyManager.RecordSync(repo.Path, repo.Direction, status, duration, errorMsg)
+	// A repository still failing after its pause pauses again silently
+	if paused && circuit.Failures == s.circuitFailures && s.notificationManager != nil && repo.NotificationsEnabled() {
+		s.notificationManager.SendSyncNotification(repo.Path, repo.Direction, "paused", duration,
+			fmt.Sprintf("%d syncs in a row failed, paused until %s ('git sync resume' retries now): %s",
+				circuit.Failures, circuit.PausedUntil.Local().Format("15:04"), entry.ErrorMsg))
 	}
 
-	// Send notification if notification manager is available
-	if s.notificationManager != nil {
-		s.notificationManager.SendSyncNotification(repo.Path, repo.Direction, status, duration, errorMsg)
+	// Blocked pushes need the user to act, so they are the exception
+	if s.newSecretAlert(repo.Path, skipErr) && s.notificationManager != nil && repo.NotificationsEnabled() {
+		s.notificationManager.SendSyncNotification(repo.Path, repo.Direction, "blocked", duration, skipErr.Detail)
+	}
+
+	if hookFailed {
+		s.logger.Warn("After-pull command failed, the sync itself succeeded",
+			"repo", repo.Path,
+			"error", hookErr)
 	}
 
-	if err != nil {
+	if skipped && skipErr.Reason == SkipSecretsDetected {
+		s.logger.Warn("Push blocked by secret scan",
+			"repo", repo.Path,
+			"detail", skipErr.Detail)
+	} else if skipped {
+		s.logger.Info("Sync skipped",
+			"repo", repo.Path,
+			"reason", skipErr.Reason,
+			"detail", skipErr.Detail)
+	} else if partial {
+		s.logger.Warn("Sync stopped at max_runtime, keeping the refs it updated",
+			"repo", repo.Path,
+			"max_runtime", partialErr.Budget,
+			"kept", entry.Progress)
+	} else if paused {
+		s.logger.Error("Sync failed repeatedly, pausing repository",
+			"repo", repo.Path,
+			"error", err,
+			"kind", entry.ErrorKind,
+			"failures", circuit.Failures,
+			"until", circuit.PausedUntil.Format(time.RFC3339))
+	} else if retrying {
+		s.logger.Warn("Sync failed, retrying",
+			"repo", repo.Path,
+			"error", err,
+			"kind", entry.ErrorKind,
+			"attempt", retry.Attempt,
+			"max_attempts", retry.MaxAttempts,
+			"retry_in", time.Until(retry.NextRetry).Round(time.Second))
+	} else if err != nil {
 		s.logger.Error("Sync failed", 
 			"repo", repo.Path, 
 			"error", err,
+			"kind", entry.ErrorKind,
+			"duration", duration)
+	} else if entry.Status == "noop" {
+		s.logger.Debug("Sync completed, nothing changed",
+			"repo", repo.Path,
 			"duration", duration)
 	} else {
 		s.logger.Info("Sync completed successfully", 
 			"repo", repo.Path,
 			"duration", duration)
 	}
+
+	var moved []RefUpdate
+	if ranNow {
+		moved = transfer.RefsMoved
+	}
+	s.sendWebhook(repo, entry, moved)
+
+	if err == nil && repo.Mirror != "" {
+		s.runMirror(repo, entry)
+	}
+
+	return entry, err
+}
+
+// TriggerAll makes every repository and sync set sync right away, once each
+// even when triggered again before they get to it
+func (s *Scheduler) TriggerAll() {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, trigger := range s.triggers {
+		select {
+		case trigger <- struct{}{}:
+		default:
+			// Already pending
+		}
+	}
+}
+
+// Trigger makes a repository sync right away, or the sync set it belongs to,
+// reporting whether the repository is scheduled at all
+func (s *Scheduler) Trigger(repoPath string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	trigger, exists := s.triggers[repoPath]
+	if !exists {
+		set, member := s.memberSets[repoPath]
+		if !member {
+			return false
+		}
+		trigger = s.triggers[setTriggerKey(set)]
+	}
+	select {
+	case trigger <- struct{}{}:
+	default:
+		// Already pending
+	}
+	return true
+}
+
+// startOffset spreads the i-th of n first syncs over the stagger window
+func (s *Scheduler) startOffset(i, n int) time.Duration {
+	if s.stagger <= 0 || n <= 1 {
+		return 0
+	}
+	return s.stagger * time.Duration(i) / time.Duration(n)
+}
+
+// jittered varies d randomly by up to the configured jitter percent either way
+func (s *Scheduler) jittered(d time.Duration) time.Duration {
+	if s.jitter <= 0 || d <= 0 {
+		return d
+	}
+	spread := d * time.Duration(s.jitter) / 100
+	return d - spread + rand.N(2*spread+1)
+}
+
+// untilNextSync records when schedule next fires for repoPath and returns how long until then
+func (s *Scheduler) untilNextSync(repoPath string, schedule *cron.Schedule) time.Duration {
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		// The expression names no real date, e.g. February 30th
+		next = time.Now().Add(24 * time.Hour)
+	}
+	s.setNextSync(repoPath, next)
+	return time.Until(next)
+}
+
+// setNextSync records when repoPath is next expected to sync
+func (s *Scheduler) setNextSync(repoPath string, next time.Time) {
+	s.nextSyncMu.Lock()
+	s.nextSync[repoPath] = next
+	s.nextSyncMu.Unlock()
+}
+
+// triggerOrDefault returns the configured sync trigger, interval when unset
+func triggerOrDefault(trigger string) string {
+	if trigger == "" {
+		return "interval"
+	}
+	return trigger
+}
+
+// newSecretAlert reports whether a sync was blocked by the secret scan with findings
+// the user has not been notified about yet; any other outcome clears the last alert
+func (s *Scheduler) newSecretAlert(repoPath string, skipErr *SkipError) bool {
+	s.secretAlertsMu.Lock()
+	defer s.secretAlertsMu.Unlock()
+
+	if skipErr == nil || skipErr.Reason != SkipSecretsDetected {
+		delete(s.secretAlerts, repoPath)
+		return false
+	}
+	if s.secretAlerts[repoPath] == skipErr.Detail {
+		return false
+	}
+	s.secretAlerts[repoPath] = skipErr.Detail
+	return true
 }
 
 // GetStatus returns the current status of all scheduled repositories
@@ -178,17 +662,40 @@ func (s *Scheduler) GetStatus() map[string]SchedulerStatus {
 
 	status := make(map[string]SchedulerStatus)
 	
+	s.nextSyncMu.Lock()
+	defer s.nextSyncMu.Unlock()
+
 	for path := range s.tickers {
 		status[path] = SchedulerStatus{
-			Path:      path,
-			Active:    true,
-			NextSync:  time.Now(), // This would need to be tracked more precisely
+			Path:     path,
+			Active:   true,
+			NextSync: s.nextSync[path],
+		}
+	}
+	for path := range s.timers {
+		status[path] = SchedulerStatus{
+			Path:     path,
+			Active:   true,
+			NextSync: s.nextSync[path],
+		}
+	}
+	// Sync set members have no timer of their own
+	for path, next := range s.nextSync {
+		if _, exists := status[path]; !exists {
+			status[path] = SchedulerStatus{Path: path, Active: true, NextSync: next}
 		}
 	}
 
 	return status
 }
 
+// NextSync returns when a repository is next expected to sync, zero when it isn't scheduled
+func (s *Scheduler) NextSync(repoPath string) time.Time {
+	s.nextSyncMu.Lock()
+	defer s.nextSyncMu.Unlock()
+	return s.nextSync[repoPath]
+}
+
 type SchedulerStatus struct {
 	Path     string
 	Active   bool
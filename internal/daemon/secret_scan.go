@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/secrets"
+)
+
+// maxReportedFindings bounds how many findings a blocked push lists
+const maxReportedFindings = 3
+
+// scanPush scans the commits the go-git push would send, see scanOutgoing
+func (g *GitOperations) scanPush(ctx context.Context, r *git.Repository, repo configPkg.RepoConfig) error {
+	if !repo.SecretScan {
+		return nil
+	}
+	refSpecs, err := g.pushRefSpecStrings(ctx, r, repo)
+	if err != nil {
+		return err
+	}
+	return scanOutgoing(ctx, repo, refSpecs)
+}
+
+// pushRefSpecStrings returns the refspecs a go-git push of repo uses, as strings
+// for the git binary
+func (g *GitOperations) pushRefSpecStrings(ctx context.Context, r *git.Repository, repo configPkg.RepoConfig) ([]string, error) {
+	var refSpecs []string
+	switch {
+	case len(repo.PushRefSpecs) > 0:
+		refSpecs = repo.PushRefSpecs
+	case repo.BranchStrategy == "specific":
+		refSpecs = []string{fmt.Sprintf("refs/heads/%s:refs/heads/%s", repo.TargetBranch, repo.TargetBranch)}
+	default:
+		specs, err := g.getRefSpecs(ctx, r, repo, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, spec := range specs {
+			refSpecs = append(refSpecs, spec.String())
+		}
+	}
+	return refSpecs, nil
+}
+
+// scanOutgoing blocks the push when commits not yet on the remote add lines that
+// look like credentials. Commits stay local so the user can amend them; the push
+// resumes on its own once the findings are gone.
+func scanOutgoing(ctx context.Context, repo configPkg.RepoConfig, refSpecs []string) error {
+	if !repo.SecretScan {
+		return nil
+	}
+
+	var revs []string
+	for _, spec := range refSpecs {
+		src, _, _ := strings.Cut(strings.TrimPrefix(spec, "+"), ":")
+		switch {
+		case src == "":
+			// Deletions send no commits
+		case strings.Contains(src, "*"):
+			revs = append(revs, "--glob="+src)
+		default:
+			revs = append(revs, src)
+		}
+	}
+	if len(revs) == 0 {
+		return nil
+	}
+
+	scanner, err := secrets.NewScanner(repo.SecretScanRules)
+	if err != nil {
+		return err
+	}
+
+	// Commits reachable from the pushed refs but from no remote-tracking ref are the ones the push sends
+	args := append(gitConfigArgs(repo), "log", "-p", "--no-color", "--no-ext-diff", "--no-textconv",
+		"--format=commit %H")
+	args = append(args, revs...)
+	args = append(args, "--not", "--remotes="+repo.Remote, "--")
+	patch, err := runGit(ctx, repo.Path, args...)
+	if err != nil {
+		return fmt.Errorf("failed to scan outgoing commits for secrets: %w", err)
+	}
+
+	findings := scanner.ScanPatch(patch)
+	if len(findings) == 0 {
+		return nil
+	}
+
+	reported := make([]string, 0, maxReportedFindings)
+	for i, finding := range findings {
+		if i == maxReportedFindings {
+			reported = append(reported, fmt.Sprintf("and %d more", len(findings)-maxReportedFindings))
+			break
+		}
+		reported = append(reported, finding.String())
+	}
+	return newSkipError(SkipSecretsDetected,
+		"push blocked, possible secrets in outgoing commits: %s", strings.Join(reported, "; "))
+}
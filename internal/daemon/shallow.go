@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+// fetchDepth returns the depth to request from the remote. The configured depth
+// only applies while the repository is shallow or has no history yet; a full
+// clone already fetches incrementally and must not be made shallow again.
+func (g *GitOperations) fetchDepth(r *git.Repository, repo configPkg.RepoConfig) int {
+	if repo.FetchDepth <= 0 {
+		return 0
+	}
+	if isShallow(r) {
+		return repo.FetchDepth
+	}
+	if _, err := r.Head(); errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return repo.FetchDepth
+	}
+	return 0
+}
+
+// isShallow reports whether the repository has truncated history
+func isShallow(r *git.Repository) bool {
+	shallows, err := r.Storer.Shallow()
+	return err == nil && len(shallows) > 0
+}
+
+// withUnshallowRetry runs op and, when it fails in a shallow repository for
+// lack of history (ancestry checks, merge bases, pushes), fetches the full
+// history once and retries with depth limiting disabled.
+func (g *GitOperations) withUnshallowRetry(ctx context.Context, r *git.Repository, repo configPkg.RepoConfig, op func(configPkg.RepoConfig) error) error {
+	err := op(repo)
+	if err == nil || !isShallow(r) || !needsFullHistory(err) {
+		return err
+	}
+
+	g.logger.Info("Shallow history is insufficient, fetching full history",
+		"repo", repo.Path,
+		"reason", err)
+
+	credentials, credErr := credentialEnv(repo)
+	if credErr != nil {
+		return fmt.Errorf("%w (unshallow failed: %v)", err, credErr)
+	}
+	args := append(gitConfigArgs(repo), "fetch", "--unshallow", repo.Remote)
+	if _, unshallowErr := runGitEnv(ctx, repo.Path, credentials, args...); unshallowErr != nil {
+		return fmt.Errorf("%w (unshallow failed: %v)", err, unshallowErr)
+	}
+
+	repo.FetchDepth = 0
+	return op(repo)
+}
+
+// needsFullHistory reports whether err may be caused by missing shallow history:
+// objects beyond the shallow boundary, histories with no merge base in it, or
+// shallow updates refused. Diverged branches also fail as non fast-forward with
+// full history, so those don't count.
+func needsFullHistory(err error) bool {
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "unrelated histories") ||
+		strings.Contains(msg, "shallow") ||
+		strings.Contains(msg, "bad object") ||
+		strings.Contains(msg, "did not send all necessary objects")
+}
@@ -0,0 +1,53 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SkipReason is a machine-readable reason for a sync that was deliberately not performed
+type SkipReason string
+
+const (
+	SkipDirtyWorktree   SkipReason = "dirty_worktree"
+	SkipDetachedHead    SkipReason = "detached_head"
+	SkipUnsafeOwnership SkipReason = "unsafe_ownership"
+	SkipSecretsDetected SkipReason = "secrets_detected"
+	SkipSyncSetAborted  SkipReason = "sync_set_aborted"
+	SkipRepoBusy        SkipReason = "repo_busy"
+	SkipRepoLocked      SkipReason = "repo_locked"
+	SkipRateLimited     SkipReason = "rate_limited"
+	SkipCircuitOpen     SkipReason = "circuit_open"
+	SkipPaused          SkipReason = "paused"
+	SkipOffline         SkipReason = "offline"
+	SkipOnBattery       SkipReason = "on_battery"
+)
+
+// beforeQueue reports whether syncs skipped for r never waited for a sync slot
+func (r SkipReason) beforeQueue() bool {
+	return r == SkipRepoBusy || r == SkipCircuitOpen || r == SkipPaused || r == SkipOffline || r == SkipOnBattery
+}
+
+// SkipError signals that a sync was skipped rather than failed
+type SkipError struct {
+	Reason SkipReason
+	Detail string
+}
+
+func (e *SkipError) Error() string {
+	return e.Detail
+}
+
+// newSkipError creates a SkipError with a human-readable detail message
+func newSkipError(reason SkipReason, format string, args ...any) error {
+	return &SkipError{Reason: reason, Detail: fmt.Sprintf(format, args...)}
+}
+
+// AsSkipError reports whether err (or anything it wraps) is a SkipError
+func AsSkipError(err error) (*SkipError, bool) {
+	var skipErr *SkipError
+	if errors.As(err, &skipErr) {
+		return skipErr, true
+	}
+	return nil, false
+}
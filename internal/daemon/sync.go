@@ -2,32 +2,155 @@ package daemon
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bnema/git-sync/internal/config"
 )
 
 type SyncManager struct {
-	maxConcurrent int
-	semaphore     chan struct{}
-	gitOps        *GitOperations
-	logger        *slog.Logger
+	gitOps   *GitOperations
+	backends map[string]GitBackend
+	logger   *slog.Logger
+
+	// Repositories being synced, and how long each one's last sync queued for
+	// a slot and for its remote host
+	locksMu   sync.Mutex
+	busy      map[string]bool
+	queueWait map[string]time.Duration
+	hostWait  map[string]time.Duration
+
+	// Syncs talking to each remote host, limited by max_syncs_per_host and host_sync_delay
+	hosts *hostLimiter
+
+	// Slots of every concurrency class: io-heavy, normal (max_concurrent_syncs) and light
+	slotsMu sync.Mutex
+	pools   map[string]*slotPool
+
+	// Artificial failures and delays for testing; nil injects nothing
+	faults atomic.Pointer[faults]
 }
 
 func NewSyncManager(maxConcurrent int, logger *slog.Logger) *SyncManager {
+	gitOps := NewGitOperations(logger)
 	return &SyncManager{
-		maxConcurrent: maxConcurrent,
-		semaphore:     make(chan struct{}, maxConcurrent),
-		gitOps:        NewGitOperations(logger),
-		logger:        logger,
+		gitOps: gitOps,
+		backends: map[string]GitBackend{
+			BackendGoGit: gitOps,
+			BackendGit:   NewExecBackend(gitOps),
+		},
+		logger:    logger,
+		busy:      make(map[string]bool),
+		queueWait: make(map[string]time.Duration),
+		hostWait:  make(map[string]time.Duration),
+		hosts:     newHostLimiter(),
+		// Until SetClassLimits, io-heavy syncs run one at a time and light ones
+		// get a budget as large as normal ones
+		pools: map[string]*slotPool{
+			config.ClassIOHeavy: {max: 1},
+			config.ClassNormal:  {max: maxConcurrent},
+			config.ClassLight:   {max: maxConcurrent},
+		},
 	}
 }
 
 func (sm *SyncManager) SyncRepository(ctx context.Context, repo config.RepoConfig) error {
-	// Acquire semaphore to limit concurrent operations
-	sm.semaphore <- struct{}{}
-	defer func() { <-sm.semaphore }()
+	// Never run two syncs of the same repository at once
+	if !sm.acquireRepo(repo.Path) {
+		return newSkipError(SkipRepoBusy, "another sync of this repository is still running, skipping sync")
+	}
+	defer sm.releaseRepo(repo.Path)
+
+	// Limit the syncs talking to the same remote host, when configured
+	var host string
+	if sm.hosts.limited() {
+		host = remoteHost(repo)
+	}
+	hostWait, err := sm.hosts.acquire(ctx, host)
+	sm.recordHostWait(repo.Path, hostWait)
+	if err != nil {
+		return err
+	}
+	defer sm.hosts.release(host)
+	if hostWait > 0 {
+		sm.logger.Debug("Sync waited for its remote host",
+			"repo", repo.Path,
+			"wait", hostWait,
+			"host", host)
+	}
+
+	// Limit concurrent operations per concurrency class; higher priorities get free slots first
+	class := repo.Class()
+	wait, err := sm.acquireSlot(ctx, class, repo.PriorityRank())
+	sm.recordQueueWait(repo.Path, wait)
+	if err != nil {
+		return err
+	}
+	defer sm.releaseSlot(class)
+	if wait > 0 {
+		sm.logger.Debug("Sync waited for a free slot",
+			"repo", repo.Path,
+			"wait", wait,
+			"priority", repo.PriorityRank(),
+			"class", class)
+	}
 
-	// Delegate to GitOperations which handles all the complexity
-	return sm.gitOps.SyncRepository(ctx, repo)
-}
\ No newline at end of file
+	// The runtime budget starts once the sync has its slot
+	start := time.Now()
+	ctx, cancel := withMaxRuntime(ctx, repo)
+	defer cancel()
+
+	if err := checkIndexLock(ctx, repo); err != nil {
+		return err
+	}
+	if err := checkRateLimit(ctx, repo); err != nil {
+		return err
+	}
+	if err := sm.faults.Load().inject(ctx, repo.Path); err != nil {
+		return err
+	}
+
+	backend, err := sm.backend(repo)
+	if err != nil {
+		return err
+	}
+
+	// Delegate to the backend which handles all the complexity
+	err = backend.SyncRepository(ctx, repo)
+	if err != nil && errors.Is(context.Cause(ctx), errMaxRuntime) {
+		return sm.runtimeExceeded(repo, start, err)
+	}
+	return err
+}
+
+// backend returns the git backend selected for repo, go-git when unset
+func (sm *SyncManager) backend(repo config.RepoConfig) (GitBackend, error) {
+	name := repo.GitBackend
+	if name == "" {
+		name = BackendGoGit
+	}
+	backend, exists := sm.backends[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown git backend: %s", name)
+	}
+	return backend, nil
+}
+
+// ActiveTransfers returns the progress of syncs currently running
+func (sm *SyncManager) ActiveTransfers() []TransferProgress {
+	return sm.gitOps.progress.snapshot()
+}
+
+// RateLimits returns the rate limits git hosts reported in their latest responses
+func (sm *SyncManager) RateLimits() []RateLimit {
+	return rateLimits.snapshot()
+}
+
+// LastTransfer returns the transfer result of the most recent finished sync of repoPath
+func (sm *SyncManager) LastTransfer(repoPath string) (TransferProgress, bool) {
+	return sm.gitOps.progress.lastResult(repoPath)
+}
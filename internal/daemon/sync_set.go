@@ -0,0 +1,229 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/cron"
+)
+
+// SyncSet is a group of repositories synced one after another as a unit
+type SyncSet struct {
+	Name     string
+	Interval int    // seconds
+	Schedule string // cron expression, replaces Interval when set
+	Members  []config.RepoConfig
+}
+
+// SyncSets resolves the configured sync sets against the enabled repositories.
+// It returns the sets and the repositories that sync on their own.
+func SyncSets(cfg *config.Config, enabled []config.RepoConfig) ([]SyncSet, []config.RepoConfig) {
+	byPath := make(map[string]config.RepoConfig, len(enabled))
+	for _, repo := range enabled {
+		byPath[repo.Path] = repo
+	}
+
+	var sets []SyncSet
+	inSet := make(map[string]bool)
+	for _, setConfig := range cfg.SyncSets {
+		set := SyncSet{
+			Name:     setConfig.Name,
+			Interval: setConfig.Interval,
+			Schedule: setConfig.Schedule,
+		}
+		if set.Interval <= 0 {
+			set.Interval = cfg.Global.DefaultInterval
+		}
+		// Disabled members are left out rather than blocking the rest of the set
+		for _, path := range setConfig.Repositories {
+			if repo, enabled := byPath[path]; enabled {
+				set.Members = append(set.Members, repo)
+				inSet[path] = true
+			}
+		}
+		if len(set.Members) > 0 {
+			sets = append(sets, set)
+		}
+	}
+
+	var standalone []config.RepoConfig
+	for _, repo := range enabled {
+		if !inSet[repo.Path] {
+			standalone = append(standalone, repo)
+		}
+	}
+	return sets, standalone
+}
+
+// StartSyncSets starts syncing each set on its own interval or schedule
+func (s *Scheduler) StartSyncSets(ctx context.Context, sets []SyncSet, sm *SyncManager) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ctx = ctx
+	for i, set := range sets {
+		s.scheduleSyncSet(ctx, set, sm, s.startOffset(i, len(sets)))
+	}
+}
+
+func (s *Scheduler) scheduleSyncSet(ctx context.Context, set SyncSet, sm *SyncManager, offset time.Duration) {
+	s.logger.Info("Scheduling sync set",
+		"set", set.Name,
+		"repositories", len(set.Members),
+		"interval", set.Interval,
+		"schedule", set.Schedule,
+		"start_offset", offset)
+
+	var schedule *cron.Schedule
+	if set.Schedule != "" {
+		parsed, err := cron.Parse(set.Schedule)
+		if err != nil {
+			s.logger.Error("Invalid schedule, using interval", "set", set.Name, "error", err)
+		} else {
+			schedule = parsed
+		}
+	}
+
+	trigger := make(chan struct{}, 1)
+	s.triggers[setTriggerKey(set.Name)] = trigger
+	for _, member := range set.Members {
+		s.memberSets[member.Path] = set.Name
+	}
+	ctx, job := s.addJob(ctx, setTriggerKey(set.Name), scheduledJob{set: set})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(job.done)
+		defer func() {
+			s.mutex.Lock()
+			delete(s.jobs, setTriggerKey(set.Name))
+			delete(s.triggers, setTriggerKey(set.Name))
+			for _, member := range set.Members {
+				delete(s.memberSets, member.Path)
+			}
+			s.mutex.Unlock()
+
+			s.nextSyncMu.Lock()
+			for _, member := range set.Members {
+				delete(s.nextSync, member.Path)
+			}
+			s.nextSyncMu.Unlock()
+		}()
+
+		var stoppedAt string
+		var retryTimer *time.Timer
+		var retries <-chan time.Time
+		defer func() {
+			if retryTimer != nil {
+				retryTimer.Stop()
+			}
+		}()
+
+		// Like single repositories, interval sets sync shortly after startup
+		wait := initialSyncDelay + offset
+		for {
+			if schedule != nil {
+				next := schedule.Next(time.Now())
+				if next.IsZero() {
+					next = time.Now().Add(24 * time.Hour)
+				}
+				wait = time.Until(next)
+			}
+			for _, member := range set.Members {
+				s.setNextSync(member.Path, time.Now().Add(wait))
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+				stoppedAt = s.performSetSync(set, sm)
+			case <-trigger:
+				timer.Stop()
+				stoppedAt = s.performSetSync(set, sm)
+			case <-retries:
+				timer.Stop()
+				stoppedAt = s.performSetSync(set, sm)
+			case <-ctx.Done():
+				timer.Stop()
+				s.logger.Debug("Context cancelled for sync set", "set", set.Name)
+				return
+			}
+
+			// A member failing transiently retries the whole set
+			if retryTimer != nil {
+				retryTimer.Stop()
+			}
+			retryTimer, retries = nil, nil
+			if stoppedAt != "" {
+				retryTimer, retries = s.retryTimer(stoppedAt)
+			}
+			wait = s.jittered(s.power.scale(time.Duration(set.Interval) * time.Second))
+		}
+	}()
+}
+
+// setTriggerKey keys a sync set's trigger apart from repository paths
+func setTriggerKey(name string) string {
+	return "set:" + name
+}
+
+// performSetSync syncs the members in order and stops at the first one that
+// fails or is skipped; the members after it are recorded as not attempted.
+// It returns the path of the member the set stopped at, empty when all synced.
+func (s *Scheduler) performSetSync(set SyncSet, sm *SyncManager) string {
+	start := time.Now()
+	for i, member := range set.Members {
+		_, err := s.performSync(member, sm)
+		if err == nil {
+			continue
+		}
+
+		rest := set.Members[i+1:]
+		detail := fmt.Sprintf("sync set '%s' stopped at %s", set.Name, filepath.Base(member.Path))
+		for _, skipped := range rest {
+			s.recordNotAttempted(skipped, detail)
+		}
+
+		s.logger.Error("Sync set partially failed",
+			"set", set.Name,
+			"failed", member.Path,
+			"synced", i,
+			"not_attempted", len(rest),
+			"error", err)
+
+		// Failed members already notified; skipped ones don't, yet they stop the set just the same
+		if _, skipped := AsSkipError(err); skipped && s.notificationManager != nil && member.NotificationsEnabled() {
+			s.notificationManager.SendSyncNotification(set.Name, "set", "failed", time.Since(start),
+				fmt.Sprintf("%s: %v; %d repositories not attempted", detail, err, len(rest)))
+		}
+		return member.Path
+	}
+
+	s.logger.Info("Sync set completed",
+		"set", set.Name,
+		"repositories", len(set.Members),
+		"duration", time.Since(start))
+	return ""
+}
+
+// recordNotAttempted records a member a sync set stopped before, returning the entry
+func (s *Scheduler) recordNotAttempted(repo config.RepoConfig, detail string) SyncHistoryEntry {
+	entry := SyncHistoryEntry{
+		RepoPath:   repo.Path,
+		Direction:  repo.Direction,
+		Status:     "skipped",
+		SkipReason: string(SkipSyncSetAborted),
+		ErrorMsg:   "not attempted: " + detail,
+	}
+	if repo.HistoryEnabled() {
+		s.recordHistory(entry)
+	}
+	if s.metrics != nil {
+		s.metrics.ObserveSync(repo.Path, entry.Status, entry.SkipReason, 0)
+	}
+	return entry
+}
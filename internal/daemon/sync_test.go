@@ -0,0 +1,214 @@
+package daemon_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/gitfixture"
+)
+
+// backends are the git_backend values the sync engine supports
+var backends = []string{"go-git", "git"}
+
+// repoFunc returns the config syncing a clone in direction, with the
+// backend under test selected
+type repoFunc func(c *gitfixture.Clone, direction string) config.RepoConfig
+
+func TestSyncRepository(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, f *gitfixture.Fixture, repo repoFunc)
+	}{
+		{
+			name: "push",
+			run: func(t *testing.T, f *gitfixture.Fixture, repo repoFunc) {
+				laptop := f.Clone("laptop")
+				head := laptop.Commit("notes.md", "draft\n", "Add notes")
+
+				f.MustSync(repo(laptop, "push"))
+				requireRemote(t, f, gitfixture.DefaultBranch, head)
+			},
+		},
+		{
+			name: "pull",
+			run: func(t *testing.T, f *gitfixture.Fixture, repo repoFunc) {
+				laptop, desktop := f.Clone("laptop"), f.Clone("desktop")
+				head := laptop.Commit("notes.md", "draft\n", "Add notes")
+				f.MustSync(repo(laptop, "push"))
+
+				f.MustSync(repo(desktop, "pull"))
+				desktop.RequireFile("notes.md", "draft\n")
+				requireHead(t, desktop, head)
+			},
+		},
+		{
+			name: "both ways",
+			run: func(t *testing.T, f *gitfixture.Fixture, repo repoFunc) {
+				laptop, desktop := f.Clone("laptop"), f.Clone("desktop")
+				laptop.Commit("notes.md", "draft\n", "Add notes")
+				f.MustSync(repo(laptop, "both"))
+				f.MustSync(repo(desktop, "both"))
+
+				head := desktop.Commit("todo.md", "- sync\n", "Add todo")
+				f.MustSync(repo(desktop, "both"))
+				f.MustSync(repo(laptop, "both"))
+				laptop.RequireFile("notes.md", "draft\n")
+				laptop.RequireFile("todo.md", "- sync\n")
+				requireHead(t, laptop, head)
+			},
+		},
+		{
+			name: "conflict",
+			run: func(t *testing.T, f *gitfixture.Fixture, repo repoFunc) {
+				laptop, desktop := f.Clone("laptop"), f.Clone("desktop")
+				gitfixture.Diverge(laptop, desktop, "notes.md")
+				remote := f.RemoteHead(gitfixture.DefaultBranch)
+				local := desktop.Head()
+
+				if err := f.Sync(repo(desktop, "both")); err == nil {
+					t.Fatal("sync of diverged clones succeeded, want a conflict")
+				}
+				// Neither side loses its commit
+				requireRemote(t, f, gitfixture.DefaultBranch, remote)
+				requireHead(t, desktop, local)
+				desktop.RequireFile("notes.md", "from desktop\n")
+				if status := desktop.Git("status", "--porcelain"); status != "" {
+					t.Errorf("desktop worktree left dirty:\n%s", status)
+				}
+			},
+		},
+		{
+			name: "current strategy on a feature branch",
+			run: func(t *testing.T, f *gitfixture.Fixture, repo repoFunc) {
+				laptop, desktop := f.Clone("laptop"), f.Clone("desktop")
+				main := f.RemoteHead(gitfixture.DefaultBranch)
+				laptop.Checkout("feature", true)
+				laptop.Commit("feature.md", "wip\n", "Start feature")
+				f.MustSync(repo(laptop, "push"))
+				desktop.Git("fetch", "--quiet", "origin")
+				desktop.Git("checkout", "--quiet", "-b", "feature", "--track", "origin/feature")
+
+				head := laptop.Commit("feature.md", "done\n", "Finish feature")
+				f.MustSync(repo(laptop, "push"))
+				requireRemote(t, f, "feature", head)
+				requireRemote(t, f, gitfixture.DefaultBranch, main)
+
+				f.MustSync(repo(desktop, "pull"))
+				requireHead(t, desktop, head)
+			},
+		},
+		{
+			name: "main strategy from a feature branch",
+			run: func(t *testing.T, f *gitfixture.Fixture, repo repoFunc) {
+				laptop, desktop := f.Clone("laptop"), f.Clone("desktop")
+				head := desktop.Commit("notes.md", "draft\n", "Add notes")
+				desktop.Checkout("feature", true)
+
+				push := repo(desktop, "push")
+				push.BranchStrategy = "main"
+				f.MustSync(push)
+				requireRemote(t, f, gitfixture.DefaultBranch, head)
+				if got := f.RemoteHead("feature"); got != "" {
+					t.Errorf("feature pushed at %s, want only %s pushed", got, gitfixture.DefaultBranch)
+				}
+
+				laptop.Checkout("feature", true)
+				pull := repo(laptop, "pull")
+				pull.BranchStrategy = "main"
+				f.MustSync(pull)
+				if got := laptop.Git("rev-parse", gitfixture.DefaultBranch); got != head {
+					t.Errorf("laptop %s at %s, want %s", gitfixture.DefaultBranch, got, head)
+				}
+				requireBranch(t, laptop, "feature")
+			},
+		},
+		{
+			name: "specific strategy",
+			run: func(t *testing.T, f *gitfixture.Fixture, repo repoFunc) {
+				laptop, desktop := f.Clone("laptop"), f.Clone("desktop")
+				desktop.Checkout("develop", true)
+				head := desktop.Commit("develop.md", "next\n", "Start develop")
+				desktop.Checkout(gitfixture.DefaultBranch, false)
+
+				push := repo(desktop, "push")
+				push.BranchStrategy = "specific"
+				push.TargetBranch = "develop"
+				f.MustSync(push)
+				requireRemote(t, f, "develop", head)
+				requireBranch(t, desktop, gitfixture.DefaultBranch)
+
+				// The local develop branch is created from the remote's
+				pull := repo(laptop, "pull")
+				pull.BranchStrategy = "specific"
+				pull.TargetBranch = "develop"
+				f.MustSync(pull)
+				if got := laptop.Git("rev-parse", "develop"); got != head {
+					t.Errorf("laptop develop at %s, want %s", got, head)
+				}
+				requireBranch(t, laptop, gitfixture.DefaultBranch)
+			},
+		},
+		{
+			name: "all strategy",
+			run: func(t *testing.T, f *gitfixture.Fixture, repo repoFunc) {
+				laptop, desktop := f.Clone("laptop"), f.Clone("desktop")
+				desktop.Checkout("feature", true)
+				feature := desktop.Commit("feature.md", "wip\n", "Start feature")
+				desktop.Checkout(gitfixture.DefaultBranch, false)
+				main := desktop.Commit("notes.md", "draft\n", "Add notes")
+
+				push := repo(desktop, "push")
+				push.BranchStrategy = "all"
+				f.MustSync(push)
+				requireRemote(t, f, gitfixture.DefaultBranch, main)
+				requireRemote(t, f, "feature", feature)
+
+				// The checked out branch is behind its upstream and clean, so it is fast-forwarded
+				pull := repo(laptop, "pull")
+				pull.BranchStrategy = "all"
+				f.MustSync(pull)
+				requireHead(t, laptop, main)
+				if got := laptop.Git("rev-parse", "origin/feature"); got != feature {
+					t.Errorf("laptop origin/feature at %s, want %s", got, feature)
+				}
+			},
+		},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			repo := func(c *gitfixture.Clone, direction string) config.RepoConfig {
+				r := c.Repo(direction)
+				r.GitBackend = backend
+				return r
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.run(t, gitfixture.New(t), repo)
+				})
+			}
+		})
+	}
+}
+
+func requireRemote(t *testing.T, f *gitfixture.Fixture, branch, want string) {
+	t.Helper()
+	if got := f.RemoteHead(branch); got != want {
+		t.Fatalf("remote %s at %s, want %s", branch, got, want)
+	}
+}
+
+func requireHead(t *testing.T, c *gitfixture.Clone, want string) {
+	t.Helper()
+	if got := c.Head(); got != want {
+		t.Fatalf("%s at %s, want %s", filepath.Base(c.Path), got, want)
+	}
+}
+
+func requireBranch(t *testing.T, c *gitfixture.Clone, want string) {
+	t.Helper()
+	if got := c.Branch(); got != want {
+		t.Errorf("%s left on %s, want %s", filepath.Base(c.Path), got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package daemon
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/secretref"
+)
+
+// transportOptions returns the proxy, extra CA certificates and credentials
+// for go-git transports. Proxy URLs may use http, https or socks5 schemes; the
+// CA bundle is a PEM file used in addition to the system roots. The
+// credentials are marked for the sessions to track rate limits.
+func transportOptions(repo configPkg.RepoConfig) (transport.ProxyOptions, []byte, transport.AuthMethod, error) {
+	proxy := transport.ProxyOptions{URL: repo.Proxy}
+
+	auth, err := transportAuth(repo)
+	if err != nil {
+		return proxy, nil, nil, err
+	}
+	auth = trackRateLimits(auth)
+
+	if repo.CABundle == "" {
+		return proxy, nil, auth, nil
+	}
+
+	caBundle, err := os.ReadFile(repo.CABundle)
+	if err != nil {
+		return proxy, nil, nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	return proxy, caBundle, auth, nil
+}
+
+// transportAuth returns the go-git credentials of the repository's http_token
+// or ssh_key, with the secrets they reference resolved. Without either, nil
+// leaves authentication to go-git, which uses ssh-agent for SSH remotes.
+func transportAuth(repo configPkg.RepoConfig) (transport.AuthMethod, error) {
+	switch {
+	case repo.HTTPToken != "":
+		username, token, err := httpCredentials(repo)
+		if err != nil {
+			return nil, err
+		}
+		return &githttp.BasicAuth{Username: username, Password: token}, nil
+	case repo.SSHKey != "":
+		passphrase, err := secretref.Resolve(repo.SSHKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("ssh_key_passphrase: %w", err)
+		}
+		auth, err := gitssh.NewPublicKeysFromFile(gitssh.DefaultUsername, repo.SSHKey, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ssh_key: %w", err)
+		}
+		return auth, nil
+	}
+	return nil, nil
+}
+
+// credentialEnv returns the environment making the git binary authenticate
+// with the repository's http_token or ssh_key. The token goes through the
+// environment rather than -c, which would show it in the process list, and is
+// added after any GIT_CONFIG_COUNT entries already in it.
+func credentialEnv(repo configPkg.RepoConfig) ([]string, error) {
+	switch {
+	case repo.HTTPToken != "":
+		username, token, err := httpCredentials(repo)
+		if err != nil {
+			return nil, err
+		}
+		header := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+token))
+		return appendConfigEnv("http.extraHeader", header), nil
+	case repo.SSHKey != "":
+		if repo.SSHKeyPassphrase != "" {
+			return nil, fmt.Errorf("ssh_key_passphrase needs the go-git backend; add the key to ssh-agent to use it with git")
+		}
+		key := "'" + strings.ReplaceAll(repo.SSHKey, "'", `'\''`) + "'"
+		return []string{"GIT_SSH_COMMAND=ssh -i " + key + " -o IdentitiesOnly=yes"}, nil
+	}
+	return nil, nil
+}
+
+// appendConfigEnv returns the environment adding key = value to the
+// GIT_CONFIG_COUNT entries of the daemon's own environment
+func appendConfigEnv(key, value string) []string {
+	count := 0
+	if n, err := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT")); err == nil && n > 0 {
+		count = n
+	}
+	index := strconv.Itoa(count)
+	return []string{
+		"GIT_CONFIG_COUNT=" + strconv.Itoa(count+1),
+		"GIT_CONFIG_KEY_" + index + "=" + key,
+		"GIT_CONFIG_VALUE_" + index + "=" + value,
+	}
+}
+
+// httpCredentials returns the username and resolved http_token of the repository
+func httpCredentials(repo configPkg.RepoConfig) (string, string, error) {
+	token, err := secretref.Resolve(repo.HTTPToken)
+	if err != nil {
+		return "", "", fmt.Errorf("http_token: %w", err)
+	}
+	username := repo.HTTPUsername
+	if username == "" {
+		username = "git"
+	}
+	return username, token, nil
+}
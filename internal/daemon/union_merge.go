@@ -0,0 +1,113 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+const (
+	unionBlockStart = "# BEGIN git-sync union merge"
+	unionBlockEnd   = "# END git-sync union merge"
+)
+
+// EnsureUnionMergeAttributes writes a managed block to .git/info/attributes marking the
+// given path patterns with git's built-in union merge driver, so concurrent appends
+// from several machines merge without conflicts. An empty pattern list removes the block.
+// The repository-local attributes file is used so nothing is committed to the repo.
+func EnsureUnionMergeAttributes(ctx context.Context, repoPath string, patterns []string) error {
+	attributesPath, err := runGit(ctx, repoPath, "rev-parse", "--git-path", "info/attributes")
+	if err != nil {
+		return fmt.Errorf("failed to locate attributes file: %w", err)
+	}
+	if !filepath.IsAbs(attributesPath) {
+		attributesPath = filepath.Join(repoPath, attributesPath)
+	}
+
+	existing, err := os.ReadFile(attributesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read attributes file: %w", err)
+	}
+
+	updated := replaceUnionBlock(string(existing), patterns)
+	if updated == string(existing) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(attributesPath), 0755); err != nil {
+		return fmt.Errorf("failed to create info directory: %w", err)
+	}
+	if err := os.WriteFile(attributesPath, []byte(updated), 0644); err != nil {
+		return fmt.Errorf("failed to write attributes file: %w", err)
+	}
+	return nil
+}
+
+// replaceUnionBlock swaps the managed block in content, keeping all other lines
+func replaceUnionBlock(content string, patterns []string) string {
+	var kept []string
+	inBlock := false
+	for _, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		switch {
+		case line == unionBlockStart:
+			inBlock = true
+		case line == unionBlockEnd:
+			inBlock = false
+		case !inBlock && line != "":
+			kept = append(kept, line)
+		}
+	}
+
+	if len(patterns) > 0 {
+		kept = append(kept, unionBlockStart)
+		for _, pattern := range patterns {
+			kept = append(kept, pattern+" merge=union")
+		}
+		kept = append(kept, unionBlockEnd)
+	}
+
+	if len(kept) == 0 {
+		return ""
+	}
+	return strings.Join(kept, "\n") + "\n"
+}
+
+// mergeWithUnionDriver merges the fetched upstream branch using the git binary, which
+// honors merge=union attributes (go-git can only fast-forward). Conflicts in files not
+// covered by the union patterns abort the merge and leave the worktree untouched.
+func (g *GitOperations) mergeWithUnionDriver(ctx context.Context, repo configPkg.RepoConfig, branch string) error {
+	if err := EnsureUnionMergeAttributes(ctx, repo.Path, repo.UnionMergePatterns); err != nil {
+		return err
+	}
+
+	upstream := fmt.Sprintf("%s/%s", repo.Remote, branch)
+	message := fmt.Sprintf("git-sync: merge %s", upstream)
+	args := append(gitConfigArgs(repo), "merge", "--no-edit", "-m", message, upstream)
+	if _, err := runGit(ctx, repo.Path, args...); err != nil {
+		// git refuses some merges (e.g. unrelated histories) before starting one
+		if mergeInProgress(repo.Path) {
+			if _, abortErr := runGit(context.Background(), repo.Path, "merge", "--abort"); abortErr != nil {
+				g.logger.Error("Failed to abort conflicting merge",
+					"repo", repo.Path,
+					"error", abortErr)
+			}
+		}
+		return fmt.Errorf("branches diverged and could not be merged automatically (only %s use union merge): %w",
+			strings.Join(repo.UnionMergePatterns, ", "), err)
+	}
+
+	g.logger.Info("Merged diverged branch with union merge driver",
+		"repo", repo.Path,
+		"upstream", upstream)
+	return nil
+}
+
+// mergeInProgress reports whether a merge was started and left MERGE_HEAD behind
+func mergeInProgress(repoPath string) bool {
+	_, err := runGit(context.Background(), repoPath, "rev-parse", "-q", "--verify", "MERGE_HEAD")
+	return err == nil
+}
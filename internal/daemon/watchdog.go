@@ -0,0 +1,50 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+// WatchdogInterval returns how often to ping the systemd watchdog: half the
+// unit's WatchdogSec, or 0 when the unit has no watchdog
+func WatchdogInterval() time.Duration {
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil || interval <= 0 {
+		return 0
+	}
+	return interval / 2
+}
+
+// responsive reports whether the daemon and its scheduler can take their locks,
+// which a deadlock would keep them from
+func (d *Daemon) responsive() bool {
+	if !d.mu.TryRLock() {
+		return false
+	}
+	d.mu.RUnlock()
+
+	if !d.scheduler.mutex.TryRLock() {
+		return false
+	}
+	d.scheduler.mutex.RUnlock()
+	return true
+}
+
+// pingWatchdog tells systemd the daemon is alive. A wedged daemon stops pinging,
+// so systemd restarts it once WatchdogSec passes without a ping; a single miss,
+// e.g. while a reload holds a lock, is within the margin.
+func (d *Daemon) pingWatchdog() {
+	if !d.responsive() {
+		d.watchdogMisses++
+		if d.watchdogMisses > 1 {
+			d.logger.Warn("Daemon is not responding, holding back the systemd watchdog ping", "missed", d.watchdogMisses)
+		}
+		return
+	}
+	d.watchdogMisses = 0
+
+	if _, err := daemon.SdNotify(false, daemon.SdNotifyWatchdog); err != nil {
+		d.logger.Warn("Failed to ping systemd watchdog", "error", err)
+	}
+}
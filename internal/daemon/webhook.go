@@ -0,0 +1,313 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/secretref"
+)
+
+const (
+	// defaultWebhookTimeout bounds one delivery attempt
+	defaultWebhookTimeout = 10 * time.Second
+
+	// defaultWebhookAttempts is how many times a delivery is tried before it is dropped
+	defaultWebhookAttempts = 3
+
+	// webhookRetryDelay is the wait before the first retry, doubling after that
+	webhookRetryDelay = 5 * time.Second
+
+	// WebhookSignatureHeader carries "sha256=<hex HMAC of the body>" when the
+	// webhook has a secret
+	WebhookSignatureHeader = "X-Git-Sync-Signature"
+)
+
+// WebhookPayload is the JSON body POSTed to webhooks after a sync, and the
+// data their templates render
+type WebhookPayload struct {
+	Event       string      `json:"event"` // always "sync"
+	Repo        string      `json:"repo"`
+	Name        string      `json:"name"`
+	Direction   string      `json:"direction"`
+	Status      string      `json:"status"` // success, noop, partial, failed or skipped
+	Error       string      `json:"error,omitempty"`
+	ErrorKind   string      `json:"error_kind,omitempty"`
+	Progress    string      `json:"progress,omitempty"`
+	DurationMs  int64       `json:"duration_ms"`
+	Timestamp   time.Time   `json:"timestamp"`
+	Branch      string      `json:"branch,omitempty"`       // checked-out branch
+	CommitRange string      `json:"commit_range,omitempty"` // "old..new" the branch or its remote-tracking ref moved by
+	Refs        []RefUpdate `json:"refs"`                   // references the sync created, moved or deleted
+}
+
+// newWebhookPayload describes a finished sync of repo, moving the given references
+func newWebhookPayload(repo config.RepoConfig, entry SyncHistoryEntry, moved []RefUpdate) WebhookPayload {
+	payload := WebhookPayload{
+		Event:      "sync",
+		Repo:       repo.Path,
+		Name:       filepath.Base(repo.Path),
+		Direction:  entry.Direction,
+		Status:     entry.Status,
+		Error:      entry.ErrorMsg,
+		ErrorKind:  entry.ErrorKind,
+		Progress:   entry.Progress,
+		DurationMs: entry.DurationMs,
+		Timestamp:  entry.Timestamp,
+		Refs:       moved,
+	}
+	if payload.Refs == nil {
+		payload.Refs = []RefUpdate{}
+	}
+	if payload.Timestamp.IsZero() {
+		payload.Timestamp = time.Now()
+	}
+
+	r, err := openRepository(repo.Path)
+	if err != nil {
+		return payload
+	}
+	head, err := r.Head()
+	if err != nil || !head.Name().IsBranch() {
+		return payload
+	}
+	payload.Branch = head.Name().Short()
+
+	// Pulls move the branch, pushes its remote-tracking ref
+	for _, name := range []string{
+		head.Name().String(),
+		plumbing.NewRemoteReferenceName(repo.Remote, payload.Branch).String(),
+	} {
+		for _, ref := range moved {
+			if ref.Ref == name && ref.Old != "" && ref.New != "" {
+				payload.CommitRange = ref.Old + ".." + ref.New
+				return payload
+			}
+		}
+	}
+	return payload
+}
+
+// webhookTarget is a URL sync payloads are delivered to: a repository's own
+// webhook, or one of the global [[webhooks]] with its filters and template
+type webhookTarget struct {
+	url         string
+	secret      string
+	contentType string
+	template    *template.Template
+	statuses    map[string]bool // nil selects all but skipped syncs
+	repos       []string        // path or name globs; empty selects every repository
+	timeout     time.Duration
+	attempts    int
+}
+
+// label names the target in logs by scheme and host only, since webhook URLs
+// such as Slack's carry their credentials in the path
+func (t webhookTarget) label() string {
+	parsed, err := url.Parse(t.url)
+	if err != nil {
+		return "invalid URL"
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// newWebhookTargets compiles the global [[webhooks]] of the config
+func newWebhookTargets(webhooks []config.WebhookConfig) ([]webhookTarget, error) {
+	targets := make([]webhookTarget, 0, len(webhooks))
+	for i, webhook := range webhooks {
+		target := webhookTarget{
+			url:         webhook.URL,
+			secret:      webhook.Secret,
+			contentType: webhook.ContentType,
+			repos:       webhook.Repos,
+			timeout:     time.Duration(webhook.Timeout) * time.Second,
+			attempts:    webhook.Attempts,
+		}
+		if webhook.Template != "" {
+			tmpl, err := template.New("webhook").Funcs(config.WebhookTemplateFuncs).Parse(webhook.Template)
+			if err != nil {
+				return nil, fmt.Errorf("webhook %d: invalid template: %w", i, err)
+			}
+			target.template = tmpl
+		}
+		if len(webhook.Statuses) > 0 {
+			target.statuses = make(map[string]bool)
+			for _, status := range webhook.Statuses {
+				target.statuses[status] = true
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// selects reports whether the target wants the payloads of syncs of repoPath ending in status
+func (t webhookTarget) selects(repoPath, status string) bool {
+	if t.statuses == nil && status == "skipped" || t.statuses != nil && !t.statuses[status] {
+		return false
+	}
+	if len(t.repos) == 0 {
+		return true
+	}
+	for _, pattern := range t.repos {
+		if matched, _ := filepath.Match(pattern, repoPath); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Base(repoPath)); matched && !strings.Contains(pattern, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// body renders the payload for the target, through its template when it has one
+func (t webhookTarget) body(payload WebhookPayload) ([]byte, string, error) {
+	contentType := t.contentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	if t.template == nil {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode webhook payload: %w", err)
+		}
+		return body, contentType, nil
+	}
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, payload); err != nil {
+		return nil, "", fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	return buf.Bytes(), contentType, nil
+}
+
+// SetWebhooks replaces the global webhooks syncs are delivered to
+func (s *Scheduler) SetWebhooks(webhooks []config.WebhookConfig) error {
+	targets, err := newWebhookTargets(webhooks)
+	if err != nil {
+		return err
+	}
+	s.webhooksMu.Lock()
+	defer s.webhooksMu.Unlock()
+	s.webhookTargets = targets
+	return nil
+}
+
+// sendWebhook delivers the payload of a finished sync to the repository's own
+// webhook and to the global webhooks selecting it, in the background. Failed
+// deliveries are retried a few times; the repository's webhook never gets
+// skipped syncs, which didn't run.
+func (s *Scheduler) sendWebhook(repo config.RepoConfig, entry SyncHistoryEntry, moved []RefUpdate) {
+	var targets []webhookTarget
+	if repo.Webhook != "" && entry.Status != "skipped" {
+		targets = append(targets, webhookTarget{url: repo.Webhook, secret: repo.WebhookSecret})
+	}
+	s.webhooksMu.Lock()
+	for _, target := range s.webhookTargets {
+		if target.selects(repo.Path, entry.Status) {
+			targets = append(targets, target)
+		}
+	}
+	s.webhooksMu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	payload := newWebhookPayload(repo, entry, moved)
+	for _, target := range targets {
+		body, contentType, err := target.body(payload)
+		if err != nil {
+			s.logger.Error("Failed to prepare webhook", "repo", repo.Path, "webhook", target.label(), "error", err)
+			continue
+		}
+		s.webhooks.Add(1)
+		go s.deliverWebhook(repo, target, body, contentType)
+	}
+}
+
+// deliverWebhook POSTs body to the target until it is accepted or the attempts run out
+func (s *Scheduler) deliverWebhook(repo config.RepoConfig, target webhookTarget, body []byte, contentType string) {
+	defer s.webhooks.Done()
+	secret, err := secretref.Resolve(target.secret)
+	if err != nil {
+		s.logger.Warn("Failed to deliver webhook", "repo", repo.Path, "webhook", target.label(), "error", err)
+		return
+	}
+	target.secret = secret
+	attempts := target.attempts
+	if attempts <= 0 {
+		attempts = defaultWebhookAttempts
+	}
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := postWebhook(s.ctx, target, body, contentType)
+		if err == nil {
+			s.logger.Debug("Webhook delivered", "repo", repo.Path, "webhook", target.label())
+			return
+		}
+		if attempt >= attempts || s.ctx.Err() != nil {
+			s.logger.Warn("Failed to deliver webhook", "repo", repo.Path, "webhook", target.label(), "attempts", attempt, "error", err)
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-s.ctx.Done():
+			return
+		}
+		delay *= 2
+	}
+}
+
+// postWebhook POSTs body to the target once
+func postWebhook(ctx context.Context, target webhookTarget, body []byte, contentType string) error {
+	timeout := target.timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.url, bytes.NewReader(body))
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("failed to create webhook request: %w", urlErr.Err)
+		}
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "git-sync")
+	if target.secret != "" {
+		mac := hmac.New(sha256.New, []byte(target.secret))
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// Slack and Discord URLs hold their secret in the path
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("failed to send webhook: %w", urlErr.Err)
+		}
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", strings.TrimSpace(resp.Status))
+	}
+	return nil
+}
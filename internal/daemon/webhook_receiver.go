@@ -0,0 +1,277 @@
+package daemon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/secretref"
+)
+
+// PushWebhookPath is where Git hosts send push webhooks on api_listen
+const PushWebhookPath = "/webhooks/push"
+
+// maxPushWebhookBytes bounds the body of a push webhook
+const maxPushWebhookBytes = 5 << 20
+
+// pushWebhook is a webhook request from a Git host
+type pushWebhook struct {
+	host   string // github, gitlab or gitea
+	event  string
+	push   bool
+	ref    string
+	urls   []string                 // the pushed repository's URLs, normalized by remoteKey
+	verify func(secret string) bool // checks the request's signature or token
+}
+
+// pushWebhookPayload holds the fields the hosts' push payloads share
+type pushWebhookPayload struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		CloneURL   string `json:"clone_url"`
+		SSHURL     string `json:"ssh_url"`
+		HTMLURL    string `json:"html_url"`
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+		Homepage   string `json:"homepage"`
+	} `json:"repository"`
+	Project struct {
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+		WebURL     string `json:"web_url"`
+	} `json:"project"`
+}
+
+// parsePushWebhook recognizes a GitHub, GitLab or Gitea (and Forgejo) webhook
+// by its headers and reads the repository it is about from body
+func parsePushWebhook(r *http.Request, body []byte) (pushWebhook, error) {
+	var hook pushWebhook
+	switch {
+	case r.Header.Get("X-Gitlab-Event") != "":
+		hook.host = "gitlab"
+		hook.event = r.Header.Get("X-Gitlab-Event")
+		hook.push = hook.event == "Push Hook" || hook.event == "Tag Push Hook"
+		token := r.Header.Get("X-Gitlab-Token")
+		hook.verify = func(secret string) bool {
+			return secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+		}
+	case r.Header.Get("X-Gitea-Event") != "" || r.Header.Get("X-Forgejo-Event") != "":
+		// Checked before GitHub, whose headers Gitea sends too
+		hook.host = "gitea"
+		hook.event = r.Header.Get("X-Gitea-Event") + r.Header.Get("X-Forgejo-Event")
+		hook.push = strings.HasPrefix(hook.event, "push")
+		signature := r.Header.Get("X-Gitea-Signature")
+		if signature == "" {
+			signature = r.Header.Get("X-Forgejo-Signature")
+		}
+		hook.verify = func(secret string) bool {
+			return validSignature(body, signature, secret)
+		}
+	case r.Header.Get("X-GitHub-Event") != "":
+		hook.host = "github"
+		hook.event = r.Header.Get("X-GitHub-Event")
+		hook.push = hook.event == "push"
+		signature, _ := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+		hook.verify = func(secret string) bool {
+			return validSignature(body, signature, secret)
+		}
+	default:
+		return hook, errors.New("not a GitHub, GitLab or Gitea webhook")
+	}
+
+	var payload pushWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return hook, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	hook.ref = payload.Ref
+	for _, raw := range []string{
+		payload.Repository.CloneURL, payload.Repository.SSHURL, payload.Repository.HTMLURL,
+		payload.Repository.GitHTTPURL, payload.Repository.GitSSHURL, payload.Repository.Homepage,
+		payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL,
+	} {
+		if key := remoteKey(raw); key != "" {
+			hook.urls = append(hook.urls, key)
+		}
+	}
+	return hook, nil
+}
+
+// validSignature checks a hex HMAC-SHA256 signature of body
+func validSignature(body []byte, signature, secret string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil || secret == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// remoteKey reduces a repository URL to host/path, so the HTTPS, SSH and web
+// URLs of one repository compare equal: https://github.com/Owner/Repo.git,
+// git@github.com:owner/repo and ssh://git@github.com:22/owner/repo all give
+// github.com/owner/repo. Local paths give "".
+func remoteKey(raw string) string {
+	raw = strings.TrimSpace(raw)
+	var host, path string
+	if strings.Contains(raw, "://") {
+		parsed, err := url.Parse(raw)
+		if err != nil || parsed.Scheme == "file" {
+			return ""
+		}
+		host, path = parsed.Hostname(), parsed.Path
+	} else {
+		// scp-like syntax: [user@]host:path
+		var found bool
+		if host, path, found = strings.Cut(raw, ":"); !found || strings.Contains(host, "/") {
+			return ""
+		}
+		if i := strings.LastIndex(host, "@"); i >= 0 {
+			host = host[i+1:]
+		}
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || path == "" {
+		return ""
+	}
+	return strings.ToLower(host + "/" + path)
+}
+
+// pushTargets are the repositories push webhooks can trigger, with their
+// remotes and secrets worked out once per config load
+type pushTargets struct {
+	secret string // the global incoming_webhook_secret, resolved
+	repos  []pushTarget
+}
+
+// pushTarget is a repository that pulls, with the remoteKeys of its remote
+type pushTarget struct {
+	repo   config.RepoConfig
+	keys   map[string]bool
+	secret string // its incoming_webhook_secret resolved, the global one when unset
+}
+
+// loadPushTargets opens the repositories of cfg that pull to read their
+// remote URLs, and resolves the incoming webhook secrets. Without api_listen
+// nothing can send webhooks, so there is nothing to load.
+func (d *Daemon) loadPushTargets(cfg *config.Config) *pushTargets {
+	targets := &pushTargets{}
+	if cfg.Global.APIListen == "" {
+		return targets
+	}
+	resolve := func(secret string) string {
+		resolved, err := secretref.Resolve(secret)
+		if err != nil {
+			d.logger.Error("Failed to resolve incoming webhook secret", "error", err)
+			return ""
+		}
+		return resolved
+	}
+	targets.secret = resolve(cfg.Global.IncomingWebhookSecret)
+	for _, repo := range cfg.Repositories {
+		if !repo.Enabled || repo.Direction == "push" {
+			continue
+		}
+		secret := targets.secret
+		if repo.IncomingWebhookSecret != "" {
+			secret = resolve(repo.IncomingWebhookSecret)
+		}
+		targets.repos = append(targets.repos, pushTarget{repo: repo, keys: remoteKeys(repo), secret: secret})
+	}
+	return targets
+}
+
+// remoteKeys returns the remoteKey of each URL of the repository's remote
+func remoteKeys(repo config.RepoConfig) map[string]bool {
+	keys := make(map[string]bool)
+	r, err := openRepository(repo.Path)
+	if err != nil {
+		return keys
+	}
+	remote, err := r.Remote(repo.Remote)
+	if err != nil {
+		return keys
+	}
+	for _, raw := range remote.Config().URLs {
+		if key := remoteKey(raw); key != "" {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// servePushWebhook pulls the configured repositories whose remote a Git host
+// reports a push to. Requests must be signed with (GitLab: carry) the
+// incoming_webhook_secret of the repository, or the global one.
+func (d *Daemon) servePushWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushWebhookBytes))
+	if err != nil {
+		writeWebhookResponse(w, http.StatusBadRequest, control.ErrorResponse(fmt.Errorf("failed to read webhook: %w", err)))
+		return
+	}
+	hook, err := parsePushWebhook(r, body)
+	if err != nil {
+		writeWebhookResponse(w, http.StatusBadRequest, control.ErrorResponse(err))
+		return
+	}
+
+	// The global secret is checked first. Repositories with a secret of their
+	// own are matched before checking it; unmatched requests must pass the
+	// global one, so the answer never tells which remotes are configured.
+	targets := d.pushTargets.Load()
+	verified := hook.verify(targets.secret)
+	var matched []config.RepoConfig
+	for _, target := range targets.repos {
+		if !slices.ContainsFunc(hook.urls, func(key string) bool { return target.keys[key] }) {
+			continue
+		}
+		ok := verified
+		if target.secret != targets.secret {
+			ok = hook.verify(target.secret)
+		}
+		if ok {
+			verified = true
+			matched = append(matched, target.repo)
+		}
+	}
+	if !verified {
+		d.logger.Warn("Rejected push webhook with an invalid signature", "host", hook.host, "remote", r.RemoteAddr)
+		writeWebhookResponse(w, http.StatusUnauthorized, control.ErrorResponse(errors.New("invalid webhook signature")))
+		return
+	}
+
+	triggered := SyncTriggered{Repositories: []string{}}
+	if !hook.push {
+		// GitHub's ping and other events a hook may also be subscribed to
+		d.logger.Debug("Ignored webhook event", "host", hook.host, "event", hook.event)
+		writeWebhookResponse(w, http.StatusOK, control.OKResponse(triggered))
+		return
+	}
+	for _, repo := range matched {
+		if d.scheduler.Trigger(repo.Path) {
+			triggered.Repositories = append(triggered.Repositories, repo.Path)
+			d.logger.Info("Sync requested by push webhook", "repo", repo.Path, "host", hook.host, "ref", hook.ref)
+		}
+	}
+	if len(triggered.Repositories) == 0 {
+		d.logger.Debug("Push webhook matched no repository that pulls", "host", hook.host, "urls", hook.urls)
+	}
+	writeWebhookResponse(w, http.StatusOK, control.OKResponse(triggered))
+}
+
+func writeWebhookResponse(w http.ResponseWriter, status int, resp control.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
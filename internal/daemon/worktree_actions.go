@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+
+	configPkg "github.com/bnema/git-sync/internal/config"
+)
+
+const autoStashMessage = "git-sync auto-stash"
+
+// stashChanges stashes local changes (including untracked files) using the git
+// binary, since go-git has no stash support. The returned function pops the stash.
+func (g *GitOperations) stashChanges(ctx context.Context, repo configPkg.RepoConfig) (func() error, error) {
+	args := append(gitConfigArgs(repo), "stash", "push", "--include-untracked", "-m", autoStashMessage)
+	if _, err := runGit(ctx, repo.Path, args...); err != nil {
+		return nil, fmt.Errorf("failed to stash local changes: %w", err)
+	}
+
+	g.logger.Info("Stashed local changes before sync", "repo", repo.Path)
+
+	return func() error {
+		// Popping must not be interrupted by daemon shutdown, or changes stay stashed
+		popArgs := append(gitConfigArgs(repo), "stash", "pop")
+		if _, err := runGit(context.Background(), repo.Path, popArgs...); err != nil {
+			g.logger.Error("Failed to restore stashed changes",
+				"repo", repo.Path,
+				"error", err)
+			return fmt.Errorf("stash pop conflicted, local changes were kept in the stash (run 'git stash pop' manually): %w", err)
+		}
+		g.logger.Debug("Restored stashed changes", "repo", repo.Path)
+		return nil
+	}, nil
+}
+
+// commitChanges commits all local changes so they are included in the sync,
+// running the pre-commit hook first with run_hooks
+func (g *GitOperations) commitChanges(ctx context.Context, r *git.Repository, w *git.Worktree, repo configPkg.RepoConfig) error {
+	if err := w.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return fmt.Errorf("failed to stage local changes: %w", err)
+	}
+	if repo.RunHooks {
+		if err := g.runHook(ctx, repo, "pre-commit", ""); err != nil {
+			return err
+		}
+	}
+
+	author, err := commitSignature(r, repo)
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("git-sync: auto-commit %s", time.Now().Format(time.RFC3339))
+	hash, err := w.Commit(message, &git.CommitOptions{Author: author})
+	if err != nil {
+		return fmt.Errorf("failed to auto-commit local changes: %w", err)
+	}
+
+	g.logger.Info("Auto-committed local changes before sync",
+		"repo", repo.Path,
+		"commit", hash.String()[:7])
+
+	return nil
+}
+
+// runGit executes the git binary in the given directory and returns its output
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	return runGitEnv(ctx, dir, nil, args...)
+}
+
+// runGitEnv is runGit with env added to the environment git inherits
+func runGitEnv(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
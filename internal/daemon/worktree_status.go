@@ -0,0 +1,160 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+const (
+	// worktreeStatusTTL is how long a cached worktree status is shown without reading it again
+	worktreeStatusTTL = 10 * time.Second
+
+	worktreeStatusCacheFile = "worktree-status.json"
+)
+
+// ErrNoWorktree is returned for repositories without a worktree to report on
+var ErrNoWorktree = errors.New("bare repository has no worktree")
+
+// WorktreeStatus counts the local changes of a repository's worktree
+type WorktreeStatus struct {
+	Modified  int       `json:"modified"`  // tracked files changed, staged or not
+	Untracked int       `json:"untracked"` // files git doesn't track and doesn't ignore
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Clean reports whether the worktree has no local changes
+func (s WorktreeStatus) Clean() bool {
+	return s.Modified == 0 && s.Untracked == 0
+}
+
+var worktreeStatusCacheMu sync.Mutex
+
+// ReadWorktreeStatus reads the worktree status of repoPath with go-git, without
+// spawning git. Statuses are cached for a short while so repeated `status --all`
+// runs stay fast; a read taking longer than timeout returns the cached status if
+// there is one, and an error otherwise.
+func ReadWorktreeStatus(repoPath string, timeout time.Duration) (WorktreeStatus, error) {
+	cache := loadWorktreeStatusCache()
+	cached, hasCached := cache[repoPath]
+	if hasCached && time.Since(cached.CheckedAt) < worktreeStatusTTL {
+		return cached, nil
+	}
+
+	type result struct {
+		status WorktreeStatus
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		status, err := readWorktreeStatus(repoPath)
+		done <- result{status, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return WorktreeStatus{}, res.err
+		}
+		storeWorktreeStatus(repoPath, res.status)
+		return res.status, nil
+	case <-time.After(timeout):
+		if hasCached {
+			return cached, nil
+		}
+		return WorktreeStatus{}, fmt.Errorf("worktree status timed out after %s", timeout)
+	}
+}
+
+func readWorktreeStatus(repoPath string) (WorktreeStatus, error) {
+	r, err := openRepository(repoPath)
+	if err != nil {
+		return WorktreeStatus{}, fmt.Errorf("failed to open repository: %w", err)
+	}
+	w, err := r.Worktree()
+	if errors.Is(err, git.ErrIsBareRepository) {
+		return WorktreeStatus{}, ErrNoWorktree
+	}
+	if err != nil {
+		return WorktreeStatus{}, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	status, err := w.Status()
+	if err != nil {
+		return WorktreeStatus{}, fmt.Errorf("failed to get worktree status: %w", err)
+	}
+
+	summary := WorktreeStatus{CheckedAt: time.Now()}
+	for _, file := range status {
+		switch {
+		case file.Worktree == git.Untracked:
+			summary.Untracked++
+		case file.Worktree != git.Unmodified || file.Staging != git.Unmodified:
+			summary.Modified++
+		}
+	}
+	return summary, nil
+}
+
+func worktreeStatusCachePath() (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, worktreeStatusCacheFile), nil
+}
+
+// loadWorktreeStatusCache reads the cached statuses; a missing or unreadable cache is empty
+func loadWorktreeStatusCache() map[string]WorktreeStatus {
+	cache := make(map[string]WorktreeStatus)
+	path, err := worktreeStatusCachePath()
+	if err != nil {
+		return cache
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	_ = json.Unmarshal(data, &cache)
+	return cache
+}
+
+// storeWorktreeStatus caches the status of repoPath; failing to cache is not an error
+func storeWorktreeStatus(repoPath string, status WorktreeStatus) {
+	worktreeStatusCacheMu.Lock()
+	defer worktreeStatusCacheMu.Unlock()
+
+	path, err := worktreeStatusCachePath()
+	if err != nil {
+		return
+	}
+	cache := loadWorktreeStatusCache()
+	for cachedPath, cached := range cache {
+		// Forget repositories that are no longer looked at
+		if time.Since(cached.CheckedAt) > 24*time.Hour {
+			delete(cache, cachedPath)
+		}
+	}
+	cache[repoPath] = status
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+}
@@ -0,0 +1,8 @@
+// Package filelock takes advisory locks on open files, with flock on unix and
+// LockFileEx on Windows. Locks go away with the process however it exits.
+package filelock
+
+import "errors"
+
+// ErrLocked means another process holds a conflicting lock
+var ErrLocked = errors.New("file is locked by another process")
@@ -0,0 +1,37 @@
+//go:build unix
+
+package filelock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Lock takes an exclusive lock on f, waiting for other holders to let go
+func Lock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+// TryLock takes an exclusive lock on f, failing with ErrLocked when it is held
+func TryLock(f *os.File) error {
+	return tryFlock(f, syscall.LOCK_EX)
+}
+
+// TryRLock takes a shared lock on f, failing with ErrLocked when an exclusive one is held
+func TryRLock(f *os.File) error {
+	return tryFlock(f, syscall.LOCK_SH)
+}
+
+// Unlock releases the lock on f
+func Unlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+func tryFlock(f *os.File, how int) error {
+	err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrLocked
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+//go:build windows
+
+package filelock
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Windows locks keep other processes from reading the bytes they cover, so
+// lock one byte far past anything written, like the PID in the daemon lock
+const lockOffsetHigh = 0x7fffffff
+
+// Lock takes an exclusive lock on f, waiting for other holders to let go
+func Lock(f *os.File) error {
+	return lockFile(f, windows.LOCKFILE_EXCLUSIVE_LOCK)
+}
+
+// TryLock takes an exclusive lock on f, failing with ErrLocked when it is held
+func TryLock(f *os.File) error {
+	return lockFile(f, windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY)
+}
+
+// TryRLock takes a shared lock on f, failing with ErrLocked when an exclusive one is held
+func TryRLock(f *os.File) error {
+	return lockFile(f, windows.LOCKFILE_FAIL_IMMEDIATELY)
+}
+
+// Unlock releases the lock on f
+func Unlock(f *os.File) error {
+	overlapped := windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &overlapped)
+}
+
+func lockFile(f *os.File, flags uint32) error {
+	overlapped := windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, &overlapped)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return ErrLocked
+	}
+	return err
+}
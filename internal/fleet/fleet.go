@@ -0,0 +1,237 @@
+// Package fleet aggregates the sync results of many machines on one git-sync
+// daemon. Daemons with report_to send their history entries to the aggregator,
+// which keeps the latest result of every repository of every machine in its
+// cache directory.
+package fleet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+const (
+	// ReportPath is where the aggregator's HTTP API accepts reports
+	ReportPath = "/api/v1/fleet/report"
+
+	fileName = "fleet.json"
+
+	// maxReportEntries bounds a single report; reporters send larger backlogs in batches
+	maxReportEntries = 500
+	maxMachineIDLen  = 255
+)
+
+// Entry is one sync a machine reports
+type Entry struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Repo       string    `json:"repo"`
+	Direction  string    `json:"direction"`
+	Status     string    `json:"status"` // success, noop, failed or skipped
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	SkipReason string    `json:"skip_reason,omitempty"`
+}
+
+// Report is the body a machine posts to the aggregator
+type Report struct {
+	Machine string  `json:"machine"`
+	Entries []Entry `json:"entries"`
+}
+
+// Validate rejects reports the aggregator can't attribute or that are too large
+func (r Report) Validate() error {
+	if r.Machine == "" {
+		return errors.New("machine is required")
+	}
+	if len(r.Machine) > maxMachineIDLen {
+		return fmt.Errorf("machine is longer than %d characters", maxMachineIDLen)
+	}
+	if len(r.Entries) > maxReportEntries {
+		return fmt.Errorf("too many entries: %d, at most %d per report", len(r.Entries), maxReportEntries)
+	}
+	for _, entry := range r.Entries {
+		if entry.Repo == "" || entry.Timestamp.IsZero() {
+			return errors.New("entries need a repo and a timestamp")
+		}
+	}
+	return nil
+}
+
+// Repo is the latest sync result of one repository of a machine
+type Repo struct {
+	Path        string    `json:"path"`
+	Direction   string    `json:"direction"`
+	LastSync    time.Time `json:"last_sync"`
+	LastStatus  string    `json:"last_status"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	Failures    int       `json:"failures,omitempty"` // failed syncs in a row
+}
+
+// Machine is a daemon reporting to the aggregator
+type Machine struct {
+	ID         string           `json:"id"`
+	Address    string           `json:"address,omitempty"` // where the last report came from
+	LastReport time.Time        `json:"last_report"`
+	Repos      map[string]*Repo `json:"repos"`
+}
+
+// SortedRepos returns the machine's repositories by path
+func (m *Machine) SortedRepos() []*Repo {
+	repos := make([]*Repo, 0, len(m.Repos))
+	for _, repo := range m.Repos {
+		repos = append(repos, repo)
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Path < repos[j].Path })
+	return repos
+}
+
+// Fleet is everything the aggregator knows about the machines reporting to it
+type Fleet struct {
+	Machines map[string]*Machine `json:"machines"`
+}
+
+// SortedMachines returns the machines by ID
+func (f *Fleet) SortedMachines() []*Machine {
+	machines := make([]*Machine, 0, len(f.Machines))
+	for _, machine := range f.Machines {
+		machines = append(machines, machine)
+	}
+	sort.Slice(machines, func(i, j int) bool { return machines[i].ID < machines[j].ID })
+	return machines
+}
+
+// record folds report into the fleet. Entries older than a repository's last
+// sync, e.g. from a backlog resent after an outage, don't replace newer results.
+func (f *Fleet) record(report Report, addr string, now time.Time) {
+	if f.Machines == nil {
+		f.Machines = make(map[string]*Machine)
+	}
+	machine, exists := f.Machines[report.Machine]
+	if !exists {
+		machine = &Machine{ID: report.Machine, Repos: make(map[string]*Repo)}
+		f.Machines[report.Machine] = machine
+	}
+	machine.Address = addr
+	machine.LastReport = now
+
+	entries := append([]Entry(nil), report.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+	for _, entry := range entries {
+		repo, exists := machine.Repos[entry.Repo]
+		if !exists {
+			repo = &Repo{Path: entry.Repo}
+			machine.Repos[entry.Repo] = repo
+		}
+		if entry.Timestamp.Before(repo.LastSync) {
+			continue
+		}
+		repo.Direction = entry.Direction
+		repo.LastSync = entry.Timestamp
+		repo.LastStatus = entry.Status
+		repo.LastError = entry.Error
+		if entry.SkipReason != "" {
+			repo.LastError = entry.SkipReason
+		}
+		switch entry.Status {
+		case "success", "noop":
+			repo.LastSuccess = entry.Timestamp
+			repo.Failures = 0
+		case "failed":
+			repo.Failures++
+		}
+	}
+}
+
+// Load reads the fleet recorded by this user's daemon, empty if none reported yet
+func Load() (*Fleet, error) {
+	path, err := filePath()
+	if err != nil {
+		return nil, err
+	}
+	return load(path)
+}
+
+// Store records reports on the aggregator, keeping the fleet file up to date
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	fleet *Fleet
+}
+
+// OpenStore loads the fleet file for the aggregator to update
+func OpenStore() (*Store, error) {
+	path, err := filePath()
+	if err != nil {
+		return nil, err
+	}
+	fleet, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: path, fleet: fleet}, nil
+}
+
+// Record adds a report received from addr and saves the fleet file
+func (s *Store) Record(report Report, addr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fleet.record(report, addr, time.Now())
+	return s.fleet.save(s.path)
+}
+
+func filePath() (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %w", err)
+	}
+	return filepath.Join(dir, fileName), nil
+}
+
+func load(path string) (*Fleet, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Fleet{Machines: make(map[string]*Machine)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fleet file: %w", err)
+	}
+
+	var fleet Fleet
+	if err := json.Unmarshal(data, &fleet); err != nil {
+		return nil, fmt.Errorf("failed to parse fleet file %s: %w", path, err)
+	}
+	if fleet.Machines == nil {
+		fleet.Machines = make(map[string]*Machine)
+	}
+	return &fleet, nil
+}
+
+// save writes the fleet atomically so fleet status never reads a partial file
+func (f *Fleet) save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fleet: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write fleet file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace fleet file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxPending is how many entries wait while the aggregator is unreachable;
+	// the oldest are dropped beyond that
+	maxPending = 5000
+
+	// sendDelay batches the entries of syncs finishing close together, and is
+	// the first retry delay when a report fails
+	sendDelay = 5 * time.Second
+
+	// maxRetryDelay caps the backoff between failed reports
+	maxRetryDelay = 5 * time.Minute
+
+	reportTimeout = 30 * time.Second
+)
+
+// Reporter sends this machine's history entries to a fleet aggregator. Entries
+// are kept in memory until the aggregator accepts them, so a restart while it
+// is unreachable loses them.
+type Reporter struct {
+	mu      sync.Mutex
+	url     string
+	token   string
+	machine string
+	pending []Entry
+	head    int // how many entries were queued before pending[0]
+	dropped int
+
+	wake   chan struct{}
+	client *http.Client
+	logger *slog.Logger
+}
+
+// NewReporter creates a reporter that sends nothing until configured
+func NewReporter(logger *slog.Logger) *Reporter {
+	return &Reporter{
+		wake:   make(chan struct{}, 1),
+		client: &http.Client{Timeout: reportTimeout},
+		logger: logger,
+	}
+}
+
+// Configure points the reporter at the aggregator's base URL, e.g.
+// http://hub:8787; an empty URL stops reporting and drops pending entries
+func (r *Reporter) Configure(url, token, machine string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.url = strings.TrimRight(url, "/")
+	r.token = token
+	r.machine = machine
+	if r.url == "" {
+		r.head += len(r.pending)
+		r.pending = nil
+	}
+}
+
+// Add queues entry to be sent with the next report
+func (r *Reporter) Add(entry Entry) {
+	r.mu.Lock()
+	if r.url == "" {
+		r.mu.Unlock()
+		return
+	}
+	r.pending = append(r.pending, entry)
+	if over := len(r.pending) - maxPending; over > 0 {
+		r.pending = r.pending[over:]
+		r.head += over
+		r.dropped += over
+	}
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends queued entries until ctx is cancelled, backing off while the
+// aggregator fails
+func (r *Reporter) Run(ctx context.Context) {
+	delay := sendDelay
+	for {
+		select {
+		case <-r.wake:
+		case <-ctx.Done():
+			return
+		}
+
+		for {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+
+			sent, remaining, err := r.flush(ctx)
+			if err != nil {
+				delay = min(delay*2, maxRetryDelay)
+				r.logger.Warn("Failed to report syncs to fleet aggregator", "pending", remaining, "retry_in", delay, "error", err)
+				continue
+			}
+			if sent > 0 {
+				r.logger.Debug("Reported syncs to fleet aggregator", "entries", sent)
+			}
+			delay = sendDelay
+			if remaining == 0 {
+				break
+			}
+		}
+	}
+}
+
+// flush sends one batch of pending entries, returning how many were sent and
+// how many are still pending
+func (r *Reporter) flush(ctx context.Context) (int, int, error) {
+	r.mu.Lock()
+	url, token, head := r.url, r.token, r.head
+	report := Report{Machine: r.machine, Entries: append([]Entry(nil), r.pending[:min(len(r.pending), maxReportEntries)]...)}
+	dropped := r.dropped
+	r.dropped = 0
+	r.mu.Unlock()
+
+	if dropped > 0 {
+		r.logger.Warn("Dropped syncs the fleet aggregator did not receive in time", "entries", dropped)
+	}
+	if url == "" || len(report.Entries) == 0 {
+		return 0, 0, nil
+	}
+
+	err := r.send(ctx, url, token, report)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		return 0, len(r.pending), err
+	}
+	// Entries may have been queued, or dropped from the front, while sending
+	if done := min(head+len(report.Entries)-r.head, len(r.pending)); done > 0 {
+		r.pending = r.pending[done:]
+		r.head += done
+	}
+	return len(report.Entries), len(r.pending), nil
+}
+
+func (r *Reporter) send(ctx context.Context, url, token string, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+ReportPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create report request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send report: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var reply struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		if json.Unmarshal(data, &reply) == nil && reply.Error != "" {
+			return fmt.Errorf("aggregator answered %s: %s", resp.Status, reply.Error)
+		}
+		return fmt.Errorf("aggregator answered %s", resp.Status)
+	}
+	return nil
+}
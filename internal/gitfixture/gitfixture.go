@@ -0,0 +1,223 @@
+// Package gitfixture sets up throwaway git remotes and clones for tests of the
+// sync engine. Everything lives in t.TempDir and the git-sync state, cache and
+// config directories are redirected there too, so tests never touch the user's.
+//
+//	f := gitfixture.New(t)
+//	laptop, desktop := f.Clone("laptop"), f.Clone("desktop")
+//	laptop.Commit("notes.md", "draft", "Add notes")
+//	f.Sync(laptop.Repo("push"))
+//	f.Sync(desktop.Repo("pull"))
+//	desktop.RequireFile("notes.md", "draft")
+package gitfixture
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/daemon"
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+// DefaultBranch is the branch the remote starts with
+const DefaultBranch = "main"
+
+// syncTimeout bounds a single sync, so a hanging sync fails the test instead of stalling it
+const syncTimeout = 30 * time.Second
+
+// Fixture is a bare remote seeded with one commit, and the clones made of it
+type Fixture struct {
+	t      testing.TB
+	Dir    string // temporary directory holding the remote and clones
+	Remote string // path of the bare remote
+
+	syncManager *daemon.SyncManager
+}
+
+// Clone is a working clone of the fixture's remote
+type Clone struct {
+	t    testing.TB
+	f    *Fixture
+	Path string
+}
+
+// New creates a bare remote with an initial commit on DefaultBranch. Git runs
+// without the user's global and system config.
+func New(t testing.TB) *Fixture {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(dir, "gitconfig"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Fixture")
+	t.Setenv("GIT_AUTHOR_EMAIL", "fixture@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Fixture")
+	t.Setenv("GIT_COMMITTER_EMAIL", "fixture@example.com")
+	t.Setenv(paths.StateDirEnv, filepath.Join(dir, "state"))
+	t.Setenv(paths.CacheDirEnv, filepath.Join(dir, "cache"))
+	t.Setenv(paths.ConfigDirEnv, filepath.Join(dir, "config"))
+
+	f := &Fixture{
+		t:           t,
+		Dir:         dir,
+		Remote:      filepath.Join(dir, "remote.git"),
+		syncManager: daemon.NewSyncManager(1, slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}
+	f.git(dir, "init", "--quiet", "--bare", "--initial-branch="+DefaultBranch, f.Remote)
+
+	seed := f.Clone("seed")
+	seed.Git("symbolic-ref", "HEAD", "refs/heads/"+DefaultBranch)
+	seed.Commit("README.md", "fixture\n", "Initial commit")
+	seed.Git("push", "--quiet", "origin", DefaultBranch)
+	return f
+}
+
+// Clone clones the remote into a directory called name
+func (f *Fixture) Clone(name string) *Clone {
+	f.t.Helper()
+	path := filepath.Join(f.Dir, name)
+	f.git(f.Dir, "clone", "--quiet", f.Remote, path)
+	return &Clone{t: f.t, f: f, Path: path}
+}
+
+// Sync runs a single sync of repo through the sync engine
+func (f *Fixture) Sync(repo config.RepoConfig) error {
+	f.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+	return f.syncManager.SyncRepository(ctx, repo)
+}
+
+// MustSync syncs repo and fails the test if the sync fails or is skipped
+func (f *Fixture) MustSync(repo config.RepoConfig) {
+	f.t.Helper()
+	if err := f.Sync(repo); err != nil {
+		f.t.Fatalf("sync of %s failed: %v", filepath.Base(repo.Path), err)
+	}
+}
+
+// RemoteHead returns the commit branch points to on the remote, or "" if it doesn't exist
+func (f *Fixture) RemoteHead(branch string) string {
+	f.t.Helper()
+	out, err := runGit(f.Remote, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	if err != nil {
+		return ""
+	}
+	return out
+}
+
+// Repo returns the config syncing the clone in direction (push, pull or both)
+// with the current branch strategy, as `git sync init` sets it up
+func (c *Clone) Repo(direction string) config.RepoConfig {
+	return config.RepoConfig{
+		Path:           c.Path,
+		Enabled:        true,
+		Direction:      direction,
+		Interval:       300,
+		Remote:         "origin",
+		BranchStrategy: "current",
+		SafetyChecks:   true,
+	}
+}
+
+// Git runs git in the clone and returns its trimmed output, failing the test on error
+func (c *Clone) Git(args ...string) string {
+	c.t.Helper()
+	return c.f.git(c.Path, args...)
+}
+
+// WriteFile writes a file in the worktree without committing it
+func (c *Clone) WriteFile(name, content string) {
+	c.t.Helper()
+	path := filepath.Join(c.Path, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		c.t.Fatalf("failed to create directory for %s: %v", name, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		c.t.Fatalf("failed to write %s: %v", name, err)
+	}
+}
+
+// Commit writes a file and commits it, returning the new commit's hash
+func (c *Clone) Commit(name, content, message string) string {
+	c.t.Helper()
+	c.WriteFile(name, content)
+	c.Git("add", "--", name)
+	c.Git("commit", "--quiet", "--no-gpg-sign", "-m", message)
+	return c.Head()
+}
+
+// Checkout switches to branch, creating it from HEAD when create is set
+func (c *Clone) Checkout(branch string, create bool) {
+	c.t.Helper()
+	if create {
+		c.Git("checkout", "--quiet", "-b", branch)
+		return
+	}
+	c.Git("checkout", "--quiet", branch)
+}
+
+// Head returns the commit HEAD points to
+func (c *Clone) Head() string {
+	c.t.Helper()
+	return c.Git("rev-parse", "HEAD")
+}
+
+// Branch returns the checked out branch, or "HEAD" when detached
+func (c *Clone) Branch() string {
+	c.t.Helper()
+	return c.Git("rev-parse", "--abbrev-ref", "HEAD")
+}
+
+// ReadFile returns the content of a file in the worktree
+func (c *Clone) ReadFile(name string) string {
+	c.t.Helper()
+	data, err := os.ReadFile(filepath.Join(c.Path, name))
+	if err != nil {
+		c.t.Fatalf("failed to read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+// RequireFile fails the test unless the worktree has name with content
+func (c *Clone) RequireFile(name, content string) {
+	c.t.Helper()
+	if got := c.ReadFile(name); got != content {
+		c.t.Fatalf("%s in %s: got %q, want %q", name, filepath.Base(c.Path), got, content)
+	}
+}
+
+// Diverge commits conflicting changes to name in both clones and pushes a's,
+// leaving b behind the remote with a commit of its own: syncing b hits a conflict
+func Diverge(a, b *Clone, name string) {
+	a.t.Helper()
+	a.Commit(name, "from "+filepath.Base(a.Path)+"\n", "Change "+name+" in "+filepath.Base(a.Path))
+	a.Git("push", "--quiet", "origin", "HEAD")
+	b.Commit(name, "from "+filepath.Base(b.Path)+"\n", "Change "+name+" in "+filepath.Base(b.Path))
+}
+
+func (f *Fixture) git(dir string, args ...string) string {
+	f.t.Helper()
+	out, err := runGit(dir, args...)
+	if err != nil {
+		f.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return out
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,195 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTrackedMessages bounds the number of distinct messages kept in memory
+const maxTrackedMessages = 1000
+
+// DedupHandler wraps a slog.Handler and rate limits identical log records.
+// The first `burst` occurrences of a record within `window` are passed through,
+// later ones are dropped and counted. The next occurrence after the window
+// expires is emitted with a "repeated" attribute holding the dropped count.
+type DedupHandler struct {
+	next   slog.Handler
+	prefix string
+	state  *dedupState
+}
+
+type dedupState struct {
+	mu        sync.Mutex
+	burst     int
+	window    time.Duration
+	entries   map[string]*dedupEntry
+	lastPrune time.Time
+}
+
+type dedupEntry struct {
+	windowStart time.Time
+	count       int
+	suppressed  int
+
+	// The last dropped record and the handler it was for, reported with the
+	// dropped count when the entry is evicted before the message comes again
+	last    slog.Record
+	handler slog.Handler
+}
+
+// NewDedupHandler creates a deduplicating handler. A burst of 0 or less disables deduplication.
+func NewDedupHandler(next slog.Handler, burst int, window time.Duration) *DedupHandler {
+	return &DedupHandler{
+		next: next,
+		state: &dedupState{
+			burst:   burst,
+			window:  window,
+			entries: make(map[string]*dedupEntry),
+		},
+	}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level
+func (h *DedupHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record through unless it is a repeat beyond the allowed burst
+func (h *DedupHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.state.burst <= 0 {
+		return h.next.Handle(ctx, r)
+	}
+
+	key := h.recordKey(r)
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	s := h.state
+	s.mu.Lock()
+	var evicted []*dedupEntry
+	if now.Sub(s.lastPrune) > s.window {
+		evicted = s.pruneLocked(now, key)
+	}
+	entry, exists := s.entries[key]
+	if !exists || now.Sub(entry.windowStart) > s.window {
+		suppressed := 0
+		if exists {
+			suppressed = entry.suppressed
+		}
+		if !exists && len(s.entries) >= maxTrackedMessages {
+			evicted = append(evicted, s.evictOldestLocked())
+		}
+		s.entries[key] = &dedupEntry{windowStart: now, count: 1}
+		s.mu.Unlock()
+		reportEvicted(ctx, evicted)
+
+		if suppressed > 0 {
+			r = r.Clone()
+			r.AddAttrs(slog.Int("repeated", suppressed))
+		}
+		return h.next.Handle(ctx, r)
+	}
+
+	entry.count++
+	if entry.count <= s.burst {
+		s.mu.Unlock()
+		reportEvicted(ctx, evicted)
+		return h.next.Handle(ctx, r)
+	}
+	entry.suppressed++
+	entry.last = r.Clone()
+	entry.handler = h.next
+	s.mu.Unlock()
+	reportEvicted(ctx, evicted)
+	return nil
+}
+
+// WithAttrs returns a handler sharing the same dedup state
+func (h *DedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	b.WriteString(h.prefix)
+	for _, a := range attrs {
+		writeAttrKey(&b, a)
+	}
+	return &DedupHandler{next: h.next.WithAttrs(attrs), prefix: b.String(), state: h.state}
+}
+
+// WithGroup returns a handler sharing the same dedup state
+func (h *DedupHandler) WithGroup(name string) slog.Handler {
+	return &DedupHandler{next: h.next.WithGroup(name), prefix: h.prefix + name + ".", state: h.state}
+}
+
+// recordKey identifies a record by level, message and attributes. Durations and
+// timestamps are ignored so repeated failures with varying timings still match.
+func (h *DedupHandler) recordKey(r slog.Record) string {
+	var b strings.Builder
+	b.WriteString(h.prefix)
+	b.WriteString(r.Level.String())
+	b.WriteByte('|')
+	b.WriteString(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttrKey(&b, a)
+		return true
+	})
+	return b.String()
+}
+
+func writeAttrKey(b *strings.Builder, a slog.Attr) {
+	switch a.Value.Kind() {
+	case slog.KindDuration, slog.KindTime:
+		return
+	}
+	fmt.Fprintf(b, "|%s=%s", a.Key, a.Value.String())
+}
+
+// pruneLocked drops the entries whose window has expired, but the one of keep,
+// returning those with dropped records to report
+func (s *dedupState) pruneLocked(now time.Time, keep string) []*dedupEntry {
+	s.lastPrune = now
+	var evicted []*dedupEntry
+	for key, entry := range s.entries {
+		if key == keep || now.Sub(entry.windowStart) <= s.window {
+			continue
+		}
+		delete(s.entries, key)
+		if entry.suppressed > 0 {
+			evicted = append(evicted, entry)
+		}
+	}
+	return evicted
+}
+
+// evictOldestLocked drops the entry whose window started first, making room
+// when maxTrackedMessages distinct messages are all still within their window
+func (s *dedupState) evictOldestLocked() *dedupEntry {
+	var oldestKey string
+	var oldest *dedupEntry
+	for key, entry := range s.entries {
+		if oldest == nil || entry.windowStart.Before(oldest.windowStart) {
+			oldestKey, oldest = key, entry
+		}
+	}
+	delete(s.entries, oldestKey)
+	return oldest
+}
+
+// reportEvicted emits the last dropped record of each evicted entry, with the
+// count of the others dropped, so suppressed messages aren't lost with their entry
+func reportEvicted(ctx context.Context, evicted []*dedupEntry) {
+	for _, entry := range evicted {
+		if entry == nil || entry.suppressed == 0 {
+			continue
+		}
+		r := entry.last.Clone()
+		if entry.suppressed > 1 {
+			r.AddAttrs(slog.Int("repeated", entry.suppressed-1))
+		}
+		entry.handler.Handle(ctx, r)
+	}
+}
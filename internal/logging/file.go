@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// logFileBackups is how many rotated log files are kept next to the log file
+const logFileBackups = 3
+
+// RotatingFile is a log file that is rotated once it grows past its maximum
+// size: the file becomes <path>.1, older ones shift up to <path>.3
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+}
+
+// OpenRotatingFile opens the log file at path for appending, creating it and its
+// directory as needed. A maxSizeMB of 0 or less never rotates.
+func OpenRotatingFile(path string, maxSizeMB int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f := &RotatingFile{path: path, maxSize: int64(maxSizeMB) * 1024 * 1024}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first when p would take the file past its maximum size
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups up, dropping the oldest, and starts a new file
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	f.file = nil
+	for i := logFileBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return f.open()
+}
+
+// Close closes the log file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// Backups returns the rotated files of the log file at path, newest first
+func Backups(path string) []string {
+	var backups []string
+	for i := 1; i <= logFileBackups; i++ {
+		backup := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(backup); err == nil {
+			backups = append(backups, backup)
+		}
+	}
+	return backups
+}
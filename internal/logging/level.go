@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// ParseLevel converts a config log level (debug, info, warn, error) into a slog.Level
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("invalid log level '%s': must be debug, info, warn, or error", level)
+	}
+}
+
+// LevelName returns the config spelling of a slog.Level
+func LevelName(level slog.Level) string {
+	switch {
+	case level <= slog.LevelDebug:
+		return "debug"
+	case level <= slog.LevelInfo:
+		return "info"
+	case level <= slog.LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// NewHandler creates the slog handler for a config log format (text or json)
+func NewHandler(format string, w io.Writer, opts *slog.HandlerOptions) (slog.Handler, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "text":
+		return slog.NewTextHandler(w, opts), nil
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return slog.NewTextHandler(w, opts), fmt.Errorf("invalid log format '%s': must be text or json", format)
+	}
+}
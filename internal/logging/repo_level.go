@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sync"
+)
+
+// RepoKey is the attribute naming the repository a log record is about
+const RepoKey = "repo"
+
+// RepoLevelHandler wraps a slog.Handler and filters records by level, using the
+// level set for the repository in their "repo" attribute when there is one and
+// the base level otherwise
+type RepoLevelHandler struct {
+	next   slog.Handler
+	repo   string // set by WithAttrs
+	levels *RepoLevels
+}
+
+// RepoLevels holds the base level and the per-repository levels replacing it
+type RepoLevels struct {
+	base   slog.Leveler
+	mu     sync.RWMutex
+	levels map[string]slog.Level
+	lowest slog.Level // the most verbose per-repository level
+}
+
+// NewRepoLevelHandler creates a handler filtering by base unless a repository
+// level set on the returned RepoLevels applies. next should accept every level.
+func NewRepoLevelHandler(next slog.Handler, base slog.Leveler) (*RepoLevelHandler, *RepoLevels) {
+	levels := &RepoLevels{base: base}
+	return &RepoLevelHandler{next: next, levels: levels}, levels
+}
+
+// Set replaces the per-repository levels, keyed by repository path
+func (l *RepoLevels) Set(levels map[string]slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.levels = levels
+	l.lowest = slog.LevelError
+	for _, level := range levels {
+		l.lowest = min(l.lowest, level)
+	}
+}
+
+// Levels returns the per-repository levels, keyed by repository path
+func (l *RepoLevels) Levels() map[string]slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return maps.Clone(l.levels)
+}
+
+// level returns the level records about repo are filtered by
+func (l *RepoLevels) level(repo string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if level, exists := l.levels[repo]; exists && repo != "" {
+		return level
+	}
+	return l.base.Level()
+}
+
+// enabled reports whether some repository or the base level lets level through
+func (l *RepoLevels) enabled(level slog.Level) bool {
+	if level >= l.base.Level() {
+		return true
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.levels) > 0 && level >= l.lowest
+}
+
+// Enabled reports whether records at level may be logged, before knowing their repository
+func (h *RepoLevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.repo != "" {
+		return level >= h.levels.level(h.repo)
+	}
+	return h.levels.enabled(level)
+}
+
+// Handle passes the record through when its level reaches that of its repository
+func (h *RepoLevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	repo := h.repo
+	if repo == "" {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == RepoKey {
+				repo = a.Value.String()
+				return false
+			}
+			return true
+		})
+	}
+	if r.Level < h.levels.level(repo) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler sharing the same levels, bound to the repository
+// the attributes name
+func (h *RepoLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	repo := h.repo
+	for _, a := range attrs {
+		if a.Key == RepoKey {
+			repo = a.Value.String()
+		}
+	}
+	return &RepoLevelHandler{next: h.next.WithAttrs(attrs), repo: repo, levels: h.levels}
+}
+
+// WithGroup returns a handler sharing the same levels
+func (h *RepoLevelHandler) WithGroup(name string) slog.Handler {
+	return &RepoLevelHandler{next: h.next.WithGroup(name), repo: h.repo, levels: h.levels}
+}
@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus renders the snapshot in the Prometheus text exposition format
+func (s Snapshot) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	writeHeader(bw, "git_sync_start_time_seconds", "gauge", "Unix time the daemon started.")
+	fmt.Fprintf(bw, "git_sync_start_time_seconds %d\n", s.StartedAt.Unix())
+
+	writeHeader(bw, "git_sync_syncs_total", "counter", "Syncs performed by status.")
+	for _, repo := range s.Repos {
+		for _, status := range sortedKeys(repo.Syncs) {
+			fmt.Fprintf(bw, "git_sync_syncs_total{repo=%s,status=%s} %d\n",
+				quoteLabel(repo.Repo), quoteLabel(status), repo.Syncs[status])
+		}
+	}
+
+	writeHeader(bw, "git_sync_skips_total", "counter", "Skipped syncs by reason.")
+	for _, repo := range s.Repos {
+		for _, reason := range sortedKeys(repo.Skips) {
+			fmt.Fprintf(bw, "git_sync_skips_total{repo=%s,reason=%s} %d\n",
+				quoteLabel(repo.Repo), quoteLabel(reason), repo.Skips[reason])
+		}
+	}
+
+	writeHeader(bw, "git_sync_last_sync_timestamp_seconds", "gauge", "Unix time of the last sync attempt.")
+	for _, repo := range s.Repos {
+		fmt.Fprintf(bw, "git_sync_last_sync_timestamp_seconds{repo=%s} %d\n", quoteLabel(repo.Repo), repo.LastSync.Unix())
+	}
+
+	writeHeader(bw, "git_sync_last_success_timestamp_seconds", "gauge", "Unix time of the last successful sync.")
+	for _, repo := range s.Repos {
+		if repo.LastSuccess.IsZero() {
+			continue
+		}
+		fmt.Fprintf(bw, "git_sync_last_success_timestamp_seconds{repo=%s} %d\n", quoteLabel(repo.Repo), repo.LastSuccess.Unix())
+	}
+
+	writeHeader(bw, "git_sync_last_sync_duration_seconds", "gauge", "Duration of the last sync that ran.")
+	for _, repo := range s.Repos {
+		fmt.Fprintf(bw, "git_sync_last_sync_duration_seconds{repo=%s} %g\n", quoteLabel(repo.Repo), repo.LastDurationSeconds)
+	}
+
+	writeHeader(bw, "git_sync_sync_duration_seconds", "histogram", "Duration of syncs that ran.")
+	for _, repo := range s.Repos {
+		var cumulative int64
+		for i, count := range repo.DurationBuckets {
+			cumulative += count
+			bound := "+Inf"
+			if i < len(DurationBuckets) {
+				bound = strconv.FormatFloat(DurationBuckets[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(bw, "git_sync_sync_duration_seconds_bucket{repo=%s,le=%s} %d\n", quoteLabel(repo.Repo), quoteLabel(bound), cumulative)
+		}
+		fmt.Fprintf(bw, "git_sync_sync_duration_seconds_sum{repo=%s} %g\n", quoteLabel(repo.Repo), repo.DurationSecondsSum)
+		fmt.Fprintf(bw, "git_sync_sync_duration_seconds_count{repo=%s} %d\n", quoteLabel(repo.Repo), repo.DurationCount)
+	}
+
+	writeHeader(bw, "git_sync_consecutive_failures", "gauge", "Failed syncs since the last successful one.")
+	for _, repo := range s.Repos {
+		fmt.Fprintf(bw, "git_sync_consecutive_failures{repo=%s} %d\n", quoteLabel(repo.Repo), repo.ConsecutiveFailures)
+	}
+
+	writeHeader(bw, "git_sync_fetched_bytes_total", "counter", "Pack data fetched by syncs, pushes not included.")
+	for _, repo := range s.Repos {
+		fmt.Fprintf(bw, "git_sync_fetched_bytes_total{repo=%s} %d\n", quoteLabel(repo.Repo), repo.BytesFetched)
+	}
+
+	writeHeader(bw, "git_sync_queue_delays_total", "counter", "Syncs that waited for a free max_concurrent_syncs slot.")
+	for _, repo := range s.Repos {
+		fmt.Fprintf(bw, "git_sync_queue_delays_total{repo=%s} %d\n", quoteLabel(repo.Repo), repo.QueueDelays)
+	}
+
+	writeHeader(bw, "git_sync_queue_wait_seconds_total", "counter", "Time syncs spent waiting for a free max_concurrent_syncs slot.")
+	for _, repo := range s.Repos {
+		fmt.Fprintf(bw, "git_sync_queue_wait_seconds_total{repo=%s} %g\n", quoteLabel(repo.Repo), repo.QueueWaitSecondsSum)
+	}
+
+	writeHeader(bw, "git_sync_host_delays_total", "counter", "Syncs held back by max_syncs_per_host or host_sync_delay.")
+	for _, repo := range s.Repos {
+		fmt.Fprintf(bw, "git_sync_host_delays_total{repo=%s} %d\n", quoteLabel(repo.Repo), repo.HostDelays)
+	}
+
+	writeHeader(bw, "git_sync_host_wait_seconds_total", "counter", "Time syncs were held back by max_syncs_per_host or host_sync_delay.")
+	for _, repo := range s.Repos {
+		fmt.Fprintf(bw, "git_sync_host_wait_seconds_total{repo=%s} %g\n", quoteLabel(repo.Repo), repo.HostWaitSecondsSum)
+	}
+
+	if q := s.Queue; q != nil {
+		writeHeader(bw, "git_sync_running_syncs", "gauge", "Syncs holding a max_concurrent_syncs slot.")
+		fmt.Fprintf(bw, "git_sync_running_syncs %d\n", q.Running)
+		writeHeader(bw, "git_sync_queued_syncs", "gauge", "Syncs waiting for a free max_concurrent_syncs slot.")
+		fmt.Fprintf(bw, "git_sync_queued_syncs %d\n", q.Waiting)
+	}
+
+	if h := s.History; h != nil {
+		writeHeader(bw, "git_sync_history_writes_total", "counter", "Syncs recorded in history.")
+		fmt.Fprintf(bw, "git_sync_history_writes_total %d\n", h.Writes)
+		writeHeader(bw, "git_sync_history_write_failures_total", "counter", "Syncs that failed to be recorded in history.")
+		fmt.Fprintf(bw, "git_sync_history_write_failures_total %d\n", h.WriteFailures)
+		writeHeader(bw, "git_sync_history_rotations_total", "counter", "Rotations of the history file.")
+		fmt.Fprintf(bw, "git_sync_history_rotations_total %d\n", h.Rotations)
+		writeHeader(bw, "git_sync_history_file_size_bytes", "gauge", "Size of the history file.")
+		fmt.Fprintf(bw, "git_sync_history_file_size_bytes %d\n", h.FileSizeBytes)
+		writeHeader(bw, "git_sync_history_pending_entries", "gauge", "History entries kept in memory until the history file is writable.")
+		fmt.Fprintf(bw, "git_sync_history_pending_entries %d\n", h.Pending)
+		writeHeader(bw, "git_sync_history_dropped_total", "counter", "History entries lost because too many were pending.")
+		fmt.Fprintf(bw, "git_sync_history_dropped_total %d\n", h.Dropped)
+	}
+
+	return bw.Flush()
+}
+
+// WriteJSON renders the snapshot as indented JSON
+func (s Snapshot) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// WriteFile atomically replaces path with the snapshot in the given format
+// (prometheus or json). The rename keeps collectors from reading partial files.
+func (s Snapshot) WriteFile(path, format string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".git-sync-metrics-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary metrics file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	switch format {
+	case "json":
+		err = s.WriteJSON(tmp)
+	case "", "prometheus":
+		err = s.WritePrometheus(tmp)
+	default:
+		err = fmt.Errorf("unsupported metrics format: %s", format)
+	}
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set metrics file permissions: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close metrics file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace metrics file: %w", err)
+	}
+	return nil
+}
+
+func writeHeader(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// quoteLabel quotes a label value, escaping backslashes, quotes and newlines
+func quoteLabel(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+func sortedKeys(counts map[string]int64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,199 @@
+// Package metrics collects per-repository sync counters and renders them as
+// Prometheus text exposition or JSON snapshots.
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the sync duration histogram
+var DurationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+// Registry accumulates sync metrics for the lifetime of the daemon
+type Registry struct {
+	mu        sync.Mutex
+	startedAt time.Time
+	repos     map[string]*RepoMetrics
+}
+
+// RepoMetrics holds the counters for a single repository
+type RepoMetrics struct {
+	Repo                string           `json:"repo"`
+	Syncs               map[string]int64 `json:"syncs"`           // by status
+	Skips               map[string]int64 `json:"skips,omitempty"` // by skip reason
+	LastSync            time.Time        `json:"last_sync"`
+	LastSuccess         time.Time        `json:"last_success,omitempty"`
+	LastStatus          string           `json:"last_status"`
+	LastDurationSeconds float64          `json:"last_duration_seconds"`
+	DurationSecondsSum  float64          `json:"duration_seconds_sum"`
+	DurationCount       int64            `json:"duration_count"`
+	DurationBuckets     []int64          `json:"duration_buckets"` // syncs per DurationBuckets bound, not cumulative
+
+	// Failed syncs since the last successful one; skips leave it alone
+	ConsecutiveFailures int64 `json:"consecutive_failures"`
+
+	// Pack data fetched by syncs; pushes are not measured
+	BytesFetched int64 `json:"bytes_fetched"`
+
+	// Syncs that waited for one of the max_concurrent_syncs slots, and for how long in total
+	QueueDelays         int64   `json:"queue_delays"`
+	QueueWaitSecondsSum float64 `json:"queue_wait_seconds_sum"`
+
+	// Syncs held back by max_syncs_per_host or host_sync_delay, and for how long in total
+	HostDelays         int64   `json:"host_delays"`
+	HostWaitSecondsSum float64 `json:"host_wait_seconds_sum"`
+}
+
+// HistoryMetrics reports the recording of sync history
+type HistoryMetrics struct {
+	Writes        int64 `json:"writes"`
+	WriteFailures int64 `json:"write_failures"`
+	Rotations     int64 `json:"rotations"`
+	FileSizeBytes int64 `json:"file_size_bytes"`
+	Pending       int   `json:"pending"` // entries kept in memory until the file is writable
+	Dropped       int64 `json:"dropped"`
+}
+
+// QueueMetrics reports the max_concurrent_syncs slots at the time of a snapshot
+type QueueMetrics struct {
+	Running int `json:"running"`
+	Waiting int `json:"waiting"`
+}
+
+// Snapshot is a point-in-time copy of all metrics
+type Snapshot struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	StartedAt   time.Time       `json:"started_at"`
+	Repos       []RepoMetrics   `json:"repos"`
+	History     *HistoryMetrics `json:"history,omitempty"` // nil when history is disabled
+	Queue       *QueueMetrics   `json:"queue,omitempty"`
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		startedAt: time.Now(),
+		repos:     make(map[string]*RepoMetrics),
+	}
+}
+
+// ObserveSync records the outcome of one sync. Skipped syncs count towards
+// syncs and skips but not towards durations.
+func (r *Registry) ObserveSync(repo, status, skipReason string, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.repo(repo)
+
+	now := time.Now()
+	m.Syncs[status]++
+	m.LastSync = now
+	m.LastStatus = status
+
+	if skipReason != "" {
+		m.Skips[skipReason]++
+		return
+	}
+
+	if status == "success" || status == "noop" {
+		m.LastSuccess = now
+		m.ConsecutiveFailures = 0
+	} else if status == "failed" {
+		m.ConsecutiveFailures++
+	}
+	m.LastDurationSeconds = duration.Seconds()
+	m.DurationSecondsSum += duration.Seconds()
+	m.DurationCount++
+	m.DurationBuckets[sort.SearchFloat64s(DurationBuckets, duration.Seconds())]++
+}
+
+// ObserveTransfer records the pack data a sync fetched; pushes are not measured
+func (r *Registry) ObserveTransfer(repo string, fetched int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.repo(repo).BytesFetched += fetched
+}
+
+// ObserveQueueWait records a sync that had to wait for a free concurrency slot
+func (r *Registry) ObserveQueueWait(repo string, wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.repo(repo)
+	m.QueueDelays++
+	m.QueueWaitSecondsSum += wait.Seconds()
+}
+
+// ObserveHostWait records a sync held back by the per-host limits
+func (r *Registry) ObserveHostWait(repo string, wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.repo(repo)
+	m.HostDelays++
+	m.HostWaitSecondsSum += wait.Seconds()
+}
+
+// repo returns the metrics of a repository, creating them on first use; callers hold mu
+func (r *Registry) repo(repo string) *RepoMetrics {
+	m, exists := r.repos[repo]
+	if !exists {
+		m = &RepoMetrics{
+			Repo:            repo,
+			Syncs:           make(map[string]int64),
+			Skips:           make(map[string]int64),
+			DurationBuckets: make([]int64, len(DurationBuckets)+1), // the last one is +Inf
+		}
+		r.repos[repo] = m
+	}
+	return m
+}
+
+// Last returns when a repository last synced, with which status, and when it
+// last synced successfully; zero times when it hasn't synced yet
+func (r *Registry) Last(repo string) (lastSync time.Time, status string, lastSuccess time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m, exists := r.repos[repo]; exists {
+		return m.LastSync, m.LastStatus, m.LastSuccess
+	}
+	return time.Time{}, "", time.Time{}
+}
+
+// Snapshot returns a copy of the current metrics sorted by repository
+func (r *Registry) Snapshot() Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := Snapshot{
+		GeneratedAt: time.Now(),
+		StartedAt:   r.startedAt,
+		Repos:       make([]RepoMetrics, 0, len(r.repos)),
+	}
+
+	for _, m := range r.repos {
+		repoCopy := *m
+		repoCopy.Syncs = copyCounts(m.Syncs)
+		repoCopy.Skips = copyCounts(m.Skips)
+		repoCopy.DurationBuckets = append([]int64(nil), m.DurationBuckets...)
+		snapshot.Repos = append(snapshot.Repos, repoCopy)
+	}
+
+	sort.Slice(snapshot.Repos, func(i, j int) bool {
+		return snapshot.Repos[i].Repo < snapshot.Repos[j].Repo
+	})
+
+	return snapshot
+}
+
+func copyCounts(counts map[string]int64) map[string]int64 {
+	copied := make(map[string]int64, len(counts))
+	for key, value := range counts {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package multiuser
+
+import "os/exec"
+
+// runAs is never reached: NewSupervisor needs root, which non-unix systems
+// do not have, so no daemon is started as another user here
+func runAs(cmd *exec.Cmd, acct account) {}
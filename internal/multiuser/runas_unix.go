@@ -0,0 +1,16 @@
+//go:build unix
+
+package multiuser
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// runAs makes cmd run as the account, in its own process group
+func runAs(cmd *exec.Cmd, acct account) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: acct.uid, Gid: acct.gid, Groups: acct.groups},
+		Setpgid:    true,
+	}
+}
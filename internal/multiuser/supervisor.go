@@ -0,0 +1,297 @@
+// Package multiuser runs one git-sync daemon per user from a system-wide
+// supervisor, for shared machines where a single service syncs repositories
+// for several accounts. Each daemon runs as its user with that user's config.
+package multiuser
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	minRestartDelay = 1 * time.Second
+	maxRestartDelay = 1 * time.Minute
+	// A child running this long is considered healthy and resets the backoff
+	stableRunTime = 1 * time.Minute
+	stopTimeout   = 10 * time.Second
+)
+
+// UsersConfig lists the users the supervisor runs daemons for
+type UsersConfig struct {
+	Users []UserEntry `toml:"users"`
+}
+
+// UserEntry is a single user; Config defaults to the user's own git-sync config
+type UserEntry struct {
+	Name   string `toml:"name"`
+	Config string `toml:"config,omitempty"`
+}
+
+// LoadUsersConfig reads the supervisor's users file
+func LoadUsersConfig(path string) (*UsersConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users file: %w", err)
+	}
+
+	var cfg UsersConfig
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse users file: %w", err)
+	}
+	if len(cfg.Users) == 0 {
+		return nil, fmt.Errorf("users file %s lists no users", path)
+	}
+	return &cfg, nil
+}
+
+// account is a resolved system user
+type account struct {
+	name       string
+	uid, gid   uint32
+	groups     []uint32
+	home       string
+	configPath string
+}
+
+// Supervisor starts, restarts and stops the per-user daemons
+type Supervisor struct {
+	binary   string
+	accounts []account
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	children map[string]*exec.Cmd
+}
+
+// NewSupervisor resolves every configured user. It must run as root to switch uids.
+func NewSupervisor(binary string, cfg *UsersConfig, logger *slog.Logger) (*Supervisor, error) {
+	if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("multi-user mode must run as root to start daemons as other users")
+	}
+
+	seen := make(map[string]bool)
+	accounts := make([]account, 0, len(cfg.Users))
+	for _, entry := range cfg.Users {
+		if seen[entry.Name] {
+			return nil, fmt.Errorf("user '%s' is listed more than once", entry.Name)
+		}
+		seen[entry.Name] = true
+
+		acct, err := resolveAccount(entry)
+		if err != nil {
+			return nil, err
+		}
+		if acct.uid == 0 {
+			return nil, fmt.Errorf("refusing to run a daemon as root for user '%s'", entry.Name)
+		}
+		accounts = append(accounts, acct)
+	}
+
+	return &Supervisor{
+		binary:   binary,
+		accounts: accounts,
+		logger:   logger,
+		children: make(map[string]*exec.Cmd),
+	}, nil
+}
+
+// resolveAccount looks up the uid, groups and home directory of a user
+func resolveAccount(entry UserEntry) (account, error) {
+	u, err := user.Lookup(entry.Name)
+	if err != nil {
+		return account{}, fmt.Errorf("failed to look up user '%s': %w", entry.Name, err)
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return account{}, fmt.Errorf("invalid uid for user '%s': %w", entry.Name, err)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return account{}, fmt.Errorf("invalid gid for user '%s': %w", entry.Name, err)
+	}
+
+	if info, err := os.Stat(u.HomeDir); err != nil || !info.IsDir() {
+		return account{}, fmt.Errorf("home directory of user '%s' does not exist: %s", entry.Name, u.HomeDir)
+	}
+
+	var groups []uint32
+	if groupIDs, err := u.GroupIds(); err == nil {
+		for _, id := range groupIDs {
+			if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+				groups = append(groups, uint32(g))
+			}
+		}
+	}
+
+	return account{
+		name:       u.Username,
+		uid:        uint32(uid),
+		gid:        uint32(gid),
+		groups:     groups,
+		home:       u.HomeDir,
+		configPath: entry.Config,
+	}, nil
+}
+
+// Run supervises the daemons until ctx is cancelled, then stops them
+func (s *Supervisor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, acct := range s.accounts {
+		wg.Add(1)
+		go func(acct account) {
+			defer wg.Done()
+			s.superviseAccount(ctx, acct)
+		}(acct)
+	}
+	wg.Wait()
+}
+
+// Reload asks every running daemon to reload its configuration
+func (s *Supervisor) Reload() {
+	s.signalChildren(syscall.SIGHUP)
+}
+
+// superviseAccount keeps one user's daemon running, restarting it with backoff
+func (s *Supervisor) superviseAccount(ctx context.Context, acct account) {
+	delay := minRestartDelay
+	for {
+		started := time.Now()
+		err := s.runChild(ctx, acct)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) >= stableRunTime {
+			delay = minRestartDelay
+		}
+		s.logger.Warn("User daemon exited, restarting",
+			"user", acct.name,
+			"error", err,
+			"restart_in", delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		delay = min(delay*2, maxRestartDelay)
+	}
+}
+
+// runChild starts the daemon as the user and waits for it to exit. Cancelling
+// ctx sends SIGTERM, escalating to SIGKILL after stopTimeout.
+func (s *Supervisor) runChild(ctx context.Context, acct account) error {
+	args := []string{"daemon"}
+	if acct.configPath != "" {
+		args = append(args, "--config", acct.configPath)
+	}
+
+	cmd := exec.Command(s.binary, args...)
+	cmd.Dir = acct.home
+	cmd.Env = childEnv(acct)
+	runAs(cmd, acct)
+
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to capture daemon output: %w", err)
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start daemon: %w", err)
+	}
+	s.logger.Info("Started user daemon", "user", acct.name, "uid", acct.uid, "pid", cmd.Process.Pid)
+
+	s.mu.Lock()
+	s.children[acct.name] = cmd
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.children, acct.name)
+		s.mu.Unlock()
+	}()
+
+	copied := make(chan struct{})
+	go func() {
+		prefixLines(os.Stdout, output, acct.name)
+		close(copied)
+	}()
+
+	exited := make(chan error, 1)
+	go func() {
+		<-copied // Wait must follow reading the pipe to completion
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		return err
+	case <-ctx.Done():
+	}
+
+	_ = cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(stopTimeout):
+		s.logger.Warn("User daemon did not stop in time, killing it", "user", acct.name)
+		_ = cmd.Process.Kill()
+		return <-exited
+	}
+}
+
+// signalChildren sends sig to every running daemon
+func (s *Supervisor) signalChildren(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, cmd := range s.children {
+		if err := cmd.Process.Signal(sig); err != nil {
+			s.logger.Warn("Failed to signal user daemon", "user", name, "error", err)
+		}
+	}
+}
+
+// childEnv builds a minimal environment for the user's daemon. NOTIFY_SOCKET is
+// dropped so only the supervisor talks to systemd.
+func childEnv(acct account) []string {
+	env := []string{
+		"HOME=" + acct.home,
+		"USER=" + acct.name,
+		"LOGNAME=" + acct.name,
+		"PATH=" + os.Getenv("PATH"),
+	}
+
+	// The user's runtime dir keeps the control socket reachable by `git sync` as that user
+	runtimeDir := filepath.Join("/run/user", strconv.FormatUint(uint64(acct.uid), 10))
+	if info, err := os.Stat(runtimeDir); err == nil && info.IsDir() {
+		env = append(env, "XDG_RUNTIME_DIR="+runtimeDir)
+	}
+
+	return env
+}
+
+// prefixLines copies r to w line by line, tagging each line with the user
+func prefixLines(w io.Writer, r io.Reader, name string) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fmt.Fprintf(w, "user=%s %s\n", name, scanner.Text())
+	}
+	// Keep draining after an oversized line so the child never blocks on a full pipe
+	_, _ = io.Copy(io.Discard, r)
+}
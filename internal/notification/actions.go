@@ -0,0 +1,162 @@
+package notification
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Keys of the actions on sync notifications
+const (
+	ActionOpen    = "default" // clicking the notification itself
+	ActionEditor  = "editor"
+	ActionHistory = "history"
+	ActionRetry   = "retry"
+)
+
+// Editors that run in a terminal, so they are opened in one
+var terminalEditors = []string{"vi", "vim", "nvim", "nano", "micro", "hx", "helix", "kak", "joe", "ne", "mg"}
+
+// Terminal emulators tried in order when $TERMINAL isn't set, with the
+// arguments coming before the command they run
+var terminals = [][]string{
+	{"x-terminal-emulator", "-e"},
+	{"gnome-terminal", "--"},
+	{"konsole", "-e"},
+	{"xfce4-terminal", "-x"},
+	{"kitty"},
+	{"alacritty", "-e"},
+	{"foot"},
+	{"wezterm", "start", "--"},
+	{"xterm", "-e"},
+}
+
+// actionsFor returns the actions offered on a notification about a
+// repository: clicking it opens the repository in the file manager, failures
+// can be synced again or looked up in the history, and the repository can be
+// opened in $VISUAL or $EDITOR. Notification daemons that show the default
+// action as a button label it Open repo.
+func (d *desktopNotifier) actionsFor(msg Message) []Action {
+	if msg.RepoPath == "" {
+		return nil
+	}
+	d.mu.RLock()
+	retry, history := d.retry, d.history
+	d.mu.RUnlock()
+
+	actions := []Action{{Key: ActionOpen, Label: "Open repo"}}
+	if msg.Severity == SeverityCritical {
+		if retry != nil {
+			actions = append(actions, Action{Key: ActionRetry, Label: "Retry sync"})
+		}
+		if history != nil {
+			actions = append(actions, Action{Key: ActionHistory, Label: "Show history"})
+		}
+	}
+	if editorCommand() != nil {
+		actions = append(actions, Action{Key: ActionEditor, Label: "Open in editor"})
+	}
+	return actions
+}
+
+// handleAction runs the action clicked on a notification about repoPath
+func (d *desktopNotifier) handleAction(repoPath, key string) {
+	switch key {
+	case ActionOpen:
+		// The file manager, or whatever else handles directories
+		d.start(repoPath, exec.Command("xdg-open", repoPath))
+	case ActionEditor:
+		editor := editorCommand()
+		if editor == nil {
+			return
+		}
+		args := append(editor, repoPath)
+		if isTerminalEditor(editor) {
+			d.startInTerminal(repoPath, args)
+			return
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = repoPath
+		d.start(repoPath, cmd)
+	case ActionHistory:
+		d.mu.RLock()
+		history := d.history
+		d.mu.RUnlock()
+		if history == nil {
+			return
+		}
+		args := append(slices.Clone(history), "--repo", repoPath)
+		// Kept open until read, since the command exits right after printing
+		d.startInTerminal(repoPath, append([]string{"sh", "-c", `"$@"; printf '\nPress Enter to close'; read -r _`, "sh"}, args...))
+	case ActionRetry:
+		d.mu.RLock()
+		retry := d.retry
+		d.mu.RUnlock()
+		if retry == nil {
+			return
+		}
+		if retry(repoPath) {
+			d.logger.Info("Sync requested from notification", "repo", repoPath)
+		} else {
+			d.logger.Warn("Repository from notification is not scheduled anymore", "repo", repoPath)
+		}
+	}
+}
+
+// start runs a command opening something for the user, without waiting for it
+func (d *desktopNotifier) start(repoPath string, cmd *exec.Cmd) {
+	if err := cmd.Start(); err != nil {
+		d.logger.Warn("Failed to run notification action", "repo", repoPath, "command", cmd.Args[0], "error", err)
+		return
+	}
+	go cmd.Wait()
+}
+
+// startInTerminal runs args in a new terminal window, in repoPath
+func (d *desktopNotifier) startInTerminal(repoPath string, args []string) {
+	terminal := terminalCommand()
+	if terminal == nil {
+		d.logger.Warn("No terminal emulator found for notification action, set $TERMINAL", "repo", repoPath)
+		return
+	}
+	args = append(terminal, args...)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = repoPath
+	d.start(repoPath, cmd)
+}
+
+// editorCommand returns $VISUAL, or else $EDITOR, split into its arguments;
+// nil when neither is set
+func editorCommand() []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(name)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return nil
+}
+
+func isTerminalEditor(editor []string) bool {
+	name := filepath.Base(editor[0])
+	if name == "emacs" {
+		return slices.Contains(editor, "-nw") || slices.Contains(editor, "-t")
+	}
+	return slices.Contains(terminalEditors, name)
+}
+
+// terminalCommand returns the terminal emulator to run commands in, with the
+// arguments preceding the command: $TERMINAL with -e, else the first one
+// installed; nil when there is none
+func terminalCommand() []string {
+	if terminal := os.Getenv("TERMINAL"); terminal != "" {
+		return []string{terminal, "-e"}
+	}
+	for _, terminal := range terminals {
+		if _, err := exec.LookPath(terminal[0]); err == nil {
+			return slices.Clone(terminal)
+		}
+	}
+	return nil
+}
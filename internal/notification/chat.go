@@ -0,0 +1,179 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bnema/git-sync/internal/secretref"
+)
+
+// Discord rejects messages longer than this
+const discordMaxLength = 2000
+
+// slackNotifier posts to a Slack incoming webhook
+type slackNotifier struct {
+	url    string // may hold ${secret:...} references, the URL is the credential
+	client *http.Client
+}
+
+func (s *slackNotifier) Notify(ctx context.Context, msg Message) error {
+	webhook, err := secretref.Resolve(s.url)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, webhook, "", map[string]string{"text": "*" + msg.Title + "*\n" + msg.Body})
+}
+
+// discordNotifier posts to a Discord channel webhook
+type discordNotifier struct {
+	url    string // may hold ${secret:...} references, the URL is the credential
+	client *http.Client
+}
+
+func (d *discordNotifier) Notify(ctx context.Context, msg Message) error {
+	webhook, err := secretref.Resolve(d.url)
+	if err != nil {
+		return err
+	}
+	content := "**" + msg.Title + "**\n" + msg.Body
+	if len(content) > discordMaxLength {
+		content = truncateError(content, discordMaxLength)
+	}
+	return postJSON(ctx, d.client, webhook, "", map[string]string{"content": content})
+}
+
+// telegramNotifier sends messages from a Telegram bot to a chat
+type telegramNotifier struct {
+	apiURL string // empty is api.telegram.org
+	token  string
+	chatID string
+	client *http.Client
+}
+
+func (t *telegramNotifier) Notify(ctx context.Context, msg Message) error {
+	token, err := secretref.Resolve(t.token)
+	if err != nil {
+		return err
+	}
+	apiURL, err := secretref.Resolve(t.apiURL)
+	if err != nil {
+		return err
+	}
+	apiURL = strings.TrimSuffix(apiURL, "/")
+	if apiURL == "" {
+		apiURL = "https://api.telegram.org"
+	}
+	return postJSON(ctx, t.client, apiURL+"/bot"+token+"/sendMessage", "", map[string]any{
+		"chat_id":              t.chatID,
+		"text":                 msg.Title + "\n" + msg.Body,
+		"disable_notification": msg.Severity == SeverityInfo,
+	})
+}
+
+// ntfyNotifier publishes to an ntfy topic, on ntfy.sh or a self-hosted server
+type ntfyNotifier struct {
+	topicURL string // may hold ${secret:...} references, anyone knowing a topic can publish to it
+	token    string
+	client   *http.Client
+}
+
+func newNtfyNotifier(topicURL, token string, client *http.Client) (*ntfyNotifier, error) {
+	// URLs with references are checked once looked up
+	if !secretref.HasReference(topicURL) {
+		if _, _, err := splitTopic(topicURL); err != nil {
+			return nil, err
+		}
+	}
+	return &ntfyNotifier{topicURL: topicURL, token: token, client: client}, nil
+}
+
+// splitTopic returns the URL of the ntfy server and the topic of topicURL
+func splitTopic(topicURL string) (server, topic string, err error) {
+	parsed, err := url.Parse(topicURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid ntfy url: %w", err)
+	}
+	path := strings.Trim(parsed.Path, "/")
+	i := strings.LastIndex(path, "/")
+	topic = path[i+1:]
+	if topic == "" {
+		return "", "", errors.New("ntfy url doesn't name a topic")
+	}
+	parsed.Path = "/" + path[:max(i, 0)]
+	return parsed.String(), topic, nil
+}
+
+func (n *ntfyNotifier) Notify(ctx context.Context, msg Message) error {
+	token, err := secretref.Resolve(n.token)
+	if err != nil {
+		return err
+	}
+	topicURL, err := secretref.Resolve(n.topicURL)
+	if err != nil {
+		return err
+	}
+	server, topic, err := splitTopic(topicURL)
+	if err != nil {
+		return err
+	}
+	// https://docs.ntfy.sh/publish/#message-priority
+	priority, tag := 3, "white_check_mark"
+	switch msg.Severity {
+	case SeverityWarning:
+		priority, tag = 4, "warning"
+	case SeverityCritical:
+		priority, tag = 5, "x"
+	}
+	return postJSON(ctx, n.client, server, token, map[string]any{
+		"topic":    topic,
+		"title":    msg.Title,
+		"message":  msg.Body,
+		"priority": priority,
+		"tags":     []string{tag},
+	})
+}
+
+// postJSON posts payload as JSON to target, with token as a bearer token unless
+// empty, failing on any status but 2xx
+func postJSON(ctx context.Context, client *http.Client, target, token string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("failed to create request: invalid url: %w", urlErr.Err)
+		}
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "git-sync")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL may hold a token, as Telegram's does
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("failed to send notification: %w", urlErr.Err)
+		}
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("notification rejected with HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// The freedesktop notification service, implemented by every Linux desktop's notification daemon
+const (
+	dbusService   = "org.freedesktop.Notifications"
+	dbusPath      = dbus.ObjectPath("/org/freedesktop/Notifications")
+	dbusInterface = "org.freedesktop.Notifications"
+
+	dbusCallTimeout = 5 * time.Second
+)
+
+// Action is a button on a notification, passed back to its handler when clicked
+type Action struct {
+	Key, Label string
+}
+
+// dbusNotifier shows notifications through the session bus, connecting on
+// first use and again after the bus went away, and runs the handler of a
+// notification when one of its actions is clicked
+type dbusNotifier struct {
+	logger *slog.Logger
+
+	mu         sync.Mutex
+	conn       *dbus.Conn
+	hasActions bool                        // the notification daemon shows action buttons
+	handlers   map[uint32]func(key string) // by notification id, until the notification is closed
+}
+
+func newDBusNotifier(logger *slog.Logger) *dbusNotifier {
+	return &dbusNotifier{logger: logger, handlers: map[uint32]func(string){}}
+}
+
+// available reports whether a notification daemon is reachable on the session bus
+func (d *dbusNotifier) available() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.connectLocked()
+	return err == nil
+}
+
+// notify shows a notification with its actions, handle receiving the key of
+// the one clicked; actions are left out when the notification daemon has no buttons
+func (d *dbusNotifier) notify(title, body, urgency, icon string, timeout int, actions []Action, handle func(key string)) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	conn, err := d.connectLocked()
+	if err != nil {
+		return err
+	}
+
+	var flat []string
+	if d.hasActions && handle != nil {
+		for _, action := range actions {
+			flat = append(flat, action.Key, action.Label)
+		}
+	}
+	hints := map[string]dbus.Variant{
+		"urgency":       dbus.MakeVariant(urgencyLevel(urgency)),
+		"desktop-entry": dbus.MakeVariant("git-sync"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbusCallTimeout)
+	defer cancel()
+	var id uint32
+	err = conn.Object(dbusService, dbusPath).CallWithContext(ctx, dbusInterface+".Notify", 0,
+		"git-sync", uint32(0), icon, title, body, flat, hints, int32(timeout)).Store(&id)
+	if err != nil {
+		return fmt.Errorf("failed to send notification over D-Bus: %w", err)
+	}
+	if len(flat) > 0 {
+		d.handlers[id] = handle
+	}
+	return nil
+}
+
+// probeDBus reports whether a notification daemon answers on the session bus,
+// closing the connection it opens to find out
+func probeDBus() bool {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbusCallTimeout)
+	defer cancel()
+	return conn.Object(dbusService, dbusPath).CallWithContext(ctx, dbusInterface+".GetCapabilities", 0).Err == nil
+}
+
+// connectLocked returns the session bus connection, opening it when there is
+// none yet or the last one was lost; the caller holds mu
+func (d *dbusNotifier) connectLocked() (*dbus.Conn, error) {
+	if d.conn != nil && d.conn.Connected() {
+		return d.conn, nil
+	}
+	d.conn = nil
+	// Notifications of a lost connection can't be clicked anymore
+	clear(d.handlers)
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the session bus: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbusCallTimeout)
+	defer cancel()
+	var capabilities []string
+	if err := conn.Object(dbusService, dbusPath).CallWithContext(ctx, dbusInterface+".GetCapabilities", 0).Store(&capabilities); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("no notification daemon on the session bus: %w", err)
+	}
+	d.hasActions = slices.Contains(capabilities, "actions")
+
+	if d.hasActions {
+		if err := conn.AddMatchSignal(dbus.WithMatchObjectPath(dbusPath), dbus.WithMatchInterface(dbusInterface)); err != nil {
+			d.logger.Debug("Failed to subscribe to notification actions", "error", err)
+			d.hasActions = false
+		} else {
+			signals := make(chan *dbus.Signal, 16)
+			conn.Signal(signals)
+			go d.watchSignals(signals)
+		}
+	}
+	d.conn = conn
+	return conn, nil
+}
+
+// watchSignals runs the handlers of clicked actions and forgets closed
+// notifications, until the connection closes the channel
+func (d *dbusNotifier) watchSignals(signals <-chan *dbus.Signal) {
+	for signal := range signals {
+		if len(signal.Body) < 2 {
+			continue
+		}
+		id, ok := signal.Body[0].(uint32)
+		if !ok {
+			continue
+		}
+		switch signal.Name {
+		case dbusInterface + ".ActionInvoked":
+			key, _ := signal.Body[1].(string)
+			d.mu.Lock()
+			handle := d.handlers[id]
+			d.mu.Unlock()
+			if handle != nil {
+				// Handlers may notify again, which needs mu
+				go handle(key)
+			}
+		case dbusInterface + ".NotificationClosed":
+			d.mu.Lock()
+			delete(d.handlers, id)
+			d.mu.Unlock()
+		}
+	}
+}
+
+// urgencyLevel maps notify-send's urgency names to the levels of the urgency hint
+func urgencyLevel(urgency string) byte {
+	switch urgency {
+	case "low":
+		return 0
+	case "critical":
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,205 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"sync"
+)
+
+// maxPendingNotifications bounds the notifications kept while they can't be
+// shown; the oldest are dropped beyond it
+const maxPendingNotifications = 5
+
+// desktopNotifier shows notifications on the desktop of Linux, macOS or
+// Windows, holding back those that fail to show until it works again
+type desktopNotifier struct {
+	logger *slog.Logger
+
+	mu      sync.RWMutex
+	timeout int // milliseconds
+
+	// Syncs a repository right away for the Retry sync action, reporting whether it is scheduled
+	retry func(repoPath string) bool
+	// Command showing the history, --repo <path> appended, for the Show history action
+	history []string
+
+	// Notifications go over D-Bus, falling back to notify-send without a notification daemon on the session bus
+	dbus *dbusNotifier
+
+	// Notifications that failed to show, oldest first, retried before the next one
+	pendingMu sync.Mutex
+	pending   []Message
+}
+
+func newDesktopNotifier(timeout int, logger *slog.Logger) *desktopNotifier {
+	return &desktopNotifier{timeout: timeout, logger: logger, dbus: newDBusNotifier(logger)}
+}
+
+func (d *desktopNotifier) setTimeout(timeout int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.timeout = timeout
+}
+
+func (d *desktopNotifier) setRetry(retry func(repoPath string) bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.retry = retry
+}
+
+func (d *desktopNotifier) setHistoryCommand(args []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.history = args
+}
+
+// Notify shows the notifications held back by earlier failures, then msg,
+// keeping whatever failed to show (e.g. while the session bus is down) for
+// later. Without any way to show notifications, e.g. on a headless server,
+// msg is dropped.
+func (d *desktopNotifier) Notify(_ context.Context, msg Message) error {
+	if !d.available() {
+		d.logger.Debug("No way to show desktop notifications, skipping desktop notification")
+		return nil
+	}
+
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+
+	if err := d.flushPendingLocked(); err == nil {
+		err = d.send(msg, msg.Body)
+		if err == nil {
+			return nil
+		}
+		d.logger.Debug("Failed to send notification, retrying later", "error", err)
+	}
+	if len(d.pending) >= maxPendingNotifications {
+		d.pending = d.pending[1:]
+	}
+	d.pending = append(d.pending, msg)
+	return nil
+}
+
+// flushPendingLocked shows the held back notifications in order, marked with when
+// they happened, stopping at the first failure; the caller holds pendingMu
+func (d *desktopNotifier) flushPendingLocked() error {
+	for len(d.pending) > 0 {
+		msg := d.pending[0]
+		body := fmt.Sprintf("%s\n(delayed, from %s)", msg.Body, msg.At.Format("15:04"))
+		if err := d.send(msg, body); err != nil {
+			return err
+		}
+		d.pending = d.pending[1:]
+	}
+	d.pending = nil
+	return nil
+}
+
+// flushPending retries showing the notifications that failed to show
+func (d *desktopNotifier) flushPending() {
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+	if len(d.pending) == 0 || !d.available() {
+		return
+	}
+	if err := d.flushPendingLocked(); err != nil {
+		d.logger.Debug("Notifications still can't be shown", "error", err, "pending", len(d.pending))
+	}
+}
+
+// available reports whether notifications can be shown: over D-Bus or with
+// notify-send on Linux, in the Notification Center on macOS, as toasts on Windows
+func (d *desktopNotifier) available() bool {
+	switch runtime.GOOS {
+	case "linux":
+		return d.dbus.available() || isNotifySendAvailable()
+	case "darwin":
+		return macBackend() != ""
+	case "windows":
+		return isPowerShellAvailable()
+	}
+	return false
+}
+
+func isNotifySendAvailable() bool {
+	_, err := exec.LookPath("notify-send")
+	return err == nil
+}
+
+// Backend names how notifications are shown on this machine's desktop: over
+// D-Bus or with notify-send, terminal-notifier or osascript, as PowerShell
+// toasts, or not at all (an empty string)
+func Backend() string {
+	switch runtime.GOOS {
+	case "linux":
+		if probeDBus() {
+			return "D-Bus"
+		}
+		if isNotifySendAvailable() {
+			return "notify-send"
+		}
+	case "darwin":
+		return macBackend()
+	case "windows":
+		if isPowerShellAvailable() {
+			return "PowerShell toasts"
+		}
+	}
+	return ""
+}
+
+// send shows msg with body over D-Bus, with its actions, or with notify-send
+// when no notification daemon answers on the session bus; macOS and Windows
+// have their own
+func (d *desktopNotifier) send(msg Message, body string) error {
+	d.mu.RLock()
+	timeout := d.timeout
+	d.mu.RUnlock()
+	switch runtime.GOOS {
+	case "darwin":
+		return sendMac(msg, body)
+	case "windows":
+		return sendToast(msg, body, timeout)
+	}
+	urgency, icon := urgencyOf(msg.Severity), iconOf(msg.Severity)
+
+	err := d.dbus.notify(msg.Title, body, urgency, icon, timeout, d.actionsFor(msg), func(key string) {
+		d.handleAction(msg.RepoPath, key)
+	})
+	if err == nil || !isNotifySendAvailable() {
+		return err
+	}
+	d.logger.Debug("Falling back to notify-send", "error", err)
+
+	args := []string{
+		msg.Title,
+		body,
+		"--urgency", urgency,
+		"--icon", icon,
+		"--expire-time", fmt.Sprintf("%d", timeout),
+		"--app-name", "git-sync",
+	}
+
+	cmd := exec.Command("notify-send", args...)
+	return cmd.Run()
+}
+
+func urgencyOf(severity Severity) string {
+	if severity == SeverityInfo {
+		return "normal"
+	}
+	return "critical"
+}
+
+func iconOf(severity Severity) string {
+	switch severity {
+	case SeverityInfo:
+		return "dialog-information"
+	case SeverityWarning:
+		return "dialog-warning"
+	}
+	return "dialog-error"
+}
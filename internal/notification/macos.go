@@ -0,0 +1,59 @@
+package notification
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// platformCommandTimeout bounds the commands showing notifications on macOS and Windows
+const platformCommandTimeout = 10 * time.Second
+
+// osascriptNotify shows msg in the macOS Notification Center. The text goes
+// through the environment, so nothing in it needs AppleScript quoting.
+const osascriptNotify = `display notification (system attribute "GIT_SYNC_BODY") with title (system attribute "GIT_SYNC_TITLE")`
+
+// macBackend returns the command macOS notifications are shown with:
+// terminal-notifier when installed, as its notifications open the repository
+// when clicked, else osascript, which every macOS has
+func macBackend() string {
+	for _, name := range []string{"terminal-notifier", "osascript"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// sendMac shows msg with body in the macOS Notification Center
+func sendMac(msg Message, body string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), platformCommandTimeout)
+	defer cancel()
+
+	// Failures make a sound, like the critical urgency does on Linux
+	sound := ""
+	if msg.Severity == SeverityCritical {
+		sound = "Basso"
+	}
+
+	if macBackend() == "terminal-notifier" {
+		args := []string{"-title", msg.Title, "-message", body}
+		if msg.RepoPath != "" {
+			// A repository's newest notification replaces its older ones
+			args = append(args, "-group", "git-sync "+msg.RepoPath, "-open", "file://"+msg.RepoPath)
+		}
+		if sound != "" {
+			args = append(args, "-sound", sound)
+		}
+		return exec.CommandContext(ctx, "terminal-notifier", args...).Run()
+	}
+
+	script := osascriptNotify
+	if sound != "" {
+		script += ` sound name "` + sound + `"`
+	}
+	cmd := exec.CommandContext(ctx, "osascript", "-e", script)
+	cmd.Env = append(os.Environ(), "GIT_SYNC_TITLE="+msg.Title, "GIT_SYNC_BODY="+body)
+	return cmd.Run()
+}
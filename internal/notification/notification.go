@@ -1,58 +1,168 @@
 package notification
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
-	"os/exec"
-	"runtime"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/bnema/git-sync/internal/config"
 )
 
 type NotificationManager struct {
-	enabled bool
-	timeout int // milliseconds
+	mu      sync.RWMutex
+	enabled bool // desktop notifications, enable_notifications
 	logger  *slog.Logger
+
+	// The desktop, and the [[notifiers]] sections, each taking notifications from its min_severity on
+	desktop   *desktopNotifier
+	notifiers []routedNotifier
+
+	// Deliveries to notifiers still in flight
+	sends sync.WaitGroup
+
+	// Which sync results are shown, see SetPolicy
+	policy string
+
+	// Whether each repository's last sync failed, for the transitions policy
+	statusMu sync.Mutex
+	failing  map[string]bool
+
+	// Results held back for the digest policy, shown together once the wave of syncs ends
+	digestMu    sync.Mutex
+	digest      []syncResult
+	digestTimer *time.Timer
+	digestStart time.Time
 }
 
 func NewNotificationManager(enabled bool, timeout int, logger *slog.Logger) *NotificationManager {
 	return &NotificationManager{
 		enabled: enabled,
-		timeout: timeout,
 		logger:  logger,
+		policy:  PolicyAll,
+		desktop: newDesktopNotifier(timeout, logger),
+	}
+}
+
+// Configure turns desktop notifications on or off and sets how long they show,
+// taking effect for the next notification; the notifiers aren't affected
+func (nm *NotificationManager) Configure(enabled bool, timeout int) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	nm.enabled = enabled
+	nm.desktop.setTimeout(timeout)
+}
+
+// SetNotifiers replaces the notifiers notifications are sent to besides the
+// desktop, keeping the current ones when one of the sections is invalid
+func (nm *NotificationManager) SetNotifiers(configs []config.NotifierConfig) error {
+	notifiers, err := buildNotifiers(configs)
+	if err != nil {
+		return err
 	}
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	nm.notifiers = notifiers
+	return nil
+}
+
+// SetRetryHandler sets what the Retry sync action on failure notifications
+// runs; without one, the action isn't offered
+func (nm *NotificationManager) SetRetryHandler(retry func(repoPath string) bool) {
+	nm.desktop.setRetry(retry)
+}
+
+// SetHistoryCommand sets the command the Show history action runs in a
+// terminal, with --repo and the repository appended; without one, the action
+// isn't offered
+func (nm *NotificationManager) SetHistoryCommand(args []string) {
+	nm.desktop.setHistoryCommand(args)
+}
+
+func (nm *NotificationManager) isEnabled() bool {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+	return nm.enabled
+}
+
+// hasTargets reports whether notifications go anywhere: the desktop or a notifier
+func (nm *NotificationManager) hasTargets() bool {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+	return nm.enabled || len(nm.notifiers) > 0
 }
 
 func (nm *NotificationManager) SendSyncNotification(repoPath, direction, status string, duration time.Duration, errorMsg string) {
-	if !nm.enabled {
+	if !nm.hasTargets() {
 		return
 	}
-	
-	// Check if notify-send is available
-	if !nm.isNotifySendAvailable() {
-		nm.logger.Debug("notify-send not available, skipping notification")
+
+	status, show := nm.admit(repoPath, direction, status, errorMsg)
+	if !show {
 		return
 	}
 	
 	// Prepare notification details
-	title := nm.buildTitle(repoPath, status)
-	body := nm.buildBody(direction, duration, errorMsg)
-	urgency := nm.getUrgency(status)
-	icon := nm.getIcon(status)
-	
-	// Send notification
-	err := nm.sendNotification(title, body, urgency, icon)
-	if err != nil {
-		nm.logger.Debug("Failed to send notification", "error", err)
+	msg := Message{
+		Title:    nm.buildTitle(repoPath, status),
+		Body:     nm.buildBody(direction, duration, errorMsg),
+		Severity: severityOf(status),
+		At:       time.Now(),
+	}
+	// Sync sets are named by their set, not a path
+	if direction != "set" {
+		msg.RepoPath = repoPath
 	}
+	nm.dispatch(msg)
 }
 
-func (nm *NotificationManager) isNotifySendAvailable() bool {
-	if runtime.GOOS != "linux" {
-		return false
+// SendAlert sends a critical notification about the daemon itself rather than a sync
+func (nm *NotificationManager) SendAlert(title, body string) {
+	if !nm.hasTargets() {
+		return
+	}
+	nm.dispatch(Message{Title: title, Body: body, Severity: SeverityCritical, At: time.Now()})
+}
+
+// dispatch shows msg on the desktop unless desktop notifications are off and
+// sends it to the notifiers taking its severity, in the background so a slow
+// mail server doesn't hold up syncs
+func (nm *NotificationManager) dispatch(msg Message) {
+	if nm.isEnabled() {
+		nm.desktop.Notify(context.Background(), msg)
+	}
+
+	nm.mu.RLock()
+	notifiers := nm.notifiers
+	nm.mu.RUnlock()
+	for _, routed := range notifiers {
+		if msg.Severity < routed.minSeverity {
+			continue
+		}
+		nm.sends.Add(1)
+		go func() {
+			defer nm.sends.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+			defer cancel()
+			if err := routed.notifier.Notify(ctx, msg); err != nil {
+				nm.logger.Warn("Failed to send notification", "notifier", routed.name, "title", msg.Title, "error", err)
+			}
+		}()
+	}
+}
+
+// Wait returns once the notifications being sent to notifiers are delivered or failed
+func (nm *NotificationManager) Wait() {
+	nm.sends.Wait()
+}
+
+// FlushPending retries showing the desktop notifications that failed to show
+func (nm *NotificationManager) FlushPending() {
+	if nm.isEnabled() {
+		nm.desktop.flushPending()
 	}
-	_, err := exec.LookPath("notify-send")
-	return err == nil
 }
 
 func (nm *NotificationManager) buildTitle(repoPath, status string) string {
@@ -60,6 +170,15 @@ func (nm *NotificationManager) buildTitle(repoPath, status string) string {
 	if status == "success" {
 		return fmt.Sprintf("✓ Git Sync: %s", repoName)
 	}
+	if status == "recovered" {
+		return fmt.Sprintf("✓ Git Sync Recovered: %s", repoName)
+	}
+	if status == "blocked" {
+		return fmt.Sprintf("⚠ Git Sync Push Blocked: %s", repoName)
+	}
+	if status == "paused" {
+		return fmt.Sprintf("✗ Git Sync Paused: %s", repoName)
+	}
 	return fmt.Sprintf("✗ Git Sync Failed: %s", repoName)
 }
 
@@ -72,34 +191,6 @@ func (nm *NotificationManager) buildBody(direction string, duration time.Duratio
 		direction, formatDuration(duration))
 }
 
-func (nm *NotificationManager) getUrgency(status string) string {
-	if status == "success" {
-		return "normal"
-	}
-	return "critical"
-}
-
-func (nm *NotificationManager) getIcon(status string) string {
-	if status == "success" {
-		return "dialog-information"
-	}
-	return "dialog-error"
-}
-
-func (nm *NotificationManager) sendNotification(title, body, urgency, icon string) error {
-	args := []string{
-		title,
-		body,
-		"--urgency", urgency,
-		"--icon", icon,
-		"--expire-time", fmt.Sprintf("%d", nm.timeout),
-		"--app-name", "git-sync",
-	}
-	
-	cmd := exec.Command("notify-send", args...)
-	return cmd.Run()
-}
-
 // Helper functions
 func getRepoName(path string) string {
 	parts := strings.Split(path, "/")
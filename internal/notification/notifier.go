@@ -0,0 +1,122 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bnema/git-sync/internal/config"
+)
+
+// Severity ranks notifications, so each notifier can take only the ones that matter to it
+type Severity int
+
+const (
+	SeverityInfo     Severity = iota // syncs that worked, recoveries
+	SeverityWarning                  // pushes blocked until the user acts
+	SeverityCritical                 // failures, pauses and alerts about the daemon
+)
+
+// ParseSeverity returns the severity of a min_severity setting, info when empty
+func ParseSeverity(name string) (Severity, error) {
+	switch name {
+	case "", "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "critical":
+		return SeverityCritical, nil
+	}
+	return 0, fmt.Errorf("invalid severity '%s': must be info, warning or critical", name)
+}
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	}
+	return "info"
+}
+
+// Message is a notification as every notifier gets it
+type Message struct {
+	Title, Body string
+	Severity    Severity
+	RepoPath    string // the repository a sync notification is about, empty for others
+	At          time.Time
+}
+
+// Notifier shows or sends notifications somewhere: the desktop, a chat, a mailbox
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// remoteTimeout bounds each delivery to a remote notifier
+const remoteTimeout = 30 * time.Second
+
+// routedNotifier is a configured notifier with the least severity it takes
+type routedNotifier struct {
+	name        string
+	minSeverity Severity
+	notifier    Notifier
+}
+
+// NewNotifier returns the notification backend a [[notifiers]] section configures
+func NewNotifier(cfg config.NotifierConfig) (Notifier, error) {
+	client := &http.Client{Timeout: remoteTimeout}
+	switch cfg.Type {
+	case "slack":
+		return &slackNotifier{url: cfg.URL, client: client}, nil
+	case "discord":
+		return &discordNotifier{url: cfg.URL, client: client}, nil
+	case "telegram":
+		return &telegramNotifier{apiURL: cfg.URL, token: cfg.Token, chatID: cfg.ChatID, client: client}, nil
+	case "ntfy":
+		return newNtfyNotifier(cfg.URL, cfg.Token, client)
+	case "smtp":
+		return &smtpNotifier{
+			host:     cfg.SMTPHost,
+			port:     cfg.SMTPPort,
+			username: cfg.Username,
+			password: cfg.Password,
+			from:     cfg.From,
+			to:       cfg.To,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier type '%s'", cfg.Type)
+}
+
+// buildNotifiers returns the notifiers of the [[notifiers]] sections, in order
+func buildNotifiers(configs []config.NotifierConfig) ([]routedNotifier, error) {
+	var notifiers []routedNotifier
+	for i, cfg := range configs {
+		minSeverity, err := ParseSeverity(cfg.MinSeverity)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %d: %w", i, err)
+		}
+		notifier, err := NewNotifier(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %d: %w", i, err)
+		}
+		notifiers = append(notifiers, routedNotifier{
+			name:        fmt.Sprintf("%d (%s)", i, cfg.Type),
+			minSeverity: minSeverity,
+			notifier:    notifier,
+		})
+	}
+	return notifiers, nil
+}
+
+// severityOf returns the severity of a sync notification with status
+func severityOf(status string) Severity {
+	switch {
+	case status == "blocked":
+		return SeverityWarning
+	case isFailure(status):
+		return SeverityCritical
+	}
+	return SeverityInfo
+}
@@ -0,0 +1,185 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Notification policies, deciding which sync results are shown and how
+const (
+	PolicyAll          = "all"           // every result, one notification each
+	PolicyFailuresOnly = "failures-only" // failures, pauses and blocked pushes
+	PolicyTransitions  = "transitions"   // a repository starting to fail, and recovering
+	PolicyDigest       = "digest"        // one summary for each wave of syncs
+)
+
+const (
+	// A wave of syncs ends once no result came in for digestQuiet, or after digestMaxDelay
+	digestQuiet    = 10 * time.Second
+	digestMaxDelay = time.Minute
+
+	// Failures listed in a digest by name, the rest are counted
+	digestMaxLines = 5
+)
+
+// syncResult is a sync result waiting for the digest
+type syncResult struct {
+	repoPath, status, errorMsg string
+}
+
+// SetPolicy sets which sync results are shown, taking effect for the next
+// one; a digest being collected is shown right away when leaving digest mode
+func (nm *NotificationManager) SetPolicy(policy string) {
+	nm.mu.Lock()
+	nm.policy = policy
+	nm.mu.Unlock()
+	if policy != PolicyDigest {
+		nm.FlushDigest()
+	}
+}
+
+func (nm *NotificationManager) getPolicy() string {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+	return nm.policy
+}
+
+// RecordStatus notes the result of a sync that sends no notification, so
+// transitions see the repository failing or recovering anyway
+func (nm *NotificationManager) RecordStatus(repoPath, status string) {
+	nm.swapFailing(repoPath, isFailure(status))
+}
+
+// admit applies the policy to a result, telling whether it is shown now and
+// with which status; digested results are held back for the digest
+func (nm *NotificationManager) admit(repoPath, direction, status, errorMsg string) (string, bool) {
+	// Mirror steps and sync sets report their own failures once, outside the repository's results
+	tracked := direction != "mirror" && direction != "set"
+	var wasFailing, known bool
+	if tracked {
+		wasFailing, known = nm.swapFailing(repoPath, isFailure(status))
+	}
+
+	// Pauses and blocked pushes need the user to act, so every policy shows them right away
+	if status == "paused" || status == "blocked" {
+		return status, true
+	}
+
+	switch nm.getPolicy() {
+	case PolicyFailuresOnly:
+		return status, isFailure(status)
+	case PolicyTransitions:
+		if !tracked {
+			return status, true
+		}
+		if isFailure(status) {
+			return status, !known || !wasFailing
+		}
+		return "recovered", known && wasFailing
+	case PolicyDigest:
+		if status != "noop" {
+			nm.addToDigest(syncResult{repoPath: repoPath, status: status, errorMsg: errorMsg})
+		}
+		return status, false
+	}
+	// Syncs finding nothing to do were never shown
+	return status, status != "noop"
+}
+
+// swapFailing records whether the repository is failing, returning what was
+// recorded before and whether anything was
+func (nm *NotificationManager) swapFailing(repoPath string, failing bool) (bool, bool) {
+	nm.statusMu.Lock()
+	defer nm.statusMu.Unlock()
+	if nm.failing == nil {
+		nm.failing = map[string]bool{}
+	}
+	was, known := nm.failing[repoPath]
+	nm.failing[repoPath] = failing
+	return was, known
+}
+
+// isFailure reports whether a sync status is one notifications report as a problem
+func isFailure(status string) bool {
+	return status != "success" && status != "noop" && status != "recovered"
+}
+
+// addToDigest holds a result back for the digest of the current wave of syncs
+func (nm *NotificationManager) addToDigest(result syncResult) {
+	nm.digestMu.Lock()
+	defer nm.digestMu.Unlock()
+	nm.digest = append(nm.digest, result)
+	switch {
+	case nm.digestTimer == nil:
+		nm.digestStart = time.Now()
+		nm.digestTimer = time.AfterFunc(digestQuiet, nm.FlushDigest)
+	case time.Since(nm.digestStart) < digestMaxDelay-digestQuiet:
+		nm.digestTimer.Reset(digestQuiet)
+	}
+}
+
+// FlushDigest shows the results held back for the digest as one notification,
+// e.g. when the daemon stops
+func (nm *NotificationManager) FlushDigest() {
+	nm.digestMu.Lock()
+	results := nm.digest
+	nm.digest = nil
+	if nm.digestTimer != nil {
+		nm.digestTimer.Stop()
+		nm.digestTimer = nil
+	}
+	nm.digestMu.Unlock()
+
+	if len(results) == 0 || !nm.hasTargets() {
+		return
+	}
+	title, body, failed := buildDigest(results)
+	severity := SeverityInfo
+	if failed {
+		severity = SeverityCritical
+	}
+	nm.dispatch(Message{Title: title, Body: body, Severity: severity, At: time.Now()})
+}
+
+// buildDigest summarizes a wave of results: counts in the title, failures by
+// name in the body, then the repositories that synced
+func buildDigest(results []syncResult) (title, body string, failed bool) {
+	var synced []string
+	var failures []string
+	for _, result := range results {
+		name := getRepoName(result.repoPath)
+		if !isFailure(result.status) {
+			synced = append(synced, name)
+			continue
+		}
+		line := fmt.Sprintf("✗ %s: %s", name, result.status)
+		if result.errorMsg != "" {
+			line = fmt.Sprintf("✗ %s: %s", name, truncateError(result.errorMsg, 80))
+		}
+		failures = append(failures, line)
+	}
+
+	var counts []string
+	if len(synced) > 0 {
+		counts = append(counts, fmt.Sprintf("%d synced", len(synced)))
+	}
+	if len(failures) > 0 {
+		counts = append(counts, fmt.Sprintf("%d failed", len(failures)))
+	}
+	title = "Git Sync: " + strings.Join(counts, ", ")
+	if len(failures) > 0 {
+		title = "✗ " + title
+	} else {
+		title = "✓ " + title
+	}
+
+	lines := failures
+	if len(lines) > digestMaxLines {
+		lines = append(lines[:digestMaxLines:digestMaxLines], fmt.Sprintf("and %d more", len(failures)-digestMaxLines))
+	}
+	if len(synced) > 0 {
+		lines = append(lines, "✓ "+strings.Join(synced, ", "))
+	}
+	return title, strings.Join(lines, "\n"), len(failures) > 0
+}
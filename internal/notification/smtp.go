@@ -0,0 +1,121 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bnema/git-sync/internal/secretref"
+)
+
+// smtpNotifier mails notifications through an SMTP server
+type smtpNotifier struct {
+	host     string
+	port     int // 0 is 587
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func (s *smtpNotifier) Notify(ctx context.Context, msg Message) error {
+	password, err := secretref.Resolve(s.password)
+	if err != nil {
+		return err
+	}
+	port := s.port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(s.host, strconv.Itoa(port))
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if port == 465 {
+		// SMTPS: TLS from the start rather than STARTTLS
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to talk to %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && port != 465 {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("failed to start TLS with %s: %w", addr, err)
+		}
+	}
+	if s.username != "" {
+		// PlainAuth refuses to send the password without TLS, except to localhost
+		if err := client.Auth(smtp.PlainAuth("", s.username, password, s.host)); err != nil {
+			return fmt.Errorf("failed to authenticate to %s: %w", addr, err)
+		}
+	}
+	if err := client.Mail(envelopeAddress(s.from)); err != nil {
+		return fmt.Errorf("server refused sender %s: %w", s.from, err)
+	}
+	for _, to := range s.to {
+		if err := client.Rcpt(envelopeAddress(to)); err != nil {
+			return fmt.Errorf("server refused recipient %s: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	if _, err := w.Write(s.message(msg)); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return client.Quit()
+}
+
+// envelopeAddress returns the bare address of e.g. "Git Sync <git-sync@example.com>"
+func envelopeAddress(address string) string {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		return parsed.Address
+	}
+	return address
+}
+
+// message returns the mail of msg, headers included
+func (s *smtpNotifier) message(msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(s.to, ", "))
+	// Titles start with ✓ or ✗, which headers can only carry encoded
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Title))
+	fmt.Fprintf(&buf, "Date: %s\r\n", msg.At.Format(time.RFC1123Z))
+	if msg.Severity == SeverityCritical {
+		buf.WriteString("X-Priority: 1\r\n")
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
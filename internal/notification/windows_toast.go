@@ -0,0 +1,54 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// toastAppID is the app Windows shows toasts as coming from; toasts need a
+// registered app, and Windows PowerShell's is on every installation
+const toastAppID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// toastScript shows a toast with the title and body in the environment, so
+// nothing in them needs PowerShell quoting. Loading WinRT types this way only
+// works in Windows PowerShell, not PowerShell 7.
+const toastScript = `$ErrorActionPreference = 'Stop'
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:GIT_SYNC_TITLE)) > $null
+$text.Item(1).AppendChild($template.CreateTextNode($env:GIT_SYNC_BODY)) > $null
+$toast = [Windows.UI.Notifications.ToastNotification]::new($template)
+if ($env:GIT_SYNC_EXPIRE) { $toast.ExpirationTime = [DateTimeOffset]::Now.AddMilliseconds([int]$env:GIT_SYNC_EXPIRE) }
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($env:GIT_SYNC_APP_ID).Show($toast)`
+
+func isPowerShellAvailable() bool {
+	_, err := exec.LookPath("powershell.exe")
+	return err == nil
+}
+
+// sendToast shows msg with body as a Windows toast notification, removed
+// from the Action Center after timeout milliseconds
+func sendToast(msg Message, body string, timeout int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), platformCommandTimeout)
+	defer cancel()
+
+	expire := ""
+	if timeout > 0 {
+		expire = fmt.Sprint(timeout)
+	}
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-Command", toastScript)
+	cmd.Env = append(os.Environ(),
+		"GIT_SYNC_TITLE="+msg.Title, "GIT_SYNC_BODY="+body, "GIT_SYNC_EXPIRE="+expire, "GIT_SYNC_APP_ID="+toastAppID)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if detail := strings.TrimSpace(string(output)); detail != "" {
+			return fmt.Errorf("%w: %s", err, detail)
+		}
+		return err
+	}
+	return nil
+}
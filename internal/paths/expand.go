@@ -0,0 +1,41 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// xdgDefaults are the XDG base directories, relative to the home directory, used when unset
+var xdgDefaults = map[string]string{
+	"XDG_CONFIG_HOME": ".config",
+	"XDG_CACHE_HOME":  ".cache",
+	"XDG_DATA_HOME":   filepath.Join(".local", "share"),
+	"XDG_STATE_HOME":  filepath.Join(".local", "state"),
+}
+
+// Expand replaces a leading ~ with the home directory and $VAR or ${VAR} with
+// the environment variable, so a config can name paths the same way on every
+// machine. The XDG base directories fall back to their defaults when unset;
+// other unset variables are left as ${VAR}.
+func Expand(path string) string {
+	if !strings.ContainsAny(path, "~$") {
+		return path
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + path[1:]
+		}
+	}
+	return os.Expand(path, func(name string) string {
+		if fallback, ok := xdgDefaults[name]; ok {
+			if dir, err := xdgDir(name, fallback); err == nil {
+				return dir
+			}
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		return "${" + name + "}"
+	})
+}
@@ -0,0 +1,101 @@
+// Package paths resolves git-sync's config, cache and state directories following the
+// XDG base directory specification, with explicit overrides from flags and env.
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// ConfigDirEnv overrides the git-sync config directory
+	ConfigDirEnv = "GIT_SYNC_CONFIG_DIR"
+	// CacheDirEnv overrides the git-sync cache directory
+	CacheDirEnv = "GIT_SYNC_CACHE_DIR"
+	// StateDirEnv overrides the git-sync state directory
+	StateDirEnv = "GIT_SYNC_STATE_DIR"
+
+	appName = "git-sync"
+)
+
+var (
+	configDirOverride string
+	cacheDirOverride  string
+)
+
+// SetConfigDir overrides the config directory for this process (e.g. from --config-dir)
+func SetConfigDir(dir string) {
+	configDirOverride = dir
+}
+
+// SetCacheDir overrides the cache directory for this process (e.g. from --cache-dir)
+func SetCacheDir(dir string) {
+	cacheDirOverride = dir
+}
+
+// ConfigDir returns the git-sync config directory: the override, $GIT_SYNC_CONFIG_DIR,
+// $XDG_CONFIG_HOME/git-sync, or ~/.config/git-sync
+func ConfigDir() (string, error) {
+	if dir := firstNonEmpty(configDirOverride, os.Getenv(ConfigDirEnv)); dir != "" {
+		return filepath.Abs(dir)
+	}
+	base, err := XDGConfigHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, appName), nil
+}
+
+// CacheDir returns the git-sync cache directory: the override, $GIT_SYNC_CACHE_DIR,
+// $XDG_CACHE_HOME/git-sync, or ~/.cache/git-sync
+func CacheDir() (string, error) {
+	if dir := firstNonEmpty(cacheDirOverride, os.Getenv(CacheDirEnv)); dir != "" {
+		return filepath.Abs(dir)
+	}
+	base, err := xdgDir("XDG_CACHE_HOME", ".cache")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, appName), nil
+}
+
+// StateDir returns the git-sync state directory: $GIT_SYNC_STATE_DIR,
+// $XDG_STATE_HOME/git-sync, or ~/.local/state/git-sync
+func StateDir() (string, error) {
+	if dir := os.Getenv(StateDirEnv); dir != "" {
+		return filepath.Abs(dir)
+	}
+	base, err := xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, appName), nil
+}
+
+// XDGConfigHome returns $XDG_CONFIG_HOME or ~/.config
+func XDGConfigHome() (string, error) {
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+// xdgDir reads an XDG base directory variable, falling back to a directory in
+// the home directory. Relative values are invalid per the spec and ignored.
+func xdgDir(envVar, homeFallback string) (string, error) {
+	if dir := os.Getenv(envVar); dir != "" && filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, homeFallback), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
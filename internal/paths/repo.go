@@ -0,0 +1,43 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeRepo cleans a repository path for storage and comparison: absolute,
+// without trailing slashes or . and .. elements, and in Unicode NFC, the form
+// most tools write even where the filesystem hands out NFD names (macOS)
+func NormalizeRepo(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return norm.NFC.String(filepath.Clean(path))
+}
+
+// SameRepo reports whether a and b name the same repository. Paths that still
+// differ once normalized are the same when they resolve to the same directory,
+// as with different letter case on case-insensitive filesystems or symlinks.
+func SameRepo(a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+	if NormalizeRepo(a) == NormalizeRepo(b) {
+		return true
+	}
+
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
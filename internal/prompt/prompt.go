@@ -2,8 +2,11 @@ package prompt
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -171,7 +174,8 @@ func (p *Prompter) SelectWithDefault(question string, options []string, defaultI
 	}
 }
 
-// MultiSelect prompts for multiple choices (space-separated indices)
+// MultiSelect prompts for multiple choices (space-separated indices, ranges
+// such as 2-5, or "all")
 func (p *Prompter) MultiSelect(question string, options []string) []int {
 	fmt.Println(question)
 	for i, option := range options {
@@ -179,9 +183,14 @@ func (p *Prompter) MultiSelect(question string, options []string) []int {
 	}
 	
 	for {
-		fmt.Printf("Enter choices (space-separated, e.g., '1 3 5'): ")
+		fmt.Printf("Enter choices (space-separated, e.g., '1 3 5', '2-4' or 'all'): ")
 		input, err := p.reader.ReadString('\n')
-		if err != nil {
+		if errors.Is(err, io.EOF) && strings.TrimSpace(input) == "" {
+			// Nothing chosen when input ends
+			fmt.Println()
+			return []int{}
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
 			fmt.Printf("❌ Error reading input: %v\n", err)
 			continue
 		}
@@ -190,19 +199,35 @@ func (p *Prompter) MultiSelect(question string, options []string) []int {
 		if input == "" {
 			return []int{}
 		}
+		if strings.EqualFold(input, "all") {
+			choices := make([]int, len(options))
+			for i := range choices {
+				choices[i] = i
+			}
+			return choices
+		}
 		
 		parts := strings.Fields(input)
 		choices := make([]int, 0, len(parts))
 		valid := true
 		
 		for _, part := range parts {
-			choice, err := strconv.Atoi(part)
-			if err != nil || choice < 1 || choice > len(options) {
+			from, to, isRange := strings.Cut(part, "-")
+			if !isRange {
+				to = from
+			}
+			first, err1 := strconv.Atoi(from)
+			last, err2 := strconv.Atoi(to)
+			if err1 != nil || err2 != nil || first < 1 || last > len(options) || first > last {
 				fmt.Printf("❌ Invalid choice '%s'. Please enter numbers between 1 and %d\n", part, len(options))
 				valid = false
 				break
 			}
-			choices = append(choices, choice-1)
+			for choice := first; choice <= last; choice++ {
+				if !slices.Contains(choices, choice-1) {
+					choices = append(choices, choice-1)
+				}
+			}
 		}
 		
 		if valid {
@@ -0,0 +1,177 @@
+// Package secretref resolves ${secret:...} references in config values, so
+// tokens and passphrases can stay in the system keyring, pass or the
+// environment instead of the config file.
+package secretref
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultService is the keyring service of references naming only an account
+const DefaultService = "git-sync"
+
+const (
+	lookupTimeout = 10 * time.Second
+	// Secrets are reused this long, so frequent syncs don't run a lookup each
+	cacheTTL = 5 * time.Minute
+)
+
+var referencePattern = regexp.MustCompile(`\$\{secret:([^}]*)\}`)
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]cachedSecret{}
+)
+
+// HasReference reports whether value holds a ${secret:...} reference
+func HasReference(value string) bool {
+	return referencePattern.MatchString(value)
+}
+
+// Validate checks the references in value are well-formed, without looking them up
+func Validate(value string) error {
+	for _, match := range referencePattern.FindAllStringSubmatch(value, -1) {
+		if _, _, err := parse(match[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve returns value with each ${secret:...} reference replaced by the
+// secret it names:
+//
+//	${secret:env:NAME}                 the environment variable NAME
+//	${secret:pass:path/to/entry}       the first line of the pass entry
+//	${secret:keyring:service/account}  the system keyring entry (libsecret or the macOS keychain)
+//	${secret:account}                  the keyring entry of account in the git-sync service
+//
+// Values without references are returned as they are.
+func Resolve(value string) (string, error) {
+	if !strings.Contains(value, "${secret:") {
+		return value, nil
+	}
+	var firstErr error
+	resolved := referencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		secret, err := lookup(referencePattern.FindStringSubmatch(reference)[1])
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return secret
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return resolved, nil
+}
+
+// parse splits a reference into its provider and the name of the secret there
+func parse(reference string) (provider, name string, err error) {
+	provider, name, ok := strings.Cut(reference, ":")
+	if !ok {
+		provider, name = "keyring", DefaultService+"/"+reference
+	}
+	switch provider {
+	case "env", "pass", "keyring":
+	default:
+		return "", "", fmt.Errorf("unknown secret provider '%s' in ${secret:%s}: must be env, pass or keyring", provider, reference)
+	}
+	if strings.TrimSpace(strings.TrimPrefix(name, DefaultService+"/")) == "" {
+		return "", "", fmt.Errorf("${secret:%s} doesn't name a secret", reference)
+	}
+	return provider, name, nil
+}
+
+// lookup returns the secret a reference names
+func lookup(reference string) (string, error) {
+	provider, name, err := parse(reference)
+	if err != nil {
+		return "", err
+	}
+	if provider == "env" {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return "", fmt.Errorf("failed to resolve ${secret:%s}: %s is not set", reference, name)
+		}
+		return value, nil
+	}
+
+	cacheMu.Lock()
+	cached, ok := cache[reference]
+	cacheMu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	var value string
+	switch provider {
+	case "pass":
+		value, err = run("pass", "show", name)
+		// The password is the first line, metadata may follow
+		value, _, _ = strings.Cut(value, "\n")
+	case "keyring":
+		value, err = keyringLookup(name)
+	}
+	if err == nil && value == "" {
+		err = fmt.Errorf("the secret is empty")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve ${secret:%s}: %w", reference, err)
+	}
+
+	cacheMu.Lock()
+	cache[reference] = cachedSecret{value: value, expires: time.Now().Add(cacheTTL)}
+	cacheMu.Unlock()
+	return value, nil
+}
+
+// keyringLookup reads the keyring entry named service/account, or account in the git-sync service
+func keyringLookup(name string) (string, error) {
+	service, account, ok := strings.Cut(name, "/")
+	if !ok {
+		service, account = DefaultService, name
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return run("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows":
+		return "", fmt.Errorf("the keyring provider is not supported on Windows, use env or pass")
+	}
+	return run("secret-tool", "lookup", "service", service, "account", account)
+}
+
+// run returns the output of a lookup command, without its trailing newline
+func run(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("%s is not installed", name)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		// secret-tool says nothing when there is no such entry
+		return "", fmt.Errorf("%s: %w, the secret was not found", name, err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
@@ -0,0 +1,205 @@
+// Package secrets detects likely credentials in patches before they are pushed,
+// using built-in rules or a gitleaks-compatible rules file.
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Rule describes one kind of secret
+type Rule struct {
+	ID          string
+	Description string
+
+	// Regex matches the secret in an added line; SecretGroup selects the
+	// capture group holding the secret itself (0 uses the whole match)
+	Regex       *regexp.Regexp
+	SecretGroup int
+
+	// Entropy is the minimum Shannon entropy (bits per character) a match needs; 0 disables the check
+	Entropy float64
+
+	// Keywords prefilter lines: at least one must appear (case-insensitively) for Regex to run
+	Keywords []string
+
+	// Path restricts the rule to matching files; a rule with only a path flags the file itself
+	Path *regexp.Regexp
+
+	Allowlist Allowlist
+}
+
+// Allowlist suppresses findings in known-safe files or with known-safe values
+type Allowlist struct {
+	Paths     []*regexp.Regexp
+	Regexes   []*regexp.Regexp
+	StopWords []string
+}
+
+// DefaultRules returns the built-in rules for common credential formats
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			ID:          "aws-access-key-id",
+			Description: "AWS access key ID",
+			Regex:       regexp.MustCompile(`\b((?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"akia", "asia", "abia", "acca"},
+		},
+		{
+			ID:          "github-token",
+			Description: "GitHub token",
+			Regex:       regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{82})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_"},
+		},
+		{
+			ID:          "gitlab-token",
+			Description: "GitLab personal access token",
+			Regex:       regexp.MustCompile(`\b(glpat-[A-Za-z0-9_-]{20,})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"glpat-"},
+		},
+		{
+			ID:          "slack-token",
+			Description: "Slack token",
+			Regex:       regexp.MustCompile(`\b(xox[abposr]-[A-Za-z0-9-]{10,})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"xox"},
+		},
+		{
+			ID:          "stripe-secret-key",
+			Description: "Stripe live secret key",
+			Regex:       regexp.MustCompile(`\b((?:sk|rk)_live_[A-Za-z0-9]{24,})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"_live_"},
+		},
+		{
+			ID:          "google-api-key",
+			Description: "Google API key",
+			Regex:       regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"aiza"},
+		},
+		{
+			ID:          "private-key",
+			Description: "Private key",
+			Regex:       regexp.MustCompile(`-----BEGIN[ A-Z0-9_-]{0,100}PRIVATE KEY(?: BLOCK)?-----`),
+			Keywords:    []string{"private key"},
+		},
+		{
+			ID:          "generic-secret",
+			Description: "Secret-looking value assigned to a key, token or password",
+			Regex:       regexp.MustCompile(`(?i)(?:api[_-]?key|secret|token|passw(?:or)?d|pwd|auth)[\w.-]{0,20}["']?\s*(?::|=|:=|=>)\s*["']?([A-Za-z0-9+/=_.~-]{16,})`),
+			SecretGroup: 1,
+			Entropy:     3.5,
+			Keywords:    []string{"key", "secret", "token", "pass", "pwd", "auth"},
+		},
+	}
+}
+
+// gitleaksConfig is the subset of the gitleaks configuration format git-sync understands
+type gitleaksConfig struct {
+	Extend struct {
+		UseDefault bool `toml:"useDefault"`
+	} `toml:"extend"`
+	Rules []struct {
+		ID          string          `toml:"id"`
+		Description string          `toml:"description"`
+		Regex       string          `toml:"regex"`
+		SecretGroup int             `toml:"secretGroup"`
+		Entropy     float64         `toml:"entropy"`
+		Keywords    []string        `toml:"keywords"`
+		Path        string          `toml:"path"`
+		Allowlist   allowlistConfig `toml:"allowlist"`
+	} `toml:"rules"`
+	Allowlist allowlistConfig `toml:"allowlist"`
+}
+
+type allowlistConfig struct {
+	Paths     []string `toml:"paths"`
+	Regexes   []string `toml:"regexes"`
+	StopWords []string `toml:"stopwords"`
+}
+
+// LoadRules reads a gitleaks-compatible rules file. The built-in rules are
+// included too when the file sets [extend] useDefault = true.
+func LoadRules(path string) ([]Rule, Allowlist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Allowlist{}, fmt.Errorf("failed to read secret rules: %w", err)
+	}
+
+	var cfg gitleaksConfig
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return nil, Allowlist{}, fmt.Errorf("failed to parse secret rules %s: %w", path, err)
+	}
+
+	var rules []Rule
+	if cfg.Extend.UseDefault {
+		rules = DefaultRules()
+	}
+
+	for _, rc := range cfg.Rules {
+		if rc.ID == "" {
+			return nil, Allowlist{}, fmt.Errorf("invalid secret rules %s: rule without an id", path)
+		}
+		if rc.Regex == "" && rc.Path == "" {
+			return nil, Allowlist{}, fmt.Errorf("invalid secret rule '%s': needs a regex or a path", rc.ID)
+		}
+
+		rule := Rule{
+			ID:          rc.ID,
+			Description: rc.Description,
+			SecretGroup: rc.SecretGroup,
+			Entropy:     rc.Entropy,
+			Keywords:    rc.Keywords,
+		}
+		if rc.Regex != "" {
+			if rule.Regex, err = regexp.Compile(rc.Regex); err != nil {
+				return nil, Allowlist{}, fmt.Errorf("invalid regex in secret rule '%s': %w", rc.ID, err)
+			}
+		}
+		if rc.Path != "" {
+			if rule.Path, err = regexp.Compile(rc.Path); err != nil {
+				return nil, Allowlist{}, fmt.Errorf("invalid path in secret rule '%s': %w", rc.ID, err)
+			}
+		}
+		if rule.Allowlist, err = compileAllowlist(rc.Allowlist); err != nil {
+			return nil, Allowlist{}, fmt.Errorf("invalid allowlist in secret rule '%s': %w", rc.ID, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	allowlist, err := compileAllowlist(cfg.Allowlist)
+	if err != nil {
+		return nil, Allowlist{}, fmt.Errorf("invalid allowlist in secret rules %s: %w", path, err)
+	}
+
+	if len(rules) == 0 {
+		return nil, Allowlist{}, fmt.Errorf("invalid secret rules %s: no rules defined", path)
+	}
+	return rules, allowlist, nil
+}
+
+func compileAllowlist(cfg allowlistConfig) (Allowlist, error) {
+	allowlist := Allowlist{StopWords: cfg.StopWords}
+	for _, pattern := range cfg.Paths {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return Allowlist{}, err
+		}
+		allowlist.Paths = append(allowlist.Paths, re)
+	}
+	for _, pattern := range cfg.Regexes {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return Allowlist{}, err
+		}
+		allowlist.Regexes = append(allowlist.Regexes, re)
+	}
+	return allowlist, nil
+}
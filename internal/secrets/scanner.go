@@ -0,0 +1,245 @@
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// allowMarkers suppress findings on the line they appear on
+var allowMarkers = []string{"gitsync:allow", "gitleaks:allow"}
+
+// Finding is a likely secret added by a commit
+type Finding struct {
+	RuleID string
+	Commit string
+	File   string
+	Line   int
+	Secret string // redacted
+}
+
+func (f Finding) String() string {
+	location := f.File
+	if f.Line > 0 {
+		location = fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	if f.Commit != "" {
+		return fmt.Sprintf("%s in %s (commit %s)", f.RuleID, location, shortHash(f.Commit))
+	}
+	return fmt.Sprintf("%s in %s", f.RuleID, location)
+}
+
+// Scanner checks patches against a set of rules
+type Scanner struct {
+	rules     []Rule
+	allowlist Allowlist
+}
+
+// NewScanner loads the rules file at rulesPath, or uses the built-in rules when it is empty
+func NewScanner(rulesPath string) (*Scanner, error) {
+	if rulesPath == "" {
+		return &Scanner{rules: DefaultRules()}, nil
+	}
+	rules, allowlist, err := LoadRules(rulesPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Scanner{rules: rules, allowlist: allowlist}, nil
+}
+
+// ScanPatch scans the lines added in patch, the output of `git log -p` with each
+// commit introduced by a "commit <hash>" line. Each secret is reported once per file.
+func (s *Scanner) ScanPatch(patch string) []Finding {
+	var (
+		findings []Finding
+		commit   string
+		file     string
+		line     int
+		inHunk   bool
+	)
+	seen := make(map[string]bool)
+	report := func(f Finding) {
+		key := f.RuleID + "\x00" + f.File + "\x00" + f.Secret
+		if !seen[key] {
+			seen[key] = true
+			findings = append(findings, f)
+		}
+	}
+
+	for _, text := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(text, "commit "):
+			commit = strings.TrimSpace(strings.TrimPrefix(text, "commit "))
+			inHunk = false
+		case strings.HasPrefix(text, "diff --git "):
+			file = ""
+			inHunk = false
+		case !inHunk && strings.HasPrefix(text, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/")
+			if file == "/dev/null" {
+				file = ""
+				continue
+			}
+			for _, f := range s.scanPath(file) {
+				f.Commit = commit
+				report(f)
+			}
+		case !inHunk && strings.HasPrefix(text, "Binary files ") && strings.HasSuffix(text, " differ"):
+			// Binary files have no hunks, but path rules still apply to them
+			_, added, _ := strings.Cut(strings.TrimSuffix(text, " differ"), " and ")
+			if path, ok := strings.CutPrefix(added, "b/"); ok {
+				for _, f := range s.scanPath(path) {
+					f.Commit = commit
+					report(f)
+				}
+			}
+		case strings.HasPrefix(text, "@@ "):
+			line = hunkStart(text)
+			inHunk = true
+		case inHunk && strings.HasPrefix(text, "+"):
+			if file != "" {
+				for _, f := range s.scanLine(text[1:], file, line) {
+					f.Commit = commit
+					report(f)
+				}
+			}
+			line++
+		case inHunk && strings.HasPrefix(text, " "):
+			line++
+		}
+	}
+	return findings
+}
+
+// scanPath applies the path-only rules to an added or modified file
+func (s *Scanner) scanPath(file string) []Finding {
+	var findings []Finding
+	for _, rule := range s.rules {
+		if rule.Regex == nil && rule.Path.MatchString(file) && !s.allowed(rule, file, "") {
+			findings = append(findings, Finding{RuleID: rule.ID, File: file})
+		}
+	}
+	return findings
+}
+
+// scanLine applies every content rule to one added line
+func (s *Scanner) scanLine(text, file string, line int) []Finding {
+	for _, marker := range allowMarkers {
+		if strings.Contains(text, marker) {
+			return nil
+		}
+	}
+
+	var findings []Finding
+	lower := strings.ToLower(text)
+	for _, rule := range s.rules {
+		if rule.Regex == nil || !hasKeyword(lower, rule.Keywords) {
+			continue
+		}
+		if rule.Path != nil && !rule.Path.MatchString(file) {
+			continue
+		}
+
+		for _, match := range rule.Regex.FindAllStringSubmatch(text, -1) {
+			secret := match[0]
+			if rule.SecretGroup > 0 && rule.SecretGroup < len(match) {
+				secret = match[rule.SecretGroup]
+			}
+			if secret == "" || (rule.Entropy > 0 && shannonEntropy(secret) < rule.Entropy) {
+				continue
+			}
+			if s.allowed(rule, file, secret) {
+				continue
+			}
+			findings = append(findings, Finding{RuleID: rule.ID, File: file, Line: line, Secret: redact(secret)})
+		}
+	}
+	return findings
+}
+
+// allowed reports whether the global or rule allowlist covers the file or secret
+func (s *Scanner) allowed(rule Rule, file, secret string) bool {
+	for _, allowlist := range []Allowlist{s.allowlist, rule.Allowlist} {
+		for _, re := range allowlist.Paths {
+			if re.MatchString(file) {
+				return true
+			}
+		}
+		if secret == "" {
+			continue
+		}
+		for _, re := range allowlist.Regexes {
+			if re.MatchString(secret) {
+				return true
+			}
+		}
+		for _, word := range allowlist.StopWords {
+			if strings.Contains(strings.ToLower(secret), strings.ToLower(word)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasKeyword(lower string, keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+	for _, keyword := range keywords {
+		if strings.Contains(lower, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// hunkStart returns the first new-file line number of a "@@ -a,b +c,d @@" header
+func hunkStart(header string) int {
+	_, rest, found := strings.Cut(header, " +")
+	if !found {
+		return 0
+	}
+	end := strings.IndexAny(rest, ", ")
+	if end < 0 {
+		end = len(rest)
+	}
+	start, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return 0
+	}
+	return start
+}
+
+// shannonEntropy returns the entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// redact keeps just enough of a secret to recognize it
+func redact(secret string) string {
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return secret[:4] + "****"
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
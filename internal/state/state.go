@@ -0,0 +1,310 @@
+// Package state persists runtime state shared between the CLI and the daemon,
+// such as temporary force-push arming, failure pauses and paused repositories, in
+// the git-sync state directory.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bnema/git-sync/internal/filelock"
+	"github.com/bnema/git-sync/internal/paths"
+)
+
+const stateFileName = "state.json"
+
+// ErrPausedAll rejects resuming a single repository while every one is paused
+var ErrPausedAll = errors.New("all repositories are paused, run 'git sync resume --all' to resume them")
+
+// State is the persisted runtime state
+type State struct {
+	// ForcePushArmed maps repository paths to the time their force-push window ends
+	ForcePushArmed map[string]time.Time `json:"force_push_armed,omitempty"`
+
+	// Circuits maps repository paths to their run of failed syncs
+	Circuits map[string]Circuit `json:"circuits,omitempty"`
+
+	// PausedRepos maps the repositories paused with `git sync pause` to when they were paused
+	PausedRepos map[string]time.Time `json:"paused_repos,omitempty"`
+
+	// PausedAllSince is when `git sync pause --all` paused every repository; zero when not
+	PausedAllSince time.Time `json:"paused_all_since,omitempty"`
+}
+
+// Circuit is a repository's run of consecutive failed syncs, and the pause the
+// circuit breaker put it in once the run got too long
+type Circuit struct {
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"last_error,omitempty"`
+	PausedUntil time.Time `json:"paused_until,omitempty"`
+}
+
+// Load reads the state file; a missing file yields an empty state
+func Load() (*State, error) {
+	path, err := filePath()
+	if err != nil {
+		return nil, err
+	}
+	return load(path)
+}
+
+// Update applies fn to the current state and saves the result. Updates hold an
+// exclusive lock so the CLI and daemon never lose each other's changes.
+func Update(fn func(*State)) error {
+	path, err := filePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open state lock: %w", err)
+	}
+	defer lock.Close()
+
+	if err := filelock.Lock(lock); err != nil {
+		return fmt.Errorf("failed to lock state: %w", err)
+	}
+	defer func() { _ = filelock.Unlock(lock) }()
+
+	s, err := load(path)
+	if err != nil {
+		return err
+	}
+
+	fn(s)
+	s.pruneExpired(time.Now())
+
+	return s.save(path)
+}
+
+// ForcePushArmedUntil returns when the force-push window of repoPath ends, if armed
+func (s *State) ForcePushArmedUntil(repoPath string, now time.Time) (time.Time, bool) {
+	until, armed := s.ForcePushArmed[repoPath]
+	if !armed || !until.After(now) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// ArmForcePush permits force pushes to repoPath until the given time
+func (s *State) ArmForcePush(repoPath string, until time.Time) {
+	if s.ForcePushArmed == nil {
+		s.ForcePushArmed = make(map[string]time.Time)
+	}
+	s.ForcePushArmed[repoPath] = until
+}
+
+// DisarmForcePush ends the force-push window of repoPath, reporting whether one was active
+func (s *State) DisarmForcePush(repoPath string, now time.Time) bool {
+	_, armed := s.ForcePushArmedUntil(repoPath, now)
+	delete(s.ForcePushArmed, repoPath)
+	return armed
+}
+
+// Paused returns the circuit of repoPath if the circuit breaker has paused its syncs
+func (s *State) Paused(repoPath string, now time.Time) (Circuit, bool) {
+	circuit, exists := s.Circuits[repoPath]
+	if !exists || !circuit.PausedUntil.After(now) {
+		return Circuit{}, false
+	}
+	return circuit, true
+}
+
+// RecordFailure counts a failed sync of repoPath, pausing it for cooldown once
+// failures syncs in a row have failed. It returns the updated circuit and
+// whether this failure paused the repository.
+func (s *State) RecordFailure(repoPath, errMsg string, failures int, cooldown time.Duration, now time.Time) (Circuit, bool) {
+	if s.Circuits == nil {
+		s.Circuits = make(map[string]Circuit)
+	}
+	circuit := s.Circuits[repoPath]
+	circuit.Failures++
+	circuit.LastError = errMsg
+	paused := circuit.Failures >= failures
+	if paused {
+		circuit.PausedUntil = now.Add(cooldown)
+	}
+	s.Circuits[repoPath] = circuit
+	return circuit, paused
+}
+
+// ResetCircuit forgets the failed syncs of repoPath, reporting whether it was paused
+func (s *State) ResetCircuit(repoPath string, now time.Time) bool {
+	_, paused := s.Paused(repoPath, now)
+	delete(s.Circuits, repoPath)
+	return paused
+}
+
+// PausedSince returns when repoPath was paused with `git sync pause`, alone or with --all
+func (s *State) PausedSince(repoPath string) (time.Time, bool) {
+	if !s.PausedAllSince.IsZero() {
+		return s.PausedAllSince, true
+	}
+	since, paused := s.PausedRepos[repoPath]
+	return since, paused
+}
+
+// Pause stops the syncs of repoPath until it is resumed, reporting whether it was running
+func (s *State) Pause(repoPath string, now time.Time) bool {
+	if _, paused := s.PausedSince(repoPath); paused {
+		return false
+	}
+	if s.PausedRepos == nil {
+		s.PausedRepos = make(map[string]time.Time)
+	}
+	s.PausedRepos[repoPath] = now
+	return true
+}
+
+// PauseAll stops the syncs of every repository until they are resumed, reporting
+// whether they weren't paused already
+func (s *State) PauseAll(now time.Time) bool {
+	if !s.PausedAllSince.IsZero() {
+		return false
+	}
+	s.PausedAllSince = now
+	return true
+}
+
+// Unpause resumes the syncs of repoPath, reporting whether it was paused on its own
+func (s *State) Unpause(repoPath string) bool {
+	_, paused := s.PausedRepos[repoPath]
+	delete(s.PausedRepos, repoPath)
+	return paused
+}
+
+// UnpauseAll resumes every paused repository, returning how many pauses ended
+// (a --all pause counts once)
+func (s *State) UnpauseAll() int {
+	resumed := len(s.PausedRepos)
+	if !s.PausedAllSince.IsZero() {
+		resumed++
+	}
+	s.PausedRepos = nil
+	s.PausedAllSince = time.Time{}
+	return resumed
+}
+
+// Resume lifts the pause and the open circuit of repoPath, reporting whether
+// either held it back. It fails with ErrPausedAll while everything is paused.
+func (s *State) Resume(repoPath string, now time.Time) (bool, error) {
+	if !s.PausedAllSince.IsZero() {
+		return false, ErrPausedAll
+	}
+	unpaused := s.Unpause(repoPath)
+	return s.ResetCircuit(repoPath, now) || unpaused, nil
+}
+
+// ResumeAll lifts every pause and open circuit, returning how many ended
+func (s *State) ResumeAll(now time.Time) int {
+	resumed := s.UnpauseAll()
+	for repoPath := range s.Circuits {
+		if s.ResetCircuit(repoPath, now) {
+			resumed++
+		}
+	}
+	return resumed
+}
+
+// pruneExpired drops force-push windows that have ended
+func (s *State) pruneExpired(now time.Time) {
+	for repoPath, until := range s.ForcePushArmed {
+		if !until.After(now) {
+			delete(s.ForcePushArmed, repoPath)
+		}
+	}
+}
+
+func filePath() (string, error) {
+	dir, err := paths.StateDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve state directory: %w", err)
+	}
+	return filepath.Join(dir, stateFileName), nil
+}
+
+func load(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// save writes the state atomically so readers never see a partial file
+func (s *State) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
+
+// Cache keeps the state in memory for frequent readers, reading the file again
+// only once a save replaced it
+type Cache struct {
+	mu    sync.Mutex
+	info  os.FileInfo // of the file read, nil when it was missing
+	state *State
+}
+
+// Load returns the state, from memory while the file is unchanged. The state
+// is shared between callers, who must not modify it.
+func (c *Cache) Load() (*State, error) {
+	path, err := filePath()
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state != nil && sameFile(c.info, info) {
+		return c.state, nil
+	}
+	s, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	c.info, c.state = info, s
+	return s, nil
+}
+
+// sameFile reports whether a and b, either nil for a missing file, are one
+// unchanged file. Saves rename a new file into place.
+func sameFile(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
@@ -5,6 +5,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+
+	"github.com/bnema/git-sync/internal/control"
+	"github.com/bnema/git-sync/internal/paths"
 )
 
 const serviceTemplate = `[Unit]
@@ -13,10 +17,11 @@ After=network.target
 
 [Service]
 Type=notify
-ExecStart=%s daemon
+ExecStart=%s
 Restart=always
 RestartSec=10
-Environment=HOME=%%h
+WatchdogSec=120
+Environment=HOME=%%h%s
 WorkingDirectory=%%h
 
 # Logging
@@ -29,11 +34,60 @@ NoNewPrivileges=true
 PrivateTmp=true
 ProtectSystem=strict
 ProtectHome=false
-ReadWritePaths=%%h
+ReadWritePaths=%%h%s
 
 [Install]
 WantedBy=default.target`
 
+// socketTemplate has systemd hold the control socket, starting the daemon on
+// the first connection when it isn't running. It listens where the CLI looks,
+// as systemd's %t is only that directory when XDG_RUNTIME_DIR is set.
+const socketTemplate = `[Unit]
+Description=Git Sync Daemon Control Socket
+
+[Socket]
+ListenStream=%s
+SocketMode=0600
+DirectoryMode=0700
+RemoveOnStop=true
+
+[Install]
+WantedBy=sockets.target`
+
+// renderService fills serviceTemplate, quoting what systemd would otherwise
+// split on spaces or expand as specifiers
+func renderService(binaryPath string, service ServiceOptions) string {
+	// ExecStart also expands $VARIABLE
+	execStart := []string{quoteUnitValue(strings.ReplaceAll(binaryPath, "$", "$$")), "daemon"}
+	for _, arg := range service.Args {
+		execStart = append(execStart, quoteUnitValue(strings.ReplaceAll(arg, "$", "$$")))
+	}
+
+	var environment strings.Builder
+	for _, env := range service.Environment {
+		environment.WriteString("\nEnvironment=" + quoteUnitValue(env))
+	}
+
+	var writable strings.Builder
+	for _, path := range service.WritablePaths {
+		// "-" keeps the unit starting when the directory is yet to be created
+		writable.WriteString(" " + quoteUnitValue("-"+path))
+	}
+
+	return fmt.Sprintf(serviceTemplate, strings.Join(execStart, " "), environment.String(), writable.String())
+}
+
+// quoteUnitValue escapes % for systemd, double-quoting values holding spaces or quotes
+func quoteUnitValue(value string) string {
+	value = strings.ReplaceAll(value, "%", "%%")
+	if !strings.ContainsAny(value, " \t\"'\\") {
+		return value
+	}
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	value = strings.ReplaceAll(value, "\"", "\\\"")
+	return "\"" + value + "\""
+}
+
 const timerTemplate = `[Unit]
 Description=Git Sync Daemon Timer
 Requires=git-sync-daemon.service
@@ -46,7 +100,20 @@ Persistent=true
 [Install]
 WantedBy=timers.target`
 
-func InstallUserService(binaryPath string, enableLinger, autoStart bool) error {
+// ServiceOptions carries the settings of the installing command into the
+// service, which would otherwise run with the default paths
+type ServiceOptions struct {
+	// Args follow "daemon" on the ExecStart line, e.g. --config-dir
+	Args []string
+	// Environment holds NAME=value overrides with no flag, e.g. GIT_SYNC_STATE_DIR
+	Environment []string
+	// WritablePaths are directories the daemon writes to besides the home directory
+	WritablePaths []string
+}
+
+// InstallUserService installs and enables the daemon's units, with the socket
+// unit only when socketActivation is set
+func InstallUserService(binaryPath string, service ServiceOptions, enableLinger, autoStart, socketActivation bool) error {
 	// Get user config directory
 	userConfigDir, err := getUserConfigDir()
 	if err != nil {
@@ -66,7 +133,7 @@ func InstallUserService(binaryPath string, enableLinger, autoStart bool) error {
 	}
 
 	// Create service file
-	serviceContent := fmt.Sprintf(serviceTemplate, absPath)
+	serviceContent := renderService(absPath, service)
 	servicePath := filepath.Join(systemdDir, "git-sync-daemon.service")
 
 	if err := os.WriteFile(servicePath, []byte(serviceContent), 0644); err != nil {
@@ -83,6 +150,24 @@ func InstallUserService(binaryPath string, enableLinger, autoStart bool) error {
 
 	fmt.Printf("✓ Created systemd timer file: %s\n", timerPath)
 
+	// Create socket file, or drop the one an earlier install left
+	socketPath := filepath.Join(systemdDir, "git-sync-daemon.socket")
+	if socketActivation {
+		socketContent := fmt.Sprintf(socketTemplate, control.SocketPath())
+		if err := os.WriteFile(socketPath, []byte(socketContent), 0644); err != nil {
+			return fmt.Errorf("failed to write socket file: %w", err)
+		}
+
+		fmt.Printf("✓ Created systemd socket file: %s\n", socketPath)
+	} else if _, err := os.Stat(socketPath); err == nil {
+		_ = runSystemdCommand("disable", "--now", "git-sync-daemon.socket")
+		if err := os.Remove(socketPath); err != nil {
+			return fmt.Errorf("failed to remove socket file: %w", err)
+		}
+
+		fmt.Printf("✓ Removed systemd socket file: %s\n", socketPath)
+	}
+
 	// Reload systemd
 	if err := runSystemdCommand("daemon-reload"); err != nil {
 		return fmt.Errorf("failed to reload systemd: %w", err)
@@ -91,6 +176,14 @@ func InstallUserService(binaryPath string, enableLinger, autoStart bool) error {
 	fmt.Println("✓ Reloaded systemd user daemon")
 
 	// Enable services
+	if socketActivation {
+		if err := runSystemdCommand("enable", "git-sync-daemon.socket"); err != nil {
+			return fmt.Errorf("failed to enable socket: %w", err)
+		}
+
+		fmt.Println("✓ Enabled git-sync-daemon.socket")
+	}
+
 	if err := runSystemdCommand("enable", "git-sync-daemon.service"); err != nil {
 		return fmt.Errorf("failed to enable service: %w", err)
 	}
@@ -105,6 +198,15 @@ func InstallUserService(binaryPath string, enableLinger, autoStart bool) error {
 
 	// Start services if requested
 	if autoStart {
+		// The socket first, so the daemon is handed it instead of creating its own
+		if socketActivation {
+			if err := runSystemdCommand("start", "git-sync-daemon.socket"); err != nil {
+				fmt.Printf("⚠️  Warning: Failed to start socket: %v\n", err)
+			} else {
+				fmt.Println("✓ Started git-sync-daemon.socket")
+			}
+		}
+
 		if err := runSystemdCommand("start", "git-sync-daemon.service"); err != nil {
 			fmt.Printf("⚠️  Warning: Failed to start service: %v\n", err)
 		} else {
@@ -150,6 +252,9 @@ func UninstallUserService() error {
 	if err := runSystemdCommand("stop", "git-sync-daemon.timer"); err != nil {
 		fmt.Printf("Warning: Failed to stop git-sync-daemon.timer: %v\n", err)
 	}
+	if err := runSystemdCommand("stop", "git-sync-daemon.socket"); err != nil {
+		fmt.Printf("Warning: Failed to stop git-sync-daemon.socket: %v\n", err)
+	}
 
 	// Disable services
 	if err := runSystemdCommand("disable", "git-sync-daemon.service"); err != nil {
@@ -158,6 +263,9 @@ func UninstallUserService() error {
 	if err := runSystemdCommand("disable", "git-sync-daemon.timer"); err != nil {
 		fmt.Printf("Warning: Failed to disable git-sync-daemon.timer: %v\n", err)
 	}
+	if err := runSystemdCommand("disable", "git-sync-daemon.socket"); err != nil {
+		fmt.Printf("Warning: Failed to disable git-sync-daemon.socket: %v\n", err)
+	}
 
 	// Get user config directory
 	userConfigDir, err := getUserConfigDir()
@@ -178,6 +286,11 @@ func UninstallUserService() error {
 		return fmt.Errorf("failed to remove timer file: %w", err)
 	}
 
+	socketPath := filepath.Join(systemdDir, "git-sync-daemon.socket")
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove socket file: %w", err)
+	}
+
 	// Reload systemd
 	if err := runSystemdCommand("daemon-reload"); err != nil {
 		fmt.Printf("Warning: Failed to reload systemd: %v\n", err)
@@ -193,17 +306,39 @@ func GetServiceStatus() (bool, error) {
 	return err == nil, nil
 }
 
-func getUserConfigDir() (string, error) {
-	if xdgConfig := os.Getenv("XDG_CONFIG_HOME"); xdgConfig != "" {
-		return xdgConfig, nil
-	}
+// ServiceState describes the git-sync-daemon.service user unit
+type ServiceState struct {
+	UnitFile  string // where the unit file is installed
+	Installed bool
+	Enabled   string // systemctl is-enabled answer, e.g. enabled or disabled
+	Active    string // systemctl is-active answer, e.g. active, inactive or failed
+}
 
-	home, err := os.UserHomeDir()
+// GetServiceState reads whether the daemon's unit is installed, enabled and
+// running, failing when systemctl is unavailable
+func GetServiceState() (ServiceState, error) {
+	var state ServiceState
+	userConfigDir, err := getUserConfigDir()
 	if err != nil {
-		return "", err
+		return state, fmt.Errorf("failed to get user config directory: %w", err)
 	}
+	state.UnitFile = filepath.Join(userConfigDir, "systemd", "user", "git-sync-daemon.service")
+	_, err = os.Stat(state.UnitFile)
+	state.Installed = err == nil
 
-	return filepath.Join(home, ".config"), nil
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return state, fmt.Errorf("systemctl not found: %w", err)
+	}
+	// Both print their answer and exit non-zero for anything but enabled and active
+	enabled, _ := exec.Command("systemctl", "--user", "is-enabled", "git-sync-daemon.service").Output()
+	active, _ := exec.Command("systemctl", "--user", "is-active", "git-sync-daemon.service").Output()
+	state.Enabled = strings.TrimSpace(string(enabled))
+	state.Active = strings.TrimSpace(string(active))
+	return state, nil
+}
+
+func getUserConfigDir() (string, error) {
+	return paths.XDGConfigHome()
 }
 
 func runSystemdCommand(args ...string) error {
@@ -0,0 +1,193 @@
+// Package gitsync runs the sync engine, scheduler and history store of the
+// git-sync daemon in other Go programs, without shelling out to the CLI. It is
+// a thin layer over the daemon: its types are aliases of the daemon's and the
+// config's, and importing it links the daemon's dependencies, go-systemd,
+// D-Bus, SQLite and viper among them.
+//
+//	engine := gitsync.NewEngine(gitsync.Options{})
+//	repo := gitsync.DefaultRepoConfig("/home/me/notes")
+//	repo.Direction = "both"
+//	if err := engine.Sync(ctx, repo); err != nil {
+//		if reason, ok := gitsync.SkipReasonOf(err); ok {
+//			log.Printf("skipped: %s", reason)
+//		}
+//	}
+package gitsync
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/bnema/git-sync/internal/config"
+	"github.com/bnema/git-sync/internal/daemon"
+)
+
+// RepoConfig describes a single repository to sync, with the fields of a
+// [[repositories]] entry
+type RepoConfig = config.RepoConfig
+
+// HistoryEntry is a single recorded sync result, as the daemon records it
+type HistoryEntry = daemon.SyncHistoryEntry
+
+// SkipError is returned when a sync was deliberately skipped (e.g. dirty worktree)
+type SkipError = daemon.SkipError
+
+// Options configures an Engine. Zero values use sensible defaults.
+type Options struct {
+	// Logger receives engine logs; defaults to discarding them
+	Logger *slog.Logger
+	// MaxConcurrent limits simultaneous syncs; defaults to 5
+	MaxConcurrent int
+}
+
+// Engine performs repository syncs with bounded concurrency
+type Engine struct {
+	syncManager *daemon.SyncManager
+	logger      *slog.Logger
+}
+
+// NewEngine creates a sync engine
+func NewEngine(opts Options) *Engine {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	maxConcurrent := opts.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = 5
+	}
+
+	return &Engine{
+		syncManager: daemon.NewSyncManager(maxConcurrent, logger),
+		logger:      logger,
+	}
+}
+
+// Sync performs a single sync of the repository. Skipped syncs return a *SkipError.
+func (e *Engine) Sync(ctx context.Context, repo RepoConfig) error {
+	return e.syncManager.SyncRepository(ctx, repo)
+}
+
+// DefaultRepoConfig returns the settings `git sync init` uses by default
+func DefaultRepoConfig(path string) RepoConfig {
+	return RepoConfig{
+		Path:           path,
+		Enabled:        true,
+		Direction:      "push",
+		Interval:       300,
+		Remote:         "origin",
+		BranchStrategy: "current",
+		SafetyChecks:   true,
+	}
+}
+
+// SkipReasonOf returns the machine-readable skip reason if err is a skipped sync
+func SkipReasonOf(err error) (string, bool) {
+	skipErr, ok := daemon.AsSkipError(err)
+	if !ok {
+		return "", false
+	}
+	return string(skipErr.Reason), true
+}
+
+// HistoryStore persists sync results as JSON Lines or in a SQLite database
+type HistoryStore struct {
+	manager *daemon.HistoryManager
+}
+
+// HistoryOptions configures a HistoryStore. Zero values use the daemon defaults.
+type HistoryOptions struct {
+	// Dir holds the history file; defaults to the git-sync cache directory
+	Dir           string
+	MaxEntries    int
+	RetentionDays int
+	MaxFileSizeMB int
+	// Backend is "jsonl" (the default) or "sqlite"
+	Backend string
+	Logger  *slog.Logger
+}
+
+// OpenHistory opens (creating if needed) a history store
+func OpenHistory(opts HistoryOptions) (*HistoryStore, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 1000
+	}
+	if opts.RetentionDays <= 0 {
+		opts.RetentionDays = 30
+	}
+	if opts.MaxFileSizeMB <= 0 {
+		opts.MaxFileSizeMB = 10
+	}
+
+	manager, err := daemon.NewHistoryManager(opts.Dir, opts.MaxEntries, opts.RetentionDays, opts.MaxFileSizeMB, opts.Backend, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryStore{manager: manager}, nil
+}
+
+// Record appends an entry; a zero Timestamp is set to now
+func (h *HistoryStore) Record(entry HistoryEntry) {
+	h.manager.RecordSync(entry)
+}
+
+// Query returns entries newest first. An empty repoPath matches all repositories
+// and a limit of 0 returns everything.
+func (h *HistoryStore) Query(limit int, repoPath string, failedOnly bool) ([]HistoryEntry, error) {
+	return h.manager.GetHistory(limit, repoPath, failedOnly)
+}
+
+// Prune removes entries older than the retention period
+func (h *HistoryStore) Prune() error {
+	return h.manager.CleanOldEntries()
+}
+
+// Close closes the store's database, if it has one
+func (h *HistoryStore) Close() error {
+	return h.manager.Close()
+}
+
+// Scheduler periodically syncs repositories using an Engine, recording results
+// in an optional HistoryStore
+type Scheduler struct {
+	scheduler *daemon.Scheduler
+	engine    *Engine
+}
+
+// NewScheduler creates a scheduler; history may be nil
+func NewScheduler(engine *Engine, history *HistoryStore) *Scheduler {
+	var manager *daemon.HistoryManager
+	if history != nil {
+		manager = history.manager
+	}
+	return &Scheduler{
+		scheduler: daemon.NewScheduler(engine.logger, manager, nil, nil),
+		engine:    engine,
+	}
+}
+
+// Start schedules every enabled repository at its interval (in seconds, must be
+// positive) until ctx is cancelled or Stop is called
+func (s *Scheduler) Start(ctx context.Context, repos []RepoConfig) {
+	s.scheduler.Start(ctx, repos, s.engine.syncManager)
+}
+
+// Stop stops all scheduled syncs, waiting briefly for in-flight ones
+func (s *Scheduler) Stop() {
+	s.scheduler.Stop()
+}
+
+// ScheduledRepos returns the paths currently scheduled
+func (s *Scheduler) ScheduledRepos() []string {
+	status := s.scheduler.GetStatus()
+	paths := make([]string, 0, len(status))
+	for path := range status {
+		paths = append(paths, path)
+	}
+	return paths
+}